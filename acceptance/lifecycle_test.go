@@ -312,12 +312,14 @@ var _ = Describe("bosh-init", func() {
 			Expect(deployingSteps[3]).To(MatchRegexp("^  Attaching disk '.*' to VM '.*'" + stageFinishedPattern))
 			Expect(deployingSteps[4]).To(MatchRegexp("^  Rendering job templates" + stageFinishedPattern))
 
-			for _, line := range deployingSteps[5 : numDeployingSteps-2] {
+			for _, line := range deployingSteps[5 : numDeployingSteps-4] {
 				Expect(line).To(MatchRegexp("^  Compiling package '.*/.*'" + stageFinishedPattern))
 			}
 
-			Expect(deployingSteps[numDeployingSteps-2]).To(MatchRegexp("^  Updating instance 'dummy_job/0'" + stageFinishedPattern))
-			Expect(deployingSteps[numDeployingSteps-1]).To(MatchRegexp("^  Waiting for instance 'dummy_job/0' to be running" + stageFinishedPattern))
+			Expect(deployingSteps[numDeployingSteps-4]).To(MatchRegexp("^  Updating instance 'dummy_job/0'" + stageFinishedPattern))
+			Expect(deployingSteps[numDeployingSteps-3]).To(MatchRegexp("^  Waiting for instance 'dummy_job/0' to be running" + stageFinishedPattern))
+			Expect(deployingSteps[numDeployingSteps-2]).To(MatchRegexp("^  Running post-start script on instance 'dummy_job/0'" + stageFinishedPattern))
+			Expect(deployingSteps[numDeployingSteps-1]).To(MatchRegexp("^  Running post-deploy script on instance 'dummy_job/0'" + stageFinishedPattern))
 
 			println("#################################################")
 			println("it sets the ssh password")
@@ -1,18 +1,25 @@
 package blobstore
 
 import (
+	"crypto/sha1"
+	"fmt"
 	"io"
 	"os"
+	"time"
 
-	boshdavcli "github.com/cloudfoundry/bosh-agent/davcli/client"
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	boshretry "github.com/cloudfoundry/bosh-agent/retrystrategy"
 	boshsys "github.com/cloudfoundry/bosh-agent/system"
+	boshtime "github.com/cloudfoundry/bosh-agent/time"
 	boshuuid "github.com/cloudfoundry/bosh-agent/uuid"
+
+	biui "github.com/cloudfoundry/bosh-init/ui"
 )
 
 type Blobstore interface {
-	Get(blobID string) (LocalBlob, error)
+	// Get downloads the blob to a local temp file, verifying its SHA1 unless expectedSHA1 is empty
+	Get(blobID string, expectedSHA1 string) (LocalBlob, error)
 	Add(sourcePath string) (blobID string, err error)
 }
 
@@ -22,51 +29,121 @@ type Config struct {
 	Password string
 }
 
+// SHA1MismatchError is returned when the content of a downloaded blob does not match its expected SHA1
+type SHA1MismatchError struct {
+	BlobID       string
+	ExpectedSHA1 string
+	ActualSHA1   string
+}
+
+func (e SHA1MismatchError) Error() string {
+	return fmt.Sprintf("Blob '%s' has SHA1 '%s', expected '%s'", e.BlobID, e.ActualSHA1, e.ExpectedSHA1)
+}
+
 type blobstore struct {
-	davClient     boshdavcli.Client
+	davClient     DavClient
 	uuidGenerator boshuuid.Generator
 	fs            boshsys.FileSystem
+	getAttempts   int
+	getDelay      time.Duration
+	timeService   boshtime.Service
 	logger        boshlog.Logger
 	logTag        string
 }
 
-func NewBlobstore(davClient boshdavcli.Client, uuidGenerator boshuuid.Generator, fs boshsys.FileSystem, logger boshlog.Logger) Blobstore {
+func NewBlobstore(
+	davClient DavClient,
+	uuidGenerator boshuuid.Generator,
+	fs boshsys.FileSystem,
+	getAttempts int,
+	getDelay time.Duration,
+	timeService boshtime.Service,
+	logger boshlog.Logger,
+) Blobstore {
 	return &blobstore{
 		davClient:     davClient,
 		uuidGenerator: uuidGenerator,
 		fs:            fs,
+		getAttempts:   getAttempts,
+		getDelay:      getDelay,
+		timeService:   timeService,
 		logger:        logger,
 		logTag:        "blobstore",
 	}
 }
 
-func (b *blobstore) Get(blobID string) (LocalBlob, error) {
+// Get retries failed and corrupted downloads up to the configured number of attempts
+func (b *blobstore) Get(blobID string, expectedSHA1 string) (LocalBlob, error) {
+	var localBlob LocalBlob
+	getRetryable := boshretry.NewRetryable(func() (bool, error) {
+		var err error
+		localBlob, err = b.download(blobID, expectedSHA1)
+		if err != nil {
+			b.logger.Warn(b.logTag, "Failed to download blob %s: %s", blobID, err.Error())
+			return true, err
+		}
+		return false, nil
+	})
+
+	getRetryStrategy := boshretry.NewAttemptRetryStrategy(b.getAttempts, b.getDelay, getRetryable, b.logger)
+	err := getRetryStrategy.Try()
+	if err != nil {
+		return nil, err
+	}
+
+	return localBlob, nil
+}
+
+func (b *blobstore) download(blobID string, expectedSHA1 string) (LocalBlob, error) {
 	file, err := b.fs.TempFile("bosh-init-local-blob")
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Creating temp file for blob")
+	}
 	destinationPath := file.Name()
 	err = file.Close()
 	if err != nil {
 		return nil, bosherr.WrapErrorf(err, "Closing new temp file '%s'", destinationPath)
 	}
 
+	localBlob := NewLocalBlob(destinationPath, b.fs, b.logger)
+
 	b.logger.Debug(b.logTag, "Downloading blob %s to %s", blobID, destinationPath)
 
 	readCloser, err := b.davClient.Get(blobID)
 	if err != nil {
+		localBlob.DeleteSilently()
 		return nil, bosherr.WrapErrorf(err, "Getting blob %s from blobstore", blobID)
 	}
 	defer readCloser.Close()
 
 	targetFile, err := b.fs.OpenFile(destinationPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
+		localBlob.DeleteSilently()
 		return nil, bosherr.WrapErrorf(err, "Opening file for blob at %s", destinationPath)
 	}
+	defer targetFile.Close()
 
-	_, err = io.Copy(targetFile, readCloser)
+	// the dav client does not expose the content length of the blob
+	progress := biui.NewLoggingProgressWriter(fmt.Sprintf("Downloading blob %s", blobID), -1, b.timeService, b.logger)
+	digest := sha1.New()
+	_, err = io.Copy(io.MultiWriter(targetFile, progress), io.TeeReader(readCloser, digest))
+	progress.Finish()
 	if err != nil {
+		localBlob.DeleteSilently()
 		return nil, bosherr.WrapErrorf(err, "Saving blob to %s", destinationPath)
 	}
 
-	return NewLocalBlob(destinationPath, b.fs, b.logger), nil
+	actualSHA1 := fmt.Sprintf("%x", digest.Sum(nil))
+	if expectedSHA1 != "" && actualSHA1 != expectedSHA1 {
+		localBlob.DeleteSilently()
+		return nil, SHA1MismatchError{
+			BlobID:       blobID,
+			ExpectedSHA1: expectedSHA1,
+			ActualSHA1:   actualSHA1,
+		}
+	}
+
+	return localBlob, nil
 }
 
 func (b *blobstore) Add(sourcePath string) (blobID string, err error) {
@@ -75,23 +152,45 @@ func (b *blobstore) Add(sourcePath string) (blobID string, err error) {
 		return "", bosherr.WrapError(err, "Generating Blob ID")
 	}
 
+	err = b.put(blobID, sourcePath)
+	if err != nil {
+		return "", err
+	}
+
+	return blobID, nil
+}
+
+func (b *blobstore) put(blobID string, sourcePath string) error {
 	b.logger.Debug(b.logTag, "Uploading blob %s from %s", blobID, sourcePath)
 
 	file, err := b.fs.OpenFile(sourcePath, os.O_RDONLY, 0)
 	if err != nil {
-		return "", bosherr.WrapErrorf(err, "Opening file for reading %s", sourcePath)
+		return bosherr.WrapErrorf(err, "Opening file for reading %s", sourcePath)
 	}
 	defer file.Close()
 
 	fileInfo, err := file.Stat()
 	if err != nil {
-		return "", bosherr.WrapErrorf(err, "Getting fileInfo from %s", sourcePath)
+		return bosherr.WrapErrorf(err, "Getting fileInfo from %s", sourcePath)
+	}
+
+	// blobs are uploaded concurrently while compiling, so progress is only logged
+	progress := biui.NewLoggingProgressWriter(fmt.Sprintf("Uploading blob %s", blobID), fileInfo.Size(), b.timeService, b.logger)
+	content := progressReadCloser{
+		Reader: io.TeeReader(file, progress),
+		Closer: file,
 	}
 
-	err = b.davClient.Put(blobID, file, fileInfo.Size())
+	err = b.davClient.Put(blobID, content, fileInfo.Size())
+	progress.Finish()
 	if err != nil {
-		return "", bosherr.WrapErrorf(err, "Putting file '%s' into blobstore (via DAVClient) as blobID '%s'", sourcePath, blobID)
+		return bosherr.WrapErrorf(err, "Putting file '%s' into blobstore (via DAVClient) as blobID '%s'", sourcePath, blobID)
 	}
 
-	return blobID, nil
+	return nil
+}
+
+type progressReadCloser struct {
+	io.Reader
+	io.Closer
 }
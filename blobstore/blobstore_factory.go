@@ -3,37 +3,73 @@ package blobstore
 import (
 	"fmt"
 	"net/url"
+	"time"
 
-	boshdavcli "github.com/cloudfoundry/bosh-agent/davcli/client"
 	boshdavcliconf "github.com/cloudfoundry/bosh-agent/davcli/config"
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 	boshsys "github.com/cloudfoundry/bosh-agent/system"
+	boshtime "github.com/cloudfoundry/bosh-agent/time"
 	boshuuid "github.com/cloudfoundry/bosh-agent/uuid"
 
+	biconfig "github.com/cloudfoundry/bosh-init/config"
+	bicrypto "github.com/cloudfoundry/bosh-init/crypto"
 	bihttpclient "github.com/cloudfoundry/bosh-init/deployment/httpclient"
 )
 
 type Factory interface {
 	Create(string) (Blobstore, error)
+	CreateContentAddressed(string, biconfig.BlobRepo) (Blobstore, error)
 }
 
 type blobstoreFactory struct {
 	uuidGenerator boshuuid.Generator
 	fs            boshsys.FileSystem
+	getAttempts   int
+	getDelay      time.Duration
+	timeService   boshtime.Service
 	logger        boshlog.Logger
 }
 
-func NewBlobstoreFactory(uuidGenerator boshuuid.Generator, fs boshsys.FileSystem, logger boshlog.Logger) Factory {
+func NewBlobstoreFactory(
+	uuidGenerator boshuuid.Generator,
+	fs boshsys.FileSystem,
+	getAttempts int,
+	getDelay time.Duration,
+	timeService boshtime.Service,
+	logger boshlog.Logger,
+) Factory {
 	return blobstoreFactory{
 		uuidGenerator: uuidGenerator,
 		fs:            fs,
+		getAttempts:   getAttempts,
+		getDelay:      getDelay,
+		timeService:   timeService,
 		logger:        logger,
 	}
 }
 
 //TODO: rename NewBlobstore
 func (f blobstoreFactory) Create(blobstoreURL string) (Blobstore, error) {
+	davClient, err := f.newDavClient(blobstoreURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBlobstore(davClient, f.uuidGenerator, f.fs, f.getAttempts, f.getDelay, f.timeService, f.logger), nil
+}
+
+// CreateContentAddressed returns a blobstore that skips uploading blobs recorded in the blob repo or already in the blobstore
+func (f blobstoreFactory) CreateContentAddressed(blobstoreURL string, blobRepo biconfig.BlobRepo) (Blobstore, error) {
+	davClient, err := f.newDavClient(blobstoreURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewContentAddressedBlobstore(davClient, bicrypto.NewSha1Calculator(f.fs), blobRepo, f.fs, f.getAttempts, f.getDelay, f.timeService, f.logger), nil
+}
+
+func (f blobstoreFactory) newDavClient(blobstoreURL string) (DavClient, error) {
 	blobstoreConfig, err := f.parseBlobstoreURL(blobstoreURL)
 	if err != nil {
 		return nil, bosherr.WrapError(err, "Creating blobstore config")
@@ -41,13 +77,11 @@ func (f blobstoreFactory) Create(blobstoreURL string) (Blobstore, error) {
 
 	httpClient := bihttpclient.DefaultClient
 
-	davClient := boshdavcli.NewClient(boshdavcliconf.Config{
+	return NewDavClient(boshdavcliconf.Config{
 		Endpoint: fmt.Sprintf("%s/blobs", blobstoreConfig.Endpoint),
 		User:     blobstoreConfig.Username,
 		Password: blobstoreConfig.Password,
-	}, &httpClient)
-
-	return NewBlobstore(davClient, f.uuidGenerator, f.fs, f.logger), nil
+	}, &httpClient), nil
 }
 
 func (f blobstoreFactory) parseBlobstoreURL(blobstoreURL string) (Config, error) {
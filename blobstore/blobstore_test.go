@@ -1,6 +1,7 @@
 package blobstore_test
 
 import (
+	"bytes"
 	"errors"
 	"io/ioutil"
 	"strings"
@@ -10,28 +11,32 @@ import (
 
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 
-	fakeboshdavcli "github.com/cloudfoundry/bosh-agent/davcli/client/fakes"
 	fakesys "github.com/cloudfoundry/bosh-agent/system/fakes"
+	faketime "github.com/cloudfoundry/bosh-agent/time/fakes"
 	fakeuuid "github.com/cloudfoundry/bosh-agent/uuid/fakes"
 
 	. "github.com/cloudfoundry/bosh-init/blobstore"
+
+	fakebiblobstore "github.com/cloudfoundry/bosh-init/blobstore/fakes"
 )
 
 var _ = Describe("Blobstore", func() {
 	var (
-		fakeDavClient     *fakeboshdavcli.FakeClient
+		fakeDavClient     *fakebiblobstore.FakeDavClient
 		fakeUUIDGenerator *fakeuuid.FakeGenerator
 		fs                *fakesys.FakeFileSystem
+		logBuffer         *bytes.Buffer
 		blobstore         Blobstore
 	)
 
 	BeforeEach(func() {
-		fakeDavClient = fakeboshdavcli.NewFakeClient()
+		fakeDavClient = fakebiblobstore.NewFakeDavClient()
 		fakeUUIDGenerator = fakeuuid.NewFakeGenerator()
 		fs = fakesys.NewFakeFileSystem()
-		logger := boshlog.NewLogger(boshlog.LevelNone)
+		logBuffer = bytes.NewBufferString("")
+		logger := boshlog.NewWriterLogger(boshlog.LevelDebug, logBuffer, logBuffer)
 
-		blobstore = NewBlobstore(fakeDavClient, fakeUUIDGenerator, fs, logger)
+		blobstore = NewBlobstore(fakeDavClient, fakeUUIDGenerator, fs, 3, 0, &faketime.FakeService{}, logger)
 	})
 
 	Describe("Get", func() {
@@ -43,7 +48,7 @@ var _ = Describe("Blobstore", func() {
 		It("gets the blob from the blobstore", func() {
 			fakeDavClient.GetContents = ioutil.NopCloser(strings.NewReader("fake-content"))
 
-			localBlob, err := blobstore.Get("fake-blob-id")
+			localBlob, err := blobstore.Get("fake-blob-id", "")
 			Expect(err).ToNot(HaveOccurred())
 			defer localBlob.DeleteSilently()
 
@@ -53,7 +58,7 @@ var _ = Describe("Blobstore", func() {
 		It("saves the blob to the destination path", func() {
 			fakeDavClient.GetContents = ioutil.NopCloser(strings.NewReader("fake-content"))
 
-			localBlob, err := blobstore.Get("fake-blob-id")
+			localBlob, err := blobstore.Get("fake-blob-id", "")
 			Expect(err).ToNot(HaveOccurred())
 			defer func() {
 				err := localBlob.Delete()
@@ -71,10 +76,62 @@ var _ = Describe("Blobstore", func() {
 			It("returns an error", func() {
 				fakeDavClient.GetErr = errors.New("fake-get-error")
 
-				_, err := blobstore.Get("fake-blob-id")
+				_, err := blobstore.Get("fake-blob-id", "")
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-get-error"))
 			})
+
+			It("retries the download", func() {
+				fakeDavClient.GetErr = errors.New("fake-get-error")
+
+				_, err := blobstore.Get("fake-blob-id", "")
+				Expect(err).To(HaveOccurred())
+				Expect(fakeDavClient.GetInputs).To(Equal([]string{"fake-blob-id", "fake-blob-id", "fake-blob-id"}))
+			})
+		})
+
+		Context("when an expected SHA1 is provided", func() {
+			// SHA1 of 'fake-content'
+			var expectedSHA1 = "50fe6e45709c690c0737343ecd613813d8dd2d53"
+
+			It("returns the blob when the SHA1 matches", func() {
+				fakeDavClient.GetResponses = []string{"fake-content"}
+
+				localBlob, err := blobstore.Get("fake-blob-id", expectedSHA1)
+				Expect(err).ToNot(HaveOccurred())
+				defer localBlob.DeleteSilently()
+
+				contents, err := fs.ReadFileString(localBlob.Path())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(contents).To(Equal("fake-content"))
+			})
+
+			It("retries when the downloaded content does not match", func() {
+				fakeDavClient.GetResponses = []string{"fake-trunc", "fake-content"}
+
+				localBlob, err := blobstore.Get("fake-blob-id", expectedSHA1)
+				Expect(err).ToNot(HaveOccurred())
+				defer localBlob.DeleteSilently()
+
+				Expect(fakeDavClient.GetInputs).To(HaveLen(2))
+				contents, err := fs.ReadFileString(localBlob.Path())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(contents).To(Equal("fake-content"))
+			})
+
+			It("deletes the download and returns a SHA1MismatchError when no attempt matches", func() {
+				fakeDavClient.GetResponses = []string{"fake-trunc", "fake-trunc", "fake-trunc"}
+
+				_, err := blobstore.Get("fake-blob-id", expectedSHA1)
+				Expect(err).To(HaveOccurred())
+
+				mismatchErr, ok := err.(SHA1MismatchError)
+				Expect(ok).To(BeTrue())
+				Expect(mismatchErr.BlobID).To(Equal("fake-blob-id"))
+				Expect(mismatchErr.ExpectedSHA1).To(Equal(expectedSHA1))
+
+				Expect(fs.FileExists("fake-destination-path")).To(BeFalse())
+			})
 		})
 	})
 
@@ -94,5 +151,13 @@ var _ = Describe("Blobstore", func() {
 			Expect(fakeDavClient.PutPath).To(Equal("fake-blob-id"))
 			Expect(fakeDavClient.PutContents).To(Equal("fake-contents"))
 		})
+
+		It("logs the upload progress using the file size", func() {
+			fakeUUIDGenerator.GeneratedUUID = "fake-blob-id"
+
+			_, err := blobstore.Add("fake-source-path")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(logBuffer.String()).To(ContainSubstring("Uploading blob fake-blob-id: 13 B / 13 B (100%)"))
+		})
 	})
 })
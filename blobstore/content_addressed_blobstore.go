@@ -0,0 +1,83 @@
+package blobstore
+
+import (
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	boshsys "github.com/cloudfoundry/bosh-agent/system"
+	boshtime "github.com/cloudfoundry/bosh-agent/time"
+
+	biconfig "github.com/cloudfoundry/bosh-init/config"
+	bicrypto "github.com/cloudfoundry/bosh-init/crypto"
+)
+
+type contentAddressedBlobstore struct {
+	blobstore
+	sha1Calculator bicrypto.SHA1Calculator
+	blobRepo       biconfig.BlobRepo
+}
+
+// NewContentAddressedBlobstore returns a blobstore that uses the SHA1 of the content as the blob ID,
+// so adding a file that was already uploaded (recorded in the blob repo or found in the blobstore) is skipped.
+func NewContentAddressedBlobstore(
+	davClient DavClient,
+	sha1Calculator bicrypto.SHA1Calculator,
+	blobRepo biconfig.BlobRepo,
+	fs boshsys.FileSystem,
+	getAttempts int,
+	getDelay time.Duration,
+	timeService boshtime.Service,
+	logger boshlog.Logger,
+) Blobstore {
+	return &contentAddressedBlobstore{
+		blobstore: blobstore{
+			davClient:   davClient,
+			fs:          fs,
+			getAttempts: getAttempts,
+			getDelay:    getDelay,
+			timeService: timeService,
+			logger:      logger,
+			logTag:      "contentAddressedBlobstore",
+		},
+		sha1Calculator: sha1Calculator,
+		blobRepo:       blobRepo,
+	}
+}
+
+func (b *contentAddressedBlobstore) Add(sourcePath string) (string, error) {
+	blobID, err := b.sha1Calculator.Calculate(sourcePath)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Calculating SHA1 of %s", sourcePath)
+	}
+
+	uploaded, err := b.blobRepo.Contains(blobID)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Finding uploaded blob %s", blobID)
+	}
+	if uploaded {
+		b.logger.Debug(b.logTag, "Skipping upload of %s, blob %s was already uploaded", sourcePath, blobID)
+		return blobID, nil
+	}
+
+	exists, err := b.davClient.Exists(blobID)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Checking blobstore for blob %s", blobID)
+	}
+
+	if exists {
+		b.logger.Debug(b.logTag, "Skipping upload of %s, blob %s exists in the blobstore", sourcePath, blobID)
+	} else {
+		err = b.put(blobID, sourcePath)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = b.blobRepo.Save(blobID)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Saving uploaded blob %s", blobID)
+	}
+
+	return blobID, nil
+}
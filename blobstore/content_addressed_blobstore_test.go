@@ -0,0 +1,116 @@
+package blobstore_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	fakesys "github.com/cloudfoundry/bosh-agent/system/fakes"
+	faketime "github.com/cloudfoundry/bosh-agent/time/fakes"
+
+	. "github.com/cloudfoundry/bosh-init/blobstore"
+
+	fakebiblobstore "github.com/cloudfoundry/bosh-init/blobstore/fakes"
+	fakebiconfig "github.com/cloudfoundry/bosh-init/config/fakes"
+	fakebicrypto "github.com/cloudfoundry/bosh-init/crypto/fakes"
+)
+
+var _ = Describe("ContentAddressedBlobstore", func() {
+	var (
+		fakeDavClient      *fakebiblobstore.FakeDavClient
+		fakeSHA1Calculator *fakebicrypto.FakeSha1Calculator
+		fakeBlobRepo       *fakebiconfig.FakeBlobRepo
+		fs                 *fakesys.FakeFileSystem
+		blobstore          Blobstore
+	)
+
+	BeforeEach(func() {
+		fakeDavClient = fakebiblobstore.NewFakeDavClient()
+		fakeSHA1Calculator = fakebicrypto.NewFakeSha1Calculator()
+		fakeBlobRepo = fakebiconfig.NewFakeBlobRepo()
+		fs = fakesys.NewFakeFileSystem()
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+
+		fakeSHA1Calculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+			"fake-source-path": {Sha1: "fake-sha1"},
+		})
+		fs.RegisterOpenFile("fake-source-path", &fakesys.FakeFile{
+			Contents: []byte("fake-contents"),
+		})
+
+		blobstore = NewContentAddressedBlobstore(fakeDavClient, fakeSHA1Calculator, fakeBlobRepo, fs, 1, 0, &faketime.FakeService{}, logger)
+	})
+
+	Describe("Add", func() {
+		It("uploads the file using its SHA1 as the blob ID", func() {
+			blobID, err := blobstore.Add("fake-source-path")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blobID).To(Equal("fake-sha1"))
+			Expect(fakeDavClient.ExistsPath).To(Equal("fake-sha1"))
+			Expect(fakeDavClient.PutPath).To(Equal("fake-sha1"))
+			Expect(fakeDavClient.PutContents).To(Equal("fake-contents"))
+		})
+
+		It("records the uploaded blob", func() {
+			_, err := blobstore.Add("fake-source-path")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeBlobRepo.SaveInputs).To(Equal([]string{"fake-sha1"}))
+		})
+
+		Context("when the blob was already uploaded to the current VM", func() {
+			BeforeEach(func() {
+				fakeBlobRepo.BlobIDs["fake-sha1"] = true
+			})
+
+			It("skips checking the blobstore and uploading", func() {
+				blobID, err := blobstore.Add("fake-source-path")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(blobID).To(Equal("fake-sha1"))
+				Expect(fakeDavClient.ExistsPath).To(BeEmpty())
+				Expect(fakeDavClient.PutPath).To(BeEmpty())
+			})
+		})
+
+		Context("when the blob exists in the blobstore", func() {
+			BeforeEach(func() {
+				fakeDavClient.ExistsResult = true
+			})
+
+			It("skips uploading and records the blob", func() {
+				blobID, err := blobstore.Add("fake-source-path")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(blobID).To(Equal("fake-sha1"))
+				Expect(fakeDavClient.PutPath).To(BeEmpty())
+				Expect(fakeBlobRepo.SaveInputs).To(Equal([]string{"fake-sha1"}))
+			})
+		})
+
+		Context("when checking the blobstore fails", func() {
+			BeforeEach(func() {
+				fakeDavClient.ExistsErr = errors.New("fake-exists-error")
+			})
+
+			It("returns an error", func() {
+				_, err := blobstore.Add("fake-source-path")
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-exists-error"))
+			})
+		})
+
+		Context("when calculating the SHA1 fails", func() {
+			BeforeEach(func() {
+				fakeSHA1Calculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+					"fake-source-path": {Err: errors.New("fake-sha1-error")},
+				})
+			})
+
+			It("returns an error", func() {
+				_, err := blobstore.Add("fake-source-path")
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-sha1-error"))
+			})
+		})
+	})
+})
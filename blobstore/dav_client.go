@@ -0,0 +1,75 @@
+package blobstore
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+
+	boshdavcli "github.com/cloudfoundry/bosh-agent/davcli/client"
+	boshdavcliconf "github.com/cloudfoundry/bosh-agent/davcli/config"
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshhttp "github.com/cloudfoundry/bosh-agent/http"
+)
+
+// DavClient extends the agent's dav client with a check for existing blobs
+type DavClient interface {
+	boshdavcli.Client
+	Exists(blobID string) (bool, error)
+}
+
+type davClient struct {
+	boshdavcli.Client
+	config     boshdavcliconf.Config
+	httpClient boshhttp.Client
+}
+
+func NewDavClient(config boshdavcliconf.Config, httpClient boshhttp.Client) DavClient {
+	return davClient{
+		Client:     boshdavcli.NewClient(config, httpClient),
+		config:     config,
+		httpClient: httpClient,
+	}
+}
+
+func (c davClient) Exists(blobID string) (bool, error) {
+	blobURL, err := c.blobURL(blobID)
+	if err != nil {
+		return false, bosherr.WrapErrorf(err, "Building dav blob url for %s", blobID)
+	}
+
+	request, err := http.NewRequest("HEAD", blobURL, nil)
+	if err != nil {
+		return false, bosherr.WrapErrorf(err, "Creating HEAD request for dav blob %s", blobID)
+	}
+	request.SetBasicAuth(c.config.User, c.config.Password)
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return false, bosherr.WrapErrorf(err, "Checking for dav blob %s", blobID)
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, bosherr.Errorf("Checking for dav blob %s: Wrong response code: %d", blobID, response.StatusCode)
+	}
+}
+
+// blobURL matches the layout of the agent's dav client, which nests blobs under the first byte of the SHA1 of their ID
+func (c davClient) blobURL(blobID string) (string, error) {
+	blobURL, err := url.Parse(c.config.Endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	digest := sha1.Sum([]byte(blobID))
+	blobURL.Path = path.Join("/", blobURL.Path, fmt.Sprintf("%02x", digest[0]), blobID)
+
+	return blobURL.String(), nil
+}
@@ -0,0 +1,63 @@
+package blobstore_test
+
+import (
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	boshdavcliconf "github.com/cloudfoundry/bosh-agent/davcli/config"
+
+	. "github.com/cloudfoundry/bosh-init/blobstore"
+)
+
+var _ = Describe("DavClient", func() {
+	var (
+		server    *ghttp.Server
+		davClient DavClient
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		davClient = NewDavClient(boshdavcliconf.Config{
+			Endpoint: server.URL() + "/blobs",
+			User:     "fake-user",
+			Password: "fake-password",
+		}, http.DefaultClient)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("Exists", func() {
+		It("sends a HEAD request for the blob path used by the agent", func() {
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyRequest("HEAD", "/blobs/80/fake-blob-id"),
+				ghttp.VerifyBasicAuth("fake-user", "fake-password"),
+				ghttp.RespondWith(http.StatusOK, nil),
+			))
+
+			exists, err := davClient.Exists("fake-blob-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exists).To(BeTrue())
+		})
+
+		It("returns false when the blob is not found", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusNotFound, nil))
+
+			exists, err := davClient.Exists("fake-blob-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exists).To(BeFalse())
+		})
+
+		It("returns an error for other response codes", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusInternalServerError, nil))
+
+			_, err := davClient.Exists("fake-blob-id")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Wrong response code: 500"))
+		})
+	})
+})
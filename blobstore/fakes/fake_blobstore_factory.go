@@ -2,12 +2,14 @@ package fakes
 
 import (
 	biblobstore "github.com/cloudfoundry/bosh-init/blobstore"
+	biconfig "github.com/cloudfoundry/bosh-init/config"
 )
 
 type FakeBlobstoreFactory struct {
 	CreateBlobstoreURL string
 	CreateBlobstore    biblobstore.Blobstore
 	CreateErr          error
+	CreateBlobRepo     biconfig.BlobRepo
 }
 
 func NewFakeBlobstoreFactory() *FakeBlobstoreFactory {
@@ -18,3 +20,9 @@ func (f *FakeBlobstoreFactory) Create(blobstoreURL string) (biblobstore.Blobstor
 	f.CreateBlobstoreURL = blobstoreURL
 	return f.CreateBlobstore, f.CreateErr
 }
+
+func (f *FakeBlobstoreFactory) CreateContentAddressed(blobstoreURL string, blobRepo biconfig.BlobRepo) (biblobstore.Blobstore, error) {
+	f.CreateBlobstoreURL = blobstoreURL
+	f.CreateBlobRepo = blobRepo
+	return f.CreateBlobstore, f.CreateErr
+}
@@ -0,0 +1,45 @@
+package fakes
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+
+	fakeboshdavcli "github.com/cloudfoundry/bosh-agent/davcli/client/fakes"
+)
+
+type FakeDavClient struct {
+	*fakeboshdavcli.FakeClient
+
+	// GetResponses, when set, are returned by consecutive calls to Get instead of GetContents
+	GetInputs    []string
+	GetResponses []string
+
+	ExistsPath   string
+	ExistsResult bool
+	ExistsErr    error
+}
+
+func NewFakeDavClient() *FakeDavClient {
+	return &FakeDavClient{
+		FakeClient: fakeboshdavcli.NewFakeClient(),
+	}
+}
+
+func (c *FakeDavClient) Get(path string) (io.ReadCloser, error) {
+	c.GetInputs = append(c.GetInputs, path)
+
+	if len(c.GetResponses) == 0 {
+		return c.FakeClient.Get(path)
+	}
+
+	response := c.GetResponses[0]
+	c.GetResponses = c.GetResponses[1:]
+	c.GetPath = path
+	return ioutil.NopCloser(strings.NewReader(response)), nil
+}
+
+func (c *FakeDavClient) Exists(path string) (bool, error) {
+	c.ExistsPath = path
+	return c.ExistsResult, c.ExistsErr
+}
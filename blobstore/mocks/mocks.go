@@ -6,6 +6,7 @@ package mocks
 import (
 	gomock "code.google.com/p/gomock/gomock"
 	blobstore "github.com/cloudfoundry/bosh-init/blobstore"
+	config "github.com/cloudfoundry/bosh-init/config"
 )
 
 // Mock of Factory interface
@@ -40,6 +41,17 @@ func (_mr *_MockFactoryRecorder) Create(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Create", arg0)
 }
 
+func (_m *MockFactory) CreateContentAddressed(_param0 string, _param1 config.BlobRepo) (blobstore.Blobstore, error) {
+	ret := _m.ctrl.Call(_m, "CreateContentAddressed", _param0, _param1)
+	ret0, _ := ret[0].(blobstore.Blobstore)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockFactoryRecorder) CreateContentAddressed(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "CreateContentAddressed", arg0, arg1)
+}
+
 // Mock of Blobstore interface
 type MockBlobstore struct {
 	ctrl     *gomock.Controller
@@ -72,13 +84,13 @@ func (_mr *_MockBlobstoreRecorder) Add(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Add", arg0)
 }
 
-func (_m *MockBlobstore) Get(_param0 string) (blobstore.LocalBlob, error) {
-	ret := _m.ctrl.Call(_m, "Get", _param0)
+func (_m *MockBlobstore) Get(_param0 string, _param1 string) (blobstore.LocalBlob, error) {
+	ret := _m.ctrl.Call(_m, "Get", _param0, _param1)
 	ret0, _ := ret[0].(blobstore.LocalBlob)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockBlobstoreRecorder) Get(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "Get", arg0)
+func (_mr *_MockBlobstoreRecorder) Get(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Get", arg0, arg1)
 }
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	boshsys "github.com/cloudfoundry/bosh-agent/system"
+
+	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
+	bitarball "github.com/cloudfoundry/bosh-init/installation/tarball"
+	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
+	biui "github.com/cloudfoundry/bosh-init/ui"
+)
+
+type bundleCmd struct {
+	ui                  biui.UI
+	fs                  boshsys.FileSystem
+	releaseSetParser    birelsetmanifest.Parser
+	releaseSetValidator birelsetmanifest.Validator
+	deploymentParser    bideplmanifest.Parser
+	tarballBundler      bitarball.Bundler
+	logger              boshlog.Logger
+	logTag              string
+}
+
+func NewBundleCmd(
+	ui biui.UI,
+	fs boshsys.FileSystem,
+	releaseSetParser birelsetmanifest.Parser,
+	releaseSetValidator birelsetmanifest.Validator,
+	deploymentParser bideplmanifest.Parser,
+	tarballBundler bitarball.Bundler,
+	logger boshlog.Logger,
+) Cmd {
+	return &bundleCmd{
+		ui:                  ui,
+		fs:                  fs,
+		releaseSetParser:    releaseSetParser,
+		releaseSetValidator: releaseSetValidator,
+		deploymentParser:    deploymentParser,
+		tarballBundler:      tarballBundler,
+		logger:              logger,
+		logTag:              "bundleCmd",
+	}
+}
+
+func (c *bundleCmd) Name() string {
+	return "bundle"
+}
+
+func (c *bundleCmd) Meta() Meta {
+	return Meta{
+		Synopsis: "Create a bundle of the releases and stemcell of a deployment, to deploy without network access",
+		Usage:    "create [--output <bundle_path>] <deployment_manifest_path>",
+		Env:      genericEnv,
+	}
+}
+
+func (c *bundleCmd) Run(stage biui.Stage, args []string) error {
+	positionalArgs, _, valueFlags, err := parseFlags(args, []string{}, []string{"output"})
+	if err != nil {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return err
+	}
+	if len(positionalArgs) != 2 || positionalArgs[0] != "create" {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return errors.New("Invalid usage - bundle command requires 'create' and exactly 1 argument")
+	}
+
+	manifestAbsFilePath, err := filepath.Abs(positionalArgs[1])
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Getting absolute path to deployment file '%s'", positionalArgs[1])
+	}
+
+	if !c.fs.FileExists(manifestAbsFilePath) {
+		c.ui.ErrorLinef("Deployment '%s' does not exist", manifestAbsFilePath)
+		return bosherr.Errorf("Deployment manifest does not exist at '%s'", manifestAbsFilePath)
+	}
+
+	bundlePath, given := valueFlags["output"]
+	if !given {
+		bundlePath = defaultBundlePath(manifestAbsFilePath)
+	}
+	bundleAbsFilePath, err := filepath.Abs(bundlePath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Getting absolute path to bundle '%s'", bundlePath)
+	}
+
+	c.ui.PrintLinef("Deployment manifest: '%s'", manifestAbsFilePath)
+
+	var sources []bitarball.Source
+	err = stage.Perform("Validating deployment manifest", func() error {
+		sources, err = c.tarballSources(manifestAbsFilePath)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = stage.PerformComplex("creating bundle", func(bundleStage biui.Stage) error {
+		return c.tarballBundler.Create(sources, bundleAbsFilePath, bundleStage)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.ui.PrintLinef("Bundle: '%s'", bundleAbsFilePath)
+	return nil
+}
+
+// tarballSources returns the releases (including the CPI release) and the stemcells of the deployment
+func (c *bundleCmd) tarballSources(deploymentManifestPath string) ([]bitarball.Source, error) {
+	releaseSetManifest, err := c.releaseSetParser.Parse(deploymentManifestPath)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Parsing release set manifest '%s'", deploymentManifestPath)
+	}
+
+	err = c.releaseSetValidator.Validate(releaseSetManifest)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Validating release set manifest")
+	}
+
+	deploymentManifest, err := c.deploymentParser.Parse(deploymentManifestPath)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Parsing deployment manifest '%s'", deploymentManifestPath)
+	}
+
+	sources := []bitarball.Source{}
+	for _, releaseRef := range releaseSetManifest.Releases {
+		sources = append(sources, releaseRef)
+	}
+	for _, resourcePool := range deploymentManifest.ResourcePools {
+		sources = append(sources, resourcePool.Stemcell)
+	}
+
+	return sources, nil
+}
+
+func defaultBundlePath(deploymentManifestPath string) string {
+	baseFileName := filepath.Base(strings.TrimSuffix(deploymentManifestPath, filepath.Ext(deploymentManifestPath)))
+	return filepath.Join(filepath.Dir(deploymentManifestPath), fmt.Sprintf("%s-bundle.tgz", baseFileName))
+}
@@ -0,0 +1,113 @@
+package cmd_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	fakesys "github.com/cloudfoundry/bosh-agent/system/fakes"
+
+	. "github.com/cloudfoundry/bosh-init/cmd"
+	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
+	fakebideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest/fakes"
+	bitarball "github.com/cloudfoundry/bosh-init/installation/tarball"
+	fakebitarball "github.com/cloudfoundry/bosh-init/installation/tarball/fakes"
+	birelmanifest "github.com/cloudfoundry/bosh-init/release/manifest"
+	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
+	fakebirelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest/fakes"
+
+	fakebiui "github.com/cloudfoundry/bosh-init/ui/fakes"
+)
+
+var _ = Describe("BundleCmd", func() {
+	var (
+		fs                      *fakesys.FakeFileSystem
+		fakeUI                  *fakebiui.FakeUI
+		fakeStage               *fakebiui.FakeStage
+		fakeReleaseSetParser    *fakebirelsetmanifest.FakeParser
+		fakeReleaseSetValidator *fakebirelsetmanifest.FakeValidator
+		fakeDeploymentParser    *fakebideplmanifest.FakeParser
+		fakeTarballBundler      *fakebitarball.FakeBundler
+
+		deploymentManifestPath = "/path/to/manifest.yml"
+		releaseRef             = birelmanifest.ReleaseRef{Name: "fake-cpi-release", URL: "https://fake-release-url", SHA1: "fake-release-sha1"}
+		stemcellRef            = bideplmanifest.StemcellRef{URL: "https://fake-stemcell-url", SHA1: "fake-stemcell-sha1"}
+
+		command Cmd
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		fakeUI = &fakebiui.FakeUI{}
+		fakeStage = fakebiui.NewFakeStage()
+		fakeReleaseSetParser = fakebirelsetmanifest.NewFakeParser()
+		fakeReleaseSetValidator = fakebirelsetmanifest.NewFakeValidator()
+		fakeReleaseSetValidator.SetValidateBehavior([]fakebirelsetmanifest.ValidateOutput{{Err: nil}})
+		fakeDeploymentParser = fakebideplmanifest.NewFakeParser()
+		fakeTarballBundler = fakebitarball.NewFakeBundler()
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+
+		fs.WriteFileString(deploymentManifestPath, "")
+		fakeReleaseSetParser.ParseManifest = birelsetmanifest.Manifest{
+			Releases: []birelmanifest.ReleaseRef{releaseRef},
+		}
+		fakeDeploymentParser.ParseManifest = bideplmanifest.Manifest{
+			ResourcePools: []bideplmanifest.ResourcePool{
+				{Name: "fake-resource-pool", Stemcell: stemcellRef},
+			},
+		}
+
+		command = NewBundleCmd(fakeUI, fs, fakeReleaseSetParser, fakeReleaseSetValidator, fakeDeploymentParser, fakeTarballBundler, logger)
+	})
+
+	It("bundles the releases and stemcells of the deployment next to the manifest", func() {
+		err := command.Run(fakeStage, []string{"create", deploymentManifestPath})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(fakeTarballBundler.CreateInputs).To(Equal([]fakebitarball.CreateInput{
+			{
+				Sources:    []bitarball.Source{releaseRef, stemcellRef},
+				BundlePath: "/path/to/manifest-bundle.tgz",
+			},
+		}))
+		Expect(fakeUI.Said).To(ContainElement("Bundle: '/path/to/manifest-bundle.tgz'"))
+	})
+
+	It("writes the bundle to --output", func() {
+		err := command.Run(fakeStage, []string{"create", "--output", "/fake-bundle.tgz", deploymentManifestPath})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fakeTarballBundler.CreateInputs[0].BundlePath).To(Equal("/fake-bundle.tgz"))
+	})
+
+	It("returns an error when the release set manifest is invalid", func() {
+		fakeReleaseSetValidator.SetValidateBehavior([]fakebirelsetmanifest.ValidateOutput{
+			{Err: bosherr.Error("fake-validate-error")},
+		})
+
+		err := command.Run(fakeStage, []string{"create", deploymentManifestPath})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Validating release set manifest: fake-validate-error"))
+		Expect(fakeTarballBundler.CreateInputs).To(BeEmpty())
+	})
+
+	It("returns an error when the bundle cannot be created", func() {
+		fakeTarballBundler.CreateErr = bosherr.Error("fake-create-error")
+
+		err := command.Run(fakeStage, []string{"create", deploymentManifestPath})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("fake-create-error"))
+	})
+
+	It("returns an error when the deployment manifest does not exist", func() {
+		err := command.Run(fakeStage, []string{"create", "/path/to/missing.yml"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Deployment manifest does not exist at '/path/to/missing.yml'"))
+	})
+
+	It("returns an error without the create subcommand", func() {
+		err := command.Run(fakeStage, []string{deploymentManifestPath})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Invalid usage"))
+	})
+})
@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	bitarball "github.com/cloudfoundry/bosh-init/installation/tarball"
+	biui "github.com/cloudfoundry/bosh-init/ui"
+	biuifmt "github.com/cloudfoundry/bosh-init/ui/fmt"
+)
+
+type cacheCmd struct {
+	ui           biui.UI
+	tarballCache bitarball.Cache
+	maxSize      int64
+	logger       boshlog.Logger
+	logTag       string
+}
+
+// NewCacheCmd returns the command managing the cache of downloaded release and stemcell tarballs.
+// maxSize is the configured maximum size of the cache, used by prune unless --max-size is given.
+func NewCacheCmd(ui biui.UI, tarballCache bitarball.Cache, maxSize int64, logger boshlog.Logger) Cmd {
+	return &cacheCmd{
+		ui:           ui,
+		tarballCache: tarballCache,
+		maxSize:      maxSize,
+		logger:       logger,
+		logTag:       "cacheCmd",
+	}
+}
+
+func (c *cacheCmd) Name() string {
+	return "cache"
+}
+
+func (c *cacheCmd) Meta() Meta {
+	env := map[string]MetaEnv{
+		"BOSH_INIT_CACHE_MAX_SIZE": MetaEnv{
+			Example:     "10GB",
+			Default:     "unlimited",
+			Description: "Least recently used tarballs are evicted when the cache grows beyond this size",
+		},
+	}
+	for name, metaEnv := range genericEnv {
+		env[name] = metaEnv
+	}
+
+	return Meta{
+		Synopsis: "List, clear or prune downloaded release and stemcell tarballs",
+		Usage:    "list | clear | prune [--older-than <duration, e.g. 30d>] [--max-size <size, e.g. 10GB>]",
+		Env:      env,
+	}
+}
+
+func (c *cacheCmd) Run(stage biui.Stage, args []string) error {
+	positionalArgs, _, valueFlags, err := parseFlags(args, []string{}, []string{"older-than", "max-size"})
+	if err != nil {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return err
+	}
+
+	if len(positionalArgs) != 1 {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return errors.New("Invalid usage - cache command requires exactly 1 argument")
+	}
+
+	subcommand := positionalArgs[0]
+	if subcommand != "prune" && len(valueFlags) > 0 {
+		return bosherr.Errorf("Invalid usage - cache %s does not accept flags", subcommand)
+	}
+
+	switch subcommand {
+	case "list":
+		return c.list()
+	case "clear":
+		return stage.Perform("Clearing cache", func() error {
+			return c.tarballCache.Clear()
+		})
+	case "prune":
+		return c.prune(stage, valueFlags)
+	}
+
+	return bosherr.Errorf("Invalid usage - unknown cache subcommand '%s', must be one of list, clear, prune", subcommand)
+}
+
+func (c *cacheCmd) list() error {
+	entries, err := c.tarballCache.List()
+	if err != nil {
+		return bosherr.WrapError(err, "Listing cached tarballs")
+	}
+
+	var totalSize int64
+	for _, entry := range entries {
+		totalSize += entry.Size
+		c.ui.PrintLinef("%s", c.describe(entry))
+	}
+
+	c.ui.PrintLinef("")
+	c.ui.PrintLinef("%d tarball(s), %s", len(entries), biuifmt.Bytes(totalSize))
+	return nil
+}
+
+func (c *cacheCmd) prune(stage biui.Stage, valueFlags map[string]string) error {
+	var olderThan time.Duration
+	if value, given := valueFlags["older-than"]; given {
+		var err error
+		olderThan, err = parseAge(value)
+		if err != nil {
+			return err
+		}
+	}
+
+	maxSize := c.maxSize
+	if value, given := valueFlags["max-size"]; given {
+		var err error
+		maxSize, err = biuifmt.ParseBytes(value)
+		if err != nil {
+			return bosherr.WrapError(err, "Parsing --max-size")
+		}
+	}
+
+	if olderThan <= 0 && maxSize <= 0 {
+		return errors.New("Invalid usage - cache prune requires --older-than or --max-size (or BOSH_INIT_CACHE_MAX_SIZE)")
+	}
+
+	var evicted []bitarball.CacheEntry
+	err := stage.Perform("Pruning cache", func() error {
+		var err error
+		evicted, err = c.tarballCache.Prune(olderThan, maxSize)
+		return err
+	})
+
+	var evictedSize int64
+	for _, entry := range evicted {
+		evictedSize += entry.Size
+		c.ui.PrintLinef("Deleted %s", c.describe(entry))
+	}
+	if err != nil {
+		return err
+	}
+
+	c.ui.PrintLinef("Deleted %d tarball(s), %s", len(evicted), biuifmt.Bytes(evictedSize))
+	return nil
+}
+
+// describe returns e.g. "https://example.com/release.tgz (1.2 GB, last used 2015-06-01 12:00:00 UTC, in use)"
+func (c *cacheCmd) describe(entry bitarball.CacheEntry) string {
+	source := entry.URL
+	if source == "" {
+		source = entry.Path
+	}
+
+	lastUsed := "unknown"
+	if !entry.LastUsedAt.IsZero() {
+		lastUsed = entry.LastUsedAt.UTC().Format("2006-01-02 15:04:05 MST")
+	}
+
+	details := []string{biuifmt.Bytes(entry.Size), "last used " + lastUsed}
+	if entry.Referenced {
+		details = append(details, "in use")
+	}
+
+	return source + " (" + strings.Join(details, ", ") + ")"
+}
+
+// parseAge is time.ParseDuration that also accepts a number of days, e.g. "30d"
+func parseAge(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err == nil && days >= 0 {
+			return time.Duration(days) * 24 * time.Hour, nil
+		}
+	} else if duration, err := time.ParseDuration(value); err == nil && duration >= 0 {
+		return duration, nil
+	}
+
+	return 0, bosherr.Errorf("Invalid --older-than '%s', expected e.g. '30d' or '12h'", value)
+}
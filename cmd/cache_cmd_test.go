@@ -0,0 +1,160 @@
+package cmd_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"strings"
+	"time"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	fakesys "github.com/cloudfoundry/bosh-agent/system/fakes"
+	faketime "github.com/cloudfoundry/bosh-agent/time/fakes"
+
+	. "github.com/cloudfoundry/bosh-init/cmd"
+	bitarball "github.com/cloudfoundry/bosh-init/installation/tarball"
+	birelmanifest "github.com/cloudfoundry/bosh-init/release/manifest"
+
+	fakebiui "github.com/cloudfoundry/bosh-init/ui/fakes"
+)
+
+var _ = Describe("CacheCmd", func() {
+	var (
+		fs              *fakesys.FakeFileSystem
+		fakeUI          *fakebiui.FakeUI
+		fakeStage       *fakebiui.FakeStage
+		fakeTimeService *faketime.FakeService
+		logger          boshlog.Logger
+		tarballCache    bitarball.Cache
+		now             time.Time
+
+		command Cmd
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		fakeUI = &fakebiui.FakeUI{}
+		fakeStage = fakebiui.NewFakeStage()
+		fakeTimeService = &faketime.FakeService{}
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+		now = time.Date(2015, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+		tarballCache = bitarball.NewCache("/fake-cache", 0, fs, fakeTimeService, logger)
+		command = NewCacheCmd(fakeUI, tarballCache, 0, logger)
+	})
+
+	saveTarball := func(url string, size int, savedAt time.Time) bitarball.Source {
+		source := birelmanifest.ReleaseRef{Name: "fake-release", URL: url, SHA1: "fake-sha1"}
+
+		fakeTimeService.NowTimes = []time.Time{savedAt}
+		fs.WriteFileString("/fake-download", "")
+		err := tarballCache.Save("/fake-download", source)
+		Expect(err).ToNot(HaveOccurred())
+
+		cachedFile := fakesys.NewFakeFile(tarballCache.Path(source), fs)
+		cachedFile.Contents = []byte(strings.Repeat("x", size))
+		fs.RegisterOpenFile(tarballCache.Path(source), cachedFile)
+
+		return source
+	}
+
+	Describe("list", func() {
+		It("prints the cached tarballs and their total size", func() {
+			saveTarball("http://old.example.com", 1024, now.Add(-48*time.Hour))
+			source := saveTarball("http://new.example.com", 2048, now)
+
+			fs.WriteFileString("/fake-deployment-state.json", "{}")
+			err := tarballCache.UpdateReferences("/fake-deployment-state.json", []bitarball.Source{source})
+			Expect(err).ToNot(HaveOccurred())
+
+			err = command.Run(fakeStage, []string{"list"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeUI.Said).To(Equal([]string{
+				"http://old.example.com (1.0 KB, last used 2015-05-30 12:00:00 UTC)",
+				"http://new.example.com (2.0 KB, last used 2015-06-01 12:00:00 UTC, in use)",
+				"",
+				"2 tarball(s), 3.0 KB",
+			}))
+		})
+	})
+
+	Describe("clear", func() {
+		It("deletes all cached tarballs", func() {
+			saveTarball("http://foo.bar.com", 1024, now)
+
+			err := command.Run(fakeStage, []string{"clear"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fs.FileExists("/fake-cache")).To(BeFalse())
+			Expect(fakeStage.PerformCalls[0].Name).To(Equal("Clearing cache"))
+		})
+	})
+
+	Describe("prune", func() {
+		It("deletes tarballs last used before --older-than", func() {
+			oldSource := saveTarball("http://old.example.com", 1024, now.Add(-48*time.Hour))
+			newSource := saveTarball("http://new.example.com", 1024, now)
+
+			fakeTimeService.NowTimes = []time.Time{now}
+			err := command.Run(fakeStage, []string{"prune", "--older-than", "1d"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.FileExists(tarballCache.Path(oldSource))).To(BeFalse())
+			Expect(fs.FileExists(tarballCache.Path(newSource))).To(BeTrue())
+			Expect(fakeUI.Said).To(Equal([]string{
+				"Deleted http://old.example.com (1.0 KB, last used 2015-05-30 12:00:00 UTC)",
+				"Deleted 1 tarball(s), 1.0 KB",
+			}))
+		})
+
+		It("deletes the least recently used tarballs beyond --max-size", func() {
+			oldSource := saveTarball("http://old.example.com", 1024, now.Add(-48*time.Hour))
+			newSource := saveTarball("http://new.example.com", 1024, now)
+
+			err := command.Run(fakeStage, []string{"prune", "--max-size=1KB"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.FileExists(tarballCache.Path(oldSource))).To(BeFalse())
+			Expect(fs.FileExists(tarballCache.Path(newSource))).To(BeTrue())
+		})
+
+		It("defaults to the configured maximum size", func() {
+			command = NewCacheCmd(fakeUI, tarballCache, 1024, logger)
+			oldSource := saveTarball("http://old.example.com", 1024, now.Add(-48*time.Hour))
+			saveTarball("http://new.example.com", 1024, now)
+
+			err := command.Run(fakeStage, []string{"prune"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fs.FileExists(tarballCache.Path(oldSource))).To(BeFalse())
+		})
+
+		It("returns an error when no limit is given or configured", func() {
+			err := command.Run(fakeStage, []string{"prune"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid usage - cache prune requires --older-than or --max-size"))
+		})
+
+		It("returns an error when --older-than is invalid", func() {
+			err := command.Run(fakeStage, []string{"prune", "--older-than", "a while"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Invalid --older-than 'a while', expected e.g. '30d' or '12h'"))
+		})
+
+		It("returns an error when a flag has no value", func() {
+			err := command.Run(fakeStage, []string{"prune", "--max-size"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Invalid usage - flag '--max-size' requires a value"))
+		})
+	})
+
+	It("returns an error for an unknown subcommand", func() {
+		err := command.Run(fakeStage, []string{"purge"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Invalid usage - unknown cache subcommand 'purge', must be one of list, clear, prune"))
+	})
+
+	It("returns an error without a subcommand", func() {
+		err := command.Run(fakeStage, []string{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Invalid usage - cache command requires exactly 1 argument"))
+	})
+})
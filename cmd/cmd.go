@@ -36,4 +36,24 @@ var genericEnv = map[string]MetaEnv{
 		Default:     "standard out/err",
 		Description: "The path where logs will be written",
 	},
+	"BOSH_INIT_ERB_RENDERER": MetaEnv{
+		Example:     "ruby",
+		Default:     "native, with ruby for templates it does not support",
+		Description: "native to render job templates only natively, or ruby to render them with the ruby executable",
+	},
+	"BOSH_INIT_CONTENT_ADDRESSED_BLOBS": MetaEnv{
+		Example:     "true",
+		Default:     "false",
+		Description: "true to name uploaded blobs after the SHA1 of their content and skip uploading blobs the VM already has",
+	},
+	"BOSH_INIT_BLOBSTORE_GET_ATTEMPTS": MetaEnv{
+		Example:     "10",
+		Default:     "3",
+		Description: "How many times a blob download from the blobstore is attempted before failing",
+	},
+	"BOSH_INIT_BLOBSTORE_GET_DELAY": MetaEnv{
+		Example:     "5s",
+		Default:     "500ms",
+		Description: "How long to wait between blob download attempts",
+	},
 }
@@ -41,13 +41,13 @@ func (c *deleteCmd) Name() string {
 func (c *deleteCmd) Meta() Meta {
 	return Meta{
 		Synopsis: "Delete existing deployment",
-		Usage:    "<deployment_manifest_path>",
+		Usage:    "[--skip-drain] <deployment_manifest_path>",
 		Env:      genericEnv,
 	}
 }
 
 func (c *deleteCmd) Run(stage biui.Stage, args []string) error {
-	deploymentManifestPath, err := c.parseCmdInputs(args)
+	deploymentManifestPath, skipDrain, err := c.parseCmdInputs(args)
 	if err != nil {
 		return err
 	}
@@ -66,13 +66,18 @@ func (c *deleteCmd) Run(stage biui.Stage, args []string) error {
 	c.ui.PrintLinef("Deployment manifest: '%s'", manifestAbsFilePath)
 
 	deploymentDeleter := c.deploymentDeleterProvider(manifestAbsFilePath)
-	return deploymentDeleter.DeleteDeployment(stage)
+	return deploymentDeleter.DeleteDeployment(stage, skipDrain)
 }
 
-func (c *deleteCmd) parseCmdInputs(args []string) (string, error) {
-	if len(args) != 1 {
+func (c *deleteCmd) parseCmdInputs(args []string) (string, bool, error) {
+	positionalArgs, flags, err := parseBoolFlags(args, "skip-drain")
+	if err != nil {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return "", false, err
+	}
+	if len(positionalArgs) != 1 {
 		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
-		return "", errors.New("Invalid usage - delete command requires exactly 1 argument")
+		return "", false, errors.New("Invalid usage - delete command requires exactly 1 argument")
 	}
-	return args[0], nil
+	return positionalArgs[0], flags["skip-drain"], nil
 }
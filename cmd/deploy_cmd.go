@@ -9,11 +9,13 @@ import (
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 	boshsys "github.com/cloudfoundry/bosh-agent/system"
 
+	bitarball "github.com/cloudfoundry/bosh-init/installation/tarball"
 	biui "github.com/cloudfoundry/bosh-init/ui"
 )
 
 type deployCmd struct {
-	deploymentPreparerProvider func(deploymentManifestPath string) DeploymentPreparer
+	deploymentPreparerProvider func(deploymentManifestPath string, offline bool) DeploymentPreparer
+	tarballBundler             bitarball.Bundler
 	ui                         biui.UI
 	fs                         boshsys.FileSystem
 	eventLogger                biui.Stage
@@ -25,12 +27,14 @@ func NewDeployCmd(
 	ui biui.UI,
 	fs boshsys.FileSystem,
 	logger boshlog.Logger,
-	deploymentPreparerProvider func(deploymentManifestPath string) DeploymentPreparer,
+	deploymentPreparerProvider func(deploymentManifestPath string, offline bool) DeploymentPreparer,
+	tarballBundler bitarball.Bundler,
 ) Cmd {
 	return &deployCmd{
 		ui: ui,
 		fs: fs,
 		deploymentPreparerProvider: deploymentPreparerProvider,
+		tarballBundler:             tarballBundler,
 		logger: logger,
 		logTag: "deployCmd",
 	}
@@ -43,13 +47,13 @@ func (c *deployCmd) Name() string {
 func (c *deployCmd) Meta() Meta {
 	return Meta{
 		Synopsis: "Create or update a deployment",
-		Usage:    "<deployment_manifest_path>",
+		Usage:    "[--skip-drain] [--offline] [--bundle <bundle_path>] <deployment_manifest_path>",
 		Env:      genericEnv,
 	}
 }
 
 func (c *deployCmd) Run(stage biui.Stage, args []string) error {
-	deploymentManifestPath, err := c.parseCmdInputs(args)
+	deploymentManifestPath, skipDrain, offline, bundlePath, err := c.parseCmdInputs(args)
 	if err != nil {
 		return err
 	}
@@ -67,16 +71,39 @@ func (c *deployCmd) Run(stage biui.Stage, args []string) error {
 
 	c.ui.PrintLinef("Deployment manifest: '%s'", manifestAbsFilePath)
 
-	deploymentPreparer := c.deploymentPreparerProvider(manifestAbsFilePath)
-	return deploymentPreparer.PrepareDeployment(stage)
+	if bundlePath != "" {
+		bundleAbsFilePath, err := filepath.Abs(bundlePath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Getting absolute path to bundle '%s'", bundlePath)
+		}
+
+		err = stage.PerformComplex("importing bundle", func(importStage biui.Stage) error {
+			return c.tarballBundler.Import(bundleAbsFilePath, importStage)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	deploymentPreparer := c.deploymentPreparerProvider(manifestAbsFilePath, offline)
+	return deploymentPreparer.PrepareDeployment(stage, skipDrain)
 }
 
-func (c *deployCmd) parseCmdInputs(args []string) (string, error) {
-	if len(args) != 1 {
+// parseCmdInputs returns the manifest path, whether to skip draining, whether to deploy offline and the bundle path.
+// Deploying from a bundle is always offline.
+func (c *deployCmd) parseCmdInputs(args []string) (string, bool, bool, string, error) {
+	positionalArgs, boolFlags, valueFlags, err := parseFlags(args, []string{"skip-drain", "offline"}, []string{"bundle"})
+	if err != nil {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return "", false, false, "", err
+	}
+	if len(positionalArgs) != 1 {
 		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
-		return "", errors.New("Invalid usage - deploy command requires exactly 1 argument")
+		return "", false, false, "", errors.New("Invalid usage - deploy command requires exactly 1 argument")
 	}
-	return args[0], nil
+	bundlePath := valueFlags["bundle"]
+	offline := boolFlags["offline"] || bundlePath != ""
+	return positionalArgs[0], boolFlags["skip-drain"], offline, bundlePath, nil
 }
 
 func (c *deployCmd) isBlank(str string) bool {
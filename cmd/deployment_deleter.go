@@ -11,7 +11,9 @@ import (
 	biconfig "github.com/cloudfoundry/bosh-init/config"
 	bicpirel "github.com/cloudfoundry/bosh-init/cpi/release"
 	bidepl "github.com/cloudfoundry/bosh-init/deployment"
+	biagentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient"
 	bihttpagent "github.com/cloudfoundry/bosh-init/deployment/agentclient/http"
+	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
 	biinstall "github.com/cloudfoundry/bosh-init/installation"
 	biinstallmanifest "github.com/cloudfoundry/bosh-init/installation/manifest"
 	bitarball "github.com/cloudfoundry/bosh-init/installation/tarball"
@@ -21,6 +23,10 @@ import (
 	biui "github.com/cloudfoundry/bosh-init/ui"
 )
 
+// ErrandDeploymentProvider returns the state and the deployment manager factory of the VM of a dedicated errand,
+// which is recorded separately from the deployment's own VM
+type ErrandDeploymentProvider func(errandName string) (biconfig.DeploymentStateService, bidepl.ManagerFactory)
+
 func NewDeploymentDeleter(
 	ui biui.UI,
 	logTag string,
@@ -38,9 +44,11 @@ func NewDeploymentDeleter(
 	releaseExtractor birel.Extractor,
 	installationParser biinstallmanifest.Parser,
 	installationValidator biinstallmanifest.Validator,
+	deploymentParser bideplmanifest.Parser,
+	errandDeploymentProvider ErrandDeploymentProvider,
 	deploymentManifestPath string,
 	tarballProvider bitarball.Provider,
-
+	tarballCache bitarball.Cache,
 ) DeploymentDeleter {
 	return DeploymentDeleter{
 		ui:     ui,
@@ -59,8 +67,11 @@ func NewDeploymentDeleter(
 		releaseExtractor:         releaseExtractor,
 		installationParser:       installationParser,
 		installationValidator:    installationValidator,
+		deploymentParser:         deploymentParser,
+		errandDeploymentProvider: errandDeploymentProvider,
 		deploymentManifestPath:   deploymentManifestPath,
 		tarballProvider:          tarballProvider,
+		tarballCache:             tarballCache,
 	}
 }
 
@@ -81,11 +92,14 @@ type DeploymentDeleter struct {
 	releaseExtractor         birel.Extractor
 	installationParser       biinstallmanifest.Parser
 	installationValidator    biinstallmanifest.Validator
+	deploymentParser         bideplmanifest.Parser
+	errandDeploymentProvider ErrandDeploymentProvider
 	deploymentManifestPath   string
 	tarballProvider          bitarball.Provider
+	tarballCache             bitarball.Cache
 }
 
-func (c *DeploymentDeleter) DeleteDeployment(stage biui.Stage) (err error) {
+func (c *DeploymentDeleter) DeleteDeployment(stage biui.Stage, skipDrain bool) (err error) {
 	c.ui.PrintLinef("Deployment state: '%s'", c.deploymentStateService.Path())
 
 	if !c.deploymentStateService.Exists() {
@@ -105,12 +119,15 @@ func (c *DeploymentDeleter) DeleteDeployment(stage biui.Stage) (err error) {
 		}
 	}()
 
-	var installationManifest biinstallmanifest.Manifest
+	var (
+		installationManifest biinstallmanifest.Manifest
+		deploymentManifest   bideplmanifest.Manifest
+	)
 	err = stage.PerformComplex("validating", func(stage biui.Stage) error {
 		var err error
 		var cpiReleaseRef birelmanifest.ReleaseRef
 
-		installationManifest, cpiReleaseRef, err = c.parseDeploymentManifest(stage, c.deploymentManifestPath)
+		installationManifest, deploymentManifest, cpiReleaseRef, err = c.parseDeploymentManifest(stage, c.deploymentManifestPath)
 		if err != nil {
 			return err
 		}
@@ -170,6 +187,17 @@ func (c *DeploymentDeleter) DeleteDeployment(stage biui.Stage) (err error) {
 		}
 	}()
 
+	err = installation.StartMbus()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := installation.StopMbus()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Mbus failed to stop: %s", err)
+		}
+	}()
+
 	c.logger.Debug(c.logTag, "Creating cloud client...")
 	cloud, err := c.cloudFactory.NewCloud(installation, deploymentState.DirectorID)
 	if err != nil {
@@ -177,14 +205,19 @@ func (c *DeploymentDeleter) DeleteDeployment(stage biui.Stage) (err error) {
 	}
 
 	c.logger.Debug(c.logTag, "Creating agent client...")
-	agentClient := c.agentClientFactory.NewAgentClient(deploymentState.DirectorID, installationManifest.Mbus)
+	agentClient := c.agentClientFactory.NewAgentClient(deploymentState.DirectorID, installationManifest.Mbus, biconfig.NewVMRepo(c.deploymentStateService), biagentclient.TaskTimeouts{})
 
 	c.logger.Debug(c.logTag, "Creating blobstore client...")
-	blobstore, err := c.blobstoreFactory.Create(installationManifest.Mbus)
+	blobstore, err := c.blobstoreFactory.Create(installationManifest.BlobstoreURL())
 	if err != nil {
 		return bosherr.WrapError(err, "Creating blobstore client")
 	}
 
+	err = c.deleteErrandVMs(cloud, blobstore, deploymentState, deploymentManifest, installationManifest, skipDrain, stage)
+	if err != nil {
+		return err
+	}
+
 	c.logger.Debug(c.logTag, "Creating deployment manager...")
 	deploymentManager := c.deploymentManagerFactory.NewManager(cloud, agentClient, blobstore)
 
@@ -201,23 +234,77 @@ func (c *DeploymentDeleter) DeleteDeployment(stage biui.Stage) (err error) {
 			return nil
 		}
 
-		return deployment.Delete(deleteStage)
+		return deployment.Delete(skipDrain, deleteStage)
 	})
 	if err != nil {
 		return bosherr.WrapError(err, "Deleting deployment")
 	}
 
+	// the tarballs of the deleted deployment may now be evicted from the cache
+	err = c.tarballCache.UpdateReferences(c.deploymentStateService.Path(), []bitarball.Source{})
+	if err != nil {
+		c.logger.Warn(c.logTag, "Failed to forget the cached tarballs used by the deployment: %s", err.Error())
+	}
+
 	return deploymentManager.Cleanup(stage)
 }
 
+// deleteErrandVMs deletes the VMs of the dedicated errands kept alive by 'run-errand --keep-alive',
+// before the deployment's own VM and stemcell
+func (c *DeploymentDeleter) deleteErrandVMs(
+	cloud bicloud.Cloud,
+	blobstore biblobstore.Blobstore,
+	deploymentState biconfig.DeploymentState,
+	deploymentManifest bideplmanifest.Manifest,
+	installationManifest biinstallmanifest.Manifest,
+	skipDrain bool,
+	stage biui.Stage,
+) error {
+	for _, job := range deploymentManifest.Jobs {
+		if !job.IsErrand() {
+			continue
+		}
+
+		errandStateService, errandDeploymentManagerFactory := c.errandDeploymentProvider(job.Name)
+		if !errandStateService.Exists() {
+			continue
+		}
+
+		mbusURL, err := errandMbusURL(installationManifest.Mbus, job)
+		if err != nil {
+			return err
+		}
+
+		agentClient := c.agentClientFactory.NewAgentClient(deploymentState.DirectorID, mbusURL, biconfig.NewVMRepo(errandStateService), biagentclient.TaskTimeouts{})
+		errandDeployment, found, err := errandDeploymentManagerFactory.NewManager(cloud, agentClient, blobstore).FindCurrent()
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Finding VM of errand '%s'", job.Name)
+		}
+		if !found {
+			continue
+		}
+
+		err = stage.PerformComplex(fmt.Sprintf("deleting errand '%s'", job.Name), func(deleteStage biui.Stage) error {
+			return errandDeployment.Delete(skipDrain, deleteStage)
+		})
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Deleting errand '%s'", job.Name)
+		}
+	}
+
+	return nil
+}
+
 func (c *DeploymentDeleter) parseDeploymentManifest(validationStage biui.Stage, deploymentManifestPath string) (
 	biinstallmanifest.Manifest,
+	bideplmanifest.Manifest,
 	birelmanifest.ReleaseRef,
 	error,
 ) {
 	var cpiRelease birelmanifest.ReleaseRef
 	var releaseSetManifest birelsetmanifest.Manifest
 	var installationManifest biinstallmanifest.Manifest
+	var deploymentManifest bideplmanifest.Manifest
 
 	err := validationStage.Perform("Validating deployment manifest", func() error {
 		var err error
@@ -226,6 +313,12 @@ func (c *DeploymentDeleter) parseDeploymentManifest(validationStage biui.Stage,
 			return bosherr.WrapErrorf(err, "Parsing installation manifest '%s'", deploymentManifestPath)
 		}
 
+		// the deployment manifest locates the VMs of dedicated errands
+		deploymentManifest, err = c.deploymentParser.Parse(deploymentManifestPath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Parsing deployment manifest '%s'", deploymentManifestPath)
+		}
+
 		releaseSetManifest, err = c.releaseSetParser.Parse(deploymentManifestPath)
 		if err != nil {
 			return bosherr.WrapErrorf(err, "Parsing release set manifest '%s'", deploymentManifestPath)
@@ -250,5 +343,5 @@ func (c *DeploymentDeleter) parseDeploymentManifest(validationStage biui.Stage,
 
 		return nil
 	})
-	return installationManifest, cpiRelease, err
+	return installationManifest, deploymentManifest, cpiRelease, err
 }
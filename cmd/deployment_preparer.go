@@ -12,6 +12,7 @@ import (
 	biconfig "github.com/cloudfoundry/bosh-init/config"
 	bicpirel "github.com/cloudfoundry/bosh-init/cpi/release"
 	bidepl "github.com/cloudfoundry/bosh-init/deployment"
+	biagentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient"
 	bihttpagent "github.com/cloudfoundry/bosh-init/deployment/agentclient/http"
 	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
 	bivm "github.com/cloudfoundry/bosh-init/deployment/vm"
@@ -20,6 +21,7 @@ import (
 	bitarball "github.com/cloudfoundry/bosh-init/installation/tarball"
 	birel "github.com/cloudfoundry/bosh-init/release"
 	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
+	bistatepkg "github.com/cloudfoundry/bosh-init/state/pkg"
 	bistemcell "github.com/cloudfoundry/bosh-init/stemcell"
 	biui "github.com/cloudfoundry/bosh-init/ui"
 )
@@ -50,7 +52,10 @@ func NewDeploymentPreparer(
 	stemcellExtractor bistemcell.Extractor,
 	deploymentManifestPath string,
 	tarballProvider bitarball.Provider,
-
+	tarballCache bitarball.Cache,
+	compiledPackageRepo bistatepkg.CompiledPackageRepo,
+	compiledPackageRecordRepo biconfig.CompiledPackageRepo,
+	contentAddressedBlobs bool,
 ) DeploymentPreparer {
 	return DeploymentPreparer{
 		ui:                            ui,
@@ -68,22 +73,32 @@ func NewDeploymentPreparer(
 		vmManagerFactory:              vmManagerFactory,
 		blobstoreFactory:              blobstoreFactory,
 		deployer:                      deployer,
-		releaseSetParser:              releaseSetParser,
-		installationParser:            installationParser,
-		deploymentParser:              deploymentParser,
-		releaseSetValidator:           releaseSetValidator,
-		installationValidator:         installationValidator,
-		deploymentValidator:           deploymentValidator,
-		releaseExtractor:              releaseExtractor,
-		stemcellExtractor:             stemcellExtractor,
-		deploymentManifestPath:        deploymentManifestPath,
-		tarballProvider:               tarballProvider,
+		manifestValidator: newManifestValidator(
+			fs,
+			releaseSetParser,
+			releaseSetValidator,
+			deploymentParser,
+			deploymentValidator,
+			releaseExtractor,
+			releaseManager,
+			tarballProvider,
+		),
+		installationParser:        installationParser,
+		installationValidator:     installationValidator,
+		stemcellExtractor:         stemcellExtractor,
+		deploymentManifestPath:    deploymentManifestPath,
+		tarballProvider:           tarballProvider,
+		tarballCache:              tarballCache,
+		compiledPackageRepo:       compiledPackageRepo,
+		compiledPackageRecordRepo: compiledPackageRecordRepo,
+		contentAddressedBlobs:     contentAddressedBlobs,
 	}
 }
 
 type DeploymentPreparer struct {
 	ui                            biui.UI
 	fs                            boshsys.FileSystem
+	manifestValidator             manifestValidator
 	logger                        boshlog.Logger
 	logTag                        string
 	deploymentStateService        biconfig.DeploymentStateService
@@ -97,19 +112,18 @@ type DeploymentPreparer struct {
 	vmManagerFactory              bivm.ManagerFactory
 	blobstoreFactory              biblobstore.Factory
 	deployer                      bidepl.Deployer
-	releaseSetParser              birelsetmanifest.Parser
 	installationParser            biinstallmanifest.Parser
-	deploymentParser              bideplmanifest.Parser
-	releaseSetValidator           birelsetmanifest.Validator
 	installationValidator         biinstallmanifest.Validator
-	deploymentValidator           bideplmanifest.Validator
-	releaseExtractor              birel.Extractor
 	stemcellExtractor             bistemcell.Extractor
 	deploymentManifestPath        string
 	tarballProvider               bitarball.Provider
+	tarballCache                  bitarball.Cache
+	compiledPackageRepo           bistatepkg.CompiledPackageRepo
+	compiledPackageRecordRepo     biconfig.CompiledPackageRepo
+	contentAddressedBlobs         bool
 }
 
-func (c *DeploymentPreparer) PrepareDeployment(stage biui.Stage) (err error) {
+func (c *DeploymentPreparer) PrepareDeployment(stage biui.Stage, skipDrain bool) (err error) {
 	c.ui.PrintLinef("Deployment state: '%s'", c.deploymentStateService.Path())
 
 	if !c.deploymentStateService.Exists() {
@@ -131,9 +145,10 @@ func (c *DeploymentPreparer) PrepareDeployment(stage biui.Stage) (err error) {
 		extractedStemcell    bistemcell.ExtractedStemcell
 		deploymentManifest   bideplmanifest.Manifest
 		installationManifest biinstallmanifest.Manifest
+		tarballSources       []bitarball.Source
 	)
 	err = stage.PerformComplex("validating", func(stage biui.Stage) error {
-		extractedStemcell, deploymentManifest, installationManifest, err = c.validate(stage, c.deploymentManifestPath)
+		extractedStemcell, deploymentManifest, installationManifest, tarballSources, err = c.validate(stage, c.deploymentManifestPath)
 		return err
 	})
 	if err != nil {
@@ -159,6 +174,7 @@ func (c *DeploymentPreparer) PrepareDeployment(stage biui.Stage) (err error) {
 
 	if isDeployed {
 		c.ui.PrintLinef("No deployment, stemcell or release changes. Skipping deploy.")
+		c.updateTarballReferences(tarballSources)
 		return nil
 	}
 
@@ -190,6 +206,17 @@ func (c *DeploymentPreparer) PrepareDeployment(stage biui.Stage) (err error) {
 		}
 	}()
 
+	err = installation.StartMbus()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := installation.StopMbus()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Mbus failed to stop: %s", err)
+		}
+	}()
+
 	cloud, err := c.cloudFactory.NewCloud(installation, deploymentState.DirectorID)
 	if err != nil {
 		return bosherr.WrapError(err, "Creating CPI client from CPI installation")
@@ -202,10 +229,10 @@ func (c *DeploymentPreparer) PrepareDeployment(stage biui.Stage) (err error) {
 		return err
 	}
 
-	agentClient := c.agentClientFactory.NewAgentClient(deploymentState.DirectorID, installationManifest.Mbus)
+	agentClient := c.agentClientFactory.NewAgentClient(deploymentState.DirectorID, installationManifest.Mbus, biconfig.NewVMRepo(c.deploymentStateService), biagentclient.TaskTimeouts(deploymentManifest.Update.AgentTaskTimeouts))
 	vmManager := c.vmManagerFactory.NewManager(cloud, agentClient)
 
-	blobstore, err := c.blobstoreFactory.Create(installationManifest.Mbus)
+	blobstore, err := createAgentBlobstore(c.blobstoreFactory, installationManifest.BlobstoreURL(), c.contentAddressedBlobs, c.deploymentStateService)
 	if err != nil {
 		return bosherr.WrapError(err, "Creating blobstore client")
 	}
@@ -223,6 +250,7 @@ func (c *DeploymentPreparer) PrepareDeployment(stage biui.Stage) (err error) {
 			installationManifest.Registry,
 			vmManager,
 			blobstore,
+			skipDrain,
 			deployStage,
 		)
 		if err != nil {
@@ -242,6 +270,12 @@ func (c *DeploymentPreparer) PrepareDeployment(stage biui.Stage) (err error) {
 
 	// TODO: cleanup unused disks here?
 
+	c.updateTarballReferences(tarballSources)
+	err = c.saveCompiledPackages(extractedStemcell.Manifest())
+	if err != nil {
+		return err
+	}
+
 	err = stemcellManager.DeleteUnused(stage)
 	if err != nil {
 		return err
@@ -250,6 +284,51 @@ func (c *DeploymentPreparer) PrepareDeployment(stage biui.Stage) (err error) {
 	return nil
 }
 
+// updateTarballReferences keeps the tarballs of the deployment from being evicted from the cache
+func (c *DeploymentPreparer) updateTarballReferences(tarballSources []bitarball.Source) {
+	err := c.tarballCache.UpdateReferences(c.deploymentStateService.Path(), tarballSources)
+	if err != nil {
+		c.logger.Warn(c.logTag, "Failed to record the cached tarballs used by the deployment: %s", err.Error())
+	}
+}
+
+// saveCompiledPackages records the packages compiled on the VM in the deployment state, so that they can be exported
+func (c *DeploymentPreparer) saveCompiledPackages(stemcellManifest bistemcell.Manifest) error {
+	stemcell := fmt.Sprintf("%s/%s", stemcellManifest.OS, stemcellManifest.Version)
+
+	records := []biconfig.CompiledPackageRecord{}
+	for _, release := range c.releaseManager.List() {
+		for _, pkg := range release.Packages() {
+			record, found, err := c.compiledPackageRepo.Find(*pkg)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Finding compiled package '%s/%s'", pkg.Name, pkg.Fingerprint)
+			}
+			if !found {
+				continue
+			}
+
+			records = append(records, biconfig.CompiledPackageRecord{
+				Name:        pkg.Name,
+				Fingerprint: pkg.Fingerprint,
+				Stemcell:    stemcell,
+				BlobID:      record.BlobID,
+				SHA1:        record.BlobSHA1,
+			})
+		}
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	err := c.compiledPackageRecordRepo.Save(records...)
+	if err != nil {
+		return bosherr.WrapError(err, "Recording compiled packages")
+	}
+
+	return nil
+}
+
 func (c *DeploymentPreparer) validate(
 	validationStage biui.Stage,
 	deploymentManifestPath string,
@@ -257,28 +336,14 @@ func (c *DeploymentPreparer) validate(
 	extractedStemcell bistemcell.ExtractedStemcell,
 	deploymentManifest bideplmanifest.Manifest,
 	installationManifest biinstallmanifest.Manifest,
+	tarballSources []bitarball.Source,
 	err error,
 ) {
 	var releaseSetManifest birelsetmanifest.Manifest
 	err = validationStage.Perform("Validating deployment manifest", func() error {
-		releaseSetManifest, err = c.releaseSetParser.Parse(deploymentManifestPath)
-		if err != nil {
-			return bosherr.WrapErrorf(err, "Parsing release set manifest '%s'", deploymentManifestPath)
-		}
-
-		err = c.releaseSetValidator.Validate(releaseSetManifest)
-		if err != nil {
-			return bosherr.WrapError(err, "Validating release set manifest")
-		}
-
-		deploymentManifest, err = c.deploymentParser.Parse(deploymentManifestPath)
+		releaseSetManifest, deploymentManifest, err = c.manifestValidator.ValidateManifests(deploymentManifestPath)
 		if err != nil {
-			return bosherr.WrapErrorf(err, "Parsing deployment manifest '%s'", deploymentManifestPath)
-		}
-
-		err = c.deploymentValidator.Validate(deploymentManifest, releaseSetManifest)
-		if err != nil {
-			return bosherr.WrapError(err, "Validating deployment manifest")
+			return err
 		}
 
 		installationManifest, err = c.installationParser.Parse(deploymentManifestPath)
@@ -294,65 +359,39 @@ func (c *DeploymentPreparer) validate(
 		return nil
 	})
 	if err != nil {
-		return extractedStemcell, deploymentManifest, installationManifest, err
+		return extractedStemcell, deploymentManifest, installationManifest, tarballSources, err
 	}
 
 	for _, releaseRef := range releaseSetManifest.Releases {
-		releasePath, err := c.tarballProvider.Get(releaseRef, validationStage)
+		tarballSources = append(tarballSources, releaseRef)
+	}
+	defer func() {
 		if err != nil {
-			return extractedStemcell, deploymentManifest, installationManifest, err
-		}
-
-		err = validationStage.Perform(fmt.Sprintf("Validating release '%s'", releaseRef.Name), func() error {
-			if !c.fs.FileExists(releasePath) {
-				return bosherr.Errorf("File path '%s' does not exist", releasePath)
-			}
-
-			release, err := c.releaseExtractor.Extract(releasePath)
+			err := c.releaseManager.DeleteAll()
 			if err != nil {
-				return bosherr.WrapErrorf(err, "Extracting release '%s'", releasePath)
+				c.logger.Warn(c.logTag, "Deleting all extracted releases: %s", err.Error())
 			}
-
-			if release.Name() != releaseRef.Name {
-				return bosherr.Errorf("Release name '%s' does not match the name in release tarball '%s'", releaseRef.Name, release.Name())
-			}
-			c.releaseManager.Add(release)
-
-			return nil
-		})
-		if err != nil {
-			return extractedStemcell, deploymentManifest, installationManifest, err
 		}
-		defer func() {
-			if err != nil {
-				err := c.releaseManager.DeleteAll()
-				if err != nil {
-					c.logger.Warn(c.logTag, "Deleting all extracted releases: %s", err.Error())
-				}
-			}
-		}()
+	}()
+	err = c.manifestValidator.ValidateReleases(validationStage, releaseSetManifest)
+	if err != nil {
+		return extractedStemcell, deploymentManifest, installationManifest, tarballSources, err
 	}
 
-	err = validationStage.Perform("Validating jobs", func() error {
-		err = c.deploymentValidator.ValidateReleaseJobs(deploymentManifest, c.releaseManager)
-		if err != nil {
-			return bosherr.WrapError(err, "Validating deployment jobs refer to jobs in release")
-		}
-
-		return nil
-	})
+	err = c.manifestValidator.ValidateReleaseJobs(validationStage, deploymentManifest)
 	if err != nil {
-		return extractedStemcell, deploymentManifest, installationManifest, err
+		return extractedStemcell, deploymentManifest, installationManifest, tarballSources, err
 	}
 
 	stemcell, err := deploymentManifest.Stemcell(deploymentManifest.JobName())
 	if err != nil {
-		return extractedStemcell, deploymentManifest, installationManifest, err
+		return extractedStemcell, deploymentManifest, installationManifest, tarballSources, err
 	}
 
+	tarballSources = append(tarballSources, stemcell)
 	stemcellTarballPath, err := c.tarballProvider.Get(stemcell, validationStage)
 	if err != nil {
-		return extractedStemcell, deploymentManifest, installationManifest, err
+		return extractedStemcell, deploymentManifest, installationManifest, tarballSources, err
 	}
 
 	err = validationStage.Perform("Validating stemcell", func() error {
@@ -368,7 +407,7 @@ func (c *DeploymentPreparer) validate(
 		return nil
 	})
 	if err != nil {
-		return extractedStemcell, deploymentManifest, installationManifest, err
+		return extractedStemcell, deploymentManifest, installationManifest, tarballSources, err
 	}
 	defer func() {
 		if err != nil {
@@ -379,6 +418,12 @@ func (c *DeploymentPreparer) validate(
 		}
 	}()
 
+	stemcellManifest := extractedStemcell.Manifest()
+	err = c.manifestValidator.ValidateCompiledReleases(validationStage, stemcellManifest.OS, stemcellManifest.Version)
+	if err != nil {
+		return extractedStemcell, deploymentManifest, installationManifest, tarballSources, err
+	}
+
 	err = validationStage.Perform("Validating cpi release", func() error {
 		cpiReleaseName := installationManifest.Template.Release
 		cpiRelease, found := c.releaseManager.Find(cpiReleaseName)
@@ -395,5 +440,19 @@ func (c *DeploymentPreparer) validate(
 		return nil
 	})
 
-	return extractedStemcell, deploymentManifest, installationManifest, err
+	return extractedStemcell, deploymentManifest, installationManifest, tarballSources, err
+}
+
+// createAgentBlobstore returns the client of the agent blobstore. Blob IDs are UUIDs unless content-addressed blobs
+// are enabled, in which case they are the SHA1 of the content and blobs already uploaded to the VM are not uploaded again.
+func createAgentBlobstore(
+	blobstoreFactory biblobstore.Factory,
+	blobstoreURL string,
+	contentAddressed bool,
+	deploymentStateService biconfig.DeploymentStateService,
+) (biblobstore.Blobstore, error) {
+	if contentAddressed {
+		return blobstoreFactory.CreateContentAddressed(blobstoreURL, biconfig.NewBlobRepo(deploymentStateService))
+	}
+	return blobstoreFactory.Create(blobstoreURL)
 }
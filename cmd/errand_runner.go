@@ -0,0 +1,509 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	boshsys "github.com/cloudfoundry/bosh-agent/system"
+	boshtime "github.com/cloudfoundry/bosh-agent/time"
+
+	biblobstore "github.com/cloudfoundry/bosh-init/blobstore"
+	bicloud "github.com/cloudfoundry/bosh-init/cloud"
+	biconfig "github.com/cloudfoundry/bosh-init/config"
+	bicpirel "github.com/cloudfoundry/bosh-init/cpi/release"
+	biagentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient"
+	bihttpagent "github.com/cloudfoundry/bosh-init/deployment/agentclient/http"
+	binats "github.com/cloudfoundry/bosh-init/deployment/agentclient/nats"
+	biinstance "github.com/cloudfoundry/bosh-init/deployment/instance"
+	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
+	bivm "github.com/cloudfoundry/bosh-init/deployment/vm"
+	biinstall "github.com/cloudfoundry/bosh-init/installation"
+	biinstallmanifest "github.com/cloudfoundry/bosh-init/installation/manifest"
+	bitarball "github.com/cloudfoundry/bosh-init/installation/tarball"
+	birel "github.com/cloudfoundry/bosh-init/release"
+	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
+	bistemcell "github.com/cloudfoundry/bosh-init/stemcell"
+	biui "github.com/cloudfoundry/bosh-init/ui"
+)
+
+func NewErrandRunner(
+	ui biui.UI,
+	fs boshsys.FileSystem,
+	logger boshlog.Logger,
+	logTag string,
+	timeService boshtime.Service,
+	deploymentStateService biconfig.DeploymentStateService,
+	errandStateService biconfig.DeploymentStateService,
+	releaseManager birel.Manager,
+	installerFactory biinstall.InstallerFactory,
+	cloudFactory bicloud.Factory,
+	stemcellManagerFactory bistemcell.ManagerFactory,
+	agentClientFactory bihttpagent.AgentClientFactory,
+	vmManagerFactory bivm.ManagerFactory,
+	errandVMManagerFactory bivm.ManagerFactory,
+	instanceManagerFactory biinstance.ManagerFactory,
+	blobstoreFactory biblobstore.Factory,
+	releaseSetParser birelsetmanifest.Parser,
+	installationParser biinstallmanifest.Parser,
+	deploymentParser bideplmanifest.Parser,
+	releaseSetValidator birelsetmanifest.Validator,
+	installationValidator biinstallmanifest.Validator,
+	deploymentValidator bideplmanifest.Validator,
+	releaseExtractor birel.Extractor,
+	deploymentManifestPath string,
+	tarballProvider bitarball.Provider,
+	contentAddressedBlobs bool,
+) ErrandRunner {
+	return ErrandRunner{
+		ui:                     ui,
+		fs:                     fs,
+		logger:                 logger,
+		logTag:                 logTag,
+		timeService:            timeService,
+		deploymentStateService: deploymentStateService,
+		errandStateService:     errandStateService,
+		releaseManager:         releaseManager,
+		installerFactory:       installerFactory,
+		cloudFactory:           cloudFactory,
+		stemcellManagerFactory: stemcellManagerFactory,
+		agentClientFactory:     agentClientFactory,
+		vmManagerFactory:       vmManagerFactory,
+		errandVMManagerFactory: errandVMManagerFactory,
+		instanceManagerFactory: instanceManagerFactory,
+		blobstoreFactory:       blobstoreFactory,
+		manifestValidator: newManifestValidator(
+			fs,
+			releaseSetParser,
+			releaseSetValidator,
+			deploymentParser,
+			deploymentValidator,
+			releaseExtractor,
+			releaseManager,
+			tarballProvider,
+		),
+		installationParser:     installationParser,
+		installationValidator:  installationValidator,
+		deploymentManifestPath: deploymentManifestPath,
+		contentAddressedBlobs:  contentAddressedBlobs,
+	}
+}
+
+type ErrandRunner struct {
+	ui                     biui.UI
+	fs                     boshsys.FileSystem
+	manifestValidator      manifestValidator
+	logger                 boshlog.Logger
+	logTag                 string
+	timeService            boshtime.Service
+	deploymentStateService biconfig.DeploymentStateService
+	errandStateService     biconfig.DeploymentStateService
+	releaseManager         birel.Manager
+	installerFactory       biinstall.InstallerFactory
+	cloudFactory           bicloud.Factory
+	stemcellManagerFactory bistemcell.ManagerFactory
+	agentClientFactory     bihttpagent.AgentClientFactory
+	vmManagerFactory       bivm.ManagerFactory
+	errandVMManagerFactory bivm.ManagerFactory
+	instanceManagerFactory biinstance.ManagerFactory
+	blobstoreFactory       biblobstore.Factory
+	installationParser     biinstallmanifest.Parser
+	installationValidator  biinstallmanifest.Validator
+	deploymentManifestPath string
+	contentAddressedBlobs  bool
+}
+
+func (c *ErrandRunner) RunErrand(stage biui.Stage, errandName string, keepAlive bool) error {
+	c.ui.PrintLinef("Deployment state: '%s'", c.deploymentStateService.Path())
+
+	if !c.deploymentStateService.Exists() {
+		return bosherr.Errorf("Deployment state '%s' does not exist, errands can only be run after deploying", c.deploymentStateService.Path())
+	}
+
+	deploymentState, err := c.deploymentStateService.Load()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading deployment state")
+	}
+
+	var (
+		deploymentManifest   bideplmanifest.Manifest
+		installationManifest biinstallmanifest.Manifest
+	)
+	err = stage.PerformComplex("validating", func(stage biui.Stage) error {
+		deploymentManifest, installationManifest, err = c.validate(stage, c.deploymentManifestPath, deploymentState)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := c.releaseManager.DeleteAll()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Deleting all extracted releases: %s", err.Error())
+		}
+	}()
+
+	errandJob, isDedicated := deploymentManifest.FindJobByName(errandName)
+	isDedicated = isDedicated && errandJob.IsErrand()
+	if !isDedicated && !deploymentManifest.ServiceJobs()[0].HasTemplate(errandName) {
+		return bosherr.Errorf("Errand '%s' must be a job with lifecycle 'errand' or a template of job '%s'", errandName, deploymentManifest.JobName())
+	}
+
+	installer, err := c.installerFactory.NewInstaller()
+	if err != nil {
+		return bosherr.WrapError(err, "Creating CPI Installer")
+	}
+
+	var installation biinstall.Installation
+	err = stage.PerformComplex("installing CPI", func(installStage biui.Stage) error {
+		installation, err = installer.Install(installationManifest, installStage)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = stage.Perform("Starting registry", func() error {
+		return installation.StartRegistry()
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := installation.StopRegistry()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Registry failed to stop: %s", err)
+		}
+	}()
+
+	err = installation.StartMbus()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := installation.StopMbus()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Mbus failed to stop: %s", err)
+		}
+	}()
+
+	cloud, err := c.cloudFactory.NewCloud(installation, deploymentState.DirectorID)
+	if err != nil {
+		return bosherr.WrapError(err, "Creating CPI client from CPI installation")
+	}
+
+	// a dedicated errand VM is served by its own agent, and its blobs are recorded with its own state
+	blobstoreURL := installationManifest.BlobstoreURL()
+	blobStateService := c.deploymentStateService
+	if isDedicated {
+		blobstoreURL, err = errandBlobstoreURL(installationManifest, errandJob)
+		if err != nil {
+			return err
+		}
+		blobStateService = c.errandStateService
+	}
+
+	blobstore, err := createAgentBlobstore(c.blobstoreFactory, blobstoreURL, c.contentAddressedBlobs, blobStateService)
+	if err != nil {
+		return bosherr.WrapError(err, "Creating blobstore client")
+	}
+
+	var (
+		result   biagentclient.ErrandResult
+		logsPath string
+	)
+	err = stage.PerformComplex(fmt.Sprintf("running errand '%s'", errandName), func(errandStage biui.Stage) error {
+		var instance biinstance.Instance
+		var err error
+		if isDedicated {
+			instance, err = c.findOrCreateErrandInstance(cloud, blobstore, deploymentState, deploymentManifest, installationManifest, errandJob, errandStage)
+		} else {
+			instance, err = c.findDeployedInstance(cloud, blobstore, deploymentState, deploymentManifest, installationManifest, errandName)
+		}
+		if err != nil {
+			return err
+		}
+
+		result, logsPath, err = c.runErrandOnInstance(instance, isDedicated && !keepAlive, errandName, deploymentManifest, blobstore, errandStage)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	c.printErrandResult(errandName, result, logsPath)
+
+	if result.ExitCode != 0 {
+		return bosherr.Errorf("Errand '%s' completed with error (exit code %d)", errandName, result.ExitCode)
+	}
+
+	return nil
+}
+
+// runErrandOnInstance runs the errand and downloads its logs, then deletes the instance when asked to,
+// failing when the deletion fails even though the errand succeeded
+func (c *ErrandRunner) runErrandOnInstance(
+	instance biinstance.Instance,
+	deleteInstance bool,
+	errandName string,
+	deploymentManifest bideplmanifest.Manifest,
+	blobstore biblobstore.Blobstore,
+	errandStage biui.Stage,
+) (result biagentclient.ErrandResult, logsPath string, err error) {
+	if deleteInstance {
+		defer func() {
+			pingTimeout := 10 * time.Second
+			pingDelay := 500 * time.Millisecond
+			deleteErr := instance.Delete(pingTimeout, pingDelay, true, errandStage)
+			if deleteErr != nil && err == nil {
+				err = bosherr.WrapErrorf(deleteErr, "Deleting errand instance '%s/%d'", instance.JobName(), instance.ID())
+			}
+		}()
+	}
+
+	result, err = instance.RunErrand(errandName, deploymentManifest, errandStage)
+	if err != nil {
+		return result, "", err
+	}
+
+	logsPath, err = c.downloadLogs(instance, blobstore, errandName, errandStage)
+	return result, logsPath, err
+}
+
+func (c *ErrandRunner) findDeployedInstance(
+	cloud bicloud.Cloud,
+	blobstore biblobstore.Blobstore,
+	deploymentState biconfig.DeploymentState,
+	deploymentManifest bideplmanifest.Manifest,
+	installationManifest biinstallmanifest.Manifest,
+	errandName string,
+) (biinstance.Instance, error) {
+	agentClient := c.agentClientFactory.NewAgentClient(deploymentState.DirectorID, installationManifest.Mbus, biconfig.NewVMRepo(c.deploymentStateService), biagentclient.TaskTimeouts(deploymentManifest.Update.AgentTaskTimeouts))
+	vmManager := c.vmManagerFactory.NewManager(cloud, agentClient)
+	instanceManager := c.instanceManagerFactory.NewManager(cloud, vmManager, blobstore)
+
+	instances, err := instanceManager.FindCurrent()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Finding deployed instances")
+	}
+
+	if len(instances) == 0 {
+		return nil, bosherr.Errorf("No deployed instance found to run colocated errand '%s'", errandName)
+	}
+
+	return instances[0], nil
+}
+
+func (c *ErrandRunner) findOrCreateErrandInstance(
+	cloud bicloud.Cloud,
+	blobstore biblobstore.Blobstore,
+	deploymentState biconfig.DeploymentState,
+	deploymentManifest bideplmanifest.Manifest,
+	installationManifest biinstallmanifest.Manifest,
+	errandJob bideplmanifest.Job,
+	errandStage biui.Stage,
+) (biinstance.Instance, error) {
+	// the errand VM runs alongside the deployed VM, so its agent is reached on the errand job's own IP
+	mbusURL, err := errandMbusURL(installationManifest.Mbus, errandJob)
+	if err != nil {
+		return nil, err
+	}
+
+	agentClient := c.agentClientFactory.NewAgentClient(deploymentState.DirectorID, mbusURL, biconfig.NewVMRepo(c.errandStateService), biagentclient.TaskTimeouts(deploymentManifest.Update.AgentTaskTimeouts))
+	vmManager := c.errandVMManagerFactory.NewManager(cloud, agentClient)
+	instanceManager := c.instanceManagerFactory.NewManager(cloud, vmManager, blobstore)
+
+	instances, err := instanceManager.FindCurrent()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Finding errand instances")
+	}
+
+	if len(instances) > 0 {
+		c.logger.Debug(c.logTag, "Reusing errand VM kept alive by a previous run")
+		return instances[0], nil
+	}
+
+	stemcells, err := c.stemcellManagerFactory.NewManager(cloud).FindCurrent()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Finding deployed stemcell")
+	}
+
+	if len(stemcells) == 0 {
+		return nil, bosherr.Errorf("No deployed stemcell found to create errand VM for '%s'", errandJob.Name)
+	}
+
+	instance, _, err := instanceManager.Create(errandJob.Name, 0, deploymentManifest, stemcells[0], installationManifest.Registry, errandStage)
+	if err != nil {
+		return instance, bosherr.WrapErrorf(err, "Creating errand instance '%s/0'", errandJob.Name)
+	}
+
+	return instance, nil
+}
+
+func (c *ErrandRunner) downloadLogs(instance biinstance.Instance, blobstore biblobstore.Blobstore, errandName string, errandStage biui.Stage) (string, error) {
+	blobID, err := instance.FetchLogs(errandStage)
+	if err != nil {
+		return "", err
+	}
+
+	timestamp := c.timeService.Now().UTC().Format("20060102-150405")
+	logsPath := filepath.Join(filepath.Dir(c.deploymentManifestPath), fmt.Sprintf("%s-%s.tgz", errandName, timestamp))
+
+	err = errandStage.Perform(fmt.Sprintf("Downloading logs to '%s'", logsPath), func() error {
+		// fetch_logs does not report the SHA1 of the logs blob
+		localBlob, err := blobstore.Get(blobID, "")
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Downloading logs blob '%s'", blobID)
+		}
+		defer localBlob.DeleteSilently()
+
+		err = c.fs.CopyFile(localBlob.Path(), logsPath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Copying logs to '%s'", logsPath)
+		}
+
+		return nil
+	})
+
+	return logsPath, err
+}
+
+func (c *ErrandRunner) printErrandResult(errandName string, result biagentclient.ErrandResult, logsPath string) {
+	c.ui.PrintLinef("")
+	c.ui.PrintLinef("Errand '%s' completed with exit code %d", errandName, result.ExitCode)
+
+	if result.Stdout != "" {
+		c.ui.PrintLinef("")
+		c.ui.PrintLinef("[stdout]")
+		c.ui.PrintLinef("%s", result.Stdout)
+	}
+
+	if result.Stderr != "" {
+		c.ui.PrintLinef("")
+		c.ui.PrintLinef("[stderr]")
+		c.ui.PrintLinef("%s", result.Stderr)
+	}
+
+	c.ui.PrintLinef("")
+	c.ui.PrintLinef("Errand logs saved to '%s'", logsPath)
+}
+
+func (c *ErrandRunner) validate(
+	validationStage biui.Stage,
+	deploymentManifestPath string,
+	deploymentState biconfig.DeploymentState,
+) (
+	deploymentManifest bideplmanifest.Manifest,
+	installationManifest biinstallmanifest.Manifest,
+	err error,
+) {
+	var releaseSetManifest birelsetmanifest.Manifest
+	err = validationStage.Perform("Validating deployment manifest", func() error {
+		releaseSetManifest, deploymentManifest, err = c.manifestValidator.ValidateManifests(deploymentManifestPath)
+		if err != nil {
+			return err
+		}
+
+		installationManifest, err = c.installationParser.Parse(deploymentManifestPath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Parsing installation manifest '%s'", deploymentManifestPath)
+		}
+
+		err = c.installationValidator.Validate(installationManifest, releaseSetManifest)
+		if err != nil {
+			return bosherr.WrapError(err, "Validating installation manifest")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return deploymentManifest, installationManifest, err
+	}
+
+	err = c.manifestValidator.ValidateReleases(validationStage, releaseSetManifest)
+	if err != nil {
+		return deploymentManifest, installationManifest, err
+	}
+
+	err = c.manifestValidator.ValidateReleaseJobs(validationStage, deploymentManifest)
+	if err != nil {
+		return deploymentManifest, installationManifest, err
+	}
+
+	// errands run on VMs created from the deployed stemcell, whose OS is not recorded by older versions of bosh-init
+	for _, stemcellRecord := range deploymentState.Stemcells {
+		if stemcellRecord.ID != deploymentState.CurrentStemcellID {
+			continue
+		}
+		if stemcellRecord.OS == "" {
+			c.logger.Debug(c.logTag, "Skipping compiled release validation, OS of deployed stemcell '%s/%s' is not recorded", stemcellRecord.Name, stemcellRecord.Version)
+			break
+		}
+
+		err = c.manifestValidator.ValidateCompiledReleases(validationStage, stemcellRecord.OS, stemcellRecord.Version)
+		if err != nil {
+			return deploymentManifest, installationManifest, err
+		}
+	}
+
+	err = validationStage.Perform("Validating cpi release", func() error {
+		cpiReleaseName := installationManifest.Template.Release
+		cpiRelease, found := c.releaseManager.Find(cpiReleaseName)
+		if !found {
+			return bosherr.Errorf("installation release '%s' must refer to a provided release", cpiReleaseName)
+		}
+
+		err = bicpirel.NewValidator().Validate(cpiRelease, installationManifest.Template.Name)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Invalid CPI release '%s'", cpiReleaseName)
+		}
+
+		return nil
+	})
+
+	return deploymentManifest, installationManifest, err
+}
+
+func errandMbusURL(mbusURL string, errandJob bideplmanifest.Job) (string, error) {
+	if binats.IsNATSURL(mbusURL) {
+		// every agent connects to the same embedded NATS server, which only addresses a single agent
+		return "", bosherr.Errorf("Errand job '%s' cannot run on a dedicated VM when cloud_provider.mbus uses nats", errandJob.Name)
+	}
+
+	var errandIP string
+	for _, network := range errandJob.Networks {
+		if len(network.StaticIPs) > 0 {
+			errandIP = network.StaticIPs[0]
+			break
+		}
+	}
+	if errandIP == "" {
+		return "", bosherr.Errorf("Errand job '%s' must have a static IP so that its agent can be reached", errandJob.Name)
+	}
+
+	parsedURL, err := url.Parse(mbusURL)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Parsing mbus url '%s'", mbusURL)
+	}
+
+	// an mbus url without a port uses the default port of its scheme
+	if _, port, err := net.SplitHostPort(parsedURL.Host); err == nil {
+		parsedURL.Host = net.JoinHostPort(errandIP, port)
+	} else {
+		parsedURL.Host = errandIP
+	}
+	return parsedURL.String(), nil
+}
+
+// errandBlobstoreURL returns the blobstore of a dedicated errand VM: cloud_provider.blobstore when set,
+// or else the https mbus of the errand VM's agent, like errandMbusURL
+func errandBlobstoreURL(installationManifest biinstallmanifest.Manifest, errandJob bideplmanifest.Job) (string, error) {
+	if installationManifest.Blobstore != "" {
+		return installationManifest.Blobstore, nil
+	}
+	return errandMbusURL(installationManifest.Mbus, errandJob)
+}
@@ -0,0 +1,440 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	boshcmd "github.com/cloudfoundry/bosh-agent/platform/commands"
+	boshsys "github.com/cloudfoundry/bosh-agent/system"
+
+	biblobstore "github.com/cloudfoundry/bosh-init/blobstore"
+	biconfig "github.com/cloudfoundry/bosh-init/config"
+	biinstallmanifest "github.com/cloudfoundry/bosh-init/installation/manifest"
+	bitarball "github.com/cloudfoundry/bosh-init/installation/tarball"
+	birel "github.com/cloudfoundry/bosh-init/release"
+	birelmanifest "github.com/cloudfoundry/bosh-init/release/manifest"
+	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
+	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
+	biui "github.com/cloudfoundry/bosh-init/ui"
+)
+
+type exportReleaseCmd struct {
+	ui                          biui.UI
+	fs                          boshsys.FileSystem
+	compressor                  boshcmd.Compressor
+	releaseSetParser            birelsetmanifest.Parser
+	releaseSetValidator         birelsetmanifest.Validator
+	installationParser          biinstallmanifest.Parser
+	releaseExtractor            birel.Extractor
+	releaseManager              birel.Manager
+	tarballProvider             bitarball.Provider
+	tarballCache                bitarball.Cache
+	blobstoreFactory            biblobstore.Factory
+	compiledPackageRepoProvider func(deploymentManifestPath string) biconfig.CompiledPackageRepo
+	logger                      boshlog.Logger
+	logTag                      string
+}
+
+func NewExportReleaseCmd(
+	ui biui.UI,
+	fs boshsys.FileSystem,
+	compressor boshcmd.Compressor,
+	releaseSetParser birelsetmanifest.Parser,
+	releaseSetValidator birelsetmanifest.Validator,
+	installationParser biinstallmanifest.Parser,
+	releaseExtractor birel.Extractor,
+	releaseManager birel.Manager,
+	tarballProvider bitarball.Provider,
+	tarballCache bitarball.Cache,
+	blobstoreFactory biblobstore.Factory,
+	compiledPackageRepoProvider func(deploymentManifestPath string) biconfig.CompiledPackageRepo,
+	logger boshlog.Logger,
+) Cmd {
+	return &exportReleaseCmd{
+		ui:                          ui,
+		fs:                          fs,
+		compressor:                  compressor,
+		releaseSetParser:            releaseSetParser,
+		releaseSetValidator:         releaseSetValidator,
+		installationParser:          installationParser,
+		releaseExtractor:            releaseExtractor,
+		releaseManager:              releaseManager,
+		tarballProvider:             tarballProvider,
+		tarballCache:                tarballCache,
+		blobstoreFactory:            blobstoreFactory,
+		compiledPackageRepoProvider: compiledPackageRepoProvider,
+		logger:                      logger,
+		logTag:                      "exportReleaseCmd",
+	}
+}
+
+func (c *exportReleaseCmd) Name() string {
+	return "export-release"
+}
+
+func (c *exportReleaseCmd) Meta() Meta {
+	return Meta{
+		Synopsis: "Export the packages compiled on the deployed VM as a compiled release",
+		Usage:    "[--output <release_path>] <release_name>/<release_version> <stemcell_os>/<stemcell_version> <deployment_manifest_path>",
+		Env:      genericEnv,
+	}
+}
+
+func (c *exportReleaseCmd) Run(stage biui.Stage, args []string) error {
+	positionalArgs, _, valueFlags, err := parseFlags(args, []string{}, []string{"output"})
+	if err != nil {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return err
+	}
+	if len(positionalArgs) != 3 {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return errors.New("Invalid usage - export-release command requires exactly 3 arguments")
+	}
+
+	releaseName, releaseVersion, err := splitNameAndVersion(positionalArgs[0])
+	if err != nil {
+		return bosherr.WrapError(err, "Parsing release")
+	}
+
+	stemcellOS, stemcellVersion, err := splitNameAndVersion(positionalArgs[1])
+	if err != nil {
+		return bosherr.WrapError(err, "Parsing stemcell")
+	}
+	stemcell := fmt.Sprintf("%s/%s", stemcellOS, stemcellVersion)
+
+	manifestAbsFilePath, err := filepath.Abs(positionalArgs[2])
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Getting absolute path to deployment file '%s'", positionalArgs[2])
+	}
+
+	if !c.fs.FileExists(manifestAbsFilePath) {
+		c.ui.ErrorLinef("Deployment '%s' does not exist", manifestAbsFilePath)
+		return bosherr.Errorf("Deployment manifest does not exist at '%s'", manifestAbsFilePath)
+	}
+
+	releasePath, given := valueFlags["output"]
+	if !given {
+		releasePath = fmt.Sprintf("%s-%s-%s-%s.tgz", releaseName, releaseVersion, stemcellOS, stemcellVersion)
+	}
+	releaseAbsFilePath, err := filepath.Abs(releasePath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Getting absolute path to release '%s'", releasePath)
+	}
+
+	c.ui.PrintLinef("Deployment manifest: '%s'", manifestAbsFilePath)
+
+	defer func() {
+		err := c.releaseManager.DeleteAll()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Deleting all extracted releases: %s", err.Error())
+		}
+	}()
+
+	var (
+		release              birel.Release
+		installationManifest biinstallmanifest.Manifest
+	)
+	err = stage.PerformComplex("validating", func(validationStage biui.Stage) error {
+		release, installationManifest, err = c.validate(validationStage, manifestAbsFilePath, releaseName, releaseVersion)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	compiledPackages, err := c.findCompiledPackages(manifestAbsFilePath, release, stemcell)
+	if err != nil {
+		return err
+	}
+
+	blobstore, err := c.blobstoreFactory.Create(installationManifest.BlobstoreURL())
+	if err != nil {
+		return bosherr.WrapError(err, "Creating blobstore client")
+	}
+
+	err = stage.PerformComplex("exporting release", func(exportStage biui.Stage) error {
+		return c.export(exportStage, release, stemcell, compiledPackages, blobstore, releaseAbsFilePath)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.ui.PrintLinef("Exported release: '%s'", releaseAbsFilePath)
+	return nil
+}
+
+func (c *exportReleaseCmd) validate(validationStage biui.Stage, deploymentManifestPath string, releaseName string, releaseVersion string) (birel.Release, biinstallmanifest.Manifest, error) {
+	var (
+		releaseRef           birelmanifest.ReleaseRef
+		installationManifest biinstallmanifest.Manifest
+	)
+	err := validationStage.Perform("Validating deployment manifest", func() error {
+		releaseSetManifest, err := c.releaseSetParser.Parse(deploymentManifestPath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Parsing release set manifest '%s'", deploymentManifestPath)
+		}
+
+		err = c.releaseSetValidator.Validate(releaseSetManifest)
+		if err != nil {
+			return bosherr.WrapError(err, "Validating release set manifest")
+		}
+
+		var found bool
+		releaseRef, found = releaseSetManifest.FindByName(releaseName)
+		if !found {
+			return bosherr.Errorf("Release '%s' is not in the deployment manifest", releaseName)
+		}
+
+		installationManifest, err = c.installationParser.Parse(deploymentManifestPath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Parsing installation manifest '%s'", deploymentManifestPath)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, installationManifest, err
+	}
+
+	releaseTarballPath, err := c.tarballProvider.Get(releaseRef, validationStage)
+	if err != nil {
+		return nil, installationManifest, err
+	}
+
+	var release birel.Release
+	err = validationStage.Perform(fmt.Sprintf("Validating release '%s'", releaseName), func() error {
+		if !c.fs.FileExists(releaseTarballPath) {
+			return bosherr.Errorf("File path '%s' does not exist", releaseTarballPath)
+		}
+
+		release, err = c.releaseExtractor.Extract(releaseTarballPath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Extracting release '%s'", releaseTarballPath)
+		}
+		c.releaseManager.Add(release)
+
+		if release.Name() != releaseName {
+			return bosherr.Errorf("Release name '%s' does not match the name in release tarball '%s'", releaseName, release.Name())
+		}
+
+		if release.Version() != releaseVersion {
+			return bosherr.Errorf("Release '%s' of the deployment has version '%s', not '%s'", releaseName, release.Version(), releaseVersion)
+		}
+
+		if release.IsCompiled() {
+			return bosherr.Errorf("Release '%s/%s' is already compiled", releaseName, releaseVersion)
+		}
+
+		return nil
+	})
+
+	return release, installationManifest, err
+}
+
+// findCompiledPackages returns the records of the packages of the release compiled against the stemcell on the deployed VM
+func (c *exportReleaseCmd) findCompiledPackages(deploymentManifestPath string, release birel.Release, stemcell string) (map[string]biconfig.CompiledPackageRecord, error) {
+	records, err := c.compiledPackageRepoProvider(deploymentManifestPath).List()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Finding compiled packages of the deployment")
+	}
+
+	compiledPackages := map[string]biconfig.CompiledPackageRecord{}
+	errs := []error{}
+	for _, pkg := range release.Packages() {
+		found := false
+		for _, record := range records {
+			if record.Name == pkg.Name && record.Fingerprint == pkg.Fingerprint && record.Stemcell == stemcell {
+				compiledPackages[pkg.Name] = record
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, bosherr.Errorf("Package '%s/%s' has not been compiled against stemcell '%s' on the deployed VM", pkg.Name, pkg.Fingerprint, stemcell))
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, bosherr.WrapErrorf(bosherr.NewMultiError(errs...), "Exporting release '%s/%s'", release.Name(), release.Version())
+	}
+
+	return compiledPackages, nil
+}
+
+// export writes a compiled release with the jobs of the release and the compiled packages from the local cache or the blobstore
+func (c *exportReleaseCmd) export(
+	stage biui.Stage,
+	release birel.Release,
+	stemcell string,
+	compiledPackages map[string]biconfig.CompiledPackageRecord,
+	blobstore biblobstore.Blobstore,
+	releasePath string,
+) error {
+	releaseDir, err := c.fs.TempDir("bosh-init-export-release")
+	if err != nil {
+		return bosherr.WrapError(err, "Creating release directory")
+	}
+	defer func() {
+		err := c.fs.RemoveAll(releaseDir)
+		if err != nil {
+			c.logger.Warn(c.logTag, "Failed to delete release directory '%s': %s", releaseDir, err.Error())
+		}
+	}()
+
+	for _, dir := range []string{"jobs", "compiled_packages"} {
+		err = c.fs.MkdirAll(filepath.Join(releaseDir, dir), os.ModePerm)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Creating release directory '%s'", dir)
+		}
+	}
+
+	releaseManifest := birelmanifest.Manifest{
+		Name:             release.Name(),
+		Version:          release.Version(),
+		Jobs:             []birelmanifest.JobRef{},
+		Packages:         []birelmanifest.PackageRef{},
+		CompiledPackages: []birelmanifest.CompiledPackageRef{},
+	}
+
+	for _, pkg := range release.Packages() {
+		record := compiledPackages[pkg.Name]
+		packagePath := filepath.Join(releaseDir, "compiled_packages", pkg.Name+".tgz")
+		err = c.exportCompiledPackage(stage, pkg, record, blobstore, packagePath)
+		if err != nil {
+			return err
+		}
+
+		dependencies := []string{}
+		for _, dependency := range pkg.Dependencies {
+			dependencies = append(dependencies, dependency.Name)
+		}
+
+		releaseManifest.CompiledPackages = append(releaseManifest.CompiledPackages, birelmanifest.CompiledPackageRef{
+			Name:         pkg.Name,
+			Version:      pkg.Fingerprint,
+			Fingerprint:  pkg.Fingerprint,
+			SHA1:         record.SHA1,
+			Stemcell:     stemcell,
+			Dependencies: dependencies,
+		})
+	}
+
+	for _, job := range release.Jobs() {
+		err = c.fs.CopyFile(job.ArchivePath, filepath.Join(releaseDir, "jobs", job.Name+".tgz"))
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Copying job '%s' to release directory", job.Name)
+		}
+
+		releaseManifest.Jobs = append(releaseManifest.Jobs, birelmanifest.JobRef{
+			Name:        job.Name,
+			Version:     job.Fingerprint,
+			Fingerprint: job.Fingerprint,
+			SHA1:        job.SHA1,
+		})
+	}
+
+	return stage.Perform(fmt.Sprintf("Creating release '%s'", releasePath), func() error {
+		releaseManifestContents, err := yaml.Marshal(releaseManifest)
+		if err != nil {
+			return bosherr.WrapError(err, "Marshalling release manifest")
+		}
+
+		err = c.fs.WriteFile(filepath.Join(releaseDir, "release.MF"), releaseManifestContents)
+		if err != nil {
+			return bosherr.WrapError(err, "Writing release manifest")
+		}
+
+		archivePath, err := c.compressor.CompressFilesInDir(releaseDir)
+		if err != nil {
+			return bosherr.WrapError(err, "Compressing release")
+		}
+		defer func() {
+			err := c.compressor.CleanUp(archivePath)
+			if err != nil {
+				c.logger.Warn(c.logTag, "Failed to clean up compressed release '%s': %s", archivePath, err.Error())
+			}
+		}()
+
+		err = c.fs.CopyFile(archivePath, releasePath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Copying release to '%s'", releasePath)
+		}
+
+		return nil
+	})
+}
+
+// exportCompiledPackage copies a compiled package to packagePath from the local cache, downloading it from the blobstore
+// and caching it when it has not been exported before
+func (c *exportReleaseCmd) exportCompiledPackage(
+	stage biui.Stage,
+	pkg *birelpkg.Package,
+	record biconfig.CompiledPackageRecord,
+	blobstore biblobstore.Blobstore,
+	packagePath string,
+) error {
+	source := compiledPackageSource{record: record}
+
+	if cachedPath, found := c.tarballCache.Get(source); found {
+		return stage.Perform(fmt.Sprintf("Using cached compiled package '%s/%s'", pkg.Name, pkg.Fingerprint), func() error {
+			err := c.fs.CopyFile(cachedPath, packagePath)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Copying compiled package '%s' to release directory", pkg.Name)
+			}
+
+			return nil
+		})
+	}
+
+	return stage.Perform(fmt.Sprintf("Downloading compiled package '%s/%s'", pkg.Name, pkg.Fingerprint), func() error {
+		localBlob, err := blobstore.Get(record.BlobID, record.SHA1)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Downloading compiled package blob '%s'", record.BlobID)
+		}
+		defer localBlob.DeleteSilently()
+
+		err = c.fs.CopyFile(localBlob.Path(), packagePath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Copying compiled package '%s' to release directory", pkg.Name)
+		}
+
+		err = c.tarballCache.Save(localBlob.Path(), source)
+		if err != nil {
+			c.logger.Warn(c.logTag, "Failed to cache compiled package '%s/%s': %s", pkg.Name, pkg.Fingerprint, err.Error())
+		}
+
+		return nil
+	})
+}
+
+// compiledPackageSource caches a compiled package under its blob in the blobstore of the VM it was compiled on
+type compiledPackageSource struct {
+	record biconfig.CompiledPackageRecord
+}
+
+func (s compiledPackageSource) GetURL() string {
+	return fmt.Sprintf("compiled-package://%s/%s/%s/%s", s.record.VMCID, s.record.Stemcell, s.record.Name, s.record.BlobID)
+}
+
+func (s compiledPackageSource) GetSHA1() string {
+	return s.record.SHA1
+}
+
+func (s compiledPackageSource) Description() string {
+	return fmt.Sprintf("compiled package '%s/%s'", s.record.Name, s.record.Fingerprint)
+}
+
+func splitNameAndVersion(nameAndVersion string) (string, string, error) {
+	parts := strings.Split(nameAndVersion, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", bosherr.Errorf("'%s' must be of the form '<name>/<version>'", nameAndVersion)
+	}
+	return parts[0], parts[1], nil
+}
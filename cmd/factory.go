@@ -1,7 +1,11 @@
 package cmd
 
 import (
+	"os"
+	"os/signal"
 	"path/filepath"
+	"sync"
+	"syscall"
 	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
@@ -17,8 +21,8 @@ import (
 	bicrypto "github.com/cloudfoundry/bosh-init/crypto"
 	bidepl "github.com/cloudfoundry/bosh-init/deployment"
 	bihttpagent "github.com/cloudfoundry/bosh-init/deployment/agentclient/http"
+	binats "github.com/cloudfoundry/bosh-init/deployment/agentclient/nats"
 	bidisk "github.com/cloudfoundry/bosh-init/deployment/disk"
-	bihttpclient "github.com/cloudfoundry/bosh-init/deployment/httpclient"
 	biinstance "github.com/cloudfoundry/bosh-init/deployment/instance"
 	biinstancestate "github.com/cloudfoundry/bosh-init/deployment/instance/state"
 	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
@@ -37,6 +41,7 @@ import (
 	bitemplate "github.com/cloudfoundry/bosh-init/templatescompiler"
 	bitemplateerb "github.com/cloudfoundry/bosh-init/templatescompiler/erbrenderer"
 	biui "github.com/cloudfoundry/bosh-init/ui"
+	biuifmt "github.com/cloudfoundry/bosh-init/ui/fmt"
 )
 
 type Factory interface {
@@ -51,10 +56,14 @@ type factory struct {
 	logger                 boshlog.Logger
 	uuidGenerator          boshuuid.Generator
 	workspaceRootPath      string
+	tarballCacheMaxSize    int64
+	contentAddressedBlobs  bool
+	blobstoreGetAttempts   int
+	blobstoreGetDelay      time.Duration
 	runner                 boshsys.CmdRunner
 	compressor             boshcmd.Compressor
 	agentClientFactory     bihttpagent.AgentClientFactory
-	registryServerManager  biregistry.ServerManager
+	natsServer             binats.Server
 	sshTunnelFactory       bisshtunnel.Factory
 	instanceFactory        biinstance.Factory
 	instanceManagerFactory biinstance.ManagerFactory
@@ -73,7 +82,12 @@ type factory struct {
 	cloudFactory           bicloud.Factory
 	stateBuilderFactory    biinstancestate.BuilderFactory
 	compiledPackageRepo    bistatepkg.CompiledPackageRepo
+	tarballCache           bitarball.Cache
 	tarballProvider        bitarball.Provider
+	tarballBundler         bitarball.Bundler
+	jobRenderer            bitemplate.JobRenderer
+
+	agentTaskProgressLock sync.Mutex
 }
 
 func NewFactory(
@@ -83,20 +97,34 @@ func NewFactory(
 	logger boshlog.Logger,
 	uuidGenerator boshuuid.Generator,
 	workspaceRootPath string,
+	tarballCacheMaxSize int64,
+	contentAddressedBlobs bool,
+	blobstoreGetAttempts int,
+	blobstoreGetDelay time.Duration,
 ) Factory {
 	f := &factory{
-		fs:                fs,
-		ui:                ui,
-		timeService:       timeService,
-		logger:            logger,
-		uuidGenerator:     uuidGenerator,
-		workspaceRootPath: workspaceRootPath,
+		fs:                    fs,
+		ui:                    ui,
+		timeService:           timeService,
+		logger:                logger,
+		uuidGenerator:         uuidGenerator,
+		workspaceRootPath:     workspaceRootPath,
+		tarballCacheMaxSize:   tarballCacheMaxSize,
+		contentAddressedBlobs: contentAddressedBlobs,
+		blobstoreGetAttempts:  blobstoreGetAttempts,
+		blobstoreGetDelay:     blobstoreGetDelay,
 	}
 	f.commands = CommandList{
-		"deploy":  f.createDeployCmd,
-		"delete":  f.createDeleteCmd,
-		"help":    f.createHelpCmd,
-		"version": f.createVersionCmd,
+		"deploy":         f.createDeployCmd,
+		"delete":         f.createDeleteCmd,
+		"run-errand":     f.createRunErrandCmd,
+		"cache":          f.createCacheCmd,
+		"bundle":         f.createBundleCmd,
+		"registry":       f.createRegistryCmd,
+		"render":         f.createRenderCmd,
+		"export-release": f.createExportReleaseCmd,
+		"help":           f.createHelpCmd,
+		"version":        f.createVersionCmd,
 	}
 	return f
 }
@@ -116,11 +144,11 @@ func (f *factory) CreateCommand(name string) (Cmd, error) {
 }
 
 func (f *factory) createDeployCmd() (Cmd, error) {
-	getter := func(deploymentManifestPath string) DeploymentPreparer {
-		f := &deploymentManagerFactory2{f: f, deploymentManifestPath: deploymentManifestPath}
+	getter := func(deploymentManifestPath string, offline bool) DeploymentPreparer {
+		f := &deploymentManagerFactory2{f: f, deploymentManifestPath: deploymentManifestPath, offline: offline}
 		return f.loadDeploymentPreparer()
 	}
-	return NewDeployCmd(f.ui, f.fs, f.logger, getter), nil
+	return NewDeployCmd(f.ui, f.fs, f.logger, getter, f.loadTarballBundler()), nil
 }
 
 func (f *factory) createDeleteCmd() (Cmd, error) {
@@ -132,6 +160,85 @@ func (f *factory) createDeleteCmd() (Cmd, error) {
 	return NewDeleteCmd(f.ui, f.fs, f.logger, getter), nil
 }
 
+func (f *factory) createRunErrandCmd() (Cmd, error) {
+	getter := func(deploymentManifestPath string, errandName string) ErrandRunner {
+		f := &deploymentManagerFactory2{f: f, deploymentManifestPath: deploymentManifestPath}
+		return f.loadErrandRunner(errandName)
+	}
+
+	return NewRunErrandCmd(f.ui, f.fs, f.logger, getter), nil
+}
+
+func (f *factory) createCacheCmd() (Cmd, error) {
+	return NewCacheCmd(f.ui, f.loadTarballCache(), f.tarballCacheMaxSize, f.logger), nil
+}
+
+func (f *factory) createBundleCmd() (Cmd, error) {
+	return NewBundleCmd(
+		f.ui,
+		f.fs,
+		f.loadReleaseSetParser(),
+		f.loadReleaseSetValidator(),
+		f.loadDeploymentParser(),
+		f.loadTarballBundler(),
+		f.logger,
+	), nil
+}
+
+func (f *factory) createRegistryCmd() (Cmd, error) {
+	getter := func(deploymentManifestPath string) biregistry.PersistentRegistry {
+		f := &deploymentManagerFactory2{f: f, deploymentManifestPath: deploymentManifestPath}
+		return f.loadPersistentRegistry()
+	}
+	interrupts := func() <-chan os.Signal {
+		signalCh := make(chan os.Signal, 1)
+		signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
+		return signalCh
+	}
+
+	return NewRegistryCmd(f.ui, f.fs, f.loadInstallationParser(), getter, f.loadSSHTunnelFactory(), interrupts, f.logger), nil
+}
+
+func (f *factory) createRenderCmd() (Cmd, error) {
+	return NewRenderCmd(
+		f.ui,
+		f.fs,
+		f.loadReleaseSetParser(),
+		f.loadReleaseSetValidator(),
+		f.loadDeploymentParser(),
+		f.loadDeploymentValidator(),
+		f.loadReleaseExtractor(),
+		f.loadReleaseManager(),
+		f.loadReleaseJobResolver(),
+		f.loadJobRenderer(),
+		f.loadTarballProvider(),
+		f.logger,
+	), nil
+}
+
+func (f *factory) createExportReleaseCmd() (Cmd, error) {
+	compiledPackageRepoProvider := func(deploymentManifestPath string) biconfig.CompiledPackageRepo {
+		f := &deploymentManagerFactory2{f: f, deploymentManifestPath: deploymentManifestPath}
+		return biconfig.NewCompiledPackageRepo(f.loadDeploymentStateService())
+	}
+
+	return NewExportReleaseCmd(
+		f.ui,
+		f.fs,
+		f.loadCompressor(),
+		f.loadReleaseSetParser(),
+		f.loadReleaseSetValidator(),
+		f.loadInstallationParser(),
+		f.loadReleaseExtractor(),
+		f.loadReleaseManager(),
+		f.loadTarballProvider(),
+		f.loadTarballCache(),
+		f.loadBlobstoreFactory(),
+		compiledPackageRepoProvider,
+		f.logger,
+	), nil
+}
+
 func (f *factory) createHelpCmd() (Cmd, error) {
 	return NewHelpCmd(f.ui, f.commands), nil
 }
@@ -166,13 +273,13 @@ func (f *factory) loadCompiledPackageRepo() bistatepkg.CompiledPackageRepo {
 	return f.compiledPackageRepo
 }
 
-func (f *factory) loadRegistryServerManager() biregistry.ServerManager {
-	if f.registryServerManager != nil {
-		return f.registryServerManager
+func (f *factory) loadNATSServer() binats.Server {
+	if f.natsServer != nil {
+		return f.natsServer
 	}
 
-	f.registryServerManager = biregistry.NewServerManager(f.logger)
-	return f.registryServerManager
+	f.natsServer = binats.NewServer(f.loadSSHTunnelFactory(), f.logger)
+	return f.natsServer
 }
 
 func (f *factory) loadSSHTunnelFactory() bisshtunnel.Factory {
@@ -222,9 +329,7 @@ func (f *factory) loadBuilderFactory() biinstancestate.BuilderFactory {
 		return f.stateBuilderFactory
 	}
 
-	erbRenderer := bitemplateerb.NewERBRenderer(f.fs, f.loadCMDRunner(), f.logger)
-	jobRenderer := bitemplate.NewJobRenderer(erbRenderer, f.fs, f.logger)
-	jobListRenderer := bitemplate.NewJobListRenderer(jobRenderer, f.logger)
+	jobListRenderer := bitemplate.NewJobListRenderer(f.loadJobRenderer(), f.logger)
 
 	sha1Calculator := bicrypto.NewSha1Calculator(f.fs)
 
@@ -245,6 +350,16 @@ func (f *factory) loadBuilderFactory() biinstancestate.BuilderFactory {
 	return f.stateBuilderFactory
 }
 
+func (f *factory) loadJobRenderer() bitemplate.JobRenderer {
+	if f.jobRenderer != nil {
+		return f.jobRenderer
+	}
+
+	erbRenderer := bitemplateerb.NewERBRenderer(f.fs, f.loadCMDRunner(), f.logger)
+	f.jobRenderer = bitemplate.NewJobRenderer(erbRenderer, f.fs, f.logger)
+	return f.jobRenderer
+}
+
 func (f *factory) loadDeploymentFactory() bidepl.Factory {
 	if f.deploymentFactory != nil {
 		return f.deploymentFactory
@@ -265,16 +380,25 @@ func (f *factory) loadAgentClientFactory() bihttpagent.AgentClientFactory {
 		return f.agentClientFactory
 	}
 
-	f.agentClientFactory = bihttpagent.NewAgentClientFactory(1*time.Second, f.logger)
+	f.agentClientFactory = bihttpagent.NewAgentClientFactory(1*time.Second, f.loadNATSServer(), f.timeService, f.printAgentTaskProgress, f.logger)
 	return f.agentClientFactory
 }
 
+// printAgentTaskProgress appends the elapsed time of a long-running agent task to the line of the step waiting for it.
+// Packages compile concurrently, so several tasks may report at once.
+func (f *factory) printAgentTaskProgress(action string, elapsed time.Duration) {
+	f.agentTaskProgressLock.Lock()
+	defer f.agentTaskProgressLock.Unlock()
+
+	f.ui.BeginLinef(" still running (%s)...", biuifmt.Duration(elapsed))
+}
+
 func (f *factory) loadBlobstoreFactory() biblobstore.Factory {
 	if f.blobstoreFactory != nil {
 		return f.blobstoreFactory
 	}
 
-	f.blobstoreFactory = biblobstore.NewBlobstoreFactory(f.uuidGenerator, f.fs, f.logger)
+	f.blobstoreFactory = biblobstore.NewBlobstoreFactory(f.uuidGenerator, f.fs, f.blobstoreGetAttempts, f.blobstoreGetDelay, f.timeService, f.logger)
 	return f.blobstoreFactory
 }
 
@@ -284,23 +408,44 @@ func (f *factory) loadReleaseExtractor() birel.Extractor {
 	}
 
 	releaseValidator := birel.NewValidator(f.fs)
-	f.releaseExtractor = birel.NewExtractor(f.fs, f.loadCompressor(), releaseValidator, f.logger)
+	f.releaseExtractor = birel.NewExtractor(f.fs, f.loadCompressor(), bicrypto.NewDigestCalculator(f.fs), releaseValidator, f.logger)
 	return f.releaseExtractor
 }
 
+func (f *factory) loadTarballCache() bitarball.Cache {
+	if f.tarballCache != nil {
+		return f.tarballCache
+	}
+
+	tarballCacheBasePath := filepath.Join(f.workspaceRootPath, "downloads")
+	f.tarballCache = bitarball.NewCache(tarballCacheBasePath, f.tarballCacheMaxSize, f.fs, f.timeService, f.logger)
+	return f.tarballCache
+}
+
 func (f *factory) loadTarballProvider() bitarball.Provider {
 	if f.tarballProvider != nil {
 		return f.tarballProvider
 	}
 
-	tarballCacheBasePath := filepath.Join(f.workspaceRootPath, "downloads")
-	tarballCache := bitarball.NewCache(tarballCacheBasePath, f.fs, f.logger)
-	httpClient := bihttpclient.NewHTTPClient(bitarball.HTTPClient, f.logger)
-	sha1Calculator := bicrypto.NewSha1Calculator(f.fs)
-	f.tarballProvider = bitarball.NewProvider(tarballCache, f.fs, httpClient, sha1Calculator, 3, 500*time.Millisecond, f.logger)
+	configPath := filepath.Join(f.workspaceRootPath, "config.yml")
+	httpClient := bitarball.NewDownloadHTTPClient(configPath, "~/.netrc", f.fs, f.logger)
+	digestCalculator := bicrypto.NewDigestCalculator(f.fs)
+	f.tarballProvider = bitarball.NewProvider(f.loadTarballCache(), f.fs, httpClient, digestCalculator, 3, 500*time.Millisecond, f.logger)
 	return f.tarballProvider
 }
 
+func (f *factory) loadTarballBundler() bitarball.Bundler {
+	if f.tarballBundler != nil {
+		return f.tarballBundler
+	}
+
+	// bundles are extracted next to the tarball cache, for their tarballs to be moved into it
+	bundleWorkPath := filepath.Join(f.workspaceRootPath, "bundle")
+	digestCalculator := bicrypto.NewDigestCalculator(f.fs)
+	f.tarballBundler = bitarball.NewBundler(f.loadTarballProvider(), f.loadTarballCache(), bundleWorkPath, f.fs, f.loadCompressor(), digestCalculator, f.logger)
+	return f.tarballBundler
+}
+
 func (f *factory) loadReleaseManager() birel.Manager {
 	if f.releaseManager != nil {
 		return f.releaseManager
@@ -377,6 +522,7 @@ func (f *factory) loadCloudFactory() bicloud.Factory {
 type deploymentManagerFactory2 struct {
 	f                             *factory
 	deploymentManifestPath        string
+	offline                       bool
 	deploymentStateService        biconfig.DeploymentStateService
 	legacyDeploymentStateMigrator biconfig.LegacyDeploymentStateMigrator
 	vmRepo                        biconfig.VMRepo
@@ -389,6 +535,8 @@ type deploymentManagerFactory2 struct {
 	stemcellManagerFactory        bistemcell.ManagerFactory
 	installerFactory              biinstall.InstallerFactory
 	deployer                      bidepl.Deployer
+	persistentRegistry            biregistry.PersistentRegistry
+	registryServerManager         biregistry.ServerManager
 }
 
 func (d *deploymentManagerFactory2) loadDeploymentPreparer() DeploymentPreparer {
@@ -424,7 +572,11 @@ func (d *deploymentManagerFactory2) loadDeploymentPreparer() DeploymentPreparer
 		d.f.loadReleaseExtractor(),
 		stemcellExtractor,
 		d.deploymentManifestPath,
-		d.f.loadTarballProvider(),
+		d.loadTarballProvider(),
+		d.f.loadTarballCache(),
+		d.f.loadCompiledPackageRepo(),
+		biconfig.NewCompiledPackageRepo(d.loadDeploymentStateService()),
+		d.f.contentAddressedBlobs,
 	)
 }
 
@@ -446,11 +598,73 @@ func (d *deploymentManagerFactory2) loadDeploymentDeleter() DeploymentDeleter {
 		d.f.loadReleaseExtractor(),
 		d.f.loadInstallationParser(),
 		d.f.loadInstallationValidator(),
+		d.f.loadDeploymentParser(),
+		func(errandName string) (biconfig.DeploymentStateService, bidepl.ManagerFactory) {
+			errandFactory := d.errandFactory(errandName)
+			return errandFactory.loadDeploymentStateService(), errandFactory.loadDeploymentManagerFactory()
+		},
+		d.deploymentManifestPath,
+		d.f.loadTarballProvider(),
+		d.f.loadTarballCache(),
+	)
+}
+
+// errandFactory loads the dependencies of the VM of a dedicated errand, which is recorded in its own state file
+// so that it does not replace the deployed VM
+func (d *deploymentManagerFactory2) errandFactory(errandName string) *deploymentManagerFactory2 {
+	return &deploymentManagerFactory2{
+		f:                      d.f,
+		deploymentManifestPath: d.deploymentManifestPath,
+		deploymentStateService: biconfig.NewFileSystemDeploymentStateService(
+			d.f.fs,
+			d.f.uuidGenerator,
+			d.f.logger,
+			biconfig.ErrandStatePath(d.deploymentManifestPath, errandName),
+		),
+	}
+}
+
+func (d *deploymentManagerFactory2) loadErrandRunner(errandName string) ErrandRunner {
+	errandFactory := d.errandFactory(errandName)
+
+	return NewErrandRunner(
+		d.f.ui,
+		d.f.fs,
+		d.f.logger,
+		"ErrandRunner",
+		d.f.timeService,
+		d.loadDeploymentStateService(),
+		errandFactory.loadDeploymentStateService(),
+		d.f.loadReleaseManager(),
+		d.loadInstallerFactory(),
+		d.f.loadCloudFactory(),
+		d.loadStemcellManagerFactory(),
+		d.f.loadAgentClientFactory(),
+		d.loadVMManagerFactory(),
+		errandFactory.loadVMManagerFactory(),
+		d.f.loadInstanceManagerFactory(),
+		d.f.loadBlobstoreFactory(),
+		d.f.loadReleaseSetParser(),
+		d.f.loadInstallationParser(),
+		d.f.loadDeploymentParser(),
+		d.f.loadReleaseSetValidator(),
+		d.f.loadInstallationValidator(),
+		d.f.loadDeploymentValidator(),
+		d.f.loadReleaseExtractor(),
 		d.deploymentManifestPath,
 		d.f.loadTarballProvider(),
+		d.f.contentAddressedBlobs,
 	)
 }
 
+// loadTarballProvider returns a provider that refuses to download when deploying offline
+func (d *deploymentManagerFactory2) loadTarballProvider() bitarball.Provider {
+	if d.offline {
+		return bitarball.NewOfflineProvider(d.f.loadTarballCache(), d.f.fs, d.f.logger)
+	}
+	return d.f.loadTarballProvider()
+}
+
 func (d *deploymentManagerFactory2) loadDeploymentStateService() biconfig.DeploymentStateService {
 	if d.deploymentStateService != nil {
 		return d.deploymentStateService
@@ -465,6 +679,24 @@ func (d *deploymentManagerFactory2) loadDeploymentStateService() biconfig.Deploy
 	return d.deploymentStateService
 }
 
+func (d *deploymentManagerFactory2) loadPersistentRegistry() biregistry.PersistentRegistry {
+	if d.persistentRegistry != nil {
+		return d.persistentRegistry
+	}
+
+	d.persistentRegistry = biregistry.NewPersistentRegistry(d.loadDeploymentStateService(), d.f.logger)
+	return d.persistentRegistry
+}
+
+func (d *deploymentManagerFactory2) loadRegistryServerManager() biregistry.ServerManager {
+	if d.registryServerManager != nil {
+		return d.registryServerManager
+	}
+
+	d.registryServerManager = biregistry.NewServerManager(d.loadPersistentRegistry(), d.f.logger)
+	return d.registryServerManager
+}
+
 func (d *deploymentManagerFactory2) loadLegacyDeploymentStateMigrator() biconfig.LegacyDeploymentStateMigrator {
 	if d.legacyDeploymentStateMigrator != nil {
 		return d.legacyDeploymentStateMigrator
@@ -594,7 +826,8 @@ func (d *deploymentManagerFactory2) loadInstallerFactory() biinstall.InstallerFa
 		d.f.loadCompressor(),
 		d.f.loadReleaseJobResolver(),
 		d.f.uuidGenerator,
-		d.f.loadRegistryServerManager(),
+		d.loadRegistryServerManager(),
+		d.f.loadNATSServer(),
 		d.f.logger,
 	)
 	return d.installerFactory
@@ -40,6 +40,10 @@ var _ = Describe("cmd.Factory", func() {
 			logger,
 			uuidGenerator,
 			"/fake-path",
+			0,
+			false,
+			3,
+			0,
 		)
 	})
 
@@ -63,6 +67,54 @@ var _ = Describe("cmd.Factory", func() {
 				Expect(cmd.Name()).To(Equal("delete"))
 			})
 		})
+
+		Describe("run-errand command", func() {
+			It("returns run-errand command", func() {
+				cmd, err := factory.CreateCommand("run-errand")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cmd.Name()).To(Equal("run-errand"))
+			})
+		})
+
+		Describe("cache command", func() {
+			It("returns cache command", func() {
+				cmd, err := factory.CreateCommand("cache")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cmd.Name()).To(Equal("cache"))
+			})
+		})
+
+		Describe("bundle command", func() {
+			It("returns bundle command", func() {
+				cmd, err := factory.CreateCommand("bundle")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cmd.Name()).To(Equal("bundle"))
+			})
+		})
+
+		Describe("registry command", func() {
+			It("returns registry command", func() {
+				cmd, err := factory.CreateCommand("registry")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cmd.Name()).To(Equal("registry"))
+			})
+		})
+
+		Describe("render command", func() {
+			It("returns render command", func() {
+				cmd, err := factory.CreateCommand("render")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cmd.Name()).To(Equal("render"))
+			})
+		})
+
+		Describe("export-release command", func() {
+			It("returns export-release command", func() {
+				cmd, err := factory.CreateCommand("export-release")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cmd.Name()).To(Equal("export-release"))
+			})
+		})
 	})
 
 	Context("unknown command name", func() {
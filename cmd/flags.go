@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+)
+
+// parseBoolFlags separates the given boolean flags (e.g. "--skip-drain") from the positional arguments.
+// Flags may appear anywhere in the argument list. Unknown flags result in an invalid usage error.
+func parseBoolFlags(args []string, flagNames ...string) ([]string, map[string]bool, error) {
+	positionalArgs, boolFlags, _, err := parseFlags(args, flagNames, []string{})
+	return positionalArgs, boolFlags, err
+}
+
+// parseFlags is parseBoolFlags that also accepts flags with a value, given either as "--older-than 24h" or "--older-than=24h".
+// Value flags that are not given are absent from the returned map.
+func parseFlags(args []string, boolFlagNames []string, valueFlagNames []string) ([]string, map[string]bool, map[string]string, error) {
+	positionalArgs := []string{}
+	boolFlags := map[string]bool{}
+	for _, flagName := range boolFlagNames {
+		boolFlags[flagName] = false
+	}
+	valueFlags := map[string]string{}
+	knownValueFlags := map[string]bool{}
+	for _, flagName := range valueFlagNames {
+		knownValueFlags[flagName] = true
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			positionalArgs = append(positionalArgs, arg)
+			continue
+		}
+
+		flagName := strings.TrimPrefix(arg, "--")
+		if _, known := boolFlags[flagName]; known {
+			boolFlags[flagName] = true
+			continue
+		}
+
+		var value string
+		hasValue := false
+		if equalsIndex := strings.Index(flagName, "="); equalsIndex >= 0 {
+			value = flagName[equalsIndex+1:]
+			flagName = flagName[:equalsIndex]
+			hasValue = true
+		}
+
+		if !knownValueFlags[flagName] {
+			return positionalArgs, boolFlags, valueFlags, bosherr.Errorf("Invalid usage - unknown flag '%s'", arg)
+		}
+
+		if !hasValue {
+			if i+1 >= len(args) {
+				return positionalArgs, boolFlags, valueFlags, bosherr.Errorf("Invalid usage - flag '--%s' requires a value", flagName)
+			}
+			i++
+			value = args[i]
+		}
+		valueFlags[flagName] = value
+	}
+
+	return positionalArgs, boolFlags, valueFlags, nil
+}
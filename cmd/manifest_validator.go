@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshsys "github.com/cloudfoundry/bosh-agent/system"
+
+	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
+	bitarball "github.com/cloudfoundry/bosh-init/installation/tarball"
+	birel "github.com/cloudfoundry/bosh-init/release"
+	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
+	biui "github.com/cloudfoundry/bosh-init/ui"
+)
+
+// manifestValidator validates the release set and deployment of a deployment manifest, and the releases it refers to.
+// It is shared by the commands that deploy, run errands and render job templates.
+type manifestValidator struct {
+	fs                  boshsys.FileSystem
+	releaseSetParser    birelsetmanifest.Parser
+	releaseSetValidator birelsetmanifest.Validator
+	deploymentParser    bideplmanifest.Parser
+	deploymentValidator bideplmanifest.Validator
+	releaseExtractor    birel.Extractor
+	releaseManager      birel.Manager
+	tarballProvider     bitarball.Provider
+}
+
+func newManifestValidator(
+	fs boshsys.FileSystem,
+	releaseSetParser birelsetmanifest.Parser,
+	releaseSetValidator birelsetmanifest.Validator,
+	deploymentParser bideplmanifest.Parser,
+	deploymentValidator bideplmanifest.Validator,
+	releaseExtractor birel.Extractor,
+	releaseManager birel.Manager,
+	tarballProvider bitarball.Provider,
+) manifestValidator {
+	return manifestValidator{
+		fs:                  fs,
+		releaseSetParser:    releaseSetParser,
+		releaseSetValidator: releaseSetValidator,
+		deploymentParser:    deploymentParser,
+		deploymentValidator: deploymentValidator,
+		releaseExtractor:    releaseExtractor,
+		releaseManager:      releaseManager,
+		tarballProvider:     tarballProvider,
+	}
+}
+
+// ValidateManifests parses and validates the release set and deployment manifests
+func (v manifestValidator) ValidateManifests(deploymentManifestPath string) (birelsetmanifest.Manifest, bideplmanifest.Manifest, error) {
+	releaseSetManifest, err := v.releaseSetParser.Parse(deploymentManifestPath)
+	if err != nil {
+		return releaseSetManifest, bideplmanifest.Manifest{}, bosherr.WrapErrorf(err, "Parsing release set manifest '%s'", deploymentManifestPath)
+	}
+
+	err = v.releaseSetValidator.Validate(releaseSetManifest)
+	if err != nil {
+		return releaseSetManifest, bideplmanifest.Manifest{}, bosherr.WrapError(err, "Validating release set manifest")
+	}
+
+	deploymentManifest, err := v.deploymentParser.Parse(deploymentManifestPath)
+	if err != nil {
+		return releaseSetManifest, deploymentManifest, bosherr.WrapErrorf(err, "Parsing deployment manifest '%s'", deploymentManifestPath)
+	}
+
+	err = v.deploymentValidator.Validate(deploymentManifest, releaseSetManifest)
+	if err != nil {
+		return releaseSetManifest, deploymentManifest, bosherr.WrapError(err, "Validating deployment manifest")
+	}
+
+	return releaseSetManifest, deploymentManifest, nil
+}
+
+// ValidateReleases fetches and extracts the releases of the release set, adding them to the release manager
+func (v manifestValidator) ValidateReleases(validationStage biui.Stage, releaseSetManifest birelsetmanifest.Manifest) error {
+	for _, releaseRef := range releaseSetManifest.Releases {
+		releasePath, err := v.tarballProvider.Get(releaseRef, validationStage)
+		if err != nil {
+			return err
+		}
+
+		err = validationStage.Perform(fmt.Sprintf("Validating release '%s'", releaseRef.Name), func() error {
+			if !v.fs.FileExists(releasePath) {
+				return bosherr.Errorf("File path '%s' does not exist", releasePath)
+			}
+
+			release, err := v.releaseExtractor.Extract(releasePath)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Extracting release '%s'", releasePath)
+			}
+
+			if release.Name() != releaseRef.Name {
+				return bosherr.Errorf("Release name '%s' does not match the name in release tarball '%s'", releaseRef.Name, release.Name())
+			}
+			v.releaseManager.Add(release)
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateReleaseJobs checks that the jobs of the deployment refer to jobs of the extracted releases,
+// printing warnings about properties that the release jobs don't define
+func (v manifestValidator) ValidateReleaseJobs(validationStage biui.Stage, deploymentManifest bideplmanifest.Manifest) error {
+	var warnings []string
+	err := validationStage.Perform("Validating jobs", func() error {
+		var err error
+		warnings, err = v.deploymentValidator.ValidateReleaseJobs(deploymentManifest, v.releaseManager)
+		if err != nil {
+			return bosherr.WrapError(err, "Validating deployment jobs refer to jobs in release")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(warnings) > 0 {
+		validationStage.PrintOutput("Warnings", strings.Join(warnings, "\n"))
+	}
+
+	return nil
+}
+
+// ValidateCompiledReleases checks that the packages of compiled releases were compiled against the stemcell
+// the VM is created from, so they can be used without compiling them on the VM
+func (v manifestValidator) ValidateCompiledReleases(validationStage biui.Stage, stemcellOS string, stemcellVersion string) error {
+	if !v.hasCompiledReleases() {
+		return nil
+	}
+
+	return validationStage.Perform("Validating compiled releases", func() error {
+		stemcell := fmt.Sprintf("%s/%s", stemcellOS, stemcellVersion)
+
+		errs := []error{}
+		for _, release := range v.releaseManager.List() {
+			for _, pkg := range release.Packages() {
+				if pkg.IsCompiled() && pkg.Stemcell != stemcell {
+					errs = append(errs, bosherr.Errorf("Package '%s' of release '%s' is compiled against stemcell '%s', but the deployment uses stemcell '%s'", pkg.Name, release.Name(), pkg.Stemcell, stemcell))
+				}
+			}
+		}
+
+		if len(errs) > 0 {
+			return bosherr.NewMultiError(errs...)
+		}
+
+		return nil
+	})
+}
+
+func (v manifestValidator) hasCompiledReleases() bool {
+	for _, release := range v.releaseManager.List() {
+		if release.IsCompiled() {
+			return true
+		}
+	}
+	return false
+}
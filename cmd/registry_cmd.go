@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	boshsys "github.com/cloudfoundry/bosh-agent/system"
+
+	bisshtunnel "github.com/cloudfoundry/bosh-init/deployment/sshtunnel"
+	biinstallmanifest "github.com/cloudfoundry/bosh-init/installation/manifest"
+	biregistry "github.com/cloudfoundry/bosh-init/registry"
+	biui "github.com/cloudfoundry/bosh-init/ui"
+)
+
+const registrySSHTunnelDelay = 5 * time.Second
+
+type registryCmd struct {
+	ui                       biui.UI
+	fs                       boshsys.FileSystem
+	installationParser       biinstallmanifest.Parser
+	persistentRegistryGetter func(deploymentManifestPath string) biregistry.PersistentRegistry
+	sshTunnelFactory         bisshtunnel.Factory
+	interrupts               func() <-chan os.Signal
+	logger                   boshlog.Logger
+	logTag                   string
+}
+
+// NewRegistryCmd returns the command serving the persistent registry of a deployment after bosh-init exits.
+// interrupts is called once the registry is served, and it stops when the returned channel receives.
+func NewRegistryCmd(
+	ui biui.UI,
+	fs boshsys.FileSystem,
+	installationParser biinstallmanifest.Parser,
+	persistentRegistryGetter func(deploymentManifestPath string) biregistry.PersistentRegistry,
+	sshTunnelFactory bisshtunnel.Factory,
+	interrupts func() <-chan os.Signal,
+	logger boshlog.Logger,
+) Cmd {
+	return &registryCmd{
+		ui:                       ui,
+		fs:                       fs,
+		installationParser:       installationParser,
+		persistentRegistryGetter: persistentRegistryGetter,
+		sshTunnelFactory:         sshTunnelFactory,
+		interrupts:               interrupts,
+		logger:                   logger,
+		logTag:                   "registryCmd",
+	}
+}
+
+func (c *registryCmd) Name() string {
+	return "registry"
+}
+
+func (c *registryCmd) Meta() Meta {
+	return Meta{
+		Synopsis: "Serve the persistent registry of a deployment, e.g. for the agent to fetch its settings after the VM reboots",
+		Usage:    "serve [--forever] [--port <port>] <deployment_manifest_path>",
+		Env:      genericEnv,
+	}
+}
+
+func (c *registryCmd) Run(stage biui.Stage, args []string) error {
+	positionalArgs, boolFlags, valueFlags, err := parseFlags(args, []string{"forever"}, []string{"port"})
+	if err != nil {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return err
+	}
+	if len(positionalArgs) != 2 || positionalArgs[0] != "serve" {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return errors.New("Invalid usage - registry command requires 'serve' and exactly 1 argument")
+	}
+
+	manifestAbsFilePath, err := filepath.Abs(positionalArgs[1])
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Getting absolute path to deployment file '%s'", positionalArgs[1])
+	}
+
+	if !c.fs.FileExists(manifestAbsFilePath) {
+		c.ui.ErrorLinef("Deployment '%s' does not exist", manifestAbsFilePath)
+		return bosherr.Errorf("Deployment manifest does not exist at '%s'", manifestAbsFilePath)
+	}
+
+	installationManifest, err := c.installationParser.Parse(manifestAbsFilePath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Parsing installation manifest '%s'", manifestAbsFilePath)
+	}
+
+	registryConfig := installationManifest.Registry
+	if registryConfig.IsEmpty() {
+		return bosherr.Error("Deployment does not use a registry, it requires cloud_provider.ssh_tunnel")
+	}
+
+	if port, given := valueFlags["port"]; given {
+		registryConfig.Port, err = strconv.Atoi(port)
+		if err != nil || registryConfig.Port <= 0 {
+			return bosherr.Errorf("Invalid --port '%s'", port)
+		}
+	}
+
+	persistentRegistry := c.persistentRegistryGetter(manifestAbsFilePath)
+	username, password, found, err := persistentRegistry.Credentials()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading registry credentials")
+	}
+	if !found {
+		return bosherr.Error("Deployment state has no persistent registry, deploy with cloud_provider.registry.persistent set to true first")
+	}
+
+	fetchedCh := make(chan string, 1)
+	registry := &fetchNotifyingRegistry{PersistentRegistry: persistentRegistry, fetchedCh: fetchedCh}
+	serverManager := biregistry.NewServerManager(registry, c.logger)
+
+	var server biregistry.Server
+	err = stage.Perform("Starting registry", func() error {
+		server, err = serverManager.Start(username, password, registryConfig.Host, registryConfig.Port, true)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := server.Stop()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Failed to stop registry: %s", err.Error())
+		}
+	}()
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	if registryConfig.SSHTunnel != (biinstallmanifest.SSHTunnel{}) {
+		go c.keepSSHTunnelOpen(bisshtunnel.Options{
+			Host:              registryConfig.SSHTunnel.Host,
+			Port:              registryConfig.SSHTunnel.Port,
+			User:              registryConfig.SSHTunnel.User,
+			Password:          registryConfig.SSHTunnel.Password,
+			PrivateKey:        registryConfig.SSHTunnel.PrivateKey,
+			LocalForwardPort:  registryConfig.Port,
+			RemoteForwardPort: registryConfig.Port,
+		}, stopCh)
+	}
+
+	if boolFlags["forever"] {
+		c.ui.PrintLinef("Serving registry on %s:%d until interrupted", registryConfig.Host, registryConfig.Port)
+	} else {
+		c.ui.PrintLinef("Serving registry on %s:%d until the agent fetches its settings", registryConfig.Host, registryConfig.Port)
+	}
+
+	interrupts := c.interrupts()
+	for {
+		select {
+		case instanceID := <-fetchedCh:
+			c.ui.PrintLinef("Instance '%s' fetched its settings", instanceID)
+			if !boolFlags["forever"] {
+				return nil
+			}
+		case <-interrupts:
+			c.ui.PrintLinef("Stopping registry")
+			return nil
+		}
+	}
+}
+
+// keepSSHTunnelOpen forwards the registry port onto the VM, reconnecting whenever the tunnel closes (e.g. the VM reboots)
+func (c *registryCmd) keepSSHTunnelOpen(sshTunnelOptions bisshtunnel.Options, stopCh chan struct{}) {
+	for {
+		sshTunnel := c.sshTunnelFactory.NewSSHTunnel(sshTunnelOptions)
+
+		readyErrCh := make(chan error)
+		errCh := make(chan error)
+		go sshTunnel.Start(readyErrCh, errCh)
+
+		var err error
+		select {
+		case err = <-readyErrCh:
+			if err == nil {
+				c.logger.Debug(c.logTag, "SSH tunnel to %s:%d forwarding port %d", sshTunnelOptions.Host, sshTunnelOptions.Port, sshTunnelOptions.RemoteForwardPort)
+				select {
+				case err = <-errCh:
+				case <-stopCh:
+					_ = sshTunnel.Stop()
+					return
+				}
+			}
+		case <-stopCh:
+			_ = sshTunnel.Stop()
+			return
+		}
+
+		c.logger.Debug(c.logTag, "SSH tunnel closed, reconnecting: %s", err)
+		_ = sshTunnel.Stop()
+
+		select {
+		case <-time.After(registrySSHTunnelDelay):
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// fetchNotifyingRegistry sends the ID of the instances whose settings are fetched
+type fetchNotifyingRegistry struct {
+	biregistry.PersistentRegistry
+	fetchedCh chan string
+}
+
+func (r *fetchNotifyingRegistry) Get(instanceID string) ([]byte, bool, error) {
+	settings, found, err := r.PersistentRegistry.Get(instanceID)
+	if err == nil && found {
+		select {
+		case r.fetchedCh <- instanceID:
+		default:
+		}
+	}
+	return settings, found, err
+}
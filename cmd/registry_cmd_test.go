@@ -0,0 +1,191 @@
+package cmd_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	fakesys "github.com/cloudfoundry/bosh-agent/system/fakes"
+	fakeuuid "github.com/cloudfoundry/bosh-agent/uuid/fakes"
+
+	. "github.com/cloudfoundry/bosh-init/cmd"
+	biconfig "github.com/cloudfoundry/bosh-init/config"
+	bisshtunnel "github.com/cloudfoundry/bosh-init/deployment/sshtunnel"
+	fakebisshtunnel "github.com/cloudfoundry/bosh-init/deployment/sshtunnel/fakes"
+	biinstallmanifest "github.com/cloudfoundry/bosh-init/installation/manifest"
+	fakebiinstallmanifest "github.com/cloudfoundry/bosh-init/installation/manifest/fakes"
+	biregistry "github.com/cloudfoundry/bosh-init/registry"
+
+	fakebiui "github.com/cloudfoundry/bosh-init/ui/fakes"
+)
+
+var _ = Describe("RegistryCmd", func() {
+	var (
+		fs                     *fakesys.FakeFileSystem
+		fakeUI                 *fakebiui.FakeUI
+		fakeStage              *fakebiui.FakeStage
+		fakeInstallationParser *fakebiinstallmanifest.FakeParser
+		fakeSSHTunnelFactory   *fakebisshtunnel.FakeFactory
+		fakeSSHTunnel          *fakebisshtunnel.FakeTunnel
+		persistentRegistry     biregistry.PersistentRegistry
+		interruptCh            chan os.Signal
+
+		deploymentManifestPath = "/path/to/manifest.yml"
+
+		command Cmd
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		fakeUI = &fakebiui.FakeUI{}
+		fakeStage = fakebiui.NewFakeStage()
+		fakeInstallationParser = fakebiinstallmanifest.NewFakeParser()
+		fakeSSHTunnel = fakebisshtunnel.NewFakeTunnel()
+		fakeSSHTunnelFactory = fakebisshtunnel.NewFakeFactory()
+		fakeSSHTunnelFactory.SSHTunnel = fakeSSHTunnel
+		interruptCh = make(chan os.Signal, 1)
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+
+		fs.WriteFileString(deploymentManifestPath, "")
+		fakeInstallationParser.ParseManifest = biinstallmanifest.Manifest{
+			Registry: biinstallmanifest.Registry{
+				Username:   "fake-manifest-username",
+				Password:   "fake-manifest-password",
+				Host:       "127.0.0.1",
+				Port:       6903,
+				Persistent: true,
+				SSHTunnel: biinstallmanifest.SSHTunnel{
+					Host:       "fake-ssh-host",
+					Port:       22,
+					User:       "fake-ssh-user",
+					PrivateKey: "/fake-private-key",
+				},
+			},
+		}
+
+		deploymentStateService := biconfig.NewFileSystemDeploymentStateService(fs, fakeuuid.NewFakeGenerator(), logger, "/path/to/manifest-state.json")
+		persistentRegistry = biregistry.NewPersistentRegistry(deploymentStateService, logger)
+
+		getter := func(path string) biregistry.PersistentRegistry {
+			Expect(path).To(Equal(deploymentManifestPath))
+			return persistentRegistry
+		}
+		interrupts := func() <-chan os.Signal { return interruptCh }
+
+		command = NewRegistryCmd(fakeUI, fs, fakeInstallationParser, getter, fakeSSHTunnelFactory, interrupts, logger)
+	})
+
+	getSettings := func(port string) string {
+		httpClient := http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		response, err := httpClient.Get("http://127.0.0.1:" + port + "/instances/fake-instance-id/settings")
+		Expect(err).ToNot(HaveOccurred())
+		defer response.Body.Close()
+
+		body, err := ioutil.ReadAll(response.Body)
+		Expect(err).ToNot(HaveOccurred())
+		return string(body)
+	}
+
+	runAsync := func(args []string) chan error {
+		errCh := make(chan error, 1)
+		go func() {
+			defer GinkgoRecover()
+			errCh <- command.Run(fakeStage, args)
+		}()
+		return errCh
+	}
+
+	Context("when the deployment was deployed with a persistent registry", func() {
+		BeforeEach(func() {
+			err := persistentRegistry.SaveCredentials("fake-username", "fake-password")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = persistentRegistry.Save("fake-instance-id", []byte(`{"agent_id":"fake-agent-id"}`))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("serves the saved settings until the agent fetches them", func() {
+			errCh := runAsync([]string{"serve", deploymentManifestPath})
+
+			Eventually(func() int { return len(fakeStage.PerformCalls) }).Should(Equal(1))
+			Expect(fakeStage.PerformCalls[0].Name).To(Equal("Starting registry"))
+
+			Expect(getSettings("6903")).To(ContainSubstring(`fake-agent-id`))
+
+			Eventually(errCh).Should(Receive(BeNil()))
+			Expect(fakeUI.Said).To(ContainElement("Instance 'fake-instance-id' fetched its settings"))
+		})
+
+		It("forwards the registry port onto the VM through the ssh tunnel", func() {
+			errCh := runAsync([]string{"serve", deploymentManifestPath})
+			Eventually(func() int { return len(fakeStage.PerformCalls) }).Should(Equal(1))
+			getSettings("6903")
+			Eventually(errCh).Should(Receive(BeNil()))
+
+			Expect(fakeSSHTunnelFactory.NewSSHTunnelOptions).To(Equal(bisshtunnel.Options{
+				Host:              "fake-ssh-host",
+				Port:              22,
+				User:              "fake-ssh-user",
+				PrivateKey:        "/fake-private-key",
+				LocalForwardPort:  6903,
+				RemoteForwardPort: 6903,
+			}))
+			Eventually(func() bool { return fakeSSHTunnel.Stopped }).Should(BeTrue())
+		})
+
+		It("serves on the given port", func() {
+			errCh := runAsync([]string{"serve", "--port", "6904", deploymentManifestPath})
+			Eventually(func() int { return len(fakeStage.PerformCalls) }).Should(Equal(1))
+
+			Expect(getSettings("6904")).To(ContainSubstring(`fake-agent-id`))
+			Eventually(errCh).Should(Receive(BeNil()))
+		})
+
+		It("serves until interrupted with --forever", func() {
+			errCh := runAsync([]string{"serve", "--forever", deploymentManifestPath})
+			Eventually(func() int { return len(fakeStage.PerformCalls) }).Should(Equal(1))
+
+			getSettings("6903")
+			Consistently(errCh).ShouldNot(Receive())
+
+			interruptCh <- os.Interrupt
+			Eventually(errCh).Should(Receive(BeNil()))
+			Expect(fakeUI.Said).To(ContainElement("Stopping registry"))
+		})
+	})
+
+	Context("when the deployment was not deployed with a persistent registry", func() {
+		It("returns an error", func() {
+			err := command.Run(fakeStage, []string{"serve", deploymentManifestPath})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Deployment state has no persistent registry"))
+		})
+	})
+
+	Context("when the deployment does not use a registry", func() {
+		BeforeEach(func() {
+			fakeInstallationParser.ParseManifest = biinstallmanifest.Manifest{}
+		})
+
+		It("returns an error", func() {
+			err := command.Run(fakeStage, []string{"serve", deploymentManifestPath})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Deployment does not use a registry"))
+		})
+	})
+
+	It("returns an error when the deployment manifest does not exist", func() {
+		err := command.Run(fakeStage, []string{"serve", "/path/to/missing-manifest.yml"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Deployment manifest does not exist"))
+	})
+
+	It("returns an error without the serve subcommand", func() {
+		err := command.Run(fakeStage, []string{deploymentManifestPath})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Invalid usage"))
+	})
+})
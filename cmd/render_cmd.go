@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	boshsys "github.com/cloudfoundry/bosh-agent/system"
+
+	biinstancestate "github.com/cloudfoundry/bosh-init/deployment/instance/state"
+	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
+	bideplrel "github.com/cloudfoundry/bosh-init/deployment/release"
+	bitarball "github.com/cloudfoundry/bosh-init/installation/tarball"
+	birel "github.com/cloudfoundry/bosh-init/release"
+	bireljob "github.com/cloudfoundry/bosh-init/release/job"
+	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
+	bitemplate "github.com/cloudfoundry/bosh-init/templatescompiler"
+	biui "github.com/cloudfoundry/bosh-init/ui"
+)
+
+type renderCmd struct {
+	ui                 biui.UI
+	fs                 boshsys.FileSystem
+	manifestValidator  manifestValidator
+	releaseManager     birel.Manager
+	releaseJobResolver bideplrel.JobResolver
+	jobRenderer        bitemplate.JobRenderer
+	logger             boshlog.Logger
+	logTag             string
+}
+
+func NewRenderCmd(
+	ui biui.UI,
+	fs boshsys.FileSystem,
+	releaseSetParser birelsetmanifest.Parser,
+	releaseSetValidator birelsetmanifest.Validator,
+	deploymentParser bideplmanifest.Parser,
+	deploymentValidator bideplmanifest.Validator,
+	releaseExtractor birel.Extractor,
+	releaseManager birel.Manager,
+	releaseJobResolver bideplrel.JobResolver,
+	jobRenderer bitemplate.JobRenderer,
+	tarballProvider bitarball.Provider,
+	logger boshlog.Logger,
+) Cmd {
+	return &renderCmd{
+		ui: ui,
+		fs: fs,
+		manifestValidator: newManifestValidator(
+			fs,
+			releaseSetParser,
+			releaseSetValidator,
+			deploymentParser,
+			deploymentValidator,
+			releaseExtractor,
+			releaseManager,
+			tarballProvider,
+		),
+		releaseManager:     releaseManager,
+		releaseJobResolver: releaseJobResolver,
+		jobRenderer:        jobRenderer,
+		logger:             logger,
+		logTag:             "renderCmd",
+	}
+}
+
+func (c *renderCmd) Name() string {
+	return "render"
+}
+
+func (c *renderCmd) Meta() Meta {
+	return Meta{
+		Synopsis: "Render the job templates of a deployment job locally, without deploying",
+		Usage:    "[--job <job_name>] [--output-dir <dir>] [--diff] <deployment_manifest_path>",
+		Env:      genericEnv,
+	}
+}
+
+func (c *renderCmd) Run(stage biui.Stage, args []string) error {
+	positionalArgs, boolFlags, valueFlags, err := parseFlags(args, []string{"diff"}, []string{"job", "output-dir"})
+	if err != nil {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return err
+	}
+	if len(positionalArgs) != 1 {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return errors.New("Invalid usage - render command requires exactly 1 argument")
+	}
+
+	manifestAbsFilePath, err := filepath.Abs(positionalArgs[0])
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Getting absolute path to deployment file '%s'", positionalArgs[0])
+	}
+
+	if !c.fs.FileExists(manifestAbsFilePath) {
+		c.ui.ErrorLinef("Deployment '%s' does not exist", manifestAbsFilePath)
+		return bosherr.Errorf("Deployment manifest does not exist at '%s'", manifestAbsFilePath)
+	}
+
+	outputDir, given := valueFlags["output-dir"]
+	if !given {
+		outputDir = defaultRenderDir(manifestAbsFilePath)
+	}
+	outputAbsDir, err := filepath.Abs(outputDir)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Getting absolute path to output dir '%s'", outputDir)
+	}
+
+	c.ui.PrintLinef("Deployment manifest: '%s'", manifestAbsFilePath)
+
+	defer func() {
+		err := c.releaseManager.DeleteAll()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Deleting all extracted releases: %s", err.Error())
+		}
+	}()
+
+	var deploymentManifest bideplmanifest.Manifest
+	err = stage.PerformComplex("validating", func(validationStage biui.Stage) error {
+		deploymentManifest, err = c.validate(validationStage, manifestAbsFilePath)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	jobName, given := valueFlags["job"]
+	if !given {
+		jobName = deploymentManifest.JobName()
+	}
+
+	if !boolFlags["diff"] {
+		err = c.render(stage, deploymentManifest, jobName, outputAbsDir)
+		if err != nil {
+			return err
+		}
+
+		c.ui.PrintLinef("Rendered job templates: '%s'", outputAbsDir)
+		return nil
+	}
+
+	if !c.fs.FileExists(outputAbsDir) {
+		return bosherr.Errorf("No previously rendered job templates to compare with in '%s'", outputAbsDir)
+	}
+
+	renderDir, err := c.fs.TempDir("bosh-init-render")
+	if err != nil {
+		return bosherr.WrapError(err, "Creating rendered templates directory")
+	}
+	defer func() {
+		err := c.fs.RemoveAll(renderDir)
+		if err != nil {
+			c.logger.Warn(c.logTag, "Deleting rendered templates directory: %s", err.Error())
+		}
+	}()
+
+	err = c.render(stage, deploymentManifest, jobName, renderDir)
+	if err != nil {
+		return err
+	}
+
+	return c.printDiff(outputAbsDir, renderDir)
+}
+
+func (c *renderCmd) validate(validationStage biui.Stage, deploymentManifestPath string) (bideplmanifest.Manifest, error) {
+	var (
+		releaseSetManifest birelsetmanifest.Manifest
+		deploymentManifest bideplmanifest.Manifest
+	)
+	err := validationStage.Perform("Validating deployment manifest", func() error {
+		var err error
+		releaseSetManifest, deploymentManifest, err = c.manifestValidator.ValidateManifests(deploymentManifestPath)
+		return err
+	})
+	if err != nil {
+		return deploymentManifest, err
+	}
+
+	err = c.manifestValidator.ValidateReleases(validationStage, releaseSetManifest)
+	if err != nil {
+		return deploymentManifest, err
+	}
+
+	err = c.manifestValidator.ValidateReleaseJobs(validationStage, deploymentManifest)
+
+	return deploymentManifest, err
+}
+
+// render writes the templates of every release job of the deployment job to a sub-dir of renderDir named after the release job
+func (c *renderCmd) render(stage biui.Stage, deploymentManifest bideplmanifest.Manifest, jobName string, renderDir string) error {
+	deploymentJob, found := deploymentManifest.FindJobByName(jobName)
+	if !found {
+		return bosherr.Errorf("Job '%s' not found in deployment manifest", jobName)
+	}
+
+	networkInterfaces, err := deploymentManifest.NetworkInterfaces(deploymentJob.Name)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Finding networks for job '%s'", jobName)
+	}
+
+	instanceSpec := biinstancestate.BuildInstanceSpec(deploymentManifest, deploymentJob, 0, networkInterfaces)
+
+	releaseJobs := make([]bireljob.Job, len(deploymentJob.Templates), len(deploymentJob.Templates))
+	for i, jobRef := range deploymentJob.Templates {
+		releaseJobs[i], err = c.releaseJobResolver.Resolve(jobRef.Name, jobRef.Release)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Resolving job '%s' in release '%s'", jobRef.Name, jobRef.Release)
+		}
+	}
+
+	instanceSpec.Links, err = biinstancestate.BuildLinks(deploymentManifest, deploymentJob, releaseJobs, c.releaseJobResolver)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Resolving links for job '%s'", jobName)
+	}
+
+	for i, jobRef := range deploymentJob.Templates {
+		releaseJob := releaseJobs[i]
+		err = stage.Perform(fmt.Sprintf("Rendering job templates of '%s/%s'", jobRef.Release, jobRef.Name), func() error {
+			renderedJob, err := c.jobRenderer.Render(releaseJob, deploymentJob.Properties, deploymentManifest.Properties, deploymentManifest.Name, instanceSpec)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Rendering job '%s'", releaseJob.Name)
+			}
+			defer renderedJob.DeleteSilently()
+
+			// files of a previous render that are no longer rendered must not linger
+			jobDir := filepath.Join(renderDir, releaseJob.Name)
+			err = c.fs.RemoveAll(jobDir)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Removing previously rendered job '%s'", jobDir)
+			}
+
+			err = c.fs.CopyDir(renderedJob.Path(), jobDir)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Copying rendered job '%s' to '%s'", releaseJob.Name, jobDir)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// printDiff prints the differences between a previous render in outputDir and the new render
+func (c *renderCmd) printDiff(outputDir string, renderDir string) error {
+	diffLines, err := diffDirs(c.fs, outputDir, renderDir)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Comparing rendered job templates with '%s'", outputDir)
+	}
+
+	if len(diffLines) == 0 {
+		c.ui.PrintLinef("No changes to the job templates rendered in '%s'", outputDir)
+		return nil
+	}
+
+	c.ui.PrintLinef("%s", strings.Join(diffLines, "\n"))
+	return nil
+}
+
+func defaultRenderDir(deploymentManifestPath string) string {
+	baseFileName := filepath.Base(strings.TrimSuffix(deploymentManifestPath, filepath.Ext(deploymentManifestPath)))
+	return filepath.Join(filepath.Dir(deploymentManifestPath), fmt.Sprintf("%s-rendered", baseFileName))
+}
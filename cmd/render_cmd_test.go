@@ -0,0 +1,285 @@
+package cmd_test
+
+import (
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gomock/gomock"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	fakesys "github.com/cloudfoundry/bosh-agent/system/fakes"
+
+	. "github.com/cloudfoundry/bosh-init/cmd"
+	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
+	fakebideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest/fakes"
+	bideplrel "github.com/cloudfoundry/bosh-init/deployment/release"
+	bitarball "github.com/cloudfoundry/bosh-init/installation/tarball"
+	birel "github.com/cloudfoundry/bosh-init/release"
+	fakebirel "github.com/cloudfoundry/bosh-init/release/fakes"
+	bireljob "github.com/cloudfoundry/bosh-init/release/job"
+	birelmanifest "github.com/cloudfoundry/bosh-init/release/manifest"
+	mock_release "github.com/cloudfoundry/bosh-init/release/mocks"
+	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
+	fakebirelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest/fakes"
+	bitemplate "github.com/cloudfoundry/bosh-init/templatescompiler"
+	mock_template "github.com/cloudfoundry/bosh-init/templatescompiler/mocks"
+
+	fakebiui "github.com/cloudfoundry/bosh-init/ui/fakes"
+)
+
+var _ = Describe("RenderCmd", func() {
+	var mockCtrl *gomock.Controller
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	var (
+		fs                       *fakesys.FakeFileSystem
+		fakeUI                   *fakebiui.FakeUI
+		fakeStage                *fakebiui.FakeStage
+		fakeReleaseSetParser     *fakebirelsetmanifest.FakeParser
+		fakeReleaseSetValidator  *fakebirelsetmanifest.FakeValidator
+		fakeDeploymentParser     *fakebideplmanifest.FakeParser
+		fakeDeploymentValidator  *fakebideplmanifest.FakeValidator
+		mockReleaseExtractor     *mock_release.MockExtractor
+		mockJobRenderer          *mock_template.MockJobRenderer
+		mockRenderedJob          *mock_template.MockRenderedJob
+		releaseManager           birel.Manager
+		fakeRelease              *fakebirel.FakeRelease
+		releaseJob               bireljob.Job
+		deploymentManifest       bideplmanifest.Manifest
+		renderedInstanceSpec     bitemplate.InstanceSpec
+		deploymentManifestPath   = "/path/to/manifest.yml"
+		releaseTarballPath       = "/path/to/release.tgz"
+		defaultRenderedJobPath   = "/path/to/manifest-rendered/fake-release-job-name"
+		expectedRenderedFilePath = "/path/to/manifest-rendered/fake-release-job-name/bin/ctl"
+
+		command Cmd
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		fakeUI = &fakebiui.FakeUI{}
+		fakeStage = fakebiui.NewFakeStage()
+		fakeReleaseSetParser = fakebirelsetmanifest.NewFakeParser()
+		fakeReleaseSetValidator = fakebirelsetmanifest.NewFakeValidator()
+		fakeReleaseSetValidator.SetValidateBehavior([]fakebirelsetmanifest.ValidateOutput{{Err: nil}})
+		fakeDeploymentParser = fakebideplmanifest.NewFakeParser()
+		fakeDeploymentValidator = fakebideplmanifest.NewFakeValidator()
+		fakeDeploymentValidator.SetValidateBehavior([]fakebideplmanifest.ValidateOutput{{Err: nil}})
+		fakeDeploymentValidator.SetValidateReleaseJobsBehavior([]fakebideplmanifest.ValidateReleaseJobsOutput{{Err: nil}})
+		mockReleaseExtractor = mock_release.NewMockExtractor(mockCtrl)
+		mockJobRenderer = mock_template.NewMockJobRenderer(mockCtrl)
+		mockRenderedJob = mock_template.NewMockRenderedJob(mockCtrl)
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		releaseManager = birel.NewManager(logger)
+		releaseJobResolver := bideplrel.NewJobResolver(releaseManager)
+		tarballProvider := bitarball.NewOfflineProvider(nil, fs, logger)
+
+		fs.WriteFileString(deploymentManifestPath, "")
+		fs.WriteFileString(releaseTarballPath, "")
+		fs.WriteFileString("/fake-rendered-job-path/bin/ctl", "fake-rendered-ctl")
+
+		fakeReleaseSetParser.ParseManifest = birelsetmanifest.Manifest{
+			Releases: []birelmanifest.ReleaseRef{
+				{Name: "fake-release-name", URL: "file://" + releaseTarballPath},
+			},
+		}
+
+		deploymentManifest = bideplmanifest.Manifest{
+			Name: "fake-deployment-name",
+			Jobs: []bideplmanifest.Job{
+				{
+					Name: "fake-job-name",
+					Templates: []bideplmanifest.ReleaseJobRef{
+						{Name: "fake-release-job-name", Release: "fake-release-name"},
+					},
+					Properties: biproperty.Map{"fake-job-property": "fake-job-property-value"},
+				},
+				{
+					Name:      "fake-errand-name",
+					Lifecycle: bideplmanifest.JobLifecycleErrand,
+				},
+			},
+			Properties: biproperty.Map{"fake-global-property": "fake-global-property-value"},
+		}
+		fakeDeploymentParser.ParseManifest = deploymentManifest
+
+		releaseJob = bireljob.Job{Name: "fake-release-job-name"}
+		fakeRelease = fakebirel.New("fake-release-name", "1.0")
+		fakeRelease.ReleaseJobs = []bireljob.Job{releaseJob}
+
+		mockReleaseExtractor.EXPECT().Extract(releaseTarballPath).Return(fakeRelease, nil).AnyTimes()
+		mockRenderedJob.EXPECT().Path().Return("/fake-rendered-job-path").AnyTimes()
+		mockRenderedJob.EXPECT().DeleteSilently().AnyTimes()
+
+		command = NewRenderCmd(
+			fakeUI,
+			fs,
+			fakeReleaseSetParser,
+			fakeReleaseSetValidator,
+			fakeDeploymentParser,
+			fakeDeploymentValidator,
+			mockReleaseExtractor,
+			releaseManager,
+			releaseJobResolver,
+			mockJobRenderer,
+			tarballProvider,
+			logger,
+		)
+	})
+
+	expectRender := func() {
+		mockJobRenderer.EXPECT().Render(
+			releaseJob,
+			deploymentManifest.Jobs[0].Properties,
+			deploymentManifest.Properties,
+			"fake-deployment-name",
+			gomock.Any(),
+		).Do(func(_ bireljob.Job, _, _ biproperty.Map, _ string, instanceSpec bitemplate.InstanceSpec) {
+			renderedInstanceSpec = instanceSpec
+		}).Return(mockRenderedJob, nil)
+	}
+
+	It("writes the rendered job templates next to the manifest", func() {
+		expectRender()
+
+		err := command.Run(fakeStage, []string{deploymentManifestPath})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(fs.ReadFileString(expectedRenderedFilePath)).To(Equal("fake-rendered-ctl"))
+		Expect(renderedInstanceSpec.Index).To(Equal(0))
+		Expect(renderedInstanceSpec.Bootstrap).To(BeTrue())
+		Expect(fakeUI.Said).To(ContainElement("Rendered job templates: '/path/to/manifest-rendered'"))
+	})
+
+	It("validates the manifest and the release jobs", func() {
+		expectRender()
+
+		err := command.Run(fakeStage, []string{deploymentManifestPath})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(fakeDeploymentValidator.ValidateInputs).To(HaveLen(1))
+		Expect(fakeDeploymentValidator.ValidateReleaseJobsInputs).To(HaveLen(1))
+		Expect(fakeStage.PerformCalls[0].Name).To(Equal("validating"))
+		Expect(fakeStage.PerformCalls[1].Name).To(Equal("Rendering job templates of 'fake-release-name/fake-release-job-name'"))
+	})
+
+	It("deletes the extracted releases", func() {
+		expectRender()
+
+		err := command.Run(fakeStage, []string{deploymentManifestPath})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(releaseManager.List()).To(BeEmpty())
+	})
+
+	It("replaces the files of a previous render", func() {
+		expectRender()
+		fs.WriteFileString(defaultRenderedJobPath+"/bin/stale", "stale")
+
+		err := command.Run(fakeStage, []string{deploymentManifestPath})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fs.FileExists(defaultRenderedJobPath + "/bin/stale")).To(BeFalse())
+		Expect(fs.FileExists(expectedRenderedFilePath)).To(BeTrue())
+	})
+
+	It("writes the rendered job templates to --output-dir", func() {
+		expectRender()
+
+		err := command.Run(fakeStage, []string{"--output-dir", "/fake-output-dir", deploymentManifestPath})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fs.ReadFileString("/fake-output-dir/fake-release-job-name/bin/ctl")).To(Equal("fake-rendered-ctl"))
+	})
+
+	It("renders the job given with --job", func() {
+		err := command.Run(fakeStage, []string{"--job", "fake-errand-name", deploymentManifestPath})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fs.FileExists(expectedRenderedFilePath)).To(BeFalse())
+	})
+
+	Context("when the job does not exist", func() {
+		It("returns an error", func() {
+			err := command.Run(fakeStage, []string{"--job", "fake-unknown-job", deploymentManifestPath})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Job 'fake-unknown-job' not found in deployment manifest"))
+		})
+	})
+
+	Context("when rendering fails", func() {
+		It("returns an error naming the template", func() {
+			mockJobRenderer.EXPECT().Render(releaseJob, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(nil, errors.New("Error filling in template 'ctl.erb' for fake-release-job-name/0 (line 2: fake-error)"))
+
+			err := command.Run(fakeStage, []string{deploymentManifestPath})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Rendering job 'fake-release-job-name'"))
+			Expect(err.Error()).To(ContainSubstring("'ctl.erb' for fake-release-job-name/0 (line 2: fake-error)"))
+		})
+	})
+
+	Context("when the deployment manifest is invalid", func() {
+		It("returns an error without rendering", func() {
+			fakeDeploymentValidator.SetValidateBehavior([]fakebideplmanifest.ValidateOutput{{Err: errors.New("fake-validation-error")}})
+
+			err := command.Run(fakeStage, []string{deploymentManifestPath})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-validation-error"))
+		})
+	})
+
+	Context("with --diff", func() {
+		BeforeEach(func() {
+			fs.TempDirDir = "/fake-render-dir"
+			fs.WriteFileString(defaultRenderedJobPath+"/bin/ctl", "previously-rendered-ctl")
+		})
+
+		It("prints the differences with the previous render without changing it", func() {
+			expectRender()
+
+			err := command.Run(fakeStage, []string{"--diff", deploymentManifestPath})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeUI.Said).To(ContainElement(strings.Join([]string{
+				"--- /path/to/manifest-rendered/fake-release-job-name/bin/ctl",
+				"+++ /fake-render-dir/fake-release-job-name/bin/ctl",
+				"@@ -1,1 +1,1 @@",
+				"-previously-rendered-ctl",
+				"+fake-rendered-ctl",
+			}, "\n")))
+			Expect(fs.ReadFileString(expectedRenderedFilePath)).To(Equal("previously-rendered-ctl"))
+			Expect(fs.FileExists("/fake-render-dir")).To(BeFalse())
+		})
+
+		It("says when nothing changed", func() {
+			expectRender()
+			fs.WriteFileString(defaultRenderedJobPath+"/bin/ctl", "fake-rendered-ctl")
+
+			err := command.Run(fakeStage, []string{"--diff", deploymentManifestPath})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeUI.Said).To(ContainElement("No changes to the job templates rendered in '/path/to/manifest-rendered'"))
+		})
+
+		It("returns an error when there is no previous render", func() {
+			err := command.Run(fakeStage, []string{"--diff", "--output-dir", "/fake-missing-dir", deploymentManifestPath})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("No previously rendered job templates to compare with in '/fake-missing-dir'"))
+		})
+	})
+
+	Context("when the arguments are invalid", func() {
+		It("returns an error", func() {
+			err := command.Run(fakeStage, []string{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Invalid usage - render command requires exactly 1 argument"))
+		})
+	})
+})
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshsys "github.com/cloudfoundry/bosh-agent/system"
+)
+
+// diffContextLines is the number of unchanged lines shown around the changed lines of a file
+const diffContextLines = 3
+
+type diffLine struct {
+	op     byte // ' ', '-' or '+'
+	text   string
+	oldPos int // number of old lines before this line
+	newPos int // number of new lines before this line
+}
+
+// diffDirs returns the differences between the files of oldDir and newDir in the unified format of 'diff -ruN',
+// treating files missing from either dir as empty
+func diffDirs(fs boshsys.FileSystem, oldDir string, newDir string) ([]string, error) {
+	relativePaths := map[string]bool{}
+	for _, dir := range []string{oldDir, newDir} {
+		err := fs.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			relativePath, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			relativePaths[relativePath] = true
+			return nil
+		})
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Listing files in '%s'", dir)
+		}
+	}
+
+	sortedPaths := []string{}
+	for relativePath := range relativePaths {
+		sortedPaths = append(sortedPaths, relativePath)
+	}
+	sort.Strings(sortedPaths)
+
+	lines := []string{}
+	for _, relativePath := range sortedPaths {
+		oldPath := filepath.Join(oldDir, relativePath)
+		newPath := filepath.Join(newDir, relativePath)
+
+		oldContent, err := readFileIfExists(fs, oldPath)
+		if err != nil {
+			return nil, err
+		}
+		newContent, err := readFileIfExists(fs, newPath)
+		if err != nil {
+			return nil, err
+		}
+		if oldContent == newContent {
+			continue
+		}
+
+		lines = append(lines, fmt.Sprintf("--- %s", oldPath), fmt.Sprintf("+++ %s", newPath))
+		lines = append(lines, unifiedDiff(splitLines(oldContent), splitLines(newContent))...)
+	}
+
+	return lines, nil
+}
+
+func readFileIfExists(fs boshsys.FileSystem, path string) (string, error) {
+	if !fs.FileExists(path) {
+		return "", nil
+	}
+
+	content, err := fs.ReadFileString(path)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Reading '%s'", path)
+	}
+
+	return content, nil
+}
+
+func splitLines(content string) []string {
+	if content == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// unifiedDiff returns the hunks of changed lines between oldLines and newLines, found from their longest common subsequence
+func unifiedDiff(oldLines []string, newLines []string) []string {
+	// common[i][j] is the length of the longest common subsequence of oldLines[i:] and newLines[j:]
+	common := make([][]int, len(oldLines)+1)
+	for i := range common {
+		common[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	diff := []diffLine{}
+	i, j := 0, 0
+	for i < len(oldLines) || j < len(newLines) {
+		switch {
+		case i < len(oldLines) && j < len(newLines) && oldLines[i] == newLines[j]:
+			diff = append(diff, diffLine{op: ' ', text: oldLines[i], oldPos: i, newPos: j})
+			i++
+			j++
+		case j == len(newLines) || (i < len(oldLines) && common[i+1][j] >= common[i][j+1]):
+			diff = append(diff, diffLine{op: '-', text: oldLines[i], oldPos: i, newPos: j})
+			i++
+		default:
+			diff = append(diff, diffLine{op: '+', text: newLines[j], oldPos: i, newPos: j})
+			j++
+		}
+	}
+
+	lines := []string{}
+	for start := 0; start < len(diff); {
+		if diff[start].op == ' ' {
+			start++
+			continue
+		}
+
+		// a hunk starts with the context before its first change and ends once more than twice the context is unchanged
+		hunkStart := start - diffContextLines
+		if hunkStart < 0 {
+			hunkStart = 0
+		}
+		hunkEnd := start
+		for unchanged := 0; hunkEnd < len(diff) && unchanged <= 2*diffContextLines; hunkEnd++ {
+			if diff[hunkEnd].op == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		for hunkEnd > start && diff[hunkEnd-1].op == ' ' {
+			hunkEnd--
+		}
+		lastChange := hunkEnd
+		hunkEnd += diffContextLines
+		if hunkEnd > len(diff) {
+			hunkEnd = len(diff)
+		}
+
+		lines = append(lines, hunkHeader(diff[hunkStart:hunkEnd]))
+		for _, line := range diff[hunkStart:hunkEnd] {
+			lines = append(lines, string(line.op)+line.text)
+		}
+		start = lastChange
+	}
+
+	return lines
+}
+
+func hunkHeader(hunk []diffLine) string {
+	oldCount, newCount := 0, 0
+	for _, line := range hunk {
+		if line.op != '+' {
+			oldCount++
+		}
+		if line.op != '-' {
+			newCount++
+		}
+	}
+
+	// like diff, an empty range starts at the line before it
+	oldStart, newStart := hunk[0].oldPos, hunk[0].newPos
+	if oldCount > 0 {
+		oldStart++
+	}
+	if newCount > 0 {
+		newStart++
+	}
+
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", oldStart, oldCount, newStart, newCount)
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	fakesys "github.com/cloudfoundry/bosh-agent/system/fakes"
+)
+
+var _ = Describe("diffDirs", func() {
+	var fs *fakesys.FakeFileSystem
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+	})
+
+	It("returns no differences when the files are the same", func() {
+		fs.WriteFileString("/old/job/bin/ctl", "line\n")
+		fs.WriteFileString("/new/job/bin/ctl", "line\n")
+
+		Expect(diffDirs(fs, "/old", "/new")).To(BeEmpty())
+	})
+
+	It("diffs files missing from either dir against empty files", func() {
+		fs.WriteFileString("/old/job/removed", "removed-line\n")
+		fs.WriteFileString("/new/job/added", "added-line\n")
+
+		Expect(diffDirs(fs, "/old", "/new")).To(Equal([]string{
+			"--- /old/job/added",
+			"+++ /new/job/added",
+			"@@ -0,0 +1,1 @@",
+			"+added-line",
+			"--- /old/job/removed",
+			"+++ /new/job/removed",
+			"@@ -1,1 +0,0 @@",
+			"-removed-line",
+		}))
+	})
+})
+
+var _ = Describe("unifiedDiff", func() {
+	It("returns the changed lines with their surrounding lines", func() {
+		oldLines := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13", "14", "15", "16"}
+		newLines := []string{"1", "2", "3", "4", "5", "changed-6", "7", "8", "9", "10", "11", "12", "13", "14", "15", "16", "17"}
+
+		Expect(unifiedDiff(oldLines, newLines)).To(Equal([]string{
+			"@@ -3,7 +3,7 @@",
+			" 3",
+			" 4",
+			" 5",
+			"-6",
+			"+changed-6",
+			" 7",
+			" 8",
+			" 9",
+			"@@ -14,3 +14,4 @@",
+			" 14",
+			" 15",
+			" 16",
+			"+17",
+		}))
+	})
+
+	It("merges changes closer than twice the surrounding lines into one hunk", func() {
+		oldLines := []string{"1", "2", "3", "4", "5", "6", "7"}
+		newLines := []string{"changed-1", "2", "3", "4", "5", "6", "changed-7"}
+
+		Expect(unifiedDiff(oldLines, newLines)).To(Equal([]string{
+			"@@ -1,7 +1,7 @@",
+			"-1",
+			"+changed-1",
+			" 2",
+			" 3",
+			" 4",
+			" 5",
+			" 6",
+			"-7",
+			"+changed-7",
+		}))
+	})
+})
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"errors"
+	"path/filepath"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	boshsys "github.com/cloudfoundry/bosh-agent/system"
+
+	biui "github.com/cloudfoundry/bosh-init/ui"
+)
+
+type runErrandCmd struct {
+	errandRunnerProvider func(deploymentManifestPath string, errandName string) ErrandRunner
+	ui                   biui.UI
+	fs                   boshsys.FileSystem
+	logger               boshlog.Logger
+	logTag               string
+}
+
+func NewRunErrandCmd(
+	ui biui.UI,
+	fs boshsys.FileSystem,
+	logger boshlog.Logger,
+	errandRunnerProvider func(deploymentManifestPath string, errandName string) ErrandRunner,
+) Cmd {
+	return &runErrandCmd{
+		ui:                   ui,
+		fs:                   fs,
+		errandRunnerProvider: errandRunnerProvider,
+		logger:               logger,
+		logTag:               "runErrandCmd",
+	}
+}
+
+func (c *runErrandCmd) Name() string {
+	return "run-errand"
+}
+
+func (c *runErrandCmd) Meta() Meta {
+	return Meta{
+		Synopsis: "Run an errand job of an existing deployment",
+		Usage:    "[--keep-alive] <deployment_manifest_path> <errand_name>",
+		Env:      genericEnv,
+	}
+}
+
+func (c *runErrandCmd) Run(stage biui.Stage, args []string) error {
+	deploymentManifestPath, errandName, keepAlive, err := c.parseCmdInputs(args)
+	if err != nil {
+		return err
+	}
+
+	manifestAbsFilePath, err := filepath.Abs(deploymentManifestPath)
+	if err != nil {
+		c.ui.ErrorLinef("Failed getting absolute path to deployment file '%s'", deploymentManifestPath)
+		return bosherr.WrapErrorf(err, "Getting absolute path to deployment file '%s'", deploymentManifestPath)
+	}
+
+	if !c.fs.FileExists(manifestAbsFilePath) {
+		c.ui.ErrorLinef("Deployment '%s' does not exist", manifestAbsFilePath)
+		return bosherr.Errorf("Deployment manifest does not exist at '%s'", manifestAbsFilePath)
+	}
+
+	c.ui.PrintLinef("Deployment manifest: '%s'", manifestAbsFilePath)
+
+	errandRunner := c.errandRunnerProvider(manifestAbsFilePath, errandName)
+	return errandRunner.RunErrand(stage, errandName, keepAlive)
+}
+
+func (c *runErrandCmd) parseCmdInputs(args []string) (string, string, bool, error) {
+	positionalArgs, flags, err := parseBoolFlags(args, "keep-alive")
+	if err != nil {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return "", "", false, err
+	}
+	if len(positionalArgs) != 2 {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return "", "", false, errors.New("Invalid usage - run-errand command requires exactly 2 arguments")
+	}
+	return positionalArgs[0], positionalArgs[1], flags["keep-alive"], nil
+}
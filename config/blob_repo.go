@@ -0,0 +1,67 @@
+package config
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+)
+
+// BlobRepo persists the IDs of blobs uploaded to the blobstore of the current VM.
+// The agent blobstore does not outlive the VM, so records of other VMs are discarded.
+type BlobRepo interface {
+	Contains(blobID string) (bool, error)
+	Save(blobID string) error
+}
+
+type blobRepo struct {
+	deploymentStateService DeploymentStateService
+}
+
+func NewBlobRepo(deploymentStateService DeploymentStateService) BlobRepo {
+	return &blobRepo{
+		deploymentStateService: deploymentStateService,
+	}
+}
+
+func (r *blobRepo) Contains(blobID string) (bool, error) {
+	deploymentState, err := r.deploymentStateService.Load()
+	if err != nil {
+		return false, bosherr.WrapError(err, "Loading existing config")
+	}
+
+	if deploymentState.CurrentVMCID == "" {
+		return false, nil
+	}
+
+	for _, record := range deploymentState.Blobs {
+		if record.ID == blobID && record.VMCID == deploymentState.CurrentVMCID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *blobRepo) Save(blobID string) error {
+	err := r.deploymentStateService.Update(func(deploymentState *DeploymentState) error {
+		if deploymentState.CurrentVMCID == "" {
+			return nil
+		}
+
+		records := []BlobRecord{}
+		for _, record := range deploymentState.Blobs {
+			if record.VMCID != deploymentState.CurrentVMCID || record.ID == blobID {
+				continue
+			}
+			records = append(records, record)
+		}
+
+		deploymentState.Blobs = append(records, BlobRecord{
+			ID:    blobID,
+			VMCID: deploymentState.CurrentVMCID,
+		})
+		return nil
+	})
+	if err != nil {
+		return bosherr.WrapError(err, "Updating deployment state")
+	}
+	return nil
+}
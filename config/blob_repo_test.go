@@ -0,0 +1,99 @@
+package config_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	fakesys "github.com/cloudfoundry/bosh-agent/system/fakes"
+	fakeuuid "github.com/cloudfoundry/bosh-agent/uuid/fakes"
+
+	. "github.com/cloudfoundry/bosh-init/config"
+)
+
+var _ = Describe("BlobRepo", func() {
+	var (
+		repo                   BlobRepo
+		vmRepo                 VMRepo
+		deploymentStateService DeploymentStateService
+	)
+
+	BeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		fs := fakesys.NewFakeFileSystem()
+		deploymentStateService = NewFileSystemDeploymentStateService(fs, &fakeuuid.FakeGenerator{}, logger, "/fake/path")
+		repo = NewBlobRepo(deploymentStateService)
+		vmRepo = NewVMRepo(deploymentStateService)
+	})
+
+	Context("when there is a current vm", func() {
+		BeforeEach(func() {
+			err := vmRepo.UpdateCurrent("fake-vm-cid")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("contains saved blobs", func() {
+			err := repo.Save("fake-blob-id")
+			Expect(err).ToNot(HaveOccurred())
+
+			found, err := repo.Contains("fake-blob-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+
+			found, err = repo.Contains("fake-other-blob-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		It("does not save the same blob twice", func() {
+			err := repo.Save("fake-blob-id")
+			Expect(err).ToNot(HaveOccurred())
+			err = repo.Save("fake-blob-id")
+			Expect(err).ToNot(HaveOccurred())
+
+			deploymentState, err := deploymentStateService.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentState.Blobs).To(Equal([]BlobRecord{
+				{ID: "fake-blob-id", VMCID: "fake-vm-cid"},
+			}))
+		})
+
+		Context("when the vm is replaced", func() {
+			BeforeEach(func() {
+				err := repo.Save("fake-blob-id")
+				Expect(err).ToNot(HaveOccurred())
+
+				err = vmRepo.UpdateCurrent("fake-new-vm-cid")
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("does not contain the blobs of the old vm", func() {
+				found, err := repo.Contains("fake-blob-id")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeFalse())
+			})
+
+			It("discards the records of the old vm when saving", func() {
+				err := repo.Save("fake-other-blob-id")
+				Expect(err).ToNot(HaveOccurred())
+
+				deploymentState, err := deploymentStateService.Load()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentState.Blobs).To(Equal([]BlobRecord{
+					{ID: "fake-other-blob-id", VMCID: "fake-new-vm-cid"},
+				}))
+			})
+		})
+	})
+
+	Context("when there is no current vm", func() {
+		It("does not save blobs", func() {
+			err := repo.Save("fake-blob-id")
+			Expect(err).ToNot(HaveOccurred())
+
+			found, err := repo.Contains("fake-blob-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,80 @@
+package config
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+)
+
+// CompiledPackageRepo persists the packages compiled on the current VM, so that they can be exported after bosh-init exits.
+// Compiled packages are stored in the agent blobstore, which does not outlive the VM, so records of other VMs are discarded.
+type CompiledPackageRepo interface {
+	List() ([]CompiledPackageRecord, error)
+	Save(...CompiledPackageRecord) error
+}
+
+type compiledPackageRepo struct {
+	deploymentStateService DeploymentStateService
+}
+
+func NewCompiledPackageRepo(deploymentStateService DeploymentStateService) CompiledPackageRepo {
+	return &compiledPackageRepo{
+		deploymentStateService: deploymentStateService,
+	}
+}
+
+// List returns the packages compiled on the current VM
+func (r *compiledPackageRepo) List() ([]CompiledPackageRecord, error) {
+	deploymentState, err := r.deploymentStateService.Load()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Loading existing config")
+	}
+
+	records := []CompiledPackageRecord{}
+	if deploymentState.CurrentVMCID == "" {
+		return records, nil
+	}
+
+	for _, record := range deploymentState.CompiledPackages {
+		if record.VMCID == deploymentState.CurrentVMCID {
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
+
+// Save records packages compiled on the current VM, replacing the records of the same packages compiled against the same stemcells
+func (r *compiledPackageRepo) Save(newRecords ...CompiledPackageRecord) error {
+	err := r.deploymentStateService.Update(func(deploymentState *DeploymentState) error {
+		if deploymentState.CurrentVMCID == "" {
+			return nil
+		}
+
+		records := []CompiledPackageRecord{}
+		for _, record := range deploymentState.CompiledPackages {
+			if record.VMCID != deploymentState.CurrentVMCID || replacedByAny(record, newRecords) {
+				continue
+			}
+			records = append(records, record)
+		}
+
+		for _, newRecord := range newRecords {
+			newRecord.VMCID = deploymentState.CurrentVMCID
+			records = append(records, newRecord)
+		}
+		deploymentState.CompiledPackages = records
+		return nil
+	})
+	if err != nil {
+		return bosherr.WrapError(err, "Updating deployment state")
+	}
+	return nil
+}
+
+func replacedByAny(record CompiledPackageRecord, newRecords []CompiledPackageRecord) bool {
+	for _, newRecord := range newRecords {
+		if record.Name == newRecord.Name && record.Fingerprint == newRecord.Fingerprint && record.Stemcell == newRecord.Stemcell {
+			return true
+		}
+	}
+	return false
+}
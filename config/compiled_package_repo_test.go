@@ -0,0 +1,131 @@
+package config_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	fakesys "github.com/cloudfoundry/bosh-agent/system/fakes"
+	fakeuuid "github.com/cloudfoundry/bosh-agent/uuid/fakes"
+
+	. "github.com/cloudfoundry/bosh-init/config"
+)
+
+var _ = Describe("CompiledPackageRepo", func() {
+	var (
+		repo                   CompiledPackageRepo
+		vmRepo                 VMRepo
+		deploymentStateService DeploymentStateService
+
+		record CompiledPackageRecord
+	)
+
+	BeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		fs := fakesys.NewFakeFileSystem()
+		deploymentStateService = NewFileSystemDeploymentStateService(fs, &fakeuuid.FakeGenerator{}, logger, "/fake/path")
+		repo = NewCompiledPackageRepo(deploymentStateService)
+		vmRepo = NewVMRepo(deploymentStateService)
+
+		record = CompiledPackageRecord{
+			Name:        "fake-package-name",
+			Fingerprint: "fake-package-fingerprint",
+			Stemcell:    "ubuntu-trusty/3012",
+			BlobID:      "fake-blob-id",
+			SHA1:        "fake-blob-sha1",
+		}
+	})
+
+	Context("when there is a current vm", func() {
+		BeforeEach(func() {
+			err := vmRepo.UpdateCurrent("fake-vm-cid")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("lists saved compiled packages of the current vm", func() {
+			err := repo.Save(record)
+			Expect(err).ToNot(HaveOccurred())
+
+			records, err := repo.List()
+			Expect(err).ToNot(HaveOccurred())
+
+			record.VMCID = "fake-vm-cid"
+			Expect(records).To(Equal([]CompiledPackageRecord{record}))
+		})
+
+		It("replaces the record of the same package compiled against the same stemcell", func() {
+			err := repo.Save(record)
+			Expect(err).ToNot(HaveOccurred())
+
+			otherStemcellRecord := record
+			otherStemcellRecord.Stemcell = "ubuntu-trusty/3013"
+			err = repo.Save(otherStemcellRecord)
+			Expect(err).ToNot(HaveOccurred())
+
+			newRecord := record
+			newRecord.BlobID = "fake-new-blob-id"
+			err = repo.Save(newRecord)
+			Expect(err).ToNot(HaveOccurred())
+
+			records, err := repo.List()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(records).To(HaveLen(2))
+			Expect(records[0].Stemcell).To(Equal("ubuntu-trusty/3013"))
+			Expect(records[1].BlobID).To(Equal("fake-new-blob-id"))
+		})
+
+		It("saves several records at once", func() {
+			otherRecord := record
+			otherRecord.Name = "fake-other-package-name"
+			err := repo.Save(record, otherRecord)
+			Expect(err).ToNot(HaveOccurred())
+
+			records, err := repo.List()
+			Expect(err).ToNot(HaveOccurred())
+
+			record.VMCID = "fake-vm-cid"
+			otherRecord.VMCID = "fake-vm-cid"
+			Expect(records).To(Equal([]CompiledPackageRecord{record, otherRecord}))
+		})
+
+		Context("when the vm is replaced", func() {
+			BeforeEach(func() {
+				err := repo.Save(record)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = vmRepo.UpdateCurrent("fake-new-vm-cid")
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("does not list the compiled packages of the old vm", func() {
+				records, err := repo.List()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(records).To(BeEmpty())
+			})
+
+			It("discards the records of the old vm when saving", func() {
+				otherRecord := record
+				otherRecord.Name = "fake-other-package-name"
+				err := repo.Save(otherRecord)
+				Expect(err).ToNot(HaveOccurred())
+
+				deploymentState, err := deploymentStateService.Load()
+				Expect(err).ToNot(HaveOccurred())
+
+				otherRecord.VMCID = "fake-new-vm-cid"
+				Expect(deploymentState.CompiledPackages).To(Equal([]CompiledPackageRecord{otherRecord}))
+			})
+		})
+	})
+
+	Context("when there is no current vm", func() {
+		It("does not save compiled packages", func() {
+			err := repo.Save(record)
+			Expect(err).ToNot(HaveOccurred())
+
+			records, err := repo.List()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(records).To(BeEmpty())
+		})
+	})
+})
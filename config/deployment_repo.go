@@ -34,16 +34,12 @@ func (r deploymentRepo) FindCurrent() (string, bool, error) {
 }
 
 func (r deploymentRepo) UpdateCurrent(manifestSHA1 string) error {
-	deploymentState, err := r.deploymentStateService.Load()
-	if err != nil {
-		return bosherr.WrapError(err, "Loading existing config")
-	}
-
-	deploymentState.CurrentManifestSHA1 = manifestSHA1
-
-	err = r.deploymentStateService.Save(deploymentState)
+	err := r.deploymentStateService.Update(func(deploymentState *DeploymentState) error {
+		deploymentState.CurrentManifestSHA1 = manifestSHA1
+		return nil
+	})
 	if err != nil {
-		return bosherr.WrapError(err, "Saving new config")
+		return bosherr.WrapError(err, "Updating deployment state")
 	}
 	return nil
 }
@@ -5,22 +5,27 @@ import (
 )
 
 type DeploymentState struct {
-	DirectorID          string           `json:"director_id"`
-	InstallationID      string           `json:"installation_id"`
-	CurrentVMCID        string           `json:"current_vm_cid"`
-	CurrentStemcellID   string           `json:"current_stemcell_id"`
-	CurrentDiskID       string           `json:"current_disk_id"`
-	CurrentReleaseIDs   []string         `json:"current_release_ids"`
-	CurrentManifestSHA1 string           `json:"current_manifest_sha1"`
-	Disks               []DiskRecord     `json:"disks"`
-	Stemcells           []StemcellRecord `json:"stemcells"`
-	Releases            []ReleaseRecord  `json:"releases"`
+	DirectorID          string                  `json:"director_id"`
+	InstallationID      string                  `json:"installation_id"`
+	CurrentVMCID        string                  `json:"current_vm_cid"`
+	CurrentAgentID      string                  `json:"current_agent_id,omitempty"`
+	CurrentStemcellID   string                  `json:"current_stemcell_id"`
+	CurrentDiskID       string                  `json:"current_disk_id"`
+	CurrentReleaseIDs   []string                `json:"current_release_ids"`
+	CurrentManifestSHA1 string                  `json:"current_manifest_sha1"`
+	Disks               []DiskRecord            `json:"disks"`
+	Stemcells           []StemcellRecord        `json:"stemcells"`
+	Releases            []ReleaseRecord         `json:"releases"`
+	Blobs               []BlobRecord            `json:"blobs,omitempty"`
+	CompiledPackages    []CompiledPackageRecord `json:"compiled_packages,omitempty"`
+	Registry            *RegistryRecord         `json:"registry,omitempty"`
 }
 
 type StemcellRecord struct {
 	ID      string `json:"id"`
 	Name    string `json:"name"`
 	Version string `json:"version"`
+	OS      string `json:"os,omitempty"`
 	CID     string `json:"cid"`
 }
 
@@ -37,9 +42,40 @@ type ReleaseRecord struct {
 	Version string `json:"version"`
 }
 
+// BlobRecord is a blob known to exist in the blobstore of the agent on the VM with the given CID
+type BlobRecord struct {
+	ID    string `json:"id"`
+	VMCID string `json:"vm_cid"`
+}
+
+// CompiledPackageRecord is a package compiled against a stemcell, stored in the blobstore of the agent on the VM with the given CID
+type CompiledPackageRecord struct {
+	Name        string `json:"name"`
+	Fingerprint string `json:"fingerprint"`
+	Stemcell    string `json:"stemcell"`
+	BlobID      string `json:"blob_id"`
+	SHA1        string `json:"sha1"`
+	VMCID       string `json:"vm_cid"`
+}
+
+// RegistryRecord holds the contents of a persistent registry, so that it can be served after bosh-init exits
+type RegistryRecord struct {
+	Username  string                   `json:"username"`
+	Password  string                   `json:"password"`
+	Instances []RegistryInstanceRecord `json:"instances"`
+}
+
+type RegistryInstanceRecord struct {
+	InstanceID string `json:"instance_id"`
+	Settings   string `json:"settings"`
+}
+
 type DeploymentStateService interface {
 	Path() string
 	Exists() bool
 	Load() (DeploymentState, error)
 	Save(DeploymentState) error
+	// Update loads, changes and saves the deployment state while holding a lock, so that
+	// repos changing it from several goroutines do not overwrite each other's changes
+	Update(func(*DeploymentState) error) error
 }
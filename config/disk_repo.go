@@ -30,32 +30,29 @@ func NewDiskRepo(deploymentStateService DeploymentStateService, uuidGenerator bo
 }
 
 func (r diskRepo) Save(cid string, size int, cloudProperties biproperty.Map) (DiskRecord, error) {
-	config, records, err := r.load()
-	if err != nil {
-		return DiskRecord{}, err
-	}
-
-	oldRecord, found := r.find(records, cid)
-	if found {
-		return DiskRecord{}, bosherr.Errorf("Failed to save disk cid '%s', existing record found '%#v'", cid, oldRecord)
-	}
-
 	newRecord := DiskRecord{
 		CID:             cid,
 		Size:            size,
 		CloudProperties: cloudProperties,
 	}
-	newRecord.ID, err = r.uuidGenerator.Generate()
-	if err != nil {
-		return newRecord, bosherr.WrapError(err, "Generating disk id")
-	}
 
-	records = append(records, newRecord)
-	config.Disks = records
+	err := r.deploymentStateService.Update(func(deploymentState *DeploymentState) error {
+		oldRecord, found := r.find(deploymentState.Disks, cid)
+		if found {
+			return bosherr.Errorf("Failed to save disk cid '%s', existing record found '%#v'", cid, oldRecord)
+		}
+
+		var err error
+		newRecord.ID, err = r.uuidGenerator.Generate()
+		if err != nil {
+			return bosherr.WrapError(err, "Generating disk id")
+		}
 
-	err = r.deploymentStateService.Save(config)
+		deploymentState.Disks = append(deploymentState.Disks, newRecord)
+		return nil
+	})
 	if err != nil {
-		return newRecord, bosherr.WrapError(err, "Saving new config")
+		return newRecord, bosherr.WrapError(err, "Updating deployment state")
 	}
 	return newRecord, nil
 }
@@ -81,26 +78,22 @@ func (r diskRepo) FindCurrent() (DiskRecord, bool, error) {
 }
 
 func (r diskRepo) UpdateCurrent(diskID string) error {
-	deploymentState, err := r.deploymentStateService.Load()
-	if err != nil {
-		return bosherr.WrapError(err, "Loading existing config")
-	}
-
-	found := false
-	for _, oldRecord := range deploymentState.Disks {
-		if oldRecord.ID == diskID {
-			found = true
+	err := r.deploymentStateService.Update(func(deploymentState *DeploymentState) error {
+		found := false
+		for _, oldRecord := range deploymentState.Disks {
+			if oldRecord.ID == diskID {
+				found = true
+			}
+		}
+		if !found {
+			return bosherr.Errorf("Verifying disk record exists with id '%s'", diskID)
 		}
-	}
-	if !found {
-		return bosherr.Errorf("Verifying disk record exists with id '%s'", diskID)
-	}
-
-	deploymentState.CurrentDiskID = diskID
 
-	err = r.deploymentStateService.Save(deploymentState)
+		deploymentState.CurrentDiskID = diskID
+		return nil
+	})
 	if err != nil {
-		return bosherr.WrapError(err, "Saving new config")
+		return bosherr.WrapError(err, "Updating deployment state")
 	}
 	return nil
 }
@@ -125,43 +118,35 @@ func (r diskRepo) All() ([]DiskRecord, error) {
 }
 
 func (r diskRepo) Delete(diskRecord DiskRecord) error {
-	config, records, err := r.load()
-	if err != nil {
-		return err
-	}
-
-	newRecords := []DiskRecord{}
-	for _, record := range records {
-		if record.ID != diskRecord.ID {
-			newRecords = append(newRecords, record)
+	err := r.deploymentStateService.Update(func(deploymentState *DeploymentState) error {
+		newRecords := []DiskRecord{}
+		for _, record := range deploymentState.Disks {
+			if record.ID != diskRecord.ID {
+				newRecords = append(newRecords, record)
+			}
 		}
-	}
 
-	config.Disks = newRecords
+		deploymentState.Disks = newRecords
 
-	if config.CurrentDiskID == diskRecord.ID {
-		config.CurrentDiskID = ""
-	}
-
-	err = r.deploymentStateService.Save(config)
+		if deploymentState.CurrentDiskID == diskRecord.ID {
+			deploymentState.CurrentDiskID = ""
+		}
+		return nil
+	})
 	if err != nil {
-		return bosherr.WrapError(err, "Saving new config")
+		return bosherr.WrapError(err, "Updating deployment state")
 	}
 
 	return nil
 }
 
 func (r diskRepo) ClearCurrent() error {
-	deploymentState, err := r.deploymentStateService.Load()
-	if err != nil {
-		return bosherr.WrapError(err, "Loading existing config")
-	}
-
-	deploymentState.CurrentDiskID = ""
-
-	err = r.deploymentStateService.Save(deploymentState)
+	err := r.deploymentStateService.Update(func(deploymentState *DeploymentState) error {
+		deploymentState.CurrentDiskID = ""
+		return nil
+	})
 	if err != nil {
-		return bosherr.WrapError(err, "Saving new config")
+		return bosherr.WrapError(err, "Updating deployment state")
 	}
 	return nil
 }
@@ -0,0 +1,28 @@
+package fakes
+
+type FakeBlobRepo struct {
+	BlobIDs     map[string]bool
+	ContainsErr error
+
+	SaveInputs []string
+	SaveErr    error
+}
+
+func NewFakeBlobRepo() *FakeBlobRepo {
+	return &FakeBlobRepo{
+		BlobIDs: map[string]bool{},
+	}
+}
+
+func (r *FakeBlobRepo) Contains(blobID string) (bool, error) {
+	return r.BlobIDs[blobID], r.ContainsErr
+}
+
+func (r *FakeBlobRepo) Save(blobID string) error {
+	r.SaveInputs = append(r.SaveInputs, blobID)
+	if r.SaveErr != nil {
+		return r.SaveErr
+	}
+	r.BlobIDs[blobID] = true
+	return nil
+}
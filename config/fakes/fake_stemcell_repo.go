@@ -12,6 +12,7 @@ import (
 type StemcellRepoSaveInput struct {
 	Name    string
 	Version string
+	OS      string
 	CID     string
 }
 
@@ -90,10 +91,11 @@ func (fr *FakeStemcellRepo) All() ([]biconfig.StemcellRecord, error) {
 	return fr.AllStemcellRecords, fr.AllErr
 }
 
-func (fr *FakeStemcellRepo) Save(name, version, cid string) (biconfig.StemcellRecord, error) {
+func (fr *FakeStemcellRepo) Save(name, version, os, cid string) (biconfig.StemcellRecord, error) {
 	input := StemcellRepoSaveInput{
 		Name:    name,
 		Version: version,
+		OS:      os,
 		CID:     cid,
 	}
 	fr.SaveInputs = append(fr.SaveInputs, input)
@@ -111,10 +113,11 @@ func (fr *FakeStemcellRepo) Save(name, version, cid string) (biconfig.StemcellRe
 	return output.stemcellRecord, output.err
 }
 
-func (fr *FakeStemcellRepo) SetSaveBehavior(name, version, cid string, stemcellRecord biconfig.StemcellRecord, err error) error {
+func (fr *FakeStemcellRepo) SetSaveBehavior(name, version, os, cid string, stemcellRecord biconfig.StemcellRecord, err error) error {
 	input := StemcellRepoSaveInput{
 		Name:    name,
 		Version: version,
+		OS:      os,
 		CID:     cid,
 	}
 
@@ -4,10 +4,14 @@ type FakeVMRepo struct {
 	UpdateCurrentCID string
 	UpdateCurrentErr error
 
+	UpdateCurrentAgentIDInput string
+	UpdateCurrentAgentIDErr   error
+
 	ClearCurrentCalled bool
 	ClearCurrentErr    error
 
-	findCurrentOutput vmRepoFindCurrentOutput
+	findCurrentOutput        vmRepoFindCurrentOutput
+	findCurrentAgentIDOutput vmRepoFindCurrentAgentIDOutput
 }
 
 type vmRepoFindCurrentOutput struct {
@@ -16,6 +20,12 @@ type vmRepoFindCurrentOutput struct {
 	err   error
 }
 
+type vmRepoFindCurrentAgentIDOutput struct {
+	agentID string
+	found   bool
+	err     error
+}
+
 func NewFakeVMRepo() *FakeVMRepo {
 	return &FakeVMRepo{}
 }
@@ -37,6 +47,23 @@ func (r *FakeVMRepo) UpdateCurrent(cid string) error {
 	return r.UpdateCurrentErr
 }
 
+func (r *FakeVMRepo) FindCurrentAgentID() (agentID string, found bool, err error) {
+	return r.findCurrentAgentIDOutput.agentID, r.findCurrentAgentIDOutput.found, r.findCurrentAgentIDOutput.err
+}
+
+func (r *FakeVMRepo) SetFindCurrentAgentIDBehavior(agentID string, found bool, err error) {
+	r.findCurrentAgentIDOutput = vmRepoFindCurrentAgentIDOutput{
+		agentID: agentID,
+		found:   found,
+		err:     err,
+	}
+}
+
+func (r *FakeVMRepo) UpdateCurrentAgentID(agentID string) error {
+	r.UpdateCurrentAgentIDInput = agentID
+	return r.UpdateCurrentAgentIDErr
+}
+
 func (r *FakeVMRepo) ClearCurrent() error {
 	r.ClearCurrentCalled = true
 	return r.ClearCurrentErr
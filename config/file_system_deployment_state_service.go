@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
@@ -18,6 +19,7 @@ type fileSystemDeploymentStateService struct {
 	uuidGenerator boshuuid.Generator
 	logger        boshlog.Logger
 	logTag        string
+	lock          sync.Mutex
 }
 
 func NewFileSystemDeploymentStateService(fs boshsys.FileSystem, uuidGenerator boshuuid.Generator, logger boshlog.Logger, deploymentStatePath string) DeploymentStateService {
@@ -35,6 +37,12 @@ func DeploymentStatePath(deploymentManifestPath string) string {
 	return filepath.Join(filepath.Dir(deploymentManifestPath), fmt.Sprintf("%s-state.json", baseFileName))
 }
 
+// ErrandStatePath is where the VM of a dedicated errand is tracked, separately from the deployment's own VM.
+func ErrandStatePath(deploymentManifestPath string, errandName string) string {
+	baseFileName := filepath.Base(strings.TrimSuffix(deploymentManifestPath, filepath.Ext(deploymentManifestPath)))
+	return filepath.Join(filepath.Dir(deploymentManifestPath), fmt.Sprintf("%s-%s-errand-state.json", baseFileName, errandName))
+}
+
 func (s *fileSystemDeploymentStateService) Path() string {
 	return s.configPath
 }
@@ -44,6 +52,37 @@ func (s *fileSystemDeploymentStateService) Exists() bool {
 }
 
 func (s *fileSystemDeploymentStateService) Load() (DeploymentState, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.load()
+}
+
+func (s *fileSystemDeploymentStateService) Save(deploymentState DeploymentState) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.save(deploymentState)
+}
+
+func (s *fileSystemDeploymentStateService) Update(update func(*DeploymentState) error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	deploymentState, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	err = update(&deploymentState)
+	if err != nil {
+		return err
+	}
+
+	return s.save(deploymentState)
+}
+
+func (s *fileSystemDeploymentStateService) load() (DeploymentState, error) {
 	if s.configPath == "" {
 		panic("configPath not yet set!")
 	}
@@ -73,7 +112,7 @@ func (s *fileSystemDeploymentStateService) Load() (DeploymentState, error) {
 	return *deploymentState, nil
 }
 
-func (s *fileSystemDeploymentStateService) Save(deploymentState DeploymentState) error {
+func (s *fileSystemDeploymentStateService) save(deploymentState DeploymentState) error {
 	if s.configPath == "" {
 		panic("configPath not yet set!")
 	}
@@ -101,7 +140,7 @@ func (s *fileSystemDeploymentStateService) initDefaults(deploymentState *Deploym
 		}
 		deploymentState.DirectorID = uuid
 
-		err = s.Save(*deploymentState)
+		err = s.save(*deploymentState)
 		if err != nil {
 			return bosherr.WrapError(err, "Saving deployment state")
 		}
@@ -8,6 +8,8 @@ import (
 
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sync"
 
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 
@@ -41,6 +43,12 @@ var _ = Describe("fileSystemDeploymentStateService", func() {
 		})
 	})
 
+	Describe("ErrandStatePath", func() {
+		It("is based on the manifest path and the errand name", func() {
+			Expect(ErrandStatePath("/path/to/some-manifest.yml", "smoke-tests")).To(Equal("/path/to/some-manifest-smoke-tests-errand-state.json"))
+		})
+	})
+
 	Describe("Exists", func() {
 		It("returns true if the config file exists", func() {
 			fakeFs.WriteFileString(deploymentStatePath, "")
@@ -197,4 +205,62 @@ var _ = Describe("fileSystemDeploymentStateService", func() {
 			})
 		})
 	})
+
+	Describe("Update", func() {
+		It("saves the changes made to the loaded deployment state", func() {
+			err := service.Save(DeploymentState{DirectorID: "fake-director-id", CurrentVMCID: "fake-vm-cid"})
+			Expect(err).ToNot(HaveOccurred())
+
+			err = service.Update(func(deploymentState *DeploymentState) error {
+				Expect(deploymentState.CurrentVMCID).To(Equal("fake-vm-cid"))
+				deploymentState.CurrentDiskID = "fake-disk-id"
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			deploymentState, err := service.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentState.CurrentVMCID).To(Equal("fake-vm-cid"))
+			Expect(deploymentState.CurrentDiskID).To(Equal("fake-disk-id"))
+		})
+
+		It("does not save the deployment state when the update fails", func() {
+			err := service.Save(DeploymentState{DirectorID: "fake-director-id"})
+			Expect(err).ToNot(HaveOccurred())
+
+			err = service.Update(func(deploymentState *DeploymentState) error {
+				deploymentState.CurrentDiskID = "fake-disk-id"
+				return errors.New("fake-update-error")
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-update-error"))
+
+			deploymentState, err := service.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentState.CurrentDiskID).To(BeEmpty())
+		})
+
+		It("does not lose changes made by concurrent updates", func() {
+			err := service.Save(DeploymentState{DirectorID: "fake-director-id"})
+			Expect(err).ToNot(HaveOccurred())
+
+			wg := sync.WaitGroup{}
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					err := service.Update(func(deploymentState *DeploymentState) error {
+						deploymentState.Blobs = append(deploymentState.Blobs, BlobRecord{ID: fmt.Sprintf("fake-blob-id-%d", i)})
+						return nil
+					})
+					Expect(err).ToNot(HaveOccurred())
+				}(i)
+			}
+			wg.Wait()
+
+			deploymentState, err := service.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentState.Blobs).To(HaveLen(10))
+		})
+	})
 })
@@ -28,16 +28,12 @@ func (r releaseRepo) Update(releases []release.Release) error {
 	newRecordIDs := []string{}
 	newRecords := []ReleaseRecord{}
 
-	deploymentState, err := r.deploymentStateService.Load()
-	if err != nil {
-		return bosherr.WrapError(err, "Loading existing config")
-	}
-
 	for _, release := range releases {
 		newRecord := ReleaseRecord{
 			Name:    release.Name(),
 			Version: release.Version(),
 		}
+		var err error
 		newRecord.ID, err = r.uuidGenerator.Generate()
 		if err != nil {
 			return bosherr.WrapError(err, "Generating release id")
@@ -46,9 +42,11 @@ func (r releaseRepo) Update(releases []release.Release) error {
 		newRecordIDs = append(newRecordIDs, newRecord.ID)
 	}
 
-	deploymentState.CurrentReleaseIDs = newRecordIDs
-	deploymentState.Releases = newRecords
-	err = r.deploymentStateService.Save(deploymentState)
+	err := r.deploymentStateService.Update(func(deploymentState *DeploymentState) error {
+		deploymentState.CurrentReleaseIDs = newRecordIDs
+		deploymentState.Releases = newRecords
+		return nil
+	})
 	if err != nil {
 		return bosherr.WrapError(err, "Updating current release record")
 	}
@@ -10,7 +10,7 @@ type StemcellRepo interface {
 	UpdateCurrent(recordID string) error
 	FindCurrent() (StemcellRecord, bool, error)
 	ClearCurrent() error
-	Save(name, version, cid string) (StemcellRecord, error)
+	Save(name, version, os, cid string) (StemcellRecord, error)
 	Find(name, version string) (StemcellRecord, bool, error)
 	All() ([]StemcellRecord, error)
 	Delete(StemcellRecord) error
@@ -28,10 +28,10 @@ func NewStemcellRepo(deploymentStateService DeploymentStateService, uuidGenerato
 	}
 }
 
-func (r stemcellRepo) Save(name, version, cid string) (StemcellRecord, error) {
+func (r stemcellRepo) Save(name, version, os, cid string) (StemcellRecord, error) {
 	stemcellRecord := StemcellRecord{}
 
-	err := r.updateConfig(func(config *DeploymentState) error {
+	err := r.deploymentStateService.Update(func(config *DeploymentState) error {
 		records := config.Stemcells
 		if records == nil {
 			records = []StemcellRecord{}
@@ -40,6 +40,7 @@ func (r stemcellRepo) Save(name, version, cid string) (StemcellRecord, error) {
 		newRecord := StemcellRecord{
 			Name:    name,
 			Version: version,
+			OS:      os,
 			CID:     cid,
 		}
 		var err error
@@ -109,34 +110,26 @@ func (r stemcellRepo) All() ([]StemcellRecord, error) {
 }
 
 func (r stemcellRepo) Delete(stemcellRecord StemcellRecord) error {
-	config, records, err := r.load()
-	if err != nil {
-		return err
-	}
-
-	newRecords := []StemcellRecord{}
-	for _, record := range records {
-		if stemcellRecord.ID != record.ID {
-			newRecords = append(newRecords, record)
+	return r.deploymentStateService.Update(func(config *DeploymentState) error {
+		newRecords := []StemcellRecord{}
+		for _, record := range config.Stemcells {
+			if stemcellRecord.ID != record.ID {
+				newRecords = append(newRecords, record)
+			}
 		}
-	}
 
-	config.Stemcells = newRecords
+		config.Stemcells = newRecords
 
-	if config.CurrentStemcellID == stemcellRecord.ID {
-		config.CurrentStemcellID = ""
-	}
-
-	err = r.deploymentStateService.Save(config)
-	if err != nil {
-		return bosherr.WrapError(err, "Saving config")
-	}
+		if config.CurrentStemcellID == stemcellRecord.ID {
+			config.CurrentStemcellID = ""
+		}
 
-	return nil
+		return nil
+	})
 }
 
 func (r stemcellRepo) UpdateCurrent(recordID string) error {
-	return r.updateConfig(func(config *DeploymentState) error {
+	return r.deploymentStateService.Update(func(config *DeploymentState) error {
 		found := false
 		for _, oldRecord := range config.Stemcells {
 			if oldRecord.ID == recordID {
@@ -154,32 +147,13 @@ func (r stemcellRepo) UpdateCurrent(recordID string) error {
 }
 
 func (r stemcellRepo) ClearCurrent() error {
-	return r.updateConfig(func(config *DeploymentState) error {
+	return r.deploymentStateService.Update(func(config *DeploymentState) error {
 		config.CurrentStemcellID = ""
 
 		return nil
 	})
 }
 
-func (r stemcellRepo) updateConfig(updateFunc func(*DeploymentState) error) error {
-	deploymentState, err := r.deploymentStateService.Load()
-	if err != nil {
-		return bosherr.WrapError(err, "Loading existing config")
-	}
-
-	err = updateFunc(&deploymentState)
-	if err != nil {
-		return err
-	}
-
-	err = r.deploymentStateService.Save(deploymentState)
-	if err != nil {
-		return bosherr.WrapError(err, "Saving new config")
-	}
-
-	return nil
-}
-
 func (r stemcellRepo) load() (DeploymentState, []StemcellRecord, error) {
 	deploymentState, err := r.deploymentStateService.Load()
 	if err != nil {
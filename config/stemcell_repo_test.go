@@ -29,7 +29,7 @@ var _ = Describe("StemcellRepo", func() {
 
 	Describe("Save", func() {
 		It("saves the stemcell record using the config service", func() {
-			_, err := repo.Save("fake-name", "fake-version", "fake-cid")
+			_, err := repo.Save("fake-name", "fake-version", "fake-os", "fake-cid")
 			Expect(err).ToNot(HaveOccurred())
 
 			deploymentState, err := deploymentStateService.Load()
@@ -42,6 +42,7 @@ var _ = Describe("StemcellRepo", func() {
 						ID:      "fake-uuid-1",
 						Name:    "fake-name",
 						Version: "fake-version",
+						OS:      "fake-os",
 						CID:     "fake-cid",
 					},
 				},
@@ -51,7 +52,7 @@ var _ = Describe("StemcellRepo", func() {
 
 		It("returns the stemcell record with a new uuid", func() {
 			fakeUUIDGenerator.GeneratedUUID = "fake-uuid-1"
-			record, err := repo.Save("fake-name", "fake-version-1", "fake-cid-1")
+			record, err := repo.Save("fake-name", "fake-version-1", "", "fake-cid-1")
 			Expect(err).ToNot(HaveOccurred())
 			Expect(record).To(Equal(StemcellRecord{
 				ID:      "fake-uuid-1",
@@ -61,7 +62,7 @@ var _ = Describe("StemcellRepo", func() {
 			}))
 
 			fakeUUIDGenerator.GeneratedUUID = "fake-uuid-2"
-			record, err = repo.Save("fake-name", "fake-version-2", "fake-cid-2")
+			record, err = repo.Save("fake-name", "fake-version-2", "", "fake-cid-2")
 			Expect(err).ToNot(HaveOccurred())
 			Expect(record).To(Equal(StemcellRecord{
 				ID:      "fake-uuid-2",
@@ -73,12 +74,12 @@ var _ = Describe("StemcellRepo", func() {
 
 		Context("when a stemcell record with the same name and version exists", func() {
 			BeforeEach(func() {
-				_, err := repo.Save("fake-name", "fake-version", "fake-cid")
+				_, err := repo.Save("fake-name", "fake-version", "", "fake-cid")
 				Expect(err).ToNot(HaveOccurred())
 			})
 
 			It("returns an error", func() {
-				_, err := repo.Save("fake-name", "fake-version", "fake-cid-2")
+				_, err := repo.Save("fake-name", "fake-version", "", "fake-cid-2")
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("duplicate name/version"))
 			})
@@ -86,12 +87,12 @@ var _ = Describe("StemcellRepo", func() {
 
 		Context("when there stemcell record with the same cid exists (cpi does not garentee cid uniqueness)", func() {
 			BeforeEach(func() {
-				_, err := repo.Save("fake-name-1", "fake-version-1", "fake-cid-1")
+				_, err := repo.Save("fake-name-1", "fake-version-1", "", "fake-cid-1")
 				Expect(err).ToNot(HaveOccurred())
 			})
 
 			It("saves the stemcell record using the config service", func() {
-				_, err := repo.Save("fake-name-2", "fake-version-2", "fake-cid-1")
+				_, err := repo.Save("fake-name-2", "fake-version-2", "", "fake-cid-1")
 				Expect(err).ToNot(HaveOccurred())
 
 				deploymentState, err := deploymentStateService.Load()
@@ -118,7 +119,7 @@ var _ = Describe("StemcellRepo", func() {
 			})
 
 			It("returns the stemcell record with a new uuid", func() {
-				record, err := repo.Save("fake-name-2", "fake-version-2", "fake-cid-1")
+				record, err := repo.Save("fake-name-2", "fake-version-2", "", "fake-cid-1")
 				Expect(err).ToNot(HaveOccurred())
 				Expect(record).To(Equal(StemcellRecord{
 					ID:      "fake-uuid-2",
@@ -133,7 +134,7 @@ var _ = Describe("StemcellRepo", func() {
 	Describe("Find", func() {
 		Context("when a stemcell record with the same name and version exists", func() {
 			BeforeEach(func() {
-				_, err := repo.Save("fake-name", "fake-version", "fake-cid")
+				_, err := repo.Save("fake-name", "fake-version", "", "fake-cid")
 				Expect(err).ToNot(HaveOccurred())
 			})
 
@@ -163,7 +164,7 @@ var _ = Describe("StemcellRepo", func() {
 		Context("when a stemcell record exists with the same ID", func() {
 			BeforeEach(func() {
 				fakeUUIDGenerator.GeneratedUUID = "fake-uuid-1"
-				_, err := repo.Save("fake-name", "fake-version", "fake-cid")
+				_, err := repo.Save("fake-name", "fake-version", "", "fake-cid")
 				Expect(err).ToNot(HaveOccurred())
 			})
 
@@ -181,7 +182,7 @@ var _ = Describe("StemcellRepo", func() {
 		Context("when a stemcell record does not exists with the same ID", func() {
 			BeforeEach(func() {
 				fakeUUIDGenerator.GeneratedUUID = "fake-uuid-1"
-				_, err := repo.Save("fake-name", "fake-version", "fake-cid")
+				_, err := repo.Save("fake-name", "fake-version", "", "fake-cid")
 				Expect(err).ToNot(HaveOccurred())
 			})
 
@@ -197,7 +198,7 @@ var _ = Describe("StemcellRepo", func() {
 		Context("when a stemcell record exists with the same ID", func() {
 			BeforeEach(func() {
 				fakeUUIDGenerator.GeneratedUUID = "fake-uuid-1"
-				_, err := repo.Save("fake-name", "fake-version", "fake-cid")
+				_, err := repo.Save("fake-name", "fake-version", "", "fake-cid")
 				Expect(err).ToNot(HaveOccurred())
 
 				err = repo.UpdateCurrent("fake-uuid-1")
@@ -226,13 +227,13 @@ var _ = Describe("StemcellRepo", func() {
 		BeforeEach(func() {
 			var err error
 			fakeUUIDGenerator.GeneratedUUID = "fake-uuid-1"
-			firstStemcellRecord, err = repo.Save("fake-name1", "fake-version1", "fake-cid1")
+			firstStemcellRecord, err = repo.Save("fake-name1", "fake-version1", "", "fake-cid1")
 			Expect(err).ToNot(HaveOccurred())
 			fakeUUIDGenerator.GeneratedUUID = "fake-uuid-2"
-			secondStemcellRecord, err = repo.Save("fake-name2", "fake-version2", "fake-cid2")
+			secondStemcellRecord, err = repo.Save("fake-name2", "fake-version2", "", "fake-cid2")
 			Expect(err).ToNot(HaveOccurred())
 			fakeUUIDGenerator.GeneratedUUID = "fake-uuid-3"
-			thirdStemcellRecord, err = repo.Save("fake-name3", "fake-version3", "fake-cid3")
+			thirdStemcellRecord, err = repo.Save("fake-name3", "fake-version3", "", "fake-cid3")
 			Expect(err).ToNot(HaveOccurred())
 		})
 
@@ -277,11 +278,11 @@ var _ = Describe("StemcellRepo", func() {
 		Context("when current stemcell exists", func() {
 			BeforeEach(func() {
 				fakeUUIDGenerator.GeneratedUUID = "fake-guid-1"
-				_, err := repo.Save("fake-name", "fake-version-1", "fake-cid-1")
+				_, err := repo.Save("fake-name", "fake-version-1", "", "fake-cid-1")
 				Expect(err).ToNot(HaveOccurred())
 
 				fakeUUIDGenerator.GeneratedUUID = "fake-guid-2"
-				record, err := repo.Save("fake-name", "fake-version-2", "fake-cid-2")
+				record, err := repo.Save("fake-name", "fake-version-2", "", "fake-cid-2")
 				Expect(err).ToNot(HaveOccurred())
 
 				repo.UpdateCurrent(record.ID)
@@ -303,7 +304,7 @@ var _ = Describe("StemcellRepo", func() {
 		Context("when current stemcell does not exist", func() {
 			BeforeEach(func() {
 				fakeUUIDGenerator.GeneratedUUID = "fake-guid-1"
-				_, err := repo.Save("fake-name", "fake-version", "fake-cid")
+				_, err := repo.Save("fake-name", "fake-version", "", "fake-cid")
 				Expect(err).ToNot(HaveOccurred())
 			})
 
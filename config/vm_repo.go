@@ -7,6 +7,8 @@ import (
 type VMRepo interface {
 	FindCurrent() (cid string, found bool, err error)
 	UpdateCurrent(cid string) error
+	FindCurrentAgentID() (agentID string, found bool, err error)
+	UpdateCurrentAgentID(agentID string) error
 	ClearCurrent() error
 }
 
@@ -35,31 +37,49 @@ func (r vMRepo) FindCurrent() (string, bool, error) {
 }
 
 func (r vMRepo) UpdateCurrent(cid string) error {
+	err := r.deploymentStateService.Update(func(deploymentState *DeploymentState) error {
+		deploymentState.CurrentVMCID = cid
+		return nil
+	})
+	if err != nil {
+		return bosherr.WrapError(err, "Updating deployment state")
+	}
+	return nil
+}
+
+func (r vMRepo) FindCurrentAgentID() (string, bool, error) {
 	deploymentState, err := r.deploymentStateService.Load()
 	if err != nil {
-		return bosherr.WrapError(err, "Loading existing config")
+		return "", false, bosherr.WrapError(err, "Loading existing config")
 	}
 
-	deploymentState.CurrentVMCID = cid
+	currentAgentID := deploymentState.CurrentAgentID
+	if currentAgentID != "" {
+		return currentAgentID, true, nil
+	}
 
-	err = r.deploymentStateService.Save(deploymentState)
+	return "", false, nil
+}
+
+func (r vMRepo) UpdateCurrentAgentID(agentID string) error {
+	err := r.deploymentStateService.Update(func(deploymentState *DeploymentState) error {
+		deploymentState.CurrentAgentID = agentID
+		return nil
+	})
 	if err != nil {
-		return bosherr.WrapError(err, "Saving new config")
+		return bosherr.WrapError(err, "Updating deployment state")
 	}
 	return nil
 }
 
 func (r vMRepo) ClearCurrent() error {
-	deploymentState, err := r.deploymentStateService.Load()
-	if err != nil {
-		return bosherr.WrapError(err, "Loading existing config")
-	}
-
-	deploymentState.CurrentVMCID = ""
-
-	err = r.deploymentStateService.Save(deploymentState)
+	err := r.deploymentStateService.Update(func(deploymentState *DeploymentState) error {
+		deploymentState.CurrentVMCID = ""
+		deploymentState.CurrentAgentID = ""
+		return nil
+	})
 	if err != nil {
-		return bosherr.WrapError(err, "Saving new config")
+		return bosherr.WrapError(err, "Updating deployment state")
 	}
 	return nil
 }
@@ -67,9 +67,52 @@ var _ = Describe("VMRepo", func() {
 		})
 	})
 
+	Describe("FindCurrentAgentID", func() {
+		Context("when a current agent id is set", func() {
+			BeforeEach(func() {
+				err := repo.UpdateCurrentAgentID("fake-agent-id")
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns current agent id", func() {
+				agentID, found, err := repo.FindCurrentAgentID()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeTrue())
+				Expect(agentID).To(Equal("fake-agent-id"))
+			})
+		})
+
+		Context("when a current agent id is not set", func() {
+			It("returns false", func() {
+				_, found, err := repo.FindCurrentAgentID()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("UpdateCurrentAgentID", func() {
+		It("updates agent id", func() {
+			err := repo.UpdateCurrentAgentID("fake-agent-id")
+			Expect(err).ToNot(HaveOccurred())
+
+			deploymentState, err := deploymentStateService.Load()
+			Expect(err).ToNot(HaveOccurred())
+
+			expectedConfig := DeploymentState{
+				DirectorID:     "fake-uuid-0",
+				CurrentAgentID: "fake-agent-id",
+			}
+			Expect(deploymentState).To(Equal(expectedConfig))
+		})
+	})
+
 	Describe("ClearCurrent", func() {
 		It("updates vm cid", func() {
-			err := repo.ClearCurrent()
+			err := repo.UpdateCurrentAgentID("fake-agent-id")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = repo.ClearCurrent()
 			Expect(err).ToNot(HaveOccurred())
 
 			deploymentState, err := deploymentStateService.Load()
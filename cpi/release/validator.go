@@ -17,6 +17,10 @@ func NewValidator() Validator {
 }
 
 func (v Validator) Validate(release birel.Release, cpiReleaseJobName string) error {
+	if release.IsCompiled() {
+		return bosherr.Error("CPI release must be a source release, since it is compiled locally")
+	}
+
 	job, ok := release.FindJobByName(cpiReleaseJobName)
 	if !ok {
 		return bosherr.Errorf("CPI release must contain specified job '%s'", cpiReleaseJobName)
@@ -47,6 +47,30 @@ var _ = Describe("Validator", func() {
 		Expect(err).NotTo(HaveOccurred())
 	})
 
+	Context("when the release is compiled", func() {
+		It("returns an error", func() {
+			release := birel.NewRelease(
+				"fake-release-name",
+				"fake-release-version",
+				[]bireljob.Job{
+					{
+						Name: "fake-cpi-release-job-name",
+						Templates: map[string]string{
+							"cpi.erb": "bin/cpi",
+						},
+					},
+				},
+				[]*birelpkg.Package{{Name: "fake-package", Stemcell: "ubuntu-trusty/3012"}},
+				"/some/release/path",
+				fakeFs,
+			)
+
+			err := NewValidator().Validate(release, cpiReleaseJobName)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("CPI release must be a source release"))
+		})
+	})
+
 	Context("when the cpi job is not present", func() {
 		var validator Validator
 		var release birel.Release
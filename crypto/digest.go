@@ -0,0 +1,73 @@
+package crypto
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+)
+
+const (
+	DigestAlgorithmSHA1   = "sha1"
+	DigestAlgorithmSHA256 = "sha256"
+	DigestAlgorithmSHA512 = "sha512"
+)
+
+// Digest is the checksum of an artifact, written as '<algorithm>:<hex>' (e.g. 'sha256:e3b0...').
+// Digests without an algorithm prefix are SHA1, for compatibility with existing manifests.
+type Digest struct {
+	Algorithm string
+	Value     string
+}
+
+// ParseDigest parses an algorithm-prefixed (or bare SHA1) digest.
+// The value must be the hex encoding of a digest of the algorithm (e.g. 64 hex characters for sha256).
+func ParseDigest(digest string) (Digest, error) {
+	algorithm := DigestAlgorithmSHA1
+	value := digest
+
+	if parts := strings.SplitN(digest, ":", 2); len(parts) == 2 {
+		algorithm = strings.ToLower(parts[0])
+		value = parts[1]
+	}
+	value = strings.ToLower(value)
+
+	h, err := newHash(algorithm)
+	if err != nil {
+		return Digest{}, bosherr.Errorf("Unsupported digest algorithm '%s', must be one of sha1, sha256, sha512", algorithm)
+	}
+
+	if value == "" {
+		return Digest{}, bosherr.Errorf("Digest '%s' is missing a value", digest)
+	}
+
+	if _, err := hex.DecodeString(value); err != nil || len(value) != 2*h.Size() {
+		return Digest{}, bosherr.Errorf("Digest '%s' is not a %s digest, which is %d hex characters", digest, algorithm, 2*h.Size())
+	}
+
+	return Digest{Algorithm: algorithm, Value: value}, nil
+}
+
+// String returns the digest in the format it is parsed from, without a prefix for SHA1
+func (d Digest) String() string {
+	if d.Algorithm == DigestAlgorithmSHA1 {
+		return d.Value
+	}
+	return d.Algorithm + ":" + d.Value
+}
+
+func newHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case DigestAlgorithmSHA1:
+		return sha1.New(), nil
+	case DigestAlgorithmSHA256:
+		return sha256.New(), nil
+	case DigestAlgorithmSHA512:
+		return sha512.New(), nil
+	}
+	return nil, bosherr.Errorf("Unsupported digest algorithm '%s'", algorithm)
+}
@@ -0,0 +1,78 @@
+package crypto
+
+import (
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshsys "github.com/cloudfoundry/bosh-agent/system"
+)
+
+// DigestCalculator calculates hex digests of files (or all files in a directory) with a given algorithm
+type DigestCalculator interface {
+	Calculate(filePath string, algorithm string) (string, error)
+}
+
+type digestCalculator struct {
+	fs boshsys.FileSystem
+}
+
+func NewDigestCalculator(fs boshsys.FileSystem) DigestCalculator {
+	return digestCalculator{
+		fs: fs,
+	}
+}
+
+func (c digestCalculator) Calculate(filePath string, algorithm string) (string, error) {
+	h, err := newHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := c.fs.OpenFile(filePath, os.O_RDONLY, 0)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Calculating %s of '%s'", algorithm, filePath)
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Opening file '%s' for %s calculation", filePath, algorithm)
+	}
+
+	if fileInfo.IsDir() {
+		c.fs.Walk(filePath+"/", func(path string, info os.FileInfo, err error) error {
+			if !info.IsDir() {
+				err := c.populateHash(path, h)
+				if err != nil {
+					return bosherr.WrapErrorf(err, "Calculating directory %s for %s", algorithm, path)
+				}
+			}
+			return nil
+		})
+	} else {
+		err = c.populateHash(filePath, h)
+		if err != nil {
+			return "", bosherr.WrapErrorf(err, "Calculating file %s for %s", algorithm, filePath)
+		}
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (c digestCalculator) populateHash(filePath string, hash hash.Hash) error {
+	file, err := c.fs.OpenFile(filePath, os.O_RDONLY, 0)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Opening file '%s' for digest calculation", filePath)
+	}
+	defer file.Close()
+
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return bosherr.WrapError(err, "Copying file for digest calculation")
+	}
+
+	return nil
+}
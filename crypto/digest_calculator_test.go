@@ -0,0 +1,51 @@
+package crypto_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	fakesys "github.com/cloudfoundry/bosh-agent/system/fakes"
+
+	. "github.com/cloudfoundry/bosh-init/crypto"
+)
+
+var _ = Describe("DigestCalculator", func() {
+	var (
+		fs               *fakesys.FakeFileSystem
+		digestCalculator DigestCalculator
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		digestCalculator = NewDigestCalculator(fs)
+
+		fs.RegisterOpenFile("/fake-path", &fakesys.FakeFile{
+			Contents: []byte("fake-contents"),
+			Stats:    &fakesys.FakeFileStats{FileType: fakesys.FakeFileTypeFile},
+		})
+	})
+
+	It("calculates sha1", func() {
+		digest, err := digestCalculator.Calculate("/fake-path", "sha1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(digest).To(Equal("978ad524a02039f261773fe93d94973ae7de6470"))
+	})
+
+	It("calculates sha256", func() {
+		digest, err := digestCalculator.Calculate("/fake-path", "sha256")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(digest).To(Equal("d12d3a3ee8dcdc9e7ea3416fd618298ea50abde2cf434313c6c3edb213f441cd"))
+	})
+
+	It("calculates sha512", func() {
+		digest, err := digestCalculator.Calculate("/fake-path", "sha512")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(digest).To(Equal("ba43776f674aa0e548b155dfffbeb0cec07647bac8502faf0f83651c65abdbb0a3bfe5df6c862766e081bea31cff40a22536413b1c95cb62fddcd62c29bc57b7"))
+	})
+
+	It("returns an error for unsupported algorithms", func() {
+		_, err := digestCalculator.Calculate("/fake-path", "md5")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Unsupported digest algorithm 'md5'"))
+	})
+})
@@ -0,0 +1,62 @@
+package crypto_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-init/crypto"
+)
+
+var _ = Describe("Digest", func() {
+	const (
+		sha256Hex = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+		sha512Hex = "cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e"
+	)
+
+	Describe("ParseDigest", func() {
+		It("parses digests without a prefix as sha1", func() {
+			digest, err := ParseDigest("4603DB250D7B5B78DFE17869649784353177B549")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(digest).To(Equal(Digest{Algorithm: "sha1", Value: "4603db250d7b5b78dfe17869649784353177b549"}))
+			Expect(digest.String()).To(Equal("4603db250d7b5b78dfe17869649784353177b549"))
+		})
+
+		It("parses algorithm-prefixed digests", func() {
+			digest, err := ParseDigest("sha256:" + sha256Hex)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(digest).To(Equal(Digest{Algorithm: "sha256", Value: sha256Hex}))
+			Expect(digest.String()).To(Equal("sha256:" + sha256Hex))
+
+			digest, err = ParseDigest("SHA512:" + sha512Hex)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(digest).To(Equal(Digest{Algorithm: "sha512", Value: sha512Hex}))
+		})
+
+		It("returns an error when the value is not hex", func() {
+			_, err := ParseDigest("sha256:../../" + sha256Hex[6:])
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("is not a sha256 digest, which is 64 hex characters"))
+		})
+
+		It("returns an error when the value has the length of another algorithm", func() {
+			_, err := ParseDigest("sha512:" + sha256Hex)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("is not a sha512 digest, which is 128 hex characters"))
+
+			_, err = ParseDigest(sha256Hex)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("is not a sha1 digest, which is 40 hex characters"))
+		})
+
+		It("returns an error for unsupported algorithms", func() {
+			_, err := ParseDigest("md5:d41d8cd98f00b204e9800998ecf8427e")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unsupported digest algorithm 'md5'"))
+		})
+
+		It("returns an error when the value is missing", func() {
+			_, err := ParseDigest("sha256:")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,20 @@
+package fakes
+
+type FakeDigestCalculator struct {
+	CalculateAlgorithms []string
+	calculateInputs     map[string]CalculateInput
+}
+
+func NewFakeDigestCalculator() *FakeDigestCalculator {
+	return &FakeDigestCalculator{}
+}
+
+func (c *FakeDigestCalculator) Calculate(path string, algorithm string) (string, error) {
+	c.CalculateAlgorithms = append(c.CalculateAlgorithms, algorithm)
+	calculateInput := c.calculateInputs[path]
+	return calculateInput.Sha1, calculateInput.Err
+}
+
+func (c *FakeDigestCalculator) SetCalculateBehavior(calculateInputs map[string]CalculateInput) {
+	c.calculateInputs = calculateInputs
+}
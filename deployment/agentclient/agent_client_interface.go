@@ -1,6 +1,8 @@
 package agentclient
 
 import (
+	"time"
+
 	bias "github.com/cloudfoundry/bosh-init/deployment/applyspec"
 )
 
@@ -14,9 +16,37 @@ type AgentClient interface {
 	UnmountDisk(string) error
 	ListDisk() ([]string, error)
 	MigrateDisk() error
+	Drain(drainType DrainType, newSpecs ...bias.ApplySpec) (int64, error)
+	// RunScript runs a job lifecycle script, cancelling the agent task when it outlives the timeout (when greater than 0)
+	RunScript(scriptName string, options map[string]interface{}, timeout time.Duration) (ScriptResult, error)
+	RunErrand(errandName string) (ErrandResult, error)
+	FetchLogs(logType string, filters []string) (blobID string, err error)
 	CompilePackage(packageSource BlobRef, compiledPackageDependencies []BlobRef) (compiledPackageRef BlobRef, err error)
 }
 
+// DrainType is the reason the agent is asked to run the drain scripts of its jobs.
+type DrainType string
+
+const (
+	DrainTypeUpdate   DrainType = "update"
+	DrainTypeShutdown DrainType = "shutdown"
+	DrainTypeStatus   DrainType = "status"
+)
+
+// ScriptResult is the outcome of running a job lifecycle script (e.g. pre-start) on the agent.
+type ScriptResult struct {
+	ExitStatus int
+	Stdout     string
+	Stderr     string
+}
+
+// ErrandResult is the outcome of running an errand job on the agent.
+type ErrandResult struct {
+	ExitCode int
+	Stdout   string
+	Stderr   string
+}
+
 type AgentState struct {
 	JobState string
 }
@@ -0,0 +1,59 @@
+package agentclient
+
+import (
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	boshtime "github.com/cloudfoundry/bosh-agent/time"
+
+	bias "github.com/cloudfoundry/bosh-init/deployment/applyspec"
+)
+
+// Drainer runs the drain scripts of the jobs on an agent and waits as long as the scripts request.
+type Drainer interface {
+	Drain(drainType DrainType, newSpecs ...bias.ApplySpec) error
+}
+
+type drainer struct {
+	agentClient AgentClient
+	timeService boshtime.Service
+	logger      boshlog.Logger
+	logTag      string
+}
+
+func NewDrainer(agentClient AgentClient, timeService boshtime.Service, logger boshlog.Logger) Drainer {
+	return &drainer{
+		agentClient: agentClient,
+		timeService: timeService,
+		logger:      logger,
+		logTag:      "drainer",
+	}
+}
+
+// Drain sends the drain message to the agent and honors the returned drain value.
+// A non-negative value is the number of seconds to wait before the jobs may be stopped (static drain).
+// A negative value is the number of seconds to wait before asking the agent for the drain status again (dynamic drain).
+func (d *drainer) Drain(drainType DrainType, newSpecs ...bias.ApplySpec) error {
+	drainValue, err := d.agentClient.Drain(drainType, newSpecs...)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Draining jobs with type '%s'", drainType)
+	}
+
+	for drainValue < 0 {
+		waitTime := time.Duration(-drainValue) * time.Second
+		d.logger.Debug(d.logTag, "Dynamic drain requested, checking drain status again in %s", waitTime)
+		d.timeService.Sleep(waitTime)
+
+		drainValue, err = d.agentClient.Drain(DrainTypeStatus)
+		if err != nil {
+			return bosherr.WrapError(err, "Checking drain status")
+		}
+	}
+
+	waitTime := time.Duration(drainValue) * time.Second
+	d.logger.Debug(d.logTag, "Waiting %s for jobs to drain", waitTime)
+	d.timeService.Sleep(waitTime)
+
+	return nil
+}
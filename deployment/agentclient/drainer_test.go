@@ -0,0 +1,108 @@
+package agentclient_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	faketime "github.com/cloudfoundry/bosh-agent/time/fakes"
+	bias "github.com/cloudfoundry/bosh-init/deployment/applyspec"
+
+	fakebiagentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient/fakes"
+
+	. "github.com/cloudfoundry/bosh-init/deployment/agentclient"
+)
+
+var _ = Describe("Drainer", func() {
+	var (
+		fakeAgentClient *fakebiagentclient.FakeAgentClient
+		fakeTimeService *faketime.FakeService
+		drainer         Drainer
+	)
+
+	BeforeEach(func() {
+		fakeAgentClient = fakebiagentclient.NewFakeAgentClient()
+		fakeTimeService = &faketime.FakeService{}
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		drainer = NewDrainer(fakeAgentClient, fakeTimeService, logger)
+	})
+
+	Describe("Drain", func() {
+		It("sends the drain type and new spec to the agent", func() {
+			newSpec := bias.ApplySpec{Deployment: "fake-deployment-name"}
+
+			err := drainer.Drain(DrainTypeUpdate, newSpec)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeAgentClient.DrainInputs).To(Equal([]fakebiagentclient.DrainInput{
+				{DrainType: DrainTypeUpdate, NewSpecs: []bias.ApplySpec{newSpec}},
+			}))
+		})
+
+		Context("when the drain value is static", func() {
+			BeforeEach(func() {
+				fakeAgentClient.SetDrainBehavior(7, nil)
+			})
+
+			It("waits for the number of seconds returned by the agent", func() {
+				err := drainer.Drain(DrainTypeShutdown)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeAgentClient.DrainInputs).To(HaveLen(1))
+				Expect(fakeTimeService.SleepInputs).To(Equal([]time.Duration{7 * time.Second}))
+			})
+		})
+
+		Context("when the drain value is dynamic", func() {
+			BeforeEach(func() {
+				fakeAgentClient.SetDrainBehavior(-10, nil)
+				fakeAgentClient.SetDrainBehavior(-5, nil)
+				fakeAgentClient.SetDrainBehavior(2, nil)
+			})
+
+			It("polls the drain status until the value is not negative, then waits for that value", func() {
+				err := drainer.Drain(DrainTypeShutdown)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeAgentClient.DrainInputs).To(Equal([]fakebiagentclient.DrainInput{
+					{DrainType: DrainTypeShutdown},
+					{DrainType: DrainTypeStatus},
+					{DrainType: DrainTypeStatus},
+				}))
+				Expect(fakeTimeService.SleepInputs).To(Equal([]time.Duration{
+					10 * time.Second,
+					5 * time.Second,
+					2 * time.Second,
+				}))
+			})
+		})
+
+		Context("when draining fails", func() {
+			BeforeEach(func() {
+				fakeAgentClient.SetDrainBehavior(0, errors.New("fake-drain-error"))
+			})
+
+			It("returns an error", func() {
+				err := drainer.Drain(DrainTypeShutdown)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-drain-error"))
+			})
+		})
+
+		Context("when checking the drain status fails", func() {
+			BeforeEach(func() {
+				fakeAgentClient.SetDrainBehavior(-10, nil)
+				fakeAgentClient.SetDrainBehavior(0, errors.New("fake-drain-status-error"))
+			})
+
+			It("returns an error", func() {
+				err := drainer.Drain(DrainTypeShutdown)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-drain-status-error"))
+			})
+		})
+	})
+})
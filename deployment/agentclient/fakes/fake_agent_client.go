@@ -1,6 +1,8 @@
 package fakes
 
 import (
+	"time"
+
 	biagentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient"
 	bias "github.com/cloudfoundry/bosh-init/deployment/applyspec"
 )
@@ -33,6 +35,36 @@ type FakeAgentClient struct {
 
 	MigrateDiskCalledTimes int
 	migrateDiskErr         error
+
+	DrainInputs  []DrainInput
+	drainOutputs []drainOutput
+
+	RunScriptInputs   []RunScriptInput
+	runScriptBehavior map[string]runScriptOutput
+
+	RunErrandInputs []string
+	runErrandResult biagentclient.ErrandResult
+	runErrandErr    error
+
+	FetchLogsInputs []FetchLogsInput
+	fetchLogsBlobID string
+	fetchLogsErr    error
+}
+
+type FetchLogsInput struct {
+	LogType string
+	Filters []string
+}
+
+type RunScriptInput struct {
+	ScriptName string
+	Options    map[string]interface{}
+	Timeout    time.Duration
+}
+
+type DrainInput struct {
+	DrainType biagentclient.DrainType
+	NewSpecs  []bias.ApplySpec
 }
 
 type pingResponse struct {
@@ -45,6 +77,16 @@ type getStateOutput struct {
 	err   error
 }
 
+type drainOutput struct {
+	value int64
+	err   error
+}
+
+type runScriptOutput struct {
+	result biagentclient.ScriptResult
+	err    error
+}
+
 type compilePackageOutput struct {
 	blobRef biagentclient.BlobRef
 	err     error
@@ -52,7 +94,8 @@ type compilePackageOutput struct {
 
 func NewFakeAgentClient() *FakeAgentClient {
 	return &FakeAgentClient{
-		getStateOutputs: []getStateOutput{},
+		getStateOutputs:   []getStateOutput{},
+		runScriptBehavior: map[string]runScriptOutput{},
 	}
 }
 
@@ -114,6 +157,45 @@ func (c *FakeAgentClient) MigrateDisk() error {
 	return c.migrateDiskErr
 }
 
+func (c *FakeAgentClient) Drain(drainType biagentclient.DrainType, newSpecs ...bias.ApplySpec) (int64, error) {
+	c.DrainInputs = append(c.DrainInputs, DrainInput{
+		DrainType: drainType,
+		NewSpecs:  newSpecs,
+	})
+
+	if len(c.drainOutputs) > 0 {
+		drainReturn := c.drainOutputs[0]
+		c.drainOutputs = c.drainOutputs[1:]
+		return drainReturn.value, drainReturn.err
+	}
+
+	return 0, nil
+}
+
+func (c *FakeAgentClient) RunScript(scriptName string, options map[string]interface{}, timeout time.Duration) (biagentclient.ScriptResult, error) {
+	c.RunScriptInputs = append(c.RunScriptInputs, RunScriptInput{
+		ScriptName: scriptName,
+		Options:    options,
+		Timeout:    timeout,
+	})
+
+	output := c.runScriptBehavior[scriptName]
+	return output.result, output.err
+}
+
+func (c *FakeAgentClient) RunErrand(errandName string) (biagentclient.ErrandResult, error) {
+	c.RunErrandInputs = append(c.RunErrandInputs, errandName)
+	return c.runErrandResult, c.runErrandErr
+}
+
+func (c *FakeAgentClient) FetchLogs(logType string, filters []string) (string, error) {
+	c.FetchLogsInputs = append(c.FetchLogsInputs, FetchLogsInput{
+		LogType: logType,
+		Filters: filters,
+	})
+	return c.fetchLogsBlobID, c.fetchLogsErr
+}
+
 func (c *FakeAgentClient) CompilePackage(
 	packageSource biagentclient.BlobRef,
 	compiledPackageDependencies []biagentclient.BlobRef,
@@ -158,6 +240,30 @@ func (c *FakeAgentClient) SetMigrateDiskBehavior(err error) {
 	c.migrateDiskErr = err
 }
 
+func (c *FakeAgentClient) SetDrainBehavior(value int64, err error) {
+	c.drainOutputs = append(c.drainOutputs, drainOutput{
+		value: value,
+		err:   err,
+	})
+}
+
+func (c *FakeAgentClient) SetRunScriptBehavior(scriptName string, result biagentclient.ScriptResult, err error) {
+	c.runScriptBehavior[scriptName] = runScriptOutput{
+		result: result,
+		err:    err,
+	}
+}
+
+func (c *FakeAgentClient) SetRunErrandBehavior(result biagentclient.ErrandResult, err error) {
+	c.runErrandResult = result
+	c.runErrandErr = err
+}
+
+func (c *FakeAgentClient) SetFetchLogsBehavior(blobID string, err error) {
+	c.fetchLogsBlobID = blobID
+	c.fetchLogsErr = err
+}
+
 func (c *FakeAgentClient) SetListDiskBehavior(disks []string, err error) {
 	c.listDiskDisks = disks
 	c.listDiskErr = err
@@ -2,19 +2,31 @@ package http
 
 import (
 	"fmt"
+	"os"
+	"os/signal"
 	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
-	boshretry "github.com/cloudfoundry/bosh-agent/retrystrategy"
+	boshtime "github.com/cloudfoundry/bosh-agent/time"
 	biagentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient"
 	bias "github.com/cloudfoundry/bosh-init/deployment/applyspec"
 	bihttpclient "github.com/cloudfoundry/bosh-init/deployment/httpclient"
 )
 
+// taskProgressInterval is how often the elapsed time of a long-running agent task is reported
+const taskProgressInterval = 1 * time.Minute
+
+type agentRequester interface {
+	Send(method string, arguments []interface{}, response Response) error
+}
+
 type agentClient struct {
-	agentRequest agentRequest
+	agentRequest agentRequester
 	getTaskDelay time.Duration
+	taskTimeouts biagentclient.TaskTimeouts
+	timeService  boshtime.Service
+	taskProgress biagentclient.TaskProgressFunc
 	logger       boshlog.Logger
 	logTag       string
 }
@@ -23,7 +35,10 @@ func NewAgentClient(
 	endpoint string,
 	directorID string,
 	getTaskDelay time.Duration,
+	taskTimeouts biagentclient.TaskTimeouts,
 	httpClient bihttpclient.HTTPClient,
+	timeService boshtime.Service,
+	taskProgress biagentclient.TaskProgressFunc,
 	logger boshlog.Logger,
 ) biagentclient.AgentClient {
 	// if this were NATS, we would need the agentID, but since it's http, the endpoint is unique to the agent
@@ -33,11 +48,26 @@ func NewAgentClient(
 		endpoint:   agentEndpoint,
 		httpClient: httpClient,
 	}
+	return newAgentClient(agentRequest, getTaskDelay, taskTimeouts, timeService, taskProgress, logger, "httpAgentClient")
+}
+
+func newAgentClient(
+	agentRequest agentRequester,
+	getTaskDelay time.Duration,
+	taskTimeouts biagentclient.TaskTimeouts,
+	timeService boshtime.Service,
+	taskProgress biagentclient.TaskProgressFunc,
+	logger boshlog.Logger,
+	logTag string,
+) biagentclient.AgentClient {
 	return &agentClient{
 		agentRequest: agentRequest,
 		getTaskDelay: getTaskDelay,
+		taskTimeouts: taskTimeouts,
+		timeService:  timeService,
+		taskProgress: taskProgress,
 		logger:       logger,
-		logTag:       "httpAgentClient",
+		logTag:       logTag,
 	}
 }
 
@@ -113,7 +143,117 @@ func (c *agentClient) MigrateDisk() error {
 	return err
 }
 
-func (c *agentClient) sendAsyncTaskMessage(method string, arguments []interface{}) (value map[string]interface{}, err error) {
+func (c *agentClient) Drain(drainType biagentclient.DrainType, newSpecs ...bias.ApplySpec) (int64, error) {
+	args := []interface{}{string(drainType)}
+	for _, newSpec := range newSpecs {
+		args = append(args, newSpec)
+	}
+
+	responseValue, err := c.sendAsyncTaskMessage("drain", args)
+	if err != nil {
+		return 0, err
+	}
+
+	drainValue, ok := responseValue.(float64)
+	if !ok {
+		return 0, bosherr.Errorf("Unable to parse 'drain' response from the agent: %#v", responseValue)
+	}
+
+	return int64(drainValue), nil
+}
+
+func (c *agentClient) RunScript(scriptName string, options map[string]interface{}, timeout time.Duration) (biagentclient.ScriptResult, error) {
+	if timeout <= 0 {
+		timeout = c.taskTimeouts.For("run_script")
+	}
+
+	responseValue, err := c.sendAsyncTaskMessageWithTimeout("run_script", []interface{}{scriptName, options}, timeout)
+	if err != nil {
+		return biagentclient.ScriptResult{}, err
+	}
+
+	// agents that do not report script output respond with a plain status string
+	if _, ok := responseValue.(string); ok || responseValue == nil {
+		return biagentclient.ScriptResult{}, nil
+	}
+
+	responseMap, ok := responseValue.(map[string]interface{})
+	if !ok {
+		return biagentclient.ScriptResult{}, bosherr.Errorf("Unable to parse 'run_script' response from the agent: %#v", responseValue)
+	}
+
+	result := biagentclient.ScriptResult{}
+	if exitStatus, ok := responseMap["exit_status"].(float64); ok {
+		result.ExitStatus = int(exitStatus)
+	}
+	if stdout, ok := responseMap["stdout"].(string); ok {
+		result.Stdout = stdout
+	}
+	if stderr, ok := responseMap["stderr"].(string); ok {
+		result.Stderr = stderr
+	}
+
+	return result, nil
+}
+
+func (c *agentClient) RunErrand(errandName string) (biagentclient.ErrandResult, error) {
+	responseValue, err := c.sendAsyncTaskMessage("run_errand", []interface{}{errandName})
+	if err != nil {
+		return biagentclient.ErrandResult{}, err
+	}
+
+	responseMap, ok := responseValue.(map[string]interface{})
+	if !ok {
+		return biagentclient.ErrandResult{}, bosherr.Errorf("Unable to parse 'run_errand' response from the agent: %#v", responseValue)
+	}
+
+	exitCode, ok := responseMap["exit_code"].(float64)
+	if !ok {
+		return biagentclient.ErrandResult{}, bosherr.Errorf("Unable to parse 'run_errand' response from the agent: %#v", responseValue)
+	}
+
+	result := biagentclient.ErrandResult{
+		ExitCode: int(exitCode),
+	}
+	if stdout, ok := responseMap["stdout"].(string); ok {
+		result.Stdout = stdout
+	}
+	if stderr, ok := responseMap["stderr"].(string); ok {
+		result.Stderr = stderr
+	}
+
+	return result, nil
+}
+
+func (c *agentClient) FetchLogs(logType string, filters []string) (string, error) {
+	responseValue, err := c.sendAsyncTaskMessage("fetch_logs", []interface{}{logType, filters})
+	if err != nil {
+		return "", err
+	}
+
+	responseMap, ok := responseValue.(map[string]interface{})
+	if !ok {
+		return "", bosherr.Errorf("Unable to parse 'fetch_logs' response from the agent: %#v", responseValue)
+	}
+
+	blobID, ok := responseMap["blobstore_id"].(string)
+	if !ok {
+		return "", bosherr.Errorf("Unable to parse 'fetch_logs' response from the agent: %#v", responseValue)
+	}
+
+	return blobID, nil
+}
+
+// sendAsyncTaskMessage sends the message and polls get_task until the agent task finishes,
+// within the timeout configured for the method
+func (c *agentClient) sendAsyncTaskMessage(method string, arguments []interface{}) (value interface{}, err error) {
+	return c.sendAsyncTaskMessageWithTimeout(method, arguments, c.taskTimeouts.For(method))
+}
+
+// sendAsyncTaskMessageWithTimeout sends the message and polls get_task until the agent task finishes.
+// The task is cancelled on the agent when it outlives the timeout (0 means no limit)
+// or when the user interrupts bosh-init while waiting.
+func (c *agentClient) sendAsyncTaskMessageWithTimeout(method string, arguments []interface{}, timeout time.Duration) (value interface{}, err error) {
 	var response TaskResponse
 	err = c.agentRequest.Send(method, arguments, &response)
 	if err != nil {
@@ -125,34 +265,65 @@ func (c *agentClient) sendAsyncTaskMessage(method string, arguments []interface{
 		return value, bosherr.WrapError(err, "Getting agent task id")
 	}
 
-	getTaskRetryable := boshretry.NewRetryable(func() (bool, error) {
+	interruptCh := make(chan os.Signal, 1)
+	signal.Notify(interruptCh, os.Interrupt)
+	defer signal.Stop(interruptCh)
+
+	startTime := c.timeService.Now()
+	var progressElapsed time.Duration
+
+	for {
 		var response TaskResponse
 		err = c.agentRequest.Send("get_task", []interface{}{agentTaskID}, &response)
 		if err != nil {
-			return false, bosherr.WrapError(err, "Sending 'get_task' to the agent")
+			return value, bosherr.WrapError(err, "Sending 'get_task' to the agent")
 		}
 
 		c.logger.Debug(c.logTag, "get_task response value: %#v", response.Value)
 
 		taskState, err := response.TaskState()
 		if err != nil {
-			return false, bosherr.WrapError(err, "Getting task state")
+			return value, bosherr.WrapError(err, "Getting task state")
 		}
 
 		if taskState != "running" {
-			var ok bool
-			value, ok = response.Value.(map[string]interface{})
-			if !ok {
-				c.logger.Warn(c.logTag, "Unable to parse get_task response value: %#v", response.Value)
+			return response.Value, nil
+		}
+
+		elapsed := c.timeService.Now().Sub(startTime)
+		if timeout > 0 && elapsed >= timeout {
+			c.cancelTask(agentTaskID)
+			return value, bosherr.Errorf("Timed out after %s waiting for agent task '%s' (%s) to finish", timeout, agentTaskID, method)
+		}
+
+		if elapsed-progressElapsed >= taskProgressInterval {
+			progressElapsed = elapsed
+			c.logger.Info(c.logTag, "Agent task '%s' (%s) still running after %s", agentTaskID, method, elapsed)
+			if c.taskProgress != nil {
+				c.taskProgress(method, elapsed)
 			}
-			return true, nil
 		}
 
-		return true, bosherr.Errorf("Task %s is still running", method)
-	})
+		select {
+		case <-interruptCh:
+			c.cancelTask(agentTaskID)
+			return value, bosherr.Errorf("Interrupted while waiting for agent task '%s' (%s) to finish", agentTaskID, method)
+		default:
+		}
+
+		c.timeService.Sleep(c.getTaskDelay)
+	}
+}
+
+// cancelTask asks the agent to stop the task, the task may still run to completion if the agent does not support cancelling it
+func (c *agentClient) cancelTask(agentTaskID string) {
+	c.logger.Info(c.logTag, "Cancelling agent task '%s'", agentTaskID)
 
-	getTaskRetryStrategy := boshretry.NewUnlimitedRetryStrategy(c.getTaskDelay, getTaskRetryable, c.logger)
-	return value, getTaskRetryStrategy.Try()
+	var response SimpleTaskResponse
+	err := c.agentRequest.Send("cancel_task", []interface{}{agentTaskID}, &response)
+	if err != nil {
+		c.logger.Warn(c.logTag, "Failed to cancel agent task '%s': %s", agentTaskID, err.Error())
+	}
 }
 
 func (c *agentClient) CompilePackage(packageSource biagentclient.BlobRef, compiledPackageDependencies []biagentclient.BlobRef) (compiledPackageRef biagentclient.BlobRef, err error) {
@@ -179,7 +350,12 @@ func (c *agentClient) CompilePackage(packageSource biagentclient.BlobRef, compil
 		return biagentclient.BlobRef{}, bosherr.WrapError(err, "Sending 'compile_package' to the agent")
 	}
 
-	result, ok := responseValue["result"].(map[string]interface{})
+	responseMap, ok := responseValue.(map[string]interface{})
+	if !ok {
+		return biagentclient.BlobRef{}, bosherr.Errorf("Unable to parse 'compile_package' response from the agent: %#v", responseValue)
+	}
+
+	result, ok := responseMap["result"].(map[string]interface{})
 	if !ok {
 		return biagentclient.BlobRef{}, bosherr.Errorf("Unable to parse 'compile_package' response from the agent: %#v", responseValue)
 	}
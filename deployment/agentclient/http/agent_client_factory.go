@@ -4,30 +4,50 @@ import (
 	"time"
 
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	boshtime "github.com/cloudfoundry/bosh-agent/time"
 	biagentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient"
+	binats "github.com/cloudfoundry/bosh-init/deployment/agentclient/nats"
 	bihttpclient "github.com/cloudfoundry/bosh-init/deployment/httpclient"
 )
 
 type AgentClientFactory interface {
-	NewAgentClient(directorID, mbusURL string) biagentclient.AgentClient
+	NewAgentClient(directorID, mbusURL string, agentIDRepo binats.AgentIDRepo, taskTimeouts biagentclient.TaskTimeouts) biagentclient.AgentClient
 }
 
 type agentClientFactory struct {
-	getTaskDelay time.Duration
-	logger       boshlog.Logger
+	getTaskDelay        time.Duration
+	natsResponseTimeout time.Duration
+	natsServer          binats.Server
+	timeService         boshtime.Service
+	taskProgress        biagentclient.TaskProgressFunc
+	logger              boshlog.Logger
 }
 
 func NewAgentClientFactory(
 	getTaskDelay time.Duration,
+	natsServer binats.Server,
+	timeService boshtime.Service,
+	taskProgress biagentclient.TaskProgressFunc,
 	logger boshlog.Logger,
 ) AgentClientFactory {
 	return &agentClientFactory{
-		getTaskDelay: getTaskDelay,
-		logger:       logger,
+		getTaskDelay:        getTaskDelay,
+		natsResponseTimeout: 45 * time.Second,
+		natsServer:          natsServer,
+		timeService:         timeService,
+		taskProgress:        taskProgress,
+		logger:              logger,
 	}
 }
 
-func (f *agentClientFactory) NewAgentClient(directorID, mbusURL string) biagentclient.AgentClient {
+func (f *agentClientFactory) NewAgentClient(directorID, mbusURL string, agentIDRepo binats.AgentIDRepo, taskTimeouts biagentclient.TaskTimeouts) biagentclient.AgentClient {
+	if binats.IsNATSURL(mbusURL) {
+		agentRequest := bodyAgentRequest{
+			requester: binats.NewAgentRequest(f.natsServer, directorID, agentIDRepo, f.natsResponseTimeout, f.logger),
+		}
+		return newAgentClient(agentRequest, f.getTaskDelay, taskTimeouts, f.timeService, f.taskProgress, f.logger, "natsAgentClient")
+	}
+
 	httpClient := bihttpclient.NewHTTPClient(bihttpclient.DefaultClient, f.logger)
-	return NewAgentClient(mbusURL, directorID, f.getTaskDelay, httpClient, f.logger)
+	return NewAgentClient(mbusURL, directorID, f.getTaskDelay, taskTimeouts, httpClient, f.timeService, f.taskProgress, f.logger)
 }
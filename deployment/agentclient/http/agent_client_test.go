@@ -3,6 +3,7 @@ package http_test
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	. "github.com/cloudfoundry/bosh-init/deployment/agentclient/http"
 
@@ -10,21 +11,93 @@ import (
 	. "github.com/onsi/gomega"
 
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	faketime "github.com/cloudfoundry/bosh-agent/time/fakes"
 	biagentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient"
 	bias "github.com/cloudfoundry/bosh-init/deployment/applyspec"
 	fakebihttpclient "github.com/cloudfoundry/bosh-init/deployment/httpclient/fakes"
 )
 
+type taskProgressCall struct {
+	Action  string
+	Elapsed time.Duration
+}
+
 var _ = Describe("AgentClient", func() {
 	var (
-		fakeHTTPClient *fakebihttpclient.FakeHTTPClient
-		agentClient    biagentclient.AgentClient
+		fakeHTTPClient  *fakebihttpclient.FakeHTTPClient
+		fakeTimeService *faketime.FakeService
+		taskProgress    biagentclient.TaskProgressFunc
+		progressCalls   []taskProgressCall
+		logger          boshlog.Logger
+		agentClient     biagentclient.AgentClient
 	)
 
 	BeforeEach(func() {
-		logger := boshlog.NewLogger(boshlog.LevelNone)
+		logger = boshlog.NewLogger(boshlog.LevelNone)
 		fakeHTTPClient = fakebihttpclient.NewFakeHTTPClient()
-		agentClient = NewAgentClient("http://localhost:6305", "fake-uuid", 0, fakeHTTPClient, logger)
+		fakeTimeService = &faketime.FakeService{}
+		progressCalls = []taskProgressCall{}
+		taskProgress = func(action string, elapsed time.Duration) {
+			progressCalls = append(progressCalls, taskProgressCall{Action: action, Elapsed: elapsed})
+		}
+		agentClient = NewAgentClient("http://localhost:6305", "fake-uuid", 0, biagentclient.TaskTimeouts{}, fakeHTTPClient, fakeTimeService, taskProgress, logger)
+	})
+
+	Describe("waiting for agent tasks", func() {
+		var startTime time.Time
+
+		BeforeEach(func() {
+			startTime = time.Now()
+			agentClient = NewAgentClient("http://localhost:6305", "fake-uuid", 0, biagentclient.TaskTimeouts{"stop": 10 * time.Minute}, fakeHTTPClient, fakeTimeService, taskProgress, logger)
+
+			fakeHTTPClient.SetPostBehavior(`{"value":{"agent_task_id":"fake-agent-task-id","state":"running"}}`, 200, nil)
+			fakeHTTPClient.SetPostBehavior(`{"value":{"agent_task_id":"fake-agent-task-id","state":"running"}}`, 200, nil)
+			fakeHTTPClient.SetPostBehavior(`{"value":{"agent_task_id":"fake-agent-task-id","state":"running"}}`, 200, nil)
+		})
+
+		Context("when the task finishes before the timeout", func() {
+			BeforeEach(func() {
+				fakeTimeService.NowTimes = []time.Time{startTime, startTime.Add(90 * time.Second), startTime.Add(2 * time.Minute)}
+				fakeHTTPClient.SetPostBehavior(`{"value":"stopped"}`, 200, nil)
+			})
+
+			It("reports the elapsed time of the running task", func() {
+				err := agentClient.Stop()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(progressCalls).To(Equal([]taskProgressCall{{Action: "stop", Elapsed: 90 * time.Second}}))
+			})
+		})
+
+		Context("when the task is still running after the timeout", func() {
+			BeforeEach(func() {
+				fakeTimeService.NowTimes = []time.Time{startTime, startTime.Add(1 * time.Second), startTime.Add(10 * time.Minute)}
+				fakeHTTPClient.SetPostBehavior(`{"value":"canceled"}`, 200, nil)
+			})
+
+			It("cancels the task on the agent", func() {
+				err := agentClient.Stop()
+				Expect(err).To(HaveOccurred())
+
+				Expect(fakeHTTPClient.PostInputs).To(HaveLen(4))
+
+				var request AgentRequestMessage
+				err = json.Unmarshal(fakeHTTPClient.PostInputs[3].Payload, &request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(request).To(Equal(AgentRequestMessage{
+					Method:    "cancel_task",
+					Arguments: []interface{}{"fake-agent-task-id"},
+					ReplyTo:   "fake-uuid",
+				}))
+			})
+
+			It("returns an error", func() {
+				err := agentClient.Stop()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Timed out after 10m0s waiting for agent task 'fake-agent-task-id' (stop) to finish"))
+			})
+		})
 	})
 
 	Describe("Ping", func() {
@@ -567,6 +640,262 @@ var _ = Describe("AgentClient", func() {
 		})
 	})
 
+	Describe("Drain", func() {
+		Context("when agent responds with a value", func() {
+			BeforeEach(func() {
+				fakeHTTPClient.SetPostBehavior(`{"value":{"agent_task_id":"fake-agent-task-id","state":"running"}}`, 200, nil)
+				fakeHTTPClient.SetPostBehavior(`{"value":{"agent_task_id":"fake-agent-task-id","state":"running"}}`, 200, nil)
+				fakeHTTPClient.SetPostBehavior(`{"value":-15}`, 200, nil)
+			})
+
+			It("makes a POST request to the endpoint", func() {
+				_, err := agentClient.Drain(biagentclient.DrainTypeShutdown)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeHTTPClient.PostInputs).To(HaveLen(3))
+				Expect(fakeHTTPClient.PostInputs[0].Endpoint).To(Equal("http://localhost:6305/agent"))
+
+				var request AgentRequestMessage
+				err = json.Unmarshal(fakeHTTPClient.PostInputs[0].Payload, &request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(request).To(Equal(AgentRequestMessage{
+					Method:    "drain",
+					Arguments: []interface{}{"shutdown"},
+					ReplyTo:   "fake-uuid",
+				}))
+			})
+
+			It("sends the new spec when draining for an update", func() {
+				spec := bias.ApplySpec{
+					Deployment: "fake-deployment-name",
+				}
+				_, err := agentClient.Drain(biagentclient.DrainTypeUpdate, spec)
+				Expect(err).ToNot(HaveOccurred())
+
+				var request AgentRequestMessage
+				err = json.Unmarshal(fakeHTTPClient.PostInputs[0].Payload, &request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(request.Method).To(Equal("drain"))
+				Expect(request.Arguments).To(HaveLen(2))
+				Expect(request.Arguments[0]).To(Equal("update"))
+				Expect(request.Arguments[1]).To(HaveKeyWithValue("deployment", "fake-deployment-name"))
+			})
+
+			It("waits for the task to be finished and returns the drain value", func() {
+				drainValue, err := agentClient.Drain(biagentclient.DrainTypeShutdown)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(drainValue).To(Equal(int64(-15)))
+
+				var request AgentRequestMessage
+				err = json.Unmarshal(fakeHTTPClient.PostInputs[1].Payload, &request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(request).To(Equal(AgentRequestMessage{
+					Method:    "get_task",
+					Arguments: []interface{}{"fake-agent-task-id"},
+					ReplyTo:   "fake-uuid",
+				}))
+			})
+		})
+
+		Context("when agent responds with a value that is not a number", func() {
+			BeforeEach(func() {
+				fakeHTTPClient.SetPostBehavior(`{"value":{"agent_task_id":"fake-agent-task-id","state":"running"}}`, 200, nil)
+				fakeHTTPClient.SetPostBehavior(`{"value":"fake-drain-value"}`, 200, nil)
+			})
+
+			It("returns an error", func() {
+				_, err := agentClient.Drain(biagentclient.DrainTypeShutdown)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Unable to parse 'drain' response"))
+			})
+		})
+
+		Context("when agent responds with exception", func() {
+			BeforeEach(func() {
+				fakeHTTPClient.SetPostBehavior(`{"exception":{"message":"bad request"}}`, 200, nil)
+			})
+
+			It("returns an error", func() {
+				_, err := agentClient.Drain(biagentclient.DrainTypeShutdown)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("bad request"))
+			})
+		})
+	})
+
+	Describe("RunScript", func() {
+		Context("when agent responds with the script output", func() {
+			BeforeEach(func() {
+				fakeHTTPClient.SetPostBehavior(`{"value":{"agent_task_id":"fake-agent-task-id","state":"running"}}`, 200, nil)
+				fakeHTTPClient.SetPostBehavior(`{"value":{"exit_status":1,"stdout":"fake-stdout","stderr":"fake-stderr"}}`, 200, nil)
+			})
+
+			It("makes a POST request to the endpoint", func() {
+				_, err := agentClient.RunScript("pre-start", map[string]interface{}{}, 0)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeHTTPClient.PostInputs).To(HaveLen(2))
+				Expect(fakeHTTPClient.PostInputs[0].Endpoint).To(Equal("http://localhost:6305/agent"))
+
+				var request AgentRequestMessage
+				err = json.Unmarshal(fakeHTTPClient.PostInputs[0].Payload, &request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(request).To(Equal(AgentRequestMessage{
+					Method:    "run_script",
+					Arguments: []interface{}{"pre-start", map[string]interface{}{}},
+					ReplyTo:   "fake-uuid",
+				}))
+			})
+
+			It("returns the script result", func() {
+				result, err := agentClient.RunScript("pre-start", map[string]interface{}{}, 0)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result).To(Equal(biagentclient.ScriptResult{
+					ExitStatus: 1,
+					Stdout:     "fake-stdout",
+					Stderr:     "fake-stderr",
+				}))
+			})
+		})
+
+		Context("when the script is still running after the given timeout", func() {
+			BeforeEach(func() {
+				startTime := time.Now()
+				fakeTimeService.NowTimes = []time.Time{startTime, startTime.Add(1 * time.Second), startTime.Add(5 * time.Minute)}
+				fakeHTTPClient.SetPostBehavior(`{"value":{"agent_task_id":"fake-agent-task-id","state":"running"}}`, 200, nil)
+				fakeHTTPClient.SetPostBehavior(`{"value":{"agent_task_id":"fake-agent-task-id","state":"running"}}`, 200, nil)
+				fakeHTTPClient.SetPostBehavior(`{"value":{"agent_task_id":"fake-agent-task-id","state":"running"}}`, 200, nil)
+				fakeHTTPClient.SetPostBehavior(`{"value":"canceled"}`, 200, nil)
+			})
+
+			It("cancels the script task on the agent and returns an error", func() {
+				_, err := agentClient.RunScript("post-start", map[string]interface{}{}, 5*time.Minute)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Timed out after 5m0s waiting for agent task 'fake-agent-task-id' (run_script) to finish"))
+
+				Expect(fakeHTTPClient.PostInputs).To(HaveLen(4))
+
+				var request AgentRequestMessage
+				err = json.Unmarshal(fakeHTTPClient.PostInputs[3].Payload, &request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(request.Method).To(Equal("cancel_task"))
+			})
+		})
+
+		Context("when agent responds with a status string", func() {
+			BeforeEach(func() {
+				fakeHTTPClient.SetPostBehavior(`{"value":{"agent_task_id":"fake-agent-task-id","state":"running"}}`, 200, nil)
+				fakeHTTPClient.SetPostBehavior(`{"value":"executed"}`, 200, nil)
+			})
+
+			It("returns a successful empty result", func() {
+				result, err := agentClient.RunScript("post-start", map[string]interface{}{}, 0)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result).To(Equal(biagentclient.ScriptResult{}))
+			})
+		})
+
+		Context("when agent responds with exception", func() {
+			BeforeEach(func() {
+				fakeHTTPClient.SetPostBehavior(`{"exception":{"message":"bad request"}}`, 200, nil)
+			})
+
+			It("returns an error", func() {
+				_, err := agentClient.RunScript("pre-start", map[string]interface{}{}, 0)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("bad request"))
+			})
+		})
+	})
+
+	Describe("RunErrand", func() {
+		Context("when agent responds with the errand result", func() {
+			BeforeEach(func() {
+				fakeHTTPClient.SetPostBehavior(`{"value":{"agent_task_id":"fake-agent-task-id","state":"running"}}`, 200, nil)
+				fakeHTTPClient.SetPostBehavior(`{"value":{"exit_code":3,"stdout":"fake-stdout","stderr":"fake-stderr"}}`, 200, nil)
+			})
+
+			It("makes a POST request to the endpoint", func() {
+				_, err := agentClient.RunErrand("fake-errand-name")
+				Expect(err).ToNot(HaveOccurred())
+
+				var request AgentRequestMessage
+				err = json.Unmarshal(fakeHTTPClient.PostInputs[0].Payload, &request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(request).To(Equal(AgentRequestMessage{
+					Method:    "run_errand",
+					Arguments: []interface{}{"fake-errand-name"},
+					ReplyTo:   "fake-uuid",
+				}))
+			})
+
+			It("returns the errand result", func() {
+				result, err := agentClient.RunErrand("fake-errand-name")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result).To(Equal(biagentclient.ErrandResult{
+					ExitCode: 3,
+					Stdout:   "fake-stdout",
+					Stderr:   "fake-stderr",
+				}))
+			})
+		})
+
+		Context("when agent responds without an exit code", func() {
+			BeforeEach(func() {
+				fakeHTTPClient.SetPostBehavior(`{"value":{"agent_task_id":"fake-agent-task-id","state":"running"}}`, 200, nil)
+				fakeHTTPClient.SetPostBehavior(`{"value":{"stdout":"fake-stdout"}}`, 200, nil)
+			})
+
+			It("returns an error", func() {
+				_, err := agentClient.RunErrand("fake-errand-name")
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Unable to parse 'run_errand' response"))
+			})
+		})
+	})
+
+	Describe("FetchLogs", func() {
+		Context("when agent responds with a blobstore id", func() {
+			BeforeEach(func() {
+				fakeHTTPClient.SetPostBehavior(`{"value":{"agent_task_id":"fake-agent-task-id","state":"running"}}`, 200, nil)
+				fakeHTTPClient.SetPostBehavior(`{"value":{"blobstore_id":"fake-logs-blob-id"}}`, 200, nil)
+			})
+
+			It("sends fetch_logs with the log type and filters and returns the blob id", func() {
+				blobID, err := agentClient.FetchLogs("job", []string{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(blobID).To(Equal("fake-logs-blob-id"))
+
+				var request AgentRequestMessage
+				err = json.Unmarshal(fakeHTTPClient.PostInputs[0].Payload, &request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(request).To(Equal(AgentRequestMessage{
+					Method:    "fetch_logs",
+					Arguments: []interface{}{"job", []interface{}{}},
+					ReplyTo:   "fake-uuid",
+				}))
+			})
+		})
+
+		Context("when agent responds with exception", func() {
+			BeforeEach(func() {
+				fakeHTTPClient.SetPostBehavior(`{"exception":{"message":"bad request"}}`, 200, nil)
+			})
+
+			It("returns an error", func() {
+				_, err := agentClient.FetchLogs("job", []string{})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("bad request"))
+			})
+		})
+	})
+
 	Describe("CompilePackage", func() {
 		BeforeEach(func() {
 			fakeHTTPClient.SetPostBehavior(`{"value":{"agent_task_id":"fake-agent-task-id","state":"running"}}`, 200, nil)
@@ -60,3 +60,27 @@ func (r agentRequest) Send(method string, arguments []interface{}, response Resp
 
 	return nil
 }
+
+// bodyRequester is a transport that returns the body of the agent response (e.g. the NATS mbus)
+type bodyRequester interface {
+	Send(method string, arguments []interface{}) ([]byte, error)
+}
+
+// bodyAgentRequest unmarshals the agent responses received through a bodyRequester
+type bodyAgentRequest struct {
+	requester bodyRequester
+}
+
+func (r bodyAgentRequest) Send(method string, arguments []interface{}, response Response) error {
+	responseBody, err := r.requester.Send(method, arguments)
+	if err != nil {
+		return err
+	}
+
+	err = response.Unmarshal(responseBody)
+	if err != nil {
+		return bosherr.WrapError(err, "Unmarshaling agent response")
+	}
+
+	return response.ServerError()
+}
@@ -2,20 +2,25 @@ package fakes
 
 import (
 	biagentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient"
+	binats "github.com/cloudfoundry/bosh-init/deployment/agentclient/nats"
 )
 
 type FakeAgentClientFactory struct {
 	CreateAgentClient biagentclient.AgentClient
 	CreateDirectorID  string
 	CreateMbusURL     string
+	CreateAgentIDRepo binats.AgentIDRepo
+	CreateTimeouts    biagentclient.TaskTimeouts
 }
 
 func NewFakeAgentClientFactory() *FakeAgentClientFactory {
 	return &FakeAgentClientFactory{}
 }
 
-func (f *FakeAgentClientFactory) NewAgentClient(directorID, mbusURL string) biagentclient.AgentClient {
+func (f *FakeAgentClientFactory) NewAgentClient(directorID, mbusURL string, agentIDRepo binats.AgentIDRepo, taskTimeouts biagentclient.TaskTimeouts) biagentclient.AgentClient {
 	f.CreateDirectorID = directorID
 	f.CreateMbusURL = mbusURL
+	f.CreateAgentIDRepo = agentIDRepo
+	f.CreateTimeouts = taskTimeouts
 	return f.CreateAgentClient
 }
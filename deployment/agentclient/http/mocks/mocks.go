@@ -6,6 +6,7 @@ package mocks
 import (
 	gomock "code.google.com/p/gomock/gomock"
 	agentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient"
+	nats "github.com/cloudfoundry/bosh-init/deployment/agentclient/nats"
 )
 
 // Mock of AgentClientFactory interface
@@ -29,12 +30,12 @@ func (_m *MockAgentClientFactory) EXPECT() *_MockAgentClientFactoryRecorder {
 	return _m.recorder
 }
 
-func (_m *MockAgentClientFactory) NewAgentClient(_param0 string, _param1 string) agentclient.AgentClient {
-	ret := _m.ctrl.Call(_m, "NewAgentClient", _param0, _param1)
+func (_m *MockAgentClientFactory) NewAgentClient(_param0 string, _param1 string, _param2 nats.AgentIDRepo, _param3 agentclient.TaskTimeouts) agentclient.AgentClient {
+	ret := _m.ctrl.Call(_m, "NewAgentClient", _param0, _param1, _param2, _param3)
 	ret0, _ := ret[0].(agentclient.AgentClient)
 	return ret0
 }
 
-func (_mr *_MockAgentClientFactoryRecorder) NewAgentClient(arg0, arg1 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "NewAgentClient", arg0, arg1)
+func (_mr *_MockAgentClientFactoryRecorder) NewAgentClient(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "NewAgentClient", arg0, arg1, arg2, arg3)
 }
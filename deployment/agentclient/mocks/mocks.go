@@ -7,6 +7,7 @@ import (
 	gomock "code.google.com/p/gomock/gomock"
 	agentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient"
 	applyspec "github.com/cloudfoundry/bosh-init/deployment/applyspec"
+	time "time"
 )
 
 // Mock of AgentClient interface
@@ -51,6 +52,33 @@ func (_mr *_MockAgentClientRecorder) CompilePackage(arg0, arg1 interface{}) *gom
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "CompilePackage", arg0, arg1)
 }
 
+func (_m *MockAgentClient) Drain(_param0 agentclient.DrainType, _param1 ...applyspec.ApplySpec) (int64, error) {
+	_s := []interface{}{_param0}
+	for _, _x := range _param1 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "Drain", _s...)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockAgentClientRecorder) Drain(arg0 interface{}, arg1 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0}, arg1...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Drain", _s...)
+}
+
+func (_m *MockAgentClient) FetchLogs(_param0 string, _param1 []string) (string, error) {
+	ret := _m.ctrl.Call(_m, "FetchLogs", _param0, _param1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockAgentClientRecorder) FetchLogs(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "FetchLogs", arg0, arg1)
+}
+
 func (_m *MockAgentClient) GetState() (agentclient.AgentState, error) {
 	ret := _m.ctrl.Call(_m, "GetState")
 	ret0, _ := ret[0].(agentclient.AgentState)
@@ -104,6 +132,28 @@ func (_mr *_MockAgentClientRecorder) Ping() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Ping")
 }
 
+func (_m *MockAgentClient) RunErrand(_param0 string) (agentclient.ErrandResult, error) {
+	ret := _m.ctrl.Call(_m, "RunErrand", _param0)
+	ret0, _ := ret[0].(agentclient.ErrandResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockAgentClientRecorder) RunErrand(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RunErrand", arg0)
+}
+
+func (_m *MockAgentClient) RunScript(_param0 string, _param1 map[string]interface{}, _param2 time.Duration) (agentclient.ScriptResult, error) {
+	ret := _m.ctrl.Call(_m, "RunScript", _param0, _param1, _param2)
+	ret0, _ := ret[0].(agentclient.ScriptResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockAgentClientRecorder) RunScript(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RunScript", arg0, arg1, arg2)
+}
+
 func (_m *MockAgentClient) Start() error {
 	ret := _m.ctrl.Call(_m, "Start")
 	ret0, _ := ret[0].(error)
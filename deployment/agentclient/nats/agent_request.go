@@ -0,0 +1,96 @@
+package nats
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+)
+
+// AgentRequest sends agent requests through the embedded NATS server
+// instead of posting them to the agent's https endpoint.
+type AgentRequest interface {
+	// Send returns the body of the agent response
+	Send(method string, arguments []interface{}) ([]byte, error)
+}
+
+type requestMessage struct {
+	Method    string        `json:"method"`
+	Arguments []interface{} `json:"arguments"`
+	ReplyTo   string        `json:"reply_to"`
+}
+
+// AgentIDRepo finds the agent ID that bosh-init generated for the current VM
+type AgentIDRepo interface {
+	FindCurrentAgentID() (agentID string, found bool, err error)
+}
+
+type agentRequest struct {
+	directorID      string
+	server          Server
+	agentIDRepo     AgentIDRepo
+	responseTimeout time.Duration
+	logger          boshlog.Logger
+	logTag          string
+
+	lock         sync.Mutex
+	requestCount int
+}
+
+func NewAgentRequest(server Server, directorID string, agentIDRepo AgentIDRepo, responseTimeout time.Duration, logger boshlog.Logger) AgentRequest {
+	return &agentRequest{
+		directorID:      directorID,
+		server:          server,
+		agentIDRepo:     agentIDRepo,
+		responseTimeout: responseTimeout,
+		logger:          logger,
+		logTag:          "natsAgentRequest",
+	}
+}
+
+func (r *agentRequest) Send(method string, arguments []interface{}) ([]byte, error) {
+	agentID, replyTo, err := r.nextRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	agentRequestJSON, err := json.Marshal(requestMessage{
+		Method:    method,
+		Arguments: arguments,
+		ReplyTo:   replyTo,
+	})
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Marshaling agent request")
+	}
+
+	responseBody, err := r.server.Request(agentID, replyTo, agentRequestJSON, r.responseTimeout)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Performing request to agent '%s' over NATS", agentID)
+	}
+
+	return responseBody, nil
+}
+
+// nextRequest picks the agent to address and a reply subject unique to this request.
+// The agent ID is looked up on every request because it changes when the VM is recreated.
+func (r *agentRequest) nextRequest() (string, string, error) {
+	agentID, found, err := r.agentIDRepo.FindCurrentAgentID()
+	if err != nil {
+		return "", "", bosherr.WrapError(err, "Finding agent ID of the current VM")
+	}
+
+	if !found {
+		return "", "", bosherr.Error("Agent ID of the current VM is not recorded")
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.requestCount++
+	replyTo := fmt.Sprintf("director.%s.%s.%d", r.directorID, agentID, r.requestCount)
+
+	return agentID, replyTo, nil
+}
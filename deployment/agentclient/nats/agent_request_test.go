@@ -0,0 +1,121 @@
+package nats_test
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	. "github.com/cloudfoundry/bosh-init/deployment/agentclient/nats"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gomock/gomock"
+	mock_nats "github.com/cloudfoundry/bosh-init/deployment/agentclient/nats/mocks"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	fakebiconfig "github.com/cloudfoundry/bosh-init/config/fakes"
+)
+
+var _ = Describe("AgentRequest", func() {
+	var mockCtrl *gomock.Controller
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	var (
+		mockServer   *mock_nats.MockServer
+		fakeVMRepo   *fakebiconfig.FakeVMRepo
+		agentRequest AgentRequest
+	)
+
+	BeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		mockServer = mock_nats.NewMockServer(mockCtrl)
+		fakeVMRepo = fakebiconfig.NewFakeVMRepo()
+		agentRequest = NewAgentRequest(mockServer, "fake-director-id", fakeVMRepo, 30*time.Second, logger)
+	})
+
+	Describe("Send", func() {
+		Context("when the agent ID of the current VM is recorded", func() {
+			BeforeEach(func() {
+				fakeVMRepo.SetFindCurrentAgentIDBehavior("fake-agent-id", true, nil)
+			})
+
+			It("sends the request to the agent with a reply_to subject for that agent", func() {
+				mockServer.EXPECT().Request("fake-agent-id", "director.fake-director-id.fake-agent-id.1", gomock.Any(), 30*time.Second).Do(
+					func(_ string, _ string, message []byte, _ time.Duration) {
+						var request map[string]interface{}
+						err := json.Unmarshal(message, &request)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(request).To(Equal(map[string]interface{}{
+							"method":    "ping",
+							"arguments": []interface{}{},
+							"reply_to":  "director.fake-director-id.fake-agent-id.1",
+						}))
+					},
+				).Return([]byte(`{"value":"pong"}`), nil)
+
+				responseBody, err := agentRequest.Send("ping", []interface{}{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(responseBody)).To(Equal(`{"value":"pong"}`))
+			})
+
+			It("uses a new reply_to subject for each request", func() {
+				gomock.InOrder(
+					mockServer.EXPECT().Request("fake-agent-id", "director.fake-director-id.fake-agent-id.1", gomock.Any(), gomock.Any()).Return([]byte(`{"value":"pong"}`), nil),
+					mockServer.EXPECT().Request("fake-agent-id", "director.fake-director-id.fake-agent-id.2", gomock.Any(), gomock.Any()).Return([]byte(`{"value":"pong"}`), nil),
+				)
+
+				_, err := agentRequest.Send("ping", []interface{}{})
+				Expect(err).ToNot(HaveOccurred())
+				_, err = agentRequest.Send("ping", []interface{}{})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns an error when the request fails", func() {
+				mockServer.EXPECT().Request(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("fake-request-error"))
+
+				_, err := agentRequest.Send("ping", []interface{}{})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Performing request to agent 'fake-agent-id' over NATS: fake-request-error"))
+			})
+		})
+
+		It("returns an error when the agent ID of the current VM is not recorded", func() {
+			fakeVMRepo.SetFindCurrentAgentIDBehavior("", false, nil)
+
+			_, err := agentRequest.Send("ping", []interface{}{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Agent ID of the current VM is not recorded"))
+		})
+
+		It("returns an error when finding the agent ID fails", func() {
+			fakeVMRepo.SetFindCurrentAgentIDBehavior("", false, errors.New("fake-find-error"))
+
+			_, err := agentRequest.Send("ping", []interface{}{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-find-error"))
+		})
+
+		It("sends to the agent of the recreated VM once its agent ID is recorded", func() {
+			gomock.InOrder(
+				mockServer.EXPECT().Request("fake-agent-id-1", "director.fake-director-id.fake-agent-id-1.1", gomock.Any(), gomock.Any()).Return([]byte(`{"value":"pong"}`), nil),
+				mockServer.EXPECT().Request("fake-agent-id-2", "director.fake-director-id.fake-agent-id-2.2", gomock.Any(), gomock.Any()).Return([]byte(`{"value":"pong"}`), nil),
+			)
+
+			fakeVMRepo.SetFindCurrentAgentIDBehavior("fake-agent-id-1", true, nil)
+			_, err := agentRequest.Send("ping", []interface{}{})
+			Expect(err).ToNot(HaveOccurred())
+
+			fakeVMRepo.SetFindCurrentAgentIDBehavior("fake-agent-id-2", true, nil)
+			_, err = agentRequest.Send("ping", []interface{}{})
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,298 @@
+package nats
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+)
+
+const maxPayload = 1024 * 1024
+
+type connectOptions struct {
+	Verbose  bool   `json:"verbose"`
+	User     string `json:"user"`
+	Password string `json:"pass"`
+}
+
+type serverInfo struct {
+	ServerID     string `json:"server_id"`
+	Version      string `json:"version"`
+	AuthRequired bool   `json:"auth_required"`
+	MaxPayload   int    `json:"max_payload"`
+}
+
+// connection implements the server side of the NATS text protocol for a single client
+type connection struct {
+	netConn net.Conn
+	reader  *bufio.Reader
+	server  *server
+	logger  boshlog.Logger
+	logTag  string
+
+	writeLock     sync.Mutex
+	subsLock      sync.Mutex
+	subscriptions map[string]string
+	verbose       bool
+	authorized    bool
+}
+
+func newConnection(netConn net.Conn, server *server, logger boshlog.Logger) *connection {
+	return &connection{
+		netConn:       netConn,
+		reader:        bufio.NewReader(netConn),
+		server:        server,
+		logger:        logger,
+		logTag:        "natsConnection",
+		subscriptions: map[string]string{},
+	}
+}
+
+func (c *connection) serve() error {
+	defer c.netConn.Close()
+
+	info, err := json.Marshal(serverInfo{
+		ServerID:     "bosh-init",
+		Version:      "0.5.0",
+		AuthRequired: c.server.authRequired(),
+		MaxPayload:   maxPayload,
+	})
+	if err != nil {
+		return bosherr.WrapError(err, "Marshaling server info")
+	}
+
+	err = c.write(fmt.Sprintf("INFO %s\r\n", info))
+	if err != nil {
+		return err
+	}
+
+	c.authorized = !c.server.authRequired()
+
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return bosherr.WrapError(err, "Reading from client")
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			continue
+		}
+
+		op, args := splitOp(line)
+		if op != "CONNECT" && !c.authorized {
+			_ = c.write("-ERR 'Authorization Violation'\r\n")
+			return bosherr.Error("Client sent a message before authenticating")
+		}
+
+		switch op {
+		case "CONNECT":
+			err = c.handleConnect(args)
+		case "PING":
+			err = c.write("PONG\r\n")
+		case "PONG":
+		case "SUB":
+			err = c.handleSub(args)
+		case "UNSUB":
+			err = c.handleUnsub(args)
+		case "PUB":
+			err = c.handlePub(args)
+		default:
+			_ = c.write("-ERR 'Unknown Protocol Operation'\r\n")
+			err = bosherr.Errorf("Unknown protocol operation '%s'", op)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c *connection) handleConnect(args string) error {
+	var options connectOptions
+	err := json.Unmarshal([]byte(args), &options)
+	if err != nil {
+		_ = c.write("-ERR 'Invalid CONNECT'\r\n")
+		return bosherr.WrapError(err, "Unmarshaling CONNECT options")
+	}
+
+	c.verbose = options.Verbose
+
+	if c.server.authRequired() && !c.server.authorize(options.User, options.Password) {
+		_ = c.write("-ERR 'Authorization Violation'\r\n")
+		return bosherr.Error("Client failed to authenticate")
+	}
+	c.authorized = true
+
+	return c.ok()
+}
+
+func (c *connection) handleSub(args string) error {
+	fields := strings.Fields(args)
+	if len(fields) < 2 || len(fields) > 3 {
+		_ = c.write("-ERR 'Invalid SUB'\r\n")
+		return bosherr.Errorf("Invalid SUB arguments '%s'", args)
+	}
+
+	// the optional queue group is ignored, every subscriber receives every message
+	subject, sid := fields[0], fields[len(fields)-1]
+
+	c.subsLock.Lock()
+	c.subscriptions[sid] = subject
+	c.subsLock.Unlock()
+
+	c.logger.Debug(c.logTag, "Client %s subscribed to '%s'", c.netConn.RemoteAddr(), subject)
+	return c.ok()
+}
+
+func (c *connection) handleUnsub(args string) error {
+	fields := strings.Fields(args)
+	if len(fields) < 1 {
+		_ = c.write("-ERR 'Invalid UNSUB'\r\n")
+		return bosherr.Errorf("Invalid UNSUB arguments '%s'", args)
+	}
+
+	c.subsLock.Lock()
+	delete(c.subscriptions, fields[0])
+	c.subsLock.Unlock()
+
+	return c.ok()
+}
+
+func (c *connection) handlePub(args string) error {
+	fields := strings.Fields(args)
+	if len(fields) < 2 || len(fields) > 3 {
+		_ = c.write("-ERR 'Invalid PUB'\r\n")
+		return bosherr.Errorf("Invalid PUB arguments '%s'", args)
+	}
+
+	size, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || size < 0 || size > maxPayload {
+		_ = c.write("-ERR 'Invalid Payload Size'\r\n")
+		return bosherr.Errorf("Invalid PUB payload size '%s'", fields[len(fields)-1])
+	}
+
+	payload := make([]byte, size+2)
+	_, err = io.ReadFull(c.reader, payload)
+	if err != nil {
+		return bosherr.WrapError(err, "Reading PUB payload")
+	}
+
+	var replyTo string
+	if len(fields) == 3 {
+		replyTo = fields[1]
+	}
+
+	c.server.publish(c, fields[0], replyTo, payload[:size])
+
+	return c.ok()
+}
+
+// deliver writes the message to each matching subscription and returns the number of deliveries
+func (c *connection) deliver(subject string, replyTo string, message []byte) int {
+	c.subsLock.Lock()
+	sids := []string{}
+	for sid, subscribedSubject := range c.subscriptions {
+		if subjectMatches(subscribedSubject, subject) {
+			sids = append(sids, sid)
+		}
+	}
+	c.subsLock.Unlock()
+
+	delivered := 0
+	for _, sid := range sids {
+		header := fmt.Sprintf("MSG %s %s %d\r\n", subject, sid, len(message))
+		if replyTo != "" {
+			header = fmt.Sprintf("MSG %s %s %s %d\r\n", subject, sid, replyTo, len(message))
+		}
+
+		err := c.write(header + string(message) + "\r\n")
+		if err != nil {
+			c.logger.Warn(c.logTag, "Delivering message on '%s' to %s: %s", subject, c.netConn.RemoteAddr(), err.Error())
+			continue
+		}
+		delivered++
+	}
+
+	return delivered
+}
+
+func (c *connection) subjects() []string {
+	c.subsLock.Lock()
+	defer c.subsLock.Unlock()
+
+	subjects := []string{}
+	for _, subject := range c.subscriptions {
+		subjects = append(subjects, subject)
+	}
+	return subjects
+}
+
+func (c *connection) hasSubject(subject string) bool {
+	for _, subscribedSubject := range c.subjects() {
+		if subscribedSubject == subject {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *connection) close() {
+	_ = c.netConn.Close()
+}
+
+func (c *connection) ok() error {
+	if !c.verbose {
+		return nil
+	}
+	return c.write("+OK\r\n")
+}
+
+func (c *connection) write(message string) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	_, err := io.WriteString(c.netConn, message)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing to client")
+	}
+	return nil
+}
+
+func splitOp(line string) (string, string) {
+	parts := strings.SplitN(line, " ", 2)
+	op := strings.ToUpper(parts[0])
+	if len(parts) == 1 {
+		return op, ""
+	}
+	return op, strings.TrimSpace(parts[1])
+}
+
+// subjectMatches supports the '*' (single token) and '>' (remaining tokens) subscription wildcards
+func subjectMatches(subscribedSubject string, subject string) bool {
+	subscribedTokens := strings.Split(subscribedSubject, ".")
+	tokens := strings.Split(subject, ".")
+
+	for i, subscribedToken := range subscribedTokens {
+		if subscribedToken == ">" {
+			return len(tokens) > i
+		}
+		if i >= len(tokens) {
+			return false
+		}
+		if subscribedToken != "*" && subscribedToken != tokens[i] {
+			return false
+		}
+	}
+
+	return len(subscribedTokens) == len(tokens)
+}
@@ -0,0 +1,13 @@
+package nats_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestNats(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "NATS Suite")
+}
@@ -0,0 +1,301 @@
+package nats
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	bisshtunnel "github.com/cloudfoundry/bosh-init/deployment/sshtunnel"
+)
+
+const agentSubjectPrefix = "agent."
+
+// Server is an embedded NATS message bus that agents connect to when the mbus url uses the nats scheme.
+// Agents subscribe to 'agent.<agent_id>' and publish their responses to the reply_to subject of each request.
+type Server interface {
+	Start(mbusURL string, sshTunnelOptions bisshtunnel.Options) error
+	Stop() error
+	AgentIDs() []string
+	Request(agentID string, replyTo string, message []byte, timeout time.Duration) ([]byte, error)
+}
+
+func IsNATSURL(mbusURL string) bool {
+	parsedURL, err := url.Parse(mbusURL)
+	if err != nil {
+		return false
+	}
+	return parsedURL.Scheme == "nats"
+}
+
+type server struct {
+	sshTunnelFactory bisshtunnel.Factory
+	sshTunnelDelay   time.Duration
+	logger           boshlog.Logger
+	logTag           string
+
+	lock        sync.Mutex
+	listener    net.Listener
+	username    string
+	password    string
+	connections map[*connection]struct{}
+	pending     map[string]*pendingRequest
+	stopCh      chan struct{}
+	sshTunnel   bisshtunnel.SSHTunnel
+}
+
+type pendingRequest struct {
+	agentID    string
+	responseCh chan []byte
+}
+
+func NewServer(sshTunnelFactory bisshtunnel.Factory, logger boshlog.Logger) Server {
+	return &server{
+		sshTunnelFactory: sshTunnelFactory,
+		sshTunnelDelay:   5 * time.Second,
+		logger:           logger,
+		logTag:           "natsServer",
+	}
+}
+
+// Start listens on the host and port of the mbus url and accepts agent connections on a goroutine.
+// When ssh tunnel options are provided, the listening port is reverse forwarded onto the VM,
+// re-establishing the tunnel whenever it drops (e.g. when the VM is recreated).
+func (s *server) Start(mbusURL string, sshTunnelOptions bisshtunnel.Options) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.listener != nil {
+		return bosherr.Error("NATS server already started")
+	}
+
+	parsedURL, err := url.Parse(mbusURL)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Parsing mbus url '%s'", mbusURL)
+	}
+
+	host, portString, err := net.SplitHostPort(parsedURL.Host)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Parsing mbus url '%s'", mbusURL)
+	}
+
+	if parsedURL.User != nil {
+		s.username = parsedURL.User.Username()
+		s.password, _ = parsedURL.User.Password()
+	}
+
+	s.logger.Debug(s.logTag, "Starting NATS server at %s:%s", host, portString)
+	s.listener, err = net.Listen("tcp", net.JoinHostPort(host, portString))
+	if err != nil {
+		s.listener = nil
+		return bosherr.WrapError(err, "Starting NATS listener")
+	}
+
+	s.connections = map[*connection]struct{}{}
+	s.pending = map[string]*pendingRequest{}
+	s.stopCh = make(chan struct{})
+
+	go s.accept(s.listener)
+
+	if !sshTunnelOptions.IsEmpty() {
+		port, err := strconv.Atoi(portString)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Parsing mbus port '%s'", portString)
+		}
+		sshTunnelOptions.LocalForwardPort = port
+		sshTunnelOptions.RemoteForwardPort = port
+		go s.keepTunnelOpen(sshTunnelOptions, s.stopCh)
+	}
+
+	return nil
+}
+
+func (s *server) Stop() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.listener == nil {
+		return bosherr.Error("NATS server must be started before it can be stopped")
+	}
+
+	s.logger.Debug(s.logTag, "Stopping NATS server")
+	close(s.stopCh)
+
+	if s.sshTunnel != nil {
+		err := s.sshTunnel.Stop()
+		if err != nil {
+			s.logger.Warn(s.logTag, "Failed to stop ssh tunnel: %s", err.Error())
+		}
+		s.sshTunnel = nil
+	}
+
+	for conn := range s.connections {
+		conn.close()
+	}
+
+	err := s.listener.Close()
+	s.listener = nil
+	if err != nil {
+		return bosherr.WrapError(err, "Stopping NATS server")
+	}
+
+	return nil
+}
+
+// AgentIDs returns the IDs of the agents currently subscribed to their request subject
+func (s *server) AgentIDs() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	agentIDs := []string{}
+	for conn := range s.connections {
+		for _, subject := range conn.subjects() {
+			if strings.HasPrefix(subject, agentSubjectPrefix) {
+				agentIDs = append(agentIDs, strings.TrimPrefix(subject, agentSubjectPrefix))
+			}
+		}
+	}
+	return agentIDs
+}
+
+// Request publishes the message to the agent and waits for a response published to replyTo by the same agent
+func (s *server) Request(agentID string, replyTo string, message []byte, timeout time.Duration) ([]byte, error) {
+	subject := agentSubjectPrefix + agentID
+	request := &pendingRequest{
+		agentID:    agentID,
+		responseCh: make(chan []byte, 1),
+	}
+
+	s.lock.Lock()
+	if s.listener == nil {
+		s.lock.Unlock()
+		return nil, bosherr.Error("NATS server must be started before sending requests")
+	}
+
+	s.pending[replyTo] = request
+	delivered := 0
+	for conn := range s.connections {
+		delivered += conn.deliver(subject, "", message)
+	}
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		delete(s.pending, replyTo)
+		s.lock.Unlock()
+	}()
+
+	if delivered == 0 {
+		return nil, bosherr.Errorf("Agent '%s' is not connected to the NATS server", agentID)
+	}
+
+	select {
+	case response := <-request.responseCh:
+		return response, nil
+	case <-time.After(timeout):
+		return nil, bosherr.Errorf("Timed out after %s waiting for agent '%s' to respond", timeout, agentID)
+	}
+}
+
+func (s *server) accept(listener net.Listener) {
+	for {
+		netConn, err := listener.Accept()
+		if err != nil {
+			s.logger.Debug(s.logTag, "Stopped accepting connections: %s", err.Error())
+			return
+		}
+
+		conn := newConnection(netConn, s, s.logger)
+
+		s.lock.Lock()
+		s.connections[conn] = struct{}{}
+		s.lock.Unlock()
+
+		go func() {
+			err := conn.serve()
+			if err != nil {
+				s.logger.Debug(s.logTag, "Connection from %s closed: %s", netConn.RemoteAddr(), err.Error())
+			}
+
+			s.lock.Lock()
+			delete(s.connections, conn)
+			s.lock.Unlock()
+		}()
+	}
+}
+
+func (s *server) authorize(username, password string) bool {
+	usernameMatches := subtle.ConstantTimeCompare([]byte(username), []byte(s.username)) == 1
+	passwordMatches := subtle.ConstantTimeCompare([]byte(password), []byte(s.password)) == 1
+	return usernameMatches && passwordMatches
+}
+
+func (s *server) authRequired() bool {
+	return s.username != "" || s.password != ""
+}
+
+// publish routes a message published by a connection to matching pending requests and subscriptions
+func (s *server) publish(from *connection, subject string, replyTo string, message []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if request, found := s.pending[subject]; found {
+		if from.hasSubject(agentSubjectPrefix + request.agentID) {
+			select {
+			case request.responseCh <- message:
+			default:
+			}
+		} else {
+			s.logger.Warn(s.logTag, "Ignoring response on '%s' from a connection that is not agent '%s'", subject, request.agentID)
+		}
+		return
+	}
+
+	for conn := range s.connections {
+		conn.deliver(subject, replyTo, message)
+	}
+}
+
+func (s *server) keepTunnelOpen(sshTunnelOptions bisshtunnel.Options, stopCh chan struct{}) {
+	for {
+		sshTunnel := s.sshTunnelFactory.NewSSHTunnel(sshTunnelOptions)
+
+		s.lock.Lock()
+		s.sshTunnel = sshTunnel
+		s.lock.Unlock()
+
+		readyErrCh := make(chan error)
+		errCh := make(chan error)
+		go sshTunnel.Start(readyErrCh, errCh)
+
+		var err error
+		select {
+		case err = <-readyErrCh:
+			if err == nil {
+				s.logger.Debug(s.logTag, "SSH tunnel to %s:%d forwarding port %d", sshTunnelOptions.Host, sshTunnelOptions.Port, sshTunnelOptions.RemoteForwardPort)
+				select {
+				case err = <-errCh:
+				case <-stopCh:
+					return
+				}
+			}
+		case <-stopCh:
+			return
+		}
+
+		s.logger.Debug(s.logTag, "SSH tunnel closed, reconnecting: %s", err)
+		_ = sshTunnel.Stop()
+
+		select {
+		case <-time.After(s.sshTunnelDelay):
+		case <-stopCh:
+			return
+		}
+	}
+}
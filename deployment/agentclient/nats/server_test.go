@@ -0,0 +1,216 @@
+package nats_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/cloudfoundry/bosh-init/deployment/agentclient/nats"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	bisshtunnel "github.com/cloudfoundry/bosh-init/deployment/sshtunnel"
+	fakebisshtunnel "github.com/cloudfoundry/bosh-init/deployment/sshtunnel/fakes"
+)
+
+type fakeAgent struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func connectFakeAgent(address string, user string, password string) *fakeAgent {
+	conn, err := net.Dial("tcp", address)
+	Expect(err).ToNot(HaveOccurred())
+
+	agent := &fakeAgent{conn: conn, reader: bufio.NewReader(conn)}
+	Expect(strings.HasPrefix(agent.readLine(), "INFO ")).To(BeTrue())
+	agent.send(fmt.Sprintf(`CONNECT {"verbose":false,"user":"%s","pass":"%s"}`+"\r\n", user, password))
+	return agent
+}
+
+func (a *fakeAgent) send(message string) {
+	_, err := io.WriteString(a.conn, message)
+	Expect(err).ToNot(HaveOccurred())
+}
+
+func (a *fakeAgent) readLine() string {
+	a.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := a.reader.ReadString('\n')
+	Expect(err).ToNot(HaveOccurred())
+	return strings.TrimRight(line, "\r\n")
+}
+
+// readMsg reads a MSG and returns its subject and payload
+func (a *fakeAgent) readMsg() (string, string) {
+	fields := strings.Fields(a.readLine())
+	Expect(fields[0]).To(Equal("MSG"))
+
+	size, err := strconv.Atoi(fields[len(fields)-1])
+	Expect(err).ToNot(HaveOccurred())
+
+	payload := make([]byte, size+2)
+	_, err = io.ReadFull(a.reader, payload)
+	Expect(err).ToNot(HaveOccurred())
+
+	return fields[1], string(payload[:size])
+}
+
+func (a *fakeAgent) subscribe(agentID string) {
+	a.send(fmt.Sprintf("SUB agent.%s 1\r\n", agentID))
+	// PING/PONG guarantees the subscription has been processed
+	a.send("PING\r\n")
+	Expect(a.readLine()).To(Equal("PONG"))
+}
+
+func (a *fakeAgent) publish(subject string, payload string) {
+	a.send(fmt.Sprintf("PUB %s %d\r\n%s\r\n", subject, len(payload), payload))
+}
+
+// channelSSHTunnelFactory reports the options of each tunnel it creates on a channel,
+// since the server opens tunnels on its own goroutine
+type channelSSHTunnelFactory struct {
+	optionsCh chan bisshtunnel.Options
+}
+
+func (f *channelSSHTunnelFactory) NewSSHTunnel(options bisshtunnel.Options) bisshtunnel.SSHTunnel {
+	select {
+	case f.optionsCh <- options:
+	default:
+	}
+	return fakebisshtunnel.NewFakeTunnel()
+}
+
+var _ = Describe("Server", func() {
+	var (
+		fakeSSHTunnelFactory *fakebisshtunnel.FakeFactory
+		server               Server
+		address              string
+		mbusURL              string
+	)
+
+	BeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		fakeSSHTunnelFactory = fakebisshtunnel.NewFakeFactory()
+		server = NewServer(fakeSSHTunnelFactory, logger)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		address = listener.Addr().String()
+		listener.Close()
+
+		mbusURL = fmt.Sprintf("nats://fake-user:fake-password@%s", address)
+	})
+
+	Describe("Start", func() {
+		AfterEach(func() {
+			server.Stop()
+		})
+
+		It("accepts agents that authenticate with the mbus credentials", func() {
+			err := server.Start(mbusURL, bisshtunnel.Options{})
+			Expect(err).ToNot(HaveOccurred())
+
+			agent := connectFakeAgent(address, "fake-user", "fake-password")
+			agent.subscribe("fake-agent-id")
+
+			Expect(server.AgentIDs()).To(Equal([]string{"fake-agent-id"}))
+		})
+
+		It("rejects agents with the wrong credentials", func() {
+			err := server.Start(mbusURL, bisshtunnel.Options{})
+			Expect(err).ToNot(HaveOccurred())
+
+			agent := connectFakeAgent(address, "fake-user", "wrong-password")
+			Expect(agent.readLine()).To(Equal("-ERR 'Authorization Violation'"))
+			Expect(server.AgentIDs()).To(BeEmpty())
+		})
+
+		It("returns an error when already started", func() {
+			err := server.Start(mbusURL, bisshtunnel.Options{})
+			Expect(err).ToNot(HaveOccurred())
+
+			err = server.Start(mbusURL, bisshtunnel.Options{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("NATS server already started"))
+		})
+
+		It("forwards the mbus port through an ssh tunnel when tunnel options are given", func() {
+			tunnelFactory := &channelSSHTunnelFactory{optionsCh: make(chan bisshtunnel.Options, 1)}
+			server = NewServer(tunnelFactory, boshlog.NewLogger(boshlog.LevelNone))
+
+			err := server.Start(mbusURL, bisshtunnel.Options{Host: "fake-ssh-host", Port: 22, User: "fake-ssh-user"})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, portString, _ := net.SplitHostPort(address)
+			port, _ := strconv.Atoi(portString)
+
+			var options bisshtunnel.Options
+			Eventually(tunnelFactory.optionsCh).Should(Receive(&options))
+			Expect(options).To(Equal(bisshtunnel.Options{
+				Host:              "fake-ssh-host",
+				Port:              22,
+				User:              "fake-ssh-user",
+				LocalForwardPort:  port,
+				RemoteForwardPort: port,
+			}))
+		})
+	})
+
+	Describe("Request", func() {
+		var agent *fakeAgent
+
+		BeforeEach(func() {
+			err := server.Start(mbusURL, bisshtunnel.Options{})
+			Expect(err).ToNot(HaveOccurred())
+
+			agent = connectFakeAgent(address, "fake-user", "fake-password")
+			agent.subscribe("fake-agent-id")
+		})
+
+		AfterEach(func() {
+			server.Stop()
+		})
+
+		It("sends the request to the agent and returns the response published to reply_to", func() {
+			go func() {
+				defer GinkgoRecover()
+				subject, payload := agent.readMsg()
+				Expect(subject).To(Equal("agent.fake-agent-id"))
+				Expect(payload).To(Equal(`{"method":"ping"}`))
+				agent.publish("director.fake-director-id.fake-agent-id.1", `{"value":"pong"}`)
+			}()
+
+			response, err := server.Request("fake-agent-id", "director.fake-director-id.fake-agent-id.1", []byte(`{"method":"ping"}`), 5*time.Second)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(response)).To(Equal(`{"value":"pong"}`))
+		})
+
+		It("ignores responses published by other agents", func() {
+			otherAgent := connectFakeAgent(address, "fake-user", "fake-password")
+			otherAgent.subscribe("other-agent-id")
+
+			go func() {
+				defer GinkgoRecover()
+				agent.readMsg()
+				otherAgent.publish("director.fake-director-id.fake-agent-id.1", `{"value":"wrong-agent"}`)
+			}()
+
+			_, err := server.Request("fake-agent-id", "director.fake-director-id.fake-agent-id.1", []byte(`{"method":"ping"}`), 200*time.Millisecond)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Timed out after 200ms waiting for agent 'fake-agent-id' to respond"))
+		})
+
+		It("returns an error when the agent is not connected", func() {
+			_, err := server.Request("unknown-agent-id", "director.fake-director-id.unknown-agent-id.1", []byte(`{}`), 5*time.Second)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Agent 'unknown-agent-id' is not connected to the NATS server"))
+		})
+	})
+})
@@ -0,0 +1,10 @@
+package agentclient
+
+import (
+	"time"
+)
+
+// TaskProgressFunc is called periodically while an agent task is running, with the agent action name
+// (e.g. compile_package) and how long the task has been running. It may be called concurrently
+// when several tasks run at once.
+type TaskProgressFunc func(action string, elapsed time.Duration)
@@ -0,0 +1,20 @@
+package agentclient
+
+import (
+	"time"
+)
+
+// TaskTimeouts maps agent action names (e.g. compile_package) to how long their tasks may run.
+// The 'default' key applies to actions without their own entry. A timeout of 0 means no limit,
+// which is also what actions get when neither is configured (e.g. compile_package and drain can legitimately take hours).
+type TaskTimeouts map[string]time.Duration
+
+func (t TaskTimeouts) For(action string) time.Duration {
+	if timeout, found := t[action]; found {
+		return timeout
+	}
+	if timeout, found := t["default"]; found {
+		return timeout
+	}
+	return 0
+}
@@ -0,0 +1,28 @@
+package agentclient_test
+
+import (
+	"time"
+
+	. "github.com/cloudfoundry/bosh-init/deployment/agentclient"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TaskTimeouts", func() {
+	Describe("For", func() {
+		It("returns the timeout of the action", func() {
+			timeouts := TaskTimeouts{"compile_package": time.Hour, "default": time.Minute}
+			Expect(timeouts.For("compile_package")).To(Equal(time.Hour))
+		})
+
+		It("returns the default timeout when the action has none", func() {
+			timeouts := TaskTimeouts{"compile_package": time.Hour, "default": time.Minute}
+			Expect(timeouts.For("apply")).To(Equal(time.Minute))
+		})
+
+		It("returns no limit when nothing is configured", func() {
+			Expect(TaskTimeouts(nil).For("compile_package")).To(Equal(time.Duration(0)))
+		})
+	})
+})
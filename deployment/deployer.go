@@ -25,6 +25,7 @@ type Deployer interface {
 		biinstallmanifest.Registry,
 		bivm.Manager,
 		biblobstore.Blobstore,
+		bool,
 		biui.Stage,
 	) (Deployment, error)
 }
@@ -59,21 +60,30 @@ func (d *deployer) Deploy(
 	registryConfig biinstallmanifest.Registry,
 	vmManager bivm.Manager,
 	blobstore biblobstore.Blobstore,
+	skipDrain bool,
 	deployStage biui.Stage,
 ) (Deployment, error) {
 	instanceManager := d.instanceManagerFactory.NewManager(cloud, vmManager, blobstore)
 
 	pingTimeout := 10 * time.Second
 	pingDelay := 500 * time.Millisecond
-	if err := instanceManager.DeleteAll(pingTimeout, pingDelay, deployStage); err != nil {
+	if err := instanceManager.DeleteAll(pingTimeout, pingDelay, skipDrain, deployStage); err != nil {
 		return nil, err
 	}
 
-	instances, disks, err := d.createAllInstances(deploymentManifest, instanceManager, cloudStemcell, registryConfig, deployStage)
+	instances, disks, err := d.createAllInstances(deploymentManifest, instanceManager, cloudStemcell, registryConfig, skipDrain, deployStage)
 	if err != nil {
 		return nil, err
 	}
 
+	// post-deploy scripts run once the whole deployment is updated
+	for _, instance := range instances {
+		err = instance.RunPostDeploy(deployStage)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	stemcells := []bistemcell.CloudStemcell{cloudStemcell}
 	return d.deploymentFactory.NewDeployment(instances, disks, stemcells), nil
 }
@@ -83,16 +93,19 @@ func (d *deployer) createAllInstances(
 	instanceManager biinstance.Manager,
 	cloudStemcell bistemcell.CloudStemcell,
 	registryConfig biinstallmanifest.Registry,
+	skipDrain bool,
 	deployStage biui.Stage,
 ) ([]biinstance.Instance, []bidisk.Disk, error) {
 	instances := []biinstance.Instance{}
 	disks := []bidisk.Disk{}
 
-	if len(deploymentManifest.Jobs) != 1 {
-		return instances, disks, bosherr.Errorf("There must only be one job, found %d", len(deploymentManifest.Jobs))
+	// errands are not deployed, they are run on demand
+	serviceJobs := deploymentManifest.ServiceJobs()
+	if len(serviceJobs) != 1 {
+		return instances, disks, bosherr.Errorf("There must only be one job, found %d", len(serviceJobs))
 	}
 
-	for _, jobSpec := range deploymentManifest.Jobs {
+	for _, jobSpec := range serviceJobs {
 		if jobSpec.Instances != 1 {
 			return instances, disks, bosherr.Errorf("Job '%s' must have only one instance, found %d", jobSpec.Name, jobSpec.Instances)
 		}
@@ -104,7 +117,7 @@ func (d *deployer) createAllInstances(
 			instances = append(instances, instance)
 			disks = append(disks, instanceDisks...)
 
-			err = instance.UpdateJobs(deploymentManifest, deployStage)
+			err = instance.UpdateJobs(deploymentManifest, skipDrain, deployStage)
 			if err != nil {
 				return instances, disks, err
 			}
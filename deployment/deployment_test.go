@@ -24,6 +24,7 @@ import (
 	bicloud "github.com/cloudfoundry/bosh-init/cloud"
 	biproperty "github.com/cloudfoundry/bosh-init/common/property"
 	biconfig "github.com/cloudfoundry/bosh-init/config"
+	biagentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient"
 	bias "github.com/cloudfoundry/bosh-init/deployment/applyspec"
 	bidisk "github.com/cloudfoundry/bosh-init/deployment/disk"
 	biinstance "github.com/cloudfoundry/bosh-init/deployment/instance"
@@ -77,6 +78,7 @@ var _ = Describe("Deployment", func() {
 			gomock.InOrder(
 				mockCloud.EXPECT().HasVM("fake-vm-cid").Return(true, nil),
 				mockAgentClient.EXPECT().Ping().Return("any-state", nil),                   // ping to make sure agent is responsive
+				mockAgentClient.EXPECT().Drain(biagentclient.DrainTypeShutdown),            // drain all jobs
 				mockAgentClient.EXPECT().Stop(),                                            // stop all jobs
 				mockAgentClient.EXPECT().ListDisk().Return([]string{"fake-disk-cid"}, nil), // get mounted disks to be unmounted
 				mockAgentClient.EXPECT().UnmountDisk("fake-disk-cid"),
@@ -193,14 +195,14 @@ var _ = Describe("Deployment", func() {
 			It("stops agent, unmounts disk, deletes vm, deletes disk, deletes stemcell", func() {
 				expectNormalFlow()
 
-				err := deployment.Delete(fakeStage)
+				err := deployment.Delete(false, fakeStage)
 				Expect(err).ToNot(HaveOccurred())
 			})
 
 			It("logs validation stages", func() {
 				expectNormalFlow()
 
-				err := deployment.Delete(fakeStage)
+				err := deployment.Delete(false, fakeStage)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(fakeStage.PerformCalls).To(Equal([]fakebiui.PerformCall{
@@ -216,7 +218,7 @@ var _ = Describe("Deployment", func() {
 			It("clears current vm, disk and stemcell", func() {
 				expectNormalFlow()
 
-				err := deployment.Delete(fakeStage)
+				err := deployment.Delete(false, fakeStage)
 				Expect(err).ToNot(HaveOccurred())
 
 				_, found, err := vmRepo.FindCurrent()
@@ -255,7 +257,7 @@ var _ = Describe("Deployment", func() {
 						mockCloud.EXPECT().DeleteStemcell("fake-stemcell-cid"),
 					)
 
-					err := deployment.Delete(fakeStage)
+					err := deployment.Delete(false, fakeStage)
 					Expect(err).ToNot(HaveOccurred())
 				})
 			})
@@ -264,7 +266,7 @@ var _ = Describe("Deployment", func() {
 				JustBeforeEach(func() {
 					expectNormalFlow()
 
-					err := deployment.Delete(fakeStage)
+					err := deployment.Delete(false, fakeStage)
 					Expect(err).ToNot(HaveOccurred())
 
 					// reset event log recording
@@ -272,7 +274,7 @@ var _ = Describe("Deployment", func() {
 				})
 
 				It("does not delete anything", func() {
-					err := deployment.Delete(fakeStage)
+					err := deployment.Delete(false, fakeStage)
 					Expect(err).ToNot(HaveOccurred())
 
 					Expect(fakeStage.PerformCalls).To(BeEmpty())
@@ -293,7 +295,7 @@ var _ = Describe("Deployment", func() {
 			})
 
 			It("does not delete anything", func() {
-				err := deployment.Delete(fakeStage)
+				err := deployment.Delete(false, fakeStage)
 				Expect(err).NotTo(HaveOccurred())
 
 				Expect(fakeStage.PerformCalls).To(BeEmpty())
@@ -314,13 +316,14 @@ var _ = Describe("Deployment", func() {
 			It("stops the agent and deletes the VM", func() {
 				gomock.InOrder(
 					mockAgentClient.EXPECT().Ping().Return("any-state", nil),                   // ping to make sure agent is responsive
+					mockAgentClient.EXPECT().Drain(biagentclient.DrainTypeShutdown),            // drain all jobs
 					mockAgentClient.EXPECT().Stop(),                                            // stop all jobs
 					mockAgentClient.EXPECT().ListDisk().Return([]string{"fake-disk-cid"}, nil), // get mounted disks to be unmounted
 					mockAgentClient.EXPECT().UnmountDisk("fake-disk-cid"),
 					mockCloud.EXPECT().DeleteVM("fake-vm-cid"),
 				)
 
-				err := deployment.Delete(fakeStage)
+				err := deployment.Delete(false, fakeStage)
 				Expect(err).ToNot(HaveOccurred())
 			})
 
@@ -332,7 +335,7 @@ var _ = Describe("Deployment", func() {
 				It("skips agent shutdown & deletes the VM (to ensure related resources are released by the CPI)", func() {
 					mockCloud.EXPECT().DeleteVM("fake-vm-cid")
 
-					err := deployment.Delete(fakeStage)
+					err := deployment.Delete(false, fakeStage)
 					Expect(err).ToNot(HaveOccurred())
 				})
 
@@ -342,7 +345,7 @@ var _ = Describe("Deployment", func() {
 						Message: "fake-vm-not-found-message",
 					}))
 
-					err := deployment.Delete(fakeStage)
+					err := deployment.Delete(false, fakeStage)
 					Expect(err).ToNot(HaveOccurred())
 				})
 			})
@@ -359,7 +362,7 @@ var _ = Describe("Deployment", func() {
 			It("deletes the disk", func() {
 				mockCloud.EXPECT().DeleteDisk("fake-disk-cid")
 
-				err := deployment.Delete(fakeStage)
+				err := deployment.Delete(false, fakeStage)
 				Expect(err).ToNot(HaveOccurred())
 			})
 
@@ -367,7 +370,7 @@ var _ = Describe("Deployment", func() {
 				It("deletes the disk (to ensure related resources are released by the CPI)", func() {
 					mockCloud.EXPECT().DeleteDisk("fake-disk-cid")
 
-					err := deployment.Delete(fakeStage)
+					err := deployment.Delete(false, fakeStage)
 					Expect(err).ToNot(HaveOccurred())
 				})
 
@@ -377,7 +380,7 @@ var _ = Describe("Deployment", func() {
 						Message: "fake-disk-not-found-message",
 					}))
 
-					err := deployment.Delete(fakeStage)
+					err := deployment.Delete(false, fakeStage)
 					Expect(err).ToNot(HaveOccurred())
 				})
 			})
@@ -386,7 +389,7 @@ var _ = Describe("Deployment", func() {
 		Context("when a current stemcell exists", func() {
 			BeforeEach(func() {
 				deploymentStateService.Save(biconfig.DeploymentState{})
-				stemcellRecord, err := stemcellRepo.Save("fake-stemcell-name", "fake-stemcell-version", "fake-stemcell-cid")
+				stemcellRecord, err := stemcellRepo.Save("fake-stemcell-name", "fake-stemcell-version", "", "fake-stemcell-cid")
 				Expect(err).ToNot(HaveOccurred())
 				stemcellRepo.UpdateCurrent(stemcellRecord.ID)
 			})
@@ -394,7 +397,7 @@ var _ = Describe("Deployment", func() {
 			It("deletes the stemcell", func() {
 				mockCloud.EXPECT().DeleteStemcell("fake-stemcell-cid")
 
-				err := deployment.Delete(fakeStage)
+				err := deployment.Delete(false, fakeStage)
 				Expect(err).ToNot(HaveOccurred())
 			})
 
@@ -402,7 +405,7 @@ var _ = Describe("Deployment", func() {
 				It("deletes the stemcell (to ensure related resources are released by the CPI)", func() {
 					mockCloud.EXPECT().DeleteStemcell("fake-stemcell-cid")
 
-					err := deployment.Delete(fakeStage)
+					err := deployment.Delete(false, fakeStage)
 					Expect(err).ToNot(HaveOccurred())
 				})
 
@@ -412,7 +415,7 @@ var _ = Describe("Deployment", func() {
 						Message: "fake-stemcell-not-found-message",
 					}))
 
-					err := deployment.Delete(fakeStage)
+					err := deployment.Delete(false, fakeStage)
 					Expect(err).ToNot(HaveOccurred())
 				})
 			})
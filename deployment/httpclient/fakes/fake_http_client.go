@@ -36,6 +36,7 @@ type deleteInput struct {
 
 type getInput struct {
 	Endpoint string
+	Headers  http.Header
 }
 
 type output struct {
@@ -75,6 +76,24 @@ func (c *FakeHTTPClient) Get(endpoint string) (*http.Response, error) {
 	return getReturn.response, getReturn.err
 }
 
+func (c *FakeHTTPClient) GetCustomized(endpoint string, f func(*http.Request)) (*http.Response, error) {
+	request, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	f(request)
+
+	c.GetInputs = append(c.GetInputs, getInput{
+		Endpoint: endpoint,
+		Headers:  request.Header,
+	})
+
+	getReturn := c.getOutputs[0]
+	c.getOutputs = c.getOutputs[1:]
+
+	return getReturn.response, getReturn.err
+}
+
 func (c *FakeHTTPClient) Put(endpoint string, payload []byte) (*http.Response, error) {
 	c.PutInputs = append(c.PutInputs, putInput{
 		Payload:  payload,
@@ -122,8 +141,9 @@ func (c *FakeHTTPClient) SetPutBehavior(body string, statusCode int, err error)
 
 func (c *FakeHTTPClient) SetGetBehavior(body string, statusCode int, err error) {
 	getResponse := &http.Response{
-		Body:       ioutil.NopCloser(strings.NewReader(body)),
-		StatusCode: statusCode,
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+		StatusCode:    statusCode,
+		ContentLength: int64(len(body)),
 	}
 
 	c.getOutputs = append(c.getOutputs, output{
@@ -132,6 +152,14 @@ func (c *FakeHTTPClient) SetGetBehavior(body string, statusCode int, err error)
 	})
 }
 
+// SetGetResponse queues a complete response, e.g. with headers, to be returned by Get or GetCustomized
+func (c *FakeHTTPClient) SetGetResponse(response *http.Response, err error) {
+	c.getOutputs = append(c.getOutputs, output{
+		response: response,
+		err:      err,
+	})
+}
+
 func (c *FakeHTTPClient) SetDeleteBehavior(body string, statusCode int, err error) {
 	deleteResponse := &http.Response{
 		Body:       ioutil.NopCloser(strings.NewReader(body)),
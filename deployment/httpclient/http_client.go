@@ -27,6 +27,7 @@ type HTTPClient interface {
 	Post(endpoint string, payload []byte) (*http.Response, error)
 	Put(endpoint string, payload []byte) (*http.Response, error)
 	Get(endpoint string) (*http.Response, error)
+	GetCustomized(endpoint string, f func(*http.Request)) (*http.Response, error)
 	Delete(endpoint string) (*http.Response, error)
 }
 
@@ -85,6 +86,24 @@ func (c httpClient) Get(endpoint string) (*http.Response, error) {
 	return response, nil
 }
 
+// GetCustomized sends a GET request after f has customized it (e.g. set headers)
+func (c httpClient) GetCustomized(endpoint string, f func(*http.Request)) (*http.Response, error) {
+	c.logger.Debug(c.logTag, "Sending GET request with endpoint %s", endpoint)
+
+	request, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Creating GET request")
+	}
+
+	f(request)
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Performing GET request")
+	}
+	return response, nil
+}
+
 func (c httpClient) Delete(endpoint string) (*http.Response, error) {
 	c.logger.Debug(c.logTag, "Sending DELETE request with endpoint %s", endpoint)
 
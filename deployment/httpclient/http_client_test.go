@@ -83,6 +83,35 @@ var _ = Describe("HttpClient", func() {
 			))
 		})
 	})
+
+	Describe("GetCustomized", func() {
+		It("makes a get request customized by the given function", func() {
+			fakeServer.SetResponseBody("fake-get-response")
+			fakeServer.SetResponseStatus(200)
+
+			var customizedRequest *http.Request
+			response, err := httpClient.GetCustomized("http://localhost:6305/fake-path", func(request *http.Request) {
+				request.Header.Set("Range", "bytes=10-")
+				customizedRequest = request
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			defer response.Body.Close()
+			responseBody, err := ioutil.ReadAll(response.Body)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(responseBody).To(Equal([]byte("fake-get-response")))
+			Expect(customizedRequest.Method).To(Equal("GET"))
+			Expect(customizedRequest.Header.Get("Range")).To(Equal("bytes=10-"))
+
+			Expect(fakeServer.ReceivedRequests).To(ContainElement(
+				receivedRequest{
+					Body:   []byte(""),
+					Method: "GET",
+				},
+			))
+		})
+	})
 })
 
 type receivedRequestBody struct {
@@ -8,6 +8,7 @@ import (
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 
 	bicloud "github.com/cloudfoundry/bosh-init/cloud"
+	biagentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient"
 	bidisk "github.com/cloudfoundry/bosh-init/deployment/disk"
 	biinstancestate "github.com/cloudfoundry/bosh-init/deployment/instance/state"
 	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
@@ -17,16 +18,23 @@ import (
 	biui "github.com/cloudfoundry/bosh-init/ui"
 )
 
+// scriptTimeout lets the agent client limit the job lifecycle scripts with update.agent_task_timeouts (run_script)
+const scriptTimeout = 0
+
 type Instance interface {
 	JobName() string
 	ID() int
 	Disks() ([]bidisk.Disk, error)
 	WaitUntilReady(biinstallmanifest.Registry, biui.Stage) error
 	UpdateDisks(bideplmanifest.Manifest, biui.Stage) ([]bidisk.Disk, error)
-	UpdateJobs(bideplmanifest.Manifest, biui.Stage) error
+	UpdateJobs(deploymentManifest bideplmanifest.Manifest, skipDrain bool, stage biui.Stage) error
+	RunPostDeploy(stage biui.Stage) error
+	RunErrand(errandName string, deploymentManifest bideplmanifest.Manifest, stage biui.Stage) (biagentclient.ErrandResult, error)
+	FetchLogs(stage biui.Stage) (blobID string, err error)
 	Delete(
 		pingTimeout time.Duration,
 		pingDelay time.Duration,
+		skipDrain bool,
 		stage biui.Stage,
 	) error
 }
@@ -129,6 +137,7 @@ func (i *instance) UpdateDisks(deploymentManifest bideplmanifest.Manifest, stage
 
 func (i *instance) UpdateJobs(
 	deploymentManifest bideplmanifest.Manifest,
+	skipDrain bool,
 	stage biui.Stage,
 ) error {
 	newState, err := i.stateBuilder.Build(i.jobName, i.id, deploymentManifest, stage)
@@ -136,18 +145,33 @@ func (i *instance) UpdateJobs(
 		return bosherr.WrapErrorf(err, "Building state for instance '%s/%d'", i.jobName, i.id)
 	}
 
+	newApplySpec := newState.ToApplySpec()
+
 	stepName := fmt.Sprintf("Updating instance '%s/%d'", i.jobName, i.id)
+	var preStartResult biagentclient.ScriptResult
 	err = stage.Perform(stepName, func() error {
+		if !skipDrain {
+			err := i.vm.Drain(biagentclient.DrainTypeUpdate, newApplySpec)
+			if err != nil {
+				return bosherr.WrapError(err, "Draining the agent")
+			}
+		}
+
 		err := i.vm.Stop()
 		if err != nil {
 			return bosherr.WrapError(err, "Stopping the agent")
 		}
 
-		err = i.vm.Apply(newState.ToApplySpec())
+		err = i.vm.Apply(newApplySpec)
 		if err != nil {
 			return bosherr.WrapError(err, "Applying the agent state")
 		}
 
+		preStartResult, err = i.vm.RunScript("pre-start", scriptTimeout)
+		if err != nil {
+			return bosherr.WrapError(err, "Running the pre-start script")
+		}
+
 		err = i.vm.Start()
 		if err != nil {
 			return bosherr.WrapError(err, "Starting the agent")
@@ -155,16 +179,103 @@ func (i *instance) UpdateJobs(
 
 		return nil
 	})
+	i.printScriptOutput("pre-start", preStartResult, stage)
 	if err != nil {
 		return err
 	}
 
-	return i.waitUntilJobsAreRunning(deploymentManifest.Update.UpdateWatchTime, stage)
+	err = i.waitUntilJobsAreRunning(deploymentManifest.Update.UpdateWatchTime, stage)
+	if err != nil {
+		return err
+	}
+
+	stepName = fmt.Sprintf("Running post-start script on instance '%s/%d'", i.jobName, i.id)
+	var postStartResult biagentclient.ScriptResult
+	err = stage.Perform(stepName, func() error {
+		var err error
+		postStartResult, err = i.vm.RunScript("post-start", scriptTimeout)
+		return err
+	})
+	i.printScriptOutput("post-start", postStartResult, stage)
+	return err
+}
+
+// RunPostDeploy runs the post-deploy script, once all the instances of the deployment are updated
+func (i *instance) RunPostDeploy(stage biui.Stage) error {
+	stepName := fmt.Sprintf("Running post-deploy script on instance '%s/%d'", i.jobName, i.id)
+	var postDeployResult biagentclient.ScriptResult
+	err := stage.Perform(stepName, func() error {
+		var err error
+		postDeployResult, err = i.vm.RunScript("post-deploy", scriptTimeout)
+		if err != nil {
+			// a failing post-deploy script does not undo the deploy, so it is only reported
+			return biui.NewSkipStageError(err, "post-deploy script failed")
+		}
+		return nil
+	})
+	i.printScriptOutput("post-deploy", postDeployResult, stage)
+	return err
+}
+
+// printScriptOutput reports the output of a job lifecycle script in the event log, after the step that ran it
+func (i *instance) printScriptOutput(scriptName string, result biagentclient.ScriptResult, stage biui.Stage) {
+	stage.PrintOutput(fmt.Sprintf("%s script stdout", scriptName), result.Stdout)
+	stage.PrintOutput(fmt.Sprintf("%s script stderr", scriptName), result.Stderr)
+}
+
+func (i *instance) RunErrand(
+	errandName string,
+	deploymentManifest bideplmanifest.Manifest,
+	stage biui.Stage,
+) (biagentclient.ErrandResult, error) {
+	errandJob, found := deploymentManifest.FindJobByName(errandName)
+	if found && errandJob.IsErrand() {
+		// errands that are not colocated run on their own VM, which needs the errand job applied first
+		newState, err := i.stateBuilder.Build(errandName, i.id, deploymentManifest, stage)
+		if err != nil {
+			return biagentclient.ErrandResult{}, bosherr.WrapErrorf(err, "Building state for errand '%s'", errandName)
+		}
+
+		stepName := fmt.Sprintf("Applying errand '%s' to instance '%s/%d'", errandName, i.jobName, i.id)
+		err = stage.Perform(stepName, func() error {
+			err := i.vm.Apply(newState.ToApplySpec())
+			if err != nil {
+				return bosherr.WrapError(err, "Applying the agent state")
+			}
+			return nil
+		})
+		if err != nil {
+			return biagentclient.ErrandResult{}, err
+		}
+	}
+
+	var result biagentclient.ErrandResult
+	stepName := fmt.Sprintf("Running errand '%s' on instance '%s/%d'", errandName, i.jobName, i.id)
+	err := stage.Perform(stepName, func() error {
+		var err error
+		result, err = i.vm.RunErrand(errandName)
+		return err
+	})
+
+	return result, err
+}
+
+func (i *instance) FetchLogs(stage biui.Stage) (string, error) {
+	var blobID string
+	stepName := fmt.Sprintf("Fetching logs from instance '%s/%d'", i.jobName, i.id)
+	err := stage.Perform(stepName, func() error {
+		var err error
+		blobID, err = i.vm.FetchLogs()
+		return err
+	})
+
+	return blobID, err
 }
 
 func (i *instance) Delete(
 	pingTimeout time.Duration,
 	pingDelay time.Duration,
+	skipDrain bool,
 	stage biui.Stage,
 ) error {
 	vmExists, err := i.vm.Exists()
@@ -173,7 +284,7 @@ func (i *instance) Delete(
 	}
 
 	if vmExists {
-		if err = i.shutdown(pingTimeout, pingDelay, stage); err != nil {
+		if err = i.shutdown(pingTimeout, pingDelay, skipDrain, stage); err != nil {
 			return err
 		}
 	}
@@ -193,6 +304,7 @@ func (i *instance) Delete(
 func (i *instance) shutdown(
 	pingTimeout time.Duration,
 	pingDelay time.Duration,
+	skipDrain bool,
 	stage biui.Stage,
 ) error {
 	stepName := fmt.Sprintf("Waiting for the agent on VM '%s'", i.vm.CID())
@@ -207,7 +319,7 @@ func (i *instance) shutdown(
 		return nil
 	}
 
-	if err := i.stopJobs(stage); err != nil {
+	if err := i.stopJobs(skipDrain, stage); err != nil {
 		return err
 	}
 	if err := i.unmountDisks(stage); err != nil {
@@ -229,9 +341,14 @@ func (i *instance) waitUntilJobsAreRunning(updateWatchTime bideplmanifest.WatchT
 	})
 }
 
-func (i *instance) stopJobs(stage biui.Stage) error {
+func (i *instance) stopJobs(skipDrain bool, stage biui.Stage) error {
 	stepName := fmt.Sprintf("Stopping jobs on instance '%s/%d'", i.jobName, i.id)
 	return stage.Perform(stepName, func() error {
+		if !skipDrain {
+			if err := i.vm.Drain(biagentclient.DrainTypeShutdown); err != nil {
+				return bosherr.WrapError(err, "Draining the agent")
+			}
+		}
 		return i.vm.Stop()
 	})
 }
@@ -15,6 +15,7 @@ import (
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 
 	bicloud "github.com/cloudfoundry/bosh-init/cloud"
+	biagentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient"
 	bias "github.com/cloudfoundry/bosh-init/deployment/applyspec"
 	bidisk "github.com/cloudfoundry/bosh-init/deployment/disk"
 	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
@@ -86,7 +87,7 @@ var _ = Describe("Instance", func() {
 
 	Describe("Delete", func() {
 		It("checks if the agent on the vm is responsive", func() {
-			err := instance.Delete(pingTimeout, pingDelay, fakeStage)
+			err := instance.Delete(pingTimeout, pingDelay, false, fakeStage)
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(fakeVM.WaitUntilReadyInputs).To(ContainElement(fakebivm.WaitUntilReadyInput{
@@ -96,14 +97,14 @@ var _ = Describe("Instance", func() {
 		})
 
 		It("deletes existing vm", func() {
-			err := instance.Delete(pingTimeout, pingDelay, fakeStage)
+			err := instance.Delete(pingTimeout, pingDelay, false, fakeStage)
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(fakeVM.DeleteCalled).To(Equal(1))
 		})
 
 		It("logs start and stop events", func() {
-			err := instance.Delete(pingTimeout, pingDelay, fakeStage)
+			err := instance.Delete(pingTimeout, pingDelay, false, fakeStage)
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(fakeStage.PerformCalls).To(Equal([]fakebiui.PerformCall{
@@ -115,7 +116,7 @@ var _ = Describe("Instance", func() {
 
 		Context("when agent is responsive", func() {
 			It("logs waiting for the agent event", func() {
-				err := instance.Delete(pingTimeout, pingDelay, fakeStage)
+				err := instance.Delete(pingTimeout, pingDelay, false, fakeStage)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(fakeStage.PerformCalls[0]).To(Equal(fakebiui.PerformCall{
@@ -123,19 +124,58 @@ var _ = Describe("Instance", func() {
 				}))
 			})
 
+			It("drains the jobs before stopping them", func() {
+				err := instance.Delete(pingTimeout, pingDelay, false, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeVM.DrainInputs).To(Equal([]fakebivm.DrainInput{
+					{DrainType: biagentclient.DrainTypeShutdown},
+				}))
+			})
+
 			It("stops vm", func() {
-				err := instance.Delete(pingTimeout, pingDelay, fakeStage)
+				err := instance.Delete(pingTimeout, pingDelay, false, fakeStage)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(fakeVM.StopCalled).To(Equal(1))
 			})
 
+			Context("when skipping drain", func() {
+				It("stops vm without draining", func() {
+					err := instance.Delete(pingTimeout, pingDelay, true, fakeStage)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(fakeVM.DrainInputs).To(BeEmpty())
+					Expect(fakeVM.StopCalled).To(Equal(1))
+				})
+			})
+
+			Context("when draining vm fails", func() {
+				var (
+					drainError = bosherr.Error("fake-drain-error")
+				)
+
+				BeforeEach(func() {
+					fakeVM.DrainErr = drainError
+				})
+
+				It("returns an error and does not stop the vm", func() {
+					err := instance.Delete(pingTimeout, pingDelay, false, fakeStage)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-drain-error"))
+
+					Expect(fakeVM.StopCalled).To(Equal(0))
+					Expect(fakeStage.PerformCalls[1].Name).To(Equal("Stopping jobs on instance 'fake-job-name/0'"))
+					Expect(fakeStage.PerformCalls[1].Error.Error()).To(Equal("Draining the agent: fake-drain-error"))
+				})
+			})
+
 			It("unmounts vm disks", func() {
 				firstDisk := fakebidisk.NewFakeDisk("fake-disk-1")
 				secondDisk := fakebidisk.NewFakeDisk("fake-disk-2")
 				fakeVM.ListDisksDisks = []bidisk.Disk{firstDisk, secondDisk}
 
-				err := instance.Delete(pingTimeout, pingDelay, fakeStage)
+				err := instance.Delete(pingTimeout, pingDelay, false, fakeStage)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(fakeVM.UnmountDiskInputs).To(Equal([]fakebivm.UnmountDiskInput{
@@ -159,7 +199,7 @@ var _ = Describe("Instance", func() {
 				})
 
 				It("returns an error", func() {
-					err := instance.Delete(pingTimeout, pingDelay, fakeStage)
+					err := instance.Delete(pingTimeout, pingDelay, false, fakeStage)
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(ContainSubstring("fake-stop-error"))
 
@@ -180,7 +220,7 @@ var _ = Describe("Instance", func() {
 				})
 
 				It("returns an error", func() {
-					err := instance.Delete(pingTimeout, pingDelay, fakeStage)
+					err := instance.Delete(pingTimeout, pingDelay, false, fakeStage)
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(ContainSubstring("fake-unmount-error"))
 
@@ -197,7 +237,7 @@ var _ = Describe("Instance", func() {
 			})
 
 			It("logs failed event", func() {
-				err := instance.Delete(pingTimeout, pingDelay, fakeStage)
+				err := instance.Delete(pingTimeout, pingDelay, false, fakeStage)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(fakeStage.PerformCalls[0].Name).To(Equal("Waiting for the agent on VM 'fake-vm-cid'"))
@@ -215,7 +255,7 @@ var _ = Describe("Instance", func() {
 			})
 
 			It("returns an error", func() {
-				err := instance.Delete(pingTimeout, pingDelay, fakeStage)
+				err := instance.Delete(pingTimeout, pingDelay, false, fakeStage)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-delete-error"))
 
@@ -240,14 +280,14 @@ var _ = Describe("Instance", func() {
 			})
 
 			It("deletes existing vm", func() {
-				err := instance.Delete(pingTimeout, pingDelay, fakeStage)
+				err := instance.Delete(pingTimeout, pingDelay, false, fakeStage)
 				Expect(err).NotTo(HaveOccurred())
 
 				Expect(fakeVM.DeleteCalled).To(Equal(1))
 			})
 
 			It("does not contact the agent", func() {
-				err := instance.Delete(pingTimeout, pingDelay, fakeStage)
+				err := instance.Delete(pingTimeout, pingDelay, false, fakeStage)
 				Expect(err).NotTo(HaveOccurred())
 
 				Expect(fakeVM.WaitUntilReadyInputs).To(HaveLen(0))
@@ -256,7 +296,7 @@ var _ = Describe("Instance", func() {
 			})
 
 			It("logs vm delete as skipped", func() {
-				err := instance.Delete(pingTimeout, pingDelay, fakeStage)
+				err := instance.Delete(pingTimeout, pingDelay, false, fakeStage)
 				Expect(err).NotTo(HaveOccurred())
 
 				Expect(fakeStage.PerformCalls[0].Name).To(Equal("Deleting VM 'fake-vm-cid'"))
@@ -300,12 +340,12 @@ var _ = Describe("Instance", func() {
 		It("builds a new instance state", func() {
 			expectStateBuild.Times(1)
 
-			err := instance.UpdateJobs(deploymentManifest, fakeStage)
+			err := instance.UpdateJobs(deploymentManifest, false, fakeStage)
 			Expect(err).ToNot(HaveOccurred())
 		})
 
 		It("tells agent to stop jobs, apply a new spec (with new rendered jobs templates), and start jobs", func() {
-			err := instance.UpdateJobs(deploymentManifest, fakeStage)
+			err := instance.UpdateJobs(deploymentManifest, false, fakeStage)
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(fakeVM.StopCalled).To(Equal(1))
@@ -315,8 +355,42 @@ var _ = Describe("Instance", func() {
 			Expect(fakeVM.StartCalled).To(Equal(1))
 		})
 
+		It("drains the jobs with the new spec before stopping them", func() {
+			err := instance.UpdateJobs(deploymentManifest, false, fakeStage)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeVM.DrainInputs).To(Equal([]fakebivm.DrainInput{
+				{DrainType: biagentclient.DrainTypeUpdate, NewSpecs: []bias.ApplySpec{applySpec}},
+			}))
+		})
+
+		Context("when skipping drain", func() {
+			It("stops the jobs without draining", func() {
+				err := instance.UpdateJobs(deploymentManifest, true, fakeStage)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeVM.DrainInputs).To(BeEmpty())
+				Expect(fakeVM.StopCalled).To(Equal(1))
+			})
+		})
+
+		Context("when draining vm fails", func() {
+			BeforeEach(func() {
+				fakeVM.DrainErr = bosherr.Error("fake-drain-error")
+			})
+
+			It("returns an error", func() {
+				err := instance.UpdateJobs(deploymentManifest, false, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-drain-error"))
+
+				Expect(fakeVM.StopCalled).To(Equal(0))
+				Expect(fakeStage.PerformCalls[0].Error.Error()).To(Equal("Draining the agent: fake-drain-error"))
+			})
+		})
+
 		It("waits until agent reports state as running", func() {
-			err := instance.UpdateJobs(deploymentManifest, fakeStage)
+			err := instance.UpdateJobs(deploymentManifest, false, fakeStage)
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(fakeVM.WaitToBeRunningInputs).To(ContainElement(fakebivm.WaitInput{
@@ -326,22 +400,93 @@ var _ = Describe("Instance", func() {
 		})
 
 		It("logs start and stop events to the eventLogger", func() {
-			err := instance.UpdateJobs(deploymentManifest, fakeStage)
+			err := instance.UpdateJobs(deploymentManifest, false, fakeStage)
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(fakeStage.PerformCalls).To(Equal([]fakebiui.PerformCall{
 				{Name: "Updating instance 'fake-job-name/0'"},
 				{Name: "Waiting for instance 'fake-job-name/0' to be running"},
+				{Name: "Running post-start script on instance 'fake-job-name/0'"},
+			}))
+		})
+
+		It("runs the lifecycle scripts around starting the jobs, limited by the agent task timeouts", func() {
+			err := instance.UpdateJobs(deploymentManifest, false, fakeStage)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeVM.RunScriptInputs).To(Equal([]fakebivm.RunScriptInput{
+				{ScriptName: "pre-start", Timeout: 0},
+				{ScriptName: "post-start", Timeout: 0},
 			}))
 		})
 
+		It("reports the output of the lifecycle scripts in the event log", func() {
+			fakeVM.RunScriptResults["pre-start"] = biagentclient.ScriptResult{Stdout: "fake-pre-start-stdout"}
+			fakeVM.RunScriptResults["post-start"] = biagentclient.ScriptResult{Stderr: "fake-post-start-stderr"}
+
+			err := instance.UpdateJobs(deploymentManifest, false, fakeStage)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeStage.OutputCalls).To(Equal([]fakebiui.OutputCall{
+				{Name: "pre-start script stdout", Output: "fake-pre-start-stdout"},
+				{Name: "pre-start script stderr", Output: ""},
+				{Name: "post-start script stdout", Output: ""},
+				{Name: "post-start script stderr", Output: "fake-post-start-stderr"},
+			}))
+		})
+
+		Context("when the pre-start script fails", func() {
+			BeforeEach(func() {
+				fakeVM.RunScriptErrs["pre-start"] = bosherr.Error("fake-pre-start-error")
+			})
+
+			It("does not start the jobs and returns an error", func() {
+				err := instance.UpdateJobs(deploymentManifest, false, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-pre-start-error"))
+
+				Expect(fakeVM.StartCalled).To(Equal(0))
+				Expect(fakeStage.PerformCalls[0].Error.Error()).To(Equal("Running the pre-start script: fake-pre-start-error"))
+			})
+		})
+
+		Context("when the post-start script fails", func() {
+			var (
+				postStartError = bosherr.Error("Script 'post-start' exited with status 1: fake-stderr")
+			)
+
+			BeforeEach(func() {
+				fakeVM.RunScriptErrs["post-start"] = postStartError
+				fakeVM.RunScriptResults["post-start"] = biagentclient.ScriptResult{ExitStatus: 1, Stderr: "fake-stderr"}
+			})
+
+			It("reports the script output in the event log", func() {
+				err := instance.UpdateJobs(deploymentManifest, false, fakeStage)
+				Expect(err).To(HaveOccurred())
+
+				Expect(fakeStage.OutputCalls).To(ContainElement(fakebiui.OutputCall{Name: "post-start script stderr", Output: "fake-stderr"}))
+			})
+
+			It("fails the update with the script stderr", func() {
+				err := instance.UpdateJobs(deploymentManifest, false, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-stderr"))
+
+				Expect(fakeStage.PerformCalls[2]).To(Equal(fakebiui.PerformCall{
+					Name:  "Running post-start script on instance 'fake-job-name/0'",
+					Error: postStartError,
+				}))
+				Expect(fakeStage.PerformCalls).To(HaveLen(3))
+			})
+		})
+
 		Context("when instance state building fails", func() {
 			JustBeforeEach(func() {
 				expectStateBuild.Return(nil, bosherr.Error("fake-template-err")).Times(1)
 			})
 
 			It("returns an error", func() {
-				err := instance.UpdateJobs(deploymentManifest, fakeStage)
+				err := instance.UpdateJobs(deploymentManifest, false, fakeStage)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-template-err"))
 			})
@@ -353,7 +498,7 @@ var _ = Describe("Instance", func() {
 			})
 
 			It("logs start and stop events to the eventLogger", func() {
-				err := instance.UpdateJobs(deploymentManifest, fakeStage)
+				err := instance.UpdateJobs(deploymentManifest, false, fakeStage)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-stop-error"))
 
@@ -369,7 +514,7 @@ var _ = Describe("Instance", func() {
 			})
 
 			It("logs start and stop events to the eventLogger", func() {
-				err := instance.UpdateJobs(deploymentManifest, fakeStage)
+				err := instance.UpdateJobs(deploymentManifest, false, fakeStage)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-apply-error"))
 
@@ -385,7 +530,7 @@ var _ = Describe("Instance", func() {
 			})
 
 			It("logs start and stop events to the eventLogger", func() {
-				err := instance.UpdateJobs(deploymentManifest, fakeStage)
+				err := instance.UpdateJobs(deploymentManifest, false, fakeStage)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-start-error"))
 
@@ -405,7 +550,7 @@ var _ = Describe("Instance", func() {
 			})
 
 			It("logs instance update stages", func() {
-				err := instance.UpdateJobs(deploymentManifest, fakeStage)
+				err := instance.UpdateJobs(deploymentManifest, false, fakeStage)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-wait-running-error"))
 
@@ -420,6 +565,129 @@ var _ = Describe("Instance", func() {
 		})
 	})
 
+	Describe("RunPostDeploy", func() {
+		It("runs the post-deploy script, limited by the agent task timeouts", func() {
+			fakeVM.RunScriptResults["post-deploy"] = biagentclient.ScriptResult{Stdout: "fake-post-deploy-stdout"}
+
+			err := instance.RunPostDeploy(fakeStage)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeVM.RunScriptInputs).To(Equal([]fakebivm.RunScriptInput{
+				{ScriptName: "post-deploy", Timeout: 0},
+			}))
+			Expect(fakeStage.PerformCalls).To(Equal([]fakebiui.PerformCall{
+				{Name: "Running post-deploy script on instance 'fake-job-name/0'"},
+			}))
+			Expect(fakeStage.OutputCalls).To(Equal([]fakebiui.OutputCall{
+				{Name: "post-deploy script stdout", Output: "fake-post-deploy-stdout"},
+				{Name: "post-deploy script stderr", Output: ""},
+			}))
+		})
+
+		Context("when the post-deploy script fails", func() {
+			BeforeEach(func() {
+				fakeVM.RunScriptErrs["post-deploy"] = bosherr.Error("fake-post-deploy-error")
+			})
+
+			It("reports the failure without failing the deploy", func() {
+				err := instance.RunPostDeploy(fakeStage)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeStage.PerformCalls[0].Name).To(Equal("Running post-deploy script on instance 'fake-job-name/0'"))
+				Expect(fakeStage.PerformCalls[0].SkipError).To(HaveOccurred())
+				Expect(fakeStage.PerformCalls[0].SkipError.Error()).To(ContainSubstring("fake-post-deploy-error"))
+			})
+		})
+	})
+
+	Describe("RunErrand", func() {
+		var (
+			deploymentManifest bideplmanifest.Manifest
+			applySpec          bias.ApplySpec
+		)
+
+		BeforeEach(func() {
+			deploymentManifest = bideplmanifest.Manifest{
+				Name: "fake-deployment-name",
+				Jobs: []bideplmanifest.Job{
+					{
+						Name:      "fake-job-name",
+						Templates: []bideplmanifest.ReleaseJobRef{{Name: "fake-colocated-errand-name"}},
+					},
+					{
+						Name:      "fake-errand-name",
+						Lifecycle: bideplmanifest.JobLifecycleErrand,
+					},
+				},
+			}
+
+			applySpec = bias.ApplySpec{
+				Deployment: "fake-deployment-name",
+			}
+
+			fakeVM.RunErrandResult = biagentclient.ErrandResult{ExitCode: 0, Stdout: "fake-stdout"}
+		})
+
+		Context("when the errand is a dedicated errand job", func() {
+			JustBeforeEach(func() {
+				mockStateBuilder.EXPECT().Build("fake-errand-name", jobIndex, deploymentManifest, fakeStage).Return(mockState, nil)
+				mockState.EXPECT().ToApplySpec().Return(applySpec)
+			})
+
+			It("applies the errand job and runs the errand", func() {
+				result, err := instance.RunErrand("fake-errand-name", deploymentManifest, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result).To(Equal(biagentclient.ErrandResult{ExitCode: 0, Stdout: "fake-stdout"}))
+
+				Expect(fakeVM.ApplyInputs).To(Equal([]fakebivm.ApplyInput{
+					{ApplySpec: applySpec},
+				}))
+				Expect(fakeVM.RunErrandInputs).To(Equal([]string{"fake-errand-name"}))
+
+				Expect(fakeStage.PerformCalls).To(Equal([]fakebiui.PerformCall{
+					{Name: "Applying errand 'fake-errand-name' to instance 'fake-job-name/0'"},
+					{Name: "Running errand 'fake-errand-name' on instance 'fake-job-name/0'"},
+				}))
+			})
+		})
+
+		Context("when the errand is colocated with the deployed job", func() {
+			It("runs the errand without applying a new state", func() {
+				_, err := instance.RunErrand("fake-colocated-errand-name", deploymentManifest, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeVM.ApplyInputs).To(BeEmpty())
+				Expect(fakeVM.RunErrandInputs).To(Equal([]string{"fake-colocated-errand-name"}))
+			})
+		})
+
+		Context("when running the errand fails", func() {
+			BeforeEach(func() {
+				fakeVM.RunErrandErr = bosherr.Error("fake-run-errand-error")
+			})
+
+			It("returns an error", func() {
+				_, err := instance.RunErrand("fake-colocated-errand-name", deploymentManifest, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-run-errand-error"))
+			})
+		})
+	})
+
+	Describe("FetchLogs", func() {
+		It("fetches the logs from the vm", func() {
+			fakeVM.FetchLogsBlobID = "fake-logs-blob-id"
+
+			blobID, err := instance.FetchLogs(fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blobID).To(Equal("fake-logs-blob-id"))
+
+			Expect(fakeStage.PerformCalls).To(Equal([]fakebiui.PerformCall{
+				{Name: "Fetching logs from instance 'fake-job-name/0'"},
+			}))
+		})
+	})
+
 	Describe("WaitUntilReady", func() {
 		var (
 			registryConfig biinstallmanifest.Registry
@@ -31,6 +31,7 @@ type Manager interface {
 	DeleteAll(
 		pingTimeout time.Duration,
 		pingDelay time.Duration,
+		skipDrain bool,
 		eventLoggerStage biui.Stage,
 	) error
 }
@@ -105,7 +106,7 @@ func (m *manager) Create(
 	stepName := fmt.Sprintf("Creating VM for instance '%s/%d' from stemcell '%s'", jobName, id, cloudStemcell.CID())
 	err := eventLoggerStage.Perform(stepName, func() error {
 		var err error
-		vm, err = m.vmManager.Create(cloudStemcell, deploymentManifest)
+		vm, err = m.vmManager.Create(jobName, cloudStemcell, deploymentManifest)
 		if err != nil {
 			return bosherr.WrapError(err, "Creating VM")
 		}
@@ -137,6 +138,7 @@ func (m *manager) Create(
 func (m *manager) DeleteAll(
 	pingTimeout time.Duration,
 	pingDelay time.Duration,
+	skipDrain bool,
 	eventLoggerStage biui.Stage,
 ) error {
 	instances, err := m.FindCurrent()
@@ -145,7 +147,7 @@ func (m *manager) DeleteAll(
 	}
 
 	for _, instance := range instances {
-		if err = instance.Delete(pingTimeout, pingDelay, eventLoggerStage); err != nil {
+		if err = instance.Delete(pingTimeout, pingDelay, skipDrain, eventLoggerStage); err != nil {
 			return bosherr.WrapErrorf(err, "Deleting existing instance '%s/%d'", instance.JobName(), instance.ID())
 		}
 	}
@@ -204,6 +204,7 @@ var _ = Describe("Manager", func() {
 			Expect(instance).To(Equal(expectedInstance))
 
 			Expect(fakeVMManager.CreateInput).To(Equal(fakebivm.CreateInput{
+				JobName:  "fake-job-name",
 				Stemcell: fakeCloudStemcell,
 				Manifest: deploymentManifest,
 			}))
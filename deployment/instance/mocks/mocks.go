@@ -5,6 +5,7 @@ package mocks
 
 import (
 	gomock "code.google.com/p/gomock/gomock"
+	agentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient"
 	disk "github.com/cloudfoundry/bosh-init/deployment/disk"
 	instance "github.com/cloudfoundry/bosh-init/deployment/instance"
 	manifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
@@ -35,14 +36,14 @@ func (_m *MockInstance) EXPECT() *_MockInstanceRecorder {
 	return _m.recorder
 }
 
-func (_m *MockInstance) Delete(_param0 time.Duration, _param1 time.Duration, _param2 ui.Stage) error {
-	ret := _m.ctrl.Call(_m, "Delete", _param0, _param1, _param2)
+func (_m *MockInstance) Delete(_param0 time.Duration, _param1 time.Duration, _param2 bool, _param3 ui.Stage) error {
+	ret := _m.ctrl.Call(_m, "Delete", _param0, _param1, _param2, _param3)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-func (_mr *_MockInstanceRecorder) Delete(arg0, arg1, arg2 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "Delete", arg0, arg1, arg2)
+func (_mr *_MockInstanceRecorder) Delete(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Delete", arg0, arg1, arg2, arg3)
 }
 
 func (_m *MockInstance) Disks() ([]disk.Disk, error) {
@@ -56,6 +57,17 @@ func (_mr *_MockInstanceRecorder) Disks() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Disks")
 }
 
+func (_m *MockInstance) FetchLogs(_param0 ui.Stage) (string, error) {
+	ret := _m.ctrl.Call(_m, "FetchLogs", _param0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockInstanceRecorder) FetchLogs(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "FetchLogs", arg0)
+}
+
 func (_m *MockInstance) ID() int {
 	ret := _m.ctrl.Call(_m, "ID")
 	ret0, _ := ret[0].(int)
@@ -76,6 +88,27 @@ func (_mr *_MockInstanceRecorder) JobName() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "JobName")
 }
 
+func (_m *MockInstance) RunErrand(_param0 string, _param1 manifest.Manifest, _param2 ui.Stage) (agentclient.ErrandResult, error) {
+	ret := _m.ctrl.Call(_m, "RunErrand", _param0, _param1, _param2)
+	ret0, _ := ret[0].(agentclient.ErrandResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockInstanceRecorder) RunErrand(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RunErrand", arg0, arg1, arg2)
+}
+
+func (_m *MockInstance) RunPostDeploy(_param0 ui.Stage) error {
+	ret := _m.ctrl.Call(_m, "RunPostDeploy", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockInstanceRecorder) RunPostDeploy(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RunPostDeploy", arg0)
+}
+
 func (_m *MockInstance) UpdateDisks(_param0 manifest.Manifest, _param1 ui.Stage) ([]disk.Disk, error) {
 	ret := _m.ctrl.Call(_m, "UpdateDisks", _param0, _param1)
 	ret0, _ := ret[0].([]disk.Disk)
@@ -87,14 +120,14 @@ func (_mr *_MockInstanceRecorder) UpdateDisks(arg0, arg1 interface{}) *gomock.Ca
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdateDisks", arg0, arg1)
 }
 
-func (_m *MockInstance) UpdateJobs(_param0 manifest.Manifest, _param1 ui.Stage) error {
-	ret := _m.ctrl.Call(_m, "UpdateJobs", _param0, _param1)
+func (_m *MockInstance) UpdateJobs(_param0 manifest.Manifest, _param1 bool, _param2 ui.Stage) error {
+	ret := _m.ctrl.Call(_m, "UpdateJobs", _param0, _param1, _param2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-func (_mr *_MockInstanceRecorder) UpdateJobs(arg0, arg1 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdateJobs", arg0, arg1)
+func (_mr *_MockInstanceRecorder) UpdateJobs(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdateJobs", arg0, arg1, arg2)
 }
 
 func (_m *MockInstance) WaitUntilReady(_param0 manifest0.Registry, _param1 ui.Stage) error {
@@ -140,14 +173,14 @@ func (_mr *_MockManagerRecorder) Create(arg0, arg1, arg2, arg3, arg4, arg5 inter
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Create", arg0, arg1, arg2, arg3, arg4, arg5)
 }
 
-func (_m *MockManager) DeleteAll(_param0 time.Duration, _param1 time.Duration, _param2 ui.Stage) error {
-	ret := _m.ctrl.Call(_m, "DeleteAll", _param0, _param1, _param2)
+func (_m *MockManager) DeleteAll(_param0 time.Duration, _param1 time.Duration, _param2 bool, _param3 ui.Stage) error {
+	ret := _m.ctrl.Call(_m, "DeleteAll", _param0, _param1, _param2, _param3)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-func (_mr *_MockManagerRecorder) DeleteAll(arg0, arg1, arg2 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteAll", arg0, arg1, arg2)
+func (_mr *_MockManagerRecorder) DeleteAll(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteAll", arg0, arg1, arg2, arg3)
 }
 
 func (_m *MockManager) FindCurrent() ([]instance.Instance, error) {
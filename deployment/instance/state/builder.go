@@ -63,19 +63,26 @@ func (b *builder) Build(jobName string, instanceID int, deploymentManifest bidep
 		return nil, bosherr.WrapErrorf(err, "Resolving jobs for instance '%s/%d'", jobName, instanceID)
 	}
 
-	renderedJobTemplates, err := b.renderJobTemplates(releaseJobs, deploymentJob.Properties, deploymentManifest.Properties, deploymentManifest.Name, stage)
+	networkInterfaces, err := deploymentManifest.NetworkInterfaces(deploymentJob.Name)
 	if err != nil {
-		return nil, bosherr.WrapErrorf(err, "Rendering job templates for instance '%s/%d'", jobName, instanceID)
+		return nil, bosherr.WrapErrorf(err, "Finding networks for job '%s", jobName)
 	}
 
-	compiledPackageRefs, err := b.jobDependencyCompiler.Compile(releaseJobs, stage)
+	instanceSpec := BuildInstanceSpec(deploymentManifest, deploymentJob, instanceID, networkInterfaces)
+
+	instanceSpec.Links, err = BuildLinks(deploymentManifest, deploymentJob, releaseJobs, b.releaseJobResolver)
 	if err != nil {
-		return nil, bosherr.WrapErrorf(err, "Compiling job package dependencies for instance '%s/%d'", jobName, instanceID)
+		return nil, bosherr.WrapErrorf(err, "Resolving links for instance '%s/%d'", jobName, instanceID)
 	}
 
-	networkInterfaces, err := deploymentManifest.NetworkInterfaces(deploymentJob.Name)
+	renderedJobTemplates, err := b.renderJobTemplates(releaseJobs, deploymentJob.Properties, deploymentManifest.Properties, deploymentManifest.Name, instanceSpec, stage)
 	if err != nil {
-		return nil, bosherr.WrapErrorf(err, "Finding networks for job '%s", jobName)
+		return nil, bosherr.WrapErrorf(err, "Rendering job templates for instance '%s/%d'", jobName, instanceID)
+	}
+
+	compiledPackageRefs, err := b.jobDependencyCompiler.Compile(releaseJobs, deploymentManifest.Compilation.Workers, stage)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Compiling job package dependencies for instance '%s/%d'", jobName, instanceID)
 	}
 
 	// convert map to array
@@ -143,6 +150,7 @@ func (b *builder) renderJobTemplates(
 	jobProperties biproperty.Map,
 	globalProperties biproperty.Map,
 	deploymentName string,
+	instanceSpec bitemplate.InstanceSpec,
 	stage biui.Stage,
 ) (renderedJobs, error) {
 	var (
@@ -150,7 +158,7 @@ func (b *builder) renderJobTemplates(
 		blobID                 string
 	)
 	err := stage.Perform("Rendering job templates", func() error {
-		renderedJobList, err := b.jobListRenderer.Render(releaseJobs, jobProperties, globalProperties, deploymentName)
+		renderedJobList, err := b.jobListRenderer.Render(releaseJobs, jobProperties, globalProperties, deploymentName, instanceSpec)
 		if err != nil {
 			return err
 		}
@@ -20,6 +20,7 @@ import (
 	bireljob "github.com/cloudfoundry/bosh-init/release/job"
 	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
 	bistatejob "github.com/cloudfoundry/bosh-init/state/job"
+	bitemplate "github.com/cloudfoundry/bosh-init/templatescompiler"
 
 	fakebiui "github.com/cloudfoundry/bosh-init/ui/fakes"
 )
@@ -74,6 +75,8 @@ func describeBuilder() {
 			releasePackageCPI     *birelpkg.Package
 
 			expectCompile *gomock.Call
+
+			renderedInstanceSpec bitemplate.InstanceSpec
 		)
 
 		BeforeEach(func() {
@@ -116,6 +119,9 @@ func describeBuilder() {
 				Properties: biproperty.Map{
 					"fake-job-property": "fake-global-property-value", //overridden by job property value
 				},
+				Compilation: bideplmanifest.Compilation{
+					Workers: 3,
+				},
 			}
 
 			fakeStage = fakebiui.NewFakeStage()
@@ -181,7 +187,7 @@ func describeBuilder() {
 					SHA1:        "fake-package-compiled-archive-sha1-cpi",
 				},
 			}
-			expectCompile = mockDependencyCompiler.EXPECT().Compile(releaseJobs, fakeStage).Return(compiledPackageRefs, nil).AnyTimes()
+			expectCompile = mockDependencyCompiler.EXPECT().Compile(releaseJobs, 3, fakeStage).Return(compiledPackageRefs, nil).AnyTimes()
 
 			jobProperties := biproperty.Map{
 				"fake-job-property": "fake-job-property-value",
@@ -189,7 +195,11 @@ func describeBuilder() {
 			globalProperties := biproperty.Map{
 				"fake-job-property": "fake-global-property-value",
 			}
-			mockJobListRenderer.EXPECT().Render(releaseJobs, jobProperties, globalProperties, "fake-deployment-name").Return(mockRenderedJobList, nil)
+			mockJobListRenderer.EXPECT().Render(releaseJobs, jobProperties, globalProperties, "fake-deployment-name", gomock.Any()).Do(
+				func(_, _, _, _ interface{}, instanceSpec bitemplate.InstanceSpec) {
+					renderedInstanceSpec = instanceSpec
+				},
+			).Return(mockRenderedJobList, nil)
 
 			mockRenderedJobList.EXPECT().DeleteSilently()
 
@@ -227,6 +237,74 @@ func describeBuilder() {
 			Expect(state.NetworkInterfaces()).To(HaveLen(1))
 		})
 
+		It("renders the job templates for the instance", func() {
+			_, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(renderedInstanceSpec).To(Equal(bitemplate.InstanceSpec{
+				ID:        "37994593-f084-56d9-af16-4af4ceb43d9e",
+				Index:     0,
+				Bootstrap: true,
+				Networks: map[string]bitemplate.NetworkSpec{
+					"fake-network-name": bitemplate.NetworkSpec{},
+				},
+				Links: map[string]map[string]bitemplate.LinkSpec{
+					"fake-release-job-name": {},
+				},
+			}))
+		})
+
+		Context("when the job has a static IP on a manual network", func() {
+			BeforeEach(func() {
+				deploymentManifest.Jobs[0].ResourcePool = "fake-resource-pool-name"
+				deploymentManifest.Jobs[0].Networks = []bideplmanifest.JobNetwork{
+					{
+						Name:      "fake-network-name",
+						StaticIPs: []string{"10.0.0.5"},
+						Default:   []bideplmanifest.NetworkDefault{"dns", "gateway"},
+					},
+				}
+				deploymentManifest.Networks = []bideplmanifest.Network{
+					{
+						Name: "fake-network-name",
+						Type: "manual",
+						Subnets: []bideplmanifest.Subnet{
+							{
+								Range:   "10.0.0.0/24",
+								Gateway: "10.0.0.1",
+								DNS:     []string{"10.0.0.2"},
+							},
+						},
+					},
+				}
+				deploymentManifest.ResourcePools = []bideplmanifest.ResourcePool{
+					{
+						Name: "fake-resource-pool-name",
+						CloudProperties: biproperty.Map{
+							"availability_zone": "fake-az",
+						},
+					},
+				}
+			})
+
+			It("renders the job templates with the network of the instance", func() {
+				_, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(renderedInstanceSpec.AZ).To(Equal("fake-az"))
+				Expect(renderedInstanceSpec.Networks).To(Equal(map[string]bitemplate.NetworkSpec{
+					"fake-network-name": bitemplate.NetworkSpec{
+						IP:      "10.0.0.5",
+						Netmask: "255.255.255.0",
+						Gateway: "10.0.0.1",
+						DNS:     []string{"10.0.0.2"},
+						Default: []string{"dns", "gateway"},
+					},
+				}))
+				Expect(renderedInstanceSpec.Address()).To(Equal("10.0.0.5"))
+			})
+		})
+
 		It("builds a new instance state with zero-to-many rendered jobs from one or more releases", func() {
 			state, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, fakeStage)
 			Expect(err).ToNot(HaveOccurred())
@@ -0,0 +1,56 @@
+package state
+
+import (
+	"crypto/sha1"
+	"fmt"
+
+	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
+	bitemplate "github.com/cloudfoundry/bosh-init/templatescompiler"
+)
+
+// BuildInstanceSpec describes an instance of the deployment job to its job templates
+func BuildInstanceSpec(
+	deploymentManifest bideplmanifest.Manifest,
+	deploymentJob bideplmanifest.Job,
+	instanceID int,
+	networkInterfaces map[string]biproperty.Map,
+) bitemplate.InstanceSpec {
+	networks := map[string]bitemplate.NetworkSpec{}
+	for _, jobNetwork := range deploymentJob.Networks {
+		networkInterface := networkInterfaces[jobNetwork.Name]
+
+		networkSpec := bitemplate.NetworkSpec{}
+		networkSpec.IP, _ = networkInterface["ip"].(string)
+		networkSpec.Netmask, _ = networkInterface["netmask"].(string)
+		networkSpec.Gateway, _ = networkInterface["gateway"].(string)
+		networkSpec.DNS, _ = networkInterface["dns"].([]string)
+		for _, networkDefault := range jobNetwork.Default {
+			networkSpec.Default = append(networkSpec.Default, string(networkDefault))
+		}
+		networks[jobNetwork.Name] = networkSpec
+	}
+
+	// manifests don't have AZs, but IaaSes take the zone of the VM from the resource pool
+	var az string
+	resourcePool, err := deploymentManifest.ResourcePool(deploymentJob.Name)
+	if err == nil {
+		az, _ = resourcePool.CloudProperties["availability_zone"].(string)
+	}
+
+	return bitemplate.InstanceSpec{
+		ID:        instanceUUID(deploymentManifest.Name, deploymentJob.Name, instanceID),
+		Index:     instanceID,
+		AZ:        az,
+		Bootstrap: instanceID == 0,
+		Networks:  networks,
+	}
+}
+
+// instanceUUID returns a name based (version 5) UUID, so that the instance keeps its ID across deploys
+func instanceUUID(deploymentName string, jobName string, index int) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s/%s/%d", deploymentName, jobName, index)))
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
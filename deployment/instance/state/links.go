@@ -0,0 +1,139 @@
+package state
+
+import (
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+
+	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
+	bideplrel "github.com/cloudfoundry/bosh-init/deployment/release"
+	bireljob "github.com/cloudfoundry/bosh-init/release/job"
+	bitemplate "github.com/cloudfoundry/bosh-init/templatescompiler"
+)
+
+// BuildLinks resolves the links consumed by the release jobs of the deployment job,
+// keyed by release job name and link name. Optional links that are not provided are left out.
+// The release jobs are those of the deployment job's templates, in the same order.
+func BuildLinks(
+	deploymentManifest bideplmanifest.Manifest,
+	deploymentJob bideplmanifest.Job,
+	releaseJobs []bireljob.Job,
+	releaseJobResolver bideplrel.JobResolver,
+) (map[string]map[string]bitemplate.LinkSpec, error) {
+	findReleaseJob := func(jobRef bideplmanifest.ReleaseJobRef) (bireljob.Job, bool) {
+		releaseJob, err := releaseJobResolver.Resolve(jobRef.Name, jobRef.Release)
+		return releaseJob, err == nil
+	}
+
+	links := map[string]map[string]bitemplate.LinkSpec{}
+	for i, releaseJob := range releaseJobs {
+		jobRef := deploymentJob.Templates[i]
+
+		releaseJobLinks := map[string]bitemplate.LinkSpec{}
+		for _, link := range releaseJob.Consumes {
+			providers := deploymentManifest.FindLinkProviders(deploymentJob, jobRef, link, findReleaseJob)
+			if len(providers) == 0 {
+				if link.Optional {
+					continue
+				}
+				return nil, bosherr.Errorf("Link '%s' consumed by job '%s' is not provided by any job of the deployment", link.Name, releaseJob.Name)
+			}
+			if len(providers) > 1 {
+				return nil, bosherr.Errorf("Link '%s' consumed by job '%s' is provided by more than one job of the deployment", link.Name, releaseJob.Name)
+			}
+
+			linkSpec, err := buildLinkSpec(deploymentManifest, providers[0])
+			if err != nil {
+				return nil, bosherr.WrapErrorf(err, "Building link '%s' consumed by job '%s'", link.Name, releaseJob.Name)
+			}
+			releaseJobLinks[link.Name] = linkSpec
+		}
+		links[releaseJob.Name] = releaseJobLinks
+	}
+
+	return links, nil
+}
+
+func buildLinkSpec(deploymentManifest bideplmanifest.Manifest, provider bideplmanifest.LinkProvider) (bitemplate.LinkSpec, error) {
+	networkInterfaces, err := deploymentManifest.NetworkInterfaces(provider.Job.Name)
+	if err != nil {
+		return bitemplate.LinkSpec{}, bosherr.WrapErrorf(err, "Finding networks for job '%s'", provider.Job.Name)
+	}
+
+	// bosh-init deploys one instance of jobs that don't specify how many
+	instanceCount := provider.Job.Instances
+	if instanceCount < 1 {
+		instanceCount = 1
+	}
+
+	instances := make([]bitemplate.LinkInstanceSpec, instanceCount, instanceCount)
+	for index := range instances {
+		instanceSpec := BuildInstanceSpec(deploymentManifest, provider.Job, index, networkInterfaces)
+
+		// instances take the static IPs of the job in order
+		for _, jobNetwork := range provider.Job.Networks {
+			if index < len(jobNetwork.StaticIPs) {
+				networkSpec := instanceSpec.Networks[jobNetwork.Name]
+				networkSpec.IP = jobNetwork.StaticIPs[index]
+				instanceSpec.Networks[jobNetwork.Name] = networkSpec
+			}
+		}
+
+		instances[index] = bitemplate.LinkInstanceSpec{
+			Name:      provider.Job.Name,
+			ID:        instanceSpec.ID,
+			Index:     instanceSpec.Index,
+			AZ:        instanceSpec.AZ,
+			Address:   instanceSpec.Address(),
+			Bootstrap: instanceSpec.Bootstrap,
+		}
+	}
+
+	// shared properties are looked up like templates do: job properties, then global properties, then defaults
+	properties := biproperty.Map{}
+	for _, name := range provider.Link.Properties {
+		value, found := lookupProperty(provider.Job.Properties, name)
+		if !found {
+			value, found = lookupProperty(deploymentManifest.Properties, name)
+		}
+		if !found {
+			value = provider.ReleaseJob.Properties[name].Default
+		}
+		setProperty(properties, name, value)
+	}
+
+	return bitemplate.LinkSpec{
+		Properties: properties,
+		Instances:  instances,
+	}, nil
+}
+
+func lookupProperty(properties biproperty.Map, name string) (biproperty.Property, bool) {
+	var ref biproperty.Property = properties
+	for _, key := range strings.Split(name, ".") {
+		hash, ok := ref.(biproperty.Map)
+		if !ok {
+			return nil, false
+		}
+		ref, ok = hash[key]
+		if !ok || ref == nil {
+			return nil, false
+		}
+	}
+	return ref, true
+}
+
+func setProperty(properties biproperty.Map, name string, value biproperty.Property) {
+	keys := strings.Split(name, ".")
+	ref := properties
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := ref[key].(biproperty.Map)
+		if !ok {
+			next = biproperty.Map{}
+			ref[key] = next
+		}
+		ref = next
+	}
+	ref[keys[len(keys)-1]] = value
+}
@@ -0,0 +1,188 @@
+package state_test
+
+import (
+	. "github.com/cloudfoundry/bosh-init/deployment/instance/state"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gomock/gomock"
+	mock_deployment_release "github.com/cloudfoundry/bosh-init/deployment/release/mocks"
+
+	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
+	bireljob "github.com/cloudfoundry/bosh-init/release/job"
+	bitemplate "github.com/cloudfoundry/bosh-init/templatescompiler"
+)
+
+var _ = Describe("BuildLinks", func() {
+	var (
+		mockCtrl               *gomock.Controller
+		mockReleaseJobResolver *mock_deployment_release.MockJobResolver
+
+		deploymentManifest bideplmanifest.Manifest
+		releaseJobs        map[string]bireljob.Job
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockReleaseJobResolver = mock_deployment_release.NewMockJobResolver(mockCtrl)
+
+		releaseJobs = map[string]bireljob.Job{
+			"fake-db": {
+				Name: "fake-db",
+				Properties: map[string]bireljob.PropertyDefinition{
+					"db.port": {Default: 3306},
+					"db.name": {Default: "fake-default-db-name"},
+				},
+				Provides: []bireljob.LinkDefinition{
+					{Name: "db", Type: "database", Properties: []string{"db.port", "db.name"}},
+				},
+			},
+			"fake-web": {
+				Name: "fake-web",
+				Consumes: []bireljob.LinkDefinition{
+					{Name: "db", Type: "database"},
+					{Name: "cache", Type: "cache", Optional: true},
+				},
+			},
+			"fake-smoke-tests": {
+				Name: "fake-smoke-tests",
+				Consumes: []bireljob.LinkDefinition{
+					{Name: "database", Type: "database"},
+				},
+			},
+		}
+
+		deploymentManifest = bideplmanifest.Manifest{
+			Name: "fake-deployment-name",
+			Jobs: []bideplmanifest.Job{
+				{
+					Name:      "fake-service-job",
+					Instances: 2,
+					Templates: []bideplmanifest.ReleaseJobRef{
+						{Name: "fake-db", Release: "fake-release"},
+						{Name: "fake-web", Release: "fake-release"},
+					},
+					Networks: []bideplmanifest.JobNetwork{
+						{Name: "fake-network", StaticIPs: []string{"10.0.0.5", "10.0.0.6"}},
+					},
+					Properties: biproperty.Map{
+						"db": biproperty.Map{"port": 5432},
+					},
+				},
+				{
+					Name:      "fake-errand",
+					Lifecycle: bideplmanifest.JobLifecycleErrand,
+					Templates: []bideplmanifest.ReleaseJobRef{
+						{Name: "fake-smoke-tests", Release: "fake-release"},
+					},
+				},
+			},
+			Networks: []bideplmanifest.Network{
+				{
+					Name: "fake-network",
+					Type: bideplmanifest.Manual,
+					Subnets: []bideplmanifest.Subnet{
+						{Range: "10.0.0.0/24", Gateway: "10.0.0.1"},
+					},
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	buildLinks := func(jobName string) (map[string]map[string]bitemplate.LinkSpec, error) {
+		for name, releaseJob := range releaseJobs {
+			mockReleaseJobResolver.EXPECT().Resolve(name, "fake-release").Return(releaseJob, nil).AnyTimes()
+		}
+
+		deploymentJob, found := deploymentManifest.FindJobByName(jobName)
+		Expect(found).To(BeTrue())
+
+		jobs := []bireljob.Job{}
+		for _, jobRef := range deploymentJob.Templates {
+			jobs = append(jobs, releaseJobs[jobRef.Name])
+		}
+		return BuildLinks(deploymentManifest, deploymentJob, jobs, mockReleaseJobResolver)
+	}
+
+	It("resolves links provided by colocated jobs with the shared properties and the provider instances", func() {
+		links, err := buildLinks("fake-service-job")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(links).To(HaveLen(2))
+		Expect(links["fake-db"]).To(BeEmpty())
+		Expect(links["fake-web"]).To(Equal(map[string]bitemplate.LinkSpec{
+			"db": {
+				Properties: biproperty.Map{
+					"db": biproperty.Map{
+						"port": 5432,
+						"name": "fake-default-db-name",
+					},
+				},
+				Instances: []bitemplate.LinkInstanceSpec{
+					{Name: "fake-service-job", ID: "3673ab3c-3607-5b60-b603-ee15e29a0f66", Index: 0, Address: "10.0.0.5", Bootstrap: true},
+					{Name: "fake-service-job", ID: "df4c396e-55ab-52dc-b21a-c94c5aa4b688", Index: 1, Address: "10.0.0.6"},
+				},
+			},
+		}))
+		Expect(links["fake-web"]["db"].Address()).To(Equal("10.0.0.5"))
+	})
+
+	It("resolves links provided by other jobs of the deployment", func() {
+		links, err := buildLinks("fake-errand")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(links["fake-smoke-tests"]["database"].Instances).To(HaveLen(2))
+		Expect(links["fake-smoke-tests"]["database"].Instances[0].Name).To(Equal("fake-service-job"))
+	})
+
+	Context("when a required link is not provided", func() {
+		BeforeEach(func() {
+			releaseJob := releaseJobs["fake-web"]
+			releaseJob.Consumes = []bireljob.LinkDefinition{{Name: "cache", Type: "cache"}}
+			releaseJobs["fake-web"] = releaseJob
+		})
+
+		It("returns an error", func() {
+			_, err := buildLinks("fake-service-job")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Link 'cache' consumed by job 'fake-web' is not provided by any job of the deployment"))
+		})
+	})
+
+	Context("when more than one job provides a link", func() {
+		BeforeEach(func() {
+			releaseJobs["fake-other-db"] = bireljob.Job{
+				Name:     "fake-other-db",
+				Provides: []bireljob.LinkDefinition{{Name: "db", Type: "database"}},
+			}
+			deploymentManifest.Jobs[0].Templates = append(
+				deploymentManifest.Jobs[0].Templates,
+				bideplmanifest.ReleaseJobRef{
+					Name:     "fake-other-db",
+					Release:  "fake-release",
+					Provides: map[string]string{"db": "other-db"},
+				},
+			)
+		})
+
+		It("returns an error", func() {
+			_, err := buildLinks("fake-service-job")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Link 'db' consumed by job 'fake-web' is provided by more than one job of the deployment"))
+		})
+
+		It("resolves the link chosen with 'from'", func() {
+			deploymentManifest.Jobs[0].Templates[1].Consumes = map[string]string{"db": "other-db"}
+
+			links, err := buildLinks("fake-service-job")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(links["fake-web"]["db"].Properties).To(BeEmpty())
+		})
+	})
+})
@@ -24,6 +24,9 @@ func NewRemotePackageCompiler(blobstore biblobstore.Blobstore, agentClient biage
 }
 
 func (c *remotePackageCompiler) Compile(releasePackage *birelpkg.Package) (record bistatepkg.CompiledPackageRecord, err error) {
+	if releasePackage.IsCompiled() {
+		return c.uploadCompiled(releasePackage)
+	}
 
 	blobID, err := c.blobstore.Add(releasePackage.ArchivePath)
 	if err != nil {
@@ -86,3 +89,24 @@ func (c *remotePackageCompiler) Compile(releasePackage *birelpkg.Package) (recor
 
 	return record, nil
 }
+
+// uploadCompiled adds the archive of a package of a compiled release to the blobstore, without compiling it on the agent.
+// The deployment validates that compiled releases match the stemcell before deploying.
+func (c *remotePackageCompiler) uploadCompiled(releasePackage *birelpkg.Package) (bistatepkg.CompiledPackageRecord, error) {
+	blobID, err := c.blobstore.Add(releasePackage.ArchivePath)
+	if err != nil {
+		return bistatepkg.CompiledPackageRecord{}, bosherr.WrapErrorf(err, "Adding compiled package archive '%s' to blobstore", releasePackage.ArchivePath)
+	}
+
+	record := bistatepkg.CompiledPackageRecord{
+		BlobID:   blobID,
+		BlobSHA1: releasePackage.SHA1,
+	}
+
+	err = c.packageRepo.Save(*releasePackage, record)
+	if err != nil {
+		return record, bosherr.WrapErrorf(err, "Saving compiled package record %#v of package %#v", record, releasePackage)
+	}
+
+	return record, nil
+}
@@ -157,5 +157,29 @@ func describeRemotePackageCompiler() {
 				Expect(err.Error()).To(ContainSubstring("Remote compilation failure: Package 'fake-package-name/fake-package-fingerprint' requires package 'fake-package-name-dep/fake-package-fingerprint-dep', but it has not been compiled"))
 			})
 		})
+
+		Context("when the package is from a compiled release", func() {
+			BeforeEach(func() {
+				pkg.SHA1 = "fake-compiled-package-sha1"
+				pkg.Stemcell = "ubuntu-trusty/3012"
+			})
+
+			It("uploads the compiled package archive to the blobstore without compiling it with the agent", func() {
+				expectBlobstoreAdd.Times(1)
+				expectAgentCompile.Times(0)
+
+				compiledPackageRecord, err := remotePackageCompiler.Compile(pkg)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(compiledPackageRecord).To(Equal(bistatepkg.CompiledPackageRecord{
+					BlobID:   "fake-source-package-blob-id",
+					BlobSHA1: "fake-compiled-package-sha1",
+				}))
+
+				record, found, err := packageRepo.Find(*pkg)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeTrue())
+				Expect(record).To(Equal(compiledPackageRecord))
+			})
+		})
 	})
 }
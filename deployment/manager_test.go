@@ -218,7 +218,7 @@ var _ = Describe("Manager", func() {
 				err = diskRepo.UpdateCurrent(currentDiskRecord.ID)
 				Expect(err).ToNot(HaveOccurred())
 
-				currentStemcellRecord, err = stemcellRepo.Save("fake-stemcell-name", "fake-stemcell-version", "fake-stemcell-cid")
+				currentStemcellRecord, err = stemcellRepo.Save("fake-stemcell-name", "fake-stemcell-version", "", "fake-stemcell-cid")
 				Expect(err).ToNot(HaveOccurred())
 				err = stemcellRepo.UpdateCurrent(currentStemcellRecord.ID)
 				Expect(err).ToNot(HaveOccurred())
@@ -307,7 +307,7 @@ var _ = Describe("Manager", func() {
 
 		Context("orphan stemcell records exist", func() {
 			BeforeEach(func() {
-				_, err := stemcellRepo.Save("orphan-stemcell-name", "orphan-stemcell-version", "orphan-stemcell-cid")
+				_, err := stemcellRepo.Save("orphan-stemcell-name", "orphan-stemcell-version", "", "orphan-stemcell-cid")
 				Expect(err).ToNot(HaveOccurred())
 			})
 
@@ -39,7 +39,8 @@ type ValidateOutput struct {
 }
 
 type ValidateReleaseJobsOutput struct {
-	Err error
+	Warnings []string
+	Err      error
 }
 
 func (v *FakeValidator) Validate(manifest bideplmanifest.Manifest, releaseSetManifest birelsetmanifest.Manifest) error {
@@ -56,18 +57,18 @@ func (v *FakeValidator) Validate(manifest bideplmanifest.Manifest, releaseSetMan
 	return validateOutput.Err
 }
 
-func (v *FakeValidator) ValidateReleaseJobs(manifest bideplmanifest.Manifest, releaseManager birel.Manager) error {
+func (v *FakeValidator) ValidateReleaseJobs(manifest bideplmanifest.Manifest, releaseManager birel.Manager) ([]string, error) {
 	v.ValidateReleaseJobsInputs = append(v.ValidateReleaseJobsInputs, ValidateReleaseJobsInput{
 		Manifest:       manifest,
 		ReleaseManager: releaseManager,
 	})
 
 	if len(v.validateReleaseJobsOutputs) == 0 {
-		return bosherr.Errorf("Unexpected FakeValidator.ValidateReleaseJobs(manifest, releaseManager) called with manifest: %#v", manifest)
+		return []string{}, bosherr.Errorf("Unexpected FakeValidator.ValidateReleaseJobs(manifest, releaseManager) called with manifest: %#v", manifest)
 	}
 	validateReleaseJobsOutput := v.validateReleaseJobsOutputs[0]
 	v.validateReleaseJobsOutputs = v.validateReleaseJobsOutputs[1:]
-	return validateReleaseJobsOutput.Err
+	return validateReleaseJobsOutput.Warnings, validateReleaseJobsOutput.Err
 }
 
 func (v *FakeValidator) SetValidateBehavior(outputs []ValidateOutput) {
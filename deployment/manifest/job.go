@@ -23,9 +23,29 @@ const (
 	JobLifecycleErrand  JobLifecycle = "errand"
 )
 
+func (j Job) IsErrand() bool {
+	return j.Lifecycle == JobLifecycleErrand
+}
+
+// HasTemplate returns true if a release job with the given name is colocated on this job.
+func (j Job) HasTemplate(templateName string) bool {
+	for _, template := range j.Templates {
+		if template.Name == templateName {
+			return true
+		}
+	}
+	return false
+}
+
 type ReleaseJobRef struct {
 	Name    string
 	Release string
+
+	// Provides renames the links provided by the release job ('as'), keyed by link name
+	Provides map[string]string
+
+	// Consumes chooses the provided link ('from') of the links consumed by the release job, keyed by link name
+	Consumes map[string]string
 }
 
 type JobNetwork struct {
@@ -0,0 +1,88 @@
+package manifest
+
+import (
+	bireljob "github.com/cloudfoundry/bosh-init/release/job"
+)
+
+// ReleaseJobFinder returns the release job referenced by a template of a deployment job
+type ReleaseJobFinder func(ReleaseJobRef) (bireljob.Job, bool)
+
+// LinkProvider is a release job of a deployment job that provides a link
+type LinkProvider struct {
+	Job        Job
+	ReleaseJob bireljob.Job
+	Link       bireljob.LinkDefinition
+
+	// Name is the name of the link in the deployment, which may be changed with 'as'
+	Name string
+}
+
+// FindLinkProviders finds the release jobs that provide a link consumed by a release job of the consumer job.
+// Links are matched by the name chosen with 'from', or else by type.
+// Providers colocated on the consumer job are preferred; errands only provide links to their own release jobs.
+func (d Manifest) FindLinkProviders(consumer Job, consumerRef ReleaseJobRef, link bireljob.LinkDefinition, findReleaseJob ReleaseJobFinder) []LinkProvider {
+	from := consumerRef.Consumes[link.Name]
+
+	colocatedProviders := []LinkProvider{}
+	otherProviders := []LinkProvider{}
+	for _, provider := range d.linkProviders(findReleaseJob) {
+		isColocated := provider.Job.Name == consumer.Name
+		if !isColocated && provider.Job.IsErrand() {
+			continue
+		}
+
+		if from != "" {
+			if provider.Name != from || !linkTypesMatch(provider.Link.Type, link.Type) {
+				continue
+			}
+		} else if link.Type != "" {
+			if provider.Link.Type != link.Type {
+				continue
+			}
+		} else if provider.Name != link.Name {
+			continue
+		}
+
+		if isColocated {
+			colocatedProviders = append(colocatedProviders, provider)
+		} else {
+			otherProviders = append(otherProviders, provider)
+		}
+	}
+
+	if len(colocatedProviders) > 0 {
+		return colocatedProviders
+	}
+	return otherProviders
+}
+
+func (d Manifest) linkProviders(findReleaseJob ReleaseJobFinder) []LinkProvider {
+	providers := []LinkProvider{}
+	for _, job := range d.Jobs {
+		for _, template := range job.Templates {
+			releaseJob, found := findReleaseJob(template)
+			if !found {
+				continue
+			}
+
+			for _, link := range releaseJob.Provides {
+				name := link.Name
+				if as := template.Provides[link.Name]; as != "" {
+					name = as
+				}
+
+				providers = append(providers, LinkProvider{
+					Job:        job,
+					ReleaseJob: releaseJob,
+					Link:       link,
+					Name:       name,
+				})
+			}
+		}
+	}
+	return providers
+}
+
+func linkTypesMatch(providedType string, consumedType string) bool {
+	return providedType == "" || consumedType == "" || providedType == consumedType
+}
@@ -1,6 +1,8 @@
 package manifest
 
 import (
+	"time"
+
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 	biproperty "github.com/cloudfoundry/bosh-init/common/property"
 )
@@ -13,10 +15,20 @@ type Manifest struct {
 	DiskPools     []DiskPool
 	ResourcePools []ResourcePool
 	Update        Update
+	Compilation   Compilation
 }
 
 type Update struct {
 	UpdateWatchTime WatchTime
+
+	// AgentTaskTimeouts limits how long an agent task may run, keyed by agent action name or 'default'
+	AgentTaskTimeouts map[string]time.Duration
+}
+
+// Compilation configures how release packages are compiled on the deployed VM
+type Compilation struct {
+	// Workers is the maximum number of packages compiled concurrently
+	Workers int
 }
 
 // NetworkInterfaces returns a map of network names to network interfaces.
@@ -44,8 +56,19 @@ func (d Manifest) NetworkInterfaces(jobName string) (map[string]biproperty.Map,
 }
 
 func (d Manifest) JobName() string {
-	// Currently we deploy only one job
-	return d.Jobs[0].Name
+	// Currently we deploy only one job, errands are run separately
+	return d.ServiceJobs()[0].Name
+}
+
+// ServiceJobs returns the jobs that are deployed, excluding errands.
+func (d Manifest) ServiceJobs() []Job {
+	jobs := []Job{}
+	for _, job := range d.Jobs {
+		if !job.IsErrand() {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
 }
 
 func (d Manifest) Stemcell(jobName string) (StemcellRef, error) {
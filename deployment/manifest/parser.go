@@ -1,6 +1,8 @@
 package manifest
 
 import (
+	"time"
+
 	"gopkg.in/yaml.v2"
 
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
@@ -23,6 +25,7 @@ type parser struct {
 type manifest struct {
 	Name          string
 	Update        UpdateSpec
+	Compilation   compilation
 	Networks      []network
 	ResourcePools []resourcePool `yaml:"resource_pools"`
 	DiskPools     []diskPool     `yaml:"disk_pools"`
@@ -31,7 +34,12 @@ type manifest struct {
 }
 
 type UpdateSpec struct {
-	UpdateWatchTime *string `yaml:"update_watch_time"`
+	UpdateWatchTime   *string        `yaml:"update_watch_time"`
+	AgentTaskTimeouts map[string]int `yaml:"agent_task_timeouts"`
+}
+
+type compilation struct {
+	Workers *int `yaml:"workers"`
 }
 
 type network struct {
@@ -79,13 +87,24 @@ type job struct {
 }
 
 type releaseJobRef struct {
-	Name    string
-	Release string
+	Name     string
+	Release  string
+	Provides map[string]providedLinkRef
+	Consumes map[string]consumedLinkRef
+}
+
+type providedLinkRef struct {
+	As string
+}
+
+type consumedLinkRef struct {
+	From string
 }
 
 type stemcellRef struct {
-	URL  string
-	SHA1 string
+	URL     string
+	SHA1    string
+	Mirrors []string
 }
 
 type jobNetwork struct {
@@ -101,6 +120,9 @@ var boshDeploymentDefaults = Manifest{
 			End:   300000,
 		},
 	},
+	Compilation: Compilation{
+		Workers: 4,
+	},
 }
 
 func NewParser(fs boshsys.FileSystem, logger boshlog.Logger) Parser {
@@ -172,8 +194,17 @@ func (p *parser) parseDeploymentManifest(depManifest manifest) (Manifest, error)
 			return Manifest{}, bosherr.WrapError(err, "Parsing update watch time")
 		}
 
-		deployment.Update = Update{
-			UpdateWatchTime: updateWatchTime,
+		deployment.Update.UpdateWatchTime = updateWatchTime
+	}
+
+	if depManifest.Compilation.Workers != nil {
+		deployment.Compilation.Workers = *depManifest.Compilation.Workers
+	}
+
+	if depManifest.Update.AgentTaskTimeouts != nil {
+		deployment.Update.AgentTaskTimeouts = map[string]time.Duration{}
+		for action, seconds := range depManifest.Update.AgentTaskTimeouts {
+			deployment.Update.AgentTaskTimeouts[action] = time.Duration(seconds) * time.Second
 		}
 	}
 
@@ -199,6 +230,20 @@ func (p *parser) parseJobManifests(rawJobs []job) ([]Job, error) {
 					Name:    rawJobRef.Name,
 					Release: rawJobRef.Release,
 				}
+
+				if rawJobRef.Provides != nil {
+					releaseJobRefs[i].Provides = map[string]string{}
+					for linkName, rawLinkRef := range rawJobRef.Provides {
+						releaseJobRefs[i].Provides[linkName] = rawLinkRef.As
+					}
+				}
+
+				if rawJobRef.Consumes != nil {
+					releaseJobRefs[i].Consumes = map[string]string{}
+					for linkName, rawLinkRef := range rawJobRef.Consumes {
+						releaseJobRefs[i].Consumes[linkName] = rawLinkRef.From
+					}
+				}
 			}
 			job.Templates = releaseJobRefs
 		}
@@ -2,6 +2,7 @@ package manifest_test
 
 import (
 	"errors"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -67,6 +68,8 @@ resource_pools:
       password: secret
   stemcell:
     url: fake-stemcell-url
+    mirrors:
+    - fake-stemcell-mirror-url
 networks:
 - name: fake-network-name
   type: dynamic
@@ -89,6 +92,15 @@ disk_pools:
     fake-disk-pool-cloud-property-key: fake-disk-pool-cloud-property-value
 jobs:
 - name: bosh
+  templates:
+  - name: fake-provider-job
+    release: fake-release
+    provides:
+      fake-link: {as: fake-link-alias}
+  - name: fake-consumer-job
+    release: fake-release
+    consumes:
+      fake-link: {from: fake-link-alias}
   networks:
   - name: vip
     static_ips: [1.2.3.4]
@@ -117,6 +129,9 @@ properties:
 					End:   7000,
 				},
 			},
+			Compilation: Compilation{
+				Workers: 4,
+			},
 			Networks: []Network{
 				{
 					Name: "fake-network-name",
@@ -156,7 +171,8 @@ properties:
 						},
 					},
 					Stemcell: StemcellRef{
-						URL: "fake-stemcell-url",
+						URL:     "fake-stemcell-url",
+						Mirrors: []string{"fake-stemcell-mirror-url"},
 					},
 				},
 			},
@@ -172,6 +188,18 @@ properties:
 			Jobs: []Job{
 				{
 					Name: "bosh",
+					Templates: []ReleaseJobRef{
+						{
+							Name:     "fake-provider-job",
+							Release:  "fake-release",
+							Provides: map[string]string{"fake-link": "fake-link-alias"},
+						},
+						{
+							Name:     "fake-consumer-job",
+							Release:  "fake-release",
+							Consumes: map[string]string{"fake-link": "fake-link-alias"},
+						},
+					},
 					Networks: []JobNetwork{
 						{
 							Name:      "vip",
@@ -308,6 +336,31 @@ disk_pools:
 		})
 	})
 
+	Context("when agent task timeouts are set", func() {
+		BeforeEach(func() {
+			contents := `
+---
+name: fake-deployment-name
+update:
+  agent_task_timeouts:
+    default: 900
+    compile_package: 3600
+`
+			fakeFs.WriteFileString(comboManifestPath, contents)
+		})
+
+		It("parses the timeouts as seconds", func() {
+			deploymentManifest, err := parser.Parse(comboManifestPath)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(deploymentManifest.Update.AgentTaskTimeouts).To(Equal(map[string]time.Duration{
+				"default":         900 * time.Second,
+				"compile_package": time.Hour,
+			}))
+			Expect(deploymentManifest.Update.UpdateWatchTime.End).To(Equal(300000))
+		})
+	})
+
 	Context("when update watch time is not set", func() {
 		BeforeEach(func() {
 			contents := `
@@ -324,6 +377,25 @@ name: fake-deployment-name
 			Expect(deploymentManifest.Name).To(Equal("fake-deployment-name"))
 			Expect(deploymentManifest.Update.UpdateWatchTime.Start).To(Equal(0))
 			Expect(deploymentManifest.Update.UpdateWatchTime.End).To(Equal(300000))
+			Expect(deploymentManifest.Compilation.Workers).To(Equal(4))
+		})
+	})
+
+	Context("when compilation workers are set", func() {
+		BeforeEach(func() {
+			contents := `
+---
+name: fake-deployment-name
+compilation:
+  workers: 8
+`
+			fakeFs.WriteFileString(comboManifestPath, contents)
+		})
+
+		It("parses the number of workers", func() {
+			deploymentManifest, err := parser.Parse(comboManifestPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentManifest.Compilation.Workers).To(Equal(8))
 		})
 	})
 })
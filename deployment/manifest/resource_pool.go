@@ -15,6 +15,9 @@ type ResourcePool struct {
 type StemcellRef struct {
 	URL  string
 	SHA1 string
+
+	// Mirrors are tried in order when the tarball cannot be downloaded from URL
+	Mirrors []string
 }
 
 func (s StemcellRef) GetURL() string {
@@ -28,3 +31,7 @@ func (s StemcellRef) GetSHA1() string {
 func (s StemcellRef) Description() string {
 	return "stemcell"
 }
+
+func (s StemcellRef) GetMirrors() []string {
+	return s.Mirrors
+}
@@ -1,20 +1,25 @@
 package manifest
 
 import (
+	"fmt"
 	"net"
 	"regexp"
+	"sort"
 	"strings"
 
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 
+	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bicrypto "github.com/cloudfoundry/bosh-init/crypto"
 	birel "github.com/cloudfoundry/bosh-init/release"
+	bireljob "github.com/cloudfoundry/bosh-init/release/job"
 	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
 )
 
 type Validator interface {
 	Validate(Manifest, birelsetmanifest.Manifest) error
-	ValidateReleaseJobs(Manifest, birel.Manager) error
+	ValidateReleaseJobs(Manifest, birel.Manager) (warnings []string, err error)
 }
 
 type validator struct {
@@ -76,6 +81,23 @@ func (v *validator) Validate(deploymentManifest Manifest, releaseSetManifest bir
 		if strings.HasPrefix(resourcePool.Stemcell.URL, "http") && v.isBlank(resourcePool.Stemcell.SHA1) {
 			errs = append(errs, bosherr.Errorf("resource_pools[%d].stemcell.sha1 must be provided for http URL", idx))
 		}
+
+		if !v.isBlank(resourcePool.Stemcell.SHA1) {
+			if _, err := bicrypto.ParseDigest(resourcePool.Stemcell.SHA1); err != nil {
+				errs = append(errs, bosherr.Errorf("resource_pools[%d].stemcell.sha1 must be a sha1 or an algorithm-prefixed sha256/sha512 digest (e.g. 'sha256:<hex>')", idx))
+			}
+		}
+
+		if len(resourcePool.Stemcell.Mirrors) > 0 && !strings.HasPrefix(resourcePool.Stemcell.URL, "http") {
+			errs = append(errs, bosherr.Errorf("resource_pools[%d].stemcell.mirrors can only be used with an http(s) URL", idx))
+		}
+
+		for mirrorIdx, mirror := range resourcePool.Stemcell.Mirrors {
+			matched, err := regexp.MatchString("^(http|https)://", mirror)
+			if err != nil || !matched {
+				errs = append(errs, bosherr.Errorf("resource_pools[%d].stemcell.mirrors[%d] must be a valid http(s):// URL", idx, mirrorIdx))
+			}
+		}
 	}
 
 	for idx, diskPool := range deploymentManifest.DiskPools {
@@ -87,8 +109,8 @@ func (v *validator) Validate(deploymentManifest Manifest, releaseSetManifest bir
 		}
 	}
 
-	if len(deploymentManifest.Jobs) > 1 {
-		errs = append(errs, bosherr.Error("jobs must be of size 1"))
+	if len(deploymentManifest.ServiceJobs()) != 1 {
+		errs = append(errs, bosherr.Error("jobs must contain exactly 1 job that is not an errand"))
 	}
 
 	for idx, job := range deploymentManifest.Jobs {
@@ -134,8 +156,11 @@ func (v *validator) Validate(deploymentManifest Manifest, releaseSetManifest bir
 			}
 		}
 
-		if job.Lifecycle != "" && job.Lifecycle != JobLifecycleService {
-			errs = append(errs, bosherr.Errorf("jobs[%d].lifecycle must be 'service' ('%s' not supported)", idx, job.Lifecycle))
+		if job.Lifecycle != "" && job.Lifecycle != JobLifecycleService && job.Lifecycle != JobLifecycleErrand {
+			errs = append(errs, bosherr.Errorf("jobs[%d].lifecycle must be 'service' or 'errand' ('%s' not supported)", idx, job.Lifecycle))
+		}
+		if job.IsErrand() && (job.PersistentDisk > 0 || job.PersistentDiskPool != "") {
+			errs = append(errs, bosherr.Errorf("jobs[%d] is an errand and must not have a persistent disk", idx))
 		}
 
 		templateNames := map[string]struct{}{}
@@ -159,6 +184,16 @@ func (v *validator) Validate(deploymentManifest Manifest, releaseSetManifest bir
 		}
 	}
 
+	if deploymentManifest.Compilation.Workers < 1 {
+		errs = append(errs, bosherr.Error("compilation.workers must be > 0"))
+	}
+
+	for action, timeout := range deploymentManifest.Update.AgentTaskTimeouts {
+		if timeout < 0 {
+			errs = append(errs, bosherr.Errorf("update.agent_task_timeouts.%s must be a non-negative number of seconds", action))
+		}
+	}
+
 	if len(errs) > 0 {
 		return bosherr.NewMultiError(errs...)
 	}
@@ -166,28 +201,249 @@ func (v *validator) Validate(deploymentManifest Manifest, releaseSetManifest bir
 	return nil
 }
 
-func (v *validator) ValidateReleaseJobs(deploymentManifest Manifest, releaseManager birel.Manager) error {
+// ValidateReleaseJobs returns an error if the jobs of the deployment don't match the release jobs they refer to,
+// and warnings for manifest properties that no release job defines
+func (v *validator) ValidateReleaseJobs(deploymentManifest Manifest, releaseManager birel.Manager) ([]string, error) {
+	warnings := []string{}
 	errs := []error{}
 
+	// global properties may be used by the job templates of any job
+	allPropertyDefinitions := propertyDefinitions{}
+	allJobsResolved := true
+
 	for idx, job := range deploymentManifest.Jobs {
+		jobPropertyDefinitions := propertyDefinitions{}
+		jobResolved := true
+
 		for templateIdx, template := range job.Templates {
 			release, found := releaseManager.Find(template.Release)
 			if !found {
 				errs = append(errs, bosherr.Errorf("jobs[%d].templates[%d].release '%s' must refer to release in releases", idx, templateIdx, template.Release))
+				jobResolved = false
 			} else {
-				_, found := release.FindJobByName(template.Name)
+				releaseJob, found := release.FindJobByName(template.Name)
 				if !found {
 					errs = append(errs, bosherr.Errorf("jobs[%d].templates[%d] must refer to a job in '%s', but there is no job named '%s'", idx, templateIdx, release.Name(), template.Name))
+					jobResolved = false
+				} else {
+					jobPropertyDefinitions.add(releaseJob.Properties)
+					allPropertyDefinitions.add(releaseJob.Properties)
 				}
 			}
 		}
+
+		// properties can only be validated against the specs of all the job templates of the job
+		if !jobResolved {
+			allJobsResolved = false
+			continue
+		}
+
+		manifestPath := fmt.Sprintf("jobs[%d].properties", idx)
+		propertyWarnings, propertyErrs := v.validateProperties(manifestPath, job.Properties, jobPropertyDefinitions)
+		warnings = append(warnings, propertyWarnings...)
+		errs = append(errs, propertyErrs...)
+		errs = append(errs, v.validateRequiredProperties(manifestPath, job.Properties, deploymentManifest.Properties, jobPropertyDefinitions)...)
+	}
+
+	if allJobsResolved {
+		propertyWarnings, propertyErrs := v.validateProperties("properties", deploymentManifest.Properties, allPropertyDefinitions)
+		warnings = append(warnings, propertyWarnings...)
+		errs = append(errs, propertyErrs...)
+		errs = append(errs, v.validateLinks(deploymentManifest, releaseManager)...)
 	}
 
 	if len(errs) > 0 {
-		return bosherr.NewMultiError(errs...)
+		return warnings, bosherr.NewMultiError(errs...)
 	}
 
-	return nil
+	return warnings, nil
+}
+
+// validateLinks reports links consumed by job templates that no job template of the deployment provides,
+// or that more than one job template provides
+func (v *validator) validateLinks(deploymentManifest Manifest, releaseManager birel.Manager) []error {
+	findReleaseJob := func(jobRef ReleaseJobRef) (bireljob.Job, bool) {
+		release, found := releaseManager.Find(jobRef.Release)
+		if !found {
+			return bireljob.Job{}, false
+		}
+		return release.FindJobByName(jobRef.Name)
+	}
+
+	errs := []error{}
+	for idx, job := range deploymentManifest.Jobs {
+		for templateIdx, template := range job.Templates {
+			releaseJob, _ := findReleaseJob(template)
+			for _, link := range releaseJob.Consumes {
+				manifestPath := fmt.Sprintf("jobs[%d].templates[%d].consumes.%s", idx, templateIdx, link.Name)
+
+				providers := deploymentManifest.FindLinkProviders(job, template, link, findReleaseJob)
+				switch {
+				case len(providers) == 0 && template.Consumes[link.Name] != "":
+					errs = append(errs, bosherr.Errorf("%s.from must be the name of a link of type '%s' provided by a job template", manifestPath, link.Type))
+				case len(providers) == 0 && !link.Optional:
+					errs = append(errs, bosherr.Errorf("%s must be provided by a job template of type '%s'", manifestPath, link.Type))
+				case len(providers) > 1:
+					providerNames := make([]string, len(providers), len(providers))
+					for i, provider := range providers {
+						providerNames[i] = fmt.Sprintf("%s/%s", provider.Job.Name, provider.ReleaseJob.Name)
+					}
+					errs = append(errs, bosherr.Errorf("%s is provided by more than one job template ('%s'), choose one with 'from'", manifestPath, strings.Join(providerNames, "', '")))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// propertyDefinitions maps the dotted names of the properties of release jobs to their definitions in each job spec
+type propertyDefinitions map[string][]bireljob.PropertyDefinition
+
+func (d propertyDefinitions) add(definitions map[string]bireljob.PropertyDefinition) {
+	for name, definition := range definitions {
+		d[name] = append(d[name], definition)
+	}
+}
+
+// hasNested returns true if a property is defined below the dotted name
+func (d propertyDefinitions) hasNested(name string) bool {
+	for definedName := range d {
+		if strings.HasPrefix(definedName, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// validateProperties warns about manifest properties that no job spec defines,
+// and reports values that don't match the type of their definition
+func (v *validator) validateProperties(manifestPath string, properties biproperty.Map, definitions propertyDefinitions) ([]string, []error) {
+	warnings := []string{}
+	errs := []error{}
+	for _, name := range sortedPropertyNames(properties) {
+		propertyWarnings, propertyErrs := v.validateProperty(manifestPath, name, properties[name], definitions)
+		warnings = append(warnings, propertyWarnings...)
+		errs = append(errs, propertyErrs...)
+	}
+	return warnings, errs
+}
+
+func (v *validator) validateProperty(manifestPath string, name string, value biproperty.Property, definitions propertyDefinitions) ([]string, []error) {
+	if nameDefinitions, found := definitions[name]; found {
+		for _, definition := range nameDefinitions {
+			if propertyTypeMatches(definition.Type, value) {
+				return []string{}, []error{}
+			}
+		}
+		return []string{}, []error{bosherr.Errorf("%s.%s must be of type '%s'", manifestPath, name, nameDefinitions[0].Type)}
+	}
+
+	// job specs are not always complete, so properties they don't define are allowed
+	if !definitions.hasNested(name) {
+		return []string{fmt.Sprintf("%s.%s is not defined by any job template", manifestPath, name)}, []error{}
+	}
+
+	if value == nil {
+		return []string{}, []error{}
+	}
+
+	nestedProperties, ok := value.(biproperty.Map)
+	if !ok {
+		return []string{}, []error{bosherr.Errorf("%s.%s must be a hash", manifestPath, name)}
+	}
+
+	warnings := []string{}
+	errs := []error{}
+	for _, nestedName := range sortedPropertyNames(nestedProperties) {
+		nestedWarnings, nestedErrs := v.validateProperty(manifestPath, name+"."+nestedName, nestedProperties[nestedName], definitions)
+		warnings = append(warnings, nestedWarnings...)
+		errs = append(errs, nestedErrs...)
+	}
+	return warnings, errs
+}
+
+// validateRequiredProperties reports required properties without a default that are neither job nor global properties
+func (v *validator) validateRequiredProperties(manifestPath string, jobProperties biproperty.Map, globalProperties biproperty.Map, definitions propertyDefinitions) []error {
+	names := make([]string, 0, len(definitions))
+	for name := range definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	errs := []error{}
+	for _, name := range names {
+		required := false
+		hasDefault := false
+		for _, definition := range definitions[name] {
+			required = required || definition.Required
+			hasDefault = hasDefault || definition.Default != nil
+		}
+		if !required || hasDefault {
+			continue
+		}
+
+		if lookupProperty(jobProperties, name) == nil && lookupProperty(globalProperties, name) == nil {
+			errs = append(errs, bosherr.Errorf("%s.%s must be provided", manifestPath, name))
+		}
+	}
+	return errs
+}
+
+// propertyTypeMatches returns true if the value is of the job spec property type, or if the type is not known
+func propertyTypeMatches(propertyType string, value biproperty.Property) bool {
+	if value == nil {
+		return true
+	}
+
+	switch propertyType {
+	case "string", "password", "certificate":
+		_, ok := value.(string)
+		return ok
+	case "integer", "int":
+		switch value.(type) {
+		case int, int64, uint64:
+			return true
+		}
+		return false
+	case "number", "float":
+		switch value.(type) {
+		case int, int64, uint64, float64:
+			return true
+		}
+		return false
+	case "boolean", "bool":
+		_, ok := value.(bool)
+		return ok
+	case "array", "list":
+		_, ok := value.(biproperty.List)
+		return ok
+	case "hash", "map":
+		_, ok := value.(biproperty.Map)
+		return ok
+	}
+
+	return true
+}
+
+func lookupProperty(properties biproperty.Map, name string) biproperty.Property {
+	var current biproperty.Property = properties
+	for _, key := range strings.Split(name, ".") {
+		currentMap, ok := current.(biproperty.Map)
+		if !ok {
+			return nil
+		}
+		current = currentMap[key]
+	}
+	return current
+}
+
+func sortedPropertyNames(properties biproperty.Map) []string {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 func (v *validator) isBlank(str string) bool {
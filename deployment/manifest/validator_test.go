@@ -1,6 +1,8 @@
 package manifest_test
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -33,6 +35,9 @@ var _ = Describe("Validator", func() {
 		releaseManager = birel.NewManager(logger)
 		validManifest = Manifest{
 			Name: "fake-deployment-name",
+			Compilation: Compilation{
+				Workers: 4,
+			},
 			Networks: []Network{
 				{
 					Name: "fake-network-name",
@@ -245,6 +250,54 @@ var _ = Describe("Validator", func() {
 			Expect(err.Error()).To(ContainSubstring("resource_pools[0].stemcell.sha1 must be provided for http URL"))
 		})
 
+		It("validates stemcell sha1 digests use a supported algorithm", func() {
+			deploymentManifest := Manifest{
+				ResourcePools: []ResourcePool{
+					{
+						Stemcell: StemcellRef{
+							URL:  "https://fake-url",
+							SHA1: "sha512:cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e",
+						},
+					},
+				},
+			}
+
+			err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).ToNot(ContainSubstring("stemcell.sha1"))
+
+			deploymentManifest.ResourcePools[0].Stemcell.SHA1 = "md5:fake-md5"
+
+			err = validator.Validate(deploymentManifest, validReleaseSetManifest)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("resource_pools[0].stemcell.sha1 must be a sha1 or an algorithm-prefixed sha256/sha512 digest (e.g. 'sha256:<hex>')"))
+		})
+
+		It("validates stemcell mirrors are http(s) URLs of an http(s) stemcell", func() {
+			deploymentManifest := Manifest{
+				ResourcePools: []ResourcePool{
+					{
+						Stemcell: StemcellRef{
+							URL:     "https://fake-url",
+							SHA1:    "fake-sha1",
+							Mirrors: []string{"http://fake-mirror-url", "fake-mirror-path"},
+						},
+					},
+				},
+			}
+
+			err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).ToNot(ContainSubstring("resource_pools[0].stemcell.mirrors[0]"))
+			Expect(err.Error()).To(ContainSubstring("resource_pools[0].stemcell.mirrors[1] must be a valid http(s):// URL"))
+
+			deploymentManifest.ResourcePools[0].Stemcell.URL = "file://fake-path"
+
+			err = validator.Validate(deploymentManifest, validReleaseSetManifest)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("resource_pools[0].stemcell.mirrors can only be used with an http(s) URL"))
+		})
+
 		It("validates disk pool name", func() {
 			deploymentManifest := Manifest{
 				DiskPools: []DiskPool{
@@ -288,6 +341,32 @@ var _ = Describe("Validator", func() {
 			Expect(err.Error()).To(ContainSubstring("disk_pools[0].disk_size must be > 0"))
 		})
 
+		It("validates compilation workers", func() {
+			deploymentManifest := Manifest{
+				Compilation: Compilation{
+					Workers: 0,
+				},
+			}
+
+			err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("compilation.workers must be > 0"))
+		})
+
+		It("validates agent task timeouts are not negative", func() {
+			deploymentManifest := Manifest{
+				Update: Update{
+					AgentTaskTimeouts: map[string]time.Duration{
+						"compile_package": -1 * time.Second,
+					},
+				},
+			}
+
+			err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("update.agent_task_timeouts.compile_package must be a non-negative number of seconds"))
+		})
+
 		It("validates network name", func() {
 			deploymentManifest := Manifest{
 				Networks: []Network{
@@ -372,7 +451,48 @@ var _ = Describe("Validator", func() {
 
 			err := validator.Validate(deploymentManifest, validReleaseSetManifest)
 			Expect(err).To(HaveOccurred())
-			Expect(err.Error()).To(ContainSubstring("jobs must be of size 1"))
+			Expect(err.Error()).To(ContainSubstring("jobs must contain exactly 1 job that is not an errand"))
+		})
+
+		It("validates that there is a job that is not an errand", func() {
+			deploymentManifest := validManifest
+			errandJob := validManifest.Jobs[0]
+			errandJob.Lifecycle = JobLifecycleErrand
+			errandJob.PersistentDisk = 0
+			errandJob.PersistentDiskPool = ""
+			deploymentManifest.Jobs = []Job{errandJob}
+
+			err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("jobs must contain exactly 1 job that is not an errand"))
+		})
+
+		It("permits errand jobs in addition to the service job", func() {
+			deploymentManifest := validManifest
+			errandJob := validManifest.Jobs[0]
+			errandJob.Name = "fake-errand-name"
+			errandJob.Lifecycle = JobLifecycleErrand
+			errandJob.PersistentDisk = 0
+			errandJob.PersistentDiskPool = ""
+			deploymentManifest.Jobs = []Job{validManifest.Jobs[0], errandJob}
+
+			err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("validates that errand jobs do not have persistent disks", func() {
+			deploymentManifest := Manifest{
+				Jobs: []Job{
+					{
+						Lifecycle:      JobLifecycleErrand,
+						PersistentDisk: 1024,
+					},
+				},
+			}
+
+			err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("jobs[0] is an errand and must not have a persistent disk"))
 		})
 
 		It("validates job name", func() {
@@ -554,14 +674,14 @@ var _ = Describe("Validator", func() {
 			deploymentManifest := Manifest{
 				Jobs: []Job{
 					{
-						Lifecycle: "errand",
+						Lifecycle: "fake-lifecycle",
 					},
 				},
 			}
 
 			err := validator.Validate(deploymentManifest, validReleaseSetManifest)
 			Expect(err).To(HaveOccurred())
-			Expect(err.Error()).To(ContainSubstring("jobs[0].lifecycle must be 'service' ('errand' not supported)"))
+			Expect(err.Error()).To(ContainSubstring("jobs[0].lifecycle must be 'service' or 'errand' ('fake-lifecycle' not supported)"))
 		})
 
 		It("permits job templates to reference an undeclared release", func() {
@@ -645,9 +765,165 @@ var _ = Describe("Validator", func() {
 			fakeRelease.ReleaseJobs = []bireljob.Job{{Name: "fake-job-name"}}
 			releaseManager.Add(fakeRelease)
 
-			err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+			_, err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("jobs[0].templates[0] must refer to a job in 'fake-release-name', but there is no job named 'fake-other-job-name'"))
 		})
+
+		Context("when the release jobs define properties", func() {
+			var deploymentManifest Manifest
+
+			BeforeEach(func() {
+				fakeRelease.ReleaseJobs = []bireljob.Job{
+					{
+						Name: "fake-job-name",
+						Properties: map[string]bireljob.PropertyDefinition{
+							"director.db.host": {Type: "string"},
+							"director.port":    {Type: "integer", Default: 25555},
+							"director.ssl":     {Type: "boolean"},
+							"director.name":    {Required: true},
+							"ntp":              {Type: "array"},
+						},
+					},
+				}
+
+				deploymentManifest = validManifest
+				deploymentManifest.Jobs = []Job{validManifest.Jobs[0]}
+				deploymentManifest.Jobs[0].Properties = biproperty.Map{
+					"director": biproperty.Map{
+						"db":   biproperty.Map{"host": "fake-host"},
+						"port": 25555,
+						"name": "fake-director-name",
+					},
+				}
+				deploymentManifest.Properties = biproperty.Map{
+					"ntp": biproperty.List{"0.pool.ntp.org"},
+				}
+			})
+
+			It("accepts properties defined by the job templates", func() {
+				warnings, err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(warnings).To(BeEmpty())
+			})
+
+			It("warns about job properties not defined by the job templates", func() {
+				deploymentManifest.Jobs[0].Properties["director"].(biproperty.Map)["db"] = biproperty.Map{"hots": "fake-host"}
+
+				warnings, err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(warnings).To(Equal([]string{"jobs[0].properties.director.db.hots is not defined by any job template"}))
+			})
+
+			It("warns about global properties not defined by the job templates", func() {
+				deploymentManifest.Properties["fake-unknown"] = "fake-value"
+
+				warnings, err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(warnings).To(Equal([]string{"properties.fake-unknown is not defined by any job template"}))
+			})
+
+			It("validates properties match the type of their definition", func() {
+				deploymentManifest.Jobs[0].Properties["director"].(biproperty.Map)["port"] = "fake-port"
+				deploymentManifest.Jobs[0].Properties["director"].(biproperty.Map)["ssl"] = "yes"
+				deploymentManifest.Properties["ntp"] = "0.pool.ntp.org"
+
+				_, err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("jobs[0].properties.director.port must be of type 'integer'"))
+				Expect(err.Error()).To(ContainSubstring("jobs[0].properties.director.ssl must be of type 'boolean'"))
+				Expect(err.Error()).To(ContainSubstring("properties.ntp must be of type 'array'"))
+			})
+
+			It("validates properties with nested definitions are hashes", func() {
+				deploymentManifest.Jobs[0].Properties["director"] = "fake-director"
+
+				_, err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("jobs[0].properties.director must be a hash"))
+			})
+
+			It("validates required properties are provided by the job or the global properties", func() {
+				delete(deploymentManifest.Jobs[0].Properties["director"].(biproperty.Map), "name")
+
+				_, err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("jobs[0].properties.director.name must be provided"))
+
+				deploymentManifest.Properties["director"] = biproperty.Map{"name": "fake-director-name"}
+				_, err = validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the release jobs consume links", func() {
+			var deploymentManifest Manifest
+
+			BeforeEach(func() {
+				fakeRelease.ReleaseJobs = []bireljob.Job{
+					{
+						Name:     "fake-db-job-name",
+						Provides: []bireljob.LinkDefinition{{Name: "db", Type: "database"}},
+					},
+					{
+						Name:     "fake-other-db-job-name",
+						Provides: []bireljob.LinkDefinition{{Name: "other-db", Type: "database"}},
+					},
+					{
+						Name: "fake-job-name",
+						Consumes: []bireljob.LinkDefinition{
+							{Name: "db", Type: "database"},
+							{Name: "cache", Type: "cache", Optional: true},
+						},
+					},
+				}
+
+				deploymentManifest = validManifest
+				deploymentManifest.Jobs = []Job{validManifest.Jobs[0]}
+				deploymentManifest.Properties = biproperty.Map{}
+				deploymentManifest.Jobs[0].Properties = biproperty.Map{}
+				deploymentManifest.Jobs[0].Templates = []ReleaseJobRef{
+					{Name: "fake-db-job-name", Release: "fake-release-name"},
+					{Name: "fake-job-name", Release: "fake-release-name"},
+				}
+			})
+
+			It("accepts links provided by a job template of the deployment", func() {
+				_, err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("validates required links are provided", func() {
+				deploymentManifest.Jobs[0].Templates = deploymentManifest.Jobs[0].Templates[1:]
+
+				_, err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("jobs[0].templates[0].consumes.db must be provided by a job template of type 'database'"))
+			})
+
+			It("validates links chosen with 'from' are provided", func() {
+				deploymentManifest.Jobs[0].Templates[1].Consumes = map[string]string{"db": "fake-other-db"}
+
+				_, err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("jobs[0].templates[1].consumes.db.from must be the name of a link of type 'database' provided by a job template"))
+			})
+
+			It("validates links are provided by only one job template", func() {
+				deploymentManifest.Jobs = append(deploymentManifest.Jobs, Job{
+					Name:      "fake-errand-name",
+					Lifecycle: JobLifecycleErrand,
+					Templates: []ReleaseJobRef{
+						{Name: "fake-db-job-name", Release: "fake-release-name"},
+						{Name: "fake-job-name", Release: "fake-release-name"},
+						{Name: "fake-other-db-job-name", Release: "fake-release-name"},
+					},
+				})
+
+				_, err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("jobs[1].templates[1].consumes.db is provided by more than one job template ('fake-errand-name/fake-db-job-name', 'fake-errand-name/fake-other-db-job-name'), choose one with 'from'"))
+			})
+		})
 	})
 })
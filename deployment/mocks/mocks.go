@@ -39,14 +39,14 @@ func (_m *MockDeployment) EXPECT() *_MockDeploymentRecorder {
 	return _m.recorder
 }
 
-func (_m *MockDeployment) Delete(_param0 ui.Stage) error {
-	ret := _m.ctrl.Call(_m, "Delete", _param0)
+func (_m *MockDeployment) Delete(_param0 bool, _param1 ui.Stage) error {
+	ret := _m.ctrl.Call(_m, "Delete", _param0, _param1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-func (_mr *_MockDeploymentRecorder) Delete(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "Delete", arg0)
+func (_mr *_MockDeploymentRecorder) Delete(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Delete", arg0, arg1)
 }
 
 // Mock of Factory interface
@@ -101,15 +101,15 @@ func (_m *MockDeployer) EXPECT() *_MockDeployerRecorder {
 	return _m.recorder
 }
 
-func (_m *MockDeployer) Deploy(_param0 cloud.Cloud, _param1 manifest0.Manifest, _param2 stemcell.CloudStemcell, _param3 manifest.Registry, _param4 vm.Manager, _param5 blobstore.Blobstore, _param6 ui.Stage) (deployment.Deployment, error) {
-	ret := _m.ctrl.Call(_m, "Deploy", _param0, _param1, _param2, _param3, _param4, _param5, _param6)
+func (_m *MockDeployer) Deploy(_param0 cloud.Cloud, _param1 manifest0.Manifest, _param2 stemcell.CloudStemcell, _param3 manifest.Registry, _param4 vm.Manager, _param5 blobstore.Blobstore, _param6 bool, _param7 ui.Stage) (deployment.Deployment, error) {
+	ret := _m.ctrl.Call(_m, "Deploy", _param0, _param1, _param2, _param3, _param4, _param5, _param6, _param7)
 	ret0, _ := ret[0].(deployment.Deployment)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockDeployerRecorder) Deploy(arg0, arg1, arg2, arg3, arg4, arg5, arg6 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "Deploy", arg0, arg1, arg2, arg3, arg4, arg5, arg6)
+func (_mr *_MockDeployerRecorder) Deploy(arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Deploy", arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7)
 }
 
 // Mock of Manager interface
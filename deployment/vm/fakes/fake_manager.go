@@ -7,6 +7,7 @@ import (
 )
 
 type CreateInput struct {
+	JobName  string
 	Stemcell bistemcell.CloudStemcell
 	Manifest bideplmanifest.Manifest
 }
@@ -33,8 +34,9 @@ func (m *FakeManager) FindCurrent() (bivm.VM, bool, error) {
 	return m.findCurrentBehaviour.vm, m.findCurrentBehaviour.found, m.findCurrentBehaviour.err
 }
 
-func (m *FakeManager) Create(stemcell bistemcell.CloudStemcell, deploymentManifest bideplmanifest.Manifest) (bivm.VM, error) {
+func (m *FakeManager) Create(jobName string, stemcell bistemcell.CloudStemcell, deploymentManifest bideplmanifest.Manifest) (bivm.VM, error) {
 	input := CreateInput{
+		JobName:  jobName,
 		Stemcell: stemcell,
 		Manifest: deploymentManifest,
 	}
@@ -47,6 +47,21 @@ type FakeVM struct {
 	StopCalled int
 	StopErr    error
 
+	DrainInputs []DrainInput
+	DrainErr    error
+
+	RunScriptInputs  []RunScriptInput
+	RunScriptErrs    map[string]error
+	RunScriptResults map[string]biagentclient.ScriptResult
+
+	RunErrandInputs []string
+	RunErrandResult biagentclient.ErrandResult
+	RunErrandErr    error
+
+	FetchLogsCalled int
+	FetchLogsBlobID string
+	FetchLogsErr    error
+
 	ListDisksDisks []bidisk.Disk
 	ListDisksErr   error
 
@@ -66,6 +81,16 @@ type ApplyInput struct {
 	ApplySpec bias.ApplySpec
 }
 
+type DrainInput struct {
+	DrainType biagentclient.DrainType
+	NewSpecs  []bias.ApplySpec
+}
+
+type RunScriptInput struct {
+	ScriptName string
+	Timeout    time.Duration
+}
+
 type WaitUntilReadyInput struct {
 	Timeout time.Duration
 	Delay   time.Duration
@@ -97,6 +122,9 @@ func NewFakeVM(cid string) *FakeVM {
 		AttachDiskInputs:      []AttachDiskInput{},
 		DetachDiskInputs:      []DetachDiskInput{},
 		UnmountDiskInputs:     []UnmountDiskInput{},
+		RunScriptInputs:       []RunScriptInput{},
+		RunScriptErrs:         map[string]error{},
+		RunScriptResults:      map[string]biagentclient.ScriptResult{},
 		attachDiskBehavior:    map[string]error{},
 		detachDiskBehavior:    map[string]error{},
 		cid:                   cid,
@@ -188,6 +216,32 @@ func (vm *FakeVM) Stop() error {
 	return vm.StopErr
 }
 
+func (vm *FakeVM) Drain(drainType biagentclient.DrainType, newSpecs ...bias.ApplySpec) error {
+	vm.DrainInputs = append(vm.DrainInputs, DrainInput{
+		DrainType: drainType,
+		NewSpecs:  newSpecs,
+	})
+	return vm.DrainErr
+}
+
+func (vm *FakeVM) RunScript(scriptName string, timeout time.Duration) (biagentclient.ScriptResult, error) {
+	vm.RunScriptInputs = append(vm.RunScriptInputs, RunScriptInput{
+		ScriptName: scriptName,
+		Timeout:    timeout,
+	})
+	return vm.RunScriptResults[scriptName], vm.RunScriptErrs[scriptName]
+}
+
+func (vm *FakeVM) RunErrand(errandName string) (biagentclient.ErrandResult, error) {
+	vm.RunErrandInputs = append(vm.RunErrandInputs, errandName)
+	return vm.RunErrandResult, vm.RunErrandErr
+}
+
+func (vm *FakeVM) FetchLogs() (string, error) {
+	vm.FetchLogsCalled++
+	return vm.FetchLogsBlobID, vm.FetchLogsErr
+}
+
 func (vm *FakeVM) Disks() ([]bidisk.Disk, error) {
 	return vm.ListDisksDisks, vm.ListDisksErr
 }
@@ -16,7 +16,7 @@ import (
 
 type Manager interface {
 	FindCurrent() (VM, bool, error)
-	Create(bistemcell.CloudStemcell, bideplmanifest.Manifest) (VM, error)
+	Create(jobName string, stemcell bistemcell.CloudStemcell, deploymentManifest bideplmanifest.Manifest) (VM, error)
 }
 
 type manager struct {
@@ -79,8 +79,7 @@ func (m *manager) FindCurrent() (VM, bool, error) {
 	return vm, true, err
 }
 
-func (m *manager) Create(stemcell bistemcell.CloudStemcell, deploymentManifest bideplmanifest.Manifest) (VM, error) {
-	jobName := deploymentManifest.JobName()
+func (m *manager) Create(jobName string, stemcell bistemcell.CloudStemcell, deploymentManifest bideplmanifest.Manifest) (VM, error) {
 	networkInterfaces, err := deploymentManifest.NetworkInterfaces(jobName)
 	m.logger.Debug(m.logTag, "Creating VM with network interfaces: %#v", networkInterfaces)
 	if err != nil {
@@ -107,6 +106,11 @@ func (m *manager) Create(stemcell bistemcell.CloudStemcell, deploymentManifest b
 		return nil, bosherr.WrapError(err, "Updating current vm record")
 	}
 
+	err = m.vmRepo.UpdateCurrentAgentID(agentID)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Updating current agent id record")
+	}
+
 	vm := NewVM(
 		cid,
 		m.vmRepo,
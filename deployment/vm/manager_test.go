@@ -115,7 +115,7 @@ var _ = Describe("Manager", func() {
 
 	Describe("Create", func() {
 		It("creates a VM", func() {
-			vm, err := manager.Create(stemcell, deploymentManifest)
+			vm, err := manager.Create("fake-job", stemcell, deploymentManifest)
 			Expect(err).ToNot(HaveOccurred())
 			expectedVM := NewVM(
 				"fake-vm-cid",
@@ -141,19 +141,38 @@ var _ = Describe("Manager", func() {
 		})
 
 		It("updates the current vm record", func() {
-			_, err := manager.Create(stemcell, deploymentManifest)
+			_, err := manager.Create("fake-job", stemcell, deploymentManifest)
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(fakeVMRepo.UpdateCurrentCID).To(Equal("fake-vm-cid"))
 		})
 
+		It("records the agent id of the vm", func() {
+			_, err := manager.Create("fake-job", stemcell, deploymentManifest)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeVMRepo.UpdateCurrentAgentIDInput).To(Equal("fake-uuid-0"))
+		})
+
+		Context("when recording the agent id fails", func() {
+			BeforeEach(func() {
+				fakeVMRepo.UpdateCurrentAgentIDErr = errors.New("fake-update-agent-id-error")
+			})
+
+			It("returns an error", func() {
+				_, err := manager.Create("fake-job", stemcell, deploymentManifest)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-update-agent-id-error"))
+			})
+		})
+
 		Context("when creating the vm fails", func() {
 			BeforeEach(func() {
 				fakeCloud.CreateVMErr = errors.New("fake-create-error")
 			})
 
 			It("returns an error", func() {
-				_, err := manager.Create(stemcell, deploymentManifest)
+				_, err := manager.Create("fake-job", stemcell, deploymentManifest)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-create-error"))
 			})
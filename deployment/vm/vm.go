@@ -25,6 +25,10 @@ type VM interface {
 	WaitUntilReady(timeout time.Duration, delay time.Duration) error
 	Start() error
 	Stop() error
+	Drain(drainType biagentclient.DrainType, newSpecs ...bias.ApplySpec) error
+	RunScript(scriptName string, timeout time.Duration) (biagentclient.ScriptResult, error)
+	RunErrand(errandName string) (biagentclient.ErrandResult, error)
+	FetchLogs() (blobID string, err error)
 	Apply(bias.ApplySpec) error
 	UpdateDisks(bideplmanifest.DiskPool, biui.Stage) ([]bidisk.Disk, error)
 	WaitToBeRunning(maxAttempts int, delay time.Duration) error
@@ -114,6 +118,53 @@ func (vm *vm) Stop() error {
 	return nil
 }
 
+func (vm *vm) Drain(drainType biagentclient.DrainType, newSpecs ...bias.ApplySpec) error {
+	vm.logger.Debug(vm.logTag, "Draining jobs with type '%s'", drainType)
+	timeService := boshtime.NewConcreteService() //TODO: inject timeService
+	drainer := biagentclient.NewDrainer(vm.agentClient, timeService, vm.logger)
+	err := drainer.Drain(drainType, newSpecs...)
+	if err != nil {
+		return bosherr.WrapError(err, "Draining agent")
+	}
+
+	return nil
+}
+
+func (vm *vm) RunScript(scriptName string, timeout time.Duration) (biagentclient.ScriptResult, error) {
+	vm.logger.Debug(vm.logTag, "Running '%s' script on the agent", scriptName)
+	result, err := vm.agentClient.RunScript(scriptName, map[string]interface{}{}, timeout)
+	if err != nil {
+		return result, bosherr.WrapErrorf(err, "Running '%s' script", scriptName)
+	}
+
+	vm.logger.Info(vm.logTag, "Script '%s' exited with status %d\nstdout:\n%s\nstderr:\n%s", scriptName, result.ExitStatus, result.Stdout, result.Stderr)
+
+	if result.ExitStatus != 0 {
+		return result, bosherr.Errorf("Script '%s' exited with status %d: %s", scriptName, result.ExitStatus, result.Stderr)
+	}
+
+	return result, nil
+}
+
+func (vm *vm) RunErrand(errandName string) (biagentclient.ErrandResult, error) {
+	vm.logger.Debug(vm.logTag, "Running errand '%s' on the agent", errandName)
+	result, err := vm.agentClient.RunErrand(errandName)
+	if err != nil {
+		return result, bosherr.WrapErrorf(err, "Running errand '%s'", errandName)
+	}
+
+	return result, nil
+}
+
+func (vm *vm) FetchLogs() (string, error) {
+	blobID, err := vm.agentClient.FetchLogs("job", []string{})
+	if err != nil {
+		return "", bosherr.WrapError(err, "Fetching logs from the agent")
+	}
+
+	return blobID, nil
+}
+
 func (vm *vm) Apply(newState bias.ApplySpec) error {
 	vm.logger.Debug(vm.logTag, "Sending apply message to the agent with '%#v'", newState)
 	err := vm.agentClient.Apply(newState)
@@ -2,6 +2,7 @@ package vm_test
 
 import (
 	"errors"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -149,6 +150,107 @@ var _ = Describe("VM", func() {
 		})
 	})
 
+	Describe("Drain", func() {
+		It("sends drain to the agent", func() {
+			err := vm.Drain(biagentclient.DrainTypeUpdate, applySpec)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeAgentClient.DrainInputs).To(Equal([]fakebiagentclient.DrainInput{
+				{DrainType: biagentclient.DrainTypeUpdate, NewSpecs: []bias.ApplySpec{applySpec}},
+			}))
+		})
+
+		Context("when draining the agent fails", func() {
+			BeforeEach(func() {
+				fakeAgentClient.SetDrainBehavior(0, errors.New("fake-drain-error"))
+			})
+
+			It("returns an error", func() {
+				err := vm.Drain(biagentclient.DrainTypeShutdown)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-drain-error"))
+			})
+		})
+	})
+
+	Describe("RunScript", func() {
+		It("runs the script on the agent within the timeout", func() {
+			_, err := vm.RunScript("pre-start", 1*time.Minute)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeAgentClient.RunScriptInputs).To(Equal([]fakebiagentclient.RunScriptInput{
+				{ScriptName: "pre-start", Options: map[string]interface{}{}, Timeout: 1 * time.Minute},
+			}))
+		})
+
+		It("returns the script result", func() {
+			fakeAgentClient.SetRunScriptBehavior("pre-start", biagentclient.ScriptResult{Stdout: "fake-stdout", Stderr: "fake-stderr"}, nil)
+
+			result, err := vm.RunScript("pre-start", 1*time.Minute)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(biagentclient.ScriptResult{Stdout: "fake-stdout", Stderr: "fake-stderr"}))
+		})
+
+		Context("when the script exits with a non-zero status", func() {
+			BeforeEach(func() {
+				fakeAgentClient.SetRunScriptBehavior("post-start", biagentclient.ScriptResult{ExitStatus: 1, Stderr: "fake-stderr"}, nil)
+			})
+
+			It("returns an error with the script stderr along with the script result", func() {
+				result, err := vm.RunScript("post-start", 1*time.Minute)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Script 'post-start' exited with status 1: fake-stderr"))
+				Expect(result.Stderr).To(Equal("fake-stderr"))
+			})
+		})
+
+		Context("when running the script fails", func() {
+			BeforeEach(func() {
+				fakeAgentClient.SetRunScriptBehavior("pre-start", biagentclient.ScriptResult{}, errors.New("fake-run-script-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := vm.RunScript("pre-start", 1*time.Minute)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-run-script-error"))
+			})
+		})
+	})
+
+	Describe("RunErrand", func() {
+		It("runs the errand on the agent and returns its result", func() {
+			fakeAgentClient.SetRunErrandBehavior(biagentclient.ErrandResult{ExitCode: 1, Stdout: "fake-stdout"}, nil)
+
+			result, err := vm.RunErrand("fake-errand-name")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(biagentclient.ErrandResult{ExitCode: 1, Stdout: "fake-stdout"}))
+			Expect(fakeAgentClient.RunErrandInputs).To(Equal([]string{"fake-errand-name"}))
+		})
+
+		Context("when running the errand fails", func() {
+			BeforeEach(func() {
+				fakeAgentClient.SetRunErrandBehavior(biagentclient.ErrandResult{}, errors.New("fake-run-errand-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := vm.RunErrand("fake-errand-name")
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-run-errand-error"))
+			})
+		})
+	})
+
+	Describe("FetchLogs", func() {
+		It("fetches the job logs from the agent", func() {
+			fakeAgentClient.SetFetchLogsBehavior("fake-logs-blob-id", nil)
+
+			blobID, err := vm.FetchLogs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blobID).To(Equal("fake-logs-blob-id"))
+			Expect(fakeAgentClient.FetchLogsInputs).To(Equal([]fakebiagentclient.FetchLogsInput{
+				{LogType: "job", Filters: []string{}},
+			}))
+		})
+	})
+
 	Describe("Apply", func() {
 		It("sends apply spec to the agent", func() {
 			err := vm.Apply(applySpec)
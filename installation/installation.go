@@ -3,6 +3,8 @@ package installation
 import (
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 
+	binats "github.com/cloudfoundry/bosh-init/deployment/agentclient/nats"
+	bisshtunnel "github.com/cloudfoundry/bosh-init/deployment/sshtunnel"
 	biinstalljob "github.com/cloudfoundry/bosh-init/installation/job"
 	biinstallmanifest "github.com/cloudfoundry/bosh-init/installation/manifest"
 	biregistry "github.com/cloudfoundry/bosh-init/registry"
@@ -13,6 +15,8 @@ type Installation interface {
 	Job() biinstalljob.InstalledJob
 	StartRegistry() error
 	StopRegistry() error
+	StartMbus() error
+	StopMbus() error
 }
 
 type installation struct {
@@ -20,8 +24,10 @@ type installation struct {
 	job                   biinstalljob.InstalledJob
 	manifest              biinstallmanifest.Manifest
 	registryServerManager biregistry.ServerManager
+	mbusServer            binats.Server
 
 	registryServer biregistry.Server
+	mbusStarted    bool
 }
 
 func NewInstallation(
@@ -29,12 +35,14 @@ func NewInstallation(
 	job biinstalljob.InstalledJob,
 	manifest biinstallmanifest.Manifest,
 	registryServerManager biregistry.ServerManager,
+	mbusServer binats.Server,
 ) Installation {
 	return &installation{
 		target:                target,
 		job:                   job,
 		manifest:              manifest,
 		registryServerManager: registryServerManager,
+		mbusServer:            mbusServer,
 	}
 }
 
@@ -52,7 +60,7 @@ func (i *installation) StartRegistry() error {
 			return bosherr.Error("Registry already started")
 		}
 		config := i.manifest.Registry
-		registryServer, err := i.registryServerManager.Start(config.Username, config.Password, config.Host, config.Port)
+		registryServer, err := i.registryServerManager.Start(config.Username, config.Password, config.Host, config.Port, config.Persistent)
 		if err != nil {
 			return bosherr.WrapError(err, "Starting registry")
 		}
@@ -74,3 +82,46 @@ func (i *installation) StopRegistry() error {
 	}
 	return nil
 }
+
+// StartMbus starts the embedded NATS server when the mbus url uses the nats scheme.
+// The registry ssh tunnel, if configured, is reused to forward the NATS port onto the VM.
+func (i *installation) StartMbus() error {
+	if binats.IsNATSURL(i.manifest.Mbus) {
+		if i.mbusStarted {
+			return bosherr.Error("Mbus already started")
+		}
+
+		sshTunnelOptions := bisshtunnel.Options{}
+		sshTunnelConfig := i.manifest.Registry.SSHTunnel
+		if sshTunnelConfig != (biinstallmanifest.SSHTunnel{}) {
+			sshTunnelOptions = bisshtunnel.Options{
+				Host:       sshTunnelConfig.Host,
+				Port:       sshTunnelConfig.Port,
+				User:       sshTunnelConfig.User,
+				Password:   sshTunnelConfig.Password,
+				PrivateKey: sshTunnelConfig.PrivateKey,
+			}
+		}
+
+		err := i.mbusServer.Start(i.manifest.Mbus, sshTunnelOptions)
+		if err != nil {
+			return bosherr.WrapError(err, "Starting NATS server")
+		}
+		i.mbusStarted = true
+	}
+	return nil
+}
+
+func (i *installation) StopMbus() error {
+	if binats.IsNATSURL(i.manifest.Mbus) {
+		if !i.mbusStarted {
+			return bosherr.Error("Mbus must be started before it can be stopped")
+		}
+		err := i.mbusServer.Stop()
+		if err != nil {
+			return bosherr.WrapError(err, "Stopping NATS server")
+		}
+		i.mbusStarted = false
+	}
+	return nil
+}
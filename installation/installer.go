@@ -7,6 +7,7 @@ import (
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 	boshsys "github.com/cloudfoundry/bosh-agent/system"
 
+	binats "github.com/cloudfoundry/bosh-init/deployment/agentclient/nats"
 	biinstalljob "github.com/cloudfoundry/bosh-init/installation/job"
 	biinstallmanifest "github.com/cloudfoundry/bosh-init/installation/manifest"
 	biinstallpkg "github.com/cloudfoundry/bosh-init/installation/pkg"
@@ -27,6 +28,7 @@ type installer struct {
 	packageInstaller      biinstallpkg.Installer
 	jobInstaller          biinstalljob.Installer
 	registryServerManager biregistry.ServerManager
+	mbusServer            binats.Server
 	logger                boshlog.Logger
 	logTag                string
 }
@@ -39,6 +41,7 @@ func NewInstaller(
 	packageInstaller biinstallpkg.Installer,
 	jobInstaller biinstalljob.Installer,
 	registryServerManager biregistry.ServerManager,
+	mbusServer binats.Server,
 	logger boshlog.Logger,
 ) Installer {
 	return &installer{
@@ -49,6 +52,7 @@ func NewInstaller(
 		packageInstaller:      packageInstaller,
 		jobInstaller:          jobInstaller,
 		registryServerManager: registryServerManager,
+		mbusServer:            mbusServer,
 		logger:                logger,
 		logTag:                "installer",
 	}
@@ -92,5 +96,6 @@ func (i *installer) Install(manifest biinstallmanifest.Manifest, stage biui.Stag
 		installedJob,
 		manifest,
 		i.registryServerManager,
+		i.mbusServer,
 	), nil
 }
@@ -8,6 +8,7 @@ import (
 	boshsys "github.com/cloudfoundry/bosh-agent/system"
 	boshuuid "github.com/cloudfoundry/bosh-agent/uuid"
 
+	binats "github.com/cloudfoundry/bosh-init/deployment/agentclient/nats"
 	bideplrel "github.com/cloudfoundry/bosh-init/deployment/release"
 	biindex "github.com/cloudfoundry/bosh-init/index"
 	biinstallblob "github.com/cloudfoundry/bosh-init/installation/blob"
@@ -35,6 +36,7 @@ type installerFactory struct {
 	releaseJobResolver    bideplrel.JobResolver
 	uuidGenerator         boshuuid.Generator
 	registryServerManager biregistry.ServerManager
+	mbusServer            binats.Server
 	logger                boshlog.Logger
 	logTag                string
 }
@@ -48,6 +50,7 @@ func NewInstallerFactory(
 	releaseJobResolver bideplrel.JobResolver,
 	uuidGenerator boshuuid.Generator,
 	registryServerManager biregistry.ServerManager,
+	mbusServer binats.Server,
 	logger boshlog.Logger,
 ) InstallerFactory {
 	return &installerFactory{
@@ -59,6 +62,7 @@ func NewInstallerFactory(
 		releaseJobResolver:    releaseJobResolver,
 		uuidGenerator:         uuidGenerator,
 		registryServerManager: registryServerManager,
+		mbusServer:            mbusServer,
 		logger:                logger,
 		logTag:                "installer",
 	}
@@ -89,6 +93,7 @@ func (f *installerFactory) NewInstaller() (Installer, error) {
 		context.PackageInstaller(),
 		context.JobInstaller(),
 		f.registryServerManager,
+		f.mbusServer,
 		f.logger,
 	), nil
 }
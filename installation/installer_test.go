@@ -9,6 +9,7 @@ import (
 	. "github.com/cloudfoundry/bosh-init/installation"
 
 	"code.google.com/p/gomock/gomock"
+	mock_nats "github.com/cloudfoundry/bosh-init/deployment/agentclient/nats/mocks"
 	mock_install_job "github.com/cloudfoundry/bosh-init/installation/job/mocks"
 	mock_install_pkg "github.com/cloudfoundry/bosh-init/installation/pkg/mocks"
 	mock_install_state "github.com/cloudfoundry/bosh-init/installation/state/mocks"
@@ -45,6 +46,7 @@ var _ = Describe("Installer", func() {
 		mockJobInstaller     *mock_install_job.MockInstaller
 
 		mockRegistryServerManager *mock_registry.MockServerManager
+		mockMbusServer            *mock_nats.MockServer
 
 		logger boshlog.Logger
 
@@ -64,6 +66,7 @@ var _ = Describe("Installer", func() {
 		mockJobInstaller = mock_install_job.NewMockInstaller(mockCtrl)
 
 		mockRegistryServerManager = mock_registry.NewMockServerManager(mockCtrl)
+		mockMbusServer = mock_nats.NewMockServer(mockCtrl)
 
 		packagesPath = "/path/to/installed/packages"
 		deploymentManifestPath = "/path/to/manifest.yml"
@@ -79,6 +82,7 @@ var _ = Describe("Installer", func() {
 			mockPackageInstaller,
 			mockJobInstaller,
 			mockRegistryServerManager,
+			mockMbusServer,
 			logger,
 		)
 	})
@@ -168,6 +172,7 @@ var _ = Describe("Installer", func() {
 				installedJob,
 				installationManifest,
 				mockRegistryServerManager,
+				mockMbusServer,
 			)
 
 			Expect(installation).To(Equal(expectedInstallation))
@@ -9,9 +9,18 @@ type Manifest struct {
 	Template   ReleaseJobRef
 	Properties biproperty.Map
 	Mbus       string
+	Blobstore  string
 	Registry   Registry
 }
 
+// BlobstoreURL returns the url of the blobstore shared with the agent, which defaults to the agent's https mbus
+func (m Manifest) BlobstoreURL() string {
+	if m.Blobstore != "" {
+		return m.Blobstore
+	}
+	return m.Mbus
+}
+
 type ReleaseJobRef struct {
 	Name    string
 	Release string
@@ -23,6 +32,9 @@ type Registry struct {
 	Host      string
 	Port      int
 	SSHTunnel SSHTunnel
+
+	// Persistent registries are kept in the deployment state, see 'bosh-init registry serve'
+	Persistent bool
 }
 
 func (r Registry) IsEmpty() bool {
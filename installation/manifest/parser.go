@@ -31,7 +31,13 @@ type installation struct {
 	Template   template
 	Properties map[interface{}]interface{}
 	SSHTunnel  SSHTunnel `yaml:"ssh_tunnel"`
+	Registry   registry
 	Mbus       string
+	Blobstore  string
+}
+
+type registry struct {
+	Persistent bool
 }
 
 func (i installation) HasSSHTunnel() bool {
@@ -80,7 +86,8 @@ func (p *parser) Parse(path string) (Manifest, error) {
 			Name:    comboManifest.CloudProvider.Template.Name,
 			Release: comboManifest.CloudProvider.Template.Release,
 		},
-		Mbus: comboManifest.CloudProvider.Mbus,
+		Mbus:      comboManifest.CloudProvider.Mbus,
+		Blobstore: comboManifest.CloudProvider.Blobstore,
 	}
 
 	properties, err := biproperty.BuildMap(comboManifest.CloudProvider.Properties)
@@ -96,6 +103,7 @@ func (p *parser) Parse(path string) (Manifest, error) {
 		}
 		installationManifest.PopulateRegistry("registry", password, "127.0.0.1", 6901, comboManifest.CloudProvider.SSHTunnel)
 	}
+	installationManifest.Registry.Persistent = comboManifest.CloudProvider.Registry.Persistent
 
 	return installationManifest, nil
 }
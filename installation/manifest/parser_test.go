@@ -145,6 +145,21 @@ cloud_provider:
 			}))
 		})
 
+		Context("when the registry is persistent", func() {
+			BeforeEach(func() {
+				contents, err := fakeFs.ReadFileString(comboManifestPath)
+				Expect(err).ToNot(HaveOccurred())
+				fakeFs.WriteFileString(comboManifestPath, contents+"  registry:\n    persistent: true\n")
+			})
+
+			It("marks the registry as persistent", func() {
+				installationManifest, err := parser.Parse(comboManifestPath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(installationManifest.Registry.Persistent).To(BeTrue())
+				Expect(installationManifest.Registry.Password).To(Equal("fake-uuid"))
+			})
+		})
+
 		Context("when expanding the key file path fails", func() {
 			BeforeEach(func() {
 				fakeFs.ExpandPathErr = errors.New("fake-expand-error")
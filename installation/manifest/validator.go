@@ -6,6 +6,7 @@ import (
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 
+	binats "github.com/cloudfoundry/bosh-init/deployment/agentclient/nats"
 	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
 )
 
@@ -41,6 +42,14 @@ func (v *validator) Validate(manifest Manifest, releaseSetManifest birelsetmanif
 		errs = append(errs, bosherr.Errorf("cloud_provider.template.release '%s' must refer to a release in releases", cpiReleaseName))
 	}
 
+	if manifest.Registry.Persistent && manifest.Registry.SSHTunnel == (SSHTunnel{}) {
+		errs = append(errs, bosherr.Error("cloud_provider.registry.persistent requires cloud_provider.ssh_tunnel"))
+	}
+
+	if binats.IsNATSURL(manifest.Mbus) && v.isBlank(manifest.Blobstore) {
+		errs = append(errs, bosherr.Error("cloud_provider.blobstore must be provided when cloud_provider.mbus uses nats"))
+	}
+
 	if len(errs) > 0 {
 		return bosherr.NewMultiError(errs...)
 	}
@@ -42,6 +42,16 @@ func (_mr *_MockInstallationRecorder) Job() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Job")
 }
 
+func (_m *MockInstallation) StartMbus() error {
+	ret := _m.ctrl.Call(_m, "StartMbus")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockInstallationRecorder) StartMbus() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "StartMbus")
+}
+
 func (_m *MockInstallation) StartRegistry() error {
 	ret := _m.ctrl.Call(_m, "StartRegistry")
 	ret0, _ := ret[0].(error)
@@ -52,6 +62,16 @@ func (_mr *_MockInstallationRecorder) StartRegistry() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "StartRegistry")
 }
 
+func (_m *MockInstallation) StopMbus() error {
+	ret := _m.ctrl.Call(_m, "StopMbus")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockInstallationRecorder) StopMbus() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "StopMbus")
+}
+
 func (_m *MockInstallation) StopRegistry() error {
 	ret := _m.ctrl.Call(_m, "StopRegistry")
 	ret0, _ := ret[0].(error)
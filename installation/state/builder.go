@@ -60,7 +60,8 @@ func (b *builder) Build(installationManifest biinstallmanifest.Manifest, stage b
 		return nil, bosherr.WrapError(err, "Resolving jobs for installation")
 	}
 
-	compiledPackageRefs, err := b.jobDependencyCompiler.Compile(releaseJobs, stage)
+	// local compilation for the installation runs one package at a time
+	compiledPackageRefs, err := b.jobDependencyCompiler.Compile(releaseJobs, 1, stage)
 	if err != nil {
 		return nil, bosherr.WrapError(err, "Compiling job package dependencies for installation")
 	}
@@ -109,7 +110,7 @@ func (b *builder) renderJobTemplates(
 ) ([]biinstalljob.RenderedJobRef, error) {
 	renderedJobRefs := make([]biinstalljob.RenderedJobRef, 0, len(releaseJobs))
 	err := stage.Perform("Rendering job templates", func() error {
-		renderedJobList, err := b.jobListRenderer.Render(releaseJobs, jobProperties, globalProperties, deploymentName)
+		renderedJobList, err := b.jobListRenderer.Render(releaseJobs, jobProperties, globalProperties, deploymentName, bitemplate.InstanceSpec{})
 		if err != nil {
 			return err
 		}
@@ -153,7 +153,7 @@ var _ = Describe("Builder", func() {
 				SHA1:        "fake-compiled-package-sha1-2",
 			},
 		}
-		expectCompile = mockDependencyCompiler.EXPECT().Compile(releaseJobs, fakeStage).Return(compiledPackageRefs, nil).AnyTimes()
+		expectCompile = mockDependencyCompiler.EXPECT().Compile(releaseJobs, 1, fakeStage).Return(compiledPackageRefs, nil).AnyTimes()
 
 		jobProperties := biproperty.Map{
 			"fake-installation-property": "fake-installation-property-value",
@@ -164,7 +164,7 @@ var _ = Describe("Builder", func() {
 		renderedJobList := bitemplate.NewRenderedJobList()
 		renderedJobList.Add(bitemplate.NewRenderedJob(releaseJob, "/fake-rendered-job-cpi", fakeFS, logger))
 
-		expectJobRender = mockJobListRenderer.EXPECT().Render(releaseJobs, jobProperties, globalProperties, deploymentName).Return(renderedJobList, nil).AnyTimes()
+		expectJobRender = mockJobListRenderer.EXPECT().Render(releaseJobs, jobProperties, globalProperties, deploymentName, bitemplate.InstanceSpec{}).Return(renderedJobList, nil).AnyTimes()
 
 		fakeCompressor.CompressFilesInDirTarballPath = "/fake-rendered-job-tarball-cpi.tgz"
 
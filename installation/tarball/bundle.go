@@ -0,0 +1,241 @@
+package tarball
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	boshcmd "github.com/cloudfoundry/bosh-agent/platform/commands"
+	boshsys "github.com/cloudfoundry/bosh-agent/system"
+
+	bicrypto "github.com/cloudfoundry/bosh-init/crypto"
+	biui "github.com/cloudfoundry/bosh-init/ui"
+)
+
+const bundleIndexFileName = "index.json"
+
+// Bundler moves release and stemcell tarballs to machines without network access.
+// A bundle is a tarball of the downloaded tarballs and an index of their URLs and digests.
+type Bundler interface {
+	// Create downloads the tarballs of the given sources (unless cached) and archives them at bundlePath.
+	// Sources that are not downloaded (i.e. file://) are left out.
+	Create(sources []Source, bundlePath string, stage biui.Stage) error
+
+	// Import verifies the tarballs of the bundle at bundlePath and saves them in the cache
+	Import(bundlePath string, stage biui.Stage) error
+}
+
+type bundleIndex struct {
+	Tarballs []bundleEntry `json:"tarballs"`
+}
+
+type bundleEntry struct {
+	File              string `json:"file"`
+	URL               string `json:"url"`
+	SHA1              string `json:"sha1"`
+	SourceDescription string `json:"description"`
+}
+
+func (e bundleEntry) GetURL() string      { return e.URL }
+func (e bundleEntry) GetSHA1() string     { return e.SHA1 }
+func (e bundleEntry) Description() string { return e.SourceDescription }
+
+type bundler struct {
+	provider         Provider
+	cache            Cache
+	workPath         string
+	fs               boshsys.FileSystem
+	compressor       boshcmd.Compressor
+	digestCalculator bicrypto.DigestCalculator
+	logger           boshlog.Logger
+	logTag           string
+}
+
+// NewBundler returns a Bundler that extracts bundles to workPath,
+// which must be on the same file system as the cache for its tarballs to be moved into it.
+func NewBundler(
+	provider Provider,
+	cache Cache,
+	workPath string,
+	fs boshsys.FileSystem,
+	compressor boshcmd.Compressor,
+	digestCalculator bicrypto.DigestCalculator,
+	logger boshlog.Logger,
+) Bundler {
+	return &bundler{
+		provider:         provider,
+		cache:            cache,
+		workPath:         workPath,
+		fs:               fs,
+		compressor:       compressor,
+		digestCalculator: digestCalculator,
+		logger:           logger,
+		logTag:           "tarballBundler",
+	}
+}
+
+func (b *bundler) Create(sources []Source, bundlePath string, stage biui.Stage) error {
+	bundleDir, err := b.fs.TempDir("bosh-init-bundle")
+	if err != nil {
+		return bosherr.WrapError(err, "Creating bundle directory")
+	}
+	defer func() {
+		err := b.fs.RemoveAll(bundleDir)
+		if err != nil {
+			b.logger.Warn(b.logTag, "Failed to delete bundle directory '%s': %s", bundleDir, err.Error())
+		}
+	}()
+
+	index := bundleIndex{Tarballs: []bundleEntry{}}
+	bundled := map[string]bool{}
+	for _, source := range sources {
+		if !strings.HasPrefix(source.GetURL(), "http") {
+			b.logger.Warn(b.logTag, "Not bundling %s from '%s', only downloaded tarballs are bundled", source.Description(), source.GetURL())
+			continue
+		}
+
+		fileName := filepath.Base(b.cache.Path(source))
+		if bundled[fileName] {
+			continue
+		}
+
+		tarballPath, err := b.provider.Get(source, stage)
+		if err != nil {
+			return err
+		}
+
+		err = b.fs.CopyFile(tarballPath, filepath.Join(bundleDir, fileName))
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Copying %s to bundle directory", source.Description())
+		}
+
+		bundled[fileName] = true
+		index.Tarballs = append(index.Tarballs, bundleEntry{
+			File:              fileName,
+			URL:               source.GetURL(),
+			SHA1:              source.GetSHA1(),
+			SourceDescription: source.Description(),
+		})
+	}
+
+	return stage.Perform(fmt.Sprintf("Creating bundle '%s'", bundlePath), func() error {
+		indexContents, err := json.MarshalIndent(index, "", "  ")
+		if err != nil {
+			return bosherr.WrapError(err, "Marshalling bundle index")
+		}
+
+		err = b.fs.WriteFile(filepath.Join(bundleDir, bundleIndexFileName), indexContents)
+		if err != nil {
+			return bosherr.WrapError(err, "Writing bundle index")
+		}
+
+		archivePath, err := b.compressor.CompressFilesInDir(bundleDir)
+		if err != nil {
+			return bosherr.WrapError(err, "Compressing bundle")
+		}
+		defer func() {
+			err := b.compressor.CleanUp(archivePath)
+			if err != nil {
+				b.logger.Warn(b.logTag, "Failed to clean up compressed bundle '%s': %s", archivePath, err.Error())
+			}
+		}()
+
+		err = b.fs.CopyFile(archivePath, bundlePath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Copying bundle to '%s'", bundlePath)
+		}
+
+		return nil
+	})
+}
+
+func (b *bundler) Import(bundlePath string, stage biui.Stage) error {
+	if !b.fs.FileExists(bundlePath) {
+		return bosherr.Errorf("Bundle '%s' does not exist", bundlePath)
+	}
+
+	err := b.fs.RemoveAll(b.workPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Deleting bundle directory '%s'", b.workPath)
+	}
+	err = b.fs.MkdirAll(b.workPath, 0766)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating bundle directory '%s'", b.workPath)
+	}
+	defer func() {
+		err := b.fs.RemoveAll(b.workPath)
+		if err != nil {
+			b.logger.Warn(b.logTag, "Failed to delete bundle directory '%s': %s", b.workPath, err.Error())
+		}
+	}()
+
+	var index bundleIndex
+	err = stage.Perform(fmt.Sprintf("Extracting bundle '%s'", bundlePath), func() error {
+		err := b.compressor.DecompressFileToDir(bundlePath, b.workPath, boshcmd.CompressorOptions{})
+		if err != nil {
+			return bosherr.WrapError(err, "Extracting bundle")
+		}
+
+		indexContents, err := b.fs.ReadFile(filepath.Join(b.workPath, bundleIndexFileName))
+		if err != nil {
+			return bosherr.WrapError(err, "Reading bundle index")
+		}
+
+		err = json.Unmarshal(indexContents, &index)
+		if err != nil {
+			return bosherr.WrapError(err, "Unmarshalling bundle index")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range index.Tarballs {
+		err = stage.Perform(fmt.Sprintf("Importing %s", entry.Description()), func() error {
+			return b.importTarball(entry)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *bundler) importTarball(entry bundleEntry) error {
+	if _, found := b.cache.Get(entry); found {
+		return biui.NewSkipStageError(bosherr.Error("Already imported"), "Found in local cache")
+	}
+
+	expectedDigest, err := bicrypto.ParseDigest(entry.SHA1)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Parsing expected digest of %s", entry.Description())
+	}
+
+	tarballPath := filepath.Join(b.workPath, filepath.Base(entry.File))
+	if !b.fs.FileExists(tarballPath) {
+		return bosherr.Errorf("Bundle is missing '%s'", entry.File)
+	}
+
+	actualDigest, err := b.digestCalculator.Calculate(tarballPath, expectedDigest.Algorithm)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Calculating %s of '%s'", expectedDigest.Algorithm, entry.File)
+	}
+
+	if actualDigest != expectedDigest.Value {
+		return bosherr.Errorf(
+			"%s of bundled file '%s' does not match expected %s '%s'",
+			strings.ToUpper(expectedDigest.Algorithm),
+			actualDigest,
+			strings.ToUpper(expectedDigest.Algorithm),
+			expectedDigest.Value,
+		)
+	}
+
+	return b.cache.Save(tarballPath, entry)
+}
@@ -0,0 +1,210 @@
+package tarball_test
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	fakecmd "github.com/cloudfoundry/bosh-agent/platform/commands/fakes"
+	fakesys "github.com/cloudfoundry/bosh-agent/system/fakes"
+	faketime "github.com/cloudfoundry/bosh-agent/time/fakes"
+	fakebicrypto "github.com/cloudfoundry/bosh-init/crypto/fakes"
+	fakebihttpclient "github.com/cloudfoundry/bosh-init/deployment/httpclient/fakes"
+	fakebiui "github.com/cloudfoundry/bosh-init/ui/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-init/installation/tarball"
+)
+
+var _ = Describe("Bundler", func() {
+	var (
+		bundler          Bundler
+		cache            Cache
+		fs               *fakesys.FakeFileSystem
+		compressor       *fakecmd.FakeCompressor
+		digestCalculator *fakebicrypto.FakeDigestCalculator
+		httpClient       *fakebihttpclient.FakeHTTPClient
+		fakeStage        *fakebiui.FakeStage
+
+		releaseSource  *fakeSource
+		stemcellSource *fakeSource
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		cache = NewCache("/fake-cache", 0, fs, &faketime.FakeService{}, logger)
+		compressor = fakecmd.NewFakeCompressor()
+		digestCalculator = fakebicrypto.NewFakeDigestCalculator()
+		httpClient = fakebihttpclient.NewFakeHTTPClient()
+		provider := NewProvider(cache, fs, httpClient, digestCalculator, 1, 0, logger)
+		bundler = NewBundler(provider, cache, "/fake-bundle-work", fs, compressor, digestCalculator, logger)
+		fakeStage = fakebiui.NewFakeStage()
+
+		releaseSource = newFakeSource("http://fake-release-url", "5bd3fd3bf1e4b15a1d5e8f3d6ce39a4c93bc7e91", "release 'fake-release'")
+		stemcellSource = newFakeSource("https://fake-stemcell-url", "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", "stemcell")
+	})
+
+	Describe("Create", func() {
+		BeforeEach(func() {
+			fs.TempDirDir = "/fake-bundle-dir"
+			compressor.CompressFilesInDirTarballPath = "/fake-compressed-bundle.tgz"
+			fs.WriteFileString("/fake-compressed-bundle.tgz", "fake-bundle-contents")
+
+			fs.WriteFileString("/fake-release", "fake-release-contents")
+			Expect(cache.Save("/fake-release", releaseSource)).ToNot(HaveOccurred())
+			fs.WriteFileString("/fake-stemcell", "fake-stemcell-contents")
+			Expect(cache.Save("/fake-stemcell", stemcellSource)).ToNot(HaveOccurred())
+		})
+
+		It("archives the tarballs and an index of their URLs and digests", func() {
+			// keeps the bundle directory to inspect it
+			fs.RegisterRemoveAllError("/fake-bundle-dir", errors.New("fake-remove-all-error"))
+
+			err := bundler.Create([]Source{releaseSource, stemcellSource, releaseSource}, "/fake-bundle.tgz", fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+
+			var bundledIndex map[string]interface{}
+			indexContents, err := fs.ReadFile("/fake-bundle-dir/index.json")
+			Expect(err).ToNot(HaveOccurred())
+			err = json.Unmarshal(indexContents, &bundledIndex)
+			Expect(err).ToNot(HaveOccurred())
+
+			bundledRelease, err := fs.ReadFileString("/fake-bundle-dir/" + filepath.Base(cache.Path(releaseSource)))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(compressor.CompressFilesInDirDir).To(Equal("/fake-bundle-dir"))
+			Expect(bundledRelease).To(Equal("fake-release-contents"))
+			Expect(bundledIndex).To(Equal(map[string]interface{}{
+				"tarballs": []interface{}{
+					map[string]interface{}{
+						"file":        filepath.Base(cache.Path(releaseSource)),
+						"url":         "http://fake-release-url",
+						"sha1":        "5bd3fd3bf1e4b15a1d5e8f3d6ce39a4c93bc7e91",
+						"description": "release 'fake-release'",
+					},
+					map[string]interface{}{
+						"file":        filepath.Base(cache.Path(stemcellSource)),
+						"url":         "https://fake-stemcell-url",
+						"sha1":        "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+						"description": "stemcell",
+					},
+				},
+			}))
+
+			Expect(fs.ReadFileString("/fake-bundle.tgz")).To(Equal("fake-bundle-contents"))
+			Expect(compressor.CleanUpTarballPath).To(Equal("/fake-compressed-bundle.tgz"))
+		})
+
+		It("deletes the bundle directory", func() {
+			err := bundler.Create([]Source{releaseSource}, "/fake-bundle.tgz", fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fs.FileExists("/fake-bundle-dir")).To(BeFalse())
+		})
+
+		It("leaves out local tarballs", func() {
+			localSource := newFakeSource("file:///fake-local-release", "4603db250d7b5b78dfe17869649784353177b549", "release 'fake-local-release'")
+
+			fs.RegisterRemoveAllError("/fake-bundle-dir", errors.New("fake-remove-all-error"))
+
+			err := bundler.Create([]Source{localSource}, "/fake-bundle.tgz", fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fs.ReadFileString("/fake-bundle-dir/index.json")).To(MatchJSON(`{"tarballs": []}`))
+		})
+
+		It("returns an error when a tarball cannot be downloaded", func() {
+			missingSource := newFakeSource("http://fake-missing-url", "4603db250d7b5b78dfe17869649784353177b549", "release 'fake-missing'")
+			httpClient.SetGetBehavior("", 404, nil)
+
+			err := bundler.Create([]Source{missingSource}, "/fake-bundle.tgz", fakeStage)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Failed to download from 'http://fake-missing-url'"))
+		})
+	})
+
+	Describe("Import", func() {
+		var releaseFile string
+
+		BeforeEach(func() {
+			releaseFile = filepath.Base(cache.Path(releaseSource))
+			fs.WriteFileString("/fake-bundle.tgz", "")
+
+			compressor.DecompressFileToDirCallBack = func() {
+				index := map[string]interface{}{
+					"tarballs": []map[string]string{
+						{
+							"file":        releaseFile,
+							"url":         "http://fake-release-url",
+							"sha1":        "5bd3fd3bf1e4b15a1d5e8f3d6ce39a4c93bc7e91",
+							"description": "release 'fake-release'",
+						},
+					},
+				}
+				indexContents, err := json.Marshal(index)
+				Expect(err).ToNot(HaveOccurred())
+				fs.WriteFile("/fake-bundle-work/index.json", indexContents)
+				fs.WriteFileString("/fake-bundle-work/"+releaseFile, "fake-release-contents")
+			}
+		})
+
+		It("verifies the bundled tarballs and saves them in the cache", func() {
+			digestCalculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+				"/fake-bundle-work/" + releaseFile: {Sha1: "5bd3fd3bf1e4b15a1d5e8f3d6ce39a4c93bc7e91"},
+			})
+
+			err := bundler.Import("/fake-bundle.tgz", fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(compressor.DecompressFileToDirTarballPaths).To(Equal([]string{"/fake-bundle.tgz"}))
+			Expect(compressor.DecompressFileToDirDirs).To(Equal([]string{"/fake-bundle-work"}))
+
+			cachedPath, found := cache.Get(releaseSource)
+			Expect(found).To(BeTrue())
+			Expect(fs.ReadFileString(cachedPath)).To(Equal("fake-release-contents"))
+			Expect(fs.FileExists("/fake-bundle-work")).To(BeFalse())
+
+			Expect(fakeStage.PerformCalls[0].Name).To(Equal("Extracting bundle '/fake-bundle.tgz'"))
+			Expect(fakeStage.PerformCalls[1].Name).To(Equal("Importing release 'fake-release'"))
+		})
+
+		It("skips tarballs that are already cached", func() {
+			fs.WriteFileString("/fake-release", "fake-release-contents")
+			Expect(cache.Save("/fake-release", releaseSource)).ToNot(HaveOccurred())
+
+			err := bundler.Import("/fake-bundle.tgz", fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeStage.PerformCalls[1].SkipError.Error()).To(Equal("Found in local cache: Already imported"))
+		})
+
+		It("returns an error when a bundled tarball does not match its digest", func() {
+			digestCalculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+				"/fake-bundle-work/" + releaseFile: {Sha1: "fake-other-sha1"},
+			})
+
+			err := bundler.Import("/fake-bundle.tgz", fakeStage)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("SHA1 of bundled file 'fake-other-sha1' does not match expected SHA1 '5bd3fd3bf1e4b15a1d5e8f3d6ce39a4c93bc7e91'"))
+
+			_, found := cache.Get(releaseSource)
+			Expect(found).To(BeFalse())
+		})
+
+		It("returns an error when the bundle cannot be extracted", func() {
+			compressor.DecompressFileToDirCallBack = nil
+			compressor.DecompressFileToDirErr = errors.New("fake-decompress-error")
+
+			err := bundler.Import("/fake-bundle.tgz", fakeStage)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Extracting bundle: fake-decompress-error"))
+		})
+
+		It("returns an error when the bundle does not exist", func() {
+			err := bundler.Import("/fake-missing-bundle.tgz", fakeStage)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Bundle '/fake-missing-bundle.tgz' does not exist"))
+		})
+	})
+})
@@ -2,34 +2,86 @@ package tarball
 
 import (
 	"crypto/sha1"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 	boshsys "github.com/cloudfoundry/bosh-agent/system"
+	boshtime "github.com/cloudfoundry/bosh-agent/time"
+
+	bicrypto "github.com/cloudfoundry/bosh-init/crypto"
 )
 
+const cacheIndexFileName = "index.json"
+
 type Cache interface {
 	Get(source Source) (path string, found bool)
 	Path(source Source) (path string)
 	Save(sourcePath string, source Source) error
+
+	// List returns the cached tarballs, least recently used first
+	List() ([]CacheEntry, error)
+	// Clear deletes all cached tarballs, including partial downloads
+	Clear() error
+	// Prune deletes the least recently used tarballs that are not referenced by a deployment:
+	// those last used more than olderThan ago, then as many as needed to bring the cache under maxSize.
+	// A zero olderThan or maxSize disables that limit.
+	Prune(olderThan time.Duration, maxSize int64) ([]CacheEntry, error)
+	// UpdateReferences replaces the tarballs referenced by the deployment with the given state file,
+	// then prunes the cache down to its maximum size (if any)
+	UpdateReferences(deploymentStatePath string, sources []Source) error
+}
+
+type CacheEntry struct {
+	Path       string
+	URL        string
+	SHA1       string
+	Size       int64
+	LastUsedAt time.Time
+
+	// Referenced is true while a deployment whose state file still exists was last deployed with the tarball
+	Referenced bool
+}
+
+type cacheIndex struct {
+	Entries     map[string]cacheIndexEntry `json:"entries"`
+	Deployments map[string][]string        `json:"deployments"`
+}
+
+type cacheIndexEntry struct {
+	URL        string    `json:"url"`
+	SHA1       string    `json:"sha1"`
+	LastUsedAt time.Time `json:"last_used_at"`
 }
 
 type cache struct {
-	basePath string
-	fs       boshsys.FileSystem
-	logger   boshlog.Logger
-	logTag   string
+	basePath    string
+	maxSize     int64
+	fs          boshsys.FileSystem
+	timeService boshtime.Service
+	logger      boshlog.Logger
+	logTag      string
+	indexLock   sync.Mutex
 }
 
-func NewCache(basePath string, fs boshsys.FileSystem, logger boshlog.Logger) Cache {
+// NewCache returns a Cache of the tarballs under basePath.
+// When maxSize is greater than zero, the cache is pruned down to it every time the references of a deployment are updated,
+// i.e. once the tarballs it was deployed with can no longer be evicted.
+func NewCache(basePath string, maxSize int64, fs boshsys.FileSystem, timeService boshtime.Service, logger boshlog.Logger) Cache {
 	return &cache{
-		basePath: basePath,
-		fs:       fs,
-		logger:   logger,
-		logTag:   "tarballCache",
+		basePath:    basePath,
+		maxSize:     maxSize,
+		fs:          fs,
+		timeService: timeService,
+		logger:      logger,
+		logTag:      "tarballCache",
 	}
 }
 
@@ -37,6 +89,12 @@ func (c *cache) Get(source Source) (string, bool) {
 	cachedPath := c.Path(source)
 	if c.fs.FileExists(cachedPath) {
 		c.logger.Debug(c.logTag, "Found cached tarball at: '%s'", cachedPath)
+
+		err := c.touch(cachedPath, source)
+		if err != nil {
+			c.logger.Warn(c.logTag, "Failed to record use of cached tarball '%s': %s", cachedPath, err.Error())
+		}
+
 		return cachedPath, true
 	}
 
@@ -55,11 +113,303 @@ func (c *cache) Save(sourcePath string, source Source) error {
 	}
 
 	c.logger.Debug(c.logTag, "Saving tarball in cache at: '%s'", c.Path(source))
+
+	err = c.touch(c.Path(source), source)
+	if err != nil {
+		c.logger.Warn(c.logTag, "Failed to record cached tarball '%s': %s", c.Path(source), err.Error())
+	}
+
 	return nil
 }
 
+// Path is keyed by the URL and the expected digest, including its algorithm unless it is SHA1 (as in earlier versions)
 func (c *cache) Path(source Source) string {
 	urlSHA1 := sha1.Sum([]byte(source.GetURL()))
-	filename := fmt.Sprintf("%x-%s", string(urlSHA1[:]), source.GetSHA1())
+
+	var digest string
+	if parsedDigest, err := bicrypto.ParseDigest(source.GetSHA1()); err == nil {
+		digest = parsedDigest.Value
+		if parsedDigest.Algorithm != bicrypto.DigestAlgorithmSHA1 {
+			digest = parsedDigest.Algorithm + "-" + parsedDigest.Value
+		}
+	} else {
+		// an invalid digest never matches a download, but must not make the path escape the cache
+		digestSHA1 := sha1.Sum([]byte(source.GetSHA1()))
+		digest = fmt.Sprintf("invalid-%x", string(digestSHA1[:]))
+	}
+
+	filename := fmt.Sprintf("%x-%s", string(urlSHA1[:]), digest)
 	return filepath.Join(c.basePath, filename)
 }
+
+func (c *cache) List() ([]CacheEntry, error) {
+	c.indexLock.Lock()
+	defer c.indexLock.Unlock()
+
+	index, err := c.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.list(index)
+}
+
+func (c *cache) Clear() error {
+	c.indexLock.Lock()
+	defer c.indexLock.Unlock()
+
+	err := c.fs.RemoveAll(c.basePath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Deleting cache directory '%s'", c.basePath)
+	}
+
+	c.logger.Debug(c.logTag, "Cleared cache directory '%s'", c.basePath)
+	return nil
+}
+
+func (c *cache) Prune(olderThan time.Duration, maxSize int64) ([]CacheEntry, error) {
+	c.indexLock.Lock()
+	defer c.indexLock.Unlock()
+
+	index, err := c.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := c.list(index)
+	if err != nil {
+		return nil, err
+	}
+
+	var totalSize int64
+	for _, entry := range entries {
+		totalSize += entry.Size
+	}
+
+	cutoff := c.timeService.Now().Add(-olderThan)
+	evicted := []CacheEntry{}
+	for _, entry := range entries {
+		if entry.Referenced {
+			continue
+		}
+
+		tooOld := olderThan > 0 && entry.LastUsedAt.Before(cutoff)
+		tooBig := maxSize > 0 && totalSize > maxSize
+		if !tooOld && !tooBig {
+			continue
+		}
+
+		err = c.fs.RemoveAll(entry.Path)
+		if err != nil {
+			return evicted, bosherr.WrapErrorf(err, "Deleting cached tarball '%s'", entry.Path)
+		}
+		delete(index.Entries, filepath.Base(entry.Path))
+
+		c.logger.Debug(c.logTag, "Evicted cached tarball '%s' last used at %s", entry.Path, entry.LastUsedAt)
+		totalSize -= entry.Size
+		evicted = append(evicted, entry)
+	}
+
+	if len(evicted) > 0 {
+		err = c.saveIndex(index)
+		if err != nil {
+			return evicted, err
+		}
+	}
+
+	return evicted, nil
+}
+
+func (c *cache) UpdateReferences(deploymentStatePath string, sources []Source) error {
+	err := c.updateReferences(deploymentStatePath, sources)
+	if err != nil {
+		return err
+	}
+
+	if c.maxSize > 0 {
+		_, err = c.Prune(0, c.maxSize)
+		if err != nil {
+			c.logger.Warn(c.logTag, "Failed to prune cache down to %d bytes: %s", c.maxSize, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (c *cache) updateReferences(deploymentStatePath string, sources []Source) error {
+	c.indexLock.Lock()
+	defer c.indexLock.Unlock()
+
+	index, err := c.loadIndex()
+	if err != nil {
+		return err
+	}
+
+	fileNames := []string{}
+	for _, source := range sources {
+		fileNames = append(fileNames, filepath.Base(c.Path(source)))
+	}
+
+	if len(fileNames) > 0 {
+		index.Deployments[deploymentStatePath] = fileNames
+	} else {
+		delete(index.Deployments, deploymentStatePath)
+	}
+
+	return c.saveIndex(index)
+}
+
+func (c *cache) touch(cachedPath string, source Source) error {
+	c.indexLock.Lock()
+	defer c.indexLock.Unlock()
+
+	index, err := c.loadIndex()
+	if err != nil {
+		return err
+	}
+
+	index.Entries[filepath.Base(cachedPath)] = cacheIndexEntry{
+		URL:        source.GetURL(),
+		SHA1:       source.GetSHA1(),
+		LastUsedAt: c.timeService.Now(),
+	}
+
+	return c.saveIndex(index)
+}
+
+// list includes tarballs downloaded by earlier versions, which have no index entry and so sort as least recently used
+func (c *cache) list(index cacheIndex) ([]CacheEntry, error) {
+	referencedFileNames := map[string]bool{}
+	for deploymentStatePath, fileNames := range index.Deployments {
+		if !c.fs.FileExists(deploymentStatePath) {
+			continue
+		}
+		for _, fileName := range fileNames {
+			referencedFileNames[fileName] = true
+		}
+	}
+
+	entries := []CacheEntry{}
+	if !c.fs.FileExists(c.basePath) {
+		return entries, nil
+	}
+
+	err := c.fs.Walk(c.basePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if filepath.Dir(path) != filepath.Clean(c.basePath) || info.IsDir() {
+			return nil
+		}
+
+		fileName := filepath.Base(path)
+		// skips the index and partial downloads (see provider)
+		if strings.Contains(fileName, ".") {
+			return nil
+		}
+
+		size, err := c.fileSize(path)
+		if err != nil {
+			return err
+		}
+
+		indexEntry := index.Entries[fileName]
+		entries = append(entries, CacheEntry{
+			Path:       path,
+			URL:        indexEntry.URL,
+			SHA1:       indexEntry.SHA1,
+			Size:       size,
+			LastUsedAt: indexEntry.LastUsedAt,
+			Referenced: referencedFileNames[fileName],
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Listing cache directory '%s'", c.basePath)
+	}
+
+	sort.Stable(byLastUsedAt(entries))
+
+	return entries, nil
+}
+
+func (c *cache) fileSize(path string) (int64, error) {
+	file, err := c.fs.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return 0, bosherr.WrapErrorf(err, "Opening cached tarball '%s'", path)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, bosherr.WrapErrorf(err, "Getting size of cached tarball '%s'", path)
+	}
+
+	return info.Size(), nil
+}
+
+func (c *cache) indexPath() string {
+	return filepath.Join(c.basePath, cacheIndexFileName)
+}
+
+func (c *cache) loadIndex() (cacheIndex, error) {
+	index := cacheIndex{
+		Entries:     map[string]cacheIndexEntry{},
+		Deployments: map[string][]string{},
+	}
+
+	if !c.fs.FileExists(c.indexPath()) {
+		return index, nil
+	}
+
+	contents, err := c.fs.ReadFile(c.indexPath())
+	if err != nil {
+		return index, bosherr.WrapErrorf(err, "Reading cache index '%s'", c.indexPath())
+	}
+
+	err = json.Unmarshal(contents, &index)
+	if err != nil {
+		return index, bosherr.WrapErrorf(err, "Unmarshalling cache index '%s'", c.indexPath())
+	}
+
+	if index.Entries == nil {
+		index.Entries = map[string]cacheIndexEntry{}
+	}
+	if index.Deployments == nil {
+		index.Deployments = map[string][]string{}
+	}
+
+	return index, nil
+}
+
+// saveIndex also forgets deployments whose state file has been deleted
+func (c *cache) saveIndex(index cacheIndex) error {
+	for deploymentStatePath := range index.Deployments {
+		if !c.fs.FileExists(deploymentStatePath) {
+			delete(index.Deployments, deploymentStatePath)
+		}
+	}
+
+	contents, err := json.Marshal(index)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling cache index")
+	}
+
+	err = c.fs.MkdirAll(c.basePath, os.FileMode(0766))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Failed to create cache directory '%s'", c.basePath)
+	}
+
+	err = c.fs.WriteFile(c.indexPath(), contents)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing cache index '%s'", c.indexPath())
+	}
+
+	return nil
+}
+
+type byLastUsedAt []CacheEntry
+
+func (s byLastUsedAt) Len() int           { return len(s) }
+func (s byLastUsedAt) Less(i, j int) bool { return s[i].LastUsedAt.Before(s[j].LastUsedAt) }
+func (s byLastUsedAt) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
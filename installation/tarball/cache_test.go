@@ -1,8 +1,13 @@
 package tarball_test
 
 import (
+	"path/filepath"
+	"strings"
+	"time"
+
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 	fakesys "github.com/cloudfoundry/bosh-agent/system/fakes"
+	faketime "github.com/cloudfoundry/bosh-agent/time/fakes"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -12,32 +17,68 @@ import (
 
 var _ = Describe("Cache", func() {
 	var (
-		cache Cache
-		fs    *fakesys.FakeFileSystem
+		cache           Cache
+		fs              *fakesys.FakeFileSystem
+		fakeTimeService *faketime.FakeService
+		logger          boshlog.Logger
+		now             time.Time
 	)
 
 	BeforeEach(func() {
-		logger := boshlog.NewLogger(boshlog.LevelNone)
+		logger = boshlog.NewLogger(boshlog.LevelNone)
 		fs = fakesys.NewFakeFileSystem()
+		fakeTimeService = &faketime.FakeService{}
+		now = time.Now()
 		cache = NewCache(
 			"/fake-base-path",
+			0,
 			fs,
+			fakeTimeService,
 			logger,
 		)
 	})
 
+	// saveTarball saves a tarball of the given size as downloaded from url at the given time
+	saveTarball := func(url string, size int, savedAt time.Time) *fakeSource {
+		source := &fakeSource{
+			sha1:        "4603db250d7b5b78dfe17869649784353177b549",
+			url:         url,
+			description: "some tarball",
+		}
+
+		fakeTimeService.NowTimes = []time.Time{savedAt}
+		fs.WriteFileString("source-path", strings.Repeat("x", size))
+		err := cache.Save("source-path", source)
+		Expect(err).ToNot(HaveOccurred())
+
+		// the fake file system only reports the size of files registered as open
+		cachedFile := fakesys.NewFakeFile(cache.Path(source), fs)
+		cachedFile.Contents = []byte(strings.Repeat("x", size))
+		fs.RegisterOpenFile(cache.Path(source), cachedFile)
+
+		return source
+	}
+
+	urlsOf := func(entries []CacheEntry) []string {
+		urls := []string{}
+		for _, entry := range entries {
+			urls = append(urls, entry.URL)
+		}
+		return urls
+	}
+
 	It("is a cache hit when the tarball with that url and sha1 has been downloaded", func() {
 		fs.WriteFileString("source-path", "")
 
 		err := cache.Save("source-path", &fakeSource{
-			sha1:        "fake-sha1",
+			sha1:        "4603db250d7b5b78dfe17869649784353177b549",
 			url:         "http://foo.bar.com",
 			description: "some tarball",
 		})
 		Expect(err).ToNot(HaveOccurred())
 
 		path, found := cache.Get(&fakeSource{
-			sha1:        "fake-sha1",
+			sha1:        "4603db250d7b5b78dfe17869649784353177b549",
 			url:         "http://foo.bar.com",
 			description: "some tarball",
 		})
@@ -49,14 +90,14 @@ var _ = Describe("Cache", func() {
 		fs.WriteFileString("source-path", "")
 
 		err := cache.Save("source-path", &fakeSource{
-			sha1:        "fake-sha1",
+			sha1:        "4603db250d7b5b78dfe17869649784353177b549",
 			url:         "http://foo.bar.com",
 			description: "some tarball",
 		})
 		Expect(err).ToNot(HaveOccurred())
 
 		_, found := cache.Get(&fakeSource{
-			sha1:        "fake-sha1",
+			sha1:        "4603db250d7b5b78dfe17869649784353177b549",
 			url:         "http://baz.bar.com",
 			description: "some tarball",
 		})
@@ -67,14 +108,14 @@ var _ = Describe("Cache", func() {
 		fs.WriteFileString("source-path", "")
 
 		err := cache.Save("source-path", &fakeSource{
-			sha1:        "fake-sha1",
+			sha1:        "4603db250d7b5b78dfe17869649784353177b549",
 			url:         "http://foo.bar.com",
 			description: "some tarball",
 		})
 		Expect(err).ToNot(HaveOccurred())
 
 		_, found := cache.Get(&fakeSource{
-			sha1:        "different-fake-sha1",
+			sha1:        "5bd3fd3bf1e4b15a1d5e8f3d6ce39a4c93bc7e91",
 			url:         "http://foo.bar.com",
 			description: "some tarball",
 		})
@@ -85,17 +126,232 @@ var _ = Describe("Cache", func() {
 		fs.WriteFileString("source-path", "")
 
 		err := cache.Save("source-path", &fakeSource{
-			sha1:        "fake-sha1",
+			sha1:        "4603db250d7b5b78dfe17869649784353177b549",
 			url:         "http://foo.bar.com",
 			description: "some tarball",
 		})
 		Expect(err).ToNot(HaveOccurred())
 		// echo -n "http://foo.bar.com" | openssl sha1 -> 587cd74a86333e7f1ebca70474a1f4456e4b5d3e
 		Expect(cache.Path(&fakeSource{
-			sha1:        "fake-sha1",
+			sha1:        "4603db250d7b5b78dfe17869649784353177b549",
+			url:         "http://foo.bar.com",
+			description: "some tarball",
+		})).To(Equal("/fake-base-path/587cd74a86333e7f1ebca70474a1f4456e4b5d3e-4603db250d7b5b78dfe17869649784353177b549"))
+		Expect(fs.FileExists("/fake-base-path/587cd74a86333e7f1ebca70474a1f4456e4b5d3e-4603db250d7b5b78dfe17869649784353177b549")).To(BeTrue())
+	})
+
+	It("names files with the digest algorithm when it is not sha1", func() {
+		Expect(cache.Path(&fakeSource{
+			sha1:        "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			url:         "http://foo.bar.com",
+			description: "some tarball",
+		})).To(Equal("/fake-base-path/587cd74a86333e7f1ebca70474a1f4456e4b5d3e-sha256-e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"))
+	})
+
+	It("keeps files of invalid digests under the base path", func() {
+		path := cache.Path(&fakeSource{
+			sha1:        "sha256:../../fake-file",
 			url:         "http://foo.bar.com",
 			description: "some tarball",
-		})).To(Equal("/fake-base-path/587cd74a86333e7f1ebca70474a1f4456e4b5d3e-fake-sha1"))
-		Expect(fs.FileExists("/fake-base-path/587cd74a86333e7f1ebca70474a1f4456e4b5d3e-fake-sha1")).To(BeTrue())
+		})
+		Expect(filepath.Dir(path)).To(Equal("/fake-base-path"))
+	})
+
+	It("is a cache miss when a tarball with the same digest value but a different algorithm has been downloaded", func() {
+		fs.WriteFileString("source-path", "")
+		err := cache.Save("source-path", &fakeSource{
+			sha1:        "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			url:         "http://foo.bar.com",
+			description: "some tarball",
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		_, found := cache.Get(&fakeSource{
+			sha1:        "sha512:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			url:         "http://foo.bar.com",
+			description: "some tarball",
+		})
+		Expect(found).To(BeFalse())
+	})
+
+	Describe("List", func() {
+		It("returns the cached tarballs, least recently used first", func() {
+			saveTarball("http://new.example.com", 20, now.Add(-1*time.Hour))
+			oldSource := saveTarball("http://old.example.com", 10, now.Add(-48*time.Hour))
+
+			entries, err := cache.List()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(urlsOf(entries)).To(Equal([]string{"http://old.example.com", "http://new.example.com"}))
+			Expect(entries[0].Path).To(Equal(cache.Path(oldSource)))
+			Expect(entries[0].SHA1).To(Equal("4603db250d7b5b78dfe17869649784353177b549"))
+			Expect(entries[0].Size).To(Equal(int64(10)))
+			Expect(entries[0].LastUsedAt.Equal(now.Add(-48 * time.Hour))).To(BeTrue())
+			Expect(entries[0].Referenced).To(BeFalse())
+		})
+
+		It("records the last use of a tarball found in the cache", func() {
+			source := saveTarball("http://foo.bar.com", 10, now.Add(-48*time.Hour))
+
+			fakeTimeService.NowTimes = []time.Time{now}
+			_, found := cache.Get(source)
+			Expect(found).To(BeTrue())
+
+			entries, err := cache.List()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries[0].LastUsedAt.Equal(now)).To(BeTrue())
+		})
+
+		It("includes tarballs downloaded before last uses were recorded as least recently used", func() {
+			saveTarball("http://foo.bar.com", 10, now)
+			fs.WriteFileString("/fake-base-path/587cd74a86333e7f1ebca70474a1f4456e4b5d3e-legacy-sha1", "")
+
+			entries, err := cache.List()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(HaveLen(2))
+			Expect(entries[0].Path).To(Equal("/fake-base-path/587cd74a86333e7f1ebca70474a1f4456e4b5d3e-legacy-sha1"))
+			Expect(entries[0].URL).To(BeEmpty())
+			Expect(entries[0].LastUsedAt.IsZero()).To(BeTrue())
+		})
+
+		It("skips partial downloads", func() {
+			source := saveTarball("http://foo.bar.com", 10, now)
+			fs.WriteFileString(cache.Path(source)+"-other.partial", "")
+			fs.WriteFileString(cache.Path(source)+"-other.partial.json", "{}")
+
+			entries, err := cache.List()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(urlsOf(entries)).To(Equal([]string{"http://foo.bar.com"}))
+		})
+
+		It("returns no entries when nothing has been downloaded", func() {
+			entries, err := cache.List()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+	})
+
+	Describe("Clear", func() {
+		It("deletes the cache directory", func() {
+			saveTarball("http://foo.bar.com", 10, now)
+
+			err := cache.Clear()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fs.FileExists("/fake-base-path")).To(BeFalse())
+		})
+	})
+
+	Describe("Prune", func() {
+		It("deletes tarballs last used before the given age", func() {
+			oldSource := saveTarball("http://old.example.com", 10, now.Add(-48*time.Hour))
+			newSource := saveTarball("http://new.example.com", 10, now.Add(-1*time.Hour))
+
+			fakeTimeService.NowTimes = []time.Time{now}
+			evicted, err := cache.Prune(24*time.Hour, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(urlsOf(evicted)).To(Equal([]string{"http://old.example.com"}))
+			Expect(fs.FileExists(cache.Path(oldSource))).To(BeFalse())
+			Expect(fs.FileExists(cache.Path(newSource))).To(BeTrue())
+
+			entries, err := cache.List()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(urlsOf(entries)).To(Equal([]string{"http://new.example.com"}))
+		})
+
+		It("deletes the least recently used tarballs until the cache is no bigger than the given size", func() {
+			saveTarball("http://oldest.example.com", 10, now.Add(-3*time.Hour))
+			saveTarball("http://older.example.com", 10, now.Add(-2*time.Hour))
+			saveTarball("http://newest.example.com", 10, now.Add(-1*time.Hour))
+
+			evicted, err := cache.Prune(0, 15)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(urlsOf(evicted)).To(Equal([]string{"http://oldest.example.com", "http://older.example.com"}))
+
+			entries, err := cache.List()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(urlsOf(entries)).To(Equal([]string{"http://newest.example.com"}))
+		})
+
+		It("never deletes tarballs referenced by an existing deployment state", func() {
+			referencedSource := saveTarball("http://referenced.example.com", 10, now.Add(-48*time.Hour))
+			saveTarball("http://unreferenced.example.com", 10, now.Add(-48*time.Hour))
+
+			fs.WriteFileString("/fake-deployment-state.json", "{}")
+			err := cache.UpdateReferences("/fake-deployment-state.json", []Source{referencedSource})
+			Expect(err).ToNot(HaveOccurred())
+
+			fakeTimeService.NowTimes = []time.Time{now}
+			evicted, err := cache.Prune(24*time.Hour, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(urlsOf(evicted)).To(Equal([]string{"http://unreferenced.example.com"}))
+
+			entries, err := cache.List()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Referenced).To(BeTrue())
+		})
+
+		It("deletes tarballs once the deployment state referencing them is gone", func() {
+			source := saveTarball("http://foo.bar.com", 10, now.Add(-48*time.Hour))
+
+			fs.WriteFileString("/fake-deployment-state.json", "{}")
+			err := cache.UpdateReferences("/fake-deployment-state.json", []Source{source})
+			Expect(err).ToNot(HaveOccurred())
+			fs.RemoveAll("/fake-deployment-state.json")
+
+			evicted, err := cache.Prune(0, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(urlsOf(evicted)).To(Equal([]string{"http://foo.bar.com"}))
+		})
+
+		It("deletes tarballs no longer referenced after the deployment references others", func() {
+			oldSource := saveTarball("http://old.example.com", 10, now.Add(-48*time.Hour))
+			newSource := saveTarball("http://new.example.com", 10, now.Add(-48*time.Hour))
+
+			fs.WriteFileString("/fake-deployment-state.json", "{}")
+			err := cache.UpdateReferences("/fake-deployment-state.json", []Source{oldSource})
+			Expect(err).ToNot(HaveOccurred())
+			err = cache.UpdateReferences("/fake-deployment-state.json", []Source{newSource})
+			Expect(err).ToNot(HaveOccurred())
+
+			evicted, err := cache.Prune(0, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(urlsOf(evicted)).To(Equal([]string{"http://old.example.com"}))
+		})
+	})
+
+	Context("when a maximum size is configured", func() {
+		BeforeEach(func() {
+			cache = NewCache("/fake-base-path", 15, fs, fakeTimeService, logger)
+		})
+
+		It("does not delete tarballs when saving a tarball larger than the maximum size", func() {
+			oldSource := saveTarball("http://old.example.com", 10, now.Add(-1*time.Hour))
+
+			// the size of a tarball is only known to the fake file system once registered, i.e. after it is saved
+			newSource := &fakeSource{sha1: "4603db250d7b5b78dfe17869649784353177b549", url: "http://new.example.com", description: "some tarball"}
+			newFile := fakesys.NewFakeFile(cache.Path(newSource), fs)
+			newFile.Contents = []byte(strings.Repeat("x", 20))
+			fs.RegisterOpenFile(cache.Path(newSource), newFile)
+
+			fakeTimeService.NowTimes = []time.Time{now}
+			fs.WriteFileString("source-path", "")
+			err := cache.Save("source-path", newSource)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.FileExists(cache.Path(oldSource))).To(BeTrue())
+			Expect(fs.FileExists(cache.Path(newSource))).To(BeTrue())
+		})
+
+		It("deletes the least recently used tarballs not referenced by a deployment when the references are updated", func() {
+			oldSource := saveTarball("http://old.example.com", 10, now.Add(-1*time.Hour))
+			newSource := saveTarball("http://new.example.com", 10, now.Add(-2*time.Hour))
+
+			fs.WriteFileString("/fake-deployment-state.json", "{}")
+			err := cache.UpdateReferences("/fake-deployment-state.json", []Source{newSource})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.FileExists(cache.Path(oldSource))).To(BeFalse())
+			Expect(fs.FileExists(cache.Path(newSource))).To(BeTrue())
+		})
 	})
 })
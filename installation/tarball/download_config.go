@@ -0,0 +1,220 @@
+package tarball
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshsys "github.com/cloudfoundry/bosh-agent/system"
+)
+
+// DownloadConfig holds the credentials and TLS settings used to download tarballs, keyed by host
+type DownloadConfig struct {
+	// CACert is the path to a PEM bundle of certificate authorities trusted for all hosts, in addition to the system ones
+	CACert string                `yaml:"ca_cert"`
+	Hosts  map[string]HostConfig `yaml:"hosts"`
+
+	// defaultCredentials are the credentials of the netrc default entry, used for hosts without any
+	defaultCredentials *HostConfig
+}
+
+// HostConfig holds the settings of a host, given as a host name (e.g. "artifacts.example.com") or host and port
+type HostConfig struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// Token is sent as a bearer token, instead of basic authentication
+	Token string `yaml:"token"`
+
+	// CACert is the path to a PEM bundle of certificate authorities trusted for the host
+	CACert string `yaml:"ca_cert"`
+	// ClientCert and ClientKey are the paths to the PEM client certificate and key presented to the host
+	ClientCert string `yaml:"client_cert"`
+	ClientKey  string `yaml:"client_key"`
+}
+
+func (c HostConfig) hasCredentials() bool {
+	return c.Token != "" || c.Username != ""
+}
+
+func (c HostConfig) hasTLSConfig() bool {
+	return c.CACert != "" || c.ClientCert != "" || c.ClientKey != ""
+}
+
+type configFile struct {
+	Downloads DownloadConfig `yaml:"downloads"`
+}
+
+// LoadDownloadConfig reads the downloads section of the bosh-init config file at configPath,
+// and the credentials of the netrc file at netrcPath for hosts that the config file has none for.
+// Either file may be missing.
+func LoadDownloadConfig(configPath string, netrcPath string, fs boshsys.FileSystem) (DownloadConfig, error) {
+	config := DownloadConfig{Hosts: map[string]HostConfig{}}
+
+	expandedConfigPath, err := fs.ExpandPath(configPath)
+	if err != nil {
+		return config, bosherr.WrapErrorf(err, "Expanding config file path '%s'", configPath)
+	}
+
+	if fs.FileExists(expandedConfigPath) {
+		contents, err := fs.ReadFile(expandedConfigPath)
+		if err != nil {
+			return config, bosherr.WrapErrorf(err, "Reading config file '%s'", expandedConfigPath)
+		}
+
+		var parsedConfig configFile
+		err = yaml.Unmarshal(contents, &parsedConfig)
+		if err != nil {
+			return config, bosherr.WrapErrorf(err, "Unmarshalling config file '%s'", expandedConfigPath)
+		}
+
+		config.CACert = parsedConfig.Downloads.CACert
+		for host, hostConfig := range parsedConfig.Downloads.Hosts {
+			if hostConfig.ClientCert != "" && hostConfig.ClientKey == "" || hostConfig.ClientCert == "" && hostConfig.ClientKey != "" {
+				return config, bosherr.Errorf("Config file '%s': downloads.hosts.%s must have both client_cert and client_key", expandedConfigPath, host)
+			}
+			config.Hosts[host] = hostConfig
+		}
+	}
+
+	expandedNetrcPath, err := fs.ExpandPath(netrcPath)
+	if err != nil {
+		return config, bosherr.WrapErrorf(err, "Expanding netrc file path '%s'", netrcPath)
+	}
+
+	if fs.FileExists(expandedNetrcPath) {
+		contents, err := fs.ReadFileString(expandedNetrcPath)
+		if err != nil {
+			return config, bosherr.WrapErrorf(err, "Reading netrc file '%s'", expandedNetrcPath)
+		}
+
+		machines, defaultMachine := parseNetrc(contents)
+		for host, machine := range machines {
+			hostConfig := config.Hosts[host]
+			if !hostConfig.hasCredentials() {
+				hostConfig.Username = machine.Username
+				hostConfig.Password = machine.Password
+				config.Hosts[host] = hostConfig
+			}
+		}
+		config.defaultCredentials = defaultMachine
+	}
+
+	return config, nil
+}
+
+// Authorize sets the credentials of the request's host, if any.
+// Credentials embedded in the request URL take precedence.
+func (c DownloadConfig) Authorize(request *http.Request) {
+	if request.URL.User != nil {
+		return
+	}
+
+	hostConfig, found := c.findCredentials(request.URL.Host)
+	if !found {
+		return
+	}
+
+	if hostConfig.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+hostConfig.Token)
+		return
+	}
+	request.SetBasicAuth(hostConfig.Username, hostConfig.Password)
+}
+
+// findHost returns the settings and key of a "host:port" or of its host name, preferring the former
+func (c DownloadConfig) findHost(hostPort string) (HostConfig, string, bool) {
+	if hostConfig, found := c.Hosts[hostPort]; found {
+		return hostConfig, hostPort, true
+	}
+
+	if host, _, err := net.SplitHostPort(hostPort); err == nil {
+		if hostConfig, found := c.Hosts[host]; found {
+			return hostConfig, host, true
+		}
+	}
+
+	return HostConfig{}, "", false
+}
+
+// findCredentials is findHost that falls back to the netrc default credentials
+func (c DownloadConfig) findCredentials(hostPort string) (HostConfig, bool) {
+	if hostConfig, _, found := c.findHost(hostPort); found && hostConfig.hasCredentials() {
+		return hostConfig, true
+	}
+
+	if c.defaultCredentials != nil && c.defaultCredentials.hasCredentials() {
+		return *c.defaultCredentials, true
+	}
+
+	return HostConfig{}, false
+}
+
+// parseNetrc returns the login and password of the machines of a netrc file, and of its default entry if any.
+// Macro definitions and accounts are ignored.
+func parseNetrc(contents string) (map[string]HostConfig, *HostConfig) {
+	machines := map[string]HostConfig{}
+	var defaultMachine *HostConfig
+
+	var current *HostConfig
+	var currentHost string
+	save := func() {
+		if current == nil {
+			return
+		}
+		if currentHost == "" {
+			defaultMachine = current
+		} else {
+			machines[currentHost] = *current
+		}
+	}
+
+	inMacro := false
+	for _, line := range strings.Split(contents, "\n") {
+		if inMacro {
+			// a macro definition ends with an empty line
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			value := ""
+			if i+1 < len(fields) {
+				value = fields[i+1]
+			}
+
+			switch fields[i] {
+			case "machine":
+				save()
+				current = &HostConfig{}
+				currentHost = value
+				i++
+			case "default":
+				save()
+				current = &HostConfig{}
+				currentHost = ""
+			case "login":
+				if current != nil {
+					current.Username = value
+				}
+				i++
+			case "password":
+				if current != nil {
+					current.Password = value
+				}
+				i++
+			case "account":
+				i++
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+	save()
+
+	return machines, defaultMachine
+}
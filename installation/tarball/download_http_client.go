@@ -0,0 +1,224 @@
+package tarball
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	boshsys "github.com/cloudfoundry/bosh-agent/system"
+
+	bihttpclient "github.com/cloudfoundry/bosh-init/deployment/httpclient"
+)
+
+type downloadHTTPClient struct {
+	configPath string
+	netrcPath  string
+	fs         boshsys.FileSystem
+	logger     boshlog.Logger
+	logTag     string
+
+	loadLock      sync.Mutex
+	loaded        bool
+	loadErr       error
+	config        DownloadConfig
+	clients       map[string]bihttpclient.HTTPClient
+	defaultClient bihttpclient.HTTPClient
+}
+
+// NewDownloadHTTPClient returns an HTTPClient for downloading tarballs that authorizes GET requests
+// and verifies TLS connections with the settings of their host.
+// The settings are loaded from the bosh-init config file at configPath and the netrc file at netrcPath on first use.
+func NewDownloadHTTPClient(configPath string, netrcPath string, fs boshsys.FileSystem, logger boshlog.Logger) bihttpclient.HTTPClient {
+	return &downloadHTTPClient{
+		configPath: configPath,
+		netrcPath:  netrcPath,
+		fs:         fs,
+		logger:     logger,
+		logTag:     "downloadHTTPClient",
+	}
+}
+
+func (c *downloadHTTPClient) Post(endpoint string, payload []byte) (*http.Response, error) {
+	client, err := c.clientFor(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return client.Post(endpoint, payload)
+}
+
+func (c *downloadHTTPClient) Put(endpoint string, payload []byte) (*http.Response, error) {
+	client, err := c.clientFor(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return client.Put(endpoint, payload)
+}
+
+func (c *downloadHTTPClient) Get(endpoint string) (*http.Response, error) {
+	return c.GetCustomized(endpoint, func(*http.Request) {})
+}
+
+func (c *downloadHTTPClient) GetCustomized(endpoint string, f func(*http.Request)) (*http.Response, error) {
+	client, err := c.clientFor(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.GetCustomized(endpoint, func(request *http.Request) {
+		c.config.Authorize(request)
+		f(request)
+	})
+}
+
+func (c *downloadHTTPClient) Delete(endpoint string) (*http.Response, error) {
+	client, err := c.clientFor(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return client.Delete(endpoint)
+}
+
+// clientFor returns the client with the TLS settings of the endpoint's host, creating it on first use
+func (c *downloadHTTPClient) clientFor(endpoint string) (bihttpclient.HTTPClient, error) {
+	c.loadLock.Lock()
+	defer c.loadLock.Unlock()
+
+	if !c.loaded {
+		c.loaded = true
+		c.config, c.loadErr = LoadDownloadConfig(c.configPath, c.netrcPath, c.fs)
+		c.clients = map[string]bihttpclient.HTTPClient{}
+	}
+	if c.loadErr != nil {
+		return nil, bosherr.WrapError(c.loadErr, "Loading download settings")
+	}
+
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Parsing URL '%s'", endpoint)
+	}
+
+	hostConfig, host, found := c.config.findHost(endpointURL.Host)
+	if !found || !hostConfig.hasTLSConfig() {
+		if c.defaultClient == nil {
+			c.defaultClient, err = c.newClient(HostConfig{})
+			if err != nil {
+				return nil, err
+			}
+		}
+		return c.defaultClient, nil
+	}
+
+	client, found := c.clients[host]
+	if !found {
+		c.logger.Debug(c.logTag, "Using the TLS settings of host '%s'", host)
+		client, err = c.newClient(hostConfig)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Configuring TLS for host '%s'", host)
+		}
+		c.clients[host] = client
+	}
+
+	return client, nil
+}
+
+func (c *downloadHTTPClient) newClient(hostConfig HostConfig) (bihttpclient.HTTPClient, error) {
+	if c.config.CACert == "" && !hostConfig.hasTLSConfig() {
+		return bihttpclient.NewHTTPClient(HTTPClient, c.logger), nil
+	}
+
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil {
+		c.logger.Warn(c.logTag, "Failed to load the system certificate authorities: %s", err.Error())
+		rootCAs = x509.NewCertPool()
+	}
+
+	for _, caCertPath := range []string{c.config.CACert, hostConfig.CACert} {
+		if caCertPath == "" {
+			continue
+		}
+		err = c.appendCACerts(rootCAs, caCertPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tlsConfig := &tls.Config{RootCAs: rootCAs}
+
+	if hostConfig.ClientCert != "" {
+		certificate, err := c.loadClientCert(hostConfig.ClientCert, hostConfig.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return bihttpclient.NewHTTPClient(newHTTPClient(tlsConfig), c.logger), nil
+}
+
+func (c *downloadHTTPClient) appendCACerts(pool *x509.CertPool, caCertPath string) error {
+	expandedPath, err := c.fs.ExpandPath(caCertPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Expanding CA certificate path '%s'", caCertPath)
+	}
+
+	caCerts, err := c.fs.ReadFile(expandedPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading CA certificate '%s'", expandedPath)
+	}
+
+	if !pool.AppendCertsFromPEM(caCerts) {
+		return bosherr.Errorf("CA certificate '%s' does not contain any PEM certificate", expandedPath)
+	}
+
+	return nil
+}
+
+func (c *downloadHTTPClient) loadClientCert(certPath string, keyPath string) (tls.Certificate, error) {
+	expandedCertPath, err := c.fs.ExpandPath(certPath)
+	if err != nil {
+		return tls.Certificate{}, bosherr.WrapErrorf(err, "Expanding client certificate path '%s'", certPath)
+	}
+
+	expandedKeyPath, err := c.fs.ExpandPath(keyPath)
+	if err != nil {
+		return tls.Certificate{}, bosherr.WrapErrorf(err, "Expanding client key path '%s'", keyPath)
+	}
+
+	certPEM, err := c.fs.ReadFile(expandedCertPath)
+	if err != nil {
+		return tls.Certificate{}, bosherr.WrapErrorf(err, "Reading client certificate '%s'", expandedCertPath)
+	}
+
+	keyPEM, err := c.fs.ReadFile(expandedKeyPath)
+	if err != nil {
+		return tls.Certificate{}, bosherr.WrapErrorf(err, "Reading client key '%s'", expandedKeyPath)
+	}
+
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, bosherr.WrapErrorf(err, "Loading client certificate '%s' and key '%s'", expandedCertPath, expandedKeyPath)
+	}
+
+	return certificate, nil
+}
+
+// newHTTPClient returns a client with the timeouts of HTTPClient and the given TLS settings (nil for the defaults)
+func newHTTPClient(tlsConfig *tls.Config) http.Client {
+	return http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+			Proxy:           http.ProxyFromEnvironment,
+			Dial: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 0 * time.Second,
+			}).Dial,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
@@ -0,0 +1,106 @@
+package tarball_test
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	boshsys "github.com/cloudfoundry/bosh-agent/system"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	bihttpclient "github.com/cloudfoundry/bosh-init/deployment/httpclient"
+	. "github.com/cloudfoundry/bosh-init/installation/tarball"
+)
+
+var _ = Describe("DownloadHTTPClient", func() {
+	var (
+		server        *httptest.Server
+		authorization string
+		tempDir       string
+		configPath    string
+		fs            boshsys.FileSystem
+		httpClient    bihttpclient.HTTPClient
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization = r.Header.Get("Authorization")
+			fmt.Fprint(w, "fake-body")
+		}))
+
+		var err error
+		tempDir, err = ioutil.TempDir("", "bosh-init-download-http-client")
+		Expect(err).ToNot(HaveOccurred())
+
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		fs = boshsys.NewOsFileSystem(logger)
+		configPath = filepath.Join(tempDir, "config.yml")
+		httpClient = NewDownloadHTTPClient(configPath, filepath.Join(tempDir, "netrc"), fs, logger)
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(tempDir)
+	})
+
+	Context("when the CA certificate of the host is configured", func() {
+		BeforeEach(func() {
+			caCertPath := filepath.Join(tempDir, "ca.pem")
+			caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+			err := fs.WriteFile(caCertPath, caCert)
+			Expect(err).ToNot(HaveOccurred())
+
+			serverURL, err := url.Parse(server.URL)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = fs.WriteFileString(configPath, fmt.Sprintf(`---
+downloads:
+  hosts:
+    %s:
+      token: fake-token
+      ca_cert: %s
+`, serverURL.Host, caCertPath))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("verifies the server with it and authorizes the request", func() {
+			response, err := httpClient.GetCustomized(server.URL+"/fake-release.tgz", func(*http.Request) {})
+			Expect(err).ToNot(HaveOccurred())
+			defer response.Body.Close()
+
+			body, err := ioutil.ReadAll(response.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal("fake-body"))
+			Expect(authorization).To(Equal("Bearer fake-token"))
+		})
+	})
+
+	Context("when the CA certificate of the host is not configured", func() {
+		It("fails to verify the server", func() {
+			_, err := httpClient.GetCustomized(server.URL+"/fake-release.tgz", func(*http.Request) {})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("certificate"))
+		})
+	})
+
+	Context("when the config file is invalid", func() {
+		BeforeEach(func() {
+			err := fs.WriteFileString(configPath, "downloads: [")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns an error", func() {
+			_, err := httpClient.GetCustomized(server.URL+"/fake-release.tgz", func(*http.Request) {})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Loading download settings"))
+		})
+	})
+})
@@ -0,0 +1,36 @@
+package fakes
+
+import (
+	bitarball "github.com/cloudfoundry/bosh-init/installation/tarball"
+	biui "github.com/cloudfoundry/bosh-init/ui"
+)
+
+type FakeBundler struct {
+	CreateInputs []CreateInput
+	CreateErr    error
+
+	ImportBundlePaths []string
+	ImportErr         error
+}
+
+type CreateInput struct {
+	Sources    []bitarball.Source
+	BundlePath string
+}
+
+func NewFakeBundler() *FakeBundler {
+	return &FakeBundler{}
+}
+
+func (b *FakeBundler) Create(sources []bitarball.Source, bundlePath string, stage biui.Stage) error {
+	b.CreateInputs = append(b.CreateInputs, CreateInput{
+		Sources:    sources,
+		BundlePath: bundlePath,
+	})
+	return b.CreateErr
+}
+
+func (b *FakeBundler) Import(bundlePath string, stage biui.Stage) error {
+	b.ImportBundlePaths = append(b.ImportBundlePaths, bundlePath)
+	return b.ImportErr
+}
@@ -1,10 +1,13 @@
 package tarball
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,28 +27,27 @@ type Source interface {
 	Description() string
 }
 
+// MirroredSource is a Source whose tarball can also be downloaded from mirrors, tried in order when its URL fails.
+// The tarball is cached under its URL regardless of where it was downloaded from.
+type MirroredSource interface {
+	Source
+	GetMirrors() []string
+}
+
 type Provider interface {
 	Get(Source, biui.Stage) (path string, err error)
 }
 
-var HTTPClient = http.Client{
-	Transport: &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		Dial: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 0 * time.Second,
-		}).Dial,
-		TLSHandshakeTimeout: 10 * time.Second,
-	},
-}
+var HTTPClient = newHTTPClient(nil)
 
 type provider struct {
 	cache            Cache
 	fs               boshsys.FileSystem
 	httpClient       bihttpclient.HTTPClient
-	sha1Calculator   bicrypto.SHA1Calculator
+	digestCalculator bicrypto.DigestCalculator
 	downloadAttempts int
 	delayTimeout     time.Duration
+	offline          bool
 	logger           boshlog.Logger
 	logTag           string
 }
@@ -54,7 +56,7 @@ func NewProvider(
 	cache Cache,
 	fs boshsys.FileSystem,
 	httpClient bihttpclient.HTTPClient,
-	sha1Calculator bicrypto.SHA1Calculator,
+	digestCalculator bicrypto.DigestCalculator,
 	downloadAttempts int,
 	delayTimeout time.Duration,
 	logger boshlog.Logger,
@@ -63,7 +65,7 @@ func NewProvider(
 		cache:            cache,
 		fs:               fs,
 		httpClient:       httpClient,
-		sha1Calculator:   sha1Calculator,
+		digestCalculator: digestCalculator,
 		downloadAttempts: downloadAttempts,
 		delayTimeout:     delayTimeout,
 		logger:           logger,
@@ -71,6 +73,18 @@ func NewProvider(
 	}
 }
 
+// NewOfflineProvider returns a Provider that never downloads: tarballs must be local (file://) or already in the cache,
+// e.g. imported from a bundle.
+func NewOfflineProvider(cache Cache, fs boshsys.FileSystem, logger boshlog.Logger) Provider {
+	return &provider{
+		cache:   cache,
+		fs:      fs,
+		offline: true,
+		logger:  logger,
+		logTag:  "tarballProvider",
+	}
+}
+
 func (p *provider) Get(source Source, stage biui.Stage) (string, error) {
 	if strings.HasPrefix(source.GetURL(), "file://") {
 		filePath := strings.TrimPrefix(source.GetURL(), "file://")
@@ -85,12 +99,31 @@ func (p *provider) Get(source Source, stage biui.Stage) (string, error) {
 		return expandedPath, nil
 	}
 
+	// a release source directory, built into a dev release when the release is extracted
+	if strings.HasPrefix(source.GetURL(), "dir://") {
+		dirPath := strings.TrimPrefix(source.GetURL(), "dir://")
+
+		expandedPath, err := p.fs.ExpandPath(dirPath)
+		if err != nil {
+			p.logger.Warn(p.logTag, "Failed to expand directory path %s, using original URL", dirPath)
+			return dirPath, nil
+		}
+
+		p.logger.Debug(p.logTag, "Using the release directory from dir source: '%s'", dirPath)
+		return expandedPath, nil
+	}
+
 	if !strings.HasPrefix(source.GetURL(), "http") {
-		return "", bosherr.Errorf("Invalid source URL: '%s', must be either file:// or http(s)://", source.GetURL())
+		return "", bosherr.Errorf("Invalid source URL: '%s', must be either file://, dir:// or http(s)://", source.GetURL())
+	}
+
+	expectedDigest, err := bicrypto.ParseDigest(source.GetSHA1())
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Parsing expected digest of %s", source.Description())
 	}
 
 	var cachedPath string
-	err := stage.Perform(fmt.Sprintf("Downloading %s", source.Description()), func() error {
+	err = stage.Perform(fmt.Sprintf("Downloading %s", source.Description()), func() error {
 		var found bool
 		cachedPath, found = p.cache.Get(source)
 		if found {
@@ -98,14 +131,27 @@ func (p *provider) Get(source Source, stage biui.Stage) (string, error) {
 			return biui.NewSkipStageError(bosherr.Error("Already downloaded"), "Found in local cache")
 		}
 
-		retryStrategy := boshretry.NewAttemptRetryStrategy(p.downloadAttempts, p.delayTimeout, p.downloadRetryable(source), p.logger)
-		err := retryStrategy.Try()
-		if err != nil {
-			return bosherr.WrapErrorf(err, "Failed to download from '%s'", source.GetURL())
+		if p.offline {
+			return bosherr.Errorf("Refusing to download '%s' in offline mode, it is not in the local cache (see 'bosh-init bundle')", source.GetURL())
 		}
 
-		p.logger.Debug(p.logTag, "Using the downloaded tarball: '%s'", cachedPath)
-		return nil
+		errs := []error{}
+		for _, url := range p.downloadURLs(source) {
+			retryStrategy := boshretry.NewAttemptRetryStrategy(p.downloadAttempts, p.delayTimeout, p.downloadRetryable(source, url, expectedDigest, stage), p.logger)
+			err := retryStrategy.Try()
+			if err == nil {
+				p.logger.Debug(p.logTag, "Using the tarball downloaded from '%s'", url)
+				return nil
+			}
+
+			p.logger.Warn(p.logTag, "Failed to download %s from '%s': %s", source.Description(), url, err.Error())
+			errs = append(errs, bosherr.WrapErrorf(err, "Failed to download from '%s'", url))
+		}
+
+		if len(errs) == 1 {
+			return errs[0]
+		}
+		return bosherr.NewMultiError(errs...)
 	})
 
 	if err != nil {
@@ -115,39 +161,210 @@ func (p *provider) Get(source Source, stage biui.Stage) (string, error) {
 	return p.cache.Path(source), nil
 }
 
-func (p *provider) downloadRetryable(source Source) boshretry.Retryable {
+// downloadURLs returns the URL of the source followed by its mirrors, if any
+func (p *provider) downloadURLs(source Source) []string {
+	urls := []string{source.GetURL()}
+	if mirroredSource, ok := source.(MirroredSource); ok {
+		urls = append(urls, mirroredSource.GetMirrors()...)
+	}
+	return urls
+}
+
+// partialDownload records the validators of a partially downloaded tarball, to resume it only if it has not changed on the server
+// it was downloaded from
+type partialDownload struct {
+	URL          string `json:"url,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// downloadRetryable downloads the tarball from url to a partial file in the cache directory.
+// When the server advertises byte ranges, a failed download is kept and the next attempt (or run) resumes it.
+func (p *provider) downloadRetryable(source Source, url string, expectedDigest bicrypto.Digest, stage biui.Stage) boshretry.Retryable {
 	return boshretry.NewRetryable(func() (bool, error) {
-		downloadedFile, err := p.fs.TempFile("tarballProvider")
+		partialPath := p.cache.Path(source) + ".partial"
+		partialInfoPath := partialPath + ".json"
+
+		err := p.fs.MkdirAll(filepath.Dir(partialPath), os.FileMode(0766))
 		if err != nil {
-			return true, bosherr.WrapError(err, "Unable to create temporary file")
+			return true, bosherr.WrapErrorf(err, "Creating cache directory '%s'", filepath.Dir(partialPath))
 		}
-		defer p.fs.RemoveAll(downloadedFile.Name())
 
-		response, err := p.httpClient.Get(source.GetURL())
+		partial, offset := p.findPartialDownload(url, partialPath, partialInfoPath)
+
+		response, err := p.httpClient.GetCustomized(url, func(request *http.Request) {
+			if offset == 0 {
+				return
+			}
+			request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			if partial.ETag != "" {
+				request.Header.Set("If-Range", partial.ETag)
+			} else {
+				request.Header.Set("If-Range", partial.LastModified)
+			}
+		})
 		if err != nil {
 			return true, bosherr.WrapError(err, "Unable to download")
 		}
 		defer response.Body.Close()
 
-		_, err = io.Copy(downloadedFile, response.Body)
+		var flag int
+		switch response.StatusCode {
+		case http.StatusPartialContent:
+			if offset == 0 || p.changedSince(partial, response) || p.contentRangeStart(response) != offset {
+				p.discardPartialDownload(partialPath, partialInfoPath)
+				return true, bosherr.Errorf("Tarball at '%s' changed while resuming download", url)
+			}
+			p.logger.Debug(p.logTag, "Resuming download of '%s' from byte %d", url, offset)
+			flag = os.O_WRONLY | os.O_APPEND
+		case http.StatusOK:
+			// either the first attempt, or the server did not resume (e.g. the tarball changed)
+			flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+			err = p.savePartialDownloadInfo(url, partialInfoPath, response)
+			if err != nil {
+				return true, err
+			}
+		case http.StatusRequestedRangeNotSatisfiable:
+			p.discardPartialDownload(partialPath, partialInfoPath)
+			return true, bosherr.Errorf("Unable to resume download from byte %d", offset)
+		default:
+			return true, bosherr.Errorf("Unable to download, response status %d", response.StatusCode)
+		}
+
+		downloadedFile, err := p.fs.OpenFile(partialPath, flag, os.FileMode(0644))
 		if err != nil {
-			return true, bosherr.WrapError(err, "Saving downloaded bits to temporary file")
+			return true, bosherr.WrapErrorf(err, "Opening partial download file '%s'", partialPath)
+		}
+
+		progress := stage.Progress(source.Description(), response.ContentLength)
+		_, err = io.Copy(io.MultiWriter(downloadedFile, progress), response.Body)
+		progress.Finish()
+		closeErr := downloadedFile.Close()
+		if err != nil {
+			return true, bosherr.WrapError(err, "Saving downloaded bits to partial download file")
+		}
+		if closeErr != nil {
+			return true, bosherr.WrapError(closeErr, "Closing partial download file")
 		}
 
-		downloadedSha1, err := p.sha1Calculator.Calculate(downloadedFile.Name())
+		downloadedDigest, err := p.digestCalculator.Calculate(partialPath, expectedDigest.Algorithm)
 		if err != nil {
-			return true, bosherr.WrapError(err, "Calculating sha1 for downloaded file")
+			return true, bosherr.WrapErrorf(err, "Calculating %s for downloaded file", expectedDigest.Algorithm)
 		}
 
-		if downloadedSha1 != source.GetSHA1() {
-			return true, bosherr.Errorf("SHA1 of downloaded file '%s' does not match expected SHA1 '%s'", downloadedSha1, source.GetSHA1())
+		if downloadedDigest != expectedDigest.Value {
+			p.discardPartialDownload(partialPath, partialInfoPath)
+			algorithm := strings.ToUpper(expectedDigest.Algorithm)
+			return true, bosherr.Errorf("%s of downloaded file '%s' does not match expected %s '%s'", algorithm, downloadedDigest, algorithm, expectedDigest.Value)
 		}
 
-		err = p.cache.Save(downloadedFile.Name(), source)
+		err = p.cache.Save(partialPath, source)
 		if err != nil {
+			p.discardPartialDownload(partialPath, partialInfoPath)
 			return true, bosherr.WrapError(err, "Saving downloaded file in cache")
 		}
 
+		_ = p.fs.RemoveAll(partialInfoPath)
+
 		return false, nil
 	})
 }
+
+// findPartialDownload returns the validators and size of a partial download that can be resumed from url,
+// or a zero size when there is none
+func (p *provider) findPartialDownload(url, partialPath, partialInfoPath string) (partialDownload, int64) {
+	var partial partialDownload
+
+	if !p.fs.FileExists(partialPath) || !p.fs.FileExists(partialInfoPath) {
+		return partial, 0
+	}
+
+	partialInfoBytes, err := p.fs.ReadFile(partialInfoPath)
+	if err != nil {
+		p.logger.Warn(p.logTag, "Failed to read partial download info '%s': %s", partialInfoPath, err.Error())
+		return partial, 0
+	}
+
+	err = json.Unmarshal(partialInfoBytes, &partial)
+	if err != nil || (partial.ETag == "" && partial.LastModified == "") {
+		return partial, 0
+	}
+
+	if partial.URL != "" && partial.URL != url {
+		p.logger.Debug(p.logTag, "Not resuming download of '%s' started from '%s'", url, partial.URL)
+		return partial, 0
+	}
+
+	file, err := p.fs.OpenFile(partialPath, os.O_RDONLY, 0)
+	if err != nil {
+		return partial, 0
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return partial, 0
+	}
+
+	return partial, fileInfo.Size()
+}
+
+// savePartialDownloadInfo records the validators of a download that can be resumed, or removes stale ones
+func (p *provider) savePartialDownloadInfo(url, partialInfoPath string, response *http.Response) error {
+	partial := partialDownload{
+		URL:          url,
+		ETag:         response.Header.Get("ETag"),
+		LastModified: response.Header.Get("Last-Modified"),
+	}
+
+	if response.Header.Get("Accept-Ranges") != "bytes" || (partial.ETag == "" && partial.LastModified == "") {
+		_ = p.fs.RemoveAll(partialInfoPath)
+		return nil
+	}
+
+	partialInfoBytes, err := json.Marshal(partial)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling partial download info")
+	}
+
+	err = p.fs.WriteFile(partialInfoPath, partialInfoBytes)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing partial download info '%s'", partialInfoPath)
+	}
+
+	return nil
+}
+
+func (p *provider) changedSince(partial partialDownload, response *http.Response) bool {
+	etag := response.Header.Get("ETag")
+	if partial.ETag != "" && etag != "" && etag != partial.ETag {
+		return true
+	}
+
+	lastModified := response.Header.Get("Last-Modified")
+	if partial.LastModified != "" && lastModified != "" && lastModified != partial.LastModified {
+		return true
+	}
+
+	return false
+}
+
+// contentRangeStart returns the first byte position of a 'Content-Range: bytes <first>-<last>/<length>' header, or -1
+func (p *provider) contentRangeStart(response *http.Response) int64 {
+	contentRange := response.Header.Get("Content-Range")
+	if !strings.HasPrefix(contentRange, "bytes ") {
+		return -1
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(contentRange, "bytes "), "-", 2)
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return start
+}
+
+func (p *provider) discardPartialDownload(partialPath, partialInfoPath string) {
+	_ = p.fs.RemoveAll(partialPath)
+	_ = p.fs.RemoveAll(partialInfoPath)
+}
@@ -2,11 +2,14 @@ package tarball_test
 
 import (
 	"errors"
+	"io"
 	"io/ioutil"
-	"os"
+	"net/http"
+	"strings"
 
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 	fakesys "github.com/cloudfoundry/bosh-agent/system/fakes"
+	faketime "github.com/cloudfoundry/bosh-agent/time/fakes"
 	fakebicrypto "github.com/cloudfoundry/bosh-init/crypto/fakes"
 	fakebihttpclient "github.com/cloudfoundry/bosh-init/deployment/httpclient/fakes"
 	fakebiui "github.com/cloudfoundry/bosh-init/ui/fakes"
@@ -19,29 +22,29 @@ import (
 
 var _ = Describe("Provider", func() {
 	var (
-		provider       Provider
-		cache          Cache
-		fs             *fakesys.FakeFileSystem
-		httpClient     *fakebihttpclient.FakeHTTPClient
-		sha1Calculator *fakebicrypto.FakeSha1Calculator
-		source         *fakeSource
-		fakeStage      *fakebiui.FakeStage
+		provider         Provider
+		cache            Cache
+		fs               *fakesys.FakeFileSystem
+		httpClient       *fakebihttpclient.FakeHTTPClient
+		digestCalculator *fakebicrypto.FakeDigestCalculator
+		source           *fakeSource
+		fakeStage        *fakebiui.FakeStage
 	)
 
 	BeforeEach(func() {
 		fs = fakesys.NewFakeFileSystem()
 		logger := boshlog.NewLogger(boshlog.LevelNone)
-		cache = NewCache("/fake-base-path", fs, logger)
-		sha1Calculator = fakebicrypto.NewFakeSha1Calculator()
+		cache = NewCache("/fake-base-path", 0, fs, &faketime.FakeService{}, logger)
+		digestCalculator = fakebicrypto.NewFakeDigestCalculator()
 		httpClient = fakebihttpclient.NewFakeHTTPClient()
-		provider = NewProvider(cache, fs, httpClient, sha1Calculator, 3, 0, logger)
+		provider = NewProvider(cache, fs, httpClient, digestCalculator, 3, 0, logger)
 		fakeStage = fakebiui.NewFakeStage()
 	})
 
 	Describe("Get", func() {
 		Context("when URL starts with file://", func() {
 			BeforeEach(func() {
-				source = newFakeSource("file://fake-file", "fake-sha1", "fake-description")
+				source = newFakeSource("file://fake-file", "4603db250d7b5b78dfe17869649784353177b549", "fake-description")
 				fs.WriteFileString("expanded-file-path", "")
 				fs.ExpandPathExpanded = "expanded-file-path"
 			})
@@ -53,9 +56,22 @@ var _ = Describe("Provider", func() {
 			})
 		})
 
+		Context("when URL starts with dir://", func() {
+			BeforeEach(func() {
+				source = newFakeSource("dir://fake-release-dir", "", "fake-description")
+				fs.ExpandPathExpanded = "expanded-dir-path"
+			})
+
+			It("returns expanded path to the directory", func() {
+				path, err := provider.Get(source, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(path).To(Equal("expanded-dir-path"))
+			})
+		})
+
 		Context("when URL starts with http(s)://", func() {
 			BeforeEach(func() {
-				source = newFakeSource("http://fake-url", "fake-sha1", "fake-description")
+				source = newFakeSource("http://fake-url", "4603db250d7b5b78dfe17869649784353177b549", "fake-description")
 			})
 
 			Context("when tarball is present in cache", func() {
@@ -67,7 +83,7 @@ var _ = Describe("Provider", func() {
 				It("returns cached tarball path", func() {
 					path, err := provider.Get(source, fakeStage)
 					Expect(err).ToNot(HaveOccurred())
-					Expect(path).To(Equal("/fake-base-path/9db1fb7c47637e8709e944a232e1aa98ce6fec26-fake-sha1"))
+					Expect(path).To(Equal("/fake-base-path/9db1fb7c47637e8709e944a232e1aa98ce6fec26-4603db250d7b5b78dfe17869649784353177b549"))
 				})
 
 				It("skips downloading stage", func() {
@@ -81,23 +97,18 @@ var _ = Describe("Provider", func() {
 
 			Context("when tarball is not present in cache", func() {
 				var (
-					tempDownloadFilePath string
+					partialPath     string
+					partialInfoPath string
 				)
 
 				BeforeEach(func() {
-					tempDownloadFile, err := ioutil.TempFile("", "temp-download-file")
-					Expect(err).ToNot(HaveOccurred())
-					fs.ReturnTempFile = tempDownloadFile
-					tempDownloadFilePath = tempDownloadFile.Name()
-					sha1Calculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
-						tempDownloadFilePath: {Sha1: "fake-sha1"},
+					partialPath = "/fake-base-path/9db1fb7c47637e8709e944a232e1aa98ce6fec26-4603db250d7b5b78dfe17869649784353177b549.partial"
+					partialInfoPath = partialPath + ".json"
+					digestCalculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+						partialPath: {Sha1: "4603db250d7b5b78dfe17869649784353177b549"},
 					})
 				})
 
-				AfterEach(func() {
-					os.RemoveAll(tempDownloadFilePath)
-				})
-
 				Context("when downloading succeds", func() {
 					BeforeEach(func() {
 						httpClient.SetGetBehavior("fake-body", 200, nil)
@@ -108,10 +119,14 @@ var _ = Describe("Provider", func() {
 					It("downloads tarball from given URL and returns saved cache tarball path", func() {
 						path, err := provider.Get(source, fakeStage)
 						Expect(err).ToNot(HaveOccurred())
-						Expect(path).To(Equal("/fake-base-path/9db1fb7c47637e8709e944a232e1aa98ce6fec26-fake-sha1"))
+						Expect(path).To(Equal("/fake-base-path/9db1fb7c47637e8709e944a232e1aa98ce6fec26-4603db250d7b5b78dfe17869649784353177b549"))
 
 						Expect(httpClient.GetInputs).To(HaveLen(1))
 						Expect(httpClient.GetInputs[0].Endpoint).To(Equal("http://fake-url"))
+						Expect(httpClient.GetInputs[0].Headers.Get("Range")).To(BeEmpty())
+
+						Expect(fs.ReadFileString(path)).To(Equal("fake-body"))
+						Expect(fs.FileExists(partialPath)).To(BeFalse())
 					})
 
 					It("logs downloading stage", func() {
@@ -123,17 +138,29 @@ var _ = Describe("Provider", func() {
 						}))
 					})
 
+					It("reports download progress using the response content length", func() {
+						_, err := provider.Get(source, fakeStage)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(fakeStage.ProgressCalls).To(HaveLen(1))
+						progress := fakeStage.ProgressCalls[0]
+						Expect(progress.Description).To(Equal("fake-description"))
+						Expect(progress.Total).To(Equal(int64(len("fake-body"))))
+						Expect(string(progress.Written)).To(Equal("fake-body"))
+						Expect(progress.Finished).To(BeTrue())
+					})
+
 					Context("when sha1 does not match", func() {
 						BeforeEach(func() {
-							sha1Calculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
-								tempDownloadFilePath: {Sha1: "fake-sha2"},
+							digestCalculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+								partialPath: {Sha1: "fake-sha2"},
 							})
 						})
 
 						It("returns an error", func() {
 							_, err := provider.Get(source, fakeStage)
 							Expect(err).To(HaveOccurred())
-							Expect(err.Error()).To(ContainSubstring("'fake-sha2' does not match expected SHA1 'fake-sha1'"))
+							Expect(err.Error()).To(ContainSubstring("'fake-sha2' does not match expected SHA1 '4603db250d7b5b78dfe17869649784353177b549'"))
 						})
 
 						It("retries downloading up to 3 times", func() {
@@ -146,30 +173,72 @@ var _ = Describe("Provider", func() {
 						It("removes the downloaded file", func() {
 							_, err := provider.Get(source, fakeStage)
 							Expect(err).To(HaveOccurred())
-							Expect(fs.FileExists(tempDownloadFilePath)).To(BeFalse())
+							Expect(fs.FileExists(partialPath)).To(BeFalse())
 						})
 					})
 
 					Context("when saving to cache fails", func() {
 						BeforeEach(func() {
-							// Creating cache base directory fails
-							fs.MkdirAllError = errors.New("fake-mkdir-error")
+							fs.RenameError = errors.New("fake-rename-error")
 						})
 
 						It("returns an error", func() {
 							_, err := provider.Get(source, fakeStage)
 							Expect(err).To(HaveOccurred())
-							Expect(err.Error()).To(ContainSubstring("fake-mkdir-error"))
+							Expect(err.Error()).To(ContainSubstring("fake-rename-error"))
 						})
 
 						It("removes the downloaded file", func() {
 							_, err := provider.Get(source, fakeStage)
 							Expect(err).To(HaveOccurred())
-							Expect(fs.FileExists(tempDownloadFilePath)).To(BeFalse())
+							Expect(fs.FileExists(partialPath)).To(BeFalse())
+						})
+					})
+
+					Context("when creating the cache directory fails", func() {
+						BeforeEach(func() {
+							fs.MkdirAllError = errors.New("fake-mkdir-error")
+						})
+
+						It("returns an error", func() {
+							_, err := provider.Get(source, fakeStage)
+							Expect(err).To(HaveOccurred())
+							Expect(err.Error()).To(ContainSubstring("fake-mkdir-error"))
 						})
 					})
 				})
 
+				Context("when the expected digest uses another algorithm", func() {
+					BeforeEach(func() {
+						source = newFakeSource("http://fake-url", "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", "fake-description")
+						partialPath = "/fake-base-path/9db1fb7c47637e8709e944a232e1aa98ce6fec26-sha256-e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855.partial"
+						digestCalculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+							partialPath: {Sha1: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+						})
+						httpClient.SetGetBehavior("fake-body", 200, nil)
+					})
+
+					It("verifies the download with that algorithm", func() {
+						path, err := provider.Get(source, fakeStage)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(path).To(Equal("/fake-base-path/9db1fb7c47637e8709e944a232e1aa98ce6fec26-sha256-e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"))
+						Expect(digestCalculator.CalculateAlgorithms).To(Equal([]string{"sha256"}))
+					})
+				})
+
+				Context("when the expected digest algorithm is not supported", func() {
+					BeforeEach(func() {
+						source = newFakeSource("http://fake-url", "md5:fake-md5", "fake-description")
+					})
+
+					It("returns an error without downloading", func() {
+						_, err := provider.Get(source, fakeStage)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("Unsupported digest algorithm 'md5'"))
+						Expect(httpClient.GetInputs).To(BeEmpty())
+					})
+				})
+
 				Context("when downloading fails", func() {
 					BeforeEach(func() {
 						httpClient.SetGetBehavior("", 500, errors.New("fake-download-error-1"))
@@ -184,19 +253,178 @@ var _ = Describe("Provider", func() {
 
 						Expect(httpClient.GetInputs).To(HaveLen(3))
 					})
+				})
+
+				Context("when the server responds with an error status", func() {
+					BeforeEach(func() {
+						httpClient.SetGetBehavior("", 404, nil)
+						httpClient.SetGetBehavior("", 404, nil)
+						httpClient.SetGetBehavior("", 404, nil)
+					})
 
-					It("removes the downloaded file", func() {
+					It("returns an error", func() {
 						_, err := provider.Get(source, fakeStage)
 						Expect(err).To(HaveOccurred())
-						Expect(fs.FileExists(tempDownloadFilePath)).To(BeFalse())
+						Expect(err.Error()).To(ContainSubstring("response status 404"))
+						Expect(fs.FileExists(partialPath)).To(BeFalse())
 					})
 				})
+
+				Context("when a download that can be resumed fails part way", func() {
+					BeforeEach(func() {
+						partialFile := fakesys.NewFakeFile(partialPath, fs)
+						fs.RegisterOpenFile(partialPath, partialFile)
+
+						httpClient.SetGetResponse(newResponse(200, "fake-", errors.New("fake-read-error"), map[string]string{
+							"Accept-Ranges": "bytes",
+							"ETag":          `"fake-etag"`,
+						}), nil)
+						httpClient.SetGetResponse(newResponse(206, "body", nil, map[string]string{
+							"Content-Range": "bytes 5-8/9",
+							"ETag":          `"fake-etag"`,
+						}), nil)
+					})
+
+					It("resumes the download from the end of the partial file", func() {
+						path, err := provider.Get(source, fakeStage)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(path).To(Equal("/fake-base-path/9db1fb7c47637e8709e944a232e1aa98ce6fec26-4603db250d7b5b78dfe17869649784353177b549"))
+
+						Expect(httpClient.GetInputs).To(HaveLen(2))
+						Expect(httpClient.GetInputs[1].Headers.Get("Range")).To(Equal("bytes=5-"))
+						Expect(httpClient.GetInputs[1].Headers.Get("If-Range")).To(Equal(`"fake-etag"`))
+
+						Expect(fs.FileExists(partialPath)).To(BeFalse())
+						Expect(fs.FileExists(partialInfoPath)).To(BeFalse())
+					})
+				})
+
+				Context("when a partial download was left by a previous run", func() {
+					BeforeEach(func() {
+						partialFile := fakesys.NewFakeFile(partialPath, fs)
+						partialFile.Write([]byte("fake-"))
+						fs.RegisterOpenFile(partialPath, partialFile)
+						fs.WriteFileString(partialInfoPath, `{"etag":"\"fake-etag\""}`)
+					})
+
+					Context("when the tarball changed on the server", func() {
+						BeforeEach(func() {
+							httpClient.SetGetResponse(newResponse(206, "body", nil, map[string]string{
+								"Content-Range": "bytes 5-8/9",
+								"ETag":          `"fake-other-etag"`,
+							}), nil)
+							httpClient.SetGetBehavior("fake-body", 200, nil)
+						})
+
+						It("discards the partial download and downloads from the start", func() {
+							_, err := provider.Get(source, fakeStage)
+							Expect(err).ToNot(HaveOccurred())
+
+							Expect(httpClient.GetInputs).To(HaveLen(2))
+							Expect(httpClient.GetInputs[0].Headers.Get("Range")).To(Equal("bytes=5-"))
+							Expect(httpClient.GetInputs[1].Headers.Get("Range")).To(BeEmpty())
+						})
+					})
+
+					Context("when the server does not resume the download", func() {
+						BeforeEach(func() {
+							httpClient.SetGetBehavior("fake-body", 200, nil)
+						})
+
+						It("replaces the partial download with the full response", func() {
+							path, err := provider.Get(source, fakeStage)
+							Expect(err).ToNot(HaveOccurred())
+
+							Expect(httpClient.GetInputs).To(HaveLen(1))
+							Expect(fs.ReadFileString(path)).To(Equal("fake-body"))
+							Expect(fs.FileExists(partialInfoPath)).To(BeFalse())
+						})
+					})
+				})
+			})
+		})
+
+		Context("when the source has mirrors", func() {
+			var mirroredSource *fakeMirroredSource
+
+			BeforeEach(func() {
+				mirroredSource = &fakeMirroredSource{
+					fakeSource: newFakeSource("http://fake-url", "4603db250d7b5b78dfe17869649784353177b549", "fake-description"),
+					mirrors:    []string{"http://fake-mirror-url-1", "http://fake-mirror-url-2"},
+				}
+				digestCalculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+					"/fake-base-path/9db1fb7c47637e8709e944a232e1aa98ce6fec26-4603db250d7b5b78dfe17869649784353177b549.partial": {Sha1: "4603db250d7b5b78dfe17869649784353177b549"},
+				})
+			})
+
+			It("tries the mirrors in order when the URL fails", func() {
+				httpClient.SetGetBehavior("", 404, nil)
+				httpClient.SetGetBehavior("", 404, nil)
+				httpClient.SetGetBehavior("", 404, nil)
+				httpClient.SetGetBehavior("", 500, errors.New("fake-download-error"))
+				httpClient.SetGetBehavior("", 500, errors.New("fake-download-error"))
+				httpClient.SetGetBehavior("", 500, errors.New("fake-download-error"))
+				httpClient.SetGetBehavior("fake-body", 200, nil)
+
+				path, err := provider.Get(mirroredSource, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(path).To(Equal("/fake-base-path/9db1fb7c47637e8709e944a232e1aa98ce6fec26-4603db250d7b5b78dfe17869649784353177b549"))
+				Expect(fs.ReadFileString(path)).To(Equal("fake-body"))
+
+				endpoints := []string{}
+				for _, input := range httpClient.GetInputs {
+					endpoints = append(endpoints, input.Endpoint)
+				}
+				Expect(endpoints).To(Equal([]string{
+					"http://fake-url",
+					"http://fake-url",
+					"http://fake-url",
+					"http://fake-mirror-url-1",
+					"http://fake-mirror-url-1",
+					"http://fake-mirror-url-1",
+					"http://fake-mirror-url-2",
+				}))
+			})
+
+			It("does not try the mirrors when the URL succeeds", func() {
+				httpClient.SetGetBehavior("fake-body", 200, nil)
+
+				_, err := provider.Get(mirroredSource, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(httpClient.GetInputs).To(HaveLen(1))
+			})
+
+			It("returns the errors of all URLs when they all fail", func() {
+				for i := 0; i < 9; i++ {
+					httpClient.SetGetBehavior("", 404, nil)
+				}
+
+				_, err := provider.Get(mirroredSource, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Failed to download from 'http://fake-url'"))
+				Expect(err.Error()).To(ContainSubstring("Failed to download from 'http://fake-mirror-url-1'"))
+				Expect(err.Error()).To(ContainSubstring("Failed to download from 'http://fake-mirror-url-2'"))
+			})
+
+			It("does not resume a partial download started from another URL", func() {
+				partialPath := "/fake-base-path/9db1fb7c47637e8709e944a232e1aa98ce6fec26-4603db250d7b5b78dfe17869649784353177b549.partial"
+				partialFile := fakesys.NewFakeFile(partialPath, fs)
+				partialFile.Write([]byte("fake-"))
+				fs.RegisterOpenFile(partialPath, partialFile)
+				fs.WriteFileString(partialPath+".json", `{"url":"http://fake-mirror-url-1","etag":"\"fake-etag\""}`)
+				httpClient.SetGetBehavior("fake-body", 200, nil)
+
+				_, err := provider.Get(mirroredSource, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(httpClient.GetInputs).To(HaveLen(1))
+				Expect(httpClient.GetInputs[0].Endpoint).To(Equal("http://fake-url"))
+				Expect(httpClient.GetInputs[0].Headers.Get("Range")).To(BeEmpty())
 			})
 		})
 
 		Context("when URL does not start with either file:// or http(s)://", func() {
 			BeforeEach(func() {
-				source = newFakeSource("invalid-url", "fake-sha1", "fake-description")
+				source = newFakeSource("invalid-url", "4603db250d7b5b78dfe17869649784353177b549", "fake-description")
 			})
 
 			It("returns an error", func() {
@@ -205,6 +433,30 @@ var _ = Describe("Provider", func() {
 				Expect(err.Error()).To(ContainSubstring("Invalid source URL: 'invalid-url'"))
 			})
 		})
+
+		Context("when offline", func() {
+			BeforeEach(func() {
+				provider = NewOfflineProvider(cache, fs, boshlog.NewLogger(boshlog.LevelNone))
+				source = newFakeSource("http://fake-url", "4603db250d7b5b78dfe17869649784353177b549", "fake-description")
+			})
+
+			It("returns the cached tarball path", func() {
+				fs.WriteFileString("fake-source-path", "")
+				err := cache.Save("fake-source-path", source)
+				Expect(err).ToNot(HaveOccurred())
+
+				path, err := provider.Get(source, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(path).To(Equal("/fake-base-path/9db1fb7c47637e8709e944a232e1aa98ce6fec26-4603db250d7b5b78dfe17869649784353177b549"))
+			})
+
+			It("refuses to download a tarball that is not cached", func() {
+				_, err := provider.Get(source, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Refusing to download 'http://fake-url' in offline mode"))
+				Expect(httpClient.GetInputs).To(BeEmpty())
+			})
+		})
 	})
 })
 
@@ -221,3 +473,36 @@ func newFakeSource(url, sha1, description string) *fakeSource {
 func (s *fakeSource) GetURL() string      { return s.url }
 func (s *fakeSource) GetSHA1() string     { return s.sha1 }
 func (s *fakeSource) Description() string { return s.description }
+
+type fakeMirroredSource struct {
+	*fakeSource
+	mirrors []string
+}
+
+func (s *fakeMirroredSource) GetMirrors() []string { return s.mirrors }
+
+func newResponse(statusCode int, body string, bodyErr error, headers map[string]string) *http.Response {
+	var bodyReader io.Reader = strings.NewReader(body)
+	if bodyErr != nil {
+		bodyReader = io.MultiReader(bodyReader, errReader{err: bodyErr})
+	}
+
+	response := &http.Response{
+		StatusCode:    statusCode,
+		Body:          ioutil.NopCloser(bodyReader),
+		ContentLength: int64(len(body)),
+		Header:        http.Header{},
+	}
+	for name, value := range headers {
+		response.Header.Set(name, value)
+	}
+	return response
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
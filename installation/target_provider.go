@@ -32,23 +32,21 @@ func NewTargetProvider(
 }
 
 func (p *targetProvider) NewTarget() (Target, error) {
-	deploymentState, err := p.deploymentStateService.Load()
-	if err != nil {
-		return Target{}, bosherr.WrapError(err, "Loading deployment state")
-	}
-
-	installationID := deploymentState.InstallationID
-	if installationID == "" {
-		installationID, err = p.uuidGenerator.Generate()
-		if err != nil {
-			return Target{}, bosherr.WrapError(err, "Generating installation ID")
+	var installationID string
+	err := p.deploymentStateService.Update(func(deploymentState *biconfig.DeploymentState) error {
+		if deploymentState.InstallationID == "" {
+			var err error
+			deploymentState.InstallationID, err = p.uuidGenerator.Generate()
+			if err != nil {
+				return bosherr.WrapError(err, "Generating installation ID")
+			}
 		}
 
-		deploymentState.InstallationID = installationID
-		err := p.deploymentStateService.Save(deploymentState)
-		if err != nil {
-			return Target{}, bosherr.WrapError(err, "Saving deployment state")
-		}
+		installationID = deploymentState.InstallationID
+		return nil
+	})
+	if err != nil {
+		return Target{}, bosherr.WrapError(err, "Updating deployment state")
 	}
 
 	return NewTarget(filepath.Join(p.installationsRootPath, installationID)), nil
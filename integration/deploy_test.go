@@ -30,6 +30,7 @@ import (
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 
 	fakesys "github.com/cloudfoundry/bosh-agent/system/fakes"
+	faketime "github.com/cloudfoundry/bosh-agent/time/fakes"
 	fakeuuid "github.com/cloudfoundry/bosh-agent/uuid/fakes"
 
 	bicloud "github.com/cloudfoundry/bosh-init/cloud"
@@ -37,6 +38,7 @@ import (
 	biconfig "github.com/cloudfoundry/bosh-init/config"
 	bidepl "github.com/cloudfoundry/bosh-init/deployment"
 	biagentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient"
+	binats "github.com/cloudfoundry/bosh-init/deployment/agentclient/nats"
 	bias "github.com/cloudfoundry/bosh-init/deployment/applyspec"
 	bidisk "github.com/cloudfoundry/bosh-init/deployment/disk"
 	bihttp "github.com/cloudfoundry/bosh-init/deployment/httpclient"
@@ -44,15 +46,18 @@ import (
 	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
 	bisshtunnel "github.com/cloudfoundry/bosh-init/deployment/sshtunnel"
 	bivm "github.com/cloudfoundry/bosh-init/deployment/vm"
+	biindex "github.com/cloudfoundry/bosh-init/index"
 	biinstall "github.com/cloudfoundry/bosh-init/installation"
 	biinstalljob "github.com/cloudfoundry/bosh-init/installation/job"
 	biinstallmanifest "github.com/cloudfoundry/bosh-init/installation/manifest"
 	bitarball "github.com/cloudfoundry/bosh-init/installation/tarball"
+	fakebitarball "github.com/cloudfoundry/bosh-init/installation/tarball/fakes"
 	biregistry "github.com/cloudfoundry/bosh-init/registry"
 	birel "github.com/cloudfoundry/bosh-init/release"
 	bireljob "github.com/cloudfoundry/bosh-init/release/job"
 	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
 	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
+	bistatepkg "github.com/cloudfoundry/bosh-init/state/pkg"
 	bistemcell "github.com/cloudfoundry/bosh-init/stemcell"
 	biui "github.com/cloudfoundry/bosh-init/ui"
 
@@ -306,7 +311,7 @@ cloud_provider:
 				Path: filepath.Join(target.JobsPath(), "fake-cpi-release-job-name"),
 			}
 
-			installation := biinstall.NewInstallation(target, installedJob, installationManifest, registryServerManager)
+			installation := biinstall.NewInstallation(target, installedJob, installationManifest, registryServerManager, binats.NewServer(sshTunnelFactory, logger))
 
 			mockInstallerFactory.EXPECT().NewInstaller().Return(mockInstaller, nil).AnyTimes()
 
@@ -393,7 +398,7 @@ cloud_provider:
 			deploymentFactory := bidepl.NewFactory(pingTimeout, pingDelay)
 
 			ui := biui.NewWriterUI(stdOut, stdErr, logger)
-			doGet := func(deploymentManifestPath string) DeploymentPreparer {
+			doGet := func(deploymentManifestPath string, offline bool) DeploymentPreparer {
 				// todo: figure this out?
 				deploymentStateService = biconfig.NewFileSystemDeploymentStateService(fs, fakeUUIDGenerator, logger, biconfig.DeploymentStatePath(deploymentManifestPath))
 				vmRepo = biconfig.NewVMRepo(deploymentStateService)
@@ -415,8 +420,8 @@ cloud_provider:
 					logger,
 				)
 				fakeHTTPClient := fakebihttpclient.NewFakeHTTPClient()
-				tarballCache := bitarball.NewCache("fake-base-path", fs, logger)
-				tarballProvider := bitarball.NewProvider(tarballCache, fs, fakeHTTPClient, fakeSHA1Calculator, 1, 0, logger)
+				tarballCache := bitarball.NewCache("fake-base-path", 0, fs, &faketime.FakeService{}, logger)
+				tarballProvider := bitarball.NewProvider(tarballCache, fs, fakeHTTPClient, fakebicrypto.NewFakeDigestCalculator(), 1, 0, logger)
 
 				return NewDeploymentPreparer(
 					ui,
@@ -444,6 +449,10 @@ cloud_provider:
 					fakeStemcellExtractor,
 					deploymentManifestPath,
 					tarballProvider,
+					tarballCache,
+					bistatepkg.NewCompiledPackageRepo(biindex.NewInMemoryIndex()),
+					biconfig.NewCompiledPackageRepo(deploymentStateService),
+					false,
 				)
 			}
 
@@ -452,6 +461,7 @@ cloud_provider:
 				fs,
 				logger,
 				doGet,
+				fakebitarball.NewFakeBundler(),
 			)
 		}
 
@@ -472,10 +482,14 @@ cloud_provider:
 				mockCloud.EXPECT().AttachDisk(vmCID, diskCID),
 				mockAgentClient.EXPECT().MountDisk(diskCID),
 
+				mockAgentClient.EXPECT().Drain(biagentclient.DrainTypeUpdate, applySpec),
 				mockAgentClient.EXPECT().Stop(),
 				mockAgentClient.EXPECT().Apply(applySpec),
+				mockAgentClient.EXPECT().RunScript("pre-start", map[string]interface{}{}, time.Duration(0)),
 				mockAgentClient.EXPECT().Start(),
 				mockAgentClient.EXPECT().GetState().Return(agentRunningState, nil),
+				mockAgentClient.EXPECT().RunScript("post-start", map[string]interface{}{}, time.Duration(0)),
+				mockAgentClient.EXPECT().RunScript("post-deploy", map[string]interface{}{}, time.Duration(0)),
 			)
 		}
 
@@ -494,6 +508,7 @@ cloud_provider:
 
 				// shutdown old vm
 				mockAgentClient.EXPECT().Ping().Return("any-state", nil),
+				mockAgentClient.EXPECT().Drain(biagentclient.DrainTypeShutdown),
 				mockAgentClient.EXPECT().Stop(),
 				mockAgentClient.EXPECT().ListDisk().Return([]string{oldDiskCID}, nil),
 				mockAgentClient.EXPECT().UnmountDisk(oldDiskCID),
@@ -514,10 +529,14 @@ cloud_provider:
 				mockCloud.EXPECT().DeleteDisk(oldDiskCID),
 
 				// start jobs & wait for running
+				mockAgentClient.EXPECT().Drain(biagentclient.DrainTypeUpdate, applySpec),
 				mockAgentClient.EXPECT().Stop(),
 				mockAgentClient.EXPECT().Apply(applySpec),
+				mockAgentClient.EXPECT().RunScript("pre-start", map[string]interface{}{}, time.Duration(0)),
 				mockAgentClient.EXPECT().Start(),
 				mockAgentClient.EXPECT().GetState().Return(agentRunningState, nil),
+				mockAgentClient.EXPECT().RunScript("post-start", map[string]interface{}{}, time.Duration(0)),
+				mockAgentClient.EXPECT().RunScript("post-deploy", map[string]interface{}{}, time.Duration(0)),
 			)
 		}
 
@@ -552,10 +571,14 @@ cloud_provider:
 				mockCloud.EXPECT().DeleteDisk(oldDiskCID),
 
 				// start jobs & wait for running
+				mockAgentClient.EXPECT().Drain(biagentclient.DrainTypeUpdate, applySpec),
 				mockAgentClient.EXPECT().Stop(),
 				mockAgentClient.EXPECT().Apply(applySpec),
+				mockAgentClient.EXPECT().RunScript("pre-start", map[string]interface{}{}, time.Duration(0)),
 				mockAgentClient.EXPECT().Start(),
 				mockAgentClient.EXPECT().GetState().Return(agentRunningState, nil),
+				mockAgentClient.EXPECT().RunScript("post-start", map[string]interface{}{}, time.Duration(0)),
+				mockAgentClient.EXPECT().RunScript("post-deploy", map[string]interface{}{}, time.Duration(0)),
 			)
 		}
 
@@ -570,6 +593,7 @@ cloud_provider:
 
 				// shutdown old vm
 				mockAgentClient.EXPECT().Ping().Return("any-state", nil),
+				mockAgentClient.EXPECT().Drain(biagentclient.DrainTypeShutdown),
 				mockAgentClient.EXPECT().Stop(),
 				mockAgentClient.EXPECT().ListDisk().Return([]string{oldDiskCID}, nil),
 				mockAgentClient.EXPECT().UnmountDisk(oldDiskCID),
@@ -602,6 +626,7 @@ cloud_provider:
 
 				// shutdown old vm
 				mockAgentClient.EXPECT().Ping().Return("any-state", nil),
+				mockAgentClient.EXPECT().Drain(biagentclient.DrainTypeShutdown),
 				mockAgentClient.EXPECT().Stop(),
 				mockAgentClient.EXPECT().ListDisk().Return([]string{oldDiskCID}, nil),
 				mockAgentClient.EXPECT().UnmountDisk(oldDiskCID),
@@ -636,6 +661,7 @@ cloud_provider:
 
 				// shutdown old vm
 				mockAgentClient.EXPECT().Ping().Return("any-state", nil),
+				mockAgentClient.EXPECT().Drain(biagentclient.DrainTypeShutdown),
 				mockAgentClient.EXPECT().Stop(),
 				mockAgentClient.EXPECT().ListDisk().Return([]string{oldDiskCID}, nil),
 				mockAgentClient.EXPECT().UnmountDisk(oldDiskCID),
@@ -656,10 +682,14 @@ cloud_provider:
 				mockCloud.EXPECT().DeleteDisk(oldDiskCID),
 
 				// start jobs & wait for running
+				mockAgentClient.EXPECT().Drain(biagentclient.DrainTypeUpdate, applySpec),
 				mockAgentClient.EXPECT().Stop(),
 				mockAgentClient.EXPECT().Apply(applySpec),
+				mockAgentClient.EXPECT().RunScript("pre-start", map[string]interface{}{}, time.Duration(0)),
 				mockAgentClient.EXPECT().Start(),
 				mockAgentClient.EXPECT().GetState().Return(agentRunningState, nil),
+				mockAgentClient.EXPECT().RunScript("post-start", map[string]interface{}{}, time.Duration(0)),
+				mockAgentClient.EXPECT().RunScript("post-deploy", map[string]interface{}{}, time.Duration(0)),
 			)
 		}
 
@@ -709,12 +739,16 @@ cloud_provider:
 				),
 
 				mockAgentClient.EXPECT().MountDisk(diskCID),
+				mockAgentClient.EXPECT().Drain(biagentclient.DrainTypeUpdate, applySpec),
 				mockAgentClient.EXPECT().Stop().Do(
 					func() { expectRegistryToWork() },
 				),
 				mockAgentClient.EXPECT().Apply(applySpec),
+				mockAgentClient.EXPECT().RunScript("pre-start", map[string]interface{}{}, time.Duration(0)),
 				mockAgentClient.EXPECT().Start(),
 				mockAgentClient.EXPECT().GetState().Return(agentRunningState, nil),
+				mockAgentClient.EXPECT().RunScript("post-start", map[string]interface{}{}, time.Duration(0)),
+				mockAgentClient.EXPECT().RunScript("post-deploy", map[string]interface{}{}, time.Duration(0)),
 			)
 		}
 
@@ -745,7 +779,7 @@ cloud_provider:
 
 			mockCloud = mock_cloud.NewMockCloud(mockCtrl)
 
-			registryServerManager = biregistry.NewServerManager(logger)
+			registryServerManager = biregistry.NewServerManager(nil, logger)
 
 			mockReleaseExtractor = mock_release.NewMockExtractor(mockCtrl)
 			releaseManager = birel.NewManager(logger)
@@ -767,7 +801,7 @@ cloud_provider:
 			mockAgentClientFactory = mock_httpagent.NewMockAgentClientFactory(mockCtrl)
 			mockAgentClient = mock_agentclient.NewMockAgentClient(mockCtrl)
 
-			mockAgentClientFactory.EXPECT().NewAgentClient(directorID, mbusURL).Return(mockAgentClient).AnyTimes()
+			mockAgentClientFactory.EXPECT().NewAgentClient(directorID, mbusURL, gomock.Any(), gomock.Any()).Return(mockAgentClient).AnyTimes()
 
 			writeDeploymentManifest()
 			writeCPIReleaseTarball()
@@ -842,7 +876,7 @@ cloud_provider:
 				expectDeployFlow()
 
 				// new directorID will be generated
-				mockAgentClientFactory.EXPECT().NewAgentClient(gomock.Any(), mbusURL).Return(mockAgentClient)
+				mockAgentClientFactory.EXPECT().NewAgentClient(gomock.Any(), mbusURL, gomock.Any(), gomock.Any()).Return(mockAgentClient)
 
 				err := newDeployCmd().Run(fakeStage, []string{deploymentManifestPath})
 				Expect(err).ToNot(HaveOccurred())
@@ -949,6 +983,7 @@ cloud_provider:
 
 				mockCloud.EXPECT().HasVM(gomock.Any()).Return(true, nil).AnyTimes()
 				mockAgentClient.EXPECT().Ping().AnyTimes()
+				mockAgentClient.EXPECT().Drain(gomock.Any()).AnyTimes()
 				mockAgentClient.EXPECT().Stop().AnyTimes()
 				mockAgentClient.EXPECT().ListDisk().AnyTimes()
 			}
@@ -3,7 +3,9 @@ package main
 import (
 	"os"
 	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
@@ -28,6 +30,9 @@ func main() {
 	ui := biui.NewConsoleUI(logger)
 
 	timeService := boshtime.NewConcreteService()
+	tarballCacheMaxSize := newTarballCacheMaxSize(ui, logger)
+	contentAddressedBlobs := os.Getenv("BOSH_INIT_CONTENT_ADDRESSED_BLOBS") == "true"
+	blobstoreGetAttempts, blobstoreGetDelay := newBlobstoreGetRetries(ui, logger)
 
 	cmdFactory := bicmd.NewFactory(
 		fileSystem,
@@ -36,6 +41,10 @@ func main() {
 		logger,
 		boshuuid.NewGenerator(),
 		workspaceRootPath,
+		tarballCacheMaxSize,
+		contentAddressedBlobs,
+		blobstoreGetAttempts,
+		blobstoreGetDelay,
 	)
 
 	cmdRunner := bicmd.NewRunner(cmdFactory)
@@ -74,6 +83,52 @@ func newLogger() boshlog.Logger {
 	return boshlog.NewLogger(level)
 }
 
+func newTarballCacheMaxSize(ui biui.UI, logger boshlog.Logger) int64 {
+	maxSizeString := os.Getenv("BOSH_INIT_CACHE_MAX_SIZE")
+	if maxSizeString == "" {
+		return 0
+	}
+
+	maxSize, err := biuifmt.ParseBytes(maxSizeString)
+	if err != nil {
+		err = bosherr.WrapError(err, "Invalid BOSH_INIT_CACHE_MAX_SIZE value")
+		fail(err, ui, logger, nil)
+	}
+	return maxSize
+}
+
+func newBlobstoreGetRetries(ui biui.UI, logger boshlog.Logger) (int, time.Duration) {
+	attempts := 3
+	attemptsString := os.Getenv("BOSH_INIT_BLOBSTORE_GET_ATTEMPTS")
+	if attemptsString != "" {
+		var err error
+		attempts, err = strconv.Atoi(attemptsString)
+		if err == nil && attempts < 1 {
+			err = bosherr.Error("Must be at least 1")
+		}
+		if err != nil {
+			err = bosherr.WrapError(err, "Invalid BOSH_INIT_BLOBSTORE_GET_ATTEMPTS value")
+			fail(err, ui, logger, nil)
+		}
+	}
+
+	delay := 500 * time.Millisecond
+	delayString := os.Getenv("BOSH_INIT_BLOBSTORE_GET_DELAY")
+	if delayString != "" {
+		var err error
+		delay, err = time.ParseDuration(delayString)
+		if err == nil && delay < 0 {
+			err = bosherr.Error("Must not be negative")
+		}
+		if err != nil {
+			err = bosherr.WrapError(err, "Invalid BOSH_INIT_BLOBSTORE_GET_DELAY value")
+			fail(err, ui, logger, nil)
+		}
+	}
+
+	return attempts, delay
+}
+
 func newFileLogger(logPath string, level boshlog.LogLevel) boshlog.Logger {
 	// Log file logger errors to the STDERR logger
 	logger := boshlog.NewLogger(boshlog.LevelError)
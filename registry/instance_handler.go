@@ -66,7 +66,11 @@ func (h *instanceHandler) HandleFunc(w http.ResponseWriter, req *http.Request) {
 }
 
 func (h *instanceHandler) HandleGet(instanceID string, w http.ResponseWriter, req *http.Request) {
-	settingsJSON, ok := h.registry.Get(instanceID)
+	settingsJSON, ok, err := h.registry.Get(instanceID)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
 	if !ok {
 		h.logger.Debug(h.logTag, "No settings for %s found", instanceID)
 		h.handleNotFound(w)
@@ -104,7 +108,11 @@ func (h *instanceHandler) HandlePut(instanceID string, w http.ResponseWriter, re
 	}
 
 	h.logger.Debug(h.logTag, "Saving settings to registry for instance %s", instanceID)
-	isUpdated := h.registry.Save(instanceID, reqBody)
+	isUpdated, err := h.registry.Save(instanceID, reqBody)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
 	if isUpdated {
 		w.WriteHeader(http.StatusOK)
 		return
@@ -120,7 +128,10 @@ func (h *instanceHandler) HandleDelete(instanceID string, w http.ResponseWriter,
 	}
 
 	h.logger.Debug(h.logTag, "Deleting settings for instance %s", instanceID)
-	h.registry.Delete(instanceID)
+	err := h.registry.Delete(instanceID)
+	if err != nil {
+		h.handleError(w, err)
+	}
 }
 
 func (h *instanceHandler) handleUnauthorized(w http.ResponseWriter) {
@@ -166,3 +177,14 @@ func (h *instanceHandler) handleBadRequest(w http.ResponseWriter) {
 	}
 	w.Write(settingsJSON)
 }
+
+func (h *instanceHandler) handleError(w http.ResponseWriter, err error) {
+	h.logger.Error(h.logTag, "Accessing registry: %s", err.Error())
+	w.WriteHeader(http.StatusInternalServerError)
+	settingsJSON, err := json.Marshal(SettingsResponse{Status: "error"})
+	if err != nil {
+		h.logger.Warn(h.logTag, "Failed to marshal 'error' settings response %s", err.Error())
+		return
+	}
+	w.Write(settingsJSON)
+}
@@ -0,0 +1,143 @@
+package registry
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	biconfig "github.com/cloudfoundry/bosh-init/config"
+)
+
+// PersistentRegistry is a Registry kept in the deployment state,
+// so that the agent can fetch its settings from 'bosh-init registry serve' after bosh-init exits (e.g. when the VM reboots).
+type PersistentRegistry interface {
+	Registry
+
+	// SaveCredentials records the credentials the registry is served with
+	SaveCredentials(username string, password string) error
+
+	// Credentials returns the credentials the registry was last served with, if it ever was
+	Credentials() (username string, password string, found bool, err error)
+}
+
+type persistentRegistry struct {
+	deploymentStateService biconfig.DeploymentStateService
+	logger                 boshlog.Logger
+	logTag                 string
+}
+
+func NewPersistentRegistry(deploymentStateService biconfig.DeploymentStateService, logger boshlog.Logger) PersistentRegistry {
+	return &persistentRegistry{
+		deploymentStateService: deploymentStateService,
+		logger:                 logger,
+		logTag:                 "persistentRegistry",
+	}
+}
+
+func (r *persistentRegistry) Save(instanceID string, settings []byte) (bool, error) {
+	exists := false
+	err := r.deploymentStateService.Update(func(deploymentState *biconfig.DeploymentState) error {
+		record := r.record(*deploymentState)
+
+		for i, instance := range record.Instances {
+			if instance.InstanceID == instanceID {
+				record.Instances[i].Settings = string(settings)
+				exists = true
+			}
+		}
+		if !exists {
+			record.Instances = append(record.Instances, biconfig.RegistryInstanceRecord{
+				InstanceID: instanceID,
+				Settings:   string(settings),
+			})
+		}
+
+		deploymentState.Registry = record
+		return nil
+	})
+	if err != nil {
+		return false, bosherr.WrapError(err, "Updating deployment state")
+	}
+
+	return exists, nil
+}
+
+func (r *persistentRegistry) Get(instanceID string) ([]byte, bool, error) {
+	deploymentState, err := r.deploymentStateService.Load()
+	if err != nil {
+		return nil, false, bosherr.WrapError(err, "Loading existing deployment state")
+	}
+
+	for _, instance := range r.record(deploymentState).Instances {
+		if instance.InstanceID == instanceID {
+			return []byte(instance.Settings), true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+func (r *persistentRegistry) Delete(instanceID string) error {
+	err := r.deploymentStateService.Update(func(deploymentState *biconfig.DeploymentState) error {
+		record := r.record(*deploymentState)
+
+		instances := []biconfig.RegistryInstanceRecord{}
+		for _, instance := range record.Instances {
+			if instance.InstanceID != instanceID {
+				instances = append(instances, instance)
+			}
+		}
+		record.Instances = instances
+
+		deploymentState.Registry = record
+		return nil
+	})
+	if err != nil {
+		return bosherr.WrapError(err, "Updating deployment state")
+	}
+
+	return nil
+}
+
+func (r *persistentRegistry) SaveCredentials(username string, password string) error {
+	err := r.deploymentStateService.Update(func(deploymentState *biconfig.DeploymentState) error {
+		record := r.record(*deploymentState)
+		record.Username = username
+		record.Password = password
+
+		deploymentState.Registry = record
+		return nil
+	})
+	if err != nil {
+		return bosherr.WrapError(err, "Updating deployment state")
+	}
+
+	return nil
+}
+
+func (r *persistentRegistry) Credentials() (string, string, bool, error) {
+	if !r.deploymentStateService.Exists() {
+		return "", "", false, nil
+	}
+
+	deploymentState, err := r.deploymentStateService.Load()
+	if err != nil {
+		return "", "", false, bosherr.WrapError(err, "Loading existing deployment state")
+	}
+
+	if deploymentState.Registry == nil || deploymentState.Registry.Username == "" {
+		return "", "", false, nil
+	}
+
+	return deploymentState.Registry.Username, deploymentState.Registry.Password, true, nil
+}
+
+// record returns a copy of the registry record of the deployment state, or an empty one
+func (r *persistentRegistry) record(deploymentState biconfig.DeploymentState) *biconfig.RegistryRecord {
+	record := &biconfig.RegistryRecord{Instances: []biconfig.RegistryInstanceRecord{}}
+	if deploymentState.Registry != nil {
+		record.Username = deploymentState.Registry.Username
+		record.Password = deploymentState.Registry.Password
+		record.Instances = append(record.Instances, deploymentState.Registry.Instances...)
+	}
+	return record
+}
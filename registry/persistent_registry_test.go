@@ -0,0 +1,136 @@
+package registry_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	fakesys "github.com/cloudfoundry/bosh-agent/system/fakes"
+	fakeuuid "github.com/cloudfoundry/bosh-agent/uuid/fakes"
+
+	biconfig "github.com/cloudfoundry/bosh-init/config"
+
+	. "github.com/cloudfoundry/bosh-init/registry"
+)
+
+var _ = Describe("PersistentRegistry", func() {
+	var (
+		fakeFs                 *fakesys.FakeFileSystem
+		deploymentStateService biconfig.DeploymentStateService
+		registry               PersistentRegistry
+	)
+
+	BeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		fakeFs = fakesys.NewFakeFileSystem()
+		deploymentStateService = biconfig.NewFileSystemDeploymentStateService(fakeFs, fakeuuid.NewFakeGenerator(), logger, "/fake-deployment-state.json")
+		registry = NewPersistentRegistry(deploymentStateService, logger)
+	})
+
+	Describe("Save", func() {
+		It("records the settings in the deployment state", func() {
+			exists, err := registry.Save("fake-instance-id", []byte("fake-settings"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exists).To(BeFalse())
+
+			deploymentState, err := deploymentStateService.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentState.Registry.Instances).To(Equal([]biconfig.RegistryInstanceRecord{
+				{InstanceID: "fake-instance-id", Settings: "fake-settings"},
+			}))
+		})
+
+		It("replaces the existing settings of the instance", func() {
+			_, err := registry.Save("fake-instance-id", []byte("fake-settings"))
+			Expect(err).ToNot(HaveOccurred())
+
+			exists, err := registry.Save("fake-instance-id", []byte("fake-new-settings"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exists).To(BeTrue())
+
+			settings, found, err := registry.Get("fake-instance-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(string(settings)).To(Equal("fake-new-settings"))
+		})
+
+		It("returns an error when the deployment state cannot be saved", func() {
+			fakeFs.WriteFileError = bosherr.Error("fake-write-error")
+
+			_, err := registry.Save("fake-instance-id", []byte("fake-settings"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-write-error"))
+		})
+	})
+
+	Describe("Get", func() {
+		It("returns the settings saved by another registry of the same deployment", func() {
+			_, err := registry.Save("fake-instance-id", []byte("fake-settings"))
+			Expect(err).ToNot(HaveOccurred())
+
+			otherRegistry := NewPersistentRegistry(deploymentStateService, boshlog.NewLogger(boshlog.LevelNone))
+			settings, found, err := otherRegistry.Get("fake-instance-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(string(settings)).To(Equal("fake-settings"))
+		})
+
+		It("does not find unknown instances", func() {
+			_, found, err := registry.Get("fake-unknown-instance-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Describe("Delete", func() {
+		It("removes the settings of the instance only", func() {
+			_, err := registry.Save("fake-instance-id", []byte("fake-settings"))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = registry.Save("fake-other-instance-id", []byte("fake-other-settings"))
+			Expect(err).ToNot(HaveOccurred())
+
+			err = registry.Delete("fake-instance-id")
+			Expect(err).ToNot(HaveOccurred())
+
+			_, found, err := registry.Get("fake-instance-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+
+			_, found, err = registry.Get("fake-other-instance-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+		})
+	})
+
+	Describe("Credentials", func() {
+		It("is not found when the deployment state does not exist", func() {
+			_, _, found, err := registry.Credentials()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		It("returns the saved credentials", func() {
+			err := registry.SaveCredentials("fake-username", "fake-password")
+			Expect(err).ToNot(HaveOccurred())
+
+			username, password, found, err := registry.Credentials()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(username).To(Equal("fake-username"))
+			Expect(password).To(Equal("fake-password"))
+		})
+
+		It("keeps the saved settings", func() {
+			_, err := registry.Save("fake-instance-id", []byte("fake-settings"))
+			Expect(err).ToNot(HaveOccurred())
+
+			err = registry.SaveCredentials("fake-username", "fake-password")
+			Expect(err).ToNot(HaveOccurred())
+
+			_, found, err := registry.Get("fake-instance-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+		})
+	})
+})
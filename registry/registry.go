@@ -3,28 +3,29 @@ package registry
 type registry map[string][]byte
 
 type Registry interface {
-	Save(string, []byte) bool
-	Get(string) ([]byte, bool)
-	Delete(string)
+	Save(string, []byte) (bool, error)
+	Get(string) ([]byte, bool, error)
+	Delete(string) error
 }
 
 func NewRegistry() Registry {
 	return make(registry)
 }
 
-func (r registry) Save(key string, value []byte) bool {
+func (r registry) Save(key string, value []byte) (bool, error) {
 	_, exists := r[key]
 	r[key] = value
 
-	return exists
+	return exists, nil
 }
 
-func (r registry) Get(key string) ([]byte, bool) {
+func (r registry) Get(key string) ([]byte, bool, error) {
 	value, exists := r[key]
 
-	return value, exists
+	return value, exists, nil
 }
 
-func (r registry) Delete(key string) {
+func (r registry) Delete(key string) error {
 	delete(r, key)
+	return nil
 }
@@ -10,31 +10,49 @@ import (
 )
 
 type ServerManager interface {
-	Start(string, string, string, int) (Server, error)
+	// Start serves the registry. When persistent is true, the registry is kept in the deployment state.
+	Start(username string, password string, host string, port int, persistent bool) (Server, error)
 }
 
 type serverManager struct {
-	logger boshlog.Logger
-	logTag string
+	persistentRegistry PersistentRegistry
+	logger             boshlog.Logger
+	logTag             string
 }
 
-func NewServerManager(logger boshlog.Logger) ServerManager {
+// NewServerManager returns a ServerManager of the registry of a deployment.
+// persistentRegistry may be nil if the deployment does not have state to keep it in.
+func NewServerManager(persistentRegistry PersistentRegistry, logger boshlog.Logger) ServerManager {
 	return &serverManager{
-		logger: logger,
-		logTag: "registryServer",
+		persistentRegistry: persistentRegistry,
+		logger:             logger,
+		logTag:             "registryServer",
 	}
 }
 
 // Create starts a new server on a goroutine and returns it
 // The returned error is only for starting. Error while running is logged.
-func (s *serverManager) Start(username string, password string, host string, port int) (Server, error) {
+func (s *serverManager) Start(username string, password string, host string, port int, persistent bool) (Server, error) {
+	registry := NewRegistry()
+	if persistent {
+		if s.persistentRegistry == nil {
+			return nil, bosherr.Error("Persistent registry requires a deployment state")
+		}
+
+		err := s.persistentRegistry.SaveCredentials(username, password)
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Saving registry credentials")
+		}
+		registry = s.persistentRegistry
+	}
+
 	startedCh := make(chan error)
 	server := &server{
 		logger: s.logger,
 		logTag: "registryServer",
 	}
 	go func() {
-		err := server.start(username, password, host, port, startedCh)
+		err := server.start(username, password, host, port, registry, startedCh)
 		if err != nil {
 			s.logger.Debug(s.logTag, "Registry error occurred: %s", err.Error())
 		}
@@ -65,7 +83,7 @@ func NewServer(logger boshlog.Logger) Server {
 	}
 }
 
-func (s *server) start(username string, password string, host string, port int, readyErrCh chan error) error {
+func (s *server) start(username string, password string, host string, port int, registry Registry, readyErrCh chan error) error {
 	s.logger.Debug(s.logTag, "Starting registry server at %s:%d", host, port)
 	var err error
 	s.listener, err = net.Listen("tcp", fmt.Sprintf("%s:%d", host, port))
@@ -80,7 +98,6 @@ func (s *server) start(username string, password string, host string, port int,
 	mux := http.NewServeMux()
 	httpServer.Handler = mux
 
-	registry := NewRegistry()
 	instanceHandler := newInstanceHandler(username, password, registry, s.logger)
 	mux.HandleFunc("/instances/", instanceHandler.HandleFunc)
 
@@ -11,6 +11,10 @@ import (
 	. "github.com/onsi/gomega"
 
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	fakesys "github.com/cloudfoundry/bosh-agent/system/fakes"
+	fakeuuid "github.com/cloudfoundry/bosh-agent/uuid/fakes"
+
+	biconfig "github.com/cloudfoundry/bosh-init/config"
 
 	. "github.com/cloudfoundry/bosh-init/registry"
 )
@@ -29,9 +33,9 @@ var _ = Describe("Server", func() {
 		incorrectAuthRegistryURL = fmt.Sprintf("http://incorrect-user:incorrect-password@%s", registryHost)
 		logger := boshlog.NewLogger(boshlog.LevelNone)
 
-		serverFactory := NewServerManager(logger)
+		serverFactory := NewServerManager(nil, logger)
 		var err error
-		server, err = serverFactory.Start("fake-user", "fake-password", "localhost", 6901)
+		server, err = serverFactory.Start("fake-user", "fake-password", "localhost", 6901, false)
 		Expect(err).ToNot(HaveOccurred())
 
 		transport := &http.Transport{DisableKeepAlives: true}
@@ -161,6 +165,47 @@ var _ = Describe("Server", func() {
 	})
 })
 
+var _ = Describe("ServerManager", func() {
+	var (
+		logger boshlog.Logger
+	)
+
+	BeforeEach(func() {
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+	})
+
+	Context("when the registry is persistent", func() {
+		It("saves the credentials and serves the persistent registry", func() {
+			fakeFs := fakesys.NewFakeFileSystem()
+			deploymentStateService := biconfig.NewFileSystemDeploymentStateService(fakeFs, fakeuuid.NewFakeGenerator(), logger, "/fake-deployment-state.json")
+			persistentRegistry := NewPersistentRegistry(deploymentStateService, logger)
+			_, err := persistentRegistry.Save("1", []byte("fake-saved-settings"))
+			Expect(err).ToNot(HaveOccurred())
+
+			server, err := NewServerManager(persistentRegistry, logger).Start("fake-user", "fake-password", "localhost", 6905, true)
+			Expect(err).ToNot(HaveOccurred())
+			defer server.Stop()
+
+			username, password, found, err := persistentRegistry.Credentials()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(username).To(Equal("fake-user"))
+			Expect(password).To(Equal("fake-password"))
+
+			client := newHelperClient(http.Client{Transport: &http.Transport{DisableKeepAlives: true}})
+			httpBody, statusCode := client.DoGet("http://localhost:6905/instances/1/settings")
+			Expect(statusCode).To(Equal(200))
+			Expect(string(httpBody)).To(ContainSubstring("fake-saved-settings"))
+		})
+
+		It("returns an error without a deployment state", func() {
+			_, err := NewServerManager(nil, logger).Start("fake-user", "fake-password", "localhost", 6905, true)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Persistent registry requires a deployment state"))
+		})
+	})
+})
+
 type helperClient struct {
 	httpClient http.Client
 }
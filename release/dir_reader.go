@@ -0,0 +1,452 @@
+package release
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshcmd "github.com/cloudfoundry/bosh-agent/platform/commands"
+	boshsys "github.com/cloudfoundry/bosh-agent/system"
+
+	bicrypto "github.com/cloudfoundry/bosh-init/crypto"
+	birelmanifest "github.com/cloudfoundry/bosh-init/release/manifest"
+)
+
+// dirReader builds a dev release from a release source directory, the one 'bosh create release' works from.
+// Jobs and packages are archived into extractedReleasePath in the layout of a release tarball and read by the tarball reader.
+// Their fingerprints are computed by bosh-init (see archive), so the release is not the one the Extractor returns
+// for the tarball 'bosh create release' builds from the same directory.
+type dirReader struct {
+	releaseDirPath       string
+	extractedReleasePath string
+	fs                   boshsys.FileSystem
+	compressor           boshcmd.Compressor
+	digestCalculator     bicrypto.DigestCalculator
+}
+
+type releaseNameConfig struct {
+	Name      string `yaml:"name"`
+	FinalName string `yaml:"final_name"`
+	DevName   string `yaml:"dev_name"`
+}
+
+type blobConfig struct {
+	SHA1 string `yaml:"sha"`
+}
+
+type packageSpec struct {
+	Name          string   `yaml:"name"`
+	Dependencies  []string `yaml:"dependencies"`
+	Files         []string `yaml:"files"`
+	ExcludedFiles []string `yaml:"excluded_files"`
+}
+
+type jobSpec struct {
+	Name      string            `yaml:"name"`
+	Templates map[string]string `yaml:"templates"`
+}
+
+func NewDirReader(
+	releaseDirPath string,
+	extractedReleasePath string,
+	fs boshsys.FileSystem,
+	compressor boshcmd.Compressor,
+	digestCalculator bicrypto.DigestCalculator,
+) Reader {
+	return &dirReader{
+		releaseDirPath:       releaseDirPath,
+		extractedReleasePath: extractedReleasePath,
+		fs:                   fs,
+		compressor:           compressor,
+		digestCalculator:     digestCalculator,
+	}
+}
+
+// Read archives the packages and jobs of the release directory and constructs the release from them.
+// Package files are taken from 'src' and 'blobs', or from the local blob cache ('.blobs') for the blobs in 'config/blobs.yml'.
+// The dev release version is derived from the job and package fingerprints, so it changes whenever the release does.
+func (r *dirReader) Read() (Release, error) {
+	name, err := r.readName()
+	if err != nil {
+		return nil, err
+	}
+
+	sourceFiles, err := r.findSourceFiles()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Finding package source files")
+	}
+
+	packageRefs, err := r.archivePackages(sourceFiles)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Building packages")
+	}
+
+	jobRefs, err := r.archiveJobs()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Building jobs")
+	}
+
+	manifest := birelmanifest.Manifest{
+		Name:     name,
+		Version:  r.devVersion(jobRefs, packageRefs),
+		Jobs:     jobRefs,
+		Packages: packageRefs,
+	}
+
+	manifestBytes, err := yaml.Marshal(manifest)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Marshalling release manifest")
+	}
+
+	releaseManifestPath := path.Join(r.extractedReleasePath, "release.MF")
+	err = r.fs.WriteFile(releaseManifestPath, manifestBytes)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Writing release manifest '%s'", releaseManifestPath)
+	}
+
+	tarballReader := &reader{
+		extractedReleasePath: r.extractedReleasePath,
+		fs:                   r.fs,
+		extractor:            r.compressor,
+		digestCalculator:     r.digestCalculator,
+	}
+
+	release, err := tarballReader.newReleaseFromManifest(manifest)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Constructing release from manifest")
+	}
+
+	return release, nil
+}
+
+// readName returns the dev name of the release ('config/dev.yml'), or else its final name ('config/final.yml')
+func (r *dirReader) readName() (string, error) {
+	for _, configFileName := range []string{"dev.yml", "final.yml"} {
+		configPath := path.Join(r.releaseDirPath, "config", configFileName)
+		if !r.fs.FileExists(configPath) {
+			continue
+		}
+
+		var config releaseNameConfig
+		err := r.readYAML(configPath, &config)
+		if err != nil {
+			return "", err
+		}
+
+		for _, name := range []string{config.DevName, config.FinalName, config.Name} {
+			if name != "" {
+				return name, nil
+			}
+		}
+	}
+
+	return "", bosherr.Errorf("Release name not found in 'config/dev.yml' or 'config/final.yml' of release directory '%s'", r.releaseDirPath)
+}
+
+// findSourceFiles returns the paths of the files that packages can include, by their path relative to 'src' or 'blobs'.
+// Files in 'src' take precedence over blobs of the same path.
+func (r *dirReader) findSourceFiles() (map[string]string, error) {
+	sourceFiles := map[string]string{}
+
+	blobsConfigPath := path.Join(r.releaseDirPath, "config", "blobs.yml")
+	if r.fs.FileExists(blobsConfigPath) {
+		blobs := map[string]blobConfig{}
+		err := r.readYAML(blobsConfigPath, &blobs)
+		if err != nil {
+			return nil, err
+		}
+
+		for blobPath, blob := range blobs {
+			// the file is checked when a package includes it, so that unused blobs need not be synced
+			sourceFiles[blobPath] = path.Join(r.releaseDirPath, ".blobs", blob.SHA1)
+		}
+	}
+
+	for _, dirName := range []string{"blobs", "src"} {
+		dirPath := path.Join(r.releaseDirPath, dirName)
+		if !r.fs.FileExists(dirPath) {
+			continue
+		}
+
+		err := r.fs.Walk(dirPath, func(filePath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			relativePath, err := filepath.Rel(dirPath, filePath)
+			if err != nil {
+				return err
+			}
+			sourceFiles[filepath.ToSlash(relativePath)] = filePath
+			return nil
+		})
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Listing files in '%s'", dirPath)
+		}
+	}
+
+	return sourceFiles, nil
+}
+
+func (r *dirReader) archivePackages(sourceFiles map[string]string) ([]birelmanifest.PackageRef, error) {
+	specPaths, err := r.fs.Glob(path.Join(r.releaseDirPath, "packages", "*", "spec"))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Listing package specs")
+	}
+	sort.Strings(specPaths)
+
+	packageRefs := []birelmanifest.PackageRef{}
+	for _, specPath := range specPaths {
+		packageDirPath := path.Dir(specPath)
+
+		var spec packageSpec
+		err = r.readYAML(specPath, &spec)
+		if err != nil {
+			return nil, err
+		}
+		if spec.Name == "" {
+			spec.Name = path.Base(packageDirPath)
+		}
+
+		if r.fs.FileExists(path.Join(packageDirPath, "pre_packaging")) {
+			return nil, bosherr.Errorf("Package '%s' has a 'pre_packaging' script, which is not supported for dev releases built from a directory", spec.Name)
+		}
+
+		files := map[string]string{
+			"packaging": path.Join(packageDirPath, "packaging"),
+		}
+		for relativePath, filePath := range sourceFiles {
+			included, err := r.matchesAny(relativePath, spec.Files)
+			if err != nil {
+				return nil, bosherr.WrapErrorf(err, "Matching files of package '%s'", spec.Name)
+			}
+			excluded, err := r.matchesAny(relativePath, spec.ExcludedFiles)
+			if err != nil {
+				return nil, bosherr.WrapErrorf(err, "Matching excluded files of package '%s'", spec.Name)
+			}
+			if !included || excluded {
+				continue
+			}
+
+			if !r.fs.FileExists(filePath) {
+				return nil, bosherr.Errorf("Blob '%s' of package '%s' is not in the local blob cache, run 'bosh sync blobs'", relativePath, spec.Name)
+			}
+			files[relativePath] = filePath
+		}
+
+		dependencies := append([]string{}, spec.Dependencies...)
+		sort.Strings(dependencies)
+
+		fingerprint, archiveSHA1, err := r.archive(files, dependencies, path.Join(r.extractedReleasePath, "packages", spec.Name+".tgz"))
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Archiving package '%s'", spec.Name)
+		}
+
+		packageRefs = append(packageRefs, birelmanifest.PackageRef{
+			Name:         spec.Name,
+			Fingerprint:  fingerprint,
+			SHA1:         archiveSHA1,
+			Dependencies: spec.Dependencies,
+		})
+	}
+
+	return packageRefs, nil
+}
+
+func (r *dirReader) archiveJobs() ([]birelmanifest.JobRef, error) {
+	specPaths, err := r.fs.Glob(path.Join(r.releaseDirPath, "jobs", "*", "spec"))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Listing job specs")
+	}
+	sort.Strings(specPaths)
+
+	jobRefs := []birelmanifest.JobRef{}
+	for _, specPath := range specPaths {
+		jobDirPath := path.Dir(specPath)
+
+		var spec jobSpec
+		err = r.readYAML(specPath, &spec)
+		if err != nil {
+			return nil, err
+		}
+		if spec.Name == "" {
+			spec.Name = path.Base(jobDirPath)
+		}
+
+		// the job spec is the job manifest of the archive
+		files := map[string]string{
+			"job.MF": specPath,
+			"monit":  path.Join(jobDirPath, "monit"),
+		}
+		for template := range spec.Templates {
+			files[path.Join("templates", template)] = path.Join(jobDirPath, "templates", template)
+		}
+
+		fingerprint, archiveSHA1, err := r.archive(files, []string{}, path.Join(r.extractedReleasePath, "jobs", spec.Name+".tgz"))
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Archiving job '%s'", spec.Name)
+		}
+
+		jobRefs = append(jobRefs, birelmanifest.JobRef{
+			Name:        spec.Name,
+			Version:     fingerprint,
+			Fingerprint: fingerprint,
+			SHA1:        archiveSHA1,
+		})
+	}
+
+	return jobRefs, nil
+}
+
+// archive compresses the files, by their path in the archive, to archivePath.
+// It returns the fingerprint of the files (and of the given dependency names) and the SHA1 of the archive.
+// The fingerprint is specific to bosh-init and differs from the one 'bosh create release' computes for the same files,
+// so packages of a dev release never match compiled packages of a release built by bosh.
+func (r *dirReader) archive(files map[string]string, dependencies []string, archivePath string) (string, string, error) {
+	stagingPath, err := r.fs.TempDir("bosh-init-dev-release")
+	if err != nil {
+		return "", "", bosherr.WrapError(err, "Creating staging directory")
+	}
+	defer r.fs.RemoveAll(stagingPath)
+
+	archiveFilePaths := []string{}
+	for archiveFilePath := range files {
+		archiveFilePaths = append(archiveFilePaths, archiveFilePath)
+	}
+	sort.Strings(archiveFilePaths)
+
+	fingerprint := sha1.New()
+	for _, archiveFilePath := range archiveFilePaths {
+		filePath := files[archiveFilePath]
+		if !r.fs.FileExists(filePath) {
+			return "", "", bosherr.Errorf("File '%s' does not exist", filePath)
+		}
+
+		fileSHA1, err := r.digestCalculator.Calculate(filePath, "sha1")
+		if err != nil {
+			return "", "", bosherr.WrapErrorf(err, "Calculating SHA1 of '%s'", filePath)
+		}
+		fmt.Fprintf(fingerprint, "%s%s", archiveFilePath, fileSHA1)
+
+		stagedFilePath := path.Join(stagingPath, archiveFilePath)
+		err = r.fs.MkdirAll(path.Dir(stagedFilePath), os.ModeDir|0700)
+		if err != nil {
+			return "", "", bosherr.WrapErrorf(err, "Creating directory of '%s'", stagedFilePath)
+		}
+		err = r.fs.CopyFile(filePath, stagedFilePath)
+		if err != nil {
+			return "", "", bosherr.WrapErrorf(err, "Copying '%s' to '%s'", filePath, stagedFilePath)
+		}
+	}
+	for _, dependency := range dependencies {
+		fmt.Fprintf(fingerprint, "%s", dependency)
+	}
+
+	tarballPath, err := r.compressor.CompressFilesInDir(stagingPath)
+	if err != nil {
+		return "", "", bosherr.WrapError(err, "Compressing files")
+	}
+	defer r.compressor.CleanUp(tarballPath)
+
+	err = r.fs.MkdirAll(path.Dir(archivePath), os.ModeDir|0700)
+	if err != nil {
+		return "", "", bosherr.WrapErrorf(err, "Creating directory of '%s'", archivePath)
+	}
+	err = r.fs.CopyFile(tarballPath, archivePath)
+	if err != nil {
+		return "", "", bosherr.WrapErrorf(err, "Copying archive to '%s'", archivePath)
+	}
+
+	archiveSHA1, err := r.digestCalculator.Calculate(archivePath, "sha1")
+	if err != nil {
+		return "", "", bosherr.WrapErrorf(err, "Calculating SHA1 of '%s'", archivePath)
+	}
+
+	return fmt.Sprintf("%x", fingerprint.Sum(nil)), archiveSHA1, nil
+}
+
+// devVersion returns '0+dev.' followed by the start of the fingerprint of the release jobs and packages.
+// Unlike the dev versions of 'bosh create release', it does not increase, it only identifies the release contents.
+func (r *dirReader) devVersion(jobRefs []birelmanifest.JobRef, packageRefs []birelmanifest.PackageRef) string {
+	fingerprint := sha1.New()
+	for _, jobRef := range jobRefs {
+		fmt.Fprintf(fingerprint, "job%s%s", jobRef.Name, jobRef.Fingerprint)
+	}
+	for _, packageRef := range packageRefs {
+		fmt.Fprintf(fingerprint, "package%s%s", packageRef.Name, packageRef.Fingerprint)
+	}
+	return fmt.Sprintf("0+dev.%x", fingerprint.Sum(nil))[:len("0+dev.")+10]
+}
+
+// matchesAny returns true if the relative file path matches one of the spec file patterns.
+// Patterns are shell globs where '**' also matches nested directories and '{a,b}' matches alternatives.
+func (r *dirReader) matchesAny(relativePath string, patterns []string) (bool, error) {
+	for _, pattern := range patterns {
+		patternRegexp, err := regexp.Compile(globToRegexp(pattern))
+		if err != nil {
+			return false, bosherr.WrapErrorf(err, "Parsing file pattern '%s'", pattern)
+		}
+		if patternRegexp.MatchString(relativePath) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *dirReader) readYAML(filePath string, value interface{}) error {
+	contents, err := r.fs.ReadFile(filePath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading '%s'", filePath)
+	}
+
+	err = yaml.Unmarshal(contents, value)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Parsing '%s'", filePath)
+	}
+
+	return nil
+}
+
+func globToRegexp(pattern string) string {
+	var expr bytes.Buffer
+	expr.WriteString("^")
+	inAlternatives := false
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		case c == '{':
+			expr.WriteString("(")
+			inAlternatives = true
+		case c == '}' && inAlternatives:
+			expr.WriteString(")")
+			inAlternatives = false
+		case c == ',' && inAlternatives:
+			expr.WriteString("|")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	return expr.String()
+}
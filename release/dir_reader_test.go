@@ -0,0 +1,190 @@
+package release_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-init/release"
+
+	"os"
+	"path/filepath"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	boshcmd "github.com/cloudfoundry/bosh-agent/platform/commands"
+	boshsys "github.com/cloudfoundry/bosh-agent/system"
+
+	bicrypto "github.com/cloudfoundry/bosh-init/crypto"
+	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
+)
+
+var _ = Describe("DirReader", func() {
+	var (
+		fs             boshsys.FileSystem
+		compressor     boshcmd.Compressor
+		releaseDirPath string
+		release        Release
+
+		releaseExtractor Extractor
+	)
+
+	writeFile := func(relativePath, contents string) {
+		filePath := filepath.Join(releaseDirPath, relativePath)
+		err := fs.MkdirAll(filepath.Dir(filePath), os.ModePerm)
+		Expect(err).ToNot(HaveOccurred())
+		err = fs.WriteFileString(filePath, contents)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		fs = boshsys.NewOsFileSystem(logger)
+		compressor = boshcmd.NewTarballCompressor(boshsys.NewExecCmdRunner(logger), fs)
+		releaseExtractor = NewExtractor(fs, compressor, bicrypto.NewDigestCalculator(fs), NewValidator(fs), logger)
+
+		var err error
+		releaseDirPath, err = fs.TempDir("bosh-init-release-dir")
+		Expect(err).ToNot(HaveOccurred())
+
+		writeFile("config/final.yml", "final_name: fake-release\n")
+		writeFile("config/blobs.yml", "fake-package/fake-blob.tgz:\n  object_id: fake-object-id\n  sha: fake-blob-sha1\n  size: 9\n")
+		writeFile(".blobs/fake-blob-sha1", "fake-blob")
+		writeFile("src/fake-package/fake-source.c", "fake-source")
+		writeFile("src/fake-package/README", "fake-readme")
+		writeFile("src/other-package/other-source.c", "other-source")
+
+		writeFile("packages/fake-package/spec", `---
+name: fake-package
+dependencies: [fake-dependency]
+files:
+- fake-package/**/*
+excluded_files:
+- fake-package/README
+`)
+		writeFile("packages/fake-package/packaging", "fake-packaging")
+		writeFile("packages/fake-dependency/spec", "---\nname: fake-dependency\nfiles: []\n")
+		writeFile("packages/fake-dependency/packaging", "fake-dependency-packaging")
+
+		writeFile("jobs/fake-job/spec", `---
+name: fake-job
+templates:
+  ctl.erb: bin/ctl
+packages: [fake-package]
+properties:
+  fake-property:
+    default: fake-default
+`)
+		writeFile("jobs/fake-job/monit", "fake-monit")
+		writeFile("jobs/fake-job/templates/ctl.erb", "fake-ctl")
+	})
+
+	AfterEach(func() {
+		if release != nil {
+			release.Delete()
+		}
+		fs.RemoveAll(releaseDirPath)
+	})
+
+	It("builds a dev release named after the release directory config", func() {
+		var err error
+		release, err = releaseExtractor.Extract(releaseDirPath)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(release.Name()).To(Equal("fake-release"))
+		Expect(release.Version()).To(MatchRegexp(`^0\+dev\.[0-9a-f]{10}$`))
+		Expect(release.IsCompiled()).To(BeFalse())
+	})
+
+	It("prefers the dev name of the release", func() {
+		writeFile("config/dev.yml", "dev_name: fake-dev-release\n")
+
+		var err error
+		release, err = releaseExtractor.Extract(releaseDirPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(release.Name()).To(Equal("fake-dev-release"))
+	})
+
+	It("archives the packages with their matching source files and blobs", func() {
+		var err error
+		release, err = releaseExtractor.Extract(releaseDirPath)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(release.Packages()).To(HaveLen(2))
+		dependency := release.Packages()[0]
+		pkg := release.Packages()[1]
+		Expect(dependency.Name).To(Equal("fake-dependency"))
+		Expect(pkg.Name).To(Equal("fake-package"))
+		Expect(pkg.Dependencies).To(Equal([]*birelpkg.Package{dependency}))
+		Expect(pkg.Fingerprint).To(MatchRegexp(`^[0-9a-f]{40}$`))
+
+		Expect(fs.ReadFileString(filepath.Join(pkg.ExtractedPath, "packaging"))).To(Equal("fake-packaging"))
+		Expect(fs.ReadFileString(filepath.Join(pkg.ExtractedPath, "fake-package", "fake-source.c"))).To(Equal("fake-source"))
+		Expect(fs.ReadFileString(filepath.Join(pkg.ExtractedPath, "fake-package", "fake-blob.tgz"))).To(Equal("fake-blob"))
+		Expect(fs.FileExists(filepath.Join(pkg.ExtractedPath, "fake-package", "README"))).To(BeFalse())
+		Expect(fs.FileExists(filepath.Join(pkg.ExtractedPath, "other-package"))).To(BeFalse())
+
+		archiveSHA1, err := bicrypto.NewDigestCalculator(fs).Calculate(pkg.ArchivePath, "sha1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pkg.SHA1).To(Equal(archiveSHA1))
+	})
+
+	It("archives the jobs with their templates", func() {
+		var err error
+		release, err = releaseExtractor.Extract(releaseDirPath)
+		Expect(err).ToNot(HaveOccurred())
+
+		job, found := release.FindJobByName("fake-job")
+		Expect(found).To(BeTrue())
+		Expect(job.Release).To(Equal("fake-release"))
+		Expect(job.Templates).To(Equal(map[string]string{"ctl.erb": "bin/ctl"}))
+		Expect(job.Packages).To(HaveLen(1))
+		Expect(job.Packages[0].Name).To(Equal("fake-package"))
+		Expect(job.Properties["fake-property"].Default).To(Equal("fake-default"))
+		Expect(fs.ReadFileString(filepath.Join(job.ExtractedPath, "monit"))).To(Equal("fake-monit"))
+		Expect(fs.ReadFileString(filepath.Join(job.ExtractedPath, "templates", "ctl.erb"))).To(Equal("fake-ctl"))
+		Expect(fs.FileExists(job.ArchivePath)).To(BeTrue())
+	})
+
+	It("changes the version only when the release source changes", func() {
+		var err error
+		release, err = releaseExtractor.Extract(releaseDirPath)
+		Expect(err).ToNot(HaveOccurred())
+		version := release.Version()
+		release.Delete()
+
+		release, err = releaseExtractor.Extract(releaseDirPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(release.Version()).To(Equal(version))
+		release.Delete()
+
+		writeFile("src/fake-package/fake-source.c", "changed-fake-source")
+		release, err = releaseExtractor.Extract(releaseDirPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(release.Version()).ToNot(Equal(version))
+	})
+
+	It("returns an error when a package blob is not in the local blob cache", func() {
+		err := fs.RemoveAll(filepath.Join(releaseDirPath, ".blobs"))
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = releaseExtractor.Extract(releaseDirPath)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Blob 'fake-package/fake-blob.tgz' of package 'fake-package' is not in the local blob cache"))
+	})
+
+	It("returns an error when the release has no name", func() {
+		err := fs.RemoveAll(filepath.Join(releaseDirPath, "config", "final.yml"))
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = releaseExtractor.Extract(releaseDirPath)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Release name not found"))
+	})
+
+	It("returns an error when a package has a pre_packaging script", func() {
+		writeFile("packages/fake-package/pre_packaging", "fake-pre-packaging")
+
+		_, err := releaseExtractor.Extract(releaseDirPath)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Package 'fake-package' has a 'pre_packaging' script"))
+	})
+})
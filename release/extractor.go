@@ -1,10 +1,14 @@
 package release
 
 import (
+	"path"
+
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 	boshcmd "github.com/cloudfoundry/bosh-agent/platform/commands"
 	boshsys "github.com/cloudfoundry/bosh-agent/system"
+
+	bicrypto "github.com/cloudfoundry/bosh-init/crypto"
 )
 
 type Extractor interface {
@@ -12,30 +16,35 @@ type Extractor interface {
 }
 
 type extractor struct {
-	fs         boshsys.FileSystem
-	compressor boshcmd.Compressor
-	validator  Validator
-	logger     boshlog.Logger
-	logTag     string
+	fs               boshsys.FileSystem
+	compressor       boshcmd.Compressor
+	digestCalculator bicrypto.DigestCalculator
+	validator        Validator
+	logger           boshlog.Logger
+	logTag           string
 }
 
 func NewExtractor(
 	fs boshsys.FileSystem,
 	compressor boshcmd.Compressor,
+	digestCalculator bicrypto.DigestCalculator,
 	validator Validator,
 	logger boshlog.Logger,
 ) Extractor {
 	return &extractor{
-		fs:         fs,
-		compressor: compressor,
-		validator:  validator,
-		logger:     logger,
-		logTag:     "releaseExtractor",
+		fs:               fs,
+		compressor:       compressor,
+		digestCalculator: digestCalculator,
+		validator:        validator,
+		logger:           logger,
+		logTag:           "releaseExtractor",
 	}
 }
 
 // Extract decompresses a release tarball into a temp directory (release.extractedPath),
-// parses the release manifest, decompresses the packages and jobs, and validates the release.
+// parses the release manifest, verifies and decompresses the packages and jobs, and validates the release.
+// Archives that do not match release.MF are reported together with the validation errors.
+// A release source directory (recognized by its 'jobs' directory) is built into a dev release in the temp directory instead.
 // Use release.Delete() to clean up the temp directory.
 func (e *extractor) Extract(releaseTarballPath string) (Release, error) {
 	extractedReleasePath, err := e.fs.TempDir("bosh-init-release")
@@ -43,17 +52,30 @@ func (e *extractor) Extract(releaseTarballPath string) (Release, error) {
 		return nil, bosherr.WrapErrorf(err, "Creating temp directory to extract release '%s'", releaseTarballPath)
 	}
 
-	e.logger.Info(e.logTag, "Extracting release tarball '%s' to '%s'", releaseTarballPath, extractedReleasePath)
-
-	releaseReader := NewReader(releaseTarballPath, extractedReleasePath, e.fs, e.compressor)
+	var releaseReader Reader
+	if e.fs.FileExists(path.Join(releaseTarballPath, "jobs")) {
+		e.logger.Info(e.logTag, "Building dev release from directory '%s' in '%s'", releaseTarballPath, extractedReleasePath)
+		releaseReader = NewDirReader(releaseTarballPath, extractedReleasePath, e.fs, e.compressor, e.digestCalculator)
+	} else {
+		e.logger.Info(e.logTag, "Extracting release tarball '%s' to '%s'", releaseTarballPath, extractedReleasePath)
+		releaseReader = NewReader(releaseTarballPath, extractedReleasePath, e.fs, e.compressor, e.digestCalculator)
+	}
+	errs := []error{}
 	release, err := releaseReader.Read()
-	if err != nil {
+	if archiveErr, ok := err.(ArchiveDigestError); ok {
+		errs = append(errs, archiveErr.Errs...)
+	} else if err != nil {
+		_ = e.fs.RemoveAll(extractedReleasePath)
 		return nil, bosherr.WrapErrorf(err, "Reading release from '%s'", releaseTarballPath)
 	}
 
 	err = e.validator.Validate(release)
 	if err != nil {
-		return nil, bosherr.WrapErrorf(err, "Validating release '%s-%s'", release.Name(), release.Version())
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return nil, bosherr.WrapErrorf(bosherr.NewMultiError(errs...), "Validating release '%s-%s'", release.Name(), release.Version())
 	}
 
 	e.logger.Info(e.logTag, "Extracted release %s version %s", release.Name(), release.Version())
@@ -11,6 +11,7 @@ import (
 
 	fakesys "github.com/cloudfoundry/bosh-agent/system/fakes"
 
+	fakebicrypto "github.com/cloudfoundry/bosh-init/crypto/fakes"
 	bireljob "github.com/cloudfoundry/bosh-init/release/job"
 	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
 
@@ -23,6 +24,7 @@ var _ = Describe("Extractor", func() {
 	var (
 		fakeFS               *fakesys.FakeFileSystem
 		fakeExtractor        *testfakes.FakeMultiResponseExtractor
+		fakeDigestCalculator *fakebicrypto.FakeDigestCalculator
 		fakeReleaseValidator *fakebirel.FakeValidator
 
 		deploymentManifestPath string
@@ -32,11 +34,12 @@ var _ = Describe("Extractor", func() {
 	BeforeEach(func() {
 		fakeFS = fakesys.NewFakeFileSystem()
 		fakeExtractor = testfakes.NewFakeMultiResponseExtractor()
+		fakeDigestCalculator = fakebicrypto.NewFakeDigestCalculator()
 		fakeReleaseValidator = fakebirel.NewFakeValidator()
 		logger := boshlog.NewLogger(boshlog.LevelNone)
 
 		deploymentManifestPath = "/fake/manifest.yml"
-		releaseExtractor = NewExtractor(fakeFS, fakeExtractor, fakeReleaseValidator, logger)
+		releaseExtractor = NewExtractor(fakeFS, fakeExtractor, fakeDigestCalculator, fakeReleaseValidator, logger)
 	})
 
 	Describe("Extract", func() {
@@ -60,7 +63,7 @@ var _ = Describe("Extractor", func() {
 				releasePackage := &birelpkg.Package{
 					Name:          "fake-release-package-name",
 					Fingerprint:   "fake-release-package-fingerprint",
-					SHA1:          "fake-release-package-sha1",
+					SHA1:          "4603db250d7b5b78dfe17869649784353177b549",
 					Dependencies:  []*birelpkg.Package{},
 					ExtractedPath: "/extracted-release-path/extracted_packages/fake-release-package-name",
 				}
@@ -68,7 +71,7 @@ var _ = Describe("Extractor", func() {
 				releaseJob = bireljob.Job{
 					Name:          "cpi",
 					Fingerprint:   "fake-release-job-fingerprint",
-					SHA1:          "fake-release-job-sha1",
+					SHA1:          "0a4d55a8d778e5022fab701977c5d840bbc486d0",
 					ExtractedPath: "/extracted-release-path/extracted_jobs/cpi",
 					Templates: map[string]string{
 						"cpi.erb":     "bin/cpi",
@@ -87,13 +90,13 @@ packages:
 - name: fake-release-package-name
   version: fake-release-package-version
   fingerprint: fake-release-package-fingerprint
-  sha1: fake-release-package-sha1
+  sha1: 4603db250d7b5b78dfe17869649784353177b549
   dependencies: []
 jobs:
 - name: cpi
   version: fake-release-job-version
   fingerprint: fake-release-job-fingerprint
-  sha1: fake-release-job-sha1
+  sha1: 0a4d55a8d778e5022fab701977c5d840bbc486d0
 `
 				fakeFS.WriteFileString("/extracted-release-path/release.MF", releaseContents)
 				jobManifestContents := `---
@@ -108,6 +111,11 @@ packages:
 properties: {}
 `
 				fakeFS.WriteFileString("/extracted-release-path/extracted_jobs/cpi/job.MF", jobManifestContents)
+
+				fakeDigestCalculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+					"/extracted-release-path/packages/fake-release-package-name.tgz": {Sha1: "4603db250d7b5b78dfe17869649784353177b549"},
+					"/extracted-release-path/jobs/cpi.tgz":                           {Sha1: "0a4d55a8d778e5022fab701977c5d840bbc486d0"},
+				})
 			})
 
 			JustBeforeEach(func() {
@@ -131,7 +139,7 @@ properties: {}
 					expectedPackage := &birelpkg.Package{
 						Name:          "fake-release-package-name",
 						Fingerprint:   "fake-release-package-fingerprint",
-						SHA1:          "fake-release-package-sha1",
+						SHA1:          "4603db250d7b5b78dfe17869649784353177b549",
 						ExtractedPath: "/extracted-release-path/extracted_packages/fake-release-package-name",
 						ArchivePath:   "/extracted-release-path/packages/fake-release-package-name.tgz",
 						Dependencies:  []*birelpkg.Package{},
@@ -142,9 +150,11 @@ properties: {}
 						[]bireljob.Job{
 							{
 								Name:          "cpi",
+								Release:       "fake-release-name",
 								Fingerprint:   "fake-release-job-fingerprint",
-								SHA1:          "fake-release-job-sha1",
+								SHA1:          "0a4d55a8d778e5022fab701977c5d840bbc486d0",
 								ExtractedPath: "/extracted-release-path/extracted_jobs/cpi",
+								ArchivePath:   "/extracted-release-path/jobs/cpi.tgz",
 								Templates: map[string]string{
 									"cpi.erb":     "bin/cpi",
 									"cpi.yml.erb": "config/cpi.yml",
@@ -182,6 +192,30 @@ properties: {}
 				})
 			})
 
+			Context("and an archive does not match release.MF", func() {
+				BeforeEach(func() {
+					fakeDigestCalculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+						"/extracted-release-path/packages/fake-release-package-name.tgz": {Sha1: "da39a3ee5e6b4b0d3255bfef95601890afd80709"},
+						"/extracted-release-path/jobs/cpi.tgz":                           {Sha1: "0a4d55a8d778e5022fab701977c5d840bbc486d0"},
+					})
+					fakeReleaseValidator.ValidateError = bosherr.Error("fake-validate-error")
+				})
+
+				It("returns the archive errors together with the validation errors", func() {
+					_, err := releaseExtractor.Extract(releaseTarballPath)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Validating release 'fake-release-name-fake-release-version'"))
+					Expect(err.Error()).To(ContainSubstring("Verifying package 'fake-release-package-name' archive"))
+					Expect(err.Error()).To(ContainSubstring("fake-validate-error"))
+				})
+
+				It("does not extract the archive", func() {
+					_, err := releaseExtractor.Extract(releaseTarballPath)
+					Expect(err).To(HaveOccurred())
+					Expect(fakeFS.FileExists("/extracted-release-path/extracted_packages/fake-release-package-name")).To(BeFalse())
+				})
+			})
+
 			Context("and the tarball cannot be read", func() {
 				It("returns an error", func() {
 					fakeExtractor.SetDecompressBehavior(releaseTarballPath, "/extracted-release-path", bosherr.Error("fake-error"))
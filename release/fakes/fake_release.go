@@ -34,6 +34,15 @@ func (r *FakeRelease) Jobs() []bireljob.Job { return r.ReleaseJobs }
 
 func (r *FakeRelease) Packages() []*birelpkg.Package { return r.ReleasePackages }
 
+func (r *FakeRelease) IsCompiled() bool {
+	for _, pkg := range r.ReleasePackages {
+		if pkg.IsCompiled() {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *FakeRelease) FindJobByName(jobName string) (bireljob.Job, bool) {
 	for _, job := range r.ReleaseJobs {
 		if job.Name == jobName {
@@ -7,18 +7,36 @@ import (
 
 type Job struct {
 	Name          string
+	Release       string
 	Fingerprint   string
 	SHA1          string
 	ExtractedPath string
+	ArchivePath   string
 	Templates     map[string]string
 	PackageNames  []string
 	Packages      []*birelpkg.Package
 	Properties    map[string]PropertyDefinition
+	Provides      []LinkDefinition
+	Consumes      []LinkDefinition
 }
 
 type PropertyDefinition struct {
 	Description string
 	Default     biproperty.Property
+
+	// Type is informational (e.g. "string", "integer", "boolean", "array" or "hash"); unknown types are not validated
+	Type     string
+	Example  biproperty.Property
+	Required bool
+}
+
+// LinkDefinition is a link provided or consumed by a job.
+// Provided links share the listed properties with the consumers; consumed links may be optional.
+type LinkDefinition struct {
+	Name       string
+	Type       string
+	Optional   bool
+	Properties []string
 }
 
 func (j Job) FindTemplateByValue(value string) (string, bool) {
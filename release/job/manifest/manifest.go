@@ -5,9 +5,21 @@ type Manifest struct {
 	Templates  map[string]string             `yaml:"templates"`
 	Packages   []string                      `yaml:"packages"`
 	Properties map[string]PropertyDefinition `yaml:"properties"`
+	Provides   []LinkDefinition              `yaml:"provides"`
+	Consumes   []LinkDefinition              `yaml:"consumes"`
 }
 
 type PropertyDefinition struct {
 	Description string      `yaml:"description"`
 	Default     interface{} `yaml:"default"`
+	Type        string      `yaml:"type"`
+	Example     interface{} `yaml:"example"`
+	Required    bool        `yaml:"required"`
+}
+
+type LinkDefinition struct {
+	Name       string   `yaml:"name"`
+	Type       string   `yaml:"type"`
+	Optional   bool     `yaml:"optional"`
+	Properties []string `yaml:"properties"`
 }
@@ -71,12 +71,36 @@ func (r *reader) Read() (Job, error) {
 		if err != nil {
 			return Job{}, bosherr.WrapErrorf(err, "Parsing job '%s' property '%s' default: %#v", job.Name, propertyName, rawPropertyDef.Default)
 		}
+		// Examples are documentation only; one that is not a valid property is dropped rather than failing the read
+		exampleValue, err := biproperty.Build(rawPropertyDef.Example)
+		if err != nil {
+			exampleValue = nil
+		}
 		jobProperties[propertyName] = PropertyDefinition{
 			Description: rawPropertyDef.Description,
 			Default:     defaultValue,
+			Type:        rawPropertyDef.Type,
+			Example:     exampleValue,
+			Required:    rawPropertyDef.Required,
 		}
 	}
 	job.Properties = jobProperties
 
+	job.Provides = r.linkDefinitions(jobManifest.Provides)
+	job.Consumes = r.linkDefinitions(jobManifest.Consumes)
+
 	return job, nil
 }
+
+func (r *reader) linkDefinitions(rawLinkDefs []bireljobmanifest.LinkDefinition) []LinkDefinition {
+	var linkDefs []LinkDefinition
+	for _, rawLinkDef := range rawLinkDefs {
+		linkDefs = append(linkDefs, LinkDefinition{
+			Name:       rawLinkDef.Name,
+			Type:       rawLinkDef.Type,
+			Optional:   rawLinkDef.Optional,
+			Properties: rawLinkDef.Properties,
+		})
+	}
+	return linkDefs
+}
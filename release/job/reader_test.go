@@ -42,6 +42,19 @@ properties:
   fake-property:
     description: "Fake description"
     default: "fake-default"
+  fake-typed-property:
+    type: integer
+    example: 8080
+    required: true
+provides:
+- name: fake-provided-link
+  type: fake-link-type
+  properties:
+  - fake-property
+consumes:
+- name: fake-consumed-link
+  type: fake-other-link-type
+  optional: true
 `,
 				)
 			})
@@ -60,6 +73,25 @@ properties:
 								Description: "Fake description",
 								Default:     biproperty.Property("fake-default"),
 							},
+							"fake-typed-property": PropertyDefinition{
+								Type:     "integer",
+								Example:  biproperty.Property(8080),
+								Required: true,
+							},
+						},
+						Provides: []LinkDefinition{
+							{
+								Name:       "fake-provided-link",
+								Type:       "fake-link-type",
+								Properties: []string{"fake-property"},
+							},
+						},
+						Consumes: []LinkDefinition{
+							{
+								Name:     "fake-consumed-link",
+								Type:     "fake-other-link-type",
+								Optional: true,
+							},
 						},
 					},
 				))
@@ -80,6 +112,28 @@ properties:
 				Expect(err.Error()).To(ContainSubstring("Parsing job manifest"))
 			})
 		})
+
+		Context("when a property example is not a valid property", func() {
+			BeforeEach(func() {
+				fakeFs.WriteFileString(
+					"/extracted/job/job.MF",
+					`---
+name: fake-job
+properties:
+  fake-property:
+    example: {1: fake-value}
+`,
+				)
+			})
+
+			It("ignores the example", func() {
+				job, err := reader.Read()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(job.Properties).To(Equal(map[string]PropertyDefinition{
+					"fake-property": PropertyDefinition{},
+				}))
+			})
+		})
 	})
 
 	Context("when the job archive is not a valid tar", func() {
@@ -9,10 +9,13 @@ type Manifest struct {
 
 	Jobs     []JobRef     `yaml:"jobs"`
 	Packages []PackageRef `yaml:"packages"`
+
+	CompiledPackages []CompiledPackageRef `yaml:"compiled_packages"`
 }
 
 type JobRef struct {
 	Name        string `yaml:"name"`
+	Version     string `yaml:"version"`
 	Fingerprint string `yaml:"fingerprint"`
 	SHA1        string `yaml:"sha1"`
 }
@@ -23,3 +26,12 @@ type PackageRef struct {
 	SHA1         string   `yaml:"sha1"`
 	Dependencies []string `yaml:"dependencies"`
 }
+
+type CompiledPackageRef struct {
+	Name         string   `yaml:"name"`
+	Version      string   `yaml:"version"`
+	Fingerprint  string   `yaml:"fingerprint"`
+	SHA1         string   `yaml:"sha1"`
+	Stemcell     string   `yaml:"stemcell"`
+	Dependencies []string `yaml:"dependencies"`
+}
@@ -8,6 +8,9 @@ type ReleaseRef struct {
 	Name string
 	URL  string
 	SHA1 string
+
+	// Mirrors are tried in order when the tarball cannot be downloaded from URL
+	Mirrors []string
 }
 
 func (r ReleaseRef) GetURL() string {
@@ -21,3 +24,7 @@ func (r ReleaseRef) GetSHA1() string {
 func (r ReleaseRef) Description() string {
 	return fmt.Sprintf("release '%s'", r.Name)
 }
+
+func (r ReleaseRef) GetMirrors() []string {
+	return r.Mirrors
+}
@@ -0,0 +1,47 @@
+package pkg
+
+// DependencyCycles returns the dependency cycles between packages, each as the packages of the cycle followed by its first one
+// (e.g. a -> b -> a). Packages in a cycle can not be compiled, since none of them can be compiled first.
+func DependencyCycles(packages []*Package) [][]*Package {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+
+	cycles := [][]*Package{}
+	states := map[*Package]int{}
+	path := []*Package{}
+
+	var visit func(pkg *Package)
+	visit = func(pkg *Package) {
+		states[pkg] = visiting
+		path = append(path, pkg)
+
+		for _, dependency := range pkg.Dependencies {
+			switch states[dependency] {
+			case visiting:
+				for i, pathPkg := range path {
+					if pathPkg == dependency {
+						cycle := append([]*Package{}, path[i:]...)
+						cycles = append(cycles, append(cycle, dependency))
+						break
+					}
+				}
+			case visited:
+			default:
+				visit(dependency)
+			}
+		}
+
+		path = path[:len(path)-1]
+		states[pkg] = visited
+	}
+
+	for _, pkg := range packages {
+		if states[pkg] == 0 {
+			visit(pkg)
+		}
+	}
+
+	return cycles
+}
@@ -0,0 +1,69 @@
+package pkg_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-init/release/pkg"
+)
+
+var _ = Describe("DependencyCycles", func() {
+	var (
+		packageRepo *PackageRepo
+		packages    []*Package
+	)
+
+	var dependsOn = func(pkgName string, dependencyNames ...string) {
+		pkg := packageRepo.FindOrCreatePackage(pkgName)
+		for _, dependencyName := range dependencyNames {
+			pkg.Dependencies = append(pkg.Dependencies, packageRepo.FindOrCreatePackage(dependencyName))
+		}
+		packages = append(packages, pkg)
+	}
+
+	var cycleNames = func(cycles [][]*Package) [][]string {
+		names := [][]string{}
+		for _, cycle := range cycles {
+			cycleNames := []string{}
+			for _, pkg := range cycle {
+				cycleNames = append(cycleNames, pkg.Name)
+			}
+			names = append(names, cycleNames)
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		packageRepo = &PackageRepo{}
+		packages = []*Package{}
+	})
+
+	It("returns no cycles when the dependencies form a tree", func() {
+		dependsOn("fake-package-1", "fake-package-2", "fake-package-3")
+		dependsOn("fake-package-2", "fake-package-3")
+		dependsOn("fake-package-3")
+
+		Expect(DependencyCycles(packages)).To(BeEmpty())
+	})
+
+	It("returns a package that depends on itself", func() {
+		dependsOn("fake-package-1", "fake-package-1")
+
+		Expect(cycleNames(DependencyCycles(packages))).To(Equal([][]string{
+			{"fake-package-1", "fake-package-1"},
+		}))
+	})
+
+	It("returns every cycle between packages", func() {
+		dependsOn("fake-package-1", "fake-package-2")
+		dependsOn("fake-package-2", "fake-package-3")
+		dependsOn("fake-package-3", "fake-package-1")
+		dependsOn("fake-package-4", "fake-package-5")
+		dependsOn("fake-package-5", "fake-package-4")
+
+		Expect(cycleNames(DependencyCycles(packages))).To(Equal([][]string{
+			{"fake-package-1", "fake-package-2", "fake-package-3", "fake-package-1"},
+			{"fake-package-4", "fake-package-5", "fake-package-4"},
+		}))
+	})
+})
@@ -7,6 +7,15 @@ type Package struct {
 	Dependencies  []*Package
 	ExtractedPath string
 	ArchivePath   string
+
+	// Stemcell is the 'os/version' of the stemcell a compiled package was compiled against.
+	// It is empty for source packages.
+	Stemcell string
+}
+
+// IsCompiled returns true if the archive of the package contains compiled bits instead of source
+func (p Package) IsCompiled() bool {
+	return p.Stemcell != ""
 }
 
 func (p Package) String() string {
@@ -9,6 +9,7 @@ import (
 	boshsys "github.com/cloudfoundry/bosh-agent/system"
 
 	boshcmd "github.com/cloudfoundry/bosh-agent/platform/commands"
+	bicrypto "github.com/cloudfoundry/bosh-init/crypto"
 	bireljob "github.com/cloudfoundry/bosh-init/release/job"
 	birelmanifest "github.com/cloudfoundry/bosh-init/release/manifest"
 	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
@@ -19,23 +20,37 @@ type reader struct {
 	extractedReleasePath string
 	fs                   boshsys.FileSystem
 	extractor            boshcmd.Compressor
+	digestCalculator     bicrypto.DigestCalculator
 }
 
 type Reader interface {
 	Read() (Release, error)
 }
 
+// ArchiveDigestError is returned by Read together with the release when job or package archives do not match their
+// digest in release.MF. Those archives are not extracted: their jobs are left out of the release and their packages
+// have no ExtractedPath.
+type ArchiveDigestError struct {
+	Errs []error
+}
+
+func (e ArchiveDigestError) Error() string {
+	return bosherr.NewMultiError(e.Errs...).Error()
+}
+
 func NewReader(
 	tarFilePath string,
 	extractedReleasePath string,
 	fs boshsys.FileSystem,
 	extractor boshcmd.Compressor,
+	digestCalculator bicrypto.DigestCalculator,
 ) Reader {
 	return &reader{
 		tarFilePath:          tarFilePath,
 		extractedReleasePath: extractedReleasePath,
 		fs:                   fs,
 		extractor:            extractor,
+		digestCalculator:     digestCalculator,
 	}
 }
 
@@ -58,6 +73,9 @@ func (r *reader) Read() (Release, error) {
 	}
 
 	release, err := r.newReleaseFromManifest(manifest)
+	if _, ok := err.(ArchiveDigestError); ok {
+		return release, err
+	}
 	if err != nil {
 		return nil, bosherr.WrapError(err, "Constructing release from manifest")
 	}
@@ -67,18 +85,28 @@ func (r *reader) Read() (Release, error) {
 
 func (r *reader) newReleaseFromManifest(releaseManifest birelmanifest.Manifest) (Release, error) {
 	errors := []error{}
-	packages, err := r.newPackagesFromManifestPackages(releaseManifest.Packages)
+	var (
+		packages    []*birelpkg.Package
+		archiveErrs []error
+		err         error
+	)
+	if len(releaseManifest.CompiledPackages) > 0 {
+		packages, archiveErrs, err = r.newPackagesFromManifestCompiledPackages(releaseManifest.CompiledPackages)
+	} else {
+		packages, archiveErrs, err = r.newPackagesFromManifestPackages(releaseManifest.Packages)
+	}
 	if err != nil {
 		errors = append(errors, bosherr.WrapError(err, "Constructing packages from manifest"))
 	}
 
-	jobs, err := r.newJobsFromManifestJobs(packages, releaseManifest.Jobs)
+	jobs, jobArchiveErrs, err := r.newJobsFromManifestJobs(releaseManifest.Name, packages, releaseManifest.Jobs)
 	if err != nil {
 		errors = append(errors, bosherr.WrapError(err, "Constructing jobs from manifest"))
 	}
+	archiveErrs = append(archiveErrs, jobArchiveErrs...)
 
 	if len(errors) > 0 {
-		return nil, bosherr.NewMultiError(errors...)
+		return nil, bosherr.NewMultiError(append(errors, archiveErrs...)...)
 	}
 
 	release := &release{
@@ -92,21 +120,33 @@ func (r *reader) newReleaseFromManifest(releaseManifest birelmanifest.Manifest)
 		fs:            r.fs,
 	}
 
+	if len(archiveErrs) > 0 {
+		return release, ArchiveDigestError{Errs: archiveErrs}
+	}
+
 	return release, nil
 }
 
-func (r *reader) newJobsFromManifestJobs(packages []*birelpkg.Package, manifestJobs []birelmanifest.JobRef) ([]bireljob.Job, error) {
+// newJobsFromManifestJobs also returns the errors of the job archives that do not match release.MF, which are not extracted
+func (r *reader) newJobsFromManifestJobs(releaseName string, packages []*birelpkg.Package, manifestJobs []birelmanifest.JobRef) ([]bireljob.Job, []error, error) {
 	jobs := []bireljob.Job{}
+	archiveErrs := []error{}
 	errors := []error{}
 	for _, manifestJob := range manifestJobs {
+		jobArchivePath := path.Join(r.extractedReleasePath, "jobs", manifestJob.Name+".tgz")
+		err := r.verifyArchiveDigest(jobArchivePath, manifestJob.SHA1)
+		if err != nil {
+			archiveErrs = append(archiveErrs, bosherr.WrapErrorf(err, "Verifying job '%s' archive", manifestJob.Name))
+			continue
+		}
+
 		extractedJobPath := path.Join(r.extractedReleasePath, "extracted_jobs", manifestJob.Name)
-		err := r.fs.MkdirAll(extractedJobPath, os.ModeDir|0700)
+		err = r.fs.MkdirAll(extractedJobPath, os.ModeDir|0700)
 		if err != nil {
 			errors = append(errors, bosherr.WrapError(err, "Creating extracted job path"))
 			continue
 		}
 
-		jobArchivePath := path.Join(r.extractedReleasePath, "jobs", manifestJob.Name+".tgz")
 		jobReader := bireljob.NewReader(jobArchivePath, extractedJobPath, r.extractor, r.fs)
 		job, err := jobReader.Read()
 		if err != nil {
@@ -114,12 +154,14 @@ func (r *reader) newJobsFromManifestJobs(packages []*birelpkg.Package, manifestJ
 			continue
 		}
 
+		job.Release = releaseName
 		job.Fingerprint = manifestJob.Fingerprint
 		job.SHA1 = manifestJob.SHA1
+		job.ArchivePath = jobArchivePath
 		for _, pkgName := range job.PackageNames {
 			pkg, found := r.findPackageByName(packages, pkgName)
 			if !found {
-				return []bireljob.Job{}, bosherr.Errorf("Package not found: '%s'", pkgName)
+				return []bireljob.Job{}, archiveErrs, bosherr.Errorf("Package not found: '%s'", pkgName)
 			}
 			job.Packages = append(job.Packages, pkg)
 		}
@@ -128,10 +170,10 @@ func (r *reader) newJobsFromManifestJobs(packages []*birelpkg.Package, manifestJ
 	}
 
 	if len(errors) > 0 {
-		return []bireljob.Job{}, bosherr.NewMultiError(errors...)
+		return []bireljob.Job{}, archiveErrs, bosherr.NewMultiError(errors...)
 	}
 
-	return jobs, nil
+	return jobs, archiveErrs, nil
 }
 
 func (r *reader) findPackageByName(packages []*birelpkg.Package, pkgName string) (*birelpkg.Package, bool) {
@@ -143,31 +185,84 @@ func (r *reader) findPackageByName(packages []*birelpkg.Package, pkgName string)
 	return nil, false
 }
 
-func (r *reader) newPackagesFromManifestPackages(manifestPackages []birelmanifest.PackageRef) ([]*birelpkg.Package, error) {
+// newPackagesFromManifestPackages also returns the errors of the package archives that do not match release.MF, which are not extracted
+func (r *reader) newPackagesFromManifestPackages(manifestPackages []birelmanifest.PackageRef) ([]*birelpkg.Package, []error, error) {
 	packages := []*birelpkg.Package{}
+	archiveErrs := []error{}
 	errors := []error{}
 	packageRepo := &birelpkg.PackageRepo{}
 
 	for _, manifestPackage := range manifestPackages {
 		pkg := packageRepo.FindOrCreatePackage(manifestPackage.Name)
 
-		extractedPackagePath := path.Join(r.extractedReleasePath, "extracted_packages", manifestPackage.Name)
-		err := r.fs.MkdirAll(extractedPackagePath, os.ModeDir|0700)
+		packageArchivePath := path.Join(r.extractedReleasePath, "packages", manifestPackage.Name+".tgz")
+		err := r.verifyArchiveDigest(packageArchivePath, manifestPackage.SHA1)
 		if err != nil {
-			errors = append(errors, bosherr.WrapError(err, "Creating extracted package path"))
+			archiveErrs = append(archiveErrs, bosherr.WrapErrorf(err, "Verifying package '%s' archive", manifestPackage.Name))
+		} else {
+			extractedPackagePath := path.Join(r.extractedReleasePath, "extracted_packages", manifestPackage.Name)
+			err = r.fs.MkdirAll(extractedPackagePath, os.ModeDir|0700)
+			if err != nil {
+				errors = append(errors, bosherr.WrapError(err, "Creating extracted package path"))
+				continue
+			}
+			err = r.extractor.DecompressFileToDir(packageArchivePath, extractedPackagePath, boshcmd.CompressorOptions{})
+			if err != nil {
+				errors = append(errors, bosherr.WrapErrorf(err, "Extracting package '%s'", manifestPackage.Name))
+				continue
+			}
+			pkg.ExtractedPath = extractedPackagePath
+		}
+
+		pkg.Fingerprint = manifestPackage.Fingerprint
+		pkg.SHA1 = manifestPackage.SHA1
+		pkg.ArchivePath = packageArchivePath
+
+		pkg.Dependencies = []*birelpkg.Package{}
+		for _, manifestPackageName := range manifestPackage.Dependencies {
+			pkg.Dependencies = append(pkg.Dependencies, packageRepo.FindOrCreatePackage(manifestPackageName))
+		}
+
+		packages = append(packages, pkg)
+	}
+
+	if len(errors) > 0 {
+		return []*birelpkg.Package{}, archiveErrs, bosherr.NewMultiError(errors...)
+	}
+
+	return packages, archiveErrs, nil
+}
+
+// newPackagesFromManifestCompiledPackages constructs the packages of a compiled release.
+// Compiled package archives are uploaded to the blobstore as is, so they are not extracted.
+func (r *reader) newPackagesFromManifestCompiledPackages(manifestPackages []birelmanifest.CompiledPackageRef) ([]*birelpkg.Package, []error, error) {
+	packages := []*birelpkg.Package{}
+	archiveErrs := []error{}
+	errors := []error{}
+	packageRepo := &birelpkg.PackageRepo{}
+
+	for _, manifestPackage := range manifestPackages {
+		pkg := packageRepo.FindOrCreatePackage(manifestPackage.Name)
+
+		packageArchivePath := path.Join(r.extractedReleasePath, "compiled_packages", manifestPackage.Name+".tgz")
+		if !r.fs.FileExists(packageArchivePath) {
+			errors = append(errors, bosherr.Errorf("Compiled package '%s' archive '%s' does not exist", manifestPackage.Name, packageArchivePath))
 			continue
 		}
-		packageArchivePath := path.Join(r.extractedReleasePath, "packages", manifestPackage.Name+".tgz")
-		err = r.extractor.DecompressFileToDir(packageArchivePath, extractedPackagePath, boshcmd.CompressorOptions{})
+
+		err := r.verifyArchiveDigest(packageArchivePath, manifestPackage.SHA1)
 		if err != nil {
-			errors = append(errors, bosherr.WrapErrorf(err, "Extracting package '%s'", manifestPackage.Name))
-			continue
+			archiveErrs = append(archiveErrs, bosherr.WrapErrorf(err, "Verifying compiled package '%s' archive", manifestPackage.Name))
 		}
 
+		// the version of a compiled package is its fingerprint
 		pkg.Fingerprint = manifestPackage.Fingerprint
+		if pkg.Fingerprint == "" {
+			pkg.Fingerprint = manifestPackage.Version
+		}
 		pkg.SHA1 = manifestPackage.SHA1
-		pkg.ExtractedPath = extractedPackagePath
 		pkg.ArchivePath = packageArchivePath
+		pkg.Stemcell = manifestPackage.Stemcell
 
 		pkg.Dependencies = []*birelpkg.Package{}
 		for _, manifestPackageName := range manifestPackage.Dependencies {
@@ -178,8 +273,32 @@ func (r *reader) newPackagesFromManifestPackages(manifestPackages []birelmanifes
 	}
 
 	if len(errors) > 0 {
-		return []*birelpkg.Package{}, bosherr.NewMultiError(errors...)
+		return []*birelpkg.Package{}, archiveErrs, bosherr.NewMultiError(errors...)
+	}
+
+	return packages, archiveErrs, nil
+}
+
+// verifyArchiveDigest verifies that a job or package archive matches its sha1 (or algorithm-prefixed digest) in release.MF,
+// before the archive is extracted. Archives without a sha1 are left to the validator, which reports it missing.
+func (r *reader) verifyArchiveDigest(archivePath string, expectedSHA1 string) error {
+	if expectedSHA1 == "" {
+		return nil
+	}
+
+	expectedDigest, err := bicrypto.ParseDigest(expectedSHA1)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Parsing sha1 '%s'", expectedSHA1)
+	}
+
+	digest, err := r.digestCalculator.Calculate(archivePath, expectedDigest.Algorithm)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Calculating %s of '%s'", expectedDigest.Algorithm, archivePath)
+	}
+
+	if digest != expectedDigest.Value {
+		return bosherr.Errorf("Archive '%s' has %s '%s', but release.MF declares '%s'", archivePath, expectedDigest.Algorithm, digest, expectedDigest.Value)
 	}
 
-	return packages, nil
+	return nil
 }
@@ -10,6 +10,7 @@ import (
 
 	fakesys "github.com/cloudfoundry/bosh-agent/system/fakes"
 
+	fakebicrypto "github.com/cloudfoundry/bosh-init/crypto/fakes"
 	bireljob "github.com/cloudfoundry/bosh-init/release/job"
 	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
 
@@ -18,15 +19,24 @@ import (
 
 var _ = Describe("tarReader", func() {
 	var (
-		reader        Reader
-		fakeFs        *fakesys.FakeFileSystem
-		fakeExtractor *testfakes.FakeMultiResponseExtractor
+		reader               Reader
+		fakeFs               *fakesys.FakeFileSystem
+		fakeExtractor        *testfakes.FakeMultiResponseExtractor
+		fakeDigestCalculator *fakebicrypto.FakeDigestCalculator
 	)
 
 	BeforeEach(func() {
 		fakeFs = fakesys.NewFakeFileSystem()
 		fakeExtractor = testfakes.NewFakeMultiResponseExtractor()
-		reader = NewReader("/some/release.tgz", "/extracted/release", fakeFs, fakeExtractor)
+		fakeDigestCalculator = fakebicrypto.NewFakeDigestCalculator()
+		fakeDigestCalculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+			"/extracted/release/jobs/fake-job.tgz":                    {Sha1: "0a4d55a8d778e5022fab701977c5d840bbc486d0"},
+			"/extracted/release/jobs/fake-job-2.tgz":                  {Sha1: "2fd4e1c67a2d28fced849ee1bb76e7391b93eb12"},
+			"/extracted/release/packages/fake-package.tgz":            {Sha1: "4603db250d7b5b78dfe17869649784353177b549"},
+			"/extracted/release/compiled_packages/fake-package.tgz":   {Sha1: "4603db250d7b5b78dfe17869649784353177b549"},
+			"/extracted/release/compiled_packages/fake-package-1.tgz": {Sha1: "da39a3ee5e6b4b0d3255bfef95601890afd80709"},
+		})
+		reader = NewReader("/some/release.tgz", "/extracted/release", fakeFs, fakeExtractor, fakeDigestCalculator)
 	})
 
 	Describe("Read", func() {
@@ -46,13 +56,13 @@ jobs:
 - name: fake-job
   version: fake-job-version
   fingerprint: fake-job-fingerprint
-  sha1: fake-job-sha
+  sha1: 0a4d55a8d778e5022fab701977c5d840bbc486d0
 
 packages:
 - name: fake-package
   version: fake-package-version
   fingerprint: fake-package-fingerprint
-  sha1: fake-package-sha
+  sha1: 4603db250d7b5b78dfe17869649784353177b549
   dependencies:
   - fake-package-1
 `,
@@ -81,7 +91,7 @@ packages:
 							expectedPackage := &birelpkg.Package{
 								Name:          "fake-package",
 								Fingerprint:   "fake-package-fingerprint",
-								SHA1:          "fake-package-sha",
+								SHA1:          "4603db250d7b5b78dfe17869649784353177b549",
 								Dependencies:  []*birelpkg.Package{&birelpkg.Package{Name: "fake-package-1"}},
 								ExtractedPath: "/extracted/release/extracted_packages/fake-package",
 								ArchivePath:   "/extracted/release/packages/fake-package.tgz",
@@ -91,9 +101,11 @@ packages:
 							Expect(release.Jobs()).To(Equal([]bireljob.Job{
 								{
 									Name:          "fake-job",
+									Release:       "fake-release",
 									Fingerprint:   "fake-job-fingerprint",
-									SHA1:          "fake-job-sha",
+									SHA1:          "0a4d55a8d778e5022fab701977c5d840bbc486d0",
 									ExtractedPath: "/extracted/release/extracted_jobs/fake-job",
+									ArchivePath:   "/extracted/release/jobs/fake-job.tgz",
 									Templates:     map[string]string{"some_template": "some_file"},
 									PackageNames:  []string{"fake-package"},
 									Packages:      []*birelpkg.Package{expectedPackage},
@@ -104,6 +116,52 @@ packages:
 						})
 					})
 
+					Context("when the archives do not match release.MF", func() {
+						BeforeEach(func() {
+							fakeDigestCalculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+								"/extracted/release/jobs/fake-job.tgz":         {Sha1: "2fd4e1c67a2d28fced849ee1bb76e7391b93eb12"},
+								"/extracted/release/packages/fake-package.tgz": {Sha1: "da39a3ee5e6b4b0d3255bfef95601890afd80709"},
+							})
+						})
+
+						It("returns the release with an error for each archive, without extracting them", func() {
+							release, err := reader.Read()
+							Expect(err).To(HaveOccurred())
+							Expect(err).To(BeAssignableToTypeOf(ArchiveDigestError{}))
+							Expect(err.Error()).To(ContainSubstring("Verifying job 'fake-job' archive: Archive '/extracted/release/jobs/fake-job.tgz' has sha1 '2fd4e1c67a2d28fced849ee1bb76e7391b93eb12', but release.MF declares '0a4d55a8d778e5022fab701977c5d840bbc486d0'"))
+							Expect(err.Error()).To(ContainSubstring("Verifying package 'fake-package' archive: Archive '/extracted/release/packages/fake-package.tgz' has sha1 'da39a3ee5e6b4b0d3255bfef95601890afd80709', but release.MF declares '4603db250d7b5b78dfe17869649784353177b549'"))
+
+							Expect(release.Name()).To(Equal("fake-release"))
+							Expect(release.Jobs()).To(BeEmpty())
+							Expect(release.Packages()).To(Equal([]*birelpkg.Package{
+								{
+									Name:         "fake-package",
+									Fingerprint:  "fake-package-fingerprint",
+									SHA1:         "4603db250d7b5b78dfe17869649784353177b549",
+									Dependencies: []*birelpkg.Package{&birelpkg.Package{Name: "fake-package-1"}},
+									ArchivePath:  "/extracted/release/packages/fake-package.tgz",
+								},
+							}))
+							Expect(fakeExtractor.DecompressedFiles()).To(Equal([]string{"/extracted/release//some/release.tgz"}))
+							Expect(fakeFs.FileExists("/extracted/release/extracted_packages/fake-package")).To(BeFalse())
+						})
+					})
+
+					Context("when the digest of an archive cannot be calculated", func() {
+						BeforeEach(func() {
+							fakeDigestCalculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+								"/extracted/release/jobs/fake-job.tgz":         {Sha1: "0a4d55a8d778e5022fab701977c5d840bbc486d0"},
+								"/extracted/release/packages/fake-package.tgz": {Err: errors.New("fake-calculate-error")},
+							})
+						})
+
+						It("returns an error for the archive", func() {
+							_, err := reader.Read()
+							Expect(err).To(HaveOccurred())
+							Expect(err.Error()).To(ContainSubstring("Calculating sha1 of '/extracted/release/packages/fake-package.tgz': fake-calculate-error"))
+						})
+					})
+
 					Context("when the package cannot be extracted", func() {
 						BeforeEach(func() {
 							fakeExtractor.SetDecompressBehavior("/some/release.tgz", "/extracted/release", errors.New("Extracting package 'fake-package'"))
@@ -129,17 +187,17 @@ jobs:
 - name: fake-job
   version: fake-job-version
   fingerprint: fake-job-fingerprint
-  sha1: fake-job-sha
+  sha1: 0a4d55a8d778e5022fab701977c5d840bbc486d0
 - name: fake-job-2
   version: fake-job-2-version
   fingerprint: fake-job-2-fingerprint
-  sha1: fake-job-2-sha
+  sha1: 2fd4e1c67a2d28fced849ee1bb76e7391b93eb12
 
 packages:
 - name: fake-package
   version: fake-package-version
   fingerprint: fake-package-fingerprint
-  sha1: fake-package-sha
+  sha1: 4603db250d7b5b78dfe17869649784353177b549
   dependencies:
   - fake-package-1
 `,
@@ -186,6 +244,105 @@ packages:
 				})
 			})
 
+			Context("when the release is compiled", func() {
+				BeforeEach(func() {
+					fakeFs.WriteFileString(
+						"/extracted/release/release.MF",
+						`---
+name: fake-release
+version: fake-version
+
+jobs:
+- name: fake-job
+  version: fake-job-version
+  fingerprint: fake-job-fingerprint
+  sha1: 0a4d55a8d778e5022fab701977c5d840bbc486d0
+
+compiled_packages:
+- name: fake-package
+  version: fake-package-version
+  fingerprint: fake-package-fingerprint
+  sha1: 4603db250d7b5b78dfe17869649784353177b549
+  stemcell: ubuntu-trusty/3012
+  dependencies:
+  - fake-package-1
+- name: fake-package-1
+  version: fake-package-1-version
+  sha1: da39a3ee5e6b4b0d3255bfef95601890afd80709
+  stemcell: ubuntu-trusty/3012
+  dependencies: []
+`,
+					)
+					fakeFs.WriteFileString(
+						"/extracted/release/extracted_jobs/fake-job/job.MF",
+						`---
+name: fake-job
+templates:
+  some_template: some_file
+packages:
+- fake-package
+`,
+					)
+					fakeFs.WriteFileString("/extracted/release/compiled_packages/fake-package.tgz", "fake-compiled-package")
+					fakeFs.WriteFileString("/extracted/release/compiled_packages/fake-package-1.tgz", "fake-compiled-package-1")
+				})
+
+				It("returns a release with the compiled packages, without extracting them", func() {
+					release, err := reader.Read()
+					Expect(err).NotTo(HaveOccurred())
+
+					expectedDependency := &birelpkg.Package{
+						Name:         "fake-package-1",
+						Fingerprint:  "fake-package-1-version",
+						SHA1:         "da39a3ee5e6b4b0d3255bfef95601890afd80709",
+						Dependencies: []*birelpkg.Package{},
+						ArchivePath:  "/extracted/release/compiled_packages/fake-package-1.tgz",
+						Stemcell:     "ubuntu-trusty/3012",
+					}
+					expectedPackage := &birelpkg.Package{
+						Name:         "fake-package",
+						Fingerprint:  "fake-package-fingerprint",
+						SHA1:         "4603db250d7b5b78dfe17869649784353177b549",
+						Dependencies: []*birelpkg.Package{expectedDependency},
+						ArchivePath:  "/extracted/release/compiled_packages/fake-package.tgz",
+						Stemcell:     "ubuntu-trusty/3012",
+					}
+					Expect(release.Packages()).To(Equal([]*birelpkg.Package{expectedPackage, expectedDependency}))
+					Expect(release.Jobs()[0].Packages).To(Equal([]*birelpkg.Package{expectedPackage}))
+					Expect(release.IsCompiled()).To(BeTrue())
+					Expect(fakeFs.FileExists("/extracted/release/extracted_packages")).To(BeFalse())
+				})
+
+				Context("when a compiled package archive does not match release.MF", func() {
+					BeforeEach(func() {
+						fakeDigestCalculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+							"/extracted/release/jobs/fake-job.tgz":                    {Sha1: "0a4d55a8d778e5022fab701977c5d840bbc486d0"},
+							"/extracted/release/compiled_packages/fake-package.tgz":   {Sha1: "4603db250d7b5b78dfe17869649784353177b549"},
+							"/extracted/release/compiled_packages/fake-package-1.tgz": {Sha1: "4603db250d7b5b78dfe17869649784353177b549"},
+						})
+					})
+
+					It("returns the release with an error for the archive", func() {
+						release, err := reader.Read()
+						Expect(err).To(BeAssignableToTypeOf(ArchiveDigestError{}))
+						Expect(err.Error()).To(ContainSubstring("Verifying compiled package 'fake-package-1' archive: Archive '/extracted/release/compiled_packages/fake-package-1.tgz' has sha1 '4603db250d7b5b78dfe17869649784353177b549', but release.MF declares 'da39a3ee5e6b4b0d3255bfef95601890afd80709'"))
+						Expect(release.Packages()).To(HaveLen(2))
+					})
+				})
+
+				Context("when a compiled package archive is missing", func() {
+					BeforeEach(func() {
+						fakeFs.RemoveAll("/extracted/release/compiled_packages/fake-package-1.tgz")
+					})
+
+					It("returns an error", func() {
+						_, err := reader.Read()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("Compiled package 'fake-package-1' archive '/extracted/release/compiled_packages/fake-package-1.tgz' does not exist"))
+					})
+				})
+			})
+
 			Context("when the job refers to a package that does not exist", func() {
 				It("returns error", func() {
 					releaseMFContents :=
@@ -200,7 +357,7 @@ jobs:
 - name: fake-job
 version: fake-job-version
 fingerprint: fake-job-fingerprint
-sha1: fake-job-sha
+sha1: 0a4d55a8d778e5022fab701977c5d840bbc486d0
 `
 					fakeFs.WriteFileString("/extracted/release/release.MF", releaseMFContents)
 					jobMFContents :=
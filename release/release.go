@@ -21,6 +21,7 @@ type Release interface {
 	Version() string
 	Jobs() []bireljob.Job
 	Packages() []*birelpkg.Package
+	IsCompiled() bool
 	FindJobByName(jobName string) (job bireljob.Job, found bool)
 	Delete() error
 	Exists() bool
@@ -52,6 +53,16 @@ func (r *release) Jobs() []bireljob.Job { return r.jobs }
 
 func (r *release) Packages() []*birelpkg.Package { return r.packages }
 
+// IsCompiled returns true if the release packages were compiled against a stemcell
+func (r *release) IsCompiled() bool {
+	for _, pkg := range r.packages {
+		if pkg.IsCompiled() {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *release) FindJobByName(jobName string) (bireljob.Job, bool) {
 	for _, job := range r.jobs {
 		if job.Name == jobName {
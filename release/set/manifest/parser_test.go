@@ -60,8 +60,10 @@ releases:
   url: file://~/fake-release-1.tgz
   sha1: fake-sha1
 - name: fake-release-name-2
-  url: file://fake-release-2.tgz
+  url: https://fake-release-2.tgz
   sha1: fake-sha2
+  mirrors:
+  - https://fake-mirror/fake-release-2.tgz
 name: unknown-keys-are-ignored
 `
 		fakeFs.WriteFileString(comboManifestPath, contents)
@@ -79,9 +81,10 @@ name: unknown-keys-are-ignored
 					SHA1: "fake-sha1",
 				},
 				{
-					Name: "fake-release-name-2",
-					URL:  "file://fake-release-2.tgz",
-					SHA1: "fake-sha2",
+					Name:    "fake-release-name-2",
+					URL:     "https://fake-release-2.tgz",
+					SHA1:    "fake-sha2",
+					Mirrors: []string{"https://fake-mirror/fake-release-2.tgz"},
 				},
 			},
 		}))
@@ -6,6 +6,8 @@ import (
 
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	bicrypto "github.com/cloudfoundry/bosh-init/crypto"
 )
 
 type Validator interface {
@@ -43,14 +45,31 @@ func (v *validator) Validate(manifest Manifest) error {
 			errs = append(errs, bosherr.Errorf("releases[%d].url must be provided", releaseIdx))
 		}
 
-		matched, err := regexp.MatchString("^(file|http|https)://", release.URL)
+		matched, err := regexp.MatchString("^(file|dir|http|https)://", release.URL)
 		if err != nil || !matched {
-			errs = append(errs, bosherr.Errorf("releases[%d].url must be a valid URL (file:// or http(s)://)", releaseIdx))
+			errs = append(errs, bosherr.Errorf("releases[%d].url must be a valid URL (file://, dir:// or http(s)://)", releaseIdx))
 		}
 
 		if strings.HasPrefix(release.URL, "http") && v.isBlank(release.SHA1) {
 			errs = append(errs, bosherr.Errorf("releases[%d].sha1 must be provided for http URL", releaseIdx))
 		}
+
+		if !v.isBlank(release.SHA1) {
+			if _, err := bicrypto.ParseDigest(release.SHA1); err != nil {
+				errs = append(errs, bosherr.Errorf("releases[%d].sha1 must be a sha1 or an algorithm-prefixed sha256/sha512 digest (e.g. 'sha256:<hex>')", releaseIdx))
+			}
+		}
+
+		if len(release.Mirrors) > 0 && !strings.HasPrefix(release.URL, "http") {
+			errs = append(errs, bosherr.Errorf("releases[%d].mirrors can only be used with an http(s) URL", releaseIdx))
+		}
+
+		for mirrorIdx, mirror := range release.Mirrors {
+			matched, err := regexp.MatchString("^(http|https)://", mirror)
+			if err != nil || !matched {
+				errs = append(errs, bosherr.Errorf("releases[%d].mirrors[%d] must be a valid http(s):// URL", releaseIdx, mirrorIdx))
+			}
+		}
 	}
 
 	if len(errs) > 0 {
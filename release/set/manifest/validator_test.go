@@ -87,8 +87,8 @@ var _ = Describe("Validator", func() {
 			manifest := Manifest{
 				Releases: []birelmanifest.ReleaseRef{
 					{Name: "fake-release-name-1", URL: "file://fake-file"},
-					{Name: "fake-release-name-2", URL: "http://fake-http", SHA1: "fake-sha1"},
-					{Name: "fake-release-name-3", URL: "https://fake-https", SHA1: "fake-sha2"},
+					{Name: "fake-release-name-2", URL: "http://fake-http", SHA1: "4603db250d7b5b78dfe17869649784353177b549"},
+					{Name: "fake-release-name-3", URL: "https://fake-https", SHA1: "5bd3fd3bf1e4b15a1d5e8f3d6ce39a4c93bc7e91"},
 				},
 			}
 
@@ -108,6 +108,47 @@ var _ = Describe("Validator", func() {
 			Expect(err.Error()).To(ContainSubstring("releases[0].sha1 must be provided for http URL"))
 		})
 
+		It("validates release sha1 digests use a supported algorithm", func() {
+			manifest := Manifest{
+				Releases: []birelmanifest.ReleaseRef{
+					{Name: "fake-release-name", URL: "http://fake-url", SHA1: "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+				},
+			}
+
+			err := validator.Validate(manifest)
+			Expect(err).ToNot(HaveOccurred())
+
+			manifest.Releases[0].SHA1 = "md5:fake-md5"
+
+			err = validator.Validate(manifest)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("releases[0].sha1 must be a sha1 or an algorithm-prefixed sha256/sha512 digest (e.g. 'sha256:<hex>')"))
+		})
+
+		It("validates release mirrors are http(s) URLs of an http(s) release", func() {
+			manifest := Manifest{
+				Releases: []birelmanifest.ReleaseRef{
+					{Name: "fake-release-name", URL: "http://fake-url", SHA1: "4603db250d7b5b78dfe17869649784353177b549", Mirrors: []string{"https://fake-mirror-url"}},
+				},
+			}
+
+			err := validator.Validate(manifest)
+			Expect(err).ToNot(HaveOccurred())
+
+			manifest.Releases[0].Mirrors = []string{"https://fake-mirror-url", "file://fake-mirror-path"}
+
+			err = validator.Validate(manifest)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("releases[0].mirrors[1] must be a valid http(s):// URL"))
+
+			manifest.Releases[0].URL = "file://fake-path"
+			manifest.Releases[0].Mirrors = []string{"https://fake-mirror-url"}
+
+			err = validator.Validate(manifest)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("releases[0].mirrors can only be used with an http(s) URL"))
+		})
+
 		It("validates releases have valid urls", func() {
 			manifest := Manifest{
 				Releases: []birelmanifest.ReleaseRef{
@@ -117,7 +158,18 @@ var _ = Describe("Validator", func() {
 
 			err := validator.Validate(manifest)
 			Expect(err).To(HaveOccurred())
-			Expect(err.Error()).To(ContainSubstring("releases[0].url must be a valid URL (file:// or http(s)://)"))
+			Expect(err.Error()).To(ContainSubstring("releases[0].url must be a valid URL (file://, dir:// or http(s)://)"))
+		})
+
+		It("allows releases to be built from a release directory", func() {
+			manifest := Manifest{
+				Releases: []birelmanifest.ReleaseRef{
+					{Name: "fake-release-name", URL: "dir://fake-release-dir"},
+				},
+			}
+
+			err := validator.Validate(manifest)
+			Expect(err).ToNot(HaveOccurred())
 		})
 
 		It("validates releases are unique", func() {
@@ -4,9 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"path"
+	"strings"
 
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 	boshsys "github.com/cloudfoundry/bosh-agent/system"
+
+	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
 )
 
 type Validator interface {
@@ -18,9 +21,15 @@ type validator struct {
 }
 
 func NewValidator(fs boshsys.FileSystem) Validator {
-	return &validator{fs: fs}
+	return &validator{
+		fs: fs,
+	}
 }
 
+// Validate returns all the problems of the release together: missing fields and files, and package dependency cycles.
+// Job and package archive digests are verified by the Reader before the archives are extracted.
+// Fingerprints are not recomputed from content: that requires the fingerprint algorithm of the bosh CLI,
+// which bosh-init does not implement, so the fingerprints in release.MF are trusted as is.
 func (v *validator) Validate(release Release) error {
 	errs := []error{}
 
@@ -131,6 +140,14 @@ func (v *validator) validateReleasePackages(release Release) error {
 		}
 	}
 
+	for _, cycle := range birelpkg.DependencyCycles(release.Packages()) {
+		pkgNames := []string{}
+		for _, pkg := range cycle {
+			pkgNames = append(pkgNames, pkg.Name)
+		}
+		errs = append(errs, fmt.Errorf("Packages have a dependency cycle: %s", strings.Join(pkgNames, " -> ")))
+	}
+
 	if len(errs) > 0 {
 		return bosherr.NewMultiError(errs...)
 	}
@@ -13,7 +13,9 @@ import (
 )
 
 var _ = Describe("Validator", func() {
-	var fakeFs *fakesys.FakeFileSystem
+	var (
+		fakeFs *fakesys.FakeFileSystem
+	)
 
 	BeforeEach(func() {
 		fakeFs = fakesys.NewFakeFileSystem()
@@ -195,4 +197,19 @@ var _ = Describe("Validator", func() {
 			Expect(err.Error()).To(ContainSubstring("Job 'fake-job-2' requires 'fake-package-2' which is not in the release"))
 		})
 	})
+
+	Context("when packages have dependency cycles", func() {
+		It("returns an error with each cycle", func() {
+			package1 := &birelpkg.Package{Name: "fake-package-1", Fingerprint: "fake-fingerprint-1", SHA1: "fake-sha-1"}
+			package2 := &birelpkg.Package{Name: "fake-package-2", Fingerprint: "fake-fingerprint-2", SHA1: "fake-sha-2"}
+			package1.Dependencies = []*birelpkg.Package{package2}
+			package2.Dependencies = []*birelpkg.Package{package1}
+			release := NewRelease("fake-release-name", "fake-release-version", []bireljob.Job{}, []*birelpkg.Package{package1, package2}, "/some/release/path", fakeFs)
+			validator := NewValidator(fakeFs)
+
+			err := validator.Validate(release)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Packages have a dependency cycle: fake-package-1 -> fake-package-2 -> fake-package-1"))
+		})
+	})
 })
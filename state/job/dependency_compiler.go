@@ -21,7 +21,7 @@ type CompiledPackageRef struct {
 }
 
 type DependencyCompiler interface {
-	Compile(releaseJobs []bireljob.Job, stage biui.Stage) ([]CompiledPackageRef, error)
+	Compile(releaseJobs []bireljob.Job, maxWorkers int, stage biui.Stage) ([]CompiledPackageRef, error)
 }
 
 type dependencyCompiler struct {
@@ -38,14 +38,15 @@ func NewDependencyCompiler(packageCompiler bistatepkg.Compiler, logger boshlog.L
 	}
 }
 
-// Compile resolves and compiles all transitive dependencies of multiple release jobs
-func (c *dependencyCompiler) Compile(releaseJobs []bireljob.Job, stage biui.Stage) ([]CompiledPackageRef, error) {
+// Compile resolves and compiles all transitive dependencies of multiple release jobs,
+// compiling up to maxWorkers packages at a time once their own dependencies are compiled
+func (c *dependencyCompiler) Compile(releaseJobs []bireljob.Job, maxWorkers int, stage biui.Stage) ([]CompiledPackageRef, error) {
 	compileOrderReleasePackages, err := c.resolveJobCompilationDependencies(releaseJobs)
 	if err != nil {
 		return nil, bosherr.WrapError(err, "Resolving job package dependencies")
 	}
 
-	compiledPackageRefs, err := c.compilePackages(compileOrderReleasePackages, stage)
+	compiledPackageRefs, err := c.compilePackages(compileOrderReleasePackages, maxWorkers, stage)
 	if err != nil {
 		return nil, bosherr.WrapError(err, "Compiling job package dependencies")
 	}
@@ -61,7 +62,9 @@ func (c *dependencyCompiler) resolveJobCompilationDependencies(releaseJobs []bir
 		for _, releasePackage := range releaseJob.Packages {
 			pkgKey := c.pkgKey(releasePackage)
 			packageMap[pkgKey] = releasePackage
-			c.resolvePackageDependencies(releasePackage, packageMap)
+			for _, dependency := range bistatepkg.ResolveDependencies(releasePackage) {
+				packageMap[c.pkgKey(dependency)] = dependency
+			}
 		}
 	}
 
@@ -83,41 +86,66 @@ func (c *dependencyCompiler) resolveJobCompilationDependencies(releaseJobs []bir
 	return sortedPackages, nil
 }
 
-// resolvePackageDependencies adds the releasePackage's dependencies to the packageMap recursively
-func (c *dependencyCompiler) resolvePackageDependencies(releasePackage *birelpkg.Package, packageMap map[string]*birelpkg.Package) {
-	for _, dependency := range releasePackage.Dependencies {
-		// only add un-added packages, to avoid endless looping in case of cycles
-		pkgKey := c.pkgKey(dependency)
-		if _, found := packageMap[pkgKey]; !found {
-			packageMap[pkgKey] = dependency
-			c.resolvePackageDependencies(releasePackage, packageMap)
+type compileTask struct {
+	pkg    *birelpkg.Package
+	order  int
+	record bistatepkg.CompiledPackageRecord
+	err    error
+	done   chan struct{}
+}
+
+// compilePackages compiles the specified packages concurrently, each after all of its dependencies, and returns the blob references.
+// Stage steps are reported in the order specified, each one finishing once its package is compiled.
+func (c *dependencyCompiler) compilePackages(requiredPackages []*birelpkg.Package, maxWorkers int, stage biui.Stage) ([]CompiledPackageRef, error) {
+	if maxWorkers < 1 {
+		maxWorkers = 1
+	}
+
+	tasks := make(map[string]*compileTask, len(requiredPackages))
+	for i, pkg := range requiredPackages {
+		tasks[c.pkgKey(pkg)] = &compileTask{
+			pkg:   pkg,
+			order: i,
+			done:  make(chan struct{}),
 		}
 	}
-}
 
-// compilePackages compiles the specified packages, in the order specified, uploads them to the Blobstore, and returns the blob references
-func (c *dependencyCompiler) compilePackages(requiredPackages []*birelpkg.Package, stage biui.Stage) ([]CompiledPackageRef, error) {
+	workers := make(chan struct{}, maxWorkers)
+	abort := make(chan struct{})
+	for _, task := range tasks {
+		go c.compileTask(task, tasks, workers, abort)
+	}
+
 	packageRefs := make([]CompiledPackageRef, 0, len(requiredPackages))
 
 	for _, pkg := range requiredPackages {
+		task := tasks[c.pkgKey(pkg)]
 		stepName := fmt.Sprintf("Compiling package '%s/%s'", pkg.Name, pkg.Fingerprint)
+		if pkg.IsCompiled() {
+			stepName = fmt.Sprintf("Uploading compiled package '%s/%s'", pkg.Name, pkg.Fingerprint)
+		}
 		err := stage.Perform(stepName, func() error {
-			compiledPackageRecord, err := c.packageCompiler.Compile(pkg)
-			if err != nil {
-				return err
+			<-task.done
+			if task.err != nil {
+				return task.err
 			}
 
 			packageRef := CompiledPackageRef{
 				Name:        pkg.Name,
 				Version:     pkg.Fingerprint,
-				BlobstoreID: compiledPackageRecord.BlobID,
-				SHA1:        compiledPackageRecord.BlobSHA1,
+				BlobstoreID: task.record.BlobID,
+				SHA1:        task.record.BlobSHA1,
 			}
 			packageRefs = append(packageRefs, packageRef)
 
 			return nil
 		})
 		if err != nil {
+			// stop scheduling and wait for the compilations in flight
+			close(abort)
+			for _, task := range tasks {
+				<-task.done
+			}
 			return nil, err
 		}
 	}
@@ -125,6 +153,36 @@ func (c *dependencyCompiler) compilePackages(requiredPackages []*birelpkg.Packag
 	return packageRefs, nil
 }
 
+// compileTask waits for the task's dependencies and a free worker, then compiles the task's package
+func (c *dependencyCompiler) compileTask(task *compileTask, tasks map[string]*compileTask, workers chan struct{}, abort chan struct{}) {
+	defer close(task.done)
+
+	for _, dependency := range task.pkg.Dependencies {
+		// only wait for dependencies sorted before this package, to avoid deadlocking on dependency cycles
+		dependencyTask, found := tasks[c.pkgKey(dependency)]
+		if !found || dependencyTask.order >= task.order {
+			continue
+		}
+
+		<-dependencyTask.done
+		if dependencyTask.err != nil {
+			task.err = bosherr.Errorf("Dependency '%s' of package '%s' failed to compile", dependency.Name, task.pkg.Name)
+			return
+		}
+	}
+
+	select {
+	case workers <- struct{}{}:
+	case <-abort:
+		task.err = bosherr.Errorf("Compilation of package '%s' was aborted", task.pkg.Name)
+		return
+	}
+	defer func() { <-workers }()
+
+	c.logger.Debug(c.logTag, "Compiling package '%s/%s'", task.pkg.Name, task.pkg.Fingerprint)
+	task.record, task.err = c.packageCompiler.Compile(task.pkg)
+}
+
 func (c *dependencyCompiler) pkgKey(pkg *birelpkg.Package) string {
 	return pkg.Name
 }
@@ -1,6 +1,11 @@
 package job_test
 
 import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -107,12 +112,12 @@ var _ = Describe("DependencyCompiler", func() {
 			expectCompilePkg2.Times(1),
 		)
 
-		_, err := dependencyCompiler.Compile(releaseJobs, fakeStage)
+		_, err := dependencyCompiler.Compile(releaseJobs, 4, fakeStage)
 		Expect(err).ToNot(HaveOccurred())
 	})
 
 	It("returns references to the compiled packages", func() {
-		compiledPackageRefs, err := dependencyCompiler.Compile(releaseJobs, fakeStage)
+		compiledPackageRefs, err := dependencyCompiler.Compile(releaseJobs, 4, fakeStage)
 		Expect(err).ToNot(HaveOccurred())
 
 		Expect(compiledPackageRefs).To(Equal([]CompiledPackageRef{
@@ -132,7 +137,7 @@ var _ = Describe("DependencyCompiler", func() {
 	})
 
 	It("logs compile stages", func() {
-		_, err := dependencyCompiler.Compile(releaseJobs, fakeStage)
+		_, err := dependencyCompiler.Compile(releaseJobs, 4, fakeStage)
 		Expect(err).ToNot(HaveOccurred())
 
 		Expect(fakeStage.PerformCalls).To(Equal([]fakebiui.PerformCall{
@@ -158,7 +163,7 @@ var _ = Describe("DependencyCompiler", func() {
 				expectCompilePkg2.Times(1),
 			)
 
-			_, err := dependencyCompiler.Compile(releaseJobs, fakeStage)
+			_, err := dependencyCompiler.Compile(releaseJobs, 4, fakeStage)
 			Expect(err).ToNot(HaveOccurred())
 		})
 	})
@@ -197,8 +202,111 @@ var _ = Describe("DependencyCompiler", func() {
 			expectCompilePkg2.After(expectCompilePkg1)
 			expectCompilePkg3.After(expectCompilePkg1)
 
-			_, err := dependencyCompiler.Compile(releaseJobs, fakeStage)
+			_, err := dependencyCompiler.Compile(releaseJobs, 4, fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Context("when packages do not depend on each other", func() {
+		var (
+			releasePackage3 *birelpkg.Package
+
+			expectCompilePkg3 *gomock.Call
+		)
+
+		BeforeEach(func() {
+			releasePackage3 = &birelpkg.Package{
+				Name:          "fake-release-package-name-3",
+				Fingerprint:   "fake-release-package-fingerprint-3",
+				SHA1:          "fake-release-package-sha1-3",
+				Dependencies:  []*birelpkg.Package{},
+				ExtractedPath: "/extracted-release-path/extracted_packages/fake-release-package-name-3",
+			}
+
+			releaseJob.PackageNames = []string{releasePackage1.Name, releasePackage3.Name}
+			releaseJob.Packages = []*birelpkg.Package{releasePackage1, releasePackage3}
+			releaseJobs = []bireljob.Job{releaseJob}
+		})
+
+		JustBeforeEach(func() {
+			compiledPackageRecord3 := bistatepkg.CompiledPackageRecord{
+				BlobID:   "fake-compiled-package-blobstore-id-3",
+				BlobSHA1: "fake-compiled-package-sha1-3",
+			}
+			expectCompilePkg3 = mockPackageCompiler.EXPECT().Compile(releasePackage3).Return(compiledPackageRecord3, nil).AnyTimes()
+		})
+
+		It("compiles them concurrently", func() {
+			var startedGroup sync.WaitGroup
+			startedGroup.Add(2)
+			allStarted := make(chan struct{})
+			go func() {
+				startedGroup.Wait()
+				close(allStarted)
+			}()
+
+			overlapped := make(chan bool, 2)
+			waitForOther := func(_ *birelpkg.Package) {
+				startedGroup.Done()
+				select {
+				case <-allStarted:
+					overlapped <- true
+				case <-time.After(2 * time.Second):
+					overlapped <- false
+				}
+			}
+			expectCompilePkg1.Times(1).Do(waitForOther)
+			expectCompilePkg3.Times(1).Do(waitForOther)
+
+			_, err := dependencyCompiler.Compile(releaseJobs, 2, fakeStage)
 			Expect(err).ToNot(HaveOccurred())
+
+			Expect(<-overlapped).To(BeTrue())
+			Expect(<-overlapped).To(BeTrue())
+		})
+
+		It("compiles one at a time when limited to a single worker", func() {
+			var active, maxActive int32
+			compile := func(_ *birelpkg.Package) {
+				current := atomic.AddInt32(&active, 1)
+				for {
+					max := atomic.LoadInt32(&maxActive)
+					if current <= max || atomic.CompareAndSwapInt32(&maxActive, max, current) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				atomic.AddInt32(&active, -1)
+			}
+			expectCompilePkg1.Times(1).Do(compile)
+			expectCompilePkg3.Times(1).Do(compile)
+
+			_, err := dependencyCompiler.Compile(releaseJobs, 1, fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(atomic.LoadInt32(&maxActive)).To(Equal(int32(1)))
+		})
+	})
+
+	Context("when compiling a package fails", func() {
+		JustBeforeEach(func() {
+			expectCompilePkg1.Return(bistatepkg.CompiledPackageRecord{}, errors.New("fake-compile-error"))
+		})
+
+		It("returns an error and does not compile the packages that depend on it", func() {
+			expectCompilePkg1.Times(1)
+			expectCompilePkg2.Times(0)
+
+			_, err := dependencyCompiler.Compile(releaseJobs, 4, fakeStage)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-compile-error"))
+
+			Expect(fakeStage.PerformCalls).To(Equal([]fakebiui.PerformCall{
+				{
+					Name:  "Compiling package 'fake-release-package-name-1/fake-release-package-fingerprint-1'",
+					Error: errors.New("fake-compile-error"),
+				},
+			}))
 		})
 	})
 })
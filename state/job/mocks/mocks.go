@@ -31,13 +31,13 @@ func (_m *MockDependencyCompiler) EXPECT() *_MockDependencyCompilerRecorder {
 	return _m.recorder
 }
 
-func (_m *MockDependencyCompiler) Compile(_param0 []job.Job, _param1 ui.Stage) ([]job0.CompiledPackageRef, error) {
-	ret := _m.ctrl.Call(_m, "Compile", _param0, _param1)
+func (_m *MockDependencyCompiler) Compile(_param0 []job.Job, _param1 int, _param2 ui.Stage) ([]job0.CompiledPackageRef, error) {
+	ret := _m.ctrl.Call(_m, "Compile", _param0, _param1, _param2)
 	ret0, _ := ret[0].([]job0.CompiledPackageRef)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockDependencyCompilerRecorder) Compile(arg0, arg1 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "Compile", arg0, arg1)
+func (_mr *_MockDependencyCompilerRecorder) Compile(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Compile", arg0, arg1, arg2)
 }
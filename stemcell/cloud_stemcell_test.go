@@ -44,7 +44,7 @@ var _ = Describe("CloudStemcell", func() {
 		Context("when stemcell is in the repo", func() {
 			BeforeEach(func() {
 				fakeUUIDGenerator.GeneratedUUID = "fake-stemcell-id"
-				_, err := stemcellRepo.Save("fake-stemcell-name", "fake-stemcell-version", "fake-stemcell-cid")
+				_, err := stemcellRepo.Save("fake-stemcell-name", "fake-stemcell-version", "", "fake-stemcell-cid")
 				Expect(err).ToNot(HaveOccurred())
 			})
 
@@ -85,7 +85,7 @@ var _ = Describe("CloudStemcell", func() {
 		})
 
 		It("deletes stemcell from repo", func() {
-			_, err := stemcellRepo.Save("fake-stemcell-name", "fake-stemcell-version", "fake-stemcell-cid")
+			_, err := stemcellRepo.Save("fake-stemcell-name", "fake-stemcell-version", "", "fake-stemcell-cid")
 			Expect(err).ToNot(HaveOccurred())
 
 			err = cloudStemcell.Delete()
@@ -96,7 +96,7 @@ var _ = Describe("CloudStemcell", func() {
 
 		Context("when deleted stemcell is the current stemcell", func() {
 			BeforeEach(func() {
-				stemcellRecord, err := stemcellRepo.Save("fake-stemcell-name", "fake-stemcell-version", "fake-stemcell-cid")
+				stemcellRecord, err := stemcellRepo.Save("fake-stemcell-name", "fake-stemcell-version", "", "fake-stemcell-cid")
 				Expect(err).ToNot(HaveOccurred())
 
 				err = stemcellRepo.UpdateCurrent(stemcellRecord.ID)
@@ -132,7 +132,7 @@ var _ = Describe("CloudStemcell", func() {
 			})
 
 			BeforeEach(func() {
-				stemcellRecord, err := stemcellRepo.Save("fake-stemcell-name", "fake-stemcell-version", "fake-stemcell-cid")
+				stemcellRecord, err := stemcellRepo.Save("fake-stemcell-name", "fake-stemcell-version", "", "fake-stemcell-cid")
 				Expect(err).ToNot(HaveOccurred())
 
 				err = stemcellRepo.UpdateCurrent(stemcellRecord.ID)
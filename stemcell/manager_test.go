@@ -55,6 +55,7 @@ var _ = Describe("Manager", func() {
 			Manifest{
 				Name:      "fake-stemcell-name",
 				Version:   "fake-stemcell-version",
+				OS:        "fake-stemcell-os",
 				ImagePath: "fake-image-path",
 				CloudProperties: biproperty.Map{
 					"fake-prop-key": "fake-prop-value",
@@ -77,6 +78,7 @@ var _ = Describe("Manager", func() {
 				CID:     "fake-stemcell-cid",
 				Name:    "fake-stemcell-name",
 				Version: "fake-stemcell-version",
+				OS:      "fake-stemcell-os",
 			}
 			expectedCloudStemcell = NewCloudStemcell(stemcellRecord, stemcellRepo, fakeCloud)
 		})
@@ -107,6 +109,7 @@ var _ = Describe("Manager", func() {
 					ID:      "fake-stemcell-id-1",
 					Name:    "fake-stemcell-name",
 					Version: "fake-stemcell-version",
+					OS:      "fake-stemcell-os",
 					CID:     "fake-stemcell-cid",
 				},
 			}))
@@ -150,7 +153,7 @@ var _ = Describe("Manager", func() {
 
 			BeforeEach(func() {
 				var err error
-				foundStemcellRecord, err = stemcellRepo.Save("fake-stemcell-name", "fake-stemcell-version", "fake-existing-cid")
+				foundStemcellRecord, err = stemcellRepo.Save("fake-stemcell-name", "fake-stemcell-version", "", "fake-existing-cid")
 				Expect(err).ToNot(HaveOccurred())
 			})
 
@@ -181,7 +184,7 @@ var _ = Describe("Manager", func() {
 	Describe("FindCurrent", func() {
 		Context("when stemcell already exists in stemcell repo", func() {
 			BeforeEach(func() {
-				stemcellRecord, err := stemcellRepo.Save("fake-stemcell-name", "fake-stemcell-version", "fake-existing-stemcell-cid")
+				stemcellRecord, err := stemcellRepo.Save("fake-stemcell-name", "fake-stemcell-version", "", "fake-existing-stemcell-cid")
 				Expect(err).ToNot(HaveOccurred())
 
 				err = stemcellRepo.UpdateCurrent(stemcellRecord.ID)
@@ -226,18 +229,18 @@ var _ = Describe("Manager", func() {
 
 		BeforeEach(func() {
 			fakeUUIDGenerator.GeneratedUUID = "fake-stemcell-id-1"
-			firstStemcellRecord, err := stemcellRepo.Save("fake-stemcell-name-1", "fake-stemcell-version-1", "fake-stemcell-cid-1")
+			firstStemcellRecord, err := stemcellRepo.Save("fake-stemcell-name-1", "fake-stemcell-version-1", "", "fake-stemcell-cid-1")
 			Expect(err).ToNot(HaveOccurred())
 			firstStemcell = NewCloudStemcell(firstStemcellRecord, stemcellRepo, fakeCloud)
 
 			fakeUUIDGenerator.GeneratedUUID = "fake-stemcell-id-2"
-			_, err = stemcellRepo.Save("fake-stemcell-name-2", "fake-stemcell-version-2", "fake-stemcell-cid-2")
+			_, err = stemcellRepo.Save("fake-stemcell-name-2", "fake-stemcell-version-2", "", "fake-stemcell-cid-2")
 			Expect(err).ToNot(HaveOccurred())
 			err = stemcellRepo.UpdateCurrent("fake-stemcell-id-2")
 			Expect(err).ToNot(HaveOccurred())
 
 			fakeUUIDGenerator.GeneratedUUID = "fake-stemcell-id-3"
-			secondStemcellRecord, err := stemcellRepo.Save("fake-stemcell-name-3", "fake-stemcell-version-3", "fake-stemcell-cid-3")
+			secondStemcellRecord, err := stemcellRepo.Save("fake-stemcell-name-3", "fake-stemcell-version-3", "", "fake-stemcell-cid-3")
 			Expect(err).ToNot(HaveOccurred())
 			secondStemcell = NewCloudStemcell(secondStemcellRecord, stemcellRepo, fakeCloud)
 		})
@@ -258,17 +261,17 @@ var _ = Describe("Manager", func() {
 		)
 		BeforeEach(func() {
 			fakeUUIDGenerator.GeneratedUUID = "fake-stemcell-id-1"
-			_, err := stemcellRepo.Save("fake-stemcell-name-1", "fake-stemcell-version-1", "fake-stemcell-cid-1")
+			_, err := stemcellRepo.Save("fake-stemcell-name-1", "fake-stemcell-version-1", "", "fake-stemcell-cid-1")
 			Expect(err).ToNot(HaveOccurred())
 
 			fakeUUIDGenerator.GeneratedUUID = "fake-stemcell-id-2"
-			secondStemcellRecord, err = stemcellRepo.Save("fake-stemcell-name-2", "fake-stemcell-version-2", "fake-stemcell-cid-2")
+			secondStemcellRecord, err = stemcellRepo.Save("fake-stemcell-name-2", "fake-stemcell-version-2", "", "fake-stemcell-cid-2")
 			Expect(err).ToNot(HaveOccurred())
 			err = stemcellRepo.UpdateCurrent(secondStemcellRecord.ID)
 			Expect(err).ToNot(HaveOccurred())
 
 			fakeUUIDGenerator.GeneratedUUID = "fake-stemcell-id-3"
-			_, err = stemcellRepo.Save("fake-stemcell-name-3", "fake-stemcell-version-3", "fake-stemcell-cid-3")
+			_, err = stemcellRepo.Save("fake-stemcell-name-3", "fake-stemcell-version-3", "", "fake-stemcell-cid-3")
 			Expect(err).ToNot(HaveOccurred())
 		})
 
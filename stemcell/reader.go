@@ -15,6 +15,7 @@ type manifest struct {
 	Name            string
 	Version         string
 	SHA1            string
+	OS              string                      `yaml:"operating_system"`
 	CloudProperties map[interface{}]interface{} `yaml:"cloud_properties"`
 }
 
@@ -56,6 +57,7 @@ func (s reader) Read(stemcellTarballPath string, extractedPath string) (Extracte
 		Name:    rawManifest.Name,
 		Version: rawManifest.Version,
 		SHA1:    rawManifest.SHA1,
+		OS:      rawManifest.OS,
 	}
 
 	cloudProperties, err := biproperty.BuildMap(rawManifest.CloudProperties)
@@ -30,6 +30,7 @@ var _ = Describe("Reader", func() {
 ---
 name: fake-stemcell-name
 version: '2690'
+operating_system: ubuntu-trusty
 cloud_properties:
   infrastructure: aws
   ami:
@@ -52,6 +53,7 @@ cloud_properties:
 			Manifest{
 				Name:      "fake-stemcell-name",
 				Version:   "2690",
+				OS:        "ubuntu-trusty",
 				ImagePath: "fake-extracted-path/image",
 				CloudProperties: biproperty.Map{
 					"infrastructure": "aws",
@@ -47,5 +47,6 @@ type Manifest struct {
 	Name            string
 	Version         string
 	SHA1            string
+	OS              string
 	CloudProperties biproperty.Map
 }
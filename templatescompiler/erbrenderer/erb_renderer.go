@@ -2,6 +2,7 @@ package erbrenderer
 
 import (
 	"encoding/json"
+	"os"
 	"path/filepath"
 
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
@@ -13,7 +14,12 @@ type ERBRenderer interface {
 	Render(srcPath, dstPath string, context TemplateEvaluationContext) error
 }
 
-type erbRenderer struct {
+// RendererEnvVar selects the ruby renderer when set to "ruby", or only the native one when set to "native".
+// By default templates are rendered natively, falling back to ruby for the templates the native renderer does not support.
+const RendererEnvVar = "BOSH_INIT_ERB_RENDERER"
+
+// rubyERBRenderer renders templates by running the ruby executable on the host
+type rubyERBRenderer struct {
 	fs     boshsys.FileSystem
 	runner boshsys.CmdRunner
 	logger boshlog.Logger
@@ -27,17 +33,31 @@ func NewERBRenderer(
 	runner boshsys.CmdRunner,
 	logger boshlog.Logger,
 ) ERBRenderer {
-	return erbRenderer{
+	switch os.Getenv(RendererEnvVar) {
+	case "ruby":
+		return NewRubyERBRenderer(fs, runner, logger)
+	case "native":
+		return NewNativeERBRenderer(fs, logger)
+	}
+	return NewFallbackERBRenderer(fs, runner, logger)
+}
+
+func NewRubyERBRenderer(
+	fs boshsys.FileSystem,
+	runner boshsys.CmdRunner,
+	logger boshlog.Logger,
+) ERBRenderer {
+	return rubyERBRenderer{
 		fs:     fs,
 		runner: runner,
 		logger: logger,
-		logTag: "erbRenderer",
+		logTag: "rubyERBRenderer",
 
 		rendererScript: templateEvaluationContextRb,
 	}
 }
 
-func (r erbRenderer) Render(srcPath, dstPath string, context TemplateEvaluationContext) error {
+func (r rubyERBRenderer) Render(srcPath, dstPath string, context TemplateEvaluationContext) error {
 	r.logger.Debug(r.logTag, "Rendering template %s", dstPath)
 
 	tmpDir, err := r.fs.TempDir("erb-renderer")
@@ -63,15 +83,20 @@ func (r erbRenderer) Render(srcPath, dstPath string, context TemplateEvaluationC
 		Args: []string{rendererScriptPath, contextPath, srcPath, dstPath},
 	}
 
-	_, _, _, err = r.runner.RunComplexCommand(command)
+	_, stderr, _, err := r.runner.RunComplexCommand(command)
 	if err != nil {
+		// The excerpt is left out when the template cannot be read
+		template, _ := r.fs.ReadFileString(srcPath)
+		if templateErr, ok := parseRubyTemplateError(stderr, template); ok {
+			return templateErr
+		}
 		return bosherr.WrapError(err, "Running ruby to render templates")
 	}
 
 	return nil
 }
 
-func (r erbRenderer) writeRendererScript(scriptPath string) error {
+func (r rubyERBRenderer) writeRendererScript(scriptPath string) error {
 	err := r.fs.WriteFileString(scriptPath, r.rendererScript)
 	if err != nil {
 		return bosherr.WrapError(err, "Writing renderer script")
@@ -80,7 +105,7 @@ func (r erbRenderer) writeRendererScript(scriptPath string) error {
 	return nil
 }
 
-func (r erbRenderer) writeContext(contextPath string, context TemplateEvaluationContext) error {
+func (r rubyERBRenderer) writeContext(contextPath string, context TemplateEvaluationContext) error {
 	contextBytes, err := json.Marshal(context)
 	if err != nil {
 		return bosherr.WrapError(err, "Marshalling context")
@@ -15,7 +15,7 @@ import (
 	. "github.com/cloudfoundry/bosh-init/templatescompiler/erbrenderer"
 )
 
-var _ = Describe("ErbRenderer", func() {
+var _ = Describe("RubyERBRenderer", func() {
 	var (
 		fs          *fakesys.FakeFileSystem
 		runner      *fakesys.FakeCmdRunner
@@ -29,7 +29,7 @@ var _ = Describe("ErbRenderer", func() {
 		runner = fakesys.NewFakeCmdRunner()
 		context = &fakebierbrenderer.FakeTemplateEvaluationContext{}
 
-		erbRenderer = NewERBRenderer(fs, runner, logger)
+		erbRenderer = NewRubyERBRenderer(fs, runner, logger)
 		fs.TempDirDir = "fake-temp-dir"
 	})
 
@@ -88,4 +88,34 @@ var _ = Describe("ErbRenderer", func() {
 			Expect(err.Error()).To(ContainSubstring("fake-cmd-error"))
 		})
 	})
+
+	Context("when ruby fails to fill in the template", func() {
+		BeforeEach(func() {
+			fs.WriteFileString("fake-src-path", "line one\n<%= p('fake-property') %>")
+			runner.AddCmdResult(
+				"ruby fake-temp-dir/erb-render.rb fake-temp-dir/erb-context.json fake-src-path fake-dst-path",
+				fakesys.FakeCmdResult{
+					Stderr: "fake-temp-dir/erb-render.rb:180:in `rescue in render': " +
+						"Error filling in template 'fake-src-path' for fake-job-name/1 " +
+						"(line 2: #<TemplateEvaluationContext::UnknownProperty: Can't find property 'fake-property'>) (RuntimeError)",
+					Error: errors.New("fake-cmd-error"),
+				})
+		})
+
+		It("returns a template error", func() {
+			err := erbRenderer.Render("fake-src-path", "fake-dst-path", context)
+			Expect(err).To(Equal(TemplateError{
+				Job:               "fake-job-name",
+				Index:             1,
+				Template:          "fake-src-path",
+				Line:              2,
+				Message:           "#<TemplateEvaluationContext::UnknownProperty: Can't find property 'fake-property'>",
+				MissingProperties: []string{"fake-property"},
+				Excerpt: []TemplateLine{
+					{Number: 1, Text: "line one"},
+					{Number: 2, Text: "<%= p('fake-property') %>"},
+				},
+			}))
+		})
+	})
 })
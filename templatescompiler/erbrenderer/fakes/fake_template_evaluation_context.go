@@ -1,8 +1,12 @@
 package fakes
 
 type FakeTemplateEvaluationContext struct {
+	JSON string
 }
 
 func (f FakeTemplateEvaluationContext) MarshalJSON() ([]byte, error) {
-	return []byte("{}"), nil
+	if f.JSON == "" {
+		return []byte("{}"), nil
+	}
+	return []byte(f.JSON), nil
 }
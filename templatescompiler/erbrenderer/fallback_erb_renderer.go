@@ -0,0 +1,52 @@
+package erbrenderer
+
+import (
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	boshsys "github.com/cloudfoundry/bosh-agent/system"
+)
+
+// unsupportedByNativeClasses are the ruby exceptions raised by the native renderer for ruby it does not implement
+// (e.g. Base64, to_yaml or 'def'), which the ruby renderer may still render. The native parser cannot tell syntax
+// it does not implement (e.g. heredocs) from invalid syntax, so ruby also renders templates that do not parse.
+var unsupportedByNativeClasses = map[string]bool{
+	unsupportedClass: true,
+	"SyntaxError":    true,
+}
+
+// fallbackERBRenderer renders templates natively, and with ruby when the native renderer does not support a template
+type fallbackERBRenderer struct {
+	native nativeERBRenderer
+	ruby   ERBRenderer
+	runner boshsys.CmdRunner
+	logger boshlog.Logger
+	logTag string
+}
+
+func NewFallbackERBRenderer(
+	fs boshsys.FileSystem,
+	runner boshsys.CmdRunner,
+	logger boshlog.Logger,
+) ERBRenderer {
+	return fallbackERBRenderer{
+		native: NewNativeERBRenderer(fs, logger).(nativeERBRenderer),
+		ruby:   NewRubyERBRenderer(fs, runner, logger),
+		runner: runner,
+		logger: logger,
+		logTag: "fallbackERBRenderer",
+	}
+}
+
+func (r fallbackERBRenderer) Render(srcPath, dstPath string, context TemplateEvaluationContext) error {
+	errorClass, err := r.native.render(srcPath, dstPath, context)
+	if err == nil || !unsupportedByNativeClasses[errorClass] {
+		return err
+	}
+
+	if !r.runner.CommandExists("ruby") {
+		r.logger.Debug(r.logTag, "Not rendering template %s with ruby, which is not installed", srcPath)
+		return err
+	}
+
+	r.logger.Info(r.logTag, "Rendering template %s with ruby after native rendering failed: %s", srcPath, err.Error())
+	return r.ruby.Render(srcPath, dstPath, context)
+}
@@ -0,0 +1,106 @@
+package erbrenderer_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	fakesys "github.com/cloudfoundry/bosh-agent/system/fakes"
+	fakebierbrenderer "github.com/cloudfoundry/bosh-init/templatescompiler/erbrenderer/fakes"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	. "github.com/cloudfoundry/bosh-init/templatescompiler/erbrenderer"
+)
+
+var _ = Describe("FallbackERBRenderer", func() {
+	var (
+		fs          *fakesys.FakeFileSystem
+		runner      *fakesys.FakeCmdRunner
+		erbRenderer ERBRenderer
+		context     *fakebierbrenderer.FakeTemplateEvaluationContext
+	)
+
+	BeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		fs = fakesys.NewFakeFileSystem()
+		runner = fakesys.NewFakeCmdRunner()
+		runner.AvailableCommands = map[string]bool{"ruby": true}
+		context = &fakebierbrenderer.FakeTemplateEvaluationContext{}
+		fs.TempDirDir = "fake-temp-dir"
+
+		erbRenderer = NewFallbackERBRenderer(fs, runner, logger)
+	})
+
+	It("renders templates natively", func() {
+		fs.WriteFileString("/fake-src-path", "fake-template")
+
+		err := erbRenderer.Render("/fake-src-path", "/fake-dst-path", context)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(runner.RunComplexCommands).To(BeEmpty())
+		Expect(fs.ReadFileString("/fake-dst-path")).To(Equal("fake-template"))
+	})
+
+	It("renders templates with ruby when they call a method the native renderer does not implement", func() {
+		fs.WriteFileString("/fake-src-path", "<%= 'fake'.unsupported_method %>")
+
+		err := erbRenderer.Render("/fake-src-path", "/fake-dst-path", context)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(runner.RunComplexCommands).To(HaveLen(1))
+		Expect(runner.RunComplexCommands[0].Name).To(Equal("ruby"))
+	})
+
+	for _, template := range []string{
+		"<%= Base64.encode64('fake') %>",
+		"<%= [1, 2].each_with_index.map { |v, i| v * i }.join(',') %>",
+		"<%= {'a' => 1}.each_pair.map { |k, v| k }.join(',') %>",
+		"<%= 3.times.map { |i| i }.join(',') %>",
+		"<% def fake_helper; 'fake'; end %><%= fake_helper %>",
+		"<%= ('a'..'c').to_a.join(',') %>",
+		"<%= 'fake'.respond_to?(:upcase) %>",
+		"<%= 'fake'.frozen? %>",
+		"<%= 'fake'.to_yaml rescue 'fake-rescued' %>",
+		"<% begin %><%= 'fake'.to_yaml %><% rescue Exception %>fake-rescued<% end %>",
+	} {
+		template := template
+
+		It("renders templates with ruby when the native renderer does not support "+template, func() {
+			fs.WriteFileString("/fake-src-path", template)
+
+			err := erbRenderer.Render("/fake-src-path", "/fake-dst-path", context)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(runner.RunComplexCommands).To(HaveLen(1))
+		})
+	}
+
+	It("renders templates with ruby when the native renderer raises a SyntaxError", func() {
+		fs.WriteFileString("/fake-src-path", "<% if true %>unterminated")
+
+		err := erbRenderer.Render("/fake-src-path", "/fake-dst-path", context)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(runner.RunComplexCommands).To(HaveLen(1))
+	})
+
+	It("returns the native error without rendering with ruby when a property is missing", func() {
+		fs.WriteFileString("/fake-src-path", "<%= p('unknown') %>")
+
+		err := erbRenderer.Render("/fake-src-path", "/fake-dst-path", context)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Can't find property 'unknown'"))
+		Expect(runner.RunComplexCommands).To(BeEmpty())
+	})
+
+	Context("when ruby is not installed", func() {
+		BeforeEach(func() {
+			runner.AvailableCommands = map[string]bool{}
+		})
+
+		It("returns the native error", func() {
+			fs.WriteFileString("/fake-src-path", "<%= 'fake'.unsupported_method %>")
+
+			err := erbRenderer.Render("/fake-src-path", "/fake-dst-path", context)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("UnsupportedByNativeRenderer: undefined method `unsupported_method'"))
+			Expect(runner.RunComplexCommands).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,92 @@
+package erbrenderer
+
+import (
+	"strconv"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	boshsys "github.com/cloudfoundry/bosh-agent/system"
+)
+
+// nativeERBRenderer renders templates in process, implementing the subset of ruby used by job templates
+type nativeERBRenderer struct {
+	fs     boshsys.FileSystem
+	logger boshlog.Logger
+	logTag string
+}
+
+func NewNativeERBRenderer(
+	fs boshsys.FileSystem,
+	logger boshlog.Logger,
+) ERBRenderer {
+	return nativeERBRenderer{
+		fs:     fs,
+		logger: logger,
+		logTag: "nativeERBRenderer",
+	}
+}
+
+func (r nativeERBRenderer) Render(srcPath, dstPath string, context TemplateEvaluationContext) error {
+	_, err := r.render(srcPath, dstPath, context)
+	return err
+}
+
+// render also returns the ruby exception class when the template raised one, e.g. "NoMethodError"
+func (r nativeERBRenderer) render(srcPath, dstPath string, context TemplateEvaluationContext) (string, error) {
+	r.logger.Debug(r.logTag, "Rendering template %s", dstPath)
+
+	template, err := r.fs.ReadFileString(srcPath)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Reading template '%s'", srcPath)
+	}
+
+	contextBytes, err := context.MarshalJSON()
+	if err != nil {
+		return "", bosherr.WrapError(err, "Marshalling context")
+	}
+
+	contextValue, err := decodeJSON(contextBytes)
+	if err != nil {
+		return "", bosherr.WrapError(err, "Unmarshalling context")
+	}
+
+	spec, ok := contextValue.(*rubyHash)
+	if !ok {
+		return "", bosherr.Errorf("Expected context to be a JSON object, got '%s'", string(contextBytes))
+	}
+	templateContext := newTemplateContext(spec)
+
+	output, err := r.evaluate(template, templateContext)
+	if err != nil {
+		rubyErr, ok := err.(*rubyError)
+		if !ok {
+			return "", bosherr.WrapErrorf(err, "Rendering template '%s'", srcPath)
+		}
+		index, _ := strconv.Atoi(toS(templateContext.index))
+		return rubyErr.class, NewTemplateError(
+			srcPath,
+			template,
+			toS(templateContext.name),
+			index,
+			rubyErr.line,
+			rubyErr.Error(),
+			rubyErr.unknownProperties,
+		)
+	}
+
+	err = r.fs.WriteFileString(dstPath, output)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Writing rendered template '%s'", dstPath)
+	}
+
+	return "", nil
+}
+
+func (r nativeERBRenderer) evaluate(template string, context *templateContext) (string, error) {
+	program, err := parseTemplate(template)
+	if err != nil {
+		return "", err
+	}
+
+	return newRubyInterpreter(context).run(program)
+}
@@ -0,0 +1,329 @@
+package erbrenderer_test
+
+import (
+	"errors"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	fakesys "github.com/cloudfoundry/bosh-agent/system/fakes"
+	fakebierbrenderer "github.com/cloudfoundry/bosh-init/templatescompiler/erbrenderer/fakes"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	. "github.com/cloudfoundry/bosh-init/templatescompiler/erbrenderer"
+)
+
+var _ = Describe("NativeERBRenderer", func() {
+	var (
+		fs          *fakesys.FakeFileSystem
+		erbRenderer ERBRenderer
+		context     *fakebierbrenderer.FakeTemplateEvaluationContext
+	)
+
+	BeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		fs = fakesys.NewFakeFileSystem()
+		erbRenderer = NewNativeERBRenderer(fs, logger)
+
+		context = &fakebierbrenderer.FakeTemplateEvaluationContext{
+			JSON: `{
+				"id": "fake-instance-id",
+				"index": 0,
+				"bootstrap": true,
+				"address": "10.0.0.2",
+				"job": {"name": "fake-job-name"},
+				"networks": {"default": {"ip": "10.0.0.2", "dns": ["10.0.0.3"], "default": ["dns", "gateway"]}},
+				"links": {
+					"db": {
+						"address": "10.0.0.5",
+						"instances": [
+							{"name": "fake-db-job", "id": "fake-db-id-0", "index": 0, "address": "10.0.0.5", "bootstrap": true},
+							{"name": "fake-db-job", "id": "fake-db-id-1", "index": 1, "address": "10.0.0.6", "bootstrap": false}
+						],
+						"properties": {"db": {"port": 5432}}
+					}
+				},
+				"global_properties": {
+					"global": {"value": "from-global"}
+				},
+				"cluster_properties": {
+					"port": 8080,
+					"ratio": 1.5,
+					"enabled": true,
+					"users": [{"name": "admin", "roles": ["read", "write"]}, {"name": "guest", "roles": ["read"]}],
+					"nested": {"key": "nested-value"},
+					"global": {"value": "from-cluster"}
+				},
+				"default_properties": {
+					"port": 1,
+					"ratio": null,
+					"enabled": false,
+					"users": [],
+					"nested.key": null,
+					"nested.other": "default-value",
+					"global.value": null,
+					"missing": null
+				}
+			}`,
+		}
+	})
+
+	render := func(template string) (string, error) {
+		err := fs.WriteFileString("/fake-src-path", template)
+		Expect(err).ToNot(HaveOccurred())
+
+		err = erbRenderer.Render("/fake-src-path", "/fake-dst-path", context)
+		if err != nil {
+			return "", err
+		}
+
+		return fs.ReadFileString("/fake-dst-path")
+	}
+
+	expectRendered := func(template string, expected string) {
+		output, err := render(template)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(output).To(Equal(expected))
+	}
+
+	It("renders text and output tags", func() {
+		expectRendered("port: <%= p('port') %>\n", "port: 8080\n")
+	})
+
+	It("renders properties overridden by the cluster, the globals and the job defaults", func() {
+		expectRendered(
+			"<%= p('nested.key') %> <%= p('nested.other') %> <%= p('global.value') %>",
+			"nested-value default-value from-cluster",
+		)
+	})
+
+	It("renders the default given to p when the property is missing", func() {
+		expectRendered("<%= p('missing', 'fallback') %> <%= p(['missing', 'port']) %>", "fallback 8080")
+	})
+
+	It("renders the job name, index and spec", func() {
+		expectRendered(
+			"<%= name %>/<%= index %> <%= spec.networks.default.ip %> <%= spec.job.name %>",
+			"fake-job-name/0 10.0.0.2 fake-job-name",
+		)
+	})
+
+	It("renders the instance identity and network settings", func() {
+		expectRendered(
+			"<%= spec.id %> <%= spec.bootstrap %> <%= spec.address %> <%= spec.networks.default.dns.first %> <%= spec.az.nil? %>",
+			"fake-instance-id true 10.0.0.2 10.0.0.3 true",
+		)
+	})
+
+	It("renders the links consumed by the job", func() {
+		expectRendered(
+			"<%= link('db').address %>:<%= link('db').p('db.port') %> "+
+				"<%= link('db').instances.map { |i| \"#{i.index}=#{i.address}\" }.join(',') %> "+
+				"<%= link('db').p('db.user', 'root') %> "+
+				"<% link('db').if_p('db.port') do |port| %><%= port %><% end %>",
+			"10.0.0.5:5432 0=10.0.0.5,1=10.0.0.6 root 5432",
+		)
+	})
+
+	It("renders if_link blocks and their else blocks", func() {
+		expectRendered(
+			"<% if_link('db') do |db| %><%= db.instances.size %><% end %>|"+
+				"<% if_link('cache') do |cache| %>cache<% end.else do %>no cache<% end %>",
+			"2|no cache",
+		)
+	})
+
+	Context("when the template refers to a link that is not consumed", func() {
+		It("returns an error", func() {
+			_, err := render("<%= link('cache').address %>")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("#<TemplateEvaluationContext::UnknownLink: Can't find link 'cache'>"))
+		})
+	})
+
+	Context("when the template refers to an unknown link property", func() {
+		It("returns an error with the missing property", func() {
+			_, err := render("<%= link('db').p('db.user') %>")
+			Expect(err).To(HaveOccurred())
+			Expect(err.(TemplateError).MissingProperties).To(Equal([]string{"db.user"}))
+		})
+	})
+
+	It("renders properties as open structs", func() {
+		expectRendered("<%= properties.nested.key %> <%= properties.users.first.name %>", "nested-value admin")
+	})
+
+	It("renders if_p blocks and their else blocks", func() {
+		expectRendered(
+			"<% if_p('port', 'nested.key') do |port, key| %><%= port %>-<%= key %><% end %>"+
+				"<% if_p('missing') do |missing| %>unexpected<% end.else do %> no-missing<% end %>",
+			"8080-nested-value no-missing",
+		)
+	})
+
+	It("renders conditionals, loops and blocks", func() {
+		expectRendered(`<% p('users').each_with_index do |user, i| -%>
+<%= i %>: <%= user['name'] %> (<%= user['roles'].join(', ') %>)<% if user['roles'].include?('write') %> writer<% end %>
+<% end -%>
+<%- unless p('enabled') -%>
+disabled
+<%- else -%>
+enabled
+<%- end -%>
+`, "0: admin (read, write) writer\n1: guest (read)\nenabled\n")
+	})
+
+	It("evaluates ruby expressions over strings, arrays and hashes", func() {
+		expectRendered(
+			`<%= p('users').map { |u| u['name'].upcase }.sort.reverse.join(',') %>|`+
+				`<%= "#{p('port') + 1}:#{p('ratio') * 2}" %>|`+
+				`<%= p('users').select { |u| u['roles'].size > 1 }.map { |u| u['name'] }.inspect %>|`+
+				`<%= {'a' => 1, b: [1, 2.0, nil]}.to_json %>|`+
+				`<%= p('nested').keys.first.sub(/k(e)y/, 'x\1') %>|`+
+				`<%= "%05.1f" % p('ratio') %>|`+
+				`<%= case p('port') when 1..1024 then 'privileged' when Integer then 'unprivileged' end %>|`+
+				`<% x = 3; x += 1 %><%= x %>|`+
+				`<%= (1..4).inject(:+) %>|`+
+				`<%= JSON.dump('quoted') %>`,
+			`GUEST,ADMIN|8081:3.0|["admin"]|{"a":1,"b":[1,2.0,null]}|xe|001.5|unprivileged|4|10|"quoted"`,
+		)
+	})
+
+	It("renders literal percent tags and trims comments", func() {
+		expectRendered("<%%= not code %><%# a comment %>", "<%= not code %>")
+	})
+
+	Context("when the template refers to an unknown property", func() {
+		It("returns an error with the template line like the ruby renderer", func() {
+			_, err := render("line one\n<%= p('unknown') %>")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal(
+				"Error filling in template '/fake-src-path' for fake-job-name/0 " +
+					"(line 2: #<TemplateEvaluationContext::UnknownProperty: Can't find property 'unknown'>)",
+			))
+		})
+
+		It("returns a template error with the missing property names and an excerpt of the template", func() {
+			_, err := render("line one\nline two\n<%= p(['unknown', 'fallback']) %>\nline four")
+			Expect(err).To(Equal(TemplateError{
+				Job:               "fake-job-name",
+				Index:             0,
+				Template:          "/fake-src-path",
+				Line:              3,
+				Message:           "#<TemplateEvaluationContext::UnknownProperty: Can't find property 'unknown', or 'fallback'>",
+				MissingProperties: []string{"unknown", "fallback"},
+				Excerpt: []TemplateLine{
+					{Number: 1, Text: "line one"},
+					{Number: 2, Text: "line two"},
+					{Number: 3, Text: "<%= p(['unknown', 'fallback']) %>"},
+					{Number: 4, Text: "line four"},
+				},
+			}))
+		})
+	})
+
+	Context("when the template calls an undefined method", func() {
+		It("returns an error", func() {
+			_, err := render("<%= p('port').undefined_method %>")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("UnsupportedByNativeRenderer: undefined method `undefined_method' for 8080:Integer"))
+		})
+
+		It("does not let the template rescue the error", func() {
+			_, err := render("<%= p('port').undefined_method rescue 'fake-rescued' %>")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("UnsupportedByNativeRenderer"))
+		})
+	})
+
+	It("renders respond_to? for the fields of an OpenStruct", func() {
+		expectRendered("<%= spec.respond_to?(:address) %>", "true")
+	})
+
+	It("renders frozen? for values that are always frozen", func() {
+		expectRendered("<%= p('port').frozen? %> <%= :fake.frozen? %> <%= nil.frozen? %>", "true true true")
+	})
+
+	Context("when the template has a syntax error", func() {
+		It("returns an error", func() {
+			_, err := render("<% if p('enabled') %>unterminated")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("SyntaxError"))
+		})
+	})
+
+	Context("when reading the template fails", func() {
+		It("returns an error", func() {
+			fs.WriteFileString("/fake-src-path", "fake-template")
+			fs.ReadFileError = errors.New("fake-read-error")
+			err := erbRenderer.Render("/fake-src-path", "/fake-dst-path", context)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-read-error"))
+		})
+	})
+
+	Context("when writing the rendered template fails", func() {
+		It("returns an error", func() {
+			fs.WriteFileString("/fake-src-path", "fake-template")
+			fs.WriteFileError = errors.New("fake-write-error")
+			err := erbRenderer.Render("/fake-src-path", "/fake-dst-path", context)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-write-error"))
+		})
+	})
+})
+
+var _ = Describe("NewERBRenderer", func() {
+	var (
+		fs      *fakesys.FakeFileSystem
+		runner  *fakesys.FakeCmdRunner
+		logger  boshlog.Logger
+		context *fakebierbrenderer.FakeTemplateEvaluationContext
+	)
+
+	BeforeEach(func() {
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+		fs = fakesys.NewFakeFileSystem()
+		runner = fakesys.NewFakeCmdRunner()
+		context = &fakebierbrenderer.FakeTemplateEvaluationContext{}
+		fs.WriteFileString("/fake-src-path", "fake-template")
+	})
+
+	AfterEach(func() {
+		os.Unsetenv(RendererEnvVar)
+	})
+
+	It("renders templates natively by default", func() {
+		err := NewERBRenderer(fs, runner, logger).Render("/fake-src-path", "/fake-dst-path", context)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(runner.RunComplexCommands).To(BeEmpty())
+		Expect(fs.ReadFileString("/fake-dst-path")).To(Equal("fake-template"))
+	})
+
+	It("falls back to ruby for templates the native renderer does not support by default", func() {
+		runner.AvailableCommands = map[string]bool{"ruby": true}
+		fs.WriteFileString("/fake-src-path", "<%= 'fake'.unsupported_method %>")
+		err := NewERBRenderer(fs, runner, logger).Render("/fake-src-path", "/fake-dst-path", context)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(runner.RunComplexCommands).To(HaveLen(1))
+	})
+
+	It("renders templates only natively when selected by the environment", func() {
+		os.Setenv(RendererEnvVar, "native")
+		runner.AvailableCommands = map[string]bool{"ruby": true}
+		fs.WriteFileString("/fake-src-path", "<%= 'fake'.unsupported_method %>")
+		err := NewERBRenderer(fs, runner, logger).Render("/fake-src-path", "/fake-dst-path", context)
+		Expect(err).To(HaveOccurred())
+		Expect(runner.RunComplexCommands).To(BeEmpty())
+	})
+
+	It("renders templates with ruby when selected by the environment", func() {
+		os.Setenv(RendererEnvVar, "ruby")
+		err := NewERBRenderer(fs, runner, logger).Render("/fake-src-path", "/fake-dst-path", context)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(runner.RunComplexCommands).To(HaveLen(1))
+		Expect(runner.RunComplexCommands[0].Name).To(Equal("ruby"))
+	})
+})
@@ -0,0 +1,910 @@
+package erbrenderer
+
+import (
+	"strings"
+)
+
+// enumerableMethod implements the Enumerable methods shared by arrays, ranges and hashes,
+// where the items of a hash are its [key, value] pairs
+func (i *rubyInterpreter) enumerableMethod(recv interface{}, items []interface{}, name string, args []interface{}, block *rubyBlock) (interface{}, bool) {
+	switch name {
+	case "each":
+		for _, item := range items {
+			i.yield(block, item)
+		}
+		return recv, true
+	case "each_with_index":
+		for idx, item := range items {
+			i.yield(block, item, idx)
+		}
+		return recv, true
+	case "each_with_object":
+		i.checkArgs(name, args, 1, 1)
+		for _, item := range items {
+			i.yield(block, item, args[0])
+		}
+		return args[0], true
+	case "map", "collect":
+		results := make([]interface{}, len(items))
+		for idx, item := range items {
+			results[idx] = i.yield(block, item)
+		}
+		return newArray(results...), true
+	case "flat_map", "collect_concat":
+		results := []interface{}{}
+		for _, item := range items {
+			value := i.yield(block, item)
+			if array, ok := value.(*rubyArray); ok {
+				results = append(results, array.items...)
+			} else {
+				results = append(results, value)
+			}
+		}
+		return newArray(results...), true
+	case "select", "filter", "reject":
+		results := []interface{}{}
+		for _, item := range items {
+			if truthy(i.yield(block, item)) == (name != "reject") {
+				results = append(results, item)
+			}
+		}
+		return newArray(results...), true
+	case "find", "detect":
+		for _, item := range items {
+			if truthy(i.yield(block, item)) {
+				return item, true
+			}
+		}
+		return nil, true
+	case "find_index":
+		for idx, item := range items {
+			if (block != nil && truthy(i.yield(block, item))) || (block == nil && len(args) == 1 && rubyEqual(item, args[0])) {
+				return idx, true
+			}
+		}
+		return nil, true
+	case "any?", "all?", "none?", "one?":
+		matches := 0
+		for _, item := range items {
+			matched := truthy(item)
+			if block != nil {
+				matched = truthy(i.yield(block, item))
+			} else if len(args) == 1 {
+				matched = i.caseEqual(args[0], item)
+			}
+			if matched {
+				matches++
+			}
+		}
+		switch name {
+		case "any?":
+			return matches > 0, true
+		case "all?":
+			return matches == len(items), true
+		case "none?":
+			return matches == 0, true
+		}
+		return matches == 1, true
+	case "count":
+		if block == nil && len(args) == 0 {
+			return len(items), true
+		}
+		count := 0
+		for _, item := range items {
+			if (block != nil && truthy(i.yield(block, item))) || (block == nil && rubyEqual(item, args[0])) {
+				count++
+			}
+		}
+		return count, true
+	case "include?", "member?":
+		i.checkArgs(name, args, 1, 1)
+		for _, item := range items {
+			if rubyEqual(item, args[0]) {
+				return true, true
+			}
+		}
+		return false, true
+	case "inject", "reduce":
+		return i.inject(items, name, args, block), true
+	case "sum":
+		var sum interface{} = 0
+		if len(args) == 1 {
+			sum = args[0]
+		}
+		for _, item := range items {
+			if block != nil {
+				item = i.yield(block, item)
+			}
+			sum = i.callMethod(sum, "+", []interface{}{item}, nil)
+		}
+		return sum, true
+	case "min", "max":
+		if len(items) == 0 {
+			return nil, true
+		}
+		var less func(a, b interface{}) bool
+		if block != nil {
+			less = func(a, b interface{}) bool {
+				return i.intArg(name, []interface{}{i.yield(block, a, b)}, 0) < 0
+			}
+		}
+		sorted := i.sortValues(items, less)
+		if name == "min" {
+			return sorted[0], true
+		}
+		return sorted[len(sorted)-1], true
+	case "min_by", "max_by", "sort_by":
+		keys := map[int]interface{}{}
+		indexed := make([]interface{}, len(items))
+		for idx, item := range items {
+			keys[idx] = i.yield(block, item)
+			indexed[idx] = idx
+		}
+		sorted := i.sortValues(indexed, func(a, b interface{}) bool {
+			return i.compare(keys[a.(int)], keys[b.(int)]) < 0
+		})
+		results := make([]interface{}, len(sorted))
+		for idx, position := range sorted {
+			results[idx] = items[position.(int)]
+		}
+		switch {
+		case name == "sort_by":
+			return newArray(results...), true
+		case len(results) == 0:
+			return nil, true
+		case name == "min_by":
+			return results[0], true
+		}
+		return results[len(results)-1], true
+	case "sort":
+		var less func(a, b interface{}) bool
+		if block != nil {
+			less = func(a, b interface{}) bool {
+				return i.intArg(name, []interface{}{i.yield(block, a, b)}, 0) < 0
+			}
+		}
+		return newArray(i.sortValues(items, less)...), true
+	case "group_by", "partition":
+		groups := newHash()
+		for _, item := range items {
+			key := i.yield(block, item)
+			if name == "partition" {
+				key = truthy(key)
+			}
+			group, found := groups.get(key)
+			if !found {
+				group = newArray()
+				groups.set(key, group)
+			}
+			group.(*rubyArray).items = append(group.(*rubyArray).items, item)
+		}
+		if name == "group_by" {
+			return groups, true
+		}
+		matching, found := groups.get(true)
+		if !found {
+			matching = newArray()
+		}
+		rest, found := groups.get(false)
+		if !found {
+			rest = newArray()
+		}
+		return newArray(matching, rest), true
+	case "each_slice", "each_cons":
+		size := i.intArg(name, args, 0)
+		if size <= 0 {
+			i.raise("ArgumentError", "invalid size")
+		}
+		slices := []interface{}{}
+		for start := 0; start < len(items); start++ {
+			end := start + size
+			if end > len(items) {
+				if name == "each_cons" {
+					break
+				}
+				end = len(items)
+			}
+			slices = append(slices, newArray(append([]interface{}{}, items[start:end]...)...))
+			if name == "each_slice" {
+				start = end - 1
+			}
+		}
+		if block == nil {
+			return newArray(slices...), true
+		}
+		for _, slice := range slices {
+			i.yield(block, slice)
+		}
+		return recv, true
+	case "take", "first":
+		if name == "first" && len(args) == 0 {
+			if len(items) == 0 {
+				return nil, true
+			}
+			return items[0], true
+		}
+		n := i.intArg(name, args, 0)
+		if n < 0 {
+			i.raise("ArgumentError", "attempt to take negative size")
+		}
+		if n > len(items) {
+			n = len(items)
+		}
+		return newArray(append([]interface{}{}, items[:n]...)...), true
+	case "drop":
+		n := i.intArg(name, args, 0)
+		if n > len(items) {
+			n = len(items)
+		}
+		return newArray(append([]interface{}{}, items[n:]...)...), true
+	case "take_while", "drop_while":
+		n := 0
+		for n < len(items) && truthy(i.yield(block, items[n])) {
+			n++
+		}
+		if name == "take_while" {
+			return newArray(append([]interface{}{}, items[:n]...)...), true
+		}
+		return newArray(append([]interface{}{}, items[n:]...)...), true
+	case "to_a", "entries":
+		return newArray(append([]interface{}{}, items...)...), true
+	case "to_h":
+		hash := newHash()
+		for _, item := range items {
+			if block != nil {
+				item = i.yield(block, item)
+			}
+			pair, ok := item.(*rubyArray)
+			if !ok || len(pair.items) != 2 {
+				i.raise("TypeError", "wrong element type %s (expected array)", className(item))
+			}
+			hash.set(pair.items[0], pair.items[1])
+		}
+		return hash, true
+	case "uniq":
+		seen := newHash()
+		results := []interface{}{}
+		for _, item := range items {
+			key := item
+			if block != nil {
+				key = i.yield(block, item)
+			}
+			if _, found := seen.get(key); !found {
+				seen.set(key, true)
+				results = append(results, item)
+			}
+		}
+		return newArray(results...), true
+	case "zip":
+		results := make([]interface{}, len(items))
+		for idx, item := range items {
+			row := []interface{}{item}
+			for _, arg := range args {
+				other, ok := arg.(*rubyArray)
+				if !ok {
+					i.raise("TypeError", "wrong argument type %s (must respond to :each)", className(arg))
+				}
+				var value interface{}
+				if idx < len(other.items) {
+					value = other.items[idx]
+				}
+				row = append(row, value)
+			}
+			results[idx] = newArray(row...)
+		}
+		return newArray(results...), true
+	case "tally":
+		counts := newHash()
+		for _, item := range items {
+			count, _ := counts.get(item)
+			n, _ := count.(int)
+			counts.set(item, n+1)
+		}
+		return counts, true
+	case "join":
+		return i.join(items, name, args), true
+	}
+	return nil, false
+}
+
+func (i *rubyInterpreter) inject(items []interface{}, name string, args []interface{}, block *rubyBlock) interface{} {
+	i.checkArgs(name, args, 0, 2)
+
+	var operator string
+	if len(args) > 0 && block == nil {
+		if sym, ok := args[len(args)-1].(rubySymbol); ok {
+			operator = string(sym)
+			args = args[:len(args)-1]
+		}
+	}
+
+	var acc interface{}
+	if len(args) == 1 {
+		acc = args[0]
+	} else if len(items) > 0 {
+		acc = items[0]
+		items = items[1:]
+	}
+
+	for _, item := range items {
+		if operator != "" {
+			acc = i.callMethod(acc, operator, []interface{}{item}, nil)
+		} else {
+			acc = i.yield(block, acc, item)
+		}
+	}
+	return acc
+}
+
+func (i *rubyInterpreter) join(items []interface{}, name string, args []interface{}) string {
+	i.checkArgs(name, args, 0, 1)
+	separator := ""
+	if len(args) == 1 && args[0] != nil {
+		separator = i.stringArg(name, args, 0)
+	}
+
+	strs := make([]string, len(items))
+	for idx, item := range items {
+		if nested, ok := item.(*rubyArray); ok {
+			strs[idx] = i.join(nested.items, name, args)
+		} else {
+			strs[idx] = toS(item)
+		}
+	}
+	return strings.Join(strs, separator)
+}
+
+func (i *rubyInterpreter) arrayArg(name string, args []interface{}) *rubyArray {
+	i.checkArgs(name, args, 1, 1)
+	array, ok := args[0].(*rubyArray)
+	if !ok {
+		i.raise("TypeError", "no implicit conversion of %s into Array", className(args[0]))
+	}
+	return array
+}
+
+func flatten(items []interface{}, depth int) []interface{} {
+	results := []interface{}{}
+	for _, item := range items {
+		if nested, ok := item.(*rubyArray); ok && depth != 0 {
+			results = append(results, flatten(nested.items, depth-1)...)
+			continue
+		}
+		results = append(results, item)
+	}
+	return results
+}
+
+func (i *rubyInterpreter) arrayMethod(a *rubyArray, name string, args []interface{}, block *rubyBlock) (interface{}, bool) {
+	switch name {
+	case "[]", "slice", "at":
+		return i.arraySlice(a, name, args), true
+	case "[]=":
+		i.checkArgs(name, args, 2, 2)
+		index := i.intArg(name, args, 0)
+		if index < 0 {
+			index += len(a.items)
+			if index < 0 {
+				i.raise("IndexError", "index %d too small for array", index-len(a.items))
+			}
+		}
+		for len(a.items) <= index {
+			a.items = append(a.items, nil)
+		}
+		a.items[index] = args[1]
+		return args[1], true
+	case "fetch":
+		i.checkArgs(name, args, 1, 2)
+		index := i.intArg(name, args, 0)
+		if index < 0 {
+			index += len(a.items)
+		}
+		if index < 0 || index >= len(a.items) {
+			switch {
+			case block != nil:
+				return i.yield(block, args[0]), true
+			case len(args) == 2:
+				return args[1], true
+			}
+			i.raise("IndexError", "index %d outside of array bounds: %d...%d", i.intArg(name, args, 0), -len(a.items), len(a.items))
+		}
+		return a.items[index], true
+	case "dig":
+		i.checkArgs(name, args, 1, -1)
+		value := i.arraySlice(a, name, args[:1])
+		if len(args) == 1 || value == nil {
+			return value, true
+		}
+		return i.callMethod(value, "dig", args[1:], nil), true
+	case "last":
+		if len(args) == 0 {
+			if len(a.items) == 0 {
+				return nil, true
+			}
+			return a.items[len(a.items)-1], true
+		}
+		n := i.intArg(name, args, 0)
+		if n > len(a.items) {
+			n = len(a.items)
+		}
+		return newArray(append([]interface{}{}, a.items[len(a.items)-n:]...)...), true
+	case "length", "size":
+		return len(a.items), true
+	case "empty?":
+		return len(a.items) == 0, true
+	case "index":
+		return i.enumerableMethod(a, a.items, "find_index", args, block)
+	case "rindex":
+		for idx := len(a.items) - 1; idx >= 0; idx-- {
+			if (block != nil && truthy(i.yield(block, a.items[idx]))) || (block == nil && len(args) == 1 && rubyEqual(a.items[idx], args[0])) {
+				return idx, true
+			}
+		}
+		return nil, true
+	case "map!", "collect!", "select!", "filter!", "reject!", "sort!", "sort_by!", "uniq!", "compact!", "flatten!", "reverse!", "shuffle!":
+		result, _ := i.callMethod(a, strings.TrimSuffix(name, "!"), args, block).(*rubyArray)
+		a.items = result.items
+		return a, true
+	case "compact":
+		results := []interface{}{}
+		for _, item := range a.items {
+			if item != nil {
+				results = append(results, item)
+			}
+		}
+		return newArray(results...), true
+	case "flatten":
+		depth := -1
+		if len(args) == 1 {
+			depth = i.intArg(name, args, 0)
+		}
+		return newArray(flatten(a.items, depth)...), true
+	case "reverse":
+		results := make([]interface{}, len(a.items))
+		for idx, item := range a.items {
+			results[len(a.items)-1-idx] = item
+		}
+		return newArray(results...), true
+	case "reverse_each":
+		for idx := len(a.items) - 1; idx >= 0; idx-- {
+			i.yield(block, a.items[idx])
+		}
+		return a, true
+	case "rotate":
+		n := 1
+		if len(args) == 1 {
+			n = i.intArg(name, args, 0)
+		}
+		if len(a.items) == 0 {
+			return newArray(), true
+		}
+		n = ((n % len(a.items)) + len(a.items)) % len(a.items)
+		return newArray(append(append([]interface{}{}, a.items[n:]...), a.items[:n]...)...), true
+	case "push", "append", "<<":
+		if name == "<<" {
+			i.checkArgs(name, args, 1, 1)
+		}
+		a.items = append(a.items, args...)
+		return a, true
+	case "unshift", "prepend":
+		a.items = append(append([]interface{}{}, args...), a.items...)
+		return a, true
+	case "insert":
+		i.checkArgs(name, args, 1, -1)
+		index := i.intArg(name, args, 0)
+		if index < 0 {
+			index += len(a.items) + 1
+		}
+		for len(a.items) < index {
+			a.items = append(a.items, nil)
+		}
+		a.items = append(a.items[:index], append(append([]interface{}{}, args[1:]...), a.items[index:]...)...)
+		return a, true
+	case "concat":
+		for _, arg := range args {
+			a.items = append(a.items, i.arrayArg(name, []interface{}{arg}).items...)
+		}
+		return a, true
+	case "pop", "shift":
+		if len(a.items) == 0 {
+			return nil, true
+		}
+		if name == "pop" {
+			item := a.items[len(a.items)-1]
+			a.items = a.items[:len(a.items)-1]
+			return item, true
+		}
+		item := a.items[0]
+		a.items = a.items[1:]
+		return item, true
+	case "delete":
+		i.checkArgs(name, args, 1, 1)
+		var deleted interface{}
+		results := []interface{}{}
+		for _, item := range a.items {
+			if rubyEqual(item, args[0]) {
+				deleted = item
+				continue
+			}
+			results = append(results, item)
+		}
+		a.items = results
+		return deleted, true
+	case "delete_if":
+		result, _ := i.enumerableMethod(a, a.items, "reject", args, block)
+		a.items = result.(*rubyArray).items
+		return a, true
+	case "clear":
+		a.items = []interface{}{}
+		return a, true
+	case "+":
+		return newArray(append(append([]interface{}{}, a.items...), i.arrayArg(name, args).items...)...), true
+	case "-", "difference":
+		other := i.arrayArg(name, args)
+		results := []interface{}{}
+		for _, item := range a.items {
+			if !containsValue(other.items, item) {
+				results = append(results, item)
+			}
+		}
+		return newArray(results...), true
+	case "&", "intersection":
+		other := i.arrayArg(name, args)
+		results := []interface{}{}
+		for _, item := range a.items {
+			if containsValue(other.items, item) && !containsValue(results, item) {
+				results = append(results, item)
+			}
+		}
+		return newArray(results...), true
+	case "|", "union":
+		results := []interface{}{}
+		for _, item := range append(append([]interface{}{}, a.items...), i.arrayArg(name, args).items...) {
+			if !containsValue(results, item) {
+				results = append(results, item)
+			}
+		}
+		return newArray(results...), true
+	case "*":
+		i.checkArgs(name, args, 1, 1)
+		if _, ok := args[0].(string); ok {
+			return i.join(a.items, "join", args), true
+		}
+		results := []interface{}{}
+		for k := 0; k < i.intArg(name, args, 0); k++ {
+			results = append(results, a.items...)
+		}
+		return newArray(results...), true
+	case "sample":
+		if len(a.items) == 0 {
+			return nil, true
+		}
+		return a.items[0], true
+	case "shuffle":
+		return newArray(append([]interface{}{}, a.items...)...), true
+	case "product":
+		results := []interface{}{newArray()}
+		for _, list := range append([]interface{}{a}, args...) {
+			other := i.arrayArg(name, []interface{}{list})
+			next := []interface{}{}
+			for _, prefix := range results {
+				for _, item := range other.items {
+					next = append(next, newArray(append(append([]interface{}{}, prefix.(*rubyArray).items...), item)...))
+				}
+			}
+			results = next
+		}
+		return newArray(results...), true
+	case "transpose":
+		if len(a.items) == 0 {
+			return newArray(), true
+		}
+		first := i.arrayArg(name, a.items[:1])
+		results := make([]interface{}, len(first.items))
+		for col := range first.items {
+			row := []interface{}{}
+			for _, item := range a.items {
+				other := i.arrayArg(name, []interface{}{item})
+				if len(other.items) != len(first.items) {
+					i.raise("IndexError", "element size differs (%d should be %d)", len(other.items), len(first.items))
+				}
+				row = append(row, other.items[col])
+			}
+			results[col] = newArray(row...)
+		}
+		return newArray(results...), true
+	case "assoc":
+		i.checkArgs(name, args, 1, 1)
+		for _, item := range a.items {
+			if pair, ok := item.(*rubyArray); ok && len(pair.items) > 0 && rubyEqual(pair.items[0], args[0]) {
+				return pair, true
+			}
+		}
+		return nil, true
+	case "values_at":
+		results := []interface{}{}
+		for _, arg := range args {
+			results = append(results, i.arraySlice(a, name, []interface{}{arg}))
+		}
+		return newArray(results...), true
+	case "to_ary":
+		return a, true
+	}
+
+	return i.enumerableMethod(a, a.items, name, args, block)
+}
+
+func containsValue(items []interface{}, value interface{}) bool {
+	for _, item := range items {
+		if rubyEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *rubyInterpreter) arraySlice(a *rubyArray, name string, args []interface{}) interface{} {
+	i.checkArgs(name, args, 1, 2)
+
+	if r, ok := args[0].(*rubyRange); ok {
+		start, length, ok := rangeBounds(r, len(a.items))
+		if !ok {
+			return nil
+		}
+		return newArray(append([]interface{}{}, a.items[start:start+length]...)...)
+	}
+
+	start := i.intArg(name, args, 0)
+	if start < 0 {
+		start += len(a.items)
+	}
+	if len(args) == 2 {
+		length := i.intArg(name, args, 1)
+		if start < 0 || start > len(a.items) || length < 0 {
+			return nil
+		}
+		if start+length > len(a.items) {
+			length = len(a.items) - start
+		}
+		return newArray(append([]interface{}{}, a.items[start:start+length]...)...)
+	}
+	if start < 0 || start >= len(a.items) {
+		return nil
+	}
+	return a.items[start]
+}
+
+// hashResult converts the pairs selected by an Enumerable method back to a hash
+func hashResult(pairs interface{}) *rubyHash {
+	hash := newHash()
+	for _, item := range pairs.(*rubyArray).items {
+		pair := item.(*rubyArray)
+		hash.set(pair.items[0], pair.items[1])
+	}
+	return hash
+}
+
+func (i *rubyInterpreter) hashMethod(h *rubyHash, name string, args []interface{}, block *rubyBlock) (interface{}, bool) {
+	switch name {
+	case "[]":
+		i.checkArgs(name, args, 1, 1)
+		value, _ := h.get(args[0])
+		return value, true
+	case "[]=", "store":
+		i.checkArgs(name, args, 2, 2)
+		h.set(args[0], args[1])
+		return args[1], true
+	case "fetch":
+		i.checkArgs(name, args, 1, 2)
+		value, found := h.get(args[0])
+		if !found {
+			switch {
+			case block != nil:
+				return i.yield(block, args[0]), true
+			case len(args) == 2:
+				return args[1], true
+			}
+			i.raise("KeyError", "key not found: %s", inspect(args[0]))
+		}
+		return value, true
+	case "dig":
+		i.checkArgs(name, args, 1, -1)
+		value, _ := h.get(args[0])
+		if len(args) == 1 || value == nil {
+			return value, true
+		}
+		return i.callMethod(value, "dig", args[1:], nil), true
+	case "key?", "has_key?", "include?", "member?":
+		i.checkArgs(name, args, 1, 1)
+		_, found := h.get(args[0])
+		return found, true
+	case "value?", "has_value?":
+		i.checkArgs(name, args, 1, 1)
+		return containsValue(h.values, args[0]), true
+	case "key":
+		i.checkArgs(name, args, 1, 1)
+		for idx, value := range h.values {
+			if rubyEqual(value, args[0]) {
+				return h.keys[idx], true
+			}
+		}
+		return nil, true
+	case "keys":
+		return newArray(append([]interface{}{}, h.keys...)...), true
+	case "values":
+		return newArray(append([]interface{}{}, h.values...)...), true
+	case "values_at", "fetch_values":
+		results := []interface{}{}
+		for _, key := range args {
+			if name == "fetch_values" {
+				value, _ := i.hashMethod(h, "fetch", []interface{}{key}, block)
+				results = append(results, value)
+				continue
+			}
+			value, _ := h.get(key)
+			results = append(results, value)
+		}
+		return newArray(results...), true
+	case "size", "length":
+		return h.len(), true
+	case "empty?":
+		return h.len() == 0, true
+	case "each_pair":
+		return i.enumerableMethod(h, h.pairs(), "each", args, block)
+	case "each_key":
+		for _, key := range h.keys {
+			i.yield(block, key)
+		}
+		return h, true
+	case "each_value":
+		for _, value := range h.values {
+			i.yield(block, value)
+		}
+		return h, true
+	case "select", "filter", "reject":
+		result, _ := i.enumerableMethod(h, h.pairs(), name, args, block)
+		return hashResult(result), true
+	case "min_by", "max_by", "find", "detect":
+		return i.enumerableMethod(h, h.pairs(), name, args, block)
+	case "merge", "update", "merge!":
+		target := h
+		if name == "merge" {
+			target = h.dup()
+		}
+		for _, arg := range args {
+			other, ok := arg.(*rubyHash)
+			if !ok {
+				i.raise("TypeError", "no implicit conversion of %s into Hash", className(arg))
+			}
+			for idx, key := range other.keys {
+				value := other.values[idx]
+				if current, found := target.get(key); found && block != nil {
+					value = i.yield(block, key, current, value)
+				}
+				target.set(key, value)
+			}
+		}
+		return target, true
+	case "delete":
+		i.checkArgs(name, args, 1, 1)
+		value, _ := h.delete(args[0])
+		return value, true
+	case "delete_if", "keep_if", "select!", "reject!":
+		keep := name == "keep_if" || name == "select!"
+		for idx := 0; idx < len(h.keys); {
+			if truthy(i.yield(block, h.keys[idx], h.values[idx])) != keep {
+				h.delete(h.keys[idx])
+				continue
+			}
+			idx++
+		}
+		return h, true
+	case "invert":
+		inverted := newHash()
+		for idx, key := range h.keys {
+			inverted.set(h.values[idx], key)
+		}
+		return inverted, true
+	case "transform_values", "transform_keys":
+		transformed := newHash()
+		for idx, key := range h.keys {
+			value := h.values[idx]
+			if name == "transform_values" {
+				value = i.yield(block, value)
+			} else {
+				key = i.yield(block, key)
+			}
+			transformed.set(key, value)
+		}
+		return transformed, true
+	case "slice", "except":
+		result := newHash()
+		for idx, key := range h.keys {
+			if containsValue(args, key) == (name == "slice") {
+				result.set(key, h.values[idx])
+			}
+		}
+		return result, true
+	case "compact":
+		result := newHash()
+		for idx, key := range h.keys {
+			if h.values[idx] != nil {
+				result.set(key, h.values[idx])
+			}
+		}
+		return result, true
+	case "to_h":
+		if block == nil {
+			return h, true
+		}
+	case "to_hash":
+		return h, true
+	case "clear":
+		*h = *newHash()
+		return h, true
+	case "flatten":
+		return newArray(flatten(h.pairs(), 1)...), true
+	}
+
+	return i.enumerableMethod(h, h.pairs(), name, args, block)
+}
+
+func (i *rubyInterpreter) rangeMethod(r *rubyRange, name string, args []interface{}, block *rubyBlock) (interface{}, bool) {
+	switch name {
+	case "include?", "member?", "cover?", "===":
+		i.checkArgs(name, args, 1, 1)
+		return r.includes(args[0]), true
+	case "first", "begin", "min":
+		if len(args) == 0 {
+			if name == "min" && len(r.items()) == 0 {
+				return nil, true
+			}
+			return r.low, true
+		}
+	case "last", "end", "max":
+		if len(args) == 0 {
+			if name == "max" {
+				items := r.items()
+				if len(items) == 0 {
+					return nil, true
+				}
+				return items[len(items)-1], true
+			}
+			return r.high, true
+		}
+		items := r.items()
+		n := i.intArg(name, args, 0)
+		if n > len(items) {
+			n = len(items)
+		}
+		return newArray(items[len(items)-n:]...), true
+	case "size", "count", "length":
+		if name != "count" || (block == nil && len(args) == 0) {
+			return len(r.items()), true
+		}
+	case "exclude_end?":
+		return r.exclusive, true
+	case "step", "each_slice":
+		if name == "step" {
+			step := i.intArg(name, args, 0)
+			if step <= 0 {
+				i.raise("ArgumentError", "step can't be negative")
+			}
+			items := []interface{}{}
+			for idx, item := range r.items() {
+				if idx%step == 0 {
+					items = append(items, item)
+				}
+			}
+			if block == nil {
+				return newArray(items...), true
+			}
+			for _, item := range items {
+				i.yield(block, item)
+			}
+			return r, true
+		}
+	}
+
+	return i.enumerableMethod(r, r.items(), name, args, block)
+}
@@ -0,0 +1,325 @@
+package erbrenderer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// templateContext provides the methods of the ruby renderer's TemplateEvaluationContext to templates
+type templateContext struct {
+	name          interface{}
+	index         interface{}
+	rawProperties *rubyHash
+	properties    interface{}
+	spec          interface{}
+	links         *rubyHash
+}
+
+// elseBlock is returned by if_p and if_link to render a fallback when the properties or the link are missing
+type elseBlock struct {
+	active bool
+
+	// properties are looked up by else_if_p
+	properties *rubyHash
+}
+
+// templateLink is returned by link() to give templates the properties and instances of a consumed link
+type templateLink struct {
+	name       string
+	properties *rubyHash
+	instances  interface{}
+	address    interface{}
+}
+
+// newTemplateContext merges the properties of the context like the ruby renderer:
+// cluster properties override global properties, which override the defaults of the job spec
+func newTemplateContext(spec *rubyHash) *templateContext {
+	context := &templateContext{}
+
+	if job, ok := hashGet(spec, "job").(*rubyHash); ok {
+		context.name, _ = job.get("name")
+	}
+	context.index, _ = spec.get("index")
+
+	globalProperties, _ := hashGet(spec, "global_properties").(*rubyHash)
+	clusterProperties, _ := hashGet(spec, "cluster_properties").(*rubyHash)
+	mergedProperties := deepMerge(globalProperties, clusterProperties)
+
+	properties := newHash()
+	if defaultProperties, ok := hashGet(spec, "default_properties").(*rubyHash); ok {
+		for i, name := range defaultProperties.keys {
+			copyProperty(properties, mergedProperties, toS(name), defaultProperties.values[i])
+		}
+	}
+
+	context.rawProperties = properties
+	context.properties = toOpenStruct(properties)
+	context.spec = toOpenStruct(spec)
+	context.links, _ = hashGet(spec, "links").(*rubyHash)
+	return context
+}
+
+func hashGet(hash *rubyHash, key string) interface{} {
+	if hash == nil {
+		return nil
+	}
+	value, _ := hash.get(key)
+	return value
+}
+
+func deepMerge(dst *rubyHash, src *rubyHash) *rubyHash {
+	merged := newHash()
+	if dst != nil {
+		merged = dst.dup()
+	}
+	if src == nil {
+		return merged
+	}
+
+	for i, key := range src.keys {
+		srcValue := src.values[i]
+		dstValue, _ := merged.get(key)
+
+		dstHash, dstIsHash := dstValue.(*rubyHash)
+		srcHash, srcIsHash := srcValue.(*rubyHash)
+		if dstIsHash && srcIsHash {
+			merged.set(key, deepMerge(dstHash, srcHash))
+		} else {
+			merged.set(key, srcValue)
+		}
+	}
+	return merged
+}
+
+// copyProperty copies the dotted property name from src into dst, or its default when src doesn't have it
+func copyProperty(dst *rubyHash, src *rubyHash, name string, defaultValue interface{}) {
+	keys := strings.Split(name, ".")
+
+	value := lookupProperty(src, name)
+
+	dstRef := dst
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := hashGet(dstRef, key).(*rubyHash)
+		if !ok {
+			next = newHash()
+			dstRef.set(key, next)
+		}
+		dstRef = next
+	}
+
+	if value == nil {
+		value = defaultValue
+	}
+	dstRef.set(keys[len(keys)-1], value)
+}
+
+func lookupProperty(properties *rubyHash, name string) interface{} {
+	var ref interface{} = properties
+	for _, key := range strings.Split(name, ".") {
+		hash, ok := ref.(*rubyHash)
+		if !ok {
+			return nil
+		}
+		ref = hashGet(hash, key)
+		if ref == nil {
+			return nil
+		}
+	}
+	return ref
+}
+
+// propertyNames converts the first argument of p and if_p to a list of names
+func (i *rubyInterpreter) propertyNames(value interface{}) []string {
+	values := []interface{}{value}
+	if array, ok := value.(*rubyArray); ok {
+		values = array.items
+	}
+
+	names := make([]string, len(values))
+	for idx, name := range values {
+		names[idx] = toS(name)
+	}
+	return names
+}
+
+func (i *rubyInterpreter) p(properties *rubyHash, args []interface{}) interface{} {
+	i.checkArgs("p", args, 1, 2)
+
+	names := i.propertyNames(args[0])
+	for _, name := range names {
+		value := lookupProperty(properties, name)
+		if value != nil {
+			return value
+		}
+	}
+
+	if len(args) == 2 {
+		return args[1]
+	}
+	panic(&rubyError{
+		class:             "TemplateEvaluationContext::UnknownProperty",
+		message:           fmt.Sprintf("Can't find property '%s'", strings.Join(names, "', or '")),
+		line:              i.line,
+		unknownProperties: names,
+	})
+}
+
+func (i *rubyInterpreter) ifP(properties *rubyHash, args []interface{}, block *rubyBlock) interface{} {
+	values := []interface{}{}
+	for _, name := range args {
+		value := lookupProperty(properties, toS(name))
+		if value == nil {
+			return &elseBlock{active: true, properties: properties}
+		}
+		values = append(values, value)
+	}
+
+	i.yield(block, values...)
+	return &elseBlock{active: false}
+}
+
+// findLink returns the link consumed by the job, or nil when it is not provided
+func (i *rubyInterpreter) findLink(name string) *templateLink {
+	link, ok := hashGet(i.context.links, name).(*rubyHash)
+	if !ok {
+		return nil
+	}
+
+	properties, ok := hashGet(link, "properties").(*rubyHash)
+	if !ok {
+		properties = newHash()
+	}
+
+	instances := newArray()
+	if linkInstances, ok := hashGet(link, "instances").(*rubyArray); ok {
+		instances = linkInstances
+	}
+
+	return &templateLink{
+		name:       name,
+		properties: properties,
+		instances:  toOpenStruct(instances),
+		address:    hashGet(link, "address"),
+	}
+}
+
+func (i *rubyInterpreter) link(args []interface{}) interface{} {
+	i.checkArgs("link", args, 1, 1)
+
+	link := i.findLink(toS(args[0]))
+	if link == nil {
+		i.raise("TemplateEvaluationContext::UnknownLink", "Can't find link '%s'", toS(args[0]))
+	}
+	return link
+}
+
+func (i *rubyInterpreter) ifLink(args []interface{}, block *rubyBlock) interface{} {
+	i.checkArgs("if_link", args, 1, 1)
+
+	link := i.findLink(toS(args[0]))
+	if link == nil {
+		return &elseBlock{active: true, properties: i.context.rawProperties}
+	}
+
+	i.yield(block, link)
+	return &elseBlock{active: false}
+}
+
+func (i *rubyInterpreter) linkMethod(l *templateLink, name string, args []interface{}, block *rubyBlock) (interface{}, bool) {
+	switch name {
+	case "p":
+		return i.p(l.properties, args), true
+	case "if_p":
+		return i.ifP(l.properties, args, block), true
+	case "instances":
+		i.checkArgs(name, args, 0, 0)
+		return l.instances, true
+	case "address":
+		i.checkArgs(name, args, 0, 0)
+		return l.address, true
+	case "name":
+		i.checkArgs(name, args, 0, 0)
+		return l.name, true
+	}
+	return nil, false
+}
+
+func (i *rubyInterpreter) elseBlockMethod(e *elseBlock, name string, args []interface{}, block *rubyBlock) (interface{}, bool) {
+	switch name {
+	case "else":
+		if e.active {
+			i.yield(block)
+		}
+		return nil, true
+	case "else_if_p":
+		if e.active {
+			return i.ifP(e.properties, args, block), true
+		}
+		return &elseBlock{active: false}, true
+	}
+	return nil, false
+}
+
+// callSelf calls a method of the template context, e.g. p("name")
+func (i *rubyInterpreter) callSelf(name string, args []interface{}, block *rubyBlock) interface{} {
+	switch name {
+	case "p":
+		return i.p(i.context.rawProperties, args)
+	case "if_p":
+		return i.ifP(i.context.rawProperties, args, block)
+	case "link":
+		return i.link(args)
+	case "if_link":
+		return i.ifLink(args, block)
+	case "spec":
+		i.checkArgs(name, args, 0, 0)
+		return i.context.spec
+	case "name":
+		i.checkArgs(name, args, 0, 0)
+		return i.context.name
+	case "index":
+		i.checkArgs(name, args, 0, 0)
+		return i.context.index
+	case "properties":
+		i.checkArgs(name, args, 0, 0)
+		return i.context.properties
+	case "raw_properties":
+		i.checkArgs(name, args, 0, 0)
+		return i.context.rawProperties
+	case "raise", "fail":
+		i.checkArgs(name, args, 0, 2)
+		switch {
+		case len(args) == 0:
+			i.raise("RuntimeError", "unhandled exception")
+		case len(args) == 2:
+			i.raise(toS(args[0]), "%s", toS(args[1]))
+		}
+		switch exception := args[0].(type) {
+		case rubyClass:
+			i.raise(string(exception), "%s", string(exception))
+		case *rubyError:
+			panic(exception)
+		}
+		i.raise("RuntimeError", "%s", toS(args[0]))
+	case "format", "sprintf":
+		i.checkArgs(name, args, 1, -1)
+		return i.format(toS(args[0]), args[1:])
+	case "require":
+		return true
+	case "loop":
+		for {
+			i.yield(block)
+		}
+	case "Integer", "Float", "String", "Array", "Hash":
+		i.checkArgs(name, args, 1, 2)
+		return i.convert(name, args[0])
+	case "puts", "print":
+		return nil
+	}
+
+	if len(args) == 0 && block == nil {
+		i.unsupported("undefined local variable or method `%s' for #<TemplateEvaluationContext>", name)
+	}
+	i.unsupported("undefined method `%s' for #<TemplateEvaluationContext>", name)
+	return nil
+}
@@ -0,0 +1,556 @@
+package erbrenderer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// rubyError is a ruby exception raised while compiling or evaluating a template
+type rubyError struct {
+	class   string
+	message string
+	line    int
+
+	// unknownProperties are the names given to p() when raising TemplateEvaluationContext::UnknownProperty
+	unknownProperties []string
+}
+
+func (e *rubyError) Error() string {
+	return fmt.Sprintf("#<%s: %s>", e.class, e.message)
+}
+
+func rubySyntaxError(line int, format string, args ...interface{}) *rubyError {
+	return &rubyError{class: "SyntaxError", message: fmt.Sprintf(format, args...), line: line}
+}
+
+// unsupportedClass is the class of the exceptions raised for ruby the interpreter does not implement,
+// e.g. methods missing from its method tables, enumerators or 'def'. Templates cannot rescue it,
+// so that the fallback renderer renders the template with ruby instead of rendering a wrong output.
+const unsupportedClass = "UnsupportedByNativeRenderer"
+
+func rubyUnsupportedError(line int, format string, args ...interface{}) *rubyError {
+	return &rubyError{class: unsupportedClass, message: fmt.Sprintf(format, args...), line: line}
+}
+
+// exceptionParents is the hierarchy of the exceptions raised by templates and the interpreter.
+// Exceptions raised with other class names are StandardErrors.
+var exceptionParents = map[string]string{
+	"StandardError":       "Exception",
+	"ScriptError":         "Exception",
+	"SyntaxError":         "ScriptError",
+	"NotImplementedError": "ScriptError",
+	"NoMethodError":       "NameError",
+	"KeyError":            "IndexError",
+	"StopIteration":       "IndexError",
+	"FloatDomainError":    "RangeError",
+}
+
+func isException(err *rubyError, class string) bool {
+	for c := err.class; c != ""; {
+		if c == class {
+			return true
+		}
+		switch parent, found := exceptionParents[c]; {
+		case c == "Exception":
+			c = ""
+		case found:
+			c = parent
+		default:
+			c = "StandardError"
+		}
+	}
+	return false
+}
+
+type rubyEnv struct {
+	vars   map[string]interface{}
+	parent *rubyEnv
+}
+
+func newRubyEnv(parent *rubyEnv) *rubyEnv {
+	return &rubyEnv{vars: map[string]interface{}{}, parent: parent}
+}
+
+func (e *rubyEnv) lookup(name string) (interface{}, bool) {
+	for env := e; env != nil; env = env.parent {
+		if value, found := env.vars[name]; found {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// assign sets the variable in the scope defining it, or defines it in the current scope
+func (e *rubyEnv) assign(name string, value interface{}) {
+	for env := e; env != nil; env = env.parent {
+		if _, found := env.vars[name]; found {
+			env.vars[name] = value
+			return
+		}
+	}
+	e.vars[name] = value
+}
+
+// rubyBlock is a do...end or {} block bound to the scope it was written in, or a &:symbol block
+type rubyBlock struct {
+	node   *blockNode
+	env    *rubyEnv
+	symbol rubySymbol
+}
+
+type nextSignal struct {
+	value interface{}
+}
+
+type breakSignal struct {
+	block *rubyBlock
+	value interface{}
+}
+
+// rubyInterpreter evaluates the syntax tree of a template against a template context, writing to out
+type rubyInterpreter struct {
+	context *templateContext
+	out     strings.Builder
+	line    int
+}
+
+func newRubyInterpreter(context *templateContext) *rubyInterpreter {
+	return &rubyInterpreter{context: context}
+}
+
+func (i *rubyInterpreter) run(program node) (output string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch signal := r.(type) {
+			case *rubyError:
+				if signal.line == 0 {
+					signal.line = i.line
+				}
+				err = signal
+			case nextSignal, breakSignal:
+				err = rubySyntaxError(i.line, "Invalid next or break outside of a block")
+			default:
+				panic(r)
+			}
+		}
+	}()
+
+	i.eval(program, newRubyEnv(nil))
+	return i.out.String(), nil
+}
+
+func (i *rubyInterpreter) raise(class string, format string, args ...interface{}) {
+	panic(&rubyError{class: class, message: fmt.Sprintf(format, args...), line: i.line})
+}
+
+func (i *rubyInterpreter) unsupported(format string, args ...interface{}) {
+	panic(rubyUnsupportedError(i.line, format, args...))
+}
+
+func (i *rubyInterpreter) eval(n node, env *rubyEnv) interface{} {
+	switch n := n.(type) {
+	case *textNode:
+		i.out.WriteString(n.text)
+		return nil
+
+	case *outputNode:
+		i.line = n.line
+		i.out.WriteString(toS(i.eval(n.expr, env)))
+		return nil
+
+	case *seqNode:
+		var last interface{}
+		for _, stmt := range n.stmts {
+			last = i.eval(stmt, env)
+		}
+		return last
+
+	case *literalNode:
+		return n.value
+
+	case *strNode:
+		var buf strings.Builder
+		for _, part := range n.parts {
+			buf.WriteString(toS(i.eval(part, env)))
+		}
+		return buf.String()
+
+	case *arrayNode:
+		return newArray(i.evalArgs(n.elems, env)...)
+
+	case *hashNode:
+		hash := newHash()
+		for idx, key := range n.keys {
+			hash.set(i.eval(key, env), i.eval(n.values[idx], env))
+		}
+		return hash
+
+	case *rangeNode:
+		low, lowOk := i.eval(n.low, env).(int)
+		high, highOk := i.eval(n.high, env).(int)
+		if !lowOk || !highOk {
+			i.unsupported("only integer ranges are supported")
+		}
+		return &rubyRange{low: low, high: high, exclusive: n.exclusive}
+
+	case *varNode:
+		value, _ := env.lookup(n.name)
+		return value
+
+	case *constNode:
+		i.line = n.line
+		if !rubyClasses[n.name] {
+			i.unsupported("uninitialized constant %s", n.name)
+		}
+		return rubyClass(n.name)
+
+	case *selfNode:
+		return i.context
+
+	case *callNode:
+		return i.evalCall(n, env)
+
+	case *assignNode:
+		value := i.eval(n.value, env)
+		env.assign(n.name, value)
+		return value
+
+	case *opAssignNode:
+		return i.evalOpAssign(n, env)
+
+	case *andNode:
+		left := i.eval(n.left, env)
+		if !truthy(left) {
+			return left
+		}
+		return i.eval(n.right, env)
+
+	case *orNode:
+		left := i.eval(n.left, env)
+		if truthy(left) {
+			return left
+		}
+		return i.eval(n.right, env)
+
+	case *notNode:
+		return !truthy(i.eval(n.expr, env))
+
+	case *ifNode:
+		if truthy(i.eval(n.cond, env)) != n.negative {
+			return i.eval(n.then, env)
+		}
+		if n.els != nil {
+			return i.eval(n.els, env)
+		}
+		return nil
+
+	case *whileNode:
+		for truthy(i.eval(n.cond, env)) != n.until {
+			i.eval(n.body, env)
+		}
+		return nil
+
+	case *caseNode:
+		return i.evalCase(n, env)
+
+	case *rescueNode:
+		return i.evalRescue(n, env)
+
+	case *nextNode:
+		var value interface{}
+		if n.value != nil {
+			value = i.eval(n.value, env)
+		}
+		panic(nextSignal{value: value})
+
+	case *breakNode:
+		var value interface{}
+		if n.value != nil {
+			value = i.eval(n.value, env)
+		}
+		block, found := env.lookup(currentBlockVar)
+		if !found {
+			i.raise("SyntaxError", "Invalid break outside of a block")
+		}
+		panic(breakSignal{block: block.(*rubyBlock), value: value})
+
+	case *splatNode:
+		i.raise("SyntaxError", "unexpected splat")
+	}
+
+	panic(fmt.Sprintf("unknown node %#v", n))
+}
+
+// currentBlockVar holds the block being yielded to in the scope of its parameters, for break.
+// It can't clash with ruby variables.
+const currentBlockVar = "@block"
+
+func (i *rubyInterpreter) evalArgs(nodes []node, env *rubyEnv) []interface{} {
+	args := []interface{}{}
+	for _, n := range nodes {
+		if splat, ok := n.(*splatNode); ok {
+			switch value := i.eval(splat.expr, env).(type) {
+			case *rubyArray:
+				args = append(args, value.items...)
+			case nil:
+			default:
+				args = append(args, value)
+			}
+			continue
+		}
+		args = append(args, i.eval(n, env))
+	}
+	return args
+}
+
+func (i *rubyInterpreter) evalCall(n *callNode, env *rubyEnv) (result interface{}) {
+	var recv interface{}
+	if n.recv != nil {
+		recv = i.eval(n.recv, env)
+		if n.safeNav && recv == nil {
+			return nil
+		}
+	}
+
+	args := i.evalArgs(n.args, env)
+
+	var block *rubyBlock
+	if n.block != nil {
+		block = &rubyBlock{node: n.block, env: env}
+	} else if n.blockArg != nil {
+		switch blockArg := i.eval(n.blockArg, env).(type) {
+		case rubySymbol:
+			block = &rubyBlock{symbol: blockArg}
+		case nil:
+		default:
+			i.line = n.line
+			i.raise("TypeError", "wrong argument type %s (expected Proc)", className(blockArg))
+		}
+	}
+
+	i.line = n.line
+
+	if block != nil {
+		defer func() {
+			if r := recover(); r != nil {
+				if signal, ok := r.(breakSignal); ok && signal.block == block {
+					result = signal.value
+					return
+				}
+				panic(r)
+			}
+		}()
+	}
+
+	if n.recv == nil {
+		return i.callSelf(n.name, args, block)
+	}
+
+	result = i.callMethod(recv, n.name, args, block)
+
+	// strings are immutable here, so appending to a variable reassigns it
+	if _, isString := recv.(string); isString && (n.name == "<<" || n.name == "concat") {
+		if variable, ok := n.recv.(*varNode); ok {
+			env.assign(variable.name, result)
+		}
+	}
+	return result
+}
+
+func (i *rubyInterpreter) evalOpAssign(n *opAssignNode, env *rubyEnv) interface{} {
+	var current interface{}
+	var set func(value interface{})
+
+	switch target := n.target.(type) {
+	case *varNode:
+		current, _ = env.lookup(target.name)
+		set = func(value interface{}) { env.assign(target.name, value) }
+	case *callNode:
+		recv := i.eval(target.recv, env)
+		args := i.evalArgs(target.args, env)
+		i.line = n.line
+		if target.name == "[]" {
+			current = i.callMethod(recv, "[]", args, nil)
+			set = func(value interface{}) { i.callMethod(recv, "[]=", append(args, value), nil) }
+		} else {
+			current = i.callMethod(recv, target.name, nil, nil)
+			set = func(value interface{}) { i.callMethod(recv, target.name+"=", []interface{}{value}, nil) }
+		}
+	}
+
+	var value interface{}
+	switch n.op {
+	case "||":
+		if truthy(current) {
+			return current
+		}
+		value = i.eval(n.value, env)
+	case "&&":
+		if !truthy(current) {
+			return current
+		}
+		value = i.eval(n.value, env)
+	default:
+		operand := i.eval(n.value, env)
+		i.line = n.line
+		value = i.callMethod(current, n.op, []interface{}{operand}, nil)
+	}
+	set(value)
+	return value
+}
+
+func (i *rubyInterpreter) evalCase(n *caseNode, env *rubyEnv) interface{} {
+	var subject interface{}
+	if n.subject != nil {
+		subject = i.eval(n.subject, env)
+	}
+
+	for _, when := range n.whens {
+		for _, pattern := range i.evalArgs(when.values, env) {
+			i.line = n.line
+			matched := truthy(pattern)
+			if n.subject != nil {
+				matched = i.caseEqual(pattern, subject)
+			}
+			if matched {
+				return i.eval(when.body, env)
+			}
+		}
+	}
+
+	if n.els != nil {
+		return i.eval(n.els, env)
+	}
+	return nil
+}
+
+func (i *rubyInterpreter) evalRescue(n *rescueNode, env *rubyEnv) (result interface{}) {
+	if n.ensure != nil {
+		defer i.eval(n.ensure, env)
+	}
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		rubyErr, ok := r.(*rubyError)
+		if !ok {
+			panic(r)
+		}
+
+		for _, clause := range n.clauses {
+			if i.rescues(clause, rubyErr, env) {
+				if clause.name != "" {
+					env.assign(clause.name, rubyErr)
+				}
+				result = i.eval(clause.body, env)
+				return
+			}
+		}
+		panic(r)
+	}()
+
+	return i.eval(n.body, env)
+}
+
+func (i *rubyInterpreter) rescues(clause rescueClause, err *rubyError, env *rubyEnv) bool {
+	if err.class == unsupportedClass {
+		return false
+	}
+	if len(clause.classes) == 0 {
+		return isException(err, "StandardError")
+	}
+	for _, class := range i.evalArgs(clause.classes, env) {
+		if isException(err, toS(class)) {
+			return true
+		}
+	}
+	return false
+}
+
+// caseEqual implements ===, as used by case/when
+func (i *rubyInterpreter) caseEqual(pattern interface{}, value interface{}) bool {
+	return truthy(i.callMethod(pattern, "===", []interface{}{value}, nil))
+}
+
+// yield calls a block, spreading a single array over several parameters like ruby
+func (i *rubyInterpreter) yield(block *rubyBlock, args ...interface{}) (result interface{}) {
+	// ruby returns an enumerator when a block is not given, e.g. each_with_index.map
+	if block == nil {
+		i.unsupported("enumerators are not supported, a block must be given")
+	}
+
+	if block.node == nil {
+		if len(args) == 0 {
+			i.raise("ArgumentError", "no receiver given")
+		}
+		return i.callMethod(args[0], string(block.symbol), args[1:], nil)
+	}
+
+	env := newRubyEnv(block.env)
+	env.vars[currentBlockVar] = block
+
+	params := block.node.params
+	if len(params) > 1 && len(args) == 1 {
+		if array, ok := args[0].(*rubyArray); ok {
+			args = array.items
+		}
+	}
+	bindBlockParams(env, params, args)
+
+	defer func() {
+		if r := recover(); r != nil {
+			if signal, ok := r.(nextSignal); ok {
+				result = signal.value
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	return i.eval(block.node.body, env)
+}
+
+func bindBlockParams(env *rubyEnv, params []blockParam, args []interface{}) {
+	for idx, param := range params {
+		var value interface{}
+		if idx < len(args) {
+			value = args[idx]
+		}
+
+		if param.params != nil {
+			items := []interface{}{value}
+			if array, ok := value.(*rubyArray); ok {
+				items = array.items
+			}
+			bindBlockParams(env, param.params, items)
+			continue
+		}
+		env.vars[param.name] = value
+	}
+}
+
+func (i *rubyInterpreter) checkArgs(name string, args []interface{}, min int, max int) {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		expected := fmt.Sprintf("%d", min)
+		switch {
+		case max < 0:
+			expected += "+"
+		case max > min:
+			expected += fmt.Sprintf("..%d", max)
+		}
+		i.raise("ArgumentError", "wrong number of arguments calling `%s' (given %d, expected %s)", name, len(args), expected)
+	}
+}
+
+func describe(v interface{}) string {
+	if v == nil {
+		return "nil:NilClass"
+	}
+	description := inspect(v)
+	if len(description) > 65 {
+		description = "#<" + className(v) + ">"
+	}
+	return description + ":" + className(v)
+}
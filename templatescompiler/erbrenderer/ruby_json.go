@@ -0,0 +1,173 @@
+package erbrenderer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// toJSON generates JSON like ruby's JSON.generate and JSON.pretty_generate
+func (i *rubyInterpreter) toJSON(v interface{}, pretty bool) string {
+	var buf strings.Builder
+	i.writeJSON(&buf, v, pretty, "")
+	return buf.String()
+}
+
+func (i *rubyInterpreter) writeJSON(buf *strings.Builder, v interface{}, pretty bool, indent string) {
+	switch value := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(value))
+	case int:
+		buf.WriteString(strconv.Itoa(value))
+	case float64:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			i.raise("JSON::GeneratorError", "%s not allowed in JSON", formatFloat(value))
+		}
+		buf.WriteString(formatFloat(value))
+	case string:
+		writeJSONString(buf, value)
+	case *rubyArray:
+		if len(value.items) == 0 {
+			buf.WriteString("[]")
+			return
+		}
+		buf.WriteString("[")
+		for idx, item := range value.items {
+			if idx > 0 {
+				buf.WriteString(",")
+			}
+			if pretty {
+				buf.WriteString("\n" + indent + "  ")
+			}
+			i.writeJSON(buf, item, pretty, indent+"  ")
+		}
+		if pretty {
+			buf.WriteString("\n" + indent)
+		}
+		buf.WriteString("]")
+	case *rubyHash:
+		if value.len() == 0 {
+			buf.WriteString("{}")
+			return
+		}
+		buf.WriteString("{")
+		for idx, key := range value.keys {
+			if idx > 0 {
+				buf.WriteString(",")
+			}
+			if pretty {
+				buf.WriteString("\n" + indent + "  ")
+			}
+			writeJSONString(buf, toS(key))
+			buf.WriteString(":")
+			if pretty {
+				buf.WriteString(" ")
+			}
+			i.writeJSON(buf, value.values[idx], pretty, indent+"  ")
+		}
+		if pretty {
+			buf.WriteString("\n" + indent)
+		}
+		buf.WriteString("}")
+	case *openStruct:
+		i.writeJSON(buf, value.fields, pretty, indent)
+	default:
+		writeJSONString(buf, toS(v))
+	}
+}
+
+// writeJSONString escapes like ruby's JSON, which unlike encoding/json doesn't escape HTML characters
+func writeJSONString(buf *strings.Builder, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// decodeJSON decodes JSON into ruby values, keeping the order of object keys
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	value, err := decodeJSONValue(decoder)
+	if err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after JSON value at offset %d", decoder.InputOffset())
+	}
+	return value, nil
+}
+
+func decodeJSONValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch value := token.(type) {
+	case json.Delim:
+		switch value {
+		case '[':
+			array := newArray()
+			for decoder.More() {
+				item, err := decodeJSONValue(decoder)
+				if err != nil {
+					return nil, err
+				}
+				array.items = append(array.items, item)
+			}
+			_, err = decoder.Token()
+			return array, err
+		case '{':
+			hash := newHash()
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				item, err := decodeJSONValue(decoder)
+				if err != nil {
+					return nil, err
+				}
+				hash.set(key, item)
+			}
+			_, err = decoder.Token()
+			return hash, err
+		}
+		return nil, fmt.Errorf("unexpected delimiter %s", value)
+	case json.Number:
+		if n, err := strconv.ParseInt(value.String(), 10, 0); err == nil {
+			return int(n), nil
+		}
+		return value.Float64()
+	}
+	return token, nil
+}
@@ -0,0 +1,669 @@
+package erbrenderer
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tEOF     tokenKind = iota
+	tText              // literal template text
+	tOutput            // start of an <%= %> tag
+	tTagEnd            // end of an <% %> or <%= %> tag
+	tNewline           // newline or ';'
+	tIdent             // local variable, method name or keyword
+	tConst             // capitalized name
+	tLabel             // 'key:' in hash literals and keyword arguments
+	tInt
+	tFloat
+	tString
+	tSymbol
+	tRegexp
+	tWords // %w() literal
+	tOp
+)
+
+type token struct {
+	kind        tokenKind
+	value       string
+	parts       []stringPart // parts of a tString
+	words       []string     // words of a tWords
+	line        int
+	spaceBefore bool
+}
+
+// stringPart is either literal text or the tokens of an interpolated #{} expression
+type stringPart struct {
+	literal string
+	code    []token
+	isCode  bool
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tEOF:
+		return "end of template"
+	case tText:
+		return "template text"
+	case tOutput:
+		return "'<%='"
+	case tTagEnd:
+		return "'%>'"
+	case tNewline:
+		return "end of line"
+	case tString:
+		return "string literal"
+	default:
+		return fmt.Sprintf("'%s'", t.value)
+	}
+}
+
+// scanTemplate splits an ERB template into text, tag and ruby code tokens.
+// Like ERB's '-' trim mode, '<%-' removes the indentation before a tag and '-%>' the newline after it.
+func scanTemplate(template string) ([]token, error) {
+	tokens := []token{}
+	line := 1
+	text := ""
+	textLine := 1
+
+	flushText := func() {
+		if text != "" {
+			tokens = append(tokens, token{kind: tText, value: text, line: textLine})
+		}
+		text = ""
+		textLine = line
+	}
+
+	rest := template
+	for rest != "" {
+		start := strings.Index(rest, "<%")
+		if start < 0 {
+			text += rest
+			break
+		}
+
+		if text == "" {
+			textLine = line
+		}
+		text += rest[:start]
+		line += strings.Count(rest[:start], "\n")
+		rest = rest[start+2:]
+
+		if strings.HasPrefix(rest, "%") {
+			text += "<%"
+			rest = rest[1:]
+			continue
+		}
+
+		output := false
+		comment := false
+		switch {
+		case strings.HasPrefix(rest, "="):
+			output = true
+			rest = rest[1:]
+		case strings.HasPrefix(rest, "#"):
+			comment = true
+			rest = rest[1:]
+		case strings.HasPrefix(rest, "-"):
+			rest = rest[1:]
+			lineStart := strings.LastIndex(text, "\n") + 1
+			if strings.TrimLeft(text[lineStart:], " \t") == "" {
+				text = text[:lineStart]
+			}
+		}
+
+		end := strings.Index(rest, "%>")
+		if end < 0 {
+			return nil, rubySyntaxError(line, "unterminated tag, missing '%%>'")
+		}
+		code := rest[:end]
+		rest = rest[end+2:]
+
+		trimNewline := strings.HasSuffix(code, "-")
+		if trimNewline {
+			code = code[:len(code)-1]
+		}
+
+		flushText()
+		tagLine := line
+		if output {
+			tokens = append(tokens, token{kind: tOutput, line: tagLine})
+		}
+		if !comment {
+			codeTokens, err := lexRuby(code, tagLine)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, codeTokens...)
+		}
+		line += strings.Count(code, "\n")
+		tokens = append(tokens, token{kind: tTagEnd, line: line})
+
+		if trimNewline {
+			if strings.HasPrefix(rest, "\n") {
+				rest = rest[1:]
+				line++
+			} else if strings.HasPrefix(rest, "\r\n") {
+				rest = rest[2:]
+				line++
+			}
+		}
+		textLine = line
+	}
+
+	flushText()
+	tokens = append(tokens, token{kind: tEOF, line: line})
+	return tokens, nil
+}
+
+type rubyLexer struct {
+	src    string
+	pos    int
+	line   int
+	space  bool
+	tokens []token
+}
+
+var rubyOperators = []string{
+	"**=", "<=>", "===", "...", "<<=", ">>=", "||=", "&&=",
+	"**", "==", "!=", "=~", "!~", ">=", "<=", "&&", "||", "<<", ">>", "+=", "-=", "*=", "/=", "%=", "|=", "&=", "^=",
+	"::", "..", "=>", "->", "&.",
+	"+", "-", "*", "/", "%", "=", "<", ">", "!", "&", "|", "^", "~", "(", ")", "[", "]", "{", "}", ",", ".", "?", ":",
+}
+
+// rubyKeywords can't be local variables or self method calls
+var rubyKeywords = map[string]bool{
+	"and": true, "begin": true, "break": true, "case": true, "def": true, "defined?": true, "do": true,
+	"else": true, "elsif": true, "end": true, "ensure": true, "false": true, "for": true, "if": true,
+	"in": true, "module": true, "next": true, "nil": true, "not": true, "or": true, "redo": true,
+	"rescue": true, "retry": true, "return": true, "self": true, "then": true, "true": true,
+	"undef": true, "unless": true, "until": true, "when": true, "while": true, "yield": true, "class": true,
+}
+
+// lexRuby splits ruby code into tokens, numbering lines from line
+func lexRuby(src string, line int) ([]token, error) {
+	l := &rubyLexer{src: src, line: line}
+	err := l.run()
+	if err != nil {
+		return nil, err
+	}
+	return l.tokens, nil
+}
+
+func (l *rubyLexer) run() error {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+			l.space = true
+		case c == '\\' && strings.HasPrefix(l.src[l.pos+1:], "\n"):
+			l.pos += 2
+			l.line++
+			l.space = true
+		case c == '\n':
+			l.pos++
+			l.newline()
+			l.line++
+			l.space = true
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case c == ';':
+			l.pos++
+			l.emit(token{kind: tNewline, value: ";"})
+		case isDigit(c):
+			l.lexNumber()
+		case isIdentStart(c):
+			l.lexIdent()
+		case c == '@' || c == '$':
+			return rubyUnsupportedError(l.line, "instance and global variables are not supported")
+		case c == '"':
+			l.pos++
+			parts, err := l.lexDoubleQuoted('"')
+			if err != nil {
+				return err
+			}
+			l.emit(token{kind: tString, parts: parts})
+		case c == '\'':
+			l.pos++
+			s, err := l.lexSingleQuoted('\'')
+			if err != nil {
+				return err
+			}
+			l.emit(token{kind: tString, parts: []stringPart{{literal: s}}})
+		case c == ':' && l.pos+1 < len(l.src) && isIdentStart(l.src[l.pos+1]) && l.operandExpected():
+			l.pos++
+			start := l.pos
+			for l.pos < len(l.src) && isIdentChar(l.src[l.pos]) {
+				l.pos++
+			}
+			if l.pos < len(l.src) && (l.src[l.pos] == '?' || l.src[l.pos] == '!' || l.src[l.pos] == '=') && !strings.HasPrefix(l.src[l.pos+1:], "=") {
+				l.pos++
+			}
+			l.emit(token{kind: tSymbol, value: l.src[start:l.pos]})
+		case c == ':' && l.operandExpected() && operatorSymbol(l.src[l.pos+1:]) != "":
+			op := operatorSymbol(l.src[l.pos+1:])
+			l.pos += 1 + len(op)
+			l.emit(token{kind: tSymbol, value: op})
+		case c == ':' && strings.HasPrefix(l.src[l.pos+1:], "\""):
+			l.pos += 2
+			parts, err := l.lexDoubleQuoted('"')
+			if err != nil {
+				return err
+			}
+			l.emit(token{kind: tSymbol, value: literalParts(parts)})
+		case c == '%' && l.operandExpected() && l.pos+2 < len(l.src) && strings.IndexByte("wWqQ", l.src[l.pos+1]) >= 0 && !isIdentChar(l.src[l.pos+2]) && l.src[l.pos+2] != ' ':
+			err := l.lexPercentLiteral()
+			if err != nil {
+				return err
+			}
+		case c == '/' && l.regexpExpected():
+			err := l.lexRegexp()
+			if err != nil {
+				return err
+			}
+		default:
+			op := ""
+			for _, candidate := range rubyOperators {
+				if strings.HasPrefix(l.src[l.pos:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+				return rubySyntaxError(l.line, "unexpected character '%c'", r)
+			}
+			l.pos += len(op)
+			l.emit(token{kind: tOp, value: op})
+		}
+	}
+	return nil
+}
+
+func (l *rubyLexer) emit(t token) {
+	t.line = l.line
+	t.spaceBefore = l.space
+	l.tokens = append(l.tokens, t)
+	l.space = false
+}
+
+// newline ends a statement unless the expression obviously continues on the next line
+func (l *rubyLexer) newline() {
+	if len(l.tokens) == 0 {
+		return
+	}
+	last := l.tokens[len(l.tokens)-1]
+	if last.kind == tNewline {
+		return
+	}
+	if last.kind == tOp && last.value != ")" && last.value != "]" && last.value != "}" {
+		return
+	}
+	if last.kind == tIdent && (last.value == "and" || last.value == "or" || last.value == "not") {
+		return
+	}
+
+	next := strings.TrimLeft(l.src[l.pos:], " \t\r\n")
+	if (strings.HasPrefix(next, ".") && !strings.HasPrefix(next, "..")) || strings.HasPrefix(next, "&.") {
+		return
+	}
+	l.emit(token{kind: tNewline, value: "\n"})
+}
+
+// operandExpected is true when the next token starts an expression rather than continuing one
+func (l *rubyLexer) operandExpected() bool {
+	if len(l.tokens) == 0 {
+		return true
+	}
+	last := l.tokens[len(l.tokens)-1]
+	switch last.kind {
+	case tNewline, tLabel:
+		return true
+	case tOp:
+		return last.value != ")" && last.value != "]" && last.value != "}"
+	case tIdent:
+		if rubyKeywords[last.value] {
+			return last.value != "end" && last.value != "self" && last.value != "nil" && last.value != "true" && last.value != "false"
+		}
+		// e.g. 'split /,/' or 'p :name' but not 'a / b' or 'a ? b :c'
+		return l.space && l.pos+1 < len(l.src) && l.src[l.pos+1] != ' '
+	}
+	return false
+}
+
+func (l *rubyLexer) regexpExpected() bool {
+	return l.operandExpected()
+}
+
+func (l *rubyLexer) lexNumber() {
+	start := l.pos
+	if strings.HasPrefix(l.src[l.pos:], "0x") || strings.HasPrefix(l.src[l.pos:], "0X") {
+		l.pos += 2
+		for l.pos < len(l.src) && (isHexDigit(l.src[l.pos]) || l.src[l.pos] == '_') {
+			l.pos++
+		}
+		l.emit(token{kind: tInt, value: strings.Replace(l.src[start:l.pos], "_", "", -1)})
+		return
+	}
+
+	kind := tInt
+	for l.pos < len(l.src) && (isDigit(l.src[l.pos]) || l.src[l.pos] == '_') {
+		l.pos++
+	}
+	if l.pos+1 < len(l.src) && l.src[l.pos] == '.' && isDigit(l.src[l.pos+1]) {
+		kind = tFloat
+		l.pos++
+		for l.pos < len(l.src) && (isDigit(l.src[l.pos]) || l.src[l.pos] == '_') {
+			l.pos++
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		exp := l.pos + 1
+		if exp < len(l.src) && (l.src[exp] == '+' || l.src[exp] == '-') {
+			exp++
+		}
+		if exp < len(l.src) && isDigit(l.src[exp]) {
+			kind = tFloat
+			l.pos = exp
+			for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+				l.pos++
+			}
+		}
+	}
+	l.emit(token{kind: kind, value: strings.Replace(l.src[start:l.pos], "_", "", -1)})
+}
+
+func (l *rubyLexer) lexIdent() {
+	start := l.pos
+	for l.pos < len(l.src) && isIdentChar(l.src[l.pos]) {
+		l.pos++
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == '?' || l.src[l.pos] == '!') && !strings.HasPrefix(l.src[l.pos+1:], "=") {
+		l.pos++
+	}
+	name := l.src[start:l.pos]
+
+	afterDot := len(l.tokens) > 0 && l.tokens[len(l.tokens)-1].kind == tOp && (l.tokens[len(l.tokens)-1].value == "." || l.tokens[len(l.tokens)-1].value == "&.")
+	if !afterDot && l.pos < len(l.src) && l.src[l.pos] == ':' && !strings.HasPrefix(l.src[l.pos:], "::") && !strings.HasSuffix(name, "?") {
+		l.pos++
+		l.emit(token{kind: tLabel, value: name})
+		return
+	}
+
+	if name[0] >= 'A' && name[0] <= 'Z' {
+		l.emit(token{kind: tConst, value: name})
+		return
+	}
+	l.emit(token{kind: tIdent, value: name})
+}
+
+func (l *rubyLexer) lexSingleQuoted(closing byte) (string, error) {
+	var buf strings.Builder
+	for {
+		if l.pos >= len(l.src) {
+			return "", rubySyntaxError(l.line, "unterminated string meets end of file")
+		}
+		c := l.src[l.pos]
+		switch {
+		case c == '\\' && l.pos+1 < len(l.src) && (l.src[l.pos+1] == closing || l.src[l.pos+1] == '\\'):
+			buf.WriteByte(l.src[l.pos+1])
+			l.pos += 2
+		case c == closing:
+			l.pos++
+			return buf.String(), nil
+		default:
+			if c == '\n' {
+				l.line++
+			}
+			buf.WriteByte(c)
+			l.pos++
+		}
+	}
+}
+
+func (l *rubyLexer) lexDoubleQuoted(closing byte) ([]stringPart, error) {
+	parts := []stringPart{}
+	var buf strings.Builder
+	for {
+		if l.pos >= len(l.src) {
+			return nil, rubySyntaxError(l.line, "unterminated string meets end of file")
+		}
+		c := l.src[l.pos]
+		switch {
+		case c == '\\' && l.pos+1 < len(l.src):
+			l.pos++
+			l.lexEscape(&buf)
+		case c == '#' && strings.HasPrefix(l.src[l.pos+1:], "{"):
+			end, err := l.matchingBrace(l.pos + 2)
+			if err != nil {
+				return nil, err
+			}
+			if buf.Len() > 0 {
+				parts = append(parts, stringPart{literal: buf.String()})
+				buf.Reset()
+			}
+			code, err := lexRuby(l.src[l.pos+2:end], l.line)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, stringPart{code: code, isCode: true})
+			l.line += strings.Count(l.src[l.pos:end], "\n")
+			l.pos = end + 1
+		case c == closing:
+			l.pos++
+			if buf.Len() > 0 || len(parts) == 0 {
+				parts = append(parts, stringPart{literal: buf.String()})
+			}
+			return parts, nil
+		default:
+			if c == '\n' {
+				l.line++
+			}
+			buf.WriteByte(c)
+			l.pos++
+		}
+	}
+}
+
+func (l *rubyLexer) lexEscape(buf *strings.Builder) {
+	c := l.src[l.pos]
+	l.pos++
+	switch c {
+	case 'n':
+		buf.WriteByte('\n')
+	case 't':
+		buf.WriteByte('\t')
+	case 'r':
+		buf.WriteByte('\r')
+	case 's':
+		buf.WriteByte(' ')
+	case '0':
+		buf.WriteByte(0)
+	case 'e':
+		buf.WriteByte(27)
+	case 'a':
+		buf.WriteByte(7)
+	case 'b':
+		buf.WriteByte(8)
+	case 'f':
+		buf.WriteByte(12)
+	case 'v':
+		buf.WriteByte(11)
+	case 'u':
+		hex := ""
+		if strings.HasPrefix(l.src[l.pos:], "{") {
+			end := strings.IndexByte(l.src[l.pos:], '}')
+			if end > 0 {
+				hex = l.src[l.pos+1 : l.pos+end]
+				l.pos += end + 1
+			}
+		} else if l.pos+4 <= len(l.src) {
+			hex = l.src[l.pos : l.pos+4]
+			l.pos += 4
+		}
+		var r rune
+		fmt.Sscanf(hex, "%x", &r)
+		buf.WriteRune(r)
+	case 'x':
+		end := l.pos
+		for end < len(l.src) && end < l.pos+2 && isHexDigit(l.src[end]) {
+			end++
+		}
+		var b int
+		fmt.Sscanf(l.src[l.pos:end], "%x", &b)
+		buf.WriteByte(byte(b))
+		l.pos = end
+	case '\n':
+		l.line++
+	default:
+		buf.WriteByte(c)
+	}
+}
+
+// matchingBrace returns the position of the '}' closing an interpolation starting at pos
+func (l *rubyLexer) matchingBrace(pos int) (int, error) {
+	depth := 1
+	var quote byte
+	for i := pos; i < len(l.src); i++ {
+		c := l.src[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, rubySyntaxError(l.line, "unterminated string interpolation")
+}
+
+func (l *rubyLexer) lexPercentLiteral() error {
+	kind := l.src[l.pos+1]
+	opening := l.src[l.pos+2]
+	closing := opening
+	switch opening {
+	case '(':
+		closing = ')'
+	case '[':
+		closing = ']'
+	case '{':
+		closing = '}'
+	case '<':
+		closing = '>'
+	}
+	l.pos += 3
+
+	end := strings.IndexByte(l.src[l.pos:], closing)
+	if end < 0 {
+		return rubySyntaxError(l.line, "unterminated %%%c literal", kind)
+	}
+	content := l.src[l.pos : l.pos+end]
+
+	if kind == 'w' || kind == 'W' {
+		l.pos += end + 1
+		l.emit(token{kind: tWords, words: strings.Fields(content)})
+		l.line += strings.Count(content, "\n")
+		return nil
+	}
+
+	if kind == 'q' {
+		s, err := l.lexSingleQuoted(closing)
+		if err != nil {
+			return err
+		}
+		l.emit(token{kind: tString, parts: []stringPart{{literal: s}}})
+		return nil
+	}
+
+	parts, err := l.lexDoubleQuoted(closing)
+	if err != nil {
+		return err
+	}
+	l.emit(token{kind: tString, parts: parts})
+	return nil
+}
+
+func (l *rubyLexer) lexRegexp() error {
+	l.pos++
+	var buf strings.Builder
+	for {
+		if l.pos >= len(l.src) {
+			return rubySyntaxError(l.line, "unterminated regexp meets end of file")
+		}
+		c := l.src[l.pos]
+		if c == '\\' && l.pos+1 < len(l.src) {
+			if l.src[l.pos+1] == '/' {
+				buf.WriteByte('/')
+			} else {
+				buf.WriteString(l.src[l.pos : l.pos+2])
+			}
+			l.pos += 2
+			continue
+		}
+		l.pos++
+		if c == '/' {
+			break
+		}
+		buf.WriteByte(c)
+	}
+
+	flags := ""
+	for l.pos < len(l.src) && strings.IndexByte("imx", l.src[l.pos]) >= 0 {
+		flags += string(l.src[l.pos])
+		l.pos++
+	}
+	l.emit(token{kind: tRegexp, value: buf.String(), words: []string{flags}})
+	return nil
+}
+
+func literalParts(parts []stringPart) string {
+	s := ""
+	for _, part := range parts {
+		s += part.literal
+	}
+	return s
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
+
+// symbolOperators are the operator method names that can be written as symbols, e.g. inject(:+)
+var symbolOperators = []string{"[]=", "[]", "<=>", "===", "==", "=~", "**", "<<", ">>", "<=", ">=", "+", "-", "*", "/", "%", "<", ">", "!", "&", "|", "^"}
+
+func operatorSymbol(src string) string {
+	for _, op := range symbolOperators {
+		if strings.HasPrefix(src, op) {
+			return op
+		}
+	}
+	return ""
+}
@@ -0,0 +1,1344 @@
+package erbrenderer
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// rubyClasses are the constants templates can refer to
+var rubyClasses = map[string]bool{
+	"Object": true, "BasicObject": true, "Kernel": true, "Comparable": true, "Enumerable": true,
+	"NilClass": true, "TrueClass": true, "FalseClass": true, "Numeric": true, "Integer": true, "Fixnum": true,
+	"Float": true, "String": true, "Symbol": true, "Array": true, "Hash": true, "Range": true,
+	"OpenStruct": true, "Regexp": true, "MatchData": true, "JSON": true,
+	"Exception": true, "StandardError": true, "RuntimeError": true, "ArgumentError": true, "TypeError": true,
+	"NameError": true, "NoMethodError": true, "IndexError": true, "KeyError": true, "ZeroDivisionError": true,
+}
+
+// callMethod calls a method on a value, e.g. "a,b".split(",")
+func (i *rubyInterpreter) callMethod(recv interface{}, name string, args []interface{}, block *rubyBlock) interface{} {
+	var result interface{}
+	found := false
+
+	switch value := recv.(type) {
+	case nil:
+		result, found = i.nilMethod(name, args)
+	case bool:
+		result, found = i.boolMethod(value, name, args)
+	case int:
+		result, found = i.intMethod(value, name, args, block)
+	case float64:
+		result, found = i.floatMethod(value, name, args)
+	case string:
+		result, found = i.stringMethod(value, name, args, block)
+	case rubySymbol:
+		result, found = i.symbolMethod(value, name, args)
+	case *rubyArray:
+		result, found = i.arrayMethod(value, name, args, block)
+	case *rubyHash:
+		result, found = i.hashMethod(value, name, args, block)
+	case *rubyRange:
+		result, found = i.rangeMethod(value, name, args, block)
+	case *openStruct:
+		result, found = i.openStructMethod(value, name, args, block)
+	case *regexp.Regexp:
+		result, found = i.regexpMethod(value, name, args)
+	case *matchData:
+		result, found = i.matchDataMethod(value, name, args)
+	case rubyClass:
+		result, found = i.classMethod(value, name, args)
+	case *elseBlock:
+		result, found = i.elseBlockMethod(value, name, args, block)
+	case *templateLink:
+		result, found = i.linkMethod(value, name, args, block)
+	case *rubyError:
+		result, found = i.exceptionMethod(value, name, args)
+	case *templateContext:
+		return i.callSelf(name, args, block)
+	}
+
+	if !found {
+		result, found = i.objectMethod(recv, name, args, block)
+	}
+	if !found {
+		i.unsupported("undefined method `%s' for %s", name, describe(recv))
+	}
+	return result
+}
+
+// objectMethod implements the methods common to all values
+func (i *rubyInterpreter) objectMethod(recv interface{}, name string, args []interface{}, block *rubyBlock) (interface{}, bool) {
+	switch name {
+	case "nil?":
+		return recv == nil, true
+	case "to_s":
+		return toS(recv), true
+	case "inspect":
+		return inspect(recv), true
+	case "==", "eql?", "equal?":
+		i.checkArgs(name, args, 1, 1)
+		return rubyEqual(recv, args[0]), true
+	case "===":
+		i.checkArgs(name, args, 1, 1)
+		return rubyEqual(recv, args[0]), true
+	case "!":
+		return !truthy(recv), true
+	case "<=>":
+		i.checkArgs(name, args, 1, 1)
+		c, ok := rubyCompare(recv, args[0])
+		if !ok {
+			return nil, true
+		}
+		return c, true
+	case "is_a?", "kind_of?", "instance_of?":
+		i.checkArgs(name, args, 1, 1)
+		class, ok := args[0].(rubyClass)
+		if !ok {
+			i.raise("TypeError", "class or module required")
+		}
+		if name == "instance_of?" {
+			return className(recv) == string(class), true
+		}
+		return isA(recv, class), true
+	case "class":
+		return rubyClass(className(recv)), true
+	case "to_json":
+		return i.toJSON(recv, false), true
+	case "freeze", "itself", "dup", "clone":
+		return i.dup(recv), true
+	case "frozen?":
+		// freeze returns a copy, so only values that are always frozen in ruby are known to be
+		switch recv.(type) {
+		case nil, bool, int, float64, rubySymbol:
+			return true, true
+		}
+		i.unsupported("`frozen?' is only supported for nil, booleans, numbers and symbols")
+	case "tap":
+		i.yield(block, recv)
+		return recv, true
+	case "then", "yield_self":
+		return i.yield(block, recv), true
+	case "send", "public_send", "__send__":
+		i.checkArgs(name, args, 1, -1)
+		return i.callMethod(recv, toS(args[0]), args[1:], block), true
+	case "respond_to?":
+		i.checkArgs(name, args, 1, 2)
+		if s, ok := recv.(*openStruct); ok {
+			if _, found := s.fields.get(toS(args[0])); found {
+				return true, true
+			}
+		}
+		// the methods of each class are not listed, so whether ruby implements one cannot be told
+		i.unsupported("`respond_to?' is only supported for the fields of an OpenStruct")
+	case "instance_variable_get", "instance_eval", "instance_exec", "eval", "method", "define_method":
+		i.unsupported("`%s' is not supported in templates", name)
+	}
+	return nil, false
+}
+
+func (i *rubyInterpreter) dup(v interface{}) interface{} {
+	switch value := v.(type) {
+	case *rubyArray:
+		return newArray(append([]interface{}{}, value.items...)...)
+	case *rubyHash:
+		return value.dup()
+	}
+	return v
+}
+
+func (i *rubyInterpreter) nilMethod(name string, args []interface{}) (interface{}, bool) {
+	switch name {
+	case "to_a":
+		return newArray(), true
+	case "to_h":
+		return newHash(), true
+	case "to_i":
+		return 0, true
+	case "to_f":
+		return 0.0, true
+	case "&":
+		return false, true
+	case "|":
+		i.checkArgs(name, args, 1, 1)
+		return truthy(args[0]), true
+	}
+	return nil, false
+}
+
+func (i *rubyInterpreter) boolMethod(b bool, name string, args []interface{}) (interface{}, bool) {
+	switch name {
+	case "&", "&&":
+		i.checkArgs(name, args, 1, 1)
+		return b && truthy(args[0]), true
+	case "|", "||":
+		i.checkArgs(name, args, 1, 1)
+		return b || truthy(args[0]), true
+	case "^":
+		i.checkArgs(name, args, 1, 1)
+		return b != truthy(args[0]), true
+	}
+	return nil, false
+}
+
+func (i *rubyInterpreter) numberArg(name string, args []interface{}) interface{} {
+	i.checkArgs(name, args, 1, 1)
+	switch args[0].(type) {
+	case int, float64:
+		return args[0]
+	}
+	if args[0] == nil {
+		i.raise("TypeError", "nil can't be coerced into Numeric")
+	}
+	i.raise("TypeError", "%s can't be coerced into Numeric", className(args[0]))
+	return nil
+}
+
+func (i *rubyInterpreter) intMethod(n int, name string, args []interface{}, block *rubyBlock) (interface{}, bool) {
+	switch name {
+	case "+", "-", "*", "/", "%", "**", "modulo", "div", "fdiv":
+		other := i.numberArg(name, args)
+		if f, isFloat := other.(float64); isFloat || name == "fdiv" {
+			if !isFloat {
+				f = float64(other.(int))
+			}
+			return i.floatMethod(float64(n), name, []interface{}{f})
+		}
+		m := other.(int)
+		switch name {
+		case "+":
+			return n + m, true
+		case "-":
+			return n - m, true
+		case "*":
+			return n * m, true
+		case "/", "div":
+			if m == 0 {
+				i.raise("ZeroDivisionError", "divided by 0")
+			}
+			return floorDiv(n, m), true
+		case "%", "modulo":
+			if m == 0 {
+				i.raise("ZeroDivisionError", "divided by 0")
+			}
+			return n - floorDiv(n, m)*m, true
+		case "**":
+			if m < 0 {
+				return math.Pow(float64(n), float64(m)), true
+			}
+			result := 1
+			for k := 0; k < m; k++ {
+				result *= n
+			}
+			return result, true
+		}
+	case "<", ">", "<=", ">=", "between?":
+		return i.compareMethod(n, name, args), true
+	case "-@":
+		return -n, true
+	case "&", "|", "^", "<<", ">>":
+		i.checkArgs(name, args, 1, 1)
+		m, ok := args[0].(int)
+		if !ok {
+			i.raise("TypeError", "no implicit conversion of %s into Integer", className(args[0]))
+		}
+		switch name {
+		case "&":
+			return n & m, true
+		case "|":
+			return n | m, true
+		case "^":
+			return n ^ m, true
+		case "<<":
+			return n << uint(m), true
+		case ">>":
+			return n >> uint(m), true
+		}
+	case "to_i", "to_int", "floor", "ceil", "round", "truncate", "ord":
+		return n, true
+	case "to_f":
+		return float64(n), true
+	case "to_s":
+		if len(args) == 1 {
+			base, _ := args[0].(int)
+			return strconv.FormatInt(int64(n), base), true
+		}
+		return strconv.Itoa(n), true
+	case "chr":
+		return string(rune(n)), true
+	case "abs":
+		if n < 0 {
+			return -n, true
+		}
+		return n, true
+	case "succ", "next":
+		return n + 1, true
+	case "pred":
+		return n - 1, true
+	case "zero?":
+		return n == 0, true
+	case "positive?":
+		return n > 0, true
+	case "negative?":
+		return n < 0, true
+	case "even?":
+		return n%2 == 0, true
+	case "odd?":
+		return n%2 != 0, true
+	case "integer?":
+		return true, true
+	case "times":
+		for k := 0; k < n; k++ {
+			i.yield(block, k)
+		}
+		return n, true
+	case "upto", "downto":
+		i.checkArgs(name, args, 1, 1)
+		limit, _ := args[0].(int)
+		if name == "upto" {
+			for k := n; k <= limit; k++ {
+				i.yield(block, k)
+			}
+		} else {
+			for k := n; k >= limit; k-- {
+				i.yield(block, k)
+			}
+		}
+		return n, true
+	}
+	return nil, false
+}
+
+func floorDiv(n, m int) int {
+	q := n / m
+	if (n%m != 0) && ((n < 0) != (m < 0)) {
+		q--
+	}
+	return q
+}
+
+func (i *rubyInterpreter) floatMethod(f float64, name string, args []interface{}) (interface{}, bool) {
+	switch name {
+	case "+", "-", "*", "/", "%", "**", "modulo", "fdiv", "div":
+		other, _ := toFloat(i.numberArg(name, args))
+		switch name {
+		case "+":
+			return f + other, true
+		case "-":
+			return f - other, true
+		case "*":
+			return f * other, true
+		case "/", "fdiv":
+			return f / other, true
+		case "div":
+			if other == 0 {
+				i.raise("ZeroDivisionError", "divided by 0")
+			}
+			return int(math.Floor(f / other)), true
+		case "%", "modulo":
+			return f - math.Floor(f/other)*other, true
+		case "**":
+			return math.Pow(f, other), true
+		}
+	case "<", ">", "<=", ">=", "between?":
+		return i.compareMethod(f, name, args), true
+	case "-@":
+		return -f, true
+	case "to_f":
+		return f, true
+	case "to_i", "to_int", "truncate":
+		return int(f), true
+	case "floor":
+		return int(math.Floor(f)), true
+	case "ceil":
+		return int(math.Ceil(f)), true
+	case "round":
+		if len(args) == 1 {
+			digits, _ := args[0].(int)
+			if digits > 0 {
+				scale := math.Pow(10, float64(digits))
+				return math.Round(f*scale) / scale, true
+			}
+		}
+		return int(math.Round(f)), true
+	case "abs":
+		return math.Abs(f), true
+	case "nan?":
+		return math.IsNaN(f), true
+	case "infinite?":
+		if math.IsInf(f, 0) {
+			if f > 0 {
+				return 1, true
+			}
+			return -1, true
+		}
+		return nil, true
+	case "finite?":
+		return !math.IsInf(f, 0) && !math.IsNaN(f), true
+	case "zero?":
+		return f == 0, true
+	case "positive?":
+		return f > 0, true
+	case "negative?":
+		return f < 0, true
+	case "integer?":
+		return false, true
+	}
+	return nil, false
+}
+
+// compareMethod implements <, >, <=, >= and between? for comparable values
+func (i *rubyInterpreter) compareMethod(recv interface{}, name string, args []interface{}) bool {
+	if name == "between?" {
+		i.checkArgs(name, args, 2, 2)
+		return i.compare(recv, args[0]) >= 0 && i.compare(recv, args[1]) <= 0
+	}
+
+	i.checkArgs(name, args, 1, 1)
+	c := i.compare(recv, args[0])
+	switch name {
+	case "<":
+		return c < 0
+	case ">":
+		return c > 0
+	case "<=":
+		return c <= 0
+	}
+	return c >= 0
+}
+
+func (i *rubyInterpreter) stringArg(name string, args []interface{}, idx int) string {
+	if idx >= len(args) {
+		i.checkArgs(name, args, idx+1, -1)
+	}
+	switch value := args[idx].(type) {
+	case string:
+		return value
+	case rubySymbol:
+		if name == "start_with?" || name == "end_with?" || name == "include?" {
+			break
+		}
+		return string(value)
+	}
+	i.raise("TypeError", "no implicit conversion of %s into String", className(args[idx]))
+	return ""
+}
+
+func (i *rubyInterpreter) intArg(name string, args []interface{}, idx int) int {
+	if idx >= len(args) {
+		i.checkArgs(name, args, idx+1, -1)
+	}
+	switch value := args[idx].(type) {
+	case int:
+		return value
+	case float64:
+		return int(value)
+	}
+	i.raise("TypeError", "no implicit conversion of %s into Integer", className(args[idx]))
+	return 0
+}
+
+// pattern converts a string or regexp argument to a regexp
+func (i *rubyInterpreter) pattern(name string, arg interface{}) *regexp.Regexp {
+	switch value := arg.(type) {
+	case *regexp.Regexp:
+		return value
+	case string:
+		return regexp.MustCompile(regexp.QuoteMeta(value))
+	}
+	i.raise("TypeError", "wrong argument type %s (expected Regexp)", className(arg))
+	return nil
+}
+
+func (i *rubyInterpreter) stringMethod(s string, name string, args []interface{}, block *rubyBlock) (interface{}, bool) {
+	switch name {
+	case "+", "concat", "<<":
+		return s + i.stringArg(name, args, 0), true
+	case "*":
+		count := i.intArg(name, args, 0)
+		if count < 0 {
+			i.raise("ArgumentError", "negative argument")
+		}
+		return strings.Repeat(s, count), true
+	case "%":
+		i.checkArgs(name, args, 1, 1)
+		if array, ok := args[0].(*rubyArray); ok {
+			return i.format(s, array.items), true
+		}
+		return i.format(s, args), true
+	case "<", ">", "<=", ">=", "between?":
+		return i.compareMethod(s, name, args), true
+	case "=~":
+		i.checkArgs(name, args, 1, 1)
+		re, ok := args[0].(*regexp.Regexp)
+		if !ok {
+			i.raise("TypeError", "wrong argument type %s (expected Regexp)", className(args[0]))
+		}
+		return i.regexpMethod(re, name, []interface{}{s})
+	case "match":
+		i.checkArgs(name, args, 1, 1)
+		return i.regexpMethod(i.pattern(name, args[0]), name, []interface{}{s})
+	case "match?":
+		i.checkArgs(name, args, 1, 1)
+		return i.pattern(name, args[0]).MatchString(s), true
+	case "[]", "slice":
+		return i.stringSlice(s, name, args), true
+	case "length", "size":
+		return utf8.RuneCountInString(s), true
+	case "bytesize":
+		return len(s), true
+	case "empty?":
+		return s == "", true
+	case "upcase":
+		return strings.ToUpper(s), true
+	case "downcase":
+		return strings.ToLower(s), true
+	case "capitalize":
+		if s == "" {
+			return s, true
+		}
+		r, size := utf8.DecodeRuneInString(s)
+		return string(unicode.ToUpper(r)) + strings.ToLower(s[size:]), true
+	case "swapcase":
+		return strings.Map(func(r rune) rune {
+			if unicode.IsUpper(r) {
+				return unicode.ToLower(r)
+			}
+			return unicode.ToUpper(r)
+		}, s), true
+	case "strip":
+		return strings.Trim(s, " \t\n\r\f\v\x00"), true
+	case "lstrip":
+		return strings.TrimLeft(s, " \t\n\r\f\v\x00"), true
+	case "rstrip":
+		return strings.TrimRight(s, " \t\n\r\f\v\x00"), true
+	case "chomp":
+		if len(args) == 1 {
+			return strings.TrimSuffix(s, i.stringArg(name, args, 0)), true
+		}
+		if strings.HasSuffix(s, "\r\n") {
+			return s[:len(s)-2], true
+		}
+		return strings.TrimSuffix(strings.TrimSuffix(s, "\n"), "\r"), true
+	case "chop":
+		if s == "" {
+			return s, true
+		}
+		_, size := utf8.DecodeLastRuneInString(s)
+		return s[:len(s)-size], true
+	case "chr":
+		if s == "" {
+			return s, true
+		}
+		r, _ := utf8.DecodeRuneInString(s)
+		return string(r), true
+	case "split":
+		return i.split(s, args), true
+	case "lines":
+		return newArray(stringsToValues(splitLines(s))...), true
+	case "each_line":
+		for _, line := range splitLines(s) {
+			i.yield(block, line)
+		}
+		return s, true
+	case "chars":
+		return newArray(stringsToValues(strings.Split(s, ""))...), true
+	case "each_char":
+		for _, c := range strings.Split(s, "") {
+			i.yield(block, c)
+		}
+		return s, true
+	case "bytes":
+		bytes := []interface{}{}
+		for _, b := range []byte(s) {
+			bytes = append(bytes, int(b))
+		}
+		return newArray(bytes...), true
+	case "include?":
+		return strings.Contains(s, i.stringArg(name, args, 0)), true
+	case "start_with?", "end_with?":
+		i.checkArgs(name, args, 1, -1)
+		for idx := range args {
+			if re, ok := args[idx].(*regexp.Regexp); ok && name == "start_with?" {
+				if loc := re.FindStringIndex(s); loc != nil && loc[0] == 0 {
+					return true, true
+				}
+				continue
+			}
+			prefix := i.stringArg(name, args, idx)
+			if (name == "start_with?" && strings.HasPrefix(s, prefix)) || (name == "end_with?" && strings.HasSuffix(s, prefix)) {
+				return true, true
+			}
+		}
+		return false, true
+	case "index", "rindex":
+		i.checkArgs(name, args, 1, 2)
+		var pos int
+		if re, ok := args[0].(*regexp.Regexp); ok {
+			loc := re.FindStringIndex(s)
+			if loc == nil {
+				return nil, true
+			}
+			pos = loc[0]
+		} else if name == "index" {
+			pos = strings.Index(s, i.stringArg(name, args, 0))
+		} else {
+			pos = strings.LastIndex(s, i.stringArg(name, args, 0))
+		}
+		if pos < 0 {
+			return nil, true
+		}
+		return utf8.RuneCountInString(s[:pos]), true
+	case "sub", "gsub":
+		return i.substitute(s, name, args, block), true
+	case "tr", "tr_s":
+		i.checkArgs(name, args, 2, 2)
+		return translate(s, i.stringArg(name, args, 0), i.stringArg(name, args, 1)), true
+	case "delete":
+		i.checkArgs(name, args, 1, 1)
+		set := expandCharSet(i.stringArg(name, args, 0))
+		return strings.Map(func(r rune) rune {
+			if set.contains(r) {
+				return -1
+			}
+			return r
+		}, s), true
+	case "count":
+		i.checkArgs(name, args, 1, 1)
+		set := expandCharSet(i.stringArg(name, args, 0))
+		count := 0
+		for _, r := range s {
+			if set.contains(r) {
+				count++
+			}
+		}
+		return count, true
+	case "squeeze":
+		var buf strings.Builder
+		var last rune = -1
+		for _, r := range s {
+			if r != last {
+				buf.WriteRune(r)
+			}
+			last = r
+		}
+		return buf.String(), true
+	case "scan":
+		i.checkArgs(name, args, 1, 1)
+		re := i.pattern(name, args[0])
+		matches := []interface{}{}
+		for _, match := range re.FindAllStringSubmatch(s, -1) {
+			var value interface{} = match[0]
+			if len(match) > 1 {
+				value = newArray(stringsToValues(match[1:])...)
+			}
+			if block != nil {
+				i.yield(block, value)
+			}
+			matches = append(matches, value)
+		}
+		if block != nil {
+			return s, true
+		}
+		return newArray(matches...), true
+	case "reverse":
+		runes := []rune(s)
+		for a, b := 0, len(runes)-1; a < b; a, b = a+1, b-1 {
+			runes[a], runes[b] = runes[b], runes[a]
+		}
+		return string(runes), true
+	case "ljust", "rjust", "center":
+		i.checkArgs(name, args, 1, 2)
+		width := i.intArg(name, args, 0)
+		pad := " "
+		if len(args) == 2 {
+			pad = i.stringArg(name, args, 1)
+		}
+		return justify(s, name, width, pad), true
+	case "to_s", "to_str", "+@", "-@", "force_encoding", "encode", "unicode_normalize", "scrub", "b":
+		return s, true
+	case "to_sym", "intern":
+		return rubySymbol(s), true
+	case "to_i":
+		base := 10
+		if len(args) == 1 {
+			base = i.intArg(name, args, 0)
+		}
+		return parseLeadingInt(s, base), true
+	case "hex":
+		return parseLeadingInt(s, 16), true
+	case "oct":
+		return parseLeadingInt(s, 8), true
+	case "to_f":
+		return parseLeadingFloat(s), true
+	case "casecmp?":
+		return strings.EqualFold(s, i.stringArg(name, args, 0)), true
+	case "casecmp":
+		return strings.Compare(strings.ToLower(s), strings.ToLower(i.stringArg(name, args, 0))), true
+	case "succ", "next":
+		if s == "" {
+			return s, true
+		}
+		runes := []rune(s)
+		runes[len(runes)-1]++
+		return string(runes), true
+	case "ord":
+		if s == "" {
+			i.raise("ArgumentError", "empty string")
+		}
+		r, _ := utf8.DecodeRuneInString(s)
+		return int(r), true
+	case "shellescape":
+		return shellEscape(s), true
+	}
+	return nil, false
+}
+
+func stringsToValues(strs []string) []interface{} {
+	values := make([]interface{}, len(strs))
+	for i, s := range strs {
+		values[i] = s
+	}
+	return values
+}
+
+func splitLines(s string) []string {
+	lines := []string{}
+	for s != "" {
+		end := strings.IndexByte(s, '\n')
+		if end < 0 {
+			lines = append(lines, s)
+			break
+		}
+		lines = append(lines, s[:end+1])
+		s = s[end+1:]
+	}
+	return lines
+}
+
+func (i *rubyInterpreter) split(s string, args []interface{}) interface{} {
+	i.checkArgs("split", args, 0, 2)
+	limit := 0
+	if len(args) == 2 {
+		limit = i.intArg("split", args, 1)
+	}
+
+	var parts []string
+	switch {
+	case len(args) == 0 || args[0] == nil || args[0] == " ":
+		if limit > 0 {
+			parts = strings.SplitN(strings.TrimLeft(s, " \t\n\r\f\v"), " ", limit)
+		} else {
+			parts = strings.Fields(s)
+		}
+	default:
+		n := -1
+		if limit > 0 {
+			n = limit
+		}
+		if re, ok := args[0].(*regexp.Regexp); ok {
+			parts = re.Split(s, n)
+		} else {
+			separator := i.stringArg("split", args, 0)
+			if separator == "" {
+				parts = strings.Split(s, "")
+			} else {
+				parts = strings.SplitN(s, separator, n)
+			}
+		}
+	}
+
+	if limit == 0 {
+		for len(parts) > 0 && parts[len(parts)-1] == "" {
+			parts = parts[:len(parts)-1]
+		}
+	}
+	return newArray(stringsToValues(parts)...)
+}
+
+func (i *rubyInterpreter) stringSlice(s string, name string, args []interface{}) interface{} {
+	i.checkArgs(name, args, 1, 2)
+	runes := []rune(s)
+
+	switch arg := args[0].(type) {
+	case string:
+		if strings.Contains(s, arg) {
+			return arg
+		}
+		return nil
+	case *regexp.Regexp:
+		match := arg.FindStringSubmatch(s)
+		if match == nil {
+			return nil
+		}
+		group := 0
+		if len(args) == 2 {
+			group = i.intArg(name, args, 1)
+		}
+		if group >= len(match) {
+			return nil
+		}
+		return match[group]
+	case *rubyRange:
+		start, length, ok := rangeBounds(arg, len(runes))
+		if !ok {
+			return nil
+		}
+		return string(runes[start : start+length])
+	}
+
+	start := i.intArg(name, args, 0)
+	if start < 0 {
+		start += len(runes)
+	}
+	if len(args) == 2 {
+		length := i.intArg(name, args, 1)
+		if start < 0 || start > len(runes) || length < 0 {
+			return nil
+		}
+		if start+length > len(runes) {
+			length = len(runes) - start
+		}
+		return string(runes[start : start+length])
+	}
+	if start < 0 || start >= len(runes) {
+		return nil
+	}
+	return string(runes[start])
+}
+
+// rangeBounds resolves a range, possibly with negative ends, against a sequence of the given length
+func rangeBounds(r *rubyRange, length int) (int, int, bool) {
+	start, end := r.low, r.high
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	if !r.exclusive {
+		end++
+	}
+	if start < 0 || start > length {
+		return 0, 0, false
+	}
+	if end > length {
+		end = length
+	}
+	if end < start {
+		end = start
+	}
+	return start, end - start, true
+}
+
+var rubyBackreference = regexp.MustCompile(`\\(\d|k<(\w+)>|&|\\)`)
+
+func (i *rubyInterpreter) substitute(s string, name string, args []interface{}, block *rubyBlock) string {
+	if block != nil {
+		i.checkArgs(name, args, 1, 1)
+	} else {
+		i.checkArgs(name, args, 2, 2)
+	}
+	re := i.pattern(name, args[0])
+
+	replaced := false
+	return re.ReplaceAllStringFunc(s, func(match string) string {
+		if replaced && name == "sub" {
+			return match
+		}
+		replaced = true
+
+		if block != nil {
+			return toS(i.yield(block, match))
+		}
+
+		submatches := re.FindStringSubmatch(match)
+		if hash, ok := args[1].(*rubyHash); ok {
+			value, _ := hash.get(match)
+			return toS(value)
+		}
+		replacement := i.stringArg(name, args, 1)
+		return rubyBackreference.ReplaceAllStringFunc(replacement, func(ref string) string {
+			switch {
+			case ref == `\\`:
+				return `\`
+			case ref == `\&`:
+				return submatches[0]
+			case strings.HasPrefix(ref, `\k<`):
+				index := re.SubexpIndex(ref[3 : len(ref)-1])
+				if index >= 0 && index < len(submatches) {
+					return submatches[index]
+				}
+				return ""
+			}
+			index, _ := strconv.Atoi(ref[1:])
+			if index < len(submatches) {
+				return submatches[index]
+			}
+			return ""
+		})
+	})
+}
+
+type charSet struct {
+	runes   []rune
+	negated bool
+}
+
+func (c charSet) contains(r rune) bool {
+	for _, candidate := range c.runes {
+		if candidate == r {
+			return !c.negated
+		}
+	}
+	return c.negated
+}
+
+// expandCharSet expands a tr/delete/count character set like "a-z" or "^0-9"
+func expandCharSet(set string) charSet {
+	runes := []rune(set)
+	result := charSet{}
+	if len(runes) > 1 && runes[0] == '^' {
+		result.negated = true
+		runes = runes[1:]
+	}
+	for idx := 0; idx < len(runes); idx++ {
+		if idx+2 < len(runes) && runes[idx+1] == '-' {
+			for r := runes[idx]; r <= runes[idx+2]; r++ {
+				result.runes = append(result.runes, r)
+			}
+			idx += 2
+			continue
+		}
+		result.runes = append(result.runes, runes[idx])
+	}
+	return result
+}
+
+func translate(s string, from string, to string) string {
+	fromSet := expandCharSet(from)
+	toRunes := expandCharSet(to).runes
+	return strings.Map(func(r rune) rune {
+		if fromSet.negated {
+			if fromSet.contains(r) && len(toRunes) > 0 {
+				return toRunes[len(toRunes)-1]
+			}
+			return r
+		}
+		for idx, candidate := range fromSet.runes {
+			if candidate == r {
+				if len(toRunes) == 0 {
+					return -1
+				}
+				if idx >= len(toRunes) {
+					return toRunes[len(toRunes)-1]
+				}
+				return toRunes[idx]
+			}
+		}
+		return r
+	}, s)
+}
+
+func justify(s string, name string, width int, pad string) string {
+	padding := width - utf8.RuneCountInString(s)
+	if padding <= 0 || pad == "" {
+		return s
+	}
+	fill := func(n int) string {
+		return string([]rune(strings.Repeat(pad, n))[:n])
+	}
+	switch name {
+	case "ljust":
+		return s + fill(padding)
+	case "rjust":
+		return fill(padding) + s
+	}
+	left := padding / 2
+	return fill(left) + s + fill(padding-left)
+}
+
+func parseLeadingInt(s string, base int) int {
+	s = strings.TrimLeft(s, " \t\n\r\f\v")
+	end := 0
+	if end < len(s) && (s[end] == '-' || s[end] == '+') {
+		end++
+	}
+	if base == 16 && strings.HasPrefix(strings.ToLower(s[end:]), "0x") {
+		s = s[:end] + s[end+2:]
+	}
+	for end < len(s) {
+		c := s[end]
+		digit := -1
+		switch {
+		case c >= '0' && c <= '9':
+			digit = int(c - '0')
+		case c >= 'a' && c <= 'z':
+			digit = int(c-'a') + 10
+		case c >= 'A' && c <= 'Z':
+			digit = int(c-'A') + 10
+		case c == '_':
+			digit = 0
+		}
+		if digit < 0 || digit >= base {
+			break
+		}
+		end++
+	}
+	n, _ := strconv.ParseInt(strings.Replace(s[:end], "_", "", -1), base, 64)
+	return int(n)
+}
+
+var leadingFloat = regexp.MustCompile(`^[+-]?(\d[\d_]*)?(\.\d+)?([eE][+-]?\d+)?`)
+
+func parseLeadingFloat(s string) float64 {
+	match := leadingFloat.FindString(strings.TrimLeft(s, " \t\n\r\f\v"))
+	f, _ := strconv.ParseFloat(strings.Replace(match, "_", "", -1), 64)
+	return f
+}
+
+func shellEscape(s string) string {
+	if s == "" {
+		return "''"
+	}
+	var buf strings.Builder
+	for _, r := range s {
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-.,:+/@\n", r)) {
+			buf.WriteByte('\\')
+		}
+		if r == '\n' {
+			buf.WriteString("'\n'")
+			continue
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+func (i *rubyInterpreter) symbolMethod(sym rubySymbol, name string, args []interface{}) (interface{}, bool) {
+	switch name {
+	case "to_s", "id2name", "name":
+		return string(sym), true
+	case "to_sym":
+		return sym, true
+	case "to_proc":
+		return sym, true
+	case "length", "size":
+		return utf8.RuneCountInString(string(sym)), true
+	case "<", ">", "<=", ">=":
+		return i.compareMethod(sym, name, args), true
+	case "upcase", "downcase", "capitalize", "empty?", "start_with?", "end_with?", "[]":
+		result, _ := i.stringMethod(string(sym), name, args, nil)
+		if s, ok := result.(string); ok && name != "[]" {
+			return rubySymbol(s), true
+		}
+		return result, true
+	}
+	return nil, false
+}
+
+func (i *rubyInterpreter) regexpMethod(re *regexp.Regexp, name string, args []interface{}) (interface{}, bool) {
+	switch name {
+	case "match", "=~", "===", "match?":
+		i.checkArgs(name, args, 1, 1)
+		s, ok := args[0].(string)
+		if !ok {
+			if sym, isSym := args[0].(rubySymbol); isSym {
+				s = string(sym)
+			} else if name == "===" || args[0] == nil {
+				if name == "=~" || name == "match" {
+					return nil, true
+				}
+				return false, true
+			} else {
+				i.raise("TypeError", "no implicit conversion of %s into String", className(args[0]))
+			}
+		}
+		loc := re.FindStringSubmatchIndex(s)
+		switch name {
+		case "===", "match?":
+			return loc != nil, true
+		case "=~":
+			if loc == nil {
+				return nil, true
+			}
+			return utf8.RuneCountInString(s[:loc[0]]), true
+		}
+		if loc == nil {
+			return nil, true
+		}
+		groups := []interface{}{}
+		for g := 0; g < len(loc); g += 2 {
+			if loc[g] < 0 {
+				groups = append(groups, nil)
+			} else {
+				groups = append(groups, s[loc[g]:loc[g+1]])
+			}
+		}
+		return &matchData{groups: groups}, true
+	case "source":
+		return strings.TrimPrefix(strings.TrimPrefix(re.String(), "(?m)"), "(?im)"), true
+	}
+	return nil, false
+}
+
+func (i *rubyInterpreter) matchDataMethod(m *matchData, name string, args []interface{}) (interface{}, bool) {
+	switch name {
+	case "[]":
+		index := i.intArg(name, args, 0)
+		if index < 0 || index >= len(m.groups) {
+			return nil, true
+		}
+		return m.groups[index], true
+	case "captures":
+		return newArray(m.groups[1:]...), true
+	case "to_a":
+		return newArray(m.groups...), true
+	}
+	return nil, false
+}
+
+func (i *rubyInterpreter) classMethod(class rubyClass, name string, args []interface{}) (interface{}, bool) {
+	switch name {
+	case "===":
+		i.checkArgs(name, args, 1, 1)
+		return isA(args[0], class), true
+	case "name", "to_s":
+		return string(class), true
+	}
+
+	switch class {
+	case "JSON":
+		switch name {
+		case "dump":
+			i.checkArgs(name, args, 1, 1)
+			// like the ruby renderer, which makes JSON.dump inspect strings and numbers
+			switch args[0].(type) {
+			case string, int, float64:
+				return inspect(args[0]), true
+			}
+			return i.toJSON(args[0], false), true
+		case "generate":
+			i.checkArgs(name, args, 1, 2)
+			return i.toJSON(args[0], false), true
+		case "pretty_generate":
+			i.checkArgs(name, args, 1, 2)
+			return i.toJSON(args[0], true), true
+		case "parse", "load":
+			i.checkArgs(name, args, 1, 2)
+			value, err := decodeJSON([]byte(i.stringArg(name, args, 0)))
+			if err != nil {
+				i.raise("JSON::ParserError", "%s", err.Error())
+			}
+			return value, true
+		}
+	case "Hash":
+		if name == "new" {
+			return newHash(), true
+		}
+	case "Array":
+		if name == "new" {
+			i.checkArgs(name, args, 0, 2)
+			items := []interface{}{}
+			if len(args) > 0 {
+				var value interface{}
+				if len(args) == 2 {
+					value = args[1]
+				}
+				for k := 0; k < i.intArg(name, args, 0); k++ {
+					items = append(items, value)
+				}
+			}
+			return newArray(items...), true
+		}
+	case "OpenStruct":
+		if name == "new" {
+			i.checkArgs(name, args, 0, 1)
+			if len(args) == 1 {
+				if hash, ok := args[0].(*rubyHash); ok {
+					fields := newHash()
+					for idx, key := range hash.keys {
+						fields.set(toS(key), hash.values[idx])
+					}
+					return &openStruct{fields: fields}, true
+				}
+			}
+			return &openStruct{fields: newHash()}, true
+		}
+	}
+	return nil, false
+}
+
+func (i *rubyInterpreter) exceptionMethod(err *rubyError, name string, args []interface{}) (interface{}, bool) {
+	switch name {
+	case "message", "to_s", "full_message":
+		return err.message, true
+	case "backtrace":
+		return newArray(), true
+	}
+	return nil, false
+}
+
+// convert implements the Integer(), Float(), String() and Array() conversion functions
+func (i *rubyInterpreter) convert(class string, value interface{}) interface{} {
+	switch class {
+	case "Integer":
+		switch v := value.(type) {
+		case int:
+			return v
+		case float64:
+			return int(v)
+		case string:
+			n, err := strconv.ParseInt(strings.Replace(strings.TrimSpace(v), "_", "", -1), 0, 64)
+			if err != nil {
+				i.raise("ArgumentError", "invalid value for Integer(): %s", inspect(v))
+			}
+			return int(n)
+		}
+		i.raise("TypeError", "can't convert %s into Integer", className(value))
+	case "Float":
+		switch v := value.(type) {
+		case int:
+			return float64(v)
+		case float64:
+			return v
+		case string:
+			f, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(v), "_", "", -1), 64)
+			if err != nil {
+				i.raise("ArgumentError", "invalid value for Float(): %s", inspect(v))
+			}
+			return f
+		}
+		i.raise("TypeError", "can't convert %s into Float", className(value))
+	case "String":
+		return toS(value)
+	case "Array":
+		switch v := value.(type) {
+		case nil:
+			return newArray()
+		case *rubyArray:
+			return v
+		case *rubyHash:
+			return newArray(v.pairs()...)
+		}
+		return newArray(value)
+	case "Hash":
+		switch v := value.(type) {
+		case nil:
+			return newHash()
+		case *rubyHash:
+			return v
+		}
+		i.raise("TypeError", "can't convert %s into Hash", className(value))
+	}
+	return nil
+}
+
+func (i *rubyInterpreter) openStructMethod(s *openStruct, name string, args []interface{}, block *rubyBlock) (interface{}, bool) {
+	if value, found := s.fields.get(name); found && len(args) == 0 {
+		return value, true
+	}
+
+	switch name {
+	case "[]":
+		i.checkArgs(name, args, 1, 1)
+		value, _ := s.fields.get(toS(args[0]))
+		return value, true
+	case "[]=":
+		i.checkArgs(name, args, 2, 2)
+		s.fields.set(toS(args[0]), args[1])
+		return args[1], true
+	case "to_h":
+		return s.fields.dup(), true
+	case "each_pair":
+		for idx, key := range s.fields.keys {
+			i.yield(block, newArray(rubySymbol(toS(key)), s.fields.values[idx]))
+		}
+		return s, true
+	case "dig":
+		i.checkArgs(name, args, 1, -1)
+		value, _ := s.fields.get(toS(args[0]))
+		if len(args) == 1 || value == nil {
+			return value, true
+		}
+		return i.callMethod(value, "dig", args[1:], nil), true
+	case "to_json":
+		return i.toJSON(s.fields, false), true
+	}
+
+	if strings.HasSuffix(name, "=") && len(args) == 1 && name != "==" {
+		s.fields.set(strings.TrimSuffix(name, "="), args[0])
+		return args[0], true
+	}
+
+	if len(args) == 0 && block == nil {
+		if _, found := i.objectMethod(s, name, args, block); !found {
+			return nil, true
+		}
+	}
+	return nil, false
+}
+
+var formatDirective = regexp.MustCompile(`%([-+ 0#]*)(\d+|\*)?(?:\.(\d+))?([sdiufFeEgGxXobBcp%])`)
+
+// format implements the common directives of ruby's format, e.g. format("%05.2f", 1)
+func (i *rubyInterpreter) format(template string, args []interface{}) string {
+	next := 0
+	nextArg := func() interface{} {
+		if next >= len(args) {
+			i.raise("ArgumentError", "too few arguments")
+		}
+		next++
+		return args[next-1]
+	}
+
+	return formatDirective.ReplaceAllStringFunc(template, func(directive string) string {
+		match := formatDirective.FindStringSubmatch(directive)
+		flags, width, precision, verb := match[1], match[2], match[3], match[4]
+		if verb == "%" {
+			return "%"
+		}
+		if width == "*" {
+			width = strconv.Itoa(i.intArg("format", []interface{}{nextArg()}, 0))
+		}
+
+		spec := "%" + flags + width
+		if precision != "" {
+			spec += "." + precision
+		}
+
+		arg := nextArg()
+		switch verb {
+		case "s":
+			return fmt.Sprintf(spec+"s", toS(arg))
+		case "p":
+			return fmt.Sprintf(spec+"s", inspect(arg))
+		case "c":
+			if n, ok := arg.(int); ok {
+				return fmt.Sprintf(spec+"c", rune(n))
+			}
+			r, _ := utf8.DecodeRuneInString(toS(arg))
+			return fmt.Sprintf(spec+"c", r)
+		case "d", "i", "u", "x", "X", "o", "b", "B":
+			n, ok := i.convert("Integer", arg).(int)
+			if !ok {
+				i.raise("TypeError", "can't convert %s into Integer", className(arg))
+			}
+			goVerb := map[string]string{"d": "d", "i": "d", "u": "d", "x": "x", "X": "X", "o": "o", "b": "b", "B": "b"}[verb]
+			return fmt.Sprintf(spec+goVerb, n)
+		}
+
+		f, ok := i.convert("Float", arg).(float64)
+		if !ok {
+			i.raise("TypeError", "can't convert %s into Float", className(arg))
+		}
+		if verb == "F" {
+			verb = "f"
+		}
+		return fmt.Sprintf(spec+verb, f)
+	})
+}
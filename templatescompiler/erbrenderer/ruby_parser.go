@@ -0,0 +1,1100 @@
+package erbrenderer
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Nodes of the syntax tree of a template. Every node records its template line for error messages.
+type (
+	textNode struct {
+		text string
+	}
+
+	outputNode struct {
+		expr node
+		line int
+	}
+
+	seqNode struct {
+		stmts []node
+	}
+
+	literalNode struct {
+		value interface{}
+	}
+
+	strNode struct {
+		parts []node
+	}
+
+	arrayNode struct {
+		elems []node
+	}
+
+	hashNode struct {
+		keys   []node
+		values []node
+	}
+
+	rangeNode struct {
+		low       node
+		high      node
+		exclusive bool
+	}
+
+	varNode struct {
+		name string
+	}
+
+	constNode struct {
+		name string
+		line int
+	}
+
+	selfNode struct{}
+
+	callNode struct {
+		recv     node // nil for calls on the template context
+		name     string
+		args     []node
+		blockArg node
+		block    *blockNode
+		safeNav  bool
+		parens   bool
+		line     int
+	}
+
+	splatNode struct {
+		expr node
+	}
+
+	blockNode struct {
+		params []blockParam
+		body   node
+	}
+
+	assignNode struct {
+		name  string
+		value node
+	}
+
+	opAssignNode struct {
+		target node // varNode or callNode
+		op     string
+		value  node
+		line   int
+	}
+
+	andNode struct {
+		left  node
+		right node
+	}
+
+	orNode struct {
+		left  node
+		right node
+	}
+
+	notNode struct {
+		expr node
+	}
+
+	ifNode struct {
+		cond     node
+		then     node
+		els      node
+		negative bool
+	}
+
+	whileNode struct {
+		cond  node
+		body  node
+		until bool
+	}
+
+	caseNode struct {
+		subject node
+		whens   []whenClause
+		els     node
+		line    int
+	}
+
+	rescueNode struct {
+		body    node
+		clauses []rescueClause
+		ensure  node
+	}
+
+	nextNode struct {
+		value node
+	}
+
+	breakNode struct {
+		value node
+	}
+)
+
+type node interface{}
+
+type blockParam struct {
+	name   string
+	params []blockParam // destructured |(key, value)|
+}
+
+type rescueClause struct {
+	classes []node // rescues StandardError when empty
+	name    string // the variable bound with => e
+	body    node
+}
+
+type whenClause struct {
+	values []node
+	body   node
+}
+
+type rubyParser struct {
+	tokens []token
+	pos    int
+	scopes []map[string]bool
+	noDo   int
+}
+
+// parseTemplate compiles an ERB template into its syntax tree
+func parseTemplate(template string) (n node, err error) {
+	tokens, err := scanTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &rubyParser{tokens: tokens, scopes: []map[string]bool{{}}}
+	defer func() {
+		if r := recover(); r != nil {
+			rubyErr, ok := r.(*rubyError)
+			if !ok {
+				panic(r)
+			}
+			err = rubyErr
+		}
+	}()
+
+	program := p.parseStmts()
+	if p.peek().kind != tEOF {
+		p.unexpected()
+	}
+	return program, nil
+}
+
+func (p *rubyParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *rubyParser) peekAt(offset int) token {
+	if p.pos+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *rubyParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *rubyParser) isOp(value string) bool {
+	t := p.peek()
+	return t.kind == tOp && t.value == value
+}
+
+func (p *rubyParser) isWord(values ...string) bool {
+	t := p.peek()
+	if t.kind != tIdent {
+		return false
+	}
+	for _, value := range values {
+		if t.value == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *rubyParser) acceptOp(value string) bool {
+	if p.isOp(value) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *rubyParser) expectOp(value string) {
+	if !p.acceptOp(value) {
+		p.unexpected()
+	}
+}
+
+func (p *rubyParser) expectWord(value string) {
+	if !p.isWord(value) {
+		p.unexpected()
+	}
+	p.next()
+}
+
+func (p *rubyParser) unexpected() {
+	t := p.peek()
+	panic(rubySyntaxError(t.line, "unexpected %s", t))
+}
+
+func (p *rubyParser) skipNewlines() {
+	for p.peek().kind == tNewline || p.peek().kind == tTagEnd {
+		p.next()
+	}
+}
+
+// skipBlankText skips terminators and whitespace-only text, e.g. between 'case' and 'when' tags
+func (p *rubyParser) skipBlankText() {
+	for {
+		t := p.peek()
+		if t.kind == tNewline || t.kind == tTagEnd || (t.kind == tText && strings.TrimSpace(t.value) == "") {
+			p.next()
+			continue
+		}
+		return
+	}
+}
+
+func (p *rubyParser) pushScope() {
+	p.scopes = append(p.scopes, map[string]bool{})
+}
+
+func (p *rubyParser) popScope() {
+	p.scopes = p.scopes[:len(p.scopes)-1]
+}
+
+func (p *rubyParser) declare(name string) {
+	p.scopes[len(p.scopes)-1][name] = true
+}
+
+func (p *rubyParser) isLocal(name string) bool {
+	for _, scope := range p.scopes {
+		if scope[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *rubyParser) atStmtsEnd() bool {
+	t := p.peek()
+	switch t.kind {
+	case tEOF:
+		return true
+	case tOp:
+		return t.value == "}" || t.value == ")"
+	case tIdent:
+		switch t.value {
+		case "end", "else", "elsif", "when", "rescue", "ensure":
+			return true
+		}
+	}
+	return false
+}
+
+func (p *rubyParser) parseStmts() *seqNode {
+	seq := &seqNode{}
+	for {
+		p.skipNewlines()
+		if p.atStmtsEnd() {
+			return seq
+		}
+
+		t := p.peek()
+		switch t.kind {
+		case tText:
+			p.next()
+			seq.stmts = append(seq.stmts, &textNode{text: t.value})
+			continue
+		case tOutput:
+			p.next()
+			// like ERB, an output tag renders the value of the last of its statements
+			expr := &seqNode{}
+			for {
+				for p.peek().kind == tNewline {
+					p.next()
+				}
+				if p.peek().kind == tTagEnd {
+					break
+				}
+				expr.stmts = append(expr.stmts, p.parseStmt())
+				if next := p.peek().kind; next != tNewline && next != tTagEnd {
+					p.unexpected()
+				}
+			}
+			seq.stmts = append(seq.stmts, &outputNode{expr: expr, line: t.line})
+			continue
+		}
+
+		seq.stmts = append(seq.stmts, p.parseStmt())
+
+		t = p.peek()
+		if t.kind != tNewline && t.kind != tTagEnd && !p.atStmtsEnd() {
+			p.unexpected()
+		}
+	}
+}
+
+func (p *rubyParser) parseStmt() node {
+	n := p.parseExprStmt()
+	for {
+		switch {
+		case p.isWord("if"), p.isWord("unless"):
+			negative := p.next().value == "unless"
+			n = &ifNode{cond: p.parseExprStmt(), then: n, negative: negative}
+		case p.isWord("while"), p.isWord("until"):
+			until := p.next().value == "until"
+			n = &whileNode{cond: p.parseExprStmt(), body: n, until: until}
+		case p.isWord("rescue"):
+			p.next()
+			n = &rescueNode{body: n, clauses: []rescueClause{{body: p.parseExprStmt()}}}
+		default:
+			return n
+		}
+	}
+}
+
+func (p *rubyParser) parseExprStmt() node {
+	n := p.parseNotExpr()
+	for p.isWord("and", "or") {
+		and := p.next().value == "and"
+		p.skipNewlines()
+		right := p.parseNotExpr()
+		if and {
+			n = &andNode{left: n, right: right}
+		} else {
+			n = &orNode{left: n, right: right}
+		}
+	}
+	return n
+}
+
+func (p *rubyParser) parseNotExpr() node {
+	if p.isWord("not") {
+		p.next()
+		return &notNode{expr: p.parseNotExpr()}
+	}
+	return p.parseExpr()
+}
+
+var assignmentOps = map[string]string{
+	"+=": "+", "-=": "-", "*=": "*", "/=": "/", "%=": "%", "**=": "**", "||=": "||", "&&=": "&&",
+	"<<=": "<<", ">>=": ">>", "|=": "|", "&=": "&", "^=": "^",
+}
+
+func (p *rubyParser) parseExpr() node {
+	t := p.peek()
+	if t.kind == tIdent && !rubyKeywords[t.value] && p.peekAt(1).kind == tOp {
+		op := p.peekAt(1).value
+		if op == "=" {
+			p.next()
+			p.next()
+			p.skipNewlines()
+			p.declare(t.value)
+			return &assignNode{name: t.value, value: p.parseExprRHS()}
+		}
+		if binaryOp, found := assignmentOps[op]; found {
+			p.next()
+			p.next()
+			p.skipNewlines()
+			p.declare(t.value)
+			return &opAssignNode{target: &varNode{name: t.value}, op: binaryOp, value: p.parseExprRHS(), line: t.line}
+		}
+	}
+
+	n := p.parseTernary()
+
+	if p.peek().kind == tOp {
+		op := p.peek().value
+		call, isCall := n.(*callNode)
+		assignable := isCall && call.recv != nil && call.block == nil && (call.name == "[]" || (len(call.args) == 0 && !call.parens))
+		if op == "=" && assignable {
+			line := p.next().line
+			p.skipNewlines()
+			value := p.parseExprRHS()
+			if call.name == "[]" {
+				return &callNode{recv: call.recv, name: "[]=", args: append(call.args, value), line: line}
+			}
+			return &callNode{recv: call.recv, name: call.name + "=", args: []node{value}, line: line}
+		}
+		if binaryOp, found := assignmentOps[op]; found && assignable {
+			line := p.next().line
+			p.skipNewlines()
+			return &opAssignNode{target: call, op: binaryOp, value: p.parseExprRHS(), line: line}
+		}
+	}
+
+	return n
+}
+
+// parseExprRHS parses the value of an assignment, e.g. 'a = b = 1' or 'a = 1, 2'
+func (p *rubyParser) parseExprRHS() node {
+	n := p.parseExpr()
+	if p.isOp(",") {
+		elems := []node{n}
+		for p.acceptOp(",") {
+			p.skipNewlines()
+			elems = append(elems, p.parseExpr())
+		}
+		return &arrayNode{elems: elems}
+	}
+	return n
+}
+
+func (p *rubyParser) parseTernary() node {
+	cond := p.parseRange()
+	if !p.isOp("?") {
+		return cond
+	}
+	p.next()
+	p.skipNewlines()
+
+	var then node
+	if p.peek().kind == tLabel {
+		// 'a ? b: c'
+		t := p.next()
+		then = p.identNode(t)
+	} else {
+		then = p.parseTernary()
+		p.skipNewlines()
+		p.expectOp(":")
+	}
+	p.skipNewlines()
+	return &ifNode{cond: cond, then: then, els: p.parseTernary()}
+}
+
+func (p *rubyParser) identNode(t token) node {
+	if p.isLocal(t.value) {
+		return &varNode{name: t.value}
+	}
+	return &callNode{name: t.value, line: t.line}
+}
+
+func (p *rubyParser) parseRange() node {
+	low := p.parseBinary(0)
+	if p.isOp("..") || p.isOp("...") {
+		exclusive := p.next().value == "..."
+		return &rangeNode{low: low, high: p.parseBinary(0), exclusive: exclusive}
+	}
+	return low
+}
+
+// binaryOps lists the binary operators from lowest to highest precedence
+var binaryOps = [][]string{
+	{"||"},
+	{"&&"},
+	{"<=>", "==", "!=", "===", "=~", "!~"},
+	{"<", ">", "<=", ">="},
+	{"|", "^"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *rubyParser) parseBinary(level int) node {
+	if level == len(binaryOps) {
+		return p.parseUnaryMinus()
+	}
+
+	n := p.parseBinary(level + 1)
+	for {
+		t := p.peek()
+		if t.kind != tOp || !containsString(binaryOps[level], t.value) {
+			return n
+		}
+		p.next()
+		p.skipNewlines()
+		right := p.parseBinary(level + 1)
+
+		switch t.value {
+		case "||":
+			n = &orNode{left: n, right: right}
+		case "&&":
+			n = &andNode{left: n, right: right}
+		case "!=":
+			n = &notNode{expr: &callNode{recv: n, name: "==", args: []node{right}, line: t.line}}
+		case "!~":
+			n = &notNode{expr: &callNode{recv: n, name: "=~", args: []node{right}, line: t.line}}
+		default:
+			n = &callNode{recv: n, name: t.value, args: []node{right}, line: t.line}
+		}
+	}
+}
+
+func (p *rubyParser) parseUnaryMinus() node {
+	if p.isOp("-") {
+		t := p.next()
+		next := p.peek()
+		if (next.kind == tInt || next.kind == tFloat) && !next.spaceBefore {
+			p.next()
+			literal := p.numberLiteral(token{kind: next.kind, value: "-" + next.value, line: next.line})
+			return p.parsePowRest(p.parsePostfixRest(literal))
+		}
+		return &callNode{recv: p.parseUnaryMinus(), name: "-@", line: t.line}
+	}
+	return p.parsePowRest(p.parseUnary())
+}
+
+func (p *rubyParser) parsePowRest(n node) node {
+	if p.isOp("**") {
+		t := p.next()
+		return &callNode{recv: n, name: "**", args: []node{p.parseUnaryMinus()}, line: t.line}
+	}
+	return n
+}
+
+func (p *rubyParser) parseUnary() node {
+	switch {
+	case p.isOp("!"):
+		p.next()
+		return &notNode{expr: p.parseUnary()}
+	case p.isOp("+"):
+		p.next()
+		return p.parseUnary()
+	case p.isWord("defined?"):
+		panic(rubyUnsupportedError(p.peek().line, "defined? is not supported"))
+	}
+	return p.parsePostfixRest(p.parsePrimary())
+}
+
+func (p *rubyParser) parsePostfixRest(n node) node {
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tOp && (t.value == "." || t.value == "&."):
+			p.next()
+			p.skipNewlines()
+			nameToken := p.next()
+			if nameToken.kind != tIdent && nameToken.kind != tConst {
+				p.pos--
+				p.unexpected()
+			}
+			call := p.parseCallRest(n, nameToken.value, nameToken.line, true)
+			call.safeNav = t.value == "&."
+			n = call
+		case t.kind == tOp && t.value == "::":
+			p.next()
+			nameToken := p.next()
+			if nameToken.kind == tConst {
+				if constant, ok := n.(*constNode); ok {
+					n = &constNode{name: constant.name + "::" + nameToken.value, line: nameToken.line}
+					continue
+				}
+			}
+			if nameToken.kind != tIdent && nameToken.kind != tConst {
+				p.pos--
+				p.unexpected()
+			}
+			n = p.parseCallRest(n, nameToken.value, nameToken.line, true)
+		case t.kind == tOp && t.value == "[" && (!t.spaceBefore || isVarNode(n)):
+			p.next()
+			args, _ := p.parseArgs("]")
+			n = &callNode{recv: n, name: "[]", args: args, line: t.line}
+		default:
+			return n
+		}
+	}
+}
+
+func isVarNode(n node) bool {
+	_, ok := n.(*varNode)
+	return ok
+}
+
+// parseCallRest parses the arguments and block of a method call
+func (p *rubyParser) parseCallRest(recv node, name string, line int, allowCommand bool) *callNode {
+	call := &callNode{recv: recv, name: name, line: line}
+
+	switch {
+	case p.isOp("(") && !p.peek().spaceBefore:
+		p.next()
+		call.parens = true
+		call.args, call.blockArg = p.parseArgs(")")
+	case allowCommand && p.startsCommandArg():
+		call.args, call.blockArg = p.parseCommandArgs()
+	}
+
+	if call.blockArg == nil {
+		if p.isOp("{") && (call.parens || len(call.args) == 0) {
+			p.next()
+			call.block = p.parseBlock("}")
+		} else if p.isWord("do") && p.noDo == 0 {
+			p.next()
+			call.block = p.parseBlock("end")
+		}
+	}
+	return call
+}
+
+// startsCommandArg is true when the next token is the first argument of a call without parentheses, e.g. 'p "name"'
+func (p *rubyParser) startsCommandArg() bool {
+	t := p.peek()
+	if !t.spaceBefore {
+		return false
+	}
+	switch t.kind {
+	case tString, tInt, tFloat, tSymbol, tRegexp, tWords, tConst, tLabel:
+		return true
+	case tIdent:
+		if rubyKeywords[t.value] {
+			switch t.value {
+			case "nil", "true", "false", "self", "not", "defined?", "case", "if", "unless":
+				return t.value != "if" && t.value != "unless"
+			}
+			return false
+		}
+		return true
+	case tOp:
+		next := p.peekAt(1)
+		switch t.value {
+		case "[", "(", "!", "->", "::":
+			return true
+		case "-", "*", "&", ":":
+			return !next.spaceBefore
+		}
+	}
+	return false
+}
+
+func (p *rubyParser) parseCommandArgs() ([]node, node) {
+	p.noDo++
+	defer func() { p.noDo-- }()
+
+	args := []node{}
+	var blockArg node
+	var kwargs *hashNode
+	for {
+		if p.parseArg(&args, &blockArg, &kwargs) && p.isOp(",") {
+			p.next()
+			p.skipNewlines()
+			continue
+		}
+		break
+	}
+	if kwargs != nil {
+		args = append(args, kwargs)
+	}
+	return args, blockArg
+}
+
+// parseArgs parses comma separated arguments up to the closing bracket
+func (p *rubyParser) parseArgs(closing string) ([]node, node) {
+	p.noDo++
+	defer func() { p.noDo-- }()
+
+	args := []node{}
+	var blockArg node
+	var kwargs *hashNode
+	p.skipNewlines()
+	for !p.isOp(closing) {
+		p.parseArg(&args, &blockArg, &kwargs)
+		p.skipNewlines()
+		if !p.acceptOp(",") {
+			break
+		}
+		p.skipNewlines()
+	}
+	p.skipNewlines()
+	p.expectOp(closing)
+	if kwargs != nil {
+		args = append(args, kwargs)
+	}
+	return args, blockArg
+}
+
+// parseArg parses one argument, collecting 'key: value' and 'key => value' pairs into a trailing hash
+func (p *rubyParser) parseArg(args *[]node, blockArg *node, kwargs **hashNode) bool {
+	addPair := func(key node, value node) {
+		if *kwargs == nil {
+			*kwargs = &hashNode{}
+		}
+		(*kwargs).keys = append((*kwargs).keys, key)
+		(*kwargs).values = append((*kwargs).values, value)
+	}
+
+	switch {
+	case p.isOp("*"):
+		p.next()
+		*args = append(*args, &splatNode{expr: p.parseTernary()})
+	case p.isOp("&"):
+		p.next()
+		*blockArg = p.parseTernary()
+	case p.peek().kind == tLabel:
+		label := p.next()
+		p.skipNewlines()
+		addPair(&literalNode{value: rubySymbol(label.value)}, p.parseExpr())
+	default:
+		arg := p.parseNotExpr()
+		if p.acceptOp("=>") {
+			p.skipNewlines()
+			addPair(arg, p.parseExpr())
+		} else {
+			*args = append(*args, arg)
+		}
+	}
+	return true
+}
+
+func (p *rubyParser) parseBlock(closing string) *blockNode {
+	p.pushScope()
+	defer p.popScope()
+
+	block := &blockNode{}
+	if p.acceptOp("||") {
+		// no parameters
+	} else if p.acceptOp("|") {
+		block.params = p.parseBlockParams("|")
+	}
+
+	noDo := p.noDo
+	p.noDo = 0
+	block.body = p.parseStmts()
+	p.noDo = noDo
+
+	if closing == "}" {
+		p.expectOp("}")
+	} else {
+		p.expectWord("end")
+	}
+	return block
+}
+
+func (p *rubyParser) parseBlockParams(closing string) []blockParam {
+	params := []blockParam{}
+	for !p.isOp(closing) {
+		switch {
+		case p.acceptOp("("):
+			params = append(params, blockParam{params: p.parseBlockParams(")")})
+		case p.peek().kind == tIdent:
+			name := p.next().value
+			p.declare(name)
+			params = append(params, blockParam{name: name})
+		case p.acceptOp("*"), p.acceptOp("&"):
+			continue
+		default:
+			p.unexpected()
+		}
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	p.expectOp(closing)
+	return params
+}
+
+func (p *rubyParser) parsePrimary() node {
+	t := p.peek()
+	switch t.kind {
+	case tInt, tFloat:
+		p.next()
+		return p.numberLiteral(t)
+	case tString:
+		p.next()
+		return p.stringNode(t)
+	case tSymbol:
+		p.next()
+		return &literalNode{value: rubySymbol(t.value)}
+	case tRegexp:
+		p.next()
+		re, err := compileRubyRegexp(t.value, t.words[0])
+		if err != nil {
+			panic(rubySyntaxError(t.line, "invalid regexp /%s/: %s", t.value, err.Error()))
+		}
+		return &literalNode{value: re}
+	case tWords:
+		p.next()
+		elems := []node{}
+		for _, word := range t.words {
+			elems = append(elems, &literalNode{value: word})
+		}
+		return &arrayNode{elems: elems}
+	case tConst:
+		p.next()
+		if p.isOp("(") && !p.peek().spaceBefore {
+			// conversion functions, e.g. Integer("1")
+			return p.parseCallRest(nil, t.value, t.line, false)
+		}
+		return &constNode{name: t.value, line: t.line}
+	case tIdent:
+		return p.parseIdent()
+	case tOp:
+		switch t.value {
+		case "(":
+			p.next()
+			stmts := p.parseStmts()
+			p.skipNewlines()
+			p.expectOp(")")
+			if len(stmts.stmts) == 1 {
+				return stmts.stmts[0]
+			}
+			return stmts
+		case "[":
+			p.next()
+			elems, _ := p.parseArgs("]")
+			return &arrayNode{elems: elems}
+		case "{":
+			p.next()
+			return p.parseHash()
+		case "::":
+			p.next()
+			return p.parsePrimary()
+		case "->":
+			panic(rubyUnsupportedError(t.line, "lambdas are not supported"))
+		}
+	}
+	p.unexpected()
+	return nil
+}
+
+func (p *rubyParser) numberLiteral(t token) node {
+	if t.kind == tFloat {
+		f, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			panic(rubySyntaxError(t.line, "invalid number %s", t.value))
+		}
+		return &literalNode{value: f}
+	}
+	i, err := strconv.ParseInt(t.value, 0, 64)
+	if err != nil {
+		panic(rubySyntaxError(t.line, "invalid number %s", t.value))
+	}
+	return &literalNode{value: int(i)}
+}
+
+func (p *rubyParser) stringNode(t token) node {
+	if len(t.parts) == 1 && !t.parts[0].isCode {
+		return &literalNode{value: t.parts[0].literal}
+	}
+
+	str := &strNode{}
+	for _, part := range t.parts {
+		if !part.isCode {
+			str.parts = append(str.parts, &literalNode{value: part.literal})
+			continue
+		}
+
+		sub := &rubyParser{
+			tokens: append(part.code, token{kind: tEOF, line: t.line}),
+			scopes: p.scopes,
+		}
+		stmts := sub.parseStmts()
+		if sub.peek().kind != tEOF {
+			sub.unexpected()
+		}
+		str.parts = append(str.parts, stmts)
+	}
+	return str
+}
+
+func (p *rubyParser) parseHash() node {
+	hash := &hashNode{}
+	p.skipNewlines()
+	for !p.isOp("}") {
+		if p.peek().kind == tLabel {
+			label := p.next()
+			hash.keys = append(hash.keys, &literalNode{value: rubySymbol(label.value)})
+		} else {
+			hash.keys = append(hash.keys, p.parseExpr())
+			p.skipNewlines()
+			p.expectOp("=>")
+		}
+		p.skipNewlines()
+		hash.values = append(hash.values, p.parseExpr())
+		p.skipNewlines()
+		if !p.acceptOp(",") {
+			break
+		}
+		p.skipNewlines()
+	}
+	p.expectOp("}")
+	return hash
+}
+
+func (p *rubyParser) parseIdent() node {
+	t := p.next()
+	switch t.value {
+	case "nil":
+		return &literalNode{value: nil}
+	case "true":
+		return &literalNode{value: true}
+	case "false":
+		return &literalNode{value: false}
+	case "self":
+		return &selfNode{}
+	case "if", "unless":
+		return p.parseIf(t.value == "unless")
+	case "while", "until":
+		p.noDo++
+		cond := p.parseExprStmt()
+		p.noDo--
+		if p.isWord("do") {
+			p.next()
+		}
+		body := p.parseStmts()
+		p.expectWord("end")
+		return &whileNode{cond: cond, body: body, until: t.value == "until"}
+	case "case":
+		return p.parseCase(t)
+	case "begin":
+		return p.parseBegin()
+	case "next", "break":
+		var value node
+		if nt := p.peek(); nt.kind != tNewline && nt.kind != tTagEnd && !p.atStmtsEnd() && !p.isWord("if", "unless") {
+			value = p.parseExpr()
+		}
+		if t.value == "next" {
+			return &nextNode{value: value}
+		}
+		return &breakNode{value: value}
+	}
+
+	if rubyKeywords[t.value] {
+		p.pos--
+		if t.value == "def" || t.value == "class" || t.value == "module" || t.value == "yield" || t.value == "return" || t.value == "for" {
+			panic(rubyUnsupportedError(t.line, "'%s' is not supported in templates", t.value))
+		}
+		p.unexpected()
+	}
+
+	if p.isLocal(t.value) && !(p.isOp("(") && !p.peek().spaceBefore) {
+		return &varNode{name: t.value}
+	}
+	return p.parseCallRest(nil, t.value, t.line, true)
+}
+
+func (p *rubyParser) parseIf(negative bool) node {
+	cond := p.parseExprStmt()
+	p.acceptThen()
+	n := &ifNode{cond: cond, then: p.parseStmts(), negative: negative}
+
+	switch {
+	case p.isWord("elsif") && !negative:
+		p.next()
+		n.els = p.parseIf(false)
+		return n
+	case p.isWord("else"):
+		p.next()
+		n.els = p.parseStmts()
+	}
+	p.expectWord("end")
+	return n
+}
+
+func (p *rubyParser) acceptThen() {
+	for p.peek().kind == tNewline || p.peek().kind == tTagEnd {
+		p.next()
+	}
+	if p.isWord("then") {
+		p.next()
+	}
+}
+
+func (p *rubyParser) parseBegin() node {
+	n := &rescueNode{body: p.parseStmts()}
+
+	for p.isWord("rescue") {
+		p.next()
+		clause := rescueClause{}
+		for p.peek().kind != tNewline && p.peek().kind != tTagEnd && !p.isOp("=>") && !p.isWord("then") {
+			clause.classes = append(clause.classes, p.parseTernary())
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+		if p.acceptOp("=>") {
+			t := p.next()
+			if t.kind != tIdent {
+				panic(rubySyntaxError(t.line, "unexpected %s", t))
+			}
+			clause.name = t.value
+			p.declare(t.value)
+		}
+		p.acceptThen()
+		clause.body = p.parseStmts()
+		n.clauses = append(n.clauses, clause)
+	}
+
+	if p.isWord("ensure") {
+		p.next()
+		n.ensure = p.parseStmts()
+	}
+	p.expectWord("end")
+	return n
+}
+
+func (p *rubyParser) parseCase(t token) node {
+	n := &caseNode{line: t.line}
+	if p.peek().kind != tNewline && p.peek().kind != tTagEnd {
+		n.subject = p.parseExprStmt()
+	}
+	p.skipBlankText()
+
+	for p.isWord("when") {
+		p.next()
+		clause := whenClause{}
+		for {
+			p.skipNewlines()
+			if p.isOp("*") {
+				p.next()
+				clause.values = append(clause.values, &splatNode{expr: p.parseTernary()})
+			} else {
+				clause.values = append(clause.values, p.parseTernary())
+			}
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+		p.acceptThen()
+		clause.body = p.parseStmts()
+		n.whens = append(n.whens, clause)
+	}
+	if len(n.whens) == 0 {
+		p.unexpected()
+	}
+
+	if p.isWord("else") {
+		p.next()
+		n.els = p.parseStmts()
+	}
+	p.expectWord("end")
+	return n
+}
+
+// compileRubyRegexp translates the flags of a ruby regexp literal to go
+func compileRubyRegexp(source string, flags string) (*regexp.Regexp, error) {
+	goFlags := ""
+	if strings.Contains(flags, "i") {
+		goFlags += "i"
+	}
+	if strings.Contains(flags, "m") {
+		goFlags += "s"
+	}
+	// ruby's ^ and $ always match at line boundaries, \A and \z at the string boundaries
+	goFlags += "m"
+	source = strings.Replace(source, `\Z`, `\z`, -1)
+	source = strings.Replace(source, `\h`, `[0-9a-fA-F]`, -1)
+
+	return regexp.Compile("(?" + goFlags + ")" + source)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,534 @@
+package erbrenderer
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Ruby values are represented as nil, bool, int, float64, string, rubySymbol,
+// *rubyArray, *rubyHash, *rubyRange, *openStruct, *regexp.Regexp, *matchData, rubyClass, *rubyError, *elseBlock and *templateLink.
+
+type rubySymbol string
+
+type rubyClass string
+
+type rubyArray struct {
+	items []interface{}
+}
+
+// rubyHash keeps the insertion order of its keys like ruby's Hash
+type rubyHash struct {
+	keys   []interface{}
+	values []interface{}
+	index  map[interface{}]int
+}
+
+type rubyRange struct {
+	low       int
+	high      int
+	exclusive bool
+}
+
+// openStruct exposes the keys of a hash as methods, e.g. spec.networks.default.ip
+type openStruct struct {
+	fields *rubyHash
+}
+
+type matchData struct {
+	groups []interface{}
+}
+
+func newArray(items ...interface{}) *rubyArray {
+	if items == nil {
+		items = []interface{}{}
+	}
+	return &rubyArray{items: items}
+}
+
+func newHash() *rubyHash {
+	return &rubyHash{index: map[interface{}]int{}}
+}
+
+// hashKey returns a comparable key with ruby's eql? semantics
+func hashKey(key interface{}) interface{} {
+	switch k := key.(type) {
+	case nil, bool, int, float64, string, rubySymbol, rubyClass:
+		return k
+	default:
+		return "\x00" + inspect(key)
+	}
+}
+
+func (h *rubyHash) get(key interface{}) (interface{}, bool) {
+	i, found := h.index[hashKey(key)]
+	if !found {
+		return nil, false
+	}
+	return h.values[i], true
+}
+
+func (h *rubyHash) set(key interface{}, value interface{}) {
+	k := hashKey(key)
+	if i, found := h.index[k]; found {
+		h.values[i] = value
+		return
+	}
+	h.index[k] = len(h.keys)
+	h.keys = append(h.keys, key)
+	h.values = append(h.values, value)
+}
+
+func (h *rubyHash) delete(key interface{}) (interface{}, bool) {
+	k := hashKey(key)
+	i, found := h.index[k]
+	if !found {
+		return nil, false
+	}
+	value := h.values[i]
+	h.keys = append(h.keys[:i:i], h.keys[i+1:]...)
+	h.values = append(h.values[:i:i], h.values[i+1:]...)
+	h.reindex()
+	return value, true
+}
+
+func (h *rubyHash) reindex() {
+	h.index = map[interface{}]int{}
+	for i, key := range h.keys {
+		h.index[hashKey(key)] = i
+	}
+}
+
+func (h *rubyHash) len() int {
+	return len(h.keys)
+}
+
+func (h *rubyHash) dup() *rubyHash {
+	dup := newHash()
+	for i, key := range h.keys {
+		dup.set(key, h.values[i])
+	}
+	return dup
+}
+
+// pairs returns the [key, value] arrays of the hash
+func (h *rubyHash) pairs() []interface{} {
+	pairs := make([]interface{}, len(h.keys))
+	for i, key := range h.keys {
+		pairs[i] = newArray(key, h.values[i])
+	}
+	return pairs
+}
+
+func (r *rubyRange) items() []interface{} {
+	items := []interface{}{}
+	high := r.high
+	if r.exclusive {
+		high--
+	}
+	for i := r.low; i <= high; i++ {
+		items = append(items, i)
+	}
+	return items
+}
+
+func (r *rubyRange) includes(v interface{}) bool {
+	f, ok := toFloat(v)
+	if !ok {
+		return false
+	}
+	if r.exclusive {
+		return f >= float64(r.low) && f < float64(r.high)
+	}
+	return f >= float64(r.low) && f <= float64(r.high)
+}
+
+// toOpenStruct converts hashes to open structs, also within arrays, like the ruby renderer's openstruct helper
+func toOpenStruct(v interface{}) interface{} {
+	switch value := v.(type) {
+	case *rubyHash:
+		fields := newHash()
+		for i, key := range value.keys {
+			fields.set(toS(key), toOpenStruct(value.values[i]))
+		}
+		return &openStruct{fields: fields}
+	case *rubyArray:
+		items := make([]interface{}, len(value.items))
+		for i, item := range value.items {
+			items[i] = toOpenStruct(item)
+		}
+		return newArray(items...)
+	}
+	return v
+}
+
+func truthy(v interface{}) bool {
+	switch value := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return value
+	}
+	return true
+}
+
+func className(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "NilClass"
+	case bool:
+		if v.(bool) {
+			return "TrueClass"
+		}
+		return "FalseClass"
+	case int:
+		return "Integer"
+	case float64:
+		return "Float"
+	case string:
+		return "String"
+	case rubySymbol:
+		return "Symbol"
+	case *rubyArray:
+		return "Array"
+	case *rubyHash:
+		return "Hash"
+	case *rubyRange:
+		return "Range"
+	case *openStruct:
+		return "OpenStruct"
+	case *regexp.Regexp:
+		return "Regexp"
+	case *matchData:
+		return "MatchData"
+	case rubyClass:
+		return "Class"
+	case *elseBlock:
+		return "TemplateEvaluationContext::ElseBlock"
+	case *templateLink:
+		return "TemplateEvaluationContext::Link"
+	case *templateContext:
+		return "TemplateEvaluationContext"
+	case *rubyError:
+		return v.(*rubyError).class
+	}
+	return "Object"
+}
+
+// classAncestors lists the classes a value is_a?
+var classAncestors = map[string][]string{
+	"Integer":   {"Integer", "Numeric", "Comparable"},
+	"Float":     {"Float", "Numeric", "Comparable"},
+	"String":    {"String", "Comparable"},
+	"Array":     {"Array", "Enumerable"},
+	"Hash":      {"Hash", "Enumerable"},
+	"Range":     {"Range", "Enumerable"},
+	"TrueClass": {"TrueClass"},
+}
+
+func isA(v interface{}, class rubyClass) bool {
+	if err, ok := v.(*rubyError); ok {
+		return isException(err, string(class)) || class == "Object" || class == "BasicObject" || class == "Kernel"
+	}
+	name := className(v)
+	if string(class) == name || class == "Object" || class == "BasicObject" || class == "Kernel" {
+		return true
+	}
+	return containsString(classAncestors[name], string(class))
+}
+
+// toS converts a value like ruby's to_s, e.g. for <%= %> tags and string interpolation
+func toS(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case rubySymbol:
+		return string(value)
+	case rubyClass:
+		return string(value)
+	case *matchData:
+		return toS(value.groups[0])
+	case *rubyError:
+		return value.message
+	}
+	return inspect(v)
+}
+
+func inspect(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(value)
+	case int:
+		return strconv.Itoa(value)
+	case float64:
+		return formatFloat(value)
+	case string:
+		return inspectString(value)
+	case rubySymbol:
+		if isSymbolName(string(value)) {
+			return ":" + string(value)
+		}
+		return ":" + inspectString(string(value))
+	case rubyClass:
+		return string(value)
+	case *rubyArray:
+		items := make([]string, len(value.items))
+		for i, item := range value.items {
+			items[i] = inspect(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case *rubyHash:
+		pairs := make([]string, len(value.keys))
+		for i, key := range value.keys {
+			pairs[i] = inspect(key) + "=>" + inspect(value.values[i])
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	case *rubyRange:
+		if value.exclusive {
+			return fmt.Sprintf("%d...%d", value.low, value.high)
+		}
+		return fmt.Sprintf("%d..%d", value.low, value.high)
+	case *openStruct:
+		fields := make([]string, len(value.fields.keys))
+		for i, key := range value.fields.keys {
+			fields[i] = toS(key) + "=" + inspect(value.fields.values[i])
+		}
+		if len(fields) == 0 {
+			return "#<OpenStruct>"
+		}
+		return "#<OpenStruct " + strings.Join(fields, ", ") + ">"
+	case *regexp.Regexp:
+		return "/" + strings.TrimPrefix(strings.TrimPrefix(value.String(), "(?m)"), "(?im)") + "/"
+	case *matchData:
+		return "#<MatchData " + inspect(value.groups[0]) + ">"
+	case *rubyError:
+		return value.Error()
+	}
+	return "#<" + className(v) + ">"
+}
+
+func inspectString(s string) string {
+	var buf strings.Builder
+	buf.WriteByte('"')
+	for i, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case 27:
+			buf.WriteString(`\e`)
+		case '#':
+			if strings.HasPrefix(s[i+1:], "{") || strings.HasPrefix(s[i+1:], "$") || strings.HasPrefix(s[i+1:], "@") {
+				buf.WriteString(`\#`)
+			} else {
+				buf.WriteByte('#')
+			}
+		default:
+			if r == unicode.ReplacementChar || !unicode.IsPrint(r) && r != ' ' {
+				if r < 0x80 {
+					fmt.Fprintf(&buf, `\x%02X`, r)
+				} else {
+					fmt.Fprintf(&buf, `\u%04X`, r)
+				}
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+func isSymbolName(s string) bool {
+	if s == "" || isDigit(s[0]) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isIdentChar(s[i]) && !(i == len(s)-1 && strings.IndexByte("?!=", s[i]) >= 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatFloat formats a float like ruby's Float#to_s, e.g. 1.0, 0.0001 or 1.0e+20
+func formatFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case f == 0:
+		if math.Signbit(f) {
+			return "-0.0"
+		}
+		return "0.0"
+	}
+
+	exponent := int(math.Floor(math.Log10(math.Abs(f))))
+	if exponent >= -4 && exponent < 16 {
+		s := strconv.FormatFloat(f, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s
+	}
+
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exp := s[:strings.Index(s, "e")], s[strings.Index(s, "e"):]
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	return mantissa + exp
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch value := v.(type) {
+	case int:
+		return float64(value), true
+	case float64:
+		return value, true
+	}
+	return 0, false
+}
+
+func rubyEqual(a, b interface{}) bool {
+	switch x := a.(type) {
+	case int, float64:
+		fa, _ := toFloat(x)
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	case *rubyArray:
+		y, ok := b.(*rubyArray)
+		if !ok || len(x.items) != len(y.items) {
+			return false
+		}
+		for i := range x.items {
+			if !rubyEqual(x.items[i], y.items[i]) {
+				return false
+			}
+		}
+		return true
+	case *rubyHash:
+		y, ok := b.(*rubyHash)
+		if !ok || x.len() != y.len() {
+			return false
+		}
+		for i, key := range x.keys {
+			value, found := y.get(key)
+			if !found || !rubyEqual(x.values[i], value) {
+				return false
+			}
+		}
+		return true
+	case *openStruct:
+		y, ok := b.(*openStruct)
+		return ok && rubyEqual(x.fields, y.fields)
+	case *rubyRange:
+		y, ok := b.(*rubyRange)
+		return ok && *x == *y
+	case *regexp.Regexp:
+		y, ok := b.(*regexp.Regexp)
+		return ok && x.String() == y.String()
+	case *matchData, *elseBlock, *templateLink, *templateContext:
+		return a == b
+	}
+	return a == b
+}
+
+// rubyCompare implements <=>, returning false when the values are not comparable
+func rubyCompare(a, b interface{}) (int, bool) {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	case rubySymbol:
+		y, ok := b.(rubySymbol)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(string(x), string(y)), true
+	case *rubyArray:
+		y, ok := b.(*rubyArray)
+		if !ok {
+			return 0, false
+		}
+		for i := 0; i < len(x.items) && i < len(y.items); i++ {
+			c, ok := rubyCompare(x.items[i], y.items[i])
+			if !ok {
+				return 0, false
+			}
+			if c != 0 {
+				return c, true
+			}
+		}
+		return rubyCompareInts(len(x.items), len(y.items)), true
+	}
+	return 0, false
+}
+
+func rubyCompareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// sortValues sorts values with <=>, or with less when given
+func (i *rubyInterpreter) sortValues(values []interface{}, less func(a, b interface{}) bool) []interface{} {
+	sorted := append([]interface{}{}, values...)
+	if less == nil {
+		less = func(a, b interface{}) bool {
+			return i.compare(a, b) < 0
+		}
+	}
+	sort.SliceStable(sorted, func(x, y int) bool {
+		return less(sorted[x], sorted[y])
+	})
+	return sorted
+}
+
+func (i *rubyInterpreter) compare(a, b interface{}) int {
+	c, ok := rubyCompare(a, b)
+	if !ok {
+		i.raise("ArgumentError", "comparison of %s with %s failed", className(a), inspect(b))
+	}
+	return c
+}
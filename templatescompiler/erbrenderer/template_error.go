@@ -0,0 +1,129 @@
+package erbrenderer
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// templateExcerptContext is the number of lines shown before and after the failing line of a template
+const templateExcerptContext = 2
+
+// TemplateError is returned by ERB renderers when a template fails to compile or evaluate
+type TemplateError struct {
+	// Release and ReleaseJob are empty until set by the job renderer
+	Release    string
+	ReleaseJob string
+
+	Job      string // the deployment job being rendered
+	Index    int
+	Template string
+	Line     int // 0 when the line is unknown
+
+	// Message is the ruby exception, e.g. "#<TemplateEvaluationContext::UnknownProperty: Can't find property 'port'>"
+	Message string
+
+	// MissingProperties are the names given to p() (including its fallback names) when no property was found
+	MissingProperties []string
+
+	Excerpt []TemplateLine
+}
+
+type TemplateLine struct {
+	Number int
+	Text   string
+}
+
+func NewTemplateError(templatePath string, template string, job string, index int, line int, message string, missingProperties []string) TemplateError {
+	return TemplateError{
+		Job:               job,
+		Index:             index,
+		Template:          templatePath,
+		Line:              line,
+		Message:           message,
+		MissingProperties: missingProperties,
+		Excerpt:           templateExcerpt(template, line),
+	}
+}
+
+// Error describes the error like the ruby renderer of earlier versions did
+func (e TemplateError) Error() string {
+	return fmt.Sprintf("Error filling in template '%s' for %s/%d (line %s: %s)", e.Template, e.Job, e.Index, e.lineString(), e.Message)
+}
+
+// MultilineExplanation describes the error as a readable block, with an excerpt of the template around the failing line
+func (e TemplateError) MultilineExplanation() []string {
+	lines := []string{fmt.Sprintf("Error filling in template '%s' (line %s):", e.Template, e.lineString())}
+	if e.Release != "" {
+		lines = append(lines, fmt.Sprintf("  Release: %s", e.Release))
+	}
+	if e.ReleaseJob != "" {
+		lines = append(lines, fmt.Sprintf("  Release job: %s", e.ReleaseJob))
+	}
+	lines = append(lines, fmt.Sprintf("  Instance: %s/%d", e.Job, e.Index))
+	lines = append(lines, fmt.Sprintf("  Error: %s", e.Message))
+	if len(e.MissingProperties) > 0 {
+		lines = append(lines, fmt.Sprintf("  Missing properties: '%s'", strings.Join(e.MissingProperties, "', or '")))
+	}
+
+	if len(e.Excerpt) > 0 {
+		numberWidth := len(strconv.Itoa(e.Excerpt[len(e.Excerpt)-1].Number))
+		lines = append(lines, "  Excerpt:")
+		for _, excerptLine := range e.Excerpt {
+			marker := " "
+			if excerptLine.Number == e.Line {
+				marker = ">"
+			}
+			lines = append(lines, fmt.Sprintf("  %s %*d | %s", marker, numberWidth, excerptLine.Number, excerptLine.Text))
+		}
+	}
+
+	return lines
+}
+
+func (e TemplateError) lineString() string {
+	if e.Line < 1 {
+		return "unknown"
+	}
+	return strconv.Itoa(e.Line)
+}
+
+func templateExcerpt(template string, line int) []TemplateLine {
+	templateLines := strings.Split(template, "\n")
+	if line < 1 || line > len(templateLines) {
+		return nil
+	}
+
+	excerpt := []TemplateLine{}
+	for number := line - templateExcerptContext; number <= line+templateExcerptContext; number++ {
+		if number >= 1 && number <= len(templateLines) {
+			excerpt = append(excerpt, TemplateLine{Number: number, Text: strings.TrimRight(templateLines[number-1], "\r")})
+		}
+	}
+	return excerpt
+}
+
+var (
+	rubyTemplateErrorPattern   = regexp.MustCompile(`Error filling in template '(.*?)' for (.*?)/(\d*) \(line (\w+): (#<.*>)\)`)
+	rubyUnknownPropertyPattern = regexp.MustCompile(`^#<TemplateEvaluationContext::UnknownProperty: Can't find property '(.*)'>$`)
+)
+
+// parseRubyTemplateError parses the error raised by the ruby renderer script
+func parseRubyTemplateError(stderr string, template string) (TemplateError, bool) {
+	matches := rubyTemplateErrorPattern.FindStringSubmatch(stderr)
+	if matches == nil {
+		return TemplateError{}, false
+	}
+
+	index, _ := strconv.Atoi(matches[3])
+	line, _ := strconv.Atoi(matches[4])
+	message := matches[5]
+
+	var missingProperties []string
+	if propertyMatches := rubyUnknownPropertyPattern.FindStringSubmatch(message); propertyMatches != nil {
+		missingProperties = strings.Split(propertyMatches[1], "', or '")
+	}
+
+	return NewTemplateError(matches[1], template, matches[2], index, line, message, missingProperties), true
+}
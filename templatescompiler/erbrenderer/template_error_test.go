@@ -0,0 +1,84 @@
+package erbrenderer_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-init/templatescompiler/erbrenderer"
+)
+
+var _ = Describe("TemplateError", func() {
+	var templateErr TemplateError
+
+	BeforeEach(func() {
+		templateErr = NewTemplateError(
+			"/fake-src-path",
+			"line one\nline two\nline three\n<%= p(['fake-property', 'fake-fallback']) %>\nline five\nline six\nline seven\nline eight\nline nine\nline ten",
+			"fake-job-name",
+			1,
+			4,
+			"#<TemplateEvaluationContext::UnknownProperty: Can't find property 'fake-property', or 'fake-fallback'>",
+			[]string{"fake-property", "fake-fallback"},
+		)
+	})
+
+	Describe("Error", func() {
+		It("describes the template, instance, line and ruby exception", func() {
+			Expect(templateErr.Error()).To(Equal(
+				"Error filling in template '/fake-src-path' for fake-job-name/1 " +
+					"(line 4: #<TemplateEvaluationContext::UnknownProperty: Can't find property 'fake-property', or 'fake-fallback'>)",
+			))
+		})
+
+		It("describes the line as unknown when it is not known", func() {
+			templateErr.Line = 0
+			Expect(templateErr.Error()).To(ContainSubstring("(line unknown: "))
+		})
+	})
+
+	Describe("MultilineExplanation", func() {
+		BeforeEach(func() {
+			templateErr.Release = "fake-release-name"
+			templateErr.ReleaseJob = "fake-release-job-name"
+		})
+
+		It("explains the error with an excerpt of the template around the failing line", func() {
+			Expect(templateErr.MultilineExplanation()).To(Equal([]string{
+				"Error filling in template '/fake-src-path' (line 4):",
+				"  Release: fake-release-name",
+				"  Release job: fake-release-job-name",
+				"  Instance: fake-job-name/1",
+				"  Error: #<TemplateEvaluationContext::UnknownProperty: Can't find property 'fake-property', or 'fake-fallback'>",
+				"  Missing properties: 'fake-property', or 'fake-fallback'",
+				"  Excerpt:",
+				"    2 | line two",
+				"    3 | line three",
+				"  > 4 | <%= p(['fake-property', 'fake-fallback']) %>",
+				"    5 | line five",
+				"    6 | line six",
+			}))
+		})
+
+		It("aligns line numbers of different widths", func() {
+			templateErr.Line = 9
+			templateErr.Excerpt = []TemplateLine{
+				{Number: 8, Text: "line eight"},
+				{Number: 9, Text: "line nine"},
+				{Number: 10, Text: "line ten"},
+			}
+
+			Expect(templateErr.MultilineExplanation()).To(ContainElement("  >  9 | line nine"))
+			Expect(templateErr.MultilineExplanation()).To(ContainElement("    10 | line ten"))
+		})
+
+		It("leaves out what is not known", func() {
+			templateErr = NewTemplateError("/fake-src-path", "fake-template", "fake-job-name", 0, 0, "#<SyntaxError: fake-message>", nil)
+
+			Expect(templateErr.MultilineExplanation()).To(Equal([]string{
+				"Error filling in template '/fake-src-path' (line unknown):",
+				"  Instance: fake-job-name/0",
+				"  Error: #<SyntaxError: fake-message>",
+			}))
+		})
+	})
+})
@@ -39,6 +39,7 @@ class TemplateEvaluationContext
     @properties = openstruct(properties)
     @raw_properties = properties
     @spec = openstruct(spec)
+    @links = spec['links'] || {}
   end
 
   def get_binding
@@ -68,6 +69,20 @@ class TemplateEvaluationContext
     InactiveElseBlock.new
   end
 
+  def link(name)
+    link_spec = @links[name]
+    raise UnknownLink.new(name) if link_spec.nil?
+    Link.new(name, link_spec)
+  end
+
+  def if_link(name)
+    link_spec = @links[name]
+    return ActiveElseBlock.new(self) if link_spec.nil?
+
+    yield Link.new(name, link_spec)
+    InactiveElseBlock.new
+  end
+
   private
 
   def copy_property(dst, src, name, default = nil)
@@ -122,6 +137,60 @@ class TemplateEvaluationContext
     end
   end
 
+  class UnknownLink < StandardError
+    def initialize(name)
+      super("Can't find link '#{name}'")
+    end
+  end
+
+  class Link
+    attr_reader :name, :instances, :address
+
+    def initialize(name, link_spec)
+      @name = name
+      @properties = link_spec['properties'] || {}
+      @instances = (link_spec['instances'] || []).map { |instance| OpenStruct.new(instance) }
+      @address = link_spec['address']
+    end
+
+    def p(*args)
+      names = Array(args[0])
+
+      names.each do |name|
+        result = lookup_property(@properties, name)
+        return result unless result.nil?
+      end
+
+      return args[1] if args.length == 2
+      raise UnknownProperty.new(names)
+    end
+
+    def if_p(*names)
+      values = names.map do |name|
+        value = lookup_property(@properties, name)
+        return ActiveElseBlock.new(self) if value.nil?
+        value
+      end
+
+      yield *values
+      InactiveElseBlock.new
+    end
+
+    private
+
+    def lookup_property(collection, name)
+      keys = name.split(".")
+      ref = collection
+
+      keys.each do |key|
+        ref = ref[key]
+        return nil if ref.nil?
+      end
+
+      ref
+    end
+  end
+
   class ActiveElseBlock
     def initialize(template)
       @context = template
@@ -165,7 +234,12 @@ class ERBRenderer
   end
 
   def render(src_path, dst_path)
-    erb = ERB.new(File.read(src_path))
+    # '-' trim mode, as implemented by the native renderer (trim_mode is a keyword argument since ruby 2.6)
+    erb = if ERB.instance_method(:initialize).parameters.assoc(:key)
+      ERB.new(File.read(src_path), trim_mode: "-")
+    else
+      ERB.new(File.read(src_path), nil, "-")
+    end
     erb.filename = src_path
 
     File.open(dst_path, "w") do |f|
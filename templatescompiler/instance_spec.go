@@ -0,0 +1,58 @@
+package templatescompiler
+
+import (
+	"sort"
+)
+
+// InstanceSpec describes the instance that job templates are rendered for.
+// Templates see it as spec.id, spec.index, spec.az, spec.bootstrap, spec.address, spec.ip and spec.networks.
+type InstanceSpec struct {
+	ID        string
+	Index     int
+	AZ        string
+	Bootstrap bool
+	Networks  map[string]NetworkSpec
+
+	// Links are the links consumed by each release job, keyed by release job name and link name
+	Links map[string]map[string]LinkSpec
+}
+
+type NetworkSpec struct {
+	IP      string
+	Netmask string
+	Gateway string
+	DNS     []string
+	Default []string
+}
+
+// DefaultNetwork returns the name of the network providing the default gateway,
+// or the only network when there is just one
+func (s InstanceSpec) DefaultNetwork() (string, bool) {
+	names := make([]string, 0, len(s.Networks))
+	for name := range s.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, defaultFlag := range s.Networks[name].Default {
+			if defaultFlag == "gateway" {
+				return name, true
+			}
+		}
+	}
+
+	if len(names) == 1 {
+		return names[0], true
+	}
+	return "", false
+}
+
+// Address returns the IP of the default network, which is empty until the IP is known (e.g. for dynamic networks)
+func (s InstanceSpec) Address() string {
+	name, found := s.DefaultNetwork()
+	if !found {
+		return ""
+	}
+	return s.Networks[name].IP
+}
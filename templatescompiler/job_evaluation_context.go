@@ -16,6 +16,7 @@ type jobEvaluationContext struct {
 	jobProperties    biproperty.Map
 	globalProperties biproperty.Map
 	deploymentName   string
+	instanceSpec     InstanceSpec
 	logger           boshlog.Logger
 	logTag           string
 }
@@ -23,13 +24,23 @@ type jobEvaluationContext struct {
 // RootContext is exposed as an open struct in ERB templates.
 // It must stay same to provide backwards compatible API.
 type RootContext struct {
+	ID         string     `json:"id"`
 	Index      int        `json:"index"`
+	AZ         string     `json:"az,omitempty"`
+	Bootstrap  bool       `json:"bootstrap"`
 	JobContext jobContext `json:"job"`
 	Deployment string     `json:"deployment"`
 
+	// IP of the default network, usually accessed with <%= spec.address %>
+	Address string `json:"address"`
+	IP      string `json:"ip"`
+
 	// Usually is accessed with <%= spec.networks.default.ip %>
 	NetworkContexts map[string]networkContext `json:"networks"`
 
+	// Links consumed by the job, usually accessed with <%= link('name').address %>
+	LinkContexts map[string]linkContext `json:"links"`
+
 	//TODO: this should be a map[string]interface{}
 	GlobalProperties  biproperty.Map `json:"global_properties"`  // values from manifest's top-level properties
 	ClusterProperties biproperty.Map `json:"cluster_properties"` // values from manifest's jobs[].properties
@@ -41,9 +52,26 @@ type jobContext struct {
 }
 
 type networkContext struct {
-	IP      string `json:"ip"`
-	Netmask string `json:"netmask"`
-	Gateway string `json:"gateway"`
+	IP      string   `json:"ip"`
+	Netmask string   `json:"netmask"`
+	Gateway string   `json:"gateway"`
+	DNS     []string `json:"dns,omitempty"`
+	Default []string `json:"default,omitempty"`
+}
+
+type linkContext struct {
+	Address    string                `json:"address"`
+	Instances  []linkInstanceContext `json:"instances"`
+	Properties biproperty.Map        `json:"properties"`
+}
+
+type linkInstanceContext struct {
+	Name      string `json:"name"`
+	ID        string `json:"id"`
+	Index     int    `json:"index"`
+	AZ        string `json:"az,omitempty"`
+	Address   string `json:"address"`
+	Bootstrap bool   `json:"bootstrap"`
 }
 
 func NewJobEvaluationContext(
@@ -51,6 +79,7 @@ func NewJobEvaluationContext(
 	jobProperties biproperty.Map,
 	globalProperties biproperty.Map,
 	deploymentName string,
+	instanceSpec InstanceSpec,
 	logger boshlog.Logger,
 ) bierbrenderer.TemplateEvaluationContext {
 	return jobEvaluationContext{
@@ -58,6 +87,7 @@ func NewJobEvaluationContext(
 		jobProperties:    jobProperties,
 		globalProperties: globalProperties,
 		deploymentName:   deploymentName,
+		instanceSpec:     instanceSpec,
 		logger:           logger,
 		logTag:           "jobEvaluationContext",
 	}
@@ -67,10 +97,16 @@ func (ec jobEvaluationContext) MarshalJSON() ([]byte, error) {
 	defaultProperties := ec.propertyDefaults(ec.releaseJob.Properties)
 
 	context := RootContext{
-		Index:             0,
+		ID:                ec.instanceSpec.ID,
+		Index:             ec.instanceSpec.Index,
+		AZ:                ec.instanceSpec.AZ,
+		Bootstrap:         ec.instanceSpec.Bootstrap,
 		JobContext:        jobContext{Name: ec.releaseJob.Name},
 		Deployment:        ec.deploymentName,
+		Address:           ec.instanceSpec.Address(),
+		IP:                ec.instanceSpec.Address(),
 		NetworkContexts:   ec.buildNetworkContexts(),
+		LinkContexts:      ec.buildLinkContexts(),
 		GlobalProperties:  ec.globalProperties,
 		ClusterProperties: ec.jobProperties,
 		DefaultProperties: defaultProperties,
@@ -95,10 +131,61 @@ func (ec jobEvaluationContext) propertyDefaults(properties map[string]bireljob.P
 }
 
 func (ec jobEvaluationContext) buildNetworkContexts() map[string]networkContext {
-	// IP is being returned by agent
-	return map[string]networkContext{
-		"default": networkContext{
-			IP: "",
-		},
+	if len(ec.instanceSpec.Networks) == 0 {
+		// installation jobs are not rendered for an instance
+		return map[string]networkContext{
+			"default": networkContext{
+				IP: "",
+			},
+		}
+	}
+
+	// IP of dynamic networks is being returned by agent
+	networkContexts := map[string]networkContext{}
+	for name, network := range ec.instanceSpec.Networks {
+		networkContexts[name] = networkContext{
+			IP:      network.IP,
+			Netmask: network.Netmask,
+			Gateway: network.Gateway,
+			DNS:     network.DNS,
+			Default: network.Default,
+		}
+	}
+
+	// templates written for earlier versions of bosh-init refer to spec.networks.default
+	if _, found := networkContexts["default"]; !found {
+		if name, found := ec.instanceSpec.DefaultNetwork(); found {
+			networkContexts["default"] = networkContexts[name]
+		}
+	}
+	return networkContexts
+}
+
+func (ec jobEvaluationContext) buildLinkContexts() map[string]linkContext {
+	linkContexts := map[string]linkContext{}
+	for name, link := range ec.instanceSpec.Links[ec.releaseJob.Name] {
+		instances := make([]linkInstanceContext, len(link.Instances), len(link.Instances))
+		for i, instance := range link.Instances {
+			instances[i] = linkInstanceContext{
+				Name:      instance.Name,
+				ID:        instance.ID,
+				Index:     instance.Index,
+				AZ:        instance.AZ,
+				Address:   instance.Address,
+				Bootstrap: instance.Bootstrap,
+			}
+		}
+
+		properties := link.Properties
+		if properties == nil {
+			properties = biproperty.Map{}
+		}
+
+		linkContexts[name] = linkContext{
+			Address:    link.Address(),
+			Instances:  instances,
+			Properties: properties,
+		}
 	}
+	return linkContexts
 }
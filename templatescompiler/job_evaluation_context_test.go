@@ -26,6 +26,7 @@ var _ = Describe("JobEvaluationContext", func() {
 		releaseJob        bireljob.Job
 		clusterProperties biproperty.Map
 		globalProperties  biproperty.Map
+		instanceSpec      InstanceSpec
 	)
 	BeforeEach(func() {
 		generatedContext = RootContext{}
@@ -50,6 +51,8 @@ var _ = Describe("JobEvaluationContext", func() {
 				"fake-global-property2": "value-from-global-properties",
 			},
 		}
+
+		instanceSpec = InstanceSpec{}
 	})
 
 	JustBeforeEach(func() {
@@ -60,6 +63,7 @@ var _ = Describe("JobEvaluationContext", func() {
 			clusterProperties,
 			globalProperties,
 			"fake-deployment-name",
+			instanceSpec,
 			logger,
 		)
 
@@ -74,6 +78,104 @@ var _ = Describe("JobEvaluationContext", func() {
 		Expect(generatedContext.NetworkContexts["default"].IP).To(Equal(""))
 	})
 
+	Context("when rendering for an instance", func() {
+		BeforeEach(func() {
+			instanceSpec = InstanceSpec{
+				ID:        "fake-instance-id",
+				Index:     0,
+				AZ:        "fake-az",
+				Bootstrap: true,
+				Networks: map[string]NetworkSpec{
+					"fake-manual-network": NetworkSpec{
+						IP:      "10.0.0.5",
+						Netmask: "255.255.255.0",
+						Gateway: "10.0.0.1",
+						DNS:     []string{"10.0.0.2"},
+						Default: []string{"dns", "gateway"},
+					},
+					"fake-vip-network": NetworkSpec{
+						IP: "1.2.3.4",
+					},
+				},
+			}
+		})
+
+		It("has the instance identity", func() {
+			Expect(generatedContext.ID).To(Equal("fake-instance-id"))
+			Expect(generatedContext.Index).To(Equal(0))
+			Expect(generatedContext.AZ).To(Equal("fake-az"))
+			Expect(generatedContext.Bootstrap).To(BeTrue())
+		})
+
+		It("has a network context section for every network", func() {
+			Expect(generatedContext.NetworkContexts).To(HaveLen(3))
+			Expect(generatedContext.NetworkContexts["fake-manual-network"].IP).To(Equal("10.0.0.5"))
+			Expect(generatedContext.NetworkContexts["fake-manual-network"].Netmask).To(Equal("255.255.255.0"))
+			Expect(generatedContext.NetworkContexts["fake-manual-network"].Gateway).To(Equal("10.0.0.1"))
+			Expect(generatedContext.NetworkContexts["fake-manual-network"].DNS).To(Equal([]string{"10.0.0.2"}))
+			Expect(generatedContext.NetworkContexts["fake-manual-network"].Default).To(Equal([]string{"dns", "gateway"}))
+			Expect(generatedContext.NetworkContexts["fake-vip-network"].IP).To(Equal("1.2.3.4"))
+		})
+
+		It("has a default network context section for the network providing the default gateway", func() {
+			Expect(generatedContext.NetworkContexts["default"]).To(Equal(generatedContext.NetworkContexts["fake-manual-network"]))
+		})
+
+		Context("when a network is named default", func() {
+			BeforeEach(func() {
+				instanceSpec.Networks["default"] = NetworkSpec{IP: "10.0.1.5"}
+			})
+
+			It("keeps the network context section of that network", func() {
+				Expect(generatedContext.NetworkContexts["default"].IP).To(Equal("10.0.1.5"))
+			})
+		})
+
+		It("has the address of the network providing the default gateway", func() {
+			Expect(generatedContext.Address).To(Equal("10.0.0.5"))
+			Expect(generatedContext.IP).To(Equal("10.0.0.5"))
+		})
+	})
+
+	It("has an empty links section", func() {
+		Expect(generatedContext.LinkContexts).To(BeEmpty())
+	})
+
+	Context("when the job consumes links", func() {
+		BeforeEach(func() {
+			instanceSpec = InstanceSpec{
+				Links: map[string]map[string]LinkSpec{
+					"fake-job-name": {
+						"fake-link": {
+							Properties: biproperty.Map{"fake-port": 8080},
+							Instances: []LinkInstanceSpec{
+								{Name: "fake-provider-job", ID: "fake-id-0", Index: 0, Address: "10.0.0.5", Bootstrap: true},
+								{Name: "fake-provider-job", ID: "fake-id-1", Index: 1, Address: "10.0.0.6"},
+							},
+						},
+					},
+					"fake-other-job-name": {
+						"fake-other-link": {},
+					},
+				},
+			}
+		})
+
+		It("has a link context section for every link consumed by the job", func() {
+			Expect(generatedContext.LinkContexts).To(HaveLen(1))
+
+			link := generatedContext.LinkContexts["fake-link"]
+			Expect(link.Address).To(Equal("10.0.0.5"))
+			Expect(link.Properties).To(Equal(biproperty.Map{"fake-port": float64(8080)}))
+			Expect(link.Instances).To(HaveLen(2))
+			Expect(link.Instances[1].Name).To(Equal("fake-provider-job"))
+			Expect(link.Instances[1].ID).To(Equal("fake-id-1"))
+			Expect(link.Instances[1].Index).To(Equal(1))
+			Expect(link.Instances[1].Address).To(Equal("10.0.0.6"))
+			Expect(link.Instances[1].Bootstrap).To(BeFalse())
+		})
+	})
+
 	var erbRenderer erbrenderer.ERBRenderer
 	getValueFor := func(key string) string {
 		logger := boshlog.NewLogger(boshlog.LevelNone)
@@ -100,6 +202,7 @@ var _ = Describe("JobEvaluationContext", func() {
 			clusterProperties,
 			globalProperties,
 			"fake-deployment-name",
+			instanceSpec,
 			logger,
 		)
 
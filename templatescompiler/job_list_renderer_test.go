@@ -36,6 +36,7 @@ var _ = Describe("JobListRenderer", func() {
 		jobProperties    biproperty.Map
 		globalProperties biproperty.Map
 		deploymentName   string
+		instanceSpec     InstanceSpec
 
 		renderedJobs []*mock_template.MockRenderedJob
 
@@ -65,6 +66,8 @@ var _ = Describe("JobListRenderer", func() {
 
 		deploymentName = "fake-deployment-name"
 
+		instanceSpec = InstanceSpec{ID: "fake-instance-id", Index: 0}
+
 		renderedJobs = []*mock_template.MockRenderedJob{
 			mock_template.NewMockRenderedJob(mockCtrl),
 			mock_template.NewMockRenderedJob(mockCtrl),
@@ -74,13 +77,13 @@ var _ = Describe("JobListRenderer", func() {
 	})
 
 	JustBeforeEach(func() {
-		expectRender0 = mockJobRenderer.EXPECT().Render(releaseJobs[0], jobProperties, globalProperties, deploymentName).Return(renderedJobs[0], nil)
-		expectRender1 = mockJobRenderer.EXPECT().Render(releaseJobs[1], jobProperties, globalProperties, deploymentName).Return(renderedJobs[1], nil)
+		expectRender0 = mockJobRenderer.EXPECT().Render(releaseJobs[0], jobProperties, globalProperties, deploymentName, instanceSpec).Return(renderedJobs[0], nil)
+		expectRender1 = mockJobRenderer.EXPECT().Render(releaseJobs[1], jobProperties, globalProperties, deploymentName, instanceSpec).Return(renderedJobs[1], nil)
 	})
 
 	Describe("Render", func() {
 		It("returns a new RenderedJobList with all the RenderedJobs", func() {
-			renderedJobList, err := jobListRenderer.Render(releaseJobs, jobProperties, globalProperties, deploymentName)
+			renderedJobList, err := jobListRenderer.Render(releaseJobs, jobProperties, globalProperties, deploymentName, instanceSpec)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(renderedJobList.All()).To(Equal([]RenderedJob{
 				renderedJobs[0],
@@ -96,7 +99,7 @@ var _ = Describe("JobListRenderer", func() {
 			It("returns an error and cleans up any sucessfully rendered jobs", func() {
 				renderedJobs[0].EXPECT().DeleteSilently()
 
-				_, err := jobListRenderer.Render(releaseJobs, jobProperties, globalProperties, deploymentName)
+				_, err := jobListRenderer.Render(releaseJobs, jobProperties, globalProperties, deploymentName, instanceSpec)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-render-error"))
 			})
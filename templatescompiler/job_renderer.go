@@ -13,7 +13,7 @@ import (
 )
 
 type JobRenderer interface {
-	Render(releaseJob bireljob.Job, jobProperties, globalProperties biproperty.Map, deploymentName string) (RenderedJob, error)
+	Render(releaseJob bireljob.Job, jobProperties, globalProperties biproperty.Map, deploymentName string, instanceSpec InstanceSpec) (RenderedJob, error)
 }
 
 type jobRenderer struct {
@@ -36,8 +36,8 @@ func NewJobRenderer(
 	}
 }
 
-func (r *jobRenderer) Render(releaseJob bireljob.Job, jobProperties, globalProperties biproperty.Map, deploymentName string) (RenderedJob, error) {
-	context := NewJobEvaluationContext(releaseJob, jobProperties, globalProperties, deploymentName, r.logger)
+func (r *jobRenderer) Render(releaseJob bireljob.Job, jobProperties, globalProperties biproperty.Map, deploymentName string, instanceSpec InstanceSpec) (RenderedJob, error) {
+	context := NewJobEvaluationContext(releaseJob, jobProperties, globalProperties, deploymentName, instanceSpec, r.logger)
 
 	sourcePath := releaseJob.ExtractedPath
 
@@ -56,6 +56,7 @@ func (r *jobRenderer) Render(releaseJob bireljob.Job, jobProperties, globalPrope
 		)
 		if err != nil {
 			defer renderedJob.DeleteSilently()
+			err = r.describeTemplateError(err, releaseJob, filepath.Join("templates", src))
 			return nil, bosherr.WrapErrorf(err, "Rendering template src: %s, dst: %s", src, dst)
 		}
 	}
@@ -67,6 +68,7 @@ func (r *jobRenderer) Render(releaseJob bireljob.Job, jobProperties, globalPrope
 	)
 	if err != nil {
 		defer renderedJob.DeleteSilently()
+		err = r.describeTemplateError(err, releaseJob, "monit")
 		return nil, bosherr.WrapError(err, "Rendering monit file")
 	}
 
@@ -81,7 +83,23 @@ func (r *jobRenderer) renderFile(sourcePath, destinationPath string, context bie
 
 	err = r.erbRenderer.Render(sourcePath, destinationPath, context)
 	if err != nil {
+		if _, ok := err.(bierbrenderer.TemplateError); ok {
+			return err
+		}
 		return bosherr.WrapErrorf(err, "Rendering template src: %s, dst: %s", sourcePath, destinationPath)
 	}
 	return nil
 }
+
+// describeTemplateError replaces the extracted template path of a template error with its path in the release job
+func (r *jobRenderer) describeTemplateError(err error, releaseJob bireljob.Job, templatePath string) error {
+	templateErr, ok := err.(bierbrenderer.TemplateError)
+	if !ok {
+		return err
+	}
+
+	templateErr.Release = releaseJob.Release
+	templateErr.ReleaseJob = releaseJob.Name
+	templateErr.Template = templatePath
+	return templateErr
+}
@@ -53,7 +53,7 @@ var _ = Describe("JobRenderer", func() {
 
 		logger := boshlog.NewLogger(boshlog.LevelNone)
 
-		context = NewJobEvaluationContext(job, jobProperties, globalProperties, "fake-deployment-name", logger)
+		context = NewJobEvaluationContext(job, jobProperties, globalProperties, "fake-deployment-name", InstanceSpec{}, logger)
 
 		fakeERBRenderer = fakebirender.NewFakeERBRender()
 
@@ -84,7 +84,7 @@ var _ = Describe("JobRenderer", func() {
 
 	Describe("Render", func() {
 		It("renders job templates", func() {
-			renderedjob, err := jobRenderer.Render(job, jobProperties, globalProperties, "fake-deployment-name")
+			renderedjob, err := jobRenderer.Render(job, jobProperties, globalProperties, "fake-deployment-name", InstanceSpec{})
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(fakeERBRenderer.RenderInputs).To(Equal([]fakebirender.RenderInput{
@@ -112,10 +112,47 @@ var _ = Describe("JobRenderer", func() {
 			})
 
 			It("returns an error", func() {
-				_, err := jobRenderer.Render(job, jobProperties, globalProperties, "fake-deployment-name")
+				_, err := jobRenderer.Render(job, jobProperties, globalProperties, "fake-deployment-name", InstanceSpec{})
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-template-render-error"))
 			})
 		})
+
+		Context("when a template fails to evaluate", func() {
+			BeforeEach(func() {
+				job.Name = "fake-release-job-name"
+				job.Release = "fake-release-name"
+
+				fakeERBRenderer.SetRenderBehavior(
+					filepath.Join(srcPath, "templates/director.yml.erb"),
+					filepath.Join(dstPath, "config/director.yml"),
+					context,
+					bierbrenderer.TemplateError{
+						Job:               "fake-job-name",
+						Template:          filepath.Join(srcPath, "templates/director.yml.erb"),
+						Line:              3,
+						Message:           "fake-message",
+						MissingProperties: []string{"fake-property"},
+					},
+				)
+			})
+
+			It("returns the template error with the release and the template path in the release job", func() {
+				_, err := jobRenderer.Render(job, jobProperties, globalProperties, "fake-deployment-name", InstanceSpec{})
+				Expect(err).To(HaveOccurred())
+
+				complexErr, ok := err.(bosherr.ComplexError)
+				Expect(ok).To(BeTrue())
+				Expect(complexErr.Cause).To(Equal(bierbrenderer.TemplateError{
+					Release:           "fake-release-name",
+					ReleaseJob:        "fake-release-job-name",
+					Job:               "fake-job-name",
+					Template:          "templates/director.yml.erb",
+					Line:              3,
+					Message:           "fake-message",
+					MissingProperties: []string{"fake-property"},
+				}))
+			})
+		})
 	})
 })
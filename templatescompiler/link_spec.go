@@ -0,0 +1,35 @@
+package templatescompiler
+
+import (
+	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+)
+
+// LinkSpec is a link consumed by a release job, which templates access with link('name')
+type LinkSpec struct {
+	// Properties are the properties of the provider that are shared by the link
+	Properties biproperty.Map
+	Instances  []LinkInstanceSpec
+}
+
+// LinkInstanceSpec is an instance of the deployment job providing a link
+type LinkInstanceSpec struct {
+	Name      string
+	ID        string
+	Index     int
+	AZ        string
+	Address   string
+	Bootstrap bool
+}
+
+// Address returns the address of the bootstrap instance providing the link
+func (s LinkSpec) Address() string {
+	for _, instance := range s.Instances {
+		if instance.Bootstrap {
+			return instance.Address
+		}
+	}
+	if len(s.Instances) > 0 {
+		return s.Instances[0].Address
+	}
+	return ""
+}
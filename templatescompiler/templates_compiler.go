@@ -48,7 +48,7 @@ func (tc templatesCompiler) Compile(releaseJobs []bireljob.Job, deploymentName s
 	globalProperties := biproperty.Map{}
 
 	return stage.Perform("Rendering job templates", func() error {
-		renderedJobList, err := tc.jobListRenderer.Render(releaseJobs, jobProperties, globalProperties, deploymentName)
+		renderedJobList, err := tc.jobListRenderer.Render(releaseJobs, jobProperties, globalProperties, deploymentName, InstanceSpec{})
 		if err != nil {
 			return err
 		}
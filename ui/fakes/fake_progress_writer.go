@@ -0,0 +1,17 @@
+package fakes
+
+type FakeProgressWriter struct {
+	Description string
+	Total       int64
+	Written     []byte
+	Finished    bool
+}
+
+func (w *FakeProgressWriter) Write(p []byte) (int, error) {
+	w.Written = append(w.Written, p...)
+	return len(p), nil
+}
+
+func (w *FakeProgressWriter) Finish() {
+	w.Finished = true
+}
@@ -5,8 +5,15 @@ import (
 )
 
 type FakeStage struct {
-	PerformCalls []PerformCall
-	SubStages    []*FakeStage
+	PerformCalls  []PerformCall
+	SubStages     []*FakeStage
+	ProgressCalls []*FakeProgressWriter
+	OutputCalls   []OutputCall
+}
+
+type OutputCall struct {
+	Name   string
+	Output string
 }
 
 type PerformCall struct {
@@ -69,3 +76,16 @@ func (s *FakeStage) PerformComplex(name string, closure func(biui.Stage) error)
 
 	return err
 }
+
+func (s *FakeStage) Progress(description string, total int64) biui.ProgressWriter {
+	progressWriter := &FakeProgressWriter{
+		Description: description,
+		Total:       total,
+	}
+	s.ProgressCalls = append(s.ProgressCalls, progressWriter)
+	return progressWriter
+}
+
+func (s *FakeStage) PrintOutput(name string, output string) {
+	s.OutputCalls = append(s.OutputCalls, OutputCall{Name: name, Output: output})
+}
@@ -5,8 +5,10 @@ import (
 )
 
 type FakeUI struct {
-	Said   []string
-	Errors []string
+	Said    []string
+	Errors  []string
+	Updates []string
+	TTY     bool
 }
 
 func (ui *FakeUI) ErrorLinef(pattern string, args ...interface{}) {
@@ -24,3 +26,11 @@ func (ui *FakeUI) BeginLinef(pattern string, args ...interface{}) {
 func (ui *FakeUI) EndLinef(pattern string, args ...interface{}) {
 	ui.Said = append(ui.Said, fmt.Sprintf(pattern, args...))
 }
+
+func (ui *FakeUI) UpdateLinef(pattern string, args ...interface{}) {
+	ui.Updates = append(ui.Updates, fmt.Sprintf(pattern, args...))
+}
+
+func (ui *FakeUI) IsTTY() bool {
+	return ui.TTY
+}
@@ -0,0 +1,54 @@
+package fmt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+)
+
+var byteUnits = []string{"KB", "MB", "GB", "TB"}
+
+func Bytes(bytes int64) string {
+	if bytes < 1024 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	value := float64(bytes) / 1024
+	unit := byteUnits[0]
+	for _, nextUnit := range byteUnits[1:] {
+		if value < 1024 {
+			break
+		}
+		value = value / 1024
+		unit = nextUnit
+	}
+
+	return fmt.Sprintf("%.1f %s", value, unit)
+}
+
+// ParseBytes is the inverse of Bytes, e.g. "512", "512 B", "1.5KB", "10GB" or "10G"
+func ParseBytes(value string) (int64, error) {
+	trimmed := strings.ToUpper(strings.TrimSpace(value))
+
+	multiplier := int64(1)
+	for i := len(byteUnits) - 1; i >= 0; i-- {
+		unit := byteUnits[i]
+		if strings.HasSuffix(trimmed, unit) || strings.HasSuffix(trimmed, unit[:1]) {
+			trimmed = strings.TrimSuffix(strings.TrimSuffix(trimmed, unit), unit[:1])
+			multiplier = int64(1) << (10 * uint(i+1))
+			break
+		}
+	}
+	if multiplier == 1 {
+		trimmed = strings.TrimSuffix(trimmed, "B")
+	}
+
+	number, err := strconv.ParseFloat(strings.TrimSpace(trimmed), 64)
+	if err != nil || number < 0 {
+		return 0, bosherr.Errorf("Invalid size '%s', expected e.g. '512MB' or '10GB'", value)
+	}
+
+	return int64(number * float64(multiplier)), nil
+}
@@ -0,0 +1,50 @@
+package fmt_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-init/ui/fmt"
+)
+
+var _ = Describe("Bytes", describeBytes)
+
+func describeBytes() {
+	It("returns bytes when less than one kilobyte", func() {
+		Expect(Bytes(0)).To(Equal("0 B"))
+		Expect(Bytes(1023)).To(Equal("1023 B"))
+	})
+
+	It("returns kilobytes with one decimal", func() {
+		Expect(Bytes(1536)).To(Equal("1.5 KB"))
+	})
+
+	It("returns the largest unit less than 1024", func() {
+		Expect(Bytes(5 * 1024 * 1024)).To(Equal("5.0 MB"))
+		Expect(Bytes(3 * 1024 * 1024 * 1024)).To(Equal("3.0 GB"))
+	})
+}
+
+var _ = Describe("ParseBytes", describeParseBytes)
+
+func describeParseBytes() {
+	It("parses plain byte counts", func() {
+		Expect(ParseBytes("512")).To(Equal(int64(512)))
+		Expect(ParseBytes("512 B")).To(Equal(int64(512)))
+	})
+
+	It("parses units case-insensitively, with or without the trailing B", func() {
+		Expect(ParseBytes("1.5KB")).To(Equal(int64(1536)))
+		Expect(ParseBytes("10gb")).To(Equal(int64(10 * 1024 * 1024 * 1024)))
+		Expect(ParseBytes("5M")).To(Equal(int64(5 * 1024 * 1024)))
+	})
+
+	It("returns an error for invalid sizes", func() {
+		_, err := ParseBytes("lots")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Invalid size 'lots', expected e.g. '512MB' or '10GB'"))
+
+		_, err = ParseBytes("-1GB")
+		Expect(err).To(HaveOccurred())
+	})
+}
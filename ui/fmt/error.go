@@ -9,6 +9,12 @@ import (
 
 var Indent = "  "
 
+// MultilineExplainer is implemented by errors that describe themselves better over several lines,
+// e.g. template errors that show an excerpt of the failing template
+type MultilineExplainer interface {
+	MultilineExplanation() []string
+}
+
 func MultilineError(err error) string {
 	return prefixingMultilineError(err, "")
 }
@@ -33,6 +39,8 @@ func prefixingMultilineError(err error, prefix string) string {
 			prefixEachLine(specificErr.StdErr, Indent),
 		}
 		return prefixEachLine(strings.Join(lines, "\n"), prefix)
+	case MultilineExplainer:
+		return prefixEachLine(strings.Join(specificErr.MultilineExplanation(), "\n"), prefix)
 	default:
 		return prefix + specificErr.Error()
 	}
@@ -71,4 +71,20 @@ func describeMultilineError() {
 			Expect(MultilineError(err)).To(Equal("outer omg:\n  Error Executing Command:\n    fake-cmd --flag with some args\n  StdOut:\n    some\n    multiline\n    stdout\n  StdErr:\n    some\n    multiline\n    stderr"))
 		})
 	})
+
+	Context("when given a MultilineExplainer", func() {
+		It("returns the explanation lines at the same indentation", func() {
+			explainer := fakeMultilineExplainer{lines: []string{"fake-explanation:", "  fake-detail"}}
+			err = bosherr.WrapError(explainer, "outer omg")
+			Expect(MultilineError(err)).To(Equal("outer omg:\n  fake-explanation:\n    fake-detail"))
+		})
+	})
 }
+
+type fakeMultilineExplainer struct {
+	lines []string
+}
+
+func (e fakeMultilineExplainer) Error() string { return "fake-explainer-error" }
+
+func (e fakeMultilineExplainer) MultilineExplanation() []string { return e.lines }
@@ -29,3 +29,11 @@ func (ui *indentingUI) BeginLinef(pattern string, args ...interface{}) {
 func (ui *indentingUI) EndLinef(pattern string, args ...interface{}) {
 	ui.parent.EndLinef(fmt.Sprintf(pattern, args...))
 }
+
+func (ui *indentingUI) UpdateLinef(pattern string, args ...interface{}) {
+	ui.parent.UpdateLinef(pattern, args...)
+}
+
+func (ui *indentingUI) IsTTY() bool {
+	return ui.parent.IsTTY()
+}
@@ -0,0 +1,125 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	boshtime "github.com/cloudfoundry/bosh-agent/time"
+
+	biuifmt "github.com/cloudfoundry/bosh-init/ui/fmt"
+)
+
+const (
+	progressRenderInterval = 500 * time.Millisecond
+	progressLogInterval    = 10 * time.Second
+)
+
+// ProgressWriter counts the bytes of a transfer written through it and reports its progress.
+// Finish must be called once the transfer is over (successfully or not).
+type ProgressWriter interface {
+	Write(p []byte) (int, error)
+	Finish()
+}
+
+type progressWriter struct {
+	ui          UI
+	description string
+	total       int64
+	timeService boshtime.Service
+	logger      boshlog.Logger
+	logTag      string
+
+	written    int64
+	startTime  time.Time
+	lastReport time.Time
+}
+
+// NewProgressWriter returns a ProgressWriter that renders the progress at the end of the current line when the ui is a TTY,
+// and logs it periodically otherwise. A total of 0 or less means the size of the transfer is unknown.
+func NewProgressWriter(ui UI, description string, total int64, timeService boshtime.Service, logger boshlog.Logger) ProgressWriter {
+	now := timeService.Now()
+	return &progressWriter{
+		ui:          ui,
+		description: description,
+		total:       total,
+		timeService: timeService,
+		logger:      logger,
+		logTag:      "progressWriter",
+		startTime:   now,
+		lastReport:  now,
+	}
+}
+
+// NewLoggingProgressWriter returns a ProgressWriter that only logs the progress periodically.
+// It is safe to use while other progress is being rendered, e.g. for concurrent transfers.
+func NewLoggingProgressWriter(description string, total int64, timeService boshtime.Service, logger boshlog.Logger) ProgressWriter {
+	return NewProgressWriter(nil, description, total, timeService, logger)
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.written += int64(len(p))
+
+	now := w.timeService.Now()
+	if now.Sub(w.lastReport) < w.reportInterval() {
+		return len(p), nil
+	}
+	w.lastReport = now
+
+	status := w.status(now)
+	if w.rendering() {
+		w.ui.UpdateLinef(" %s", status)
+	} else {
+		w.logger.Info(w.logTag, "%s: %s", w.description, status)
+	}
+
+	return len(p), nil
+}
+
+func (w *progressWriter) Finish() {
+	if w.rendering() {
+		w.ui.UpdateLinef("")
+		return
+	}
+	w.logger.Info(w.logTag, "%s: %s", w.description, w.status(w.timeService.Now()))
+}
+
+func (w *progressWriter) rendering() bool {
+	return w.ui != nil && w.ui.IsTTY()
+}
+
+func (w *progressWriter) reportInterval() time.Duration {
+	if w.rendering() {
+		return progressRenderInterval
+	}
+	return progressLogInterval
+}
+
+// status returns e.g. "12.0 MB / 40.0 MB (30%) 1.2 MB/s ETA 00:00:23"
+func (w *progressWriter) status(now time.Time) string {
+	elapsed := now.Sub(w.startTime)
+
+	var rate int64
+	if elapsed > 0 {
+		rate = int64(float64(w.written) / elapsed.Seconds())
+	}
+
+	if w.total <= 0 {
+		return fmt.Sprintf("%s %s/s", biuifmt.Bytes(w.written), biuifmt.Bytes(rate))
+	}
+
+	status := fmt.Sprintf(
+		"%s / %s (%d%%) %s/s",
+		biuifmt.Bytes(w.written),
+		biuifmt.Bytes(w.total),
+		w.written*100/w.total,
+		biuifmt.Bytes(rate),
+	)
+
+	if rate > 0 && w.written < w.total {
+		eta := time.Duration(float64(w.total-w.written)/float64(rate)) * time.Second
+		status += fmt.Sprintf(" ETA %s", biuifmt.Duration(eta))
+	}
+
+	return status
+}
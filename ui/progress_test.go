@@ -0,0 +1,122 @@
+package ui_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-init/ui"
+
+	"bytes"
+	"time"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	faketime "github.com/cloudfoundry/bosh-agent/time/fakes"
+	fakeui "github.com/cloudfoundry/bosh-init/ui/fakes"
+)
+
+var _ = Describe("ProgressWriter", func() {
+	var (
+		logOutBuffer    *bytes.Buffer
+		logger          boshlog.Logger
+		fakeUI          *fakeui.FakeUI
+		fakeTimeService *faketime.FakeService
+		now             time.Time
+	)
+
+	BeforeEach(func() {
+		logOutBuffer = bytes.NewBufferString("")
+		logger = boshlog.NewWriterLogger(boshlog.LevelDebug, logOutBuffer, bytes.NewBufferString(""))
+		fakeUI = &fakeui.FakeUI{}
+		fakeTimeService = &faketime.FakeService{}
+		now = time.Now()
+	})
+
+	Context("when the ui is a TTY", func() {
+		BeforeEach(func() {
+			fakeUI.TTY = true
+		})
+
+		It("renders bytes, percent, rate and ETA at most every half second", func() {
+			fakeTimeService.NowTimes = []time.Time{
+				now,                             // start
+				now.Add(100 * time.Millisecond), // first write, not rendered
+				now.Add(1 * time.Second),        // second write
+			}
+			progressWriter := NewProgressWriter(fakeUI, "fake-description", 4096, fakeTimeService, logger)
+
+			_, err := progressWriter.Write(make([]byte, 512))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeUI.Updates).To(BeEmpty())
+
+			_, err = progressWriter.Write(make([]byte, 512))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeUI.Updates).To(Equal([]string{
+				" 1.0 KB / 4.0 KB (25%) 1.0 KB/s ETA 00:00:03",
+			}))
+		})
+
+		It("erases the rendered progress when finished", func() {
+			progressWriter := NewProgressWriter(fakeUI, "fake-description", 4096, fakeTimeService, logger)
+			progressWriter.Finish()
+
+			Expect(fakeUI.Updates).To(Equal([]string{""}))
+			Expect(logOutBuffer.String()).To(BeEmpty())
+		})
+
+		It("omits the total and ETA when the total is unknown", func() {
+			fakeTimeService.NowTimes = []time.Time{
+				now,
+				now.Add(2 * time.Second),
+			}
+			progressWriter := NewProgressWriter(fakeUI, "fake-description", -1, fakeTimeService, logger)
+
+			_, err := progressWriter.Write(make([]byte, 2048))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeUI.Updates).To(Equal([]string{" 2.0 KB 1.0 KB/s"}))
+		})
+	})
+
+	Context("when the ui is not a TTY", func() {
+		It("logs the progress every ten seconds and when finished", func() {
+			fakeTimeService.NowTimes = []time.Time{
+				now,                       // start
+				now.Add(5 * time.Second),  // first write, not logged
+				now.Add(10 * time.Second), // second write
+				now.Add(20 * time.Second), // finish
+			}
+			progressWriter := NewProgressWriter(fakeUI, "fake-description", 4096, fakeTimeService, logger)
+
+			_, err := progressWriter.Write(make([]byte, 1024))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(logOutBuffer.String()).To(BeEmpty())
+
+			_, err = progressWriter.Write(make([]byte, 1024))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(logOutBuffer.String()).To(ContainSubstring("fake-description: 2.0 KB / 4.0 KB (50%) 204 B/s ETA 00:00:10"))
+
+			progressWriter.Finish()
+			Expect(logOutBuffer.String()).To(ContainSubstring("fake-description: 2.0 KB / 4.0 KB (50%) 102 B/s"))
+
+			Expect(fakeUI.Updates).To(BeEmpty())
+			Expect(fakeUI.Said).To(BeEmpty())
+		})
+	})
+
+	Describe("NewLoggingProgressWriter", func() {
+		It("logs the progress", func() {
+			fakeTimeService.NowTimes = []time.Time{
+				now,
+				now.Add(1 * time.Second),
+				now.Add(2 * time.Second),
+			}
+			progressWriter := NewLoggingProgressWriter("fake-description", 1024, fakeTimeService, logger)
+
+			_, err := progressWriter.Write(make([]byte, 1024))
+			Expect(err).ToNot(HaveOccurred())
+			progressWriter.Finish()
+
+			Expect(logOutBuffer.String()).To(ContainSubstring("fake-description: 1.0 KB / 1.0 KB (100%) 512 B/s"))
+		})
+	})
+})
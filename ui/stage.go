@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"strings"
 	"time"
 
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
@@ -12,6 +13,8 @@ import (
 type Stage interface {
 	Perform(name string, closure func() error) error
 	PerformComplex(name string, closure func(Stage) error) error
+	Progress(description string, total int64) ProgressWriter
+	PrintOutput(name string, output string)
 }
 
 type stage struct {
@@ -72,6 +75,25 @@ func (s *stage) PerformComplex(name string, closure func(Stage) error) error {
 	return nil
 }
 
+// Progress returns a ProgressWriter for a transfer made by the step being performed
+func (s *stage) Progress(description string, total int64) ProgressWriter {
+	return NewProgressWriter(s.ui, description, total, s.timeService, s.logger)
+}
+
+// PrintOutput prints the output of a step that has been performed (e.g. of a script it ran) into the event log,
+// indenting each of its lines under the name. Empty output is not printed.
+func (s *stage) PrintOutput(name string, output string) {
+	output = strings.TrimRight(output, "\n")
+	if output == "" {
+		return
+	}
+
+	s.ui.PrintLinef("%s:", name)
+	for _, line := range strings.Split(output, "\n") {
+		s.ui.PrintLinef("  %s", line)
+	}
+}
+
 func (s *stage) elapsedSince(startTime time.Time) string {
 	stopTime := s.timeService.Now()
 	duration := stopTime.Sub(startTime)
@@ -249,4 +249,18 @@ Failed Complex stage 1 (00:01:00)
 			Expect(actionsPerformed).To(Equal([]string{"1"}))
 		})
 	})
+
+	Describe("PrintOutput", func() {
+		It("prints each line of the output indented under its name", func() {
+			stage.PrintOutput("fake-script stdout", "fake-line-1\nfake-line-2\n")
+
+			Expect(uiOut.String()).To(Equal("fake-script stdout:\n  fake-line-1\n  fake-line-2\n"))
+		})
+
+		It("does not print empty output", func() {
+			stage.PrintOutput("fake-script stderr", "\n")
+
+			Expect(uiOut.String()).To(BeEmpty())
+		})
+	})
 })
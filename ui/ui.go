@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 )
@@ -13,17 +14,28 @@ type UI interface {
 	PrintLinef(pattern string, args ...interface{})
 	BeginLinef(pattern string, args ...interface{})
 	EndLinef(pattern string, args ...interface{})
+	UpdateLinef(pattern string, args ...interface{})
+	IsTTY() bool
 }
 
 type ui struct {
 	outWriter io.Writer
 	errWriter io.Writer
+	isTTY     bool
 	logger    boshlog.Logger
 	logTag    string
+
+	updateLength int
 }
 
 func NewConsoleUI(logger boshlog.Logger) UI {
-	return NewWriterUI(os.Stdout, os.Stderr, logger)
+	return &ui{
+		outWriter: os.Stdout,
+		errWriter: os.Stderr,
+		isTTY:     isTerminal(os.Stdout),
+		logger:    logger,
+		logTag:    "ui",
+	}
 }
 
 func NewWriterUI(outWriter, errWriter io.Writer, logger boshlog.Logger) UI {
@@ -35,6 +47,11 @@ func NewWriterUI(outWriter, errWriter io.Writer, logger boshlog.Logger) UI {
 	}
 }
 
+// IsTTY returns true when lines can be updated in place (stdout is a terminal)
+func (ui *ui) IsTTY() bool {
+	return ui.isTTY
+}
+
 // ErrorLinef starts and ends a text error line
 func (ui *ui) ErrorLinef(pattern string, args ...interface{}) {
 	message := fmt.Sprintf(pattern, args...)
@@ -47,6 +64,7 @@ func (ui *ui) ErrorLinef(pattern string, args ...interface{}) {
 // Printlnf starts and ends a text line
 func (ui *ui) PrintLinef(pattern string, args ...interface{}) {
 	message := fmt.Sprintf(pattern, args...)
+	ui.updateLength = 0
 	_, err := fmt.Fprintln(ui.outWriter, message)
 	if err != nil {
 		ui.logger.Error(ui.logTag, "UI.PrintLinef failed (message='%s'): %s", message, err)
@@ -56,6 +74,7 @@ func (ui *ui) PrintLinef(pattern string, args ...interface{}) {
 // PrintBeginf starts a text line
 func (ui *ui) BeginLinef(pattern string, args ...interface{}) {
 	message := fmt.Sprintf(pattern, args...)
+	ui.updateLength = 0
 	_, err := fmt.Fprint(ui.outWriter, message)
 	if err != nil {
 		ui.logger.Error(ui.logTag, "UI.BeginLinef failed (message='%s'): %s", message, err)
@@ -65,8 +84,35 @@ func (ui *ui) BeginLinef(pattern string, args ...interface{}) {
 // PrintEndf ends a text line
 func (ui *ui) EndLinef(pattern string, args ...interface{}) {
 	message := fmt.Sprintf(pattern, args...)
+	ui.updateLength = 0
 	_, err := fmt.Fprintln(ui.outWriter, message)
 	if err != nil {
 		ui.logger.Error(ui.logTag, "UI.EndLinef failed (message='%s'): %s", message, err)
 	}
 }
+
+// UpdateLinef replaces the text printed by the previous UpdateLinef on the current line
+// An empty message erases it, leaving the line as it was
+func (ui *ui) UpdateLinef(pattern string, args ...interface{}) {
+	message := fmt.Sprintf(pattern, args...)
+
+	output := strings.Repeat("\b", ui.updateLength) + message
+	if len(message) < ui.updateLength {
+		padding := ui.updateLength - len(message)
+		output += strings.Repeat(" ", padding) + strings.Repeat("\b", padding)
+	}
+	ui.updateLength = len(message)
+
+	_, err := fmt.Fprint(ui.outWriter, output)
+	if err != nil {
+		ui.logger.Error(ui.logTag, "UI.UpdateLinef failed (message='%s'): %s", message, err)
+	}
+}
+
+func isTerminal(file *os.File) bool {
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return fileInfo.Mode()&os.ModeCharDevice != 0
+}
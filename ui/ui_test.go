@@ -132,4 +132,36 @@ var _ = Describe("UI", func() {
 			})
 		})
 	})
+
+	Describe("UpdateLinef", func() {
+		It("replaces the previous update on the current line", func() {
+			ui.BeginLinef("fake-start")
+			ui.UpdateLinef(" 10%%")
+			ui.UpdateLinef(" 100%%")
+			Expect(uiOutBuffer.String()).To(Equal("fake-start 10%\b\b\b\b 100%"))
+		})
+
+		It("erases the previous update when it is longer", func() {
+			ui.BeginLinef("fake-start")
+			ui.UpdateLinef(" 10%%")
+			ui.UpdateLinef("")
+			ui.EndLinef(" fake-end")
+			Expect(uiOutBuffer.String()).To(Equal("fake-start 10%\b\b\b\b    \b\b\b\b fake-end\n"))
+		})
+
+		It("does not erase text printed before the line was begun", func() {
+			ui.BeginLinef("fake-start")
+			ui.UpdateLinef(" 10%%")
+			ui.EndLinef(" fake-end")
+			ui.BeginLinef("fake-next")
+			ui.UpdateLinef(" 20%%")
+			Expect(uiOutBuffer.String()).To(Equal("fake-start 10% fake-end\nfake-next 20%"))
+		})
+	})
+
+	Describe("IsTTY", func() {
+		It("returns false for writers", func() {
+			Expect(ui.IsTTY()).To(BeFalse())
+		})
+	})
 })